@@ -116,6 +116,7 @@ func (h *AgentHandler) CreateAgentConfig(c *gin.Context) {
 		AnalysisModelID: body.AnalysisModelID,
 		SummaryModelID:  body.SummaryModelID,
 		ModelRouting:    body.ModelRouting,
+		RoutingPolicy:   body.RoutingPolicy,
 		// Prompt
 		PromptTemplateID: body.PromptTemplateID,
 		SystemPrompt:     body.SystemPrompt,
@@ -130,6 +131,8 @@ func (h *AgentHandler) CreateAgentConfig(c *gin.Context) {
 		RAGEnabled:       body.RAGEnabled,
 		RAGTopK:          body.RAGTopK,
 		RAGMinScore:      body.RAGMinScore,
+		// 结构化输出
+		OutputSchema: body.OutputSchema,
 		// 状态
 		Status: body.Status,
 		// 扩展
@@ -180,6 +183,7 @@ func (h *AgentHandler) UpdateAgentConfig(c *gin.Context) {
 		AnalysisModelID: body.AnalysisModelID,
 		SummaryModelID:  body.SummaryModelID,
 		ModelRouting:    body.ModelRouting,
+		RoutingPolicy:   body.RoutingPolicy,
 		// Prompt
 		PromptTemplateID: body.PromptTemplateID,
 		SystemPrompt:     body.SystemPrompt,
@@ -194,6 +198,8 @@ func (h *AgentHandler) UpdateAgentConfig(c *gin.Context) {
 		RAGEnabled:       body.RAGEnabled,
 		RAGTopK:          body.RAGTopK,
 		RAGMinScore:      body.RAGMinScore,
+		// 结构化输出
+		OutputSchema: body.OutputSchema,
 		// 状态
 		Status: body.Status,
 		// 扩展
@@ -1,5 +1,7 @@
 package agents
 
+import "backend/internal/agent"
+
 // createAgentConfigRequest 创建 Agent 配置请求体。
 type createAgentConfigRequest struct {
 	AgentType   string `json:"agentType" binding:"required"`
@@ -19,6 +21,9 @@ type createAgentConfigRequest struct {
 	SummaryModelID  string            `json:"summaryModelId"`  // 摘要任务模型
 	ModelRouting    map[string]string `json:"modelRouting"`    // 自定义任务类型路由
 
+	// 成本感知路由策略
+	RoutingPolicy *agent.ModelRoutingPolicy `json:"routingPolicy"`
+
 	// Prompt 配置
 	PromptTemplateID string `json:"promptTemplateId"`
 	SystemPrompt     string `json:"systemPrompt"`
@@ -37,6 +42,9 @@ type createAgentConfigRequest struct {
 	RAGTopK          int      `json:"ragTopK"`          // RAG 检索数量
 	RAGMinScore      float64  `json:"ragMinScore"`      // RAG 最小相似度
 
+	// 结构化输出（JSON Schema）
+	OutputSchema map[string]any `json:"outputSchema"`
+
 	// 状态
 	Status string `json:"status"` // active, disabled
 
@@ -62,6 +70,9 @@ type updateAgentConfigRequest struct {
 	SummaryModelID  *string           `json:"summaryModelId"`
 	ModelRouting    map[string]string `json:"modelRouting"`
 
+	// 成本感知路由策略
+	RoutingPolicy *agent.ModelRoutingPolicy `json:"routingPolicy"`
+
 	// Prompt 配置
 	PromptTemplateID *string `json:"promptTemplateId"`
 	SystemPrompt     *string `json:"systemPrompt"`
@@ -80,6 +91,9 @@ type updateAgentConfigRequest struct {
 	RAGTopK          *int     `json:"ragTopK"`
 	RAGMinScore      *float64 `json:"ragMinScore"`
 
+	// 结构化输出（JSON Schema）
+	OutputSchema map[string]any `json:"outputSchema"`
+
 	// 状态
 	Status *string `json:"status"`
 
@@ -208,13 +208,7 @@ func (h *AgentExecuteHandler) ExecuteStream(c *gin.Context) {
 			}
 
 			// 发送 SSE 数据
-			if chunk.Done {
-				c.SSEvent("done", gin.H{"done": true})
-				return false
-			} else {
-				c.SSEvent("message", gin.H{"content": chunk.Content})
-				return true
-			}
+			return writeStreamChunk(c, chunk)
 
 		case err, ok := <-errChan:
 			if ok && err != nil {
@@ -446,13 +440,7 @@ func (h *AgentExecuteHandler) ExecuteByTypeStream(c *gin.Context) {
 				return false
 			}
 
-			if chunk.Done {
-				c.SSEvent("done", gin.H{"done": true})
-				return false
-			} else {
-				c.SSEvent("message", gin.H{"content": chunk.Content})
-				return true
-			}
+			return writeStreamChunk(c, chunk)
 
 		case err, ok := <-errChan:
 			if ok && err != nil {
@@ -462,3 +450,19 @@ func (h *AgentExecuteHandler) ExecuteByTypeStream(c *gin.Context) {
 		}
 	})
 }
+
+// writeStreamChunk 将 Agent 响应块写为 SSE 事件，返回是否继续推送
+// 事件类型：message（内容增量）、tool_call / tool_result（工具调用过程）、done（结束，携带用量等元数据）
+func writeStreamChunk(c *gin.Context, chunk runtime.AgentChunk) bool {
+	switch {
+	case chunk.Done:
+		c.SSEvent("done", gin.H{"done": true, "metadata": chunk.Metadata})
+		return false
+	case chunk.Event != "":
+		c.SSEvent(chunk.Event, chunk.ToolCall)
+		return true
+	default:
+		c.SSEvent("message", gin.H{"content": chunk.Content})
+		return true
+	}
+}
@@ -0,0 +1,251 @@
+package agents
+
+import (
+	"errors"
+	"net/http"
+
+	"backend/api/handlers/common"
+	"backend/internal/agent"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExperimentHandler Agent A/B 实验处理器
+type ExperimentHandler struct {
+	service *agent.ABTestService
+}
+
+// NewExperimentHandler 创建实验处理器
+func NewExperimentHandler(service *agent.ABTestService) *ExperimentHandler {
+	return &ExperimentHandler{service: service}
+}
+
+// CreateExperimentRequest 创建实验请求
+type CreateExperimentRequest struct {
+	AgentID       string             `json:"agent_id" binding:"required"`
+	Name          string             `json:"name" binding:"required"`
+	Description   string             `json:"description"`
+	Variants      []agent.Variant    `json:"variants" binding:"required,min=2"`
+	TrafficSplit  map[string]float64 `json:"traffic_split"`
+	Metrics       []string           `json:"metrics"`
+	PrimaryMetric string             `json:"primary_metric"`
+	StopRule      agent.StopRule     `json:"stop_rule"`
+}
+
+// CompleteExperimentRequest 结束实验请求
+type CompleteExperimentRequest struct {
+	WinnerVariantID string `json:"winner_variant_id"`
+	Reason          string `json:"reason"`
+}
+
+// ExperimentResultsResponse 实验结果与显著性
+type ExperimentResultsResponse struct {
+	Results      *agent.ExperimentResults  `json:"results"`
+	Significance *agent.SignificanceResult `json:"significance"`
+}
+
+// ListExperiments 查询实验列表
+// @Summary 查询 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param agent_id query string false "Agent ID"
+// @Param status query string false "状态 (draft/running/paused/completed)"
+// @Success 200 {object} common.APIResponse{data=[]agent.Experiment}
+// @Router /api/agents/experiments [get]
+func (h *ExperimentHandler) ListExperiments(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	experiments, err := h.service.ListExperiments(c.Request.Context(), tenantID,
+		c.Query("agent_id"), agent.ExperimentStatus(c.Query("status")))
+	if err != nil {
+		common.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	common.Success(c, experiments)
+}
+
+// CreateExperiment 创建实验
+// @Summary 创建 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateExperimentRequest true "实验定义"
+// @Success 200 {object} common.APIResponse{data=agent.Experiment}
+// @Router /api/agents/experiments [post]
+func (h *ExperimentHandler) CreateExperiment(c *gin.Context) {
+	var req CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.Error(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	exp := &agent.Experiment{
+		TenantID:      c.GetString("tenant_id"),
+		AgentID:       req.AgentID,
+		Name:          req.Name,
+		Description:   req.Description,
+		Variants:      req.Variants,
+		TrafficSplit:  req.TrafficSplit,
+		Metrics:       req.Metrics,
+		PrimaryMetric: req.PrimaryMetric,
+		StopRule:      req.StopRule,
+		CreatedBy:     c.GetString("user_id"),
+	}
+	if err := h.service.CreateExperiment(c.Request.Context(), exp); err != nil {
+		common.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	common.Success(c, exp)
+}
+
+// GetExperiment 获取实验详情
+// @Summary 获取 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Success 200 {object} common.APIResponse{data=agent.Experiment}
+// @Router /api/agents/experiments/{id} [get]
+func (h *ExperimentHandler) GetExperiment(c *gin.Context) {
+	exp, err := h.service.GetExperiment(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, exp)
+}
+
+// StartExperiment 启动实验
+// @Summary 启动 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Success 200 {object} common.APIResponse
+// @Router /api/agents/experiments/{id}/start [post]
+func (h *ExperimentHandler) StartExperiment(c *gin.Context) {
+	if err := h.service.StartExperiment(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// PauseExperiment 暂停实验
+// @Summary 暂停 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Success 200 {object} common.APIResponse
+// @Router /api/agents/experiments/{id}/pause [post]
+func (h *ExperimentHandler) PauseExperiment(c *gin.Context) {
+	if err := h.service.PauseExperiment(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// CompleteExperiment 结束实验并指定胜出变体
+// @Summary 结束 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Param request body CompleteExperimentRequest false "胜出变体"
+// @Success 200 {object} common.APIResponse
+// @Router /api/agents/experiments/{id}/complete [post]
+func (h *ExperimentHandler) CompleteExperiment(c *gin.Context) {
+	var req CompleteExperimentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			common.Error(c, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "手动结束"
+	}
+	if err := h.service.CompleteExperiment(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), req.WinnerVariantID, req.Reason); err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// DeleteExperiment 删除实验
+// @Summary 删除 Agent A/B 实验
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Success 200 {object} common.APIResponse
+// @Router /api/agents/experiments/{id} [delete]
+func (h *ExperimentHandler) DeleteExperiment(c *gin.Context) {
+	if err := h.service.DeleteExperiment(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// GetResults 获取实验结果与显著性检验
+// @Summary 获取 Agent A/B 实验结果
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "实验 ID"
+// @Success 200 {object} common.APIResponse{data=ExperimentResultsResponse}
+// @Router /api/agents/experiments/{id}/results [get]
+func (h *ExperimentHandler) GetResults(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	results, err := h.service.GetResults(c.Request.Context(), tenantID, c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	significance, err := h.service.CalculateSignificance(c.Request.Context(), tenantID, c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, ExperimentResultsResponse{Results: results, Significance: significance})
+}
+
+// RecordFeedback 为试验补充质量评分或采纳结果
+// @Summary 记录 Agent A/B 试验反馈
+// @Tags Agent Experiments
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param trialId path string true "试验 ID（执行结果元数据 experiment_trial_id）"
+// @Param request body agent.TrialFeedback true "反馈"
+// @Success 200 {object} common.APIResponse
+// @Router /api/agents/experiments/trials/{trialId}/feedback [post]
+func (h *ExperimentHandler) RecordFeedback(c *gin.Context) {
+	var feedback agent.TrialFeedback
+	if err := c.ShouldBindJSON(&feedback); err != nil {
+		common.Error(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	if err := h.service.RecordFeedback(c.Request.Context(), c.GetString("tenant_id"), c.Param("trialId"), &feedback); err != nil {
+		h.fail(c, err)
+		return
+	}
+	common.Success(c, nil)
+}
+
+// fail 按错误类型返回状态码
+func (h *ExperimentHandler) fail(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, agent.ErrExperimentNotFound), errors.Is(err, agent.ErrTrialNotFound):
+		common.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, agent.ErrExperimentNotRunning), errors.Is(err, agent.ErrVariantNotFound):
+		common.Error(c, http.StatusConflict, err.Error())
+	default:
+		common.Error(c, http.StatusBadRequest, err.Error())
+	}
+}
@@ -1,10 +1,12 @@
 package audit
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	response "backend/api/handlers/common"
+	auditpkg "backend/internal/audit"
 	"backend/internal/auth"
 	"backend/internal/models"
 	"backend/pkg/types"
@@ -15,6 +17,12 @@ import (
 // AuditHandler 审计日志处理器
 type AuditHandler struct {
 	auditService *models.AuditLogService
+	chain        ChainVerifier
+}
+
+// ChainVerifier 租户审计日志哈希链校验（由 audit.HashChain 实现）
+type ChainVerifier interface {
+	Verify(ctx context.Context, tenantID string) (*auditpkg.ChainVerification, error)
 }
 
 // NewAuditHandler 创建审计日志处理器
@@ -24,6 +32,41 @@ func NewAuditHandler(auditService *models.AuditLogService) *AuditHandler {
 	}
 }
 
+// SetChainVerifier 设置哈希链校验器
+func (h *AuditHandler) SetChainVerifier(chain ChainVerifier) {
+	h.chain = chain
+}
+
+// VerifyIntegrity 校验当前租户审计日志哈希链
+// @Summary 校验审计日志完整性
+// @Description 按序号重算当前租户全部链上日志的哈希，核对签名检查点与链头，返回第一个断链位置
+// @Tags Audit
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} auditpkg.ChainVerification
+// @Failure 403 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Router /api/audit/integrity [get]
+func (h *AuditHandler) VerifyIntegrity(c *gin.Context) {
+	if h.chain == nil {
+		c.JSON(http.StatusServiceUnavailable, response.ErrorResponse{Success: false, Message: "审计日志哈希链未启用"})
+		return
+	}
+	userCtx, _ := auth.GetUserContext(c)
+	if userCtx.TenantID == "" {
+		c.JSON(http.StatusForbidden, response.ErrorResponse{Success: false, Message: "无权访问"})
+		return
+	}
+
+	result, err := h.chain.Verify(c.Request.Context(), userCtx.TenantID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Success: false, Message: "校验失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // QueryLogsRequest 查询审计日志请求
 type QueryLogsRequest struct {
 	UserID        string   `json:"user_id"`
@@ -369,6 +369,7 @@ func detectContentType(filename string) string {
 		".pdf":  "application/pdf",
 		".doc":  "application/msword",
 		".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		".epub": "application/epub+zip",
 	}
 
 	if ct, exists := contentTypes[ext]; exists {
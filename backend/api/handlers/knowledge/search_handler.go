@@ -31,8 +31,9 @@ func NewSearchHandler(
 
 // SearchRequest 检索请求
 type SearchRequest struct {
-	Query string `json:"query" binding:"required,min=1"`
-	TopK  int    `json:"top_k"`
+	Query  string            `json:"query" binding:"required,min=1"`
+	TopK   int               `json:"top_k"`
+	Filter *rag.SearchFilter `json:"filter,omitempty"` // 元数据过滤（eq/in/range/exists、文档 ID）
 }
 
 // Search 语义检索
@@ -61,6 +62,10 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "参数错误: " + err.Error()})
 		return
 	}
+	if err := req.Filter.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "参数错误: " + err.Error()})
+		return
+	}
 
 	// 获取用户上下文
 	userCtx, _ := auth.GetUserContext(c)
@@ -91,6 +96,7 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		TenantID:        userCtx.TenantID,
 		Query:           req.Query,
 		TopK:            topK,
+		Filter:          req.Filter,
 	}
 
 	results, err := h.ragService.Search(c.Request.Context(), searchReq)
@@ -4,8 +4,10 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"backend/internal/ai"
 	"backend/internal/common"
 	"backend/internal/models"
 
@@ -27,6 +29,12 @@ type ModelHandler struct {
 	service            *models.ModelService
 	discoveryService   *models.ModelDiscoveryService
 	credentialService  *models.ModelCredentialService
+	circuits           circuitStateSource
+}
+
+// circuitStateSource 提供各 租户:提供商 熔断状态的组件（ClientFactory）
+type circuitStateSource interface {
+	CircuitStates() map[string]ai.CircuitState
 }
 
 // NewModelHandler 创建 ModelHandler 实例
@@ -38,6 +46,31 @@ func NewModelHandler(service *models.ModelService, discoveryService *models.Mode
 	}
 }
 
+// SetCircuitStateSource 设置熔断状态来源，未设置时熔断状态接口返回空列表
+func (h *ModelHandler) SetCircuitStateSource(source circuitStateSource) {
+	h.circuits = source
+}
+
+// GetCircuitStates 查询当前租户各提供商的熔断状态
+// @Summary 查询提供商熔断状态
+// @Description 获取当前租户回退链中各模型提供商的熔断状态（closed/open/half_open）
+// @Tags Models
+// @Produce json
+// @Success 200 {object} map[string]any
+// @Router /api/models/circuits [get]
+func (h *ModelHandler) GetCircuitStates(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	states := make(map[string]ai.CircuitState)
+	if h.circuits != nil {
+		for key, state := range h.circuits.CircuitStates() {
+			if provider, ok := strings.CutPrefix(key, tenantID+":"); ok {
+				states[provider] = state
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"circuits": states})
+}
+
 // ListModels 查询模型列表
 // @Summary 查询模型列表
 // @Description 获取AI模型列表，支持按提供商、类型、状态筛选
@@ -1,7 +1,10 @@
 package notifications
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"backend/api/handlers/common"
 	"backend/internal/notification"
@@ -67,8 +70,9 @@ func (h *WebhookHandler) RegisterEndpoint(c *gin.Context) {
 		Events:  req.Events,
 	}
 
-	if err := h.service.RegisterEndpoint(endpoint); err != nil {
-		common.Error(c, http.StatusInternalServerError, err.Error())
+	endpoint.CreatedBy = c.GetString("user_id")
+	if err := h.service.RegisterEndpoint(c.Request.Context(), c.GetString("tenant_id"), endpoint); err != nil {
+		h.handleError(c, err)
 		return
 	}
 
@@ -83,7 +87,11 @@ func (h *WebhookHandler) RegisterEndpoint(c *gin.Context) {
 // @Success 200 {object} common.APIResponse{data=[]notification.WebhookEndpoint}
 // @Router /api/webhooks [get]
 func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
-	endpoints := h.service.ListEndpoints()
+	endpoints, err := h.service.ListEndpoints(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		common.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
 	common.Success(c, endpoints)
 }
 
@@ -114,8 +122,8 @@ func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
 		Events:  req.Events,
 	}
 
-	if err := h.service.UpdateEndpoint(id, update); err != nil {
-		common.Error(c, http.StatusNotFound, err.Error())
+	if err := h.service.UpdateEndpoint(c.Request.Context(), c.GetString("tenant_id"), id, update); err != nil {
+		h.handleError(c, err)
 		return
 	}
 
@@ -132,15 +140,15 @@ func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
 func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := h.service.DeleteEndpoint(id); err != nil {
-		common.Error(c, http.StatusNotFound, err.Error())
+	if err := h.service.DeleteEndpoint(c.Request.Context(), c.GetString("tenant_id"), id); err != nil {
+		h.handleError(c, err)
 		return
 	}
 
 	common.Success(c, nil)
 }
 
-// SetActive 设置端点活动状态
+// SetActive 设置端点活动状态（重新启用自动禁用的端点会清零连续失败次数）
 // @Summary 设置Webhook端点活动状态
 // @Tags Webhook
 // @Security BearerAuth
@@ -154,8 +162,8 @@ func (h *WebhookHandler) SetActive(c *gin.Context) {
 	id := c.Param("id")
 	active := c.Query("active") == "true"
 
-	if err := h.service.SetEndpointActive(id, active); err != nil {
-		common.Error(c, http.StatusNotFound, err.Error())
+	if err := h.service.SetEndpointActive(c.Request.Context(), c.GetString("tenant_id"), id, active); err != nil {
+		h.handleError(c, err)
 		return
 	}
 
@@ -186,13 +194,101 @@ func (h *WebhookHandler) TestWebhook(c *gin.Context) {
 		}
 	}
 
-	deliveries, err := h.service.EmitSync(c.Request.Context(), req.EventType, req.Payload)
+	delivery, err := h.service.TestEndpoint(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), req.EventType, req.Payload)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	common.Success(c, delivery)
+}
+
+// ListDeliveries 查询投递记录
+// @Summary 查询Webhook投递记录
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param endpoint_id query string false "端点ID"
+// @Param event_type query string false "事件类型"
+// @Param status query string false "状态（pending/retrying/succeeded/dead）"
+// @Param limit query int false "数量"
+// @Param offset query int false "偏移"
+// @Success 200 {object} common.APIResponse
+// @Router /api/webhooks/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	query := &notification.WebhookDeliveryQuery{
+		EndpointID: c.Query("endpoint_id"),
+		EventType:  c.Query("event_type"),
+		Status:     notification.WebhookDeliveryStatus(c.Query("status")),
+		Limit:      limit,
+		Offset:     offset,
+	}
+
+	deliveries, total, err := h.service.ListDeliveries(c.Request.Context(), c.GetString("tenant_id"), query)
 	if err != nil {
 		common.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	common.Success(c, deliveries)
+	common.Success(c, gin.H{"items": deliveries, "total": total})
+}
+
+// ReplayDelivery 重放投递
+// @Summary 重放Webhook投递（事件ID不变）
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "投递ID"
+// @Success 200 {object} common.APIResponse{data=notification.WebhookDelivery}
+// @Router /api/webhooks/deliveries/{id}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	delivery, err := h.service.ReplayDelivery(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	common.Success(c, delivery)
+}
+
+// ReplayFailed 重放端点的失败投递
+// @Summary 重放Webhook端点的全部死信投递
+// @Tags Webhook
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "端点ID"
+// @Param since query string false "起始时间（RFC3339）"
+// @Success 200 {object} common.APIResponse
+// @Router /api/webhooks/{id}/replay-failed [post]
+func (h *WebhookHandler) ReplayFailed(c *gin.Context) {
+	var since time.Time
+	if v := c.Query("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			common.Error(c, http.StatusBadRequest, "since 格式错误，应为 RFC3339")
+			return
+		}
+		since = parsed
+	}
+
+	replayed, err := h.service.ReplayFailed(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), since)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	common.Success(c, gin.H{"replayed": replayed})
+}
+
+func (h *WebhookHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notification.ErrWebhookEndpointNotFound), errors.Is(err, notification.ErrWebhookDeliveryNotFound):
+		common.Error(c, http.StatusNotFound, err.Error())
+	default:
+		common.Error(c, http.StatusBadRequest, err.Error())
+	}
 }
 
 // GetEventTypes 获取支持的事件类型
@@ -1,6 +1,7 @@
 package subscription
 
 import (
+	"errors"
 	"net/http"
 
 	"backend/internal/auth"
@@ -386,35 +387,114 @@ func (h *Handler) RenewSubscription(c *gin.Context) {
 	c.JSON(http.StatusOK, sub)
 }
 
+// ChangePlanRequest 更换套餐请求
+type ChangePlanRequest struct {
+	SubscriptionID string `json:"subscriptionId" binding:"required"`
+	NewPlanID      string `json:"newPlanId" binding:"required"`
+}
+
 // ChangePlan 更换套餐
 // @Summary 更换套餐
-// @Description 更换订阅套餐
+// @Description 升级立即生效并按剩余周期折算差价（补缴生成账单，多付部分退回积分）；降级在当前周期结束时生效
 // @Tags Subscription
 // @Accept json
 // @Produce json
-// @Param request body map[string]any true "更换请求"
-// @Success 200 {object} map[string]any
+// @Param request body ChangePlanRequest true "更换请求"
+// @Success 200 {object} subscription.PlanChangeResult
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/subscription/change-plan [post]
 func (h *Handler) ChangePlan(c *gin.Context) {
+	var req ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.ChangePlan(c.Request.Context(), req.SubscriptionID, req.NewPlanID)
+	if err != nil {
+		if result != nil {
+			// 套餐已变更但差价结算失败
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": result})
+			return
+		}
+		c.JSON(planChangeStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PreviewPlanChange 预览更换套餐
+// @Summary 预览更换套餐
+// @Description 返回生效时间与按剩余周期折算的补缴/退回金额，不做任何修改
+// @Tags Subscription
+// @Accept json
+// @Produce json
+// @Param request body ChangePlanRequest true "更换请求"
+// @Success 200 {object} subscription.PlanChangePreview
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/subscription/change-plan/preview [post]
+func (h *Handler) PreviewPlanChange(c *gin.Context) {
+	var req ChangePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.service.PreviewPlanChange(c.Request.Context(), req.SubscriptionID, req.NewPlanID)
+	if err != nil {
+		c.JSON(planChangeStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// CancelScheduledChange 取消计划中的套餐变更
+// @Summary 取消计划中的降级
+// @Description 取消在周期结束时生效的套餐变更
+// @Tags Subscription
+// @Accept json
+// @Produce json
+// @Param request body map[string]any true "取消请求"
+// @Success 200 {object} map[string]any
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/subscription/change-plan/cancel [post]
+func (h *Handler) CancelScheduledChange(c *gin.Context) {
 	var req struct {
 		SubscriptionID string `json:"subscriptionId" binding:"required"`
-		NewPlanID      string `json:"newPlanId" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	sub, err := h.service.ChangePlan(c.Request.Context(), req.SubscriptionID, req.NewPlanID)
+	sub, err := h.service.CancelScheduledChange(c.Request.Context(), req.SubscriptionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(planChangeStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, sub)
 }
 
+// planChangeStatus 套餐变更错误对应的状态码
+func planChangeStatus(err error) int {
+	switch {
+	case errors.Is(err, subscription.ErrSubscriptionNotFound), errors.Is(err, subscription.ErrPlanNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, subscription.ErrSamePlan), errors.Is(err, subscription.ErrSubscriptionInactive),
+		errors.Is(err, subscription.ErrCurrencyMismatch), errors.Is(err, subscription.ErrNoScheduledChange):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ========== 试用管理 ==========
 
 // StartTrial 开始试用
@@ -2,7 +2,9 @@ package workflows
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	workflow "backend/internal/workflow"
 	"backend/internal/workflow/approval"
@@ -147,7 +149,7 @@ func (h *AutomationHandler) ApproveRequest(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(approvalErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -159,7 +161,17 @@ func (h *AutomationHandler) ApproveRequest(c *gin.Context) {
 		Where("id = ?", approvalID).
 		First(&approvalReq).Error
 
-	if err == nil && approvalReq.ExecutionID != "" {
+	// 多级审批尚未全部通过时仅记录意见
+	if err == nil && approvalReq.Status == "pending" {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "审批意见已记录，等待其他审批人或后续阶段",
+			"status":       approvalReq.Status,
+			"currentStage": approvalReq.CurrentStage,
+		})
+		return
+	}
+
+	if err == nil && approvalReq.ExecutionID != "" && h.automationEngine != nil {
 		// 异步恢复工作流执行
 		go func() {
 			ctx := context.Background()
@@ -198,17 +210,113 @@ func (h *AutomationHandler) RejectRequest(c *gin.Context) {
 	)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(approvalErrorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	var approvalReq workflow.ApprovalRequest
+	if err := h.db.WithContext(c.Request.Context()).
+		Where("id = ?", approvalID).
+		First(&approvalReq).Error; err == nil && approvalReq.Status == "pending" {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "审批意见已记录，当前阶段仍可能达到通过人数",
+			"status":       approvalReq.Status,
+			"currentStage": approvalReq.CurrentStage,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "审批请求已拒绝",
 	})
 }
 
+// GetApprovalDecisions 获取审批请求的各阶段审批意见
+// GET /api/v1/workflows/approvals/:id/decisions
+func (h *AutomationHandler) GetApprovalDecisions(c *gin.Context) {
+	decisions, err := h.approvalManager.ListDecisions(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"decisions": decisions,
+		"total":     len(decisions),
+	})
+}
+
+// CreateDelegation 将当前用户的审批委托给他人
+// POST /api/v1/workflows/approvals/delegations
+func (h *AutomationHandler) CreateDelegation(c *gin.Context) {
+	var req struct {
+		DelegateID string     `json:"delegateId" binding:"required"`
+		StartsAt   time.Time  `json:"startsAt"`
+		EndsAt     *time.Time `json:"endsAt"`
+		Reason     string     `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	delegation, err := h.approvalManager.CreateDelegation(c.Request.Context(), &approval.DelegationInput{
+		TenantID:    c.GetString("tenant_id"),
+		DelegatorID: c.GetString("user_id"),
+		DelegateID:  req.DelegateID,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delegation": delegation})
+}
+
+// ListDelegations 列出当前用户相关的审批委托
+// GET /api/v1/workflows/approvals/delegations
+func (h *AutomationHandler) ListDelegations(c *gin.Context) {
+	delegations, err := h.approvalManager.ListDelegations(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delegations": delegations,
+		"total":       len(delegations),
+	})
+}
+
+// RevokeDelegation 撤销审批委托
+// DELETE /api/v1/workflows/approvals/delegations/:id
+func (h *AutomationHandler) RevokeDelegation(c *gin.Context) {
+	if err := h.approvalManager.RevokeDelegation(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "审批委托已撤销"})
+}
+
+func approvalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, approval.ErrNotApprover):
+		return http.StatusForbidden
+	case errors.Is(err, approval.ErrAlreadyDecided):
+		return http.StatusConflict
+	case errors.Is(err, approval.ErrNoEligibleApprovers):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ResendNotification 手动重发审批通知
 func (h *AutomationHandler) ResendNotification(c *gin.Context) {
 	approvalID := c.Param("id")
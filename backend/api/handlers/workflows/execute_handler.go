@@ -1,6 +1,7 @@
 package workflows
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -29,13 +30,25 @@ func NewWorkflowExecuteHandler(engine *executor.Engine, db *gorm.DB) *WorkflowEx
 
 // ExecuteWorkflowRequest 执行工作流请求
 type ExecuteWorkflowRequest struct {
-	Input map[string]any `json:"input" binding:"required"`
+	Input   map[string]any `json:"input" binding:"required"`
+	Version int            `json:"version"` // 指定执行的定义版本，为空时使用已发布版本
+}
+
+// ForkExecutionRequest 分叉执行请求：从指定步骤起重新运行，可覆盖该步骤的输入与 Agent 配置
+type ForkExecutionRequest struct {
+	StepID               string         `json:"step_id" binding:"required"`
+	Input                map[string]any `json:"input"`
+	AgentType            string         `json:"agent_type"`
+	AgentID              *string        `json:"agent_id"`
+	Role                 string         `json:"role"`
+	SystemPromptOverride string         `json:"system_prompt_override"`
 }
 
 // WorkflowExecutionDetailResponse 执行详情响应。
 type WorkflowExecutionDetailResponse struct {
-	Execution workflow.WorkflowExecution `json:"execution"`
-	Tasks     []workflow.WorkflowTask    `json:"tasks"`
+	Execution workflow.WorkflowExecution   `json:"execution"`
+	Tasks     []workflow.WorkflowTask      `json:"tasks"`
+	Children  []workflow.WorkflowExecution `json:"children"` // 子工作流步骤发起的子执行
 }
 
 // WorkflowExecutionListResponse 执行列表响应。
@@ -81,7 +94,7 @@ func (h *WorkflowExecuteHandler) ExecuteWorkflow(c *gin.Context) {
 	}
 
 	// 执行工作流
-	result, err := h.engine.Execute(c.Request.Context(), workflowID, tenantID, userID, req.Input)
+	result, err := h.engine.ExecuteVersion(c.Request.Context(), workflowID, tenantID, userID, req.Version, req.Input)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Success: false, Message: err.Error()})
 		return
@@ -96,11 +109,77 @@ func (h *WorkflowExecuteHandler) ExecuteWorkflow(c *gin.Context) {
 		StartedAt:   result.StartedAt,
 		CompletedAt: result.CompletedAt,
 		DurationMs:  result.Duration.Milliseconds(),
+		Meta: map[string]interface{}{
+			"workflow_version": result.WorkflowVersion,
+		},
 	}
 
 	c.JSON(http.StatusAccepted, resp)
 }
 
+// ForkExecution 从指定步骤分叉执行
+// @Summary 从指定步骤重新运行执行
+// @Description 复用父执行中上游步骤的输出，只重新执行所选步骤及其下游步骤
+// @Tags Workflows
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "父执行 ID"
+// @Param request body ForkExecutionRequest true "分叉配置"
+// @Success 202 {object} WorkflowExecutionResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 500 {object} response.ErrorResponse
+// @Router /api/executions/{id}/fork [post]
+func (h *WorkflowExecuteHandler) ForkExecution(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	parentID := c.Param("id")
+
+	var req ForkExecutionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var override *workflow.StepOverride
+	if len(req.Input) > 0 || req.AgentType != "" || req.AgentID != nil || req.Role != "" || req.SystemPromptOverride != "" {
+		override = &workflow.StepOverride{
+			Input:                req.Input,
+			AgentType:            req.AgentType,
+			AgentID:              req.AgentID,
+			Role:                 req.Role,
+			SystemPromptOverride: req.SystemPromptOverride,
+		}
+	}
+
+	result, err := h.engine.Fork(c.Request.Context(), tenantID, userID, parentID, req.StepID, override)
+	if err != nil {
+		switch {
+		case errors.Is(err, executor.ErrExecutionNotFound):
+			c.JSON(http.StatusNotFound, response.ErrorResponse{Success: false, Message: err.Error()})
+		case errors.Is(err, executor.ErrInvalidFork):
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ErrorResponse{Success: false, Message: err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, WorkflowExecutionResponse{
+		ExecutionID: result.ExecutionID,
+		WorkflowID:  result.WorkflowID,
+		Status:      result.Status,
+		Input:       result.Input,
+		StartedAt:   result.StartedAt,
+		Meta: map[string]interface{}{
+			"parent_execution_id": parentID,
+			"forked_from_step":    req.StepID,
+			"workflow_version":    result.WorkflowVersion,
+		},
+	})
+}
+
 // GetExecution 查询执行详情
 // @Summary 查询执行详情
 // @Tags Workflows
@@ -133,9 +212,16 @@ func (h *WorkflowExecuteHandler) GetExecution(c *gin.Context) {
 		Order("created_at ASC").
 		Find(&tasks)
 
+	// 查询子执行
+	children := []workflow.WorkflowExecution{}
+	h.db.Where("caller_execution_id = ? AND tenant_id = ?", executionID, tenantID).
+		Order("created_at ASC").
+		Find(&children)
+
 	c.JSON(http.StatusOK, WorkflowExecutionDetailResponse{
 		Execution: execution,
 		Tasks:     tasks,
+		Children:  children,
 	})
 }
 
@@ -148,6 +234,7 @@ func (h *WorkflowExecuteHandler) GetExecution(c *gin.Context) {
 // @Param page query int false "页码"
 // @Param page_size query int false "每页数量"
 // @Param status query string false "执行状态"
+// @Param parent_execution_id query string false "父执行 ID（查询分叉执行）"
 // @Success 200 {object} WorkflowExecutionListResponse
 // @Failure 500 {object} response.ErrorResponse
 // @Router /api/workflows/{id}/executions [get]
@@ -158,7 +245,8 @@ func (h *WorkflowExecuteHandler) ListExecutions(c *gin.Context) {
 	// 分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	status := c.Query("status") // 可选过滤
+	status := c.Query("status")                // 可选过滤
+	parentID := c.Query("parent_execution_id") // 可选：只看某次执行的分叉
 
 	// 查询执行列表
 	var executions []workflow.WorkflowExecution
@@ -168,6 +256,9 @@ func (h *WorkflowExecuteHandler) ListExecutions(c *gin.Context) {
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	if parentID != "" {
+		query = query.Where("parent_execution_id = ?", parentID)
+	}
 
 	countQuery := query.Session(&gorm.Session{})
 	if err := countQuery.Model(&workflow.WorkflowExecution{}).Count(&total).Error; err != nil {
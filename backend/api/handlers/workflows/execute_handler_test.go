@@ -25,7 +25,7 @@ func setupWorkflowTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("open sqlite: %v", err)
 	}
-	if err := db.AutoMigrate(&workflow.Workflow{}, &workflow.WorkflowExecution{}, &workflow.WorkflowTask{}); err != nil {
+	if err := db.AutoMigrate(&workflow.Workflow{}, &workflow.WorkflowExecution{}, &workflow.WorkflowTask{}, &workflow.WorkflowVersion{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	return db
@@ -1,6 +1,7 @@
 package workflows
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -141,10 +142,15 @@ func (h *WorkflowHandler) UpdateWorkflow(c *gin.Context) {
 		})
 		return
 	}
+	req.UpdatedBy = c.GetString("user_id")
 
 	wf, err := h.service.UpdateWorkflow(c.Request.Context(), tenantID, workflowID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		status := http.StatusBadRequest
+		if errors.Is(err, workflow.ErrVersionConflict) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, response.ErrorResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -244,3 +250,126 @@ func (h *WorkflowHandler) GetExampleWorkflow(c *gin.Context) {
 type workflowValidateRequest struct {
 	Definition map[string]any `json:"definition" binding:"required"`
 }
+
+// ListWorkflowVersions 查询工作流版本历史
+// @Summary 查询工作流版本历史
+// @Description 按版本号倒序返回工作流的不可变定义版本（不含定义内容）
+// @Tags Workflows
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "工作流 ID"
+// @Success 200 {object} response.APIResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/workflows/{id}/versions [get]
+func (h *WorkflowHandler) ListWorkflowVersions(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	workflowID := c.Param("id")
+
+	versions, err := h.service.ListVersions(c.Request.Context(), tenantID, workflowID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: versions})
+}
+
+// GetWorkflowVersion 获取工作流指定版本
+// @Summary 获取工作流指定版本
+// @Tags Workflows
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "工作流 ID"
+// @Param version path int true "版本号"
+// @Success 200 {object} workflow.WorkflowVersion
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/workflows/{id}/versions/{version} [get]
+func (h *WorkflowHandler) GetWorkflowVersion(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	workflowID := c.Param("id")
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number <= 0 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "无效的版本号"})
+		return
+	}
+
+	version, err := h.service.GetVersion(c.Request.Context(), tenantID, workflowID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, version)
+}
+
+// DiffWorkflowVersions 比较工作流的两个版本
+// @Summary 比较工作流的两个版本
+// @Description 返回新增/删除/修改的节点、连线变化以及其他定义字段的差异
+// @Tags Workflows
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "工作流 ID"
+// @Param v1 query int true "基准版本号"
+// @Param v2 query int true "对比版本号"
+// @Success 200 {object} workflow.WorkflowVersionDiff
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/workflows/{id}/versions/diff [get]
+func (h *WorkflowHandler) DiffWorkflowVersions(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	workflowID := c.Param("id")
+
+	v1, err1 := strconv.Atoi(c.Query("v1"))
+	v2, err2 := strconv.Atoi(c.Query("v2"))
+	if err1 != nil || err2 != nil || v1 <= 0 || v2 <= 0 {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "v1 和 v2 必须为有效的版本号"})
+		return
+	}
+
+	diff, err := h.service.DiffVersions(c.Request.Context(), tenantID, workflowID, v1, v2)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// publishWorkflowRequest 发布工作流请求
+type publishWorkflowRequest struct {
+	Version int `json:"version"` // 为空时发布最新版本
+}
+
+// PublishWorkflow 发布工作流版本
+// @Summary 发布工作流版本
+// @Description 将指定版本（默认最新版本）设为已发布版本，之后的执行与触发器默认使用该版本
+// @Tags Workflows
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "工作流 ID"
+// @Param request body publishWorkflowRequest false "发布的版本号"
+// @Success 200 {object} workflow.Workflow
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/workflows/{id}/publish [post]
+func (h *WorkflowHandler) PublishWorkflow(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	workflowID := c.Param("id")
+
+	var req publishWorkflowRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+			return
+		}
+	}
+
+	wf, err := h.service.PublishVersion(c.Request.Context(), tenantID, workflowID, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, wf)
+}
@@ -0,0 +1,135 @@
+package workspace
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"backend/api/handlers/common"
+	"backend/internal/workspace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// manuscriptContentTypes 导出文件的 MIME 类型
+var manuscriptContentTypes = map[string]string{
+	string(workspace.FormatEPUB): "application/epub+zip",
+	string(workspace.FormatDOCX): "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+}
+
+// ManuscriptExportHandler 作品手稿导出处理器
+type ManuscriptExportHandler struct {
+	service *workspace.ManuscriptExportService
+}
+
+// NewManuscriptExportHandler 创建手稿导出处理器
+func NewManuscriptExportHandler(service *workspace.ManuscriptExportService) *ManuscriptExportHandler {
+	return &ManuscriptExportHandler{service: service}
+}
+
+// CreateManuscriptExportRequest 创建导出任务请求
+type CreateManuscriptExportRequest struct {
+	Format string `json:"format" binding:"required,oneof=epub docx"`
+	workspace.ManuscriptExportOptions
+}
+
+// CreateExport 创建导出任务
+// @Summary 导出作品手稿
+// @Description 按大纲顺序将整部作品异步导出为 EPUB 3 或 DOCX
+// @Tags Workspace
+// @Accept json
+// @Produce json
+// @Param id path string true "作品ID"
+// @Param request body CreateManuscriptExportRequest true "导出请求"
+// @Success 202 {object} common.Response{data=workspace.ManuscriptExportJob}
+// @Router /api/workspace/works/{id}/exports [post]
+func (h *ManuscriptExportHandler) CreateExport(c *gin.Context) {
+	var req CreateManuscriptExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Success: false, Message: "请求参数错误: " + err.Error()})
+		return
+	}
+
+	job, err := h.service.CreateExport(c.Request.Context(), &workspace.ManuscriptExportRequest{
+		TenantID: c.GetString("tenant_id"),
+		WorkID:   c.Param("id"),
+		UserID:   c.GetString("user_id"),
+		Format:   workspace.ExportFormat(req.Format),
+		Options:  req.ManuscriptExportOptions,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, common.ErrorResponse{Success: false, Message: "创建导出任务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, common.APIResponse{Success: true, Data: job})
+}
+
+// ListExports 列出作品的导出任务
+// @Summary 导出任务列表
+// @Tags Workspace
+// @Produce json
+// @Param id path string true "作品ID"
+// @Param limit query int false "数量上限"
+// @Success 200 {object} common.Response{data=[]workspace.ManuscriptExportJob}
+// @Router /api/workspace/works/{id}/exports [get]
+func (h *ManuscriptExportHandler) ListExports(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	jobs, err := h.service.ListExports(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, common.ErrorResponse{Success: false, Message: "获取导出任务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.APIResponse{Success: true, Data: jobs})
+}
+
+// GetExport 获取导出任务状态与进度
+// @Summary 导出任务详情
+// @Tags Workspace
+// @Produce json
+// @Param jobId path string true "导出任务ID"
+// @Success 200 {object} common.Response{data=workspace.ManuscriptExportJob}
+// @Router /api/workspace/exports/{jobId} [get]
+func (h *ManuscriptExportHandler) GetExport(c *gin.Context) {
+	job, err := h.service.GetExport(c.Request.Context(), c.GetString("tenant_id"), c.Param("jobId"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, workspace.ErrManuscriptExportNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, common.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, common.APIResponse{Success: true, Data: job})
+}
+
+// DownloadExport 下载导出文件
+// @Summary 下载导出文件
+// @Tags Workspace
+// @Produce octet-stream
+// @Param jobId path string true "导出任务ID"
+// @Success 200 {file} file
+// @Router /api/workspace/exports/{jobId}/download [get]
+func (h *ManuscriptExportHandler) DownloadExport(c *gin.Context) {
+	job, file, err := h.service.OpenExport(c.Request.Context(), c.GetString("tenant_id"), c.Param("jobId"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, workspace.ErrManuscriptExportNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, workspace.ErrManuscriptExportNotReady):
+			status = http.StatusConflict
+		}
+		c.JSON(status, common.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	defer file.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": job.FileName})
+	c.DataFromReader(http.StatusOK, job.FileSize, manuscriptContentTypes[job.Format], file, map[string]string{
+		"Content-Disposition": disposition,
+	})
+}
@@ -586,6 +586,45 @@ func (h *Handler) ImportFromText(c *gin.Context) {
 	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: result})
 }
 
+// ImportManuscript 导入 EPUB / DOCX / TXT 手稿
+// @Summary 导入手稿文件为卷/章节
+// @Description EPUB 按 spine 顺序与目录标题、DOCX 按标题样式生成卷与章节，正文保留为 Markdown
+// @Tags Workspace
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "手稿文件（.epub/.docx/.txt）"
+// @Param parentId formData string false "父目录ID"
+// @Success 200 {object} response.APIResponse
+// @Router /api/workspace/import/manuscript [post]
+func (h *Handler) ImportManuscript(c *gin.Context) {
+	tenantID := c.GetString("tenant_id")
+	userID := c.GetString("user_id")
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "获取文件失败: " + err.Error()})
+		return
+	}
+	defer file.Close()
+
+	var parentID *string
+	if value := c.PostForm("parentId"); value != "" {
+		parentID = &value
+	}
+	result, err := h.svc.ImportManuscript(c.Request.Context(), &workspaceSvc.ImportManuscriptRequest{
+		TenantID: tenantID,
+		ParentID: parentID,
+		FileName: header.Filename,
+		Reader:   file,
+		UserID:   userID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: result})
+}
+
 // ExportWorkspaceRequest 导出工作空间请求
 type ExportWorkspaceRequest struct {
 	WorkspaceID          string `json:"workspace_id" binding:"required"`
@@ -1,16 +1,31 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	agentSvc "backend/internal/agent"
+	"backend/internal/agent/runtime"
+	"backend/internal/ai"
+	"backend/internal/billing"
 	"backend/internal/config"
+	"backend/internal/credits"
+	"backend/internal/logger"
+	modelSvc "backend/internal/models"
+	"backend/internal/notification"
 	"backend/internal/rag"
+	"backend/internal/subscription"
+	tenantSvc "backend/internal/tenant"
+	workflowpkg "backend/internal/workflow"
+	workspaceSvc "backend/internal/workspace"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -204,6 +219,57 @@ func parseRedisAddr(addr string) (string, int) {
 
 // --- 向量存储初始化 ---
 
+// initReranker 初始化检索重排序器，未配置时返回 nil
+func initReranker(cfg *config.Config, provider ai.ModelProvider, models *modelSvc.ModelService) (rag.Reranker, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	rcfg := cfg.RAG.Reranker
+	switch strings.ToLower(strings.TrimSpace(rcfg.Type)) {
+	case "":
+		return nil, nil
+	case "simple":
+		return rag.NewSimpleReranker(), nil
+	case "cross_encoder":
+		return rag.NewCrossEncoderReranker(rag.CrossEncoderOptions{
+			Endpoint:       rcfg.Endpoint,
+			APIKey:         rcfg.APIKey,
+			Model:          rcfg.Model,
+			Protocol:       rag.RerankProtocol(rcfg.Protocol),
+			BatchSize:      rcfg.BatchSize,
+			MaxConcurrency: rcfg.MaxConcurrency,
+			TimeoutSeconds: rcfg.TimeoutSeconds,
+		})
+	case "llm":
+		return rag.NewLLMReranker(provider, rag.LLMRerankerOptions{
+			ModelID:         rcfg.Model,
+			DefaultTenantID: rcfg.TenantID,
+			ModelResolver:   rerankModelResolver{models: models},
+			BatchSize:       rcfg.BatchSize,
+			MaxConcurrency:  rcfg.MaxConcurrency,
+			TimeoutSeconds:  rcfg.TimeoutSeconds,
+		})
+	default:
+		return nil, fmt.Errorf("不支持的重排序器类型: %s", rcfg.Type)
+	}
+}
+
+// rerankModelResolver 将配置的打分模型映射为调用方租户下同一提供商、同一模型标识的模型
+type rerankModelResolver struct {
+	models *modelSvc.ModelService
+}
+
+func (r rerankModelResolver) ResolveRerankModel(ctx context.Context, tenantID, sourceTenantID, sourceModelID string) (string, error) {
+	if r.models == nil {
+		return "", nil
+	}
+	model, err := r.models.FindEquivalentModel(ctx, tenantID, sourceTenantID, sourceModelID)
+	if err != nil || model == nil {
+		return "", err
+	}
+	return model.ID, nil
+}
+
 // initVectorStore 初始化向量存储
 func initVectorStore(cfg *config.Config, db *gorm.DB) (rag.VectorStore, error) {
 	if cfg != nil {
@@ -228,3 +294,146 @@ func initVectorStore(cfg *config.Config, db *gorm.DB) (rag.VectorStore, error) {
 
 	return rag.NewPGVectorStore(db)
 }
+
+// --- MCP 服务端适配 ---
+
+// registryAgentRunner 将 runtime.Registry 适配为 mcp.AgentRunner
+type registryAgentRunner struct {
+	registry *runtime.Registry
+}
+
+// RunAgent 执行 Agent 并返回文本输出
+func (r registryAgentRunner) RunAgent(ctx context.Context, tenantID, userID, agentID, content string, variables map[string]any) (string, error) {
+	result, err := r.registry.Execute(ctx, tenantID, agentID, &runtime.AgentInput{
+		Content:   content,
+		Variables: variables,
+		Context:   &runtime.AgentContext{TenantID: tenantID, UserID: userID},
+	})
+	if err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// --- A/B 实验反馈适配 ---
+
+// experimentStagingObserver 将暂存文件审核结果回传为实验试验的采纳情况
+type experimentStagingObserver struct {
+	experiments *agentSvc.ABTestService
+}
+
+// OnStagingReviewed 实现 workspace.StagingReviewObserver
+func (o experimentStagingObserver) OnStagingReviewed(ctx context.Context, staging *workspaceSvc.WorkspaceStagingFile, accepted bool) {
+	if err := o.experiments.RecordStagingReview(ctx, staging.TenantID, staging.Metadata, accepted); err != nil {
+		logger.Warn("记录实验采纳结果失败", zap.String("staging_id", staging.ID), zap.Error(err))
+	}
+}
+
+// subscriptionProrationBiller 套餐变更差价结算：补缴部分生成账单，多付部分以积分退回
+type subscriptionProrationBiller struct {
+	billing *billing.Service
+	credits *credits.Service
+}
+
+// CreateProrationBill 实现 subscription.ProrationBiller
+func (b subscriptionProrationBiller) CreateProrationBill(tx *gorm.DB, sub *subscription.UserSubscription, preview *subscription.PlanChangePreview) (string, error) {
+	now := time.Now()
+	due := now.AddDate(0, 0, 7)
+	bill, err := b.billing.CreateBillTx(tx, &billing.CreateBillRequest{
+		TenantID:           sub.TenantID,
+		UserID:             sub.UserID,
+		BillType:           "subscription",
+		Title:              fmt.Sprintf("套餐升级差价：%s → %s", preview.FromPlanCode, preview.ToPlanCode),
+		Description:        fmt.Sprintf("按当前周期剩余 %.2f%% 折算", preview.RemainingRatio*100),
+		Amount:             preview.AmountDue,
+		Currency:           preview.Currency,
+		BillingPeriodStart: &now,
+		BillingPeriodEnd:   preview.PeriodEnd,
+		DueDate:            &due,
+		SubscriptionID:     sub.ID,
+		Items: []billing.BillItem{
+			{Name: preview.ToPlanCode, Description: "新套餐剩余周期费用", Quantity: 1, UnitPrice: preview.ProratedCharge, Amount: preview.ProratedCharge},
+			{Name: preview.FromPlanCode, Description: "原套餐未使用部分抵扣", Quantity: 1, UnitPrice: -preview.UnusedCredit, Amount: -preview.UnusedCredit},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return bill.ID, nil
+}
+
+// CreditProration 实现 subscription.ProrationBiller
+func (b subscriptionProrationBiller) CreditProration(tx *gorm.DB, sub *subscription.UserSubscription, preview *subscription.PlanChangePreview) (string, error) {
+	record, err := b.credits.GiftTx(tx, &credits.GiftRequest{
+		TenantID:    sub.TenantID,
+		UserID:      sub.UserID,
+		Amount:      preview.CreditPoints,
+		Type:        credits.TransactionTypeRefund,
+		Description: fmt.Sprintf("套餐变更差价退回 %.2f %s（%s → %s）", preview.CreditAmount, preview.Currency, preview.FromPlanCode, preview.ToPlanCode),
+	})
+	if err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+// subscriptionPaymentObserver 订阅账单（如升级差价）支付成功后累计订阅实付金额
+type subscriptionPaymentObserver struct {
+	subscriptions *subscription.Service
+}
+
+// OnBillPaid 实现 billing.BillPaidObserver
+func (o subscriptionPaymentObserver) OnBillPaid(ctx context.Context, bill *billing.Bill) {
+	if bill.SubscriptionID == "" {
+		return
+	}
+	if err := o.subscriptions.RecordPayment(ctx, bill.SubscriptionID, bill.TotalAmount); err != nil {
+		logger.Warn("累计订阅实付金额失败", zap.String("bill_id", bill.ID), zap.Error(err))
+	}
+}
+
+// --- Webhook 事件适配 ---
+
+// workflowWebhookObserver 工作流执行结束后向所属租户投递 workflow.completed / workflow.failed 事件
+type workflowWebhookObserver struct {
+	webhooks *notification.WebhookService
+}
+
+// OnExecutionFinished 实现 executor.ExecutionObserver
+func (o workflowWebhookObserver) OnExecutionFinished(ctx context.Context, execution *workflowpkg.WorkflowExecution) {
+	eventType := notification.EventWorkflowCompleted
+	if execution.Status == "failed" {
+		eventType = notification.EventWorkflowFailed
+	}
+	payload := map[string]any{
+		"execution_id": execution.ID,
+		"workflow_id":  execution.WorkflowID,
+		"user_id":      execution.UserID,
+		"status":       execution.Status,
+		"started_at":   execution.StartedAt,
+		"completed_at": execution.CompletedAt,
+	}
+	if execution.ErrorMessage != "" {
+		payload["error"] = execution.ErrorMessage
+	}
+	if err := o.webhooks.Emit(ctx, execution.TenantID, eventType, payload); err != nil {
+		logger.Warn("投递工作流 Webhook 事件失败", zap.String("execution_id", execution.ID), zap.Error(err))
+	}
+}
+
+// --- 代码解释器沙箱适配 ---
+
+// tenantSandboxSelector 从租户扩展配置 extraConfig.codeSandbox 读取代码解释器沙箱后端
+type tenantSandboxSelector struct {
+	configs tenantSvc.TenantConfigService
+}
+
+// SandboxFor 实现 builtin.SandboxSelector，未配置时返回空字符串使用默认后端
+func (s tenantSandboxSelector) SandboxFor(ctx context.Context, tenantID string) string {
+	cfg, err := s.configs.GetConfig(tenantSvc.WithTenantContext(ctx, tenantSvc.TenantContext{TenantID: tenantID}))
+	if err != nil || cfg == nil {
+		return ""
+	}
+	sandbox, _ := cfg.ExtraConfig["codeSandbox"].(string)
+	return sandbox
+}
@@ -14,6 +14,9 @@ func RegisterRoutes(router *gin.Engine, container *AppContainer, handlers *Handl
 	// 认证 API（公开，不需要 JWT）
 	registerAuthRoutes(router, handlers)
 
+	// MCP 服务端（公开端点，由 API Key 认证）
+	registerMCPServerEndpoint(router, handlers)
+
 	// 主 API 组（向后兼容）
 	api := router.Group("/api")
 	api.Use(auth.AuthMiddleware(container.JWTService), middlewarepkg.GinTenantContextMiddleware(logger.Get()))
@@ -136,10 +139,15 @@ func registerAPIRoutes(apiGroup *gin.RouterGroup, c *AppContainer, h *Handlers)
 
 	// 工作空间模板系统
 	registerWorkspaceTemplateRoutes(apiGroup, h)
+	registerManuscriptExportRoutes(apiGroup, h)
 
 	// 工具市场
 	registerMarketplaceRoutes(apiGroup, h, adminGuard)
 
+	// 外部 MCP 服务
+	registerMCPRoutes(apiGroup, h, adminGuard)
+	registerMCPExposureRoutes(apiGroup, h, adminGuard)
+
 	// 用户资料
 	registerUserRoutes(apiGroup, h)
 
@@ -224,6 +232,7 @@ func registerModelRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.
 	{
 		// 查询接口（用户可用）
 		modelsGroup.GET("", h.Model.ListModels)
+		modelsGroup.GET("/circuits", h.Model.GetCircuitStates)
 		modelsGroup.GET("/:id", h.Model.GetModel)
 		modelsGroup.GET("/:id/stats", h.Model.GetModelStats)
 
@@ -313,6 +322,20 @@ func registerAgentRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.
 			perfGroup.GET("/failed", h.AgentPerformance.GetFailedRuns)
 			perfGroup.GET("/tokens", h.AgentPerformance.GetTokenUsageTrend)
 		}
+
+		// Agent A/B 实验
+		expGroup := agentsGroup.Group("/experiments")
+		{
+			expGroup.GET("", h.Experiment.ListExperiments)
+			expGroup.GET("/:id", h.Experiment.GetExperiment)
+			expGroup.GET("/:id/results", h.Experiment.GetResults)
+			expGroup.POST("/trials/:trialId/feedback", h.Experiment.RecordFeedback)
+			expGroup.POST("", adminGuard, h.Experiment.CreateExperiment)
+			expGroup.POST("/:id/start", adminGuard, h.Experiment.StartExperiment)
+			expGroup.POST("/:id/pause", adminGuard, h.Experiment.PauseExperiment)
+			expGroup.POST("/:id/complete", adminGuard, h.Experiment.CompleteExperiment)
+			expGroup.DELETE("/:id", adminGuard, h.Experiment.DeleteExperiment)
+		}
 	}
 }
 
@@ -327,6 +350,10 @@ func registerWorkflowRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard g
 		workflowsGroup.DELETE("/:id", h.Workflow.DeleteWorkflow)
 		workflowsGroup.POST("/validate", h.Workflow.ValidateWorkflow)
 		workflowsGroup.GET("/:id/stats", h.Workflow.GetWorkflowStats)
+		workflowsGroup.GET("/:id/versions", h.Workflow.ListWorkflowVersions)
+		workflowsGroup.GET("/:id/versions/diff", h.Workflow.DiffWorkflowVersions)
+		workflowsGroup.GET("/:id/versions/:version", h.Workflow.GetWorkflowVersion)
+		workflowsGroup.POST("/:id/publish", h.Workflow.PublishWorkflow)
 		workflowsGroup.GET("/example", h.Workflow.GetExampleWorkflow)
 
 		// 工作流执行
@@ -347,6 +374,10 @@ func registerWorkflowRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard g
 				approvals.POST("/:id/approve", h.Automation.ApproveRequest)
 				approvals.POST("/:id/reject", h.Automation.RejectRequest)
 				approvals.POST("/:id/resend", h.Automation.ResendNotification)
+				approvals.GET("/:id/decisions", h.Automation.GetApprovalDecisions)
+				approvals.GET("/delegations", h.Automation.ListDelegations)
+				approvals.POST("/delegations", h.Automation.CreateDelegation)
+				approvals.DELETE("/delegations/:id", h.Automation.RevokeDelegation)
 			}
 
 			// 审批规则管理
@@ -377,6 +408,7 @@ func registerWorkflowRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard g
 	executions := apiGroup.Group("/executions")
 	{
 		executions.GET("/:id", h.WfExecute.GetExecution)
+		executions.POST("/:id/fork", h.WfExecute.ForkExecution)
 	}
 }
 
@@ -391,6 +423,7 @@ func registerAuditRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.
 		auditGroup.GET("/logs/:id", adminGuard, h.Audit.GetLog)
 		auditGroup.GET("/users/:userID/activity", adminGuard, h.Audit.GetUserActivity)
 		auditGroup.POST("/logs/export", adminGuard, h.Audit.ExportLogs)
+		auditGroup.GET("/integrity", adminGuard, h.Audit.VerifyIntegrity)
 	}
 }
 
@@ -495,6 +528,7 @@ func registerWorkspaceRoutes(apiGroup *gin.RouterGroup, h *Handlers) {
 		workspaceGroup.POST("/batch/copy", h.Workspace.BatchCopyNodes)
 		workspaceGroup.POST("/autosave", h.Workspace.AutoSaveContent)
 		workspaceGroup.POST("/import/text", h.Workspace.ImportFromText)
+		workspaceGroup.POST("/import/manuscript", h.Workspace.ImportManuscript)
 
 		// 智能体产出物
 		workspaceGroup.GET("/artifact-types", h.Artifact.GetArtifactTypes)
@@ -543,6 +577,9 @@ func registerNotificationRoutes(apiGroup *gin.RouterGroup, h *Handlers) {
 			webhookGroup.DELETE("/:id", h.Webhook.DeleteEndpoint)
 			webhookGroup.PUT("/:id/active", h.Webhook.SetActive)
 			webhookGroup.POST("/:id/test", h.Webhook.TestWebhook)
+			webhookGroup.POST("/:id/replay-failed", h.Webhook.ReplayFailed)
+			webhookGroup.GET("/deliveries", h.Webhook.ListDeliveries)
+			webhookGroup.POST("/deliveries/:id/replay", h.Webhook.ReplayDelivery)
 		}
 	}
 }
@@ -685,6 +722,8 @@ func registerSubscriptionRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGua
 		subGroup.POST("/cancel", h.Subscription.CancelSubscription)
 		subGroup.POST("/renew", h.Subscription.RenewSubscription)
 		subGroup.POST("/change-plan", h.Subscription.ChangePlan)
+		subGroup.POST("/change-plan/preview", h.Subscription.PreviewPlanChange)
+		subGroup.POST("/change-plan/cancel", h.Subscription.CancelScheduledChange)
 		subGroup.POST("/trial/start", h.Subscription.StartTrial)
 		subGroup.POST("/trial/convert", h.Subscription.ConvertTrial)
 
@@ -1021,6 +1060,65 @@ func registerWorkspaceTemplateRoutes(apiGroup *gin.RouterGroup, h *Handlers) {
 	}
 }
 
+// registerManuscriptExportRoutes 注册作品手稿导出路由
+func registerManuscriptExportRoutes(apiGroup *gin.RouterGroup, h *Handlers) {
+	if h.ManuscriptExport == nil {
+		return
+	}
+
+	workspaceGroup := apiGroup.Group("/workspace")
+	{
+		workspaceGroup.POST("/works/:id/exports", h.ManuscriptExport.CreateExport)
+		workspaceGroup.GET("/works/:id/exports", h.ManuscriptExport.ListExports)
+		workspaceGroup.GET("/exports/:jobId", h.ManuscriptExport.GetExport)
+		workspaceGroup.GET("/exports/:jobId/download", h.ManuscriptExport.DownloadExport)
+	}
+}
+
+// registerMCPRoutes 注册外部 MCP 服务管理路由
+func registerMCPRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.HandlerFunc) {
+	if h.MCP == nil {
+		return
+	}
+
+	servers := apiGroup.Group("/mcp/servers")
+	{
+		servers.GET("", h.MCP.ListServers)
+		servers.GET("/:id", h.MCP.GetServer)
+		servers.GET("/:id/tools", h.MCP.ListServerTools)
+
+		// 管理员接口（配置变更会注册/注销工具）
+		servers.POST("", adminGuard, h.MCP.CreateServer)
+		servers.PUT("/:id", adminGuard, h.MCP.UpdateServer)
+		servers.DELETE("/:id", adminGuard, h.MCP.DeleteServer)
+		servers.POST("/:id/refresh", adminGuard, h.MCP.RefreshServer)
+	}
+}
+
+// registerMCPExposureRoutes 注册 MCP 服务端发布配置路由
+func registerMCPExposureRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.HandlerFunc) {
+	if h.MCPServer == nil {
+		return
+	}
+
+	exposure := apiGroup.Group("/mcp/exposure")
+	{
+		exposure.GET("", h.MCPServer.GetExposure)
+		exposure.PUT("", adminGuard, h.MCPServer.UpdateExposure)
+	}
+}
+
+// registerMCPServerEndpoint 注册 MCP 协议端点（API Key 认证，不走 JWT）
+func registerMCPServerEndpoint(router *gin.Engine, h *Handlers) {
+	if h.MCPServer == nil {
+		return
+	}
+
+	// 不提供服务端主动推送的 GET 流，客户端对 GET 收到 405 后只使用 POST
+	router.POST("/mcp", h.MCPServer.Serve)
+	router.DELETE("/mcp", h.MCPServer.Serve)
+}
+
 // registerMarketplaceRoutes 注册工具市场路由
 func registerMarketplaceRoutes(apiGroup *gin.RouterGroup, h *Handlers, adminGuard gin.HandlerFunc) {
 	if h.Marketplace == nil {
@@ -28,6 +28,7 @@ import (
 	plotHandlers "backend/api/handlers/plot"
 	userHandlers "backend/api/handlers/user"
 	marketplaceHandlers "backend/internal/tools/marketplace"
+	"backend/internal/tools/mcp"
 	auditHandlers "backend/api/handlers/audit"
 	authHandlers "backend/api/handlers/auth"
 	cacheHandlers "backend/api/handlers/cache"
@@ -123,6 +124,7 @@ type AppContainer struct {
 	WorkspaceService       *workspaceSvc.Service
 	SessionService         *modelSvc.SessionService
 	AuditService           *modelSvc.AuditLogService
+	AuditChain             *auditpkg.HashChain
 	CommandService         *command.Service
 
 	// RAG 相关
@@ -135,6 +137,7 @@ type AppContainer struct {
 	AgentRegistry *runtime.Registry
 	AsyncClient   *runtime.AsyncClient
 	ClientFactory *ai.ClientFactory
+	ABTestService *agentSvc.ABTestService
 	
 	// 缓存
 	DiskCache     *cache.DiskCache        // L3硬盘缓存
@@ -186,9 +189,18 @@ type AppContainer struct {
 	// 工作空间模板服务
 	WorkspaceTemplateService *workspaceSvc.TemplateService
 
+	// 作品手稿导出服务
+	ManuscriptExportService *workspaceSvc.ManuscriptExportService
+
 	// 工具市场服务
 	MarketplaceService *marketplaceHandlers.Service
 
+	// 外部 MCP 服务连接
+	MCPClientService *mcp.Service
+
+	// MCP 服务端（对外发布 Agent、知识库与工作区）
+	MCPServer *mcp.Server
+
 	// API Key 服务
 	APIKeyService *auth.APIKeyService
 
@@ -212,6 +224,11 @@ type AppContainer struct {
 	WorkflowEngine   *executor.Engine
 	AutomationEngine *executor.AutomationEngine
 	ApprovalManager  *approval.Manager
+	CronScheduler    *executor.CronScheduler
+
+	// 工作流触发器：触发时提交固定版本的执行
+	WebhookTriggerService   *workflowSvc.WebhookTriggerService
+	ConditionTriggerService *workflowSvc.ConditionTriggerService
 
 	// 通知
 	WSHub                      *notification.WebSocketHub
@@ -236,6 +253,7 @@ type Handlers struct {
 	Agent              *agents.AgentHandler
 	AgentExecute       *agents.AgentExecuteHandler
 	AgentPerformance   *agents.PerformanceHandler
+	Experiment         *agents.ExperimentHandler
 	Workflow           *workflows.WorkflowHandler
 	WfExecute          *workflows.WorkflowExecuteHandler
 	Automation         *workflows.AutomationHandler
@@ -269,8 +287,11 @@ type Handlers struct {
 	MultiModel         *multimodelHandlers.Handler
 	Plot               *plotHandlers.Handler
 	Marketplace        *marketplaceHandlers.Handler
+	MCP                *mcp.Handler
+	MCPServer          *mcp.ServerHandler
 	Message            *notificationHandlers.MessageHandler
 	WorkspaceTemplate  *workspaceHandlers.TemplateHandler
+	ManuscriptExport   *workspaceHandlers.ManuscriptExportHandler
 	APIKey             *apikeyHandlers.Handler
 	User               *userHandlers.Handler
 	Metrics            *metricsHandlers.Handler
@@ -360,10 +381,12 @@ func (c *AppContainer) InitHandlers() *Handlers {
 	h := &Handlers{}
 
 	h.Model = models.NewModelHandler(c.ModelService, c.ModelDiscoveryService, c.ModelCredentialService)
+	h.Model.SetCircuitStateSource(c.ClientFactory)
 	h.Template = templates.NewTemplateHandler(c.TemplateService)
 	h.Agent = agents.NewAgentHandler(c.AgentService)
 	h.AgentExecute = agents.NewAgentExecuteHandler(c.AgentRegistry, c.AsyncClient)
 	h.AgentPerformance = agents.NewPerformanceHandler(agentSvc.NewPerformanceService(c.DB))
+	h.Experiment = agents.NewExperimentHandler(c.ABTestService)
 	h.Workflow = workflows.NewWorkflowHandler(c.WorkflowService)
 	h.WfExecute = workflows.NewWorkflowExecuteHandler(c.WorkflowEngine, c.DB)
 	h.Workspace = workspaceHandlers.NewHandler(c.WorkspaceService, c.ToolExecutor, c.AgentRegistry)
@@ -372,6 +395,7 @@ func (c *AppContainer) InitHandlers() *Handlers {
 	h.Files = filesHandlers.NewHandler(c.WorkspaceService)
 	h.Auth = authHandlers.NewAuthHandler(c.JWTService, c.OAuth2Service, c.SessionService, c.AuditService, c.DB, c.IdentityStore, c.StateStore)
 	h.Audit = auditHandlers.NewAuditHandler(c.AuditService)
+	h.Audit.SetChainVerifier(c.AuditChain)
 	h.Tenant = tenantHandlers.NewTenantHandler(c.TenantService, c.UserService, c.RoleService, c.ConfigService, c.Hasher)
 	h.KB = knowledgeHandlers.NewKBHandler(c.KBService)
 	h.Document = knowledgeHandlers.NewDocumentHandler(c.DocService, c.KBService, c.RAGService)
@@ -428,6 +452,14 @@ func (c *AppContainer) InitHandlers() *Handlers {
 	// 工具市场 Handler
 	h.Marketplace = marketplaceHandlers.NewHandler(c.MarketplaceService)
 
+	// MCP 服务管理 Handler
+	if c.MCPClientService != nil {
+		h.MCP = mcp.NewHandler(c.MCPClientService)
+	}
+	if c.MCPServer != nil {
+		h.MCPServer = mcp.NewServerHandler(c.MCPServer)
+	}
+
 	// 消息 Handler
 	if c.MessageService != nil {
 		h.Message = notificationHandlers.NewMessageHandler(c.MessageService)
@@ -438,6 +470,11 @@ func (c *AppContainer) InitHandlers() *Handlers {
 		h.WorkspaceTemplate = workspaceHandlers.NewTemplateHandler(c.WorkspaceTemplateService)
 	}
 
+	// 作品手稿导出 Handler
+	if c.ManuscriptExportService != nil {
+		h.ManuscriptExport = workspaceHandlers.NewManuscriptExportHandler(c.ManuscriptExportService)
+	}
+
 	// 自动化 Handler（需要 Redis）
 	if c.RedisClient != nil && c.AutomationEngine != nil {
 		h.Automation = workflows.NewAutomationHandler(c.DB, c.RedisClient, c.AutomationEngine, c.ApprovalManager)
@@ -606,6 +643,17 @@ func (c *AppContainer) initTenant(db *gorm.DB) error {
 	c.SessionService = modelSvc.NewSessionService(db)
 	c.AuditService = modelSvc.NewAuditLogService(db)
 
+	// 审计日志哈希链：经 AuditService 写入的租户日志都挂接到所属租户的链上；
+	// 配置 AUDIT_SIGNING_KEY 时定期为链头生成签名检查点
+	c.AuditChain = auditpkg.NewHashChain(sqlDB)
+	c.AuditService.SetAppender(c.AuditChain)
+	if signer, err := auditpkg.NewEd25519SignerFromEnv(); err != nil {
+		logger.Warn("审计检查点签名密钥无效，未启用签名检查点", zap.Error(err))
+	} else if signer != nil {
+		c.AuditChain.SetSigner(signer)
+		c.AuditChain.StartCheckpointer(context.Background(), time.Hour)
+	}
+
 	idGen := &UUIDGenerator{}
 	c.Hasher = &BcryptHasher{}
 	auditAdapter := &TenantAuditAdapter{svc: c.AuditService}
@@ -726,6 +774,13 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	dbLogger := ai.NewDBLogger(db)
 	c.ClientFactory = ai.NewClientFactory(db, dbLogger, diskCache)
 
+	reranker, err := initReranker(cfg, c.ClientFactory, c.ModelService)
+	if err != nil {
+		logger.Error("初始化重排序器失败", zap.Error(err))
+	} else if reranker != nil {
+		c.RAGService.WithReranker(reranker)
+	}
+
 
 	c.AgentRegistry = runtime.NewRegistry(db, c.ClientFactory)
 
@@ -736,6 +791,14 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	memoryService := runtime.NewRAGMemoryService(c.VectorStore, embeddingProvider)
 	c.AgentRegistry.SetMemoryService(memoryService)
 
+	// Agent A/B 实验：执行时按变体分配，暂存文件审核结果回传为采纳率
+	c.ABTestService = agentSvc.NewABTestService(db)
+	if err := c.ABTestService.AutoMigrate(); err != nil {
+		logger.Warn("Agent 实验表迁移失败", zap.Error(err))
+	}
+	c.AgentRegistry.SetExperimentService(c.ABTestService)
+	c.WorkspaceService.SetStagingReviewObserver(experimentStagingObserver{experiments: c.ABTestService})
+
 	logService := runtime.NewPGLogService(db)
 
 	c.AsyncClient = runtime.NewAsyncClient(cfg.Redis)
@@ -755,6 +818,13 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 		logger.Error("Failed to register workspace tool", zap.Error(err))
 	}
 
+	// 代码解释器默认运行在进程沙箱中，租户可通过 extraConfig.codeSandbox 切换后端
+	codeInterpreter := builtin.NewCodeInterpreterTool(nil)
+	codeInterpreter.SetSandboxSelector(tenantSandboxSelector{configs: c.ConfigService})
+	if err := c.ToolRegistry.Register(codeInterpreter.GetDefinition().Name, codeInterpreter, codeInterpreter.GetDefinition()); err != nil {
+		logger.Error("Failed to register code interpreter tool", zap.Error(err))
+	}
+
 	mcpBasePath := strings.TrimSpace(os.Getenv("MCP_BASE_PATH"))
 	if mcpBasePath == "" {
 		mcpBasePath = "."
@@ -764,6 +834,21 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	}
 
 	c.ToolExecutor = tools.NewToolExecutor(c.ToolRegistry, db)
+	c.ToolExecutor.SetMetrics(tools.NewToolMetrics(nil))
+
+	// 外部 MCP 服务：连接后将远程工具导入 ToolRegistry
+	c.MCPClientService = mcp.NewService(db, c.ToolRegistry, mcp.Options{
+		AllowedCommands: cfg.MCP.AllowedCommands,
+		AllowedArgs:     cfg.MCP.AllowedArgs,
+		WorkDir:         cfg.MCP.WorkDir,
+		AllowedHosts:    cfg.MCP.AllowedHosts,
+		Redis:           c.RedisClient,
+	})
+	if err := c.MCPClientService.AutoMigrate(); err != nil {
+		logger.Warn("MCP 服务表迁移失败", zap.Error(err))
+	}
+	// 每个实例按数据库配置各自连接，其他实例的变更经 Redis 通知或定时同步生效
+	c.MCPClientService.StartSync(context.Background(), time.Minute)
 
 	toolHelper := runtime.NewToolHelper(c.ToolRegistry, c.ToolExecutor)
 	c.AgentRegistry.SetToolHelper(toolHelper)
@@ -777,7 +862,7 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	// 积分服务
 	c.CreditsService = credits.NewService(db)
 	// 自动迁移积分表
-	if err := db.AutoMigrate(&credits.CreditAccount{}, &credits.CreditTransaction{}, &credits.CreditPricing{}); err != nil {
+	if err := db.AutoMigrate(&credits.CreditAccount{}, &credits.CreditTransaction{}, &credits.CreditPricing{}, &credits.CreditHold{}); err != nil {
 		logger.Warn("积分服务表迁移失败", zap.Error(err))
 	}
 
@@ -787,6 +872,19 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	if err := c.BillingService.AutoMigrate(); err != nil {
 		logger.Warn("计费服务表迁移失败", zap.Error(err))
 	}
+	// Agent 模型路由：读取租户预算并记录路由决策
+	c.AgentRegistry.SetRoutingBilling(c.BillingService)
+
+	// 模型调用积分计量（预授权 → 按实际用量结算）
+	if cfg.Billing.Metering.Enabled {
+		meter := billing.NewMeter(c.BillingService, c.CreditsService, auditpkg.NewTokenAuditService(db), billing.MeterOptions{
+			DefaultOutputTokens: cfg.Billing.Metering.DefaultOutputTokens,
+			HoldTTL:             time.Duration(cfg.Billing.Metering.HoldTTLMinutes) * time.Minute,
+		})
+		c.ClientFactory.SetMeter(meter)
+		meter.StartHoldSweeper(context.Background(), 5*time.Minute)
+		logger.Info("模型调用积分计量已启用")
+	}
 
 	// 内容审核服务
 	c.ModerationService = moderation.NewService(db, c.AgentRegistry)
@@ -808,6 +906,9 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	if err := db.AutoMigrate(&subscription.SubscriptionPlan{}, &subscription.UserSubscription{}, &subscription.SubscriptionHistory{}); err != nil {
 		logger.Warn("订阅服务表迁移失败", zap.Error(err))
 	}
+	c.SubscriptionService.SetProrationBiller(subscriptionProrationBiller{billing: c.BillingService, credits: c.CreditsService}, 0)
+	c.BillingService.SetBillPaidObserver(subscriptionPaymentObserver{subscriptions: c.SubscriptionService})
+	c.SubscriptionService.StartScheduledChangeWorker(context.Background(), 10*time.Minute)
 
 	// 内容管理服务
 	c.ContentService = content.NewService(db)
@@ -853,6 +954,14 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	// 初始化内置模板
 	c.WorkspaceTemplateService.InitBuiltinTemplates(context.Background())
 
+	// 作品手稿导出服务：有 Redis 时经 asynq 队列执行，否则进程内执行
+	c.ManuscriptExportService = workspaceSvc.NewManuscriptExportService(c.WorkspaceService, "")
+	if c.RedisClient != nil {
+		c.ManuscriptExportService.SetQueue(queue.NewManuscriptExportQueue(c.Config.Redis))
+	}
+	c.autoMigrate(c.ManuscriptExportService, "手稿导出")
+	c.ManuscriptExportService.StartMaintenance(context.Background(), 10*time.Minute)
+
 	// 工具市场服务
 	c.MarketplaceService = marketplaceHandlers.NewService(db)
 	if err := c.MarketplaceService.AutoMigrate(); err != nil {
@@ -862,6 +971,18 @@ func (c *AppContainer) initAgentRuntime(db *gorm.DB, cfg *config.Config) error {
 	// API Key 服务
 	c.APIKeyService = auth.NewAPIKeyService(db)
 
+	// MCP 服务端：API Key 认证，按租户发布配置暴露能力
+	c.MCPServer = mcp.NewServer(db, mcp.ServerOptions{
+		APIKeys:   c.APIKeyService,
+		Agents:    registryAgentRunner{registry: c.AgentRegistry},
+		Knowledge: c.RAGService,
+		Workspace: c.WorkspaceService,
+		Redis:     c.RedisClient,
+	})
+	if err := c.MCPServer.AutoMigrate(); err != nil {
+		logger.Warn("MCP 发布配置表迁移失败", zap.Error(err))
+	}
+
 	// 指标统计服务
 	c.MetricsService = metrics.NewMetricsService(db)
 
@@ -887,8 +1008,26 @@ func (c *AppContainer) initWorkflow(db *gorm.DB, cfg *config.Config) error {
 		}
 	}
 
+	// 工作流定义版本：执行固定使用已发布或指定的版本
+	c.autoMigrate(c.WorkflowService, "工作流版本")
+
 	tokenAuditService := auditpkg.NewTokenAuditService(db)
 	c.WorkflowEngine = executor.NewEngine(db, c.AgentRegistry, c.QueueClient, tokenAuditService, executor.WithMaxConcurrency(workflowMaxConcurrency))
+	// 租约过期的执行从最近的步骤检查点恢复
+	c.WorkflowEngine.StartRecovery(context.Background(), time.Minute)
+
+	// Webhook 与条件触发器通过执行引擎提交执行
+	c.WebhookTriggerService = workflowSvc.NewWebhookTriggerService(db)
+	c.autoMigrate(c.WebhookTriggerService, "Webhook 触发器")
+	c.WebhookTriggerService.SetLauncher(c.WorkflowEngine)
+	c.ConditionTriggerService = workflowSvc.NewConditionTriggerService(db)
+	c.autoMigrate(c.ConditionTriggerService, "条件触发器")
+	c.ConditionTriggerService.SetLauncher(c.WorkflowEngine)
+
+	// 定时工作流：多实例部署时由持有调度租约的实例触发
+	c.CronScheduler = executor.NewCronScheduler(db, c.WorkflowEngine)
+	c.autoMigrate(c.CronScheduler, "定时调度")
+	c.CronScheduler.Start(context.Background(), 30*time.Second)
 
 	c.WorkflowInitializer = workflowTpl.NewSystemInitializer()
 	if err := c.WorkflowInitializer.Initialize("config"); err != nil {
@@ -899,19 +1038,31 @@ func (c *AppContainer) initWorkflow(db *gorm.DB, cfg *config.Config) error {
 }
 
 func (c *AppContainer) initNotification() {
+	// 有 Redis 时通过 Pub/Sub 在多个实例间分发 WebSocket 消息，否则为单进程模式
 	var offlineStore notification.OfflineStore = notification.NewMemoryOfflineStore(100)
+	var backplane notification.Backplane
 	if c.RedisClient != nil {
 		offlineStore = notification.NewRedisOfflineStore(c.RedisClient, 200, time.Hour)
+		backplane = notification.NewRedisBackplane(c.RedisClient, "")
 	}
 
-	c.WSHub = notification.NewWebSocketHub(notification.WithOfflineStore(offlineStore))
+	c.WSHub = notification.NewWebSocketHub(notification.WithOfflineStore(offlineStore), notification.WithBackplane(backplane))
 	c.MultiNotifier = notification.NewMultiNotifier(nil, nil, c.WSHub)
 	
 	// 初始化通知配置服务
 	c.NotificationConfigService = notification.NewNotificationConfigService(c.DB)
 
-	// 初始化Webhook服务
-	c.WebhookService = notification.NewWebhookService(3)
+	// 初始化Webhook服务：有 Redis 时经 asynq 持久化重试，否则进程内重试
+	webhookOpts := []notification.WebhookOption{notification.WithWebhookAllowedHosts(c.Config.Webhook.AllowedHosts)}
+	if c.RedisClient != nil {
+		webhookOpts = append(webhookOpts, notification.WithWebhookQueue(queue.NewWebhookQueue(c.Config.Redis)))
+	}
+	c.WebhookService = notification.NewWebhookService(c.DB, webhookOpts...)
+	c.autoMigrate(c.WebhookService, "Webhook")
+	c.WebhookService.StartRecovery(context.Background(), 5*time.Minute)
+	if c.WorkflowEngine != nil {
+		c.WorkflowEngine.SetExecutionObserver(workflowWebhookObserver{webhooks: c.WebhookService})
+	}
 
 	// 初始化邮件服务
 	emailConfig := &notification.EmailServiceConfig{
@@ -938,7 +1089,10 @@ func (c *AppContainer) initNotification() {
 		c.DB,
 		approval.WithNotifier(c.MultiNotifier),
 		approval.WithTargetResolver(targetResolver),
+		approval.WithRuleEngine(approval.NewApprovalRuleEngine(c.DB)),
 	)
+	c.autoMigrate(c.ApprovalManager, "审批")
+	c.ApprovalManager.StartExpiryChecker(context.Background(), time.Minute)
 
 	// 自动化引擎（需要 Redis）
 	if c.RedisClient != nil {
@@ -963,7 +1117,7 @@ func (c *AppContainer) initPermissions() {
 }
 
 func (c *AppContainer) initWorker(cfg *config.Config) {
-	c.WorkerServer = worker.NewServer(cfg.Redis, c.RAGService, c.WorkflowEngine, logger.Get())
+	c.WorkerServer = worker.NewServer(cfg.Redis, c.RAGService, c.WorkflowEngine, c.WebhookService, c.ManuscriptExportService, logger.Get())
 }
 
 // --- 依赖注入辅助类型 ---
@@ -0,0 +1,39 @@
+-- ============================================================
+-- 009_audit_integrity.sql - 审计日志防篡改（哈希链、签名检查点）
+-- ============================================================
+
+-- ============================================================
+-- 1. 审计日志哈希链字段
+-- ============================================================
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS chain_seq BIGINT;
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS entry_hash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_logs_chain ON audit_logs(tenant_id, chain_seq) WHERE chain_seq IS NOT NULL;
+
+-- ============================================================
+-- 2. 租户链头（写入时行锁，保证同一租户串行追加）
+-- ============================================================
+CREATE TABLE IF NOT EXISTS audit_chain_heads (
+    tenant_id UUID PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL DEFAULT 0,
+    entry_hash VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- ============================================================
+-- 3. 签名检查点
+-- ============================================================
+CREATE TABLE IF NOT EXISTS audit_chain_checkpoints (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id UUID NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    seq BIGINT NOT NULL,
+    entry_hash VARCHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, seq)
+);
+
+COMMENT ON TABLE audit_chain_heads IS '审计日志哈希链链头';
+COMMENT ON TABLE audit_chain_checkpoints IS '审计日志哈希链签名检查点';
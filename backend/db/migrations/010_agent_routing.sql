@@ -0,0 +1,29 @@
+-- ============================================================
+-- 010_agent_routing.sql - Agent 成本感知模型路由
+-- ============================================================
+
+-- ============================================================
+-- 1. Agent 路由策略
+-- ============================================================
+ALTER TABLE agent_configs ADD COLUMN IF NOT EXISTS routing_policy JSONB;
+
+-- ============================================================
+-- 2. 模型路由决策记录
+-- ============================================================
+CREATE TABLE IF NOT EXISTS model_routing_decisions (
+    id UUID PRIMARY KEY,
+    tenant_id UUID NOT NULL,
+    agent_id UUID,
+    model_id VARCHAR(100),
+    model_name VARCHAR(100),
+    default_model_id VARCHAR(100),
+    strategy VARCHAR(20),
+    reason VARCHAR(50),
+    prompt_tokens INTEGER DEFAULT 0,
+    estimated_cost DECIMAL(12,6) DEFAULT 0,
+    default_estimated_cost DECIMAL(12,6) DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_routing_tenant_time ON model_routing_decisions(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_model_routing_decisions_agent_id ON model_routing_decisions(agent_id);
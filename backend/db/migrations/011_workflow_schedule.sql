@@ -0,0 +1,23 @@
+-- ============================================================
+-- 011_workflow_schedule.sql - 工作流定时调度
+-- ============================================================
+
+-- ============================================================
+-- 1. 工作流调度配置
+-- ============================================================
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS schedule_timezone VARCHAR(64);
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS missed_run_policy VARCHAR(20);
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS last_scheduled_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_workflows_next_run_at ON workflows(next_run_at)
+    WHERE schedule IS NOT NULL AND schedule <> '' AND deleted_at IS NULL;
+
+-- ============================================================
+-- 2. 后台任务领导者租约
+-- ============================================================
+CREATE TABLE IF NOT EXISTS scheduler_leases (
+    name VARCHAR(100) PRIMARY KEY,
+    holder_id VARCHAR(200) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
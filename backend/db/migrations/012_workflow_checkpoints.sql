@@ -0,0 +1,22 @@
+-- ============================================================
+-- 012_workflow_checkpoints.sql - 工作流步骤检查点与执行租约
+-- ============================================================
+
+-- ============================================================
+-- 1. 执行租约：Worker 执行期间定期续约，过期后由恢复任务重新提交
+-- ============================================================
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS lease_owner VARCHAR(200) DEFAULT '';
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMPTZ;
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS recovery_count INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_lease_expires_at ON workflow_executions(lease_expires_at)
+    WHERE status = 'running';
+
+-- ============================================================
+-- 2. 步骤检查点：每个步骤结束后写入一行，同一执行的同一步骤只保留最新结果
+-- ============================================================
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS agent_type VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE workflow_tasks ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_tasks_execution_step ON workflow_tasks(execution_id, step_id);
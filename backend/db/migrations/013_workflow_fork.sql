@@ -0,0 +1,10 @@
+-- ============================================================
+-- 013_workflow_fork.sql - 从指定步骤分叉执行
+-- ============================================================
+
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS parent_execution_id UUID REFERENCES workflow_executions(id) ON DELETE SET NULL;
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS forked_from_step VARCHAR(100);
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS step_overrides JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_parent ON workflow_executions(parent_execution_id)
+    WHERE parent_execution_id IS NOT NULL;
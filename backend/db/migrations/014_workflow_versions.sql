@@ -0,0 +1,43 @@
+-- ============================================================
+-- 014_workflow_versions.sql - 工作流不可变定义版本与发布
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS workflow_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workflow_id UUID NOT NULL REFERENCES workflows(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL,
+    number INTEGER NOT NULL,
+    label VARCHAR(50),
+    definition JSONB NOT NULL,
+    variables JSONB,
+    change_log TEXT,
+    created_by VARCHAR(100),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_versions_number ON workflow_versions(workflow_id, number);
+CREATE INDEX IF NOT EXISTS idx_workflow_versions_tenant_id ON workflow_versions(tenant_id);
+
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS latest_version INTEGER DEFAULT 0;
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS published_version INTEGER DEFAULT 0;
+ALTER TABLE workflows ADD COLUMN IF NOT EXISTS published_at TIMESTAMPTZ;
+
+-- 0 表示版本化之前的执行，使用工作流当前定义
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS workflow_version INTEGER DEFAULT 0;
+
+-- 触发器固定的定义版本，0 表示跟随已发布版本
+ALTER TABLE IF EXISTS webhook_triggers ADD COLUMN IF NOT EXISTS workflow_version INTEGER DEFAULT 0;
+ALTER TABLE IF EXISTS condition_triggers ADD COLUMN IF NOT EXISTS workflow_version INTEGER DEFAULT 0;
+
+-- 现有工作流以当前定义生成版本 1，已启用的工作流发布该版本
+INSERT INTO workflow_versions (id, workflow_id, tenant_id, number, label, definition, variables, change_log, created_by, created_at)
+SELECT gen_random_uuid(), w.id, w.tenant_id, 1, w.version::TEXT, w.definition, w.variables, '初始版本', w.owner_user_id::TEXT, NOW()
+FROM workflows w
+WHERE w.latest_version = 0 AND w.deleted_at IS NULL
+ON CONFLICT (workflow_id, number) DO NOTHING;
+
+UPDATE workflows SET latest_version = 1 WHERE latest_version = 0 AND deleted_at IS NULL;
+
+UPDATE workflows
+SET published_version = 1, published_at = NOW()
+WHERE published_version = 0 AND status = 'active' AND deleted_at IS NULL;
@@ -0,0 +1,9 @@
+-- ============================================================
+-- 015_workflow_subworkflow.sql - 子工作流步骤
+-- ============================================================
+
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS caller_execution_id UUID REFERENCES workflow_executions(id) ON DELETE SET NULL;
+ALTER TABLE workflow_executions ADD COLUMN IF NOT EXISTS caller_step_id VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_caller ON workflow_executions(caller_execution_id, caller_step_id)
+    WHERE caller_execution_id IS NOT NULL;
@@ -3,33 +3,85 @@ package agent
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/hex"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A/B 测试相关错误
+var (
+	ErrExperimentNotFound   = errors.New("实验不存在")
+	ErrExperimentNotRunning = errors.New("实验未在运行")
+	ErrVariantNotFound      = errors.New("实验变体不存在")
+	ErrTrialNotFound        = errors.New("试验记录不存在")
+)
+
+// 实验指标
+const (
+	MetricSuccess      = "success"       // 执行成功率
+	MetricAcceptRate   = "accept_rate"   // 暂存文件采纳率
+	MetricQualityScore = "quality_score" // 质量评分均值
+	MetricLatency      = "latency_ms"    // 平均延迟（越低越好）
+	MetricCost         = "cost"          // 平均成本（越低越好）
+)
+
+const (
+	defaultMinSamplesPerVariant = 100
+	defaultConfidence           = 0.95
+	activeExperimentCacheTTL    = 30 * time.Second // 运行中实验缓存，其他实例的状态变更最多延迟该时长生效
+	stopRuleCheckInterval       = 20               // 开启自动停止时，每新增该数量的试验检查一次停止规则
 )
 
 // ABTestService Agent A/B 测试服务
+// 实验、变体分配与试验结果均持久化到数据库，多实例共享同一份分配与统计
 type ABTestService struct {
-	experiments map[string]*Experiment
-	results     map[string]*ExperimentResults
-	mu          sync.RWMutex
+	db     *gorm.DB
+	active sync.Map // tenantID:agentID -> *activeExperimentEntry
+}
+
+type activeExperimentEntry struct {
+	experiment *Experiment // 为 nil 表示没有运行中的实验
+	expiresAt  time.Time
 }
 
 // Experiment A/B 测试实验
 type Experiment struct {
-	ID          string              `json:"id"`
-	Name        string              `json:"name"`
-	Description string              `json:"description"`
-	Status      ExperimentStatus    `json:"status"`
-	Variants    []Variant           `json:"variants"`
-	TrafficSplit map[string]float64 `json:"traffic_split"` // variant_id -> 流量百分比
-	Metrics     []string            `json:"metrics"`       // 需要跟踪的指标
-	StartTime   *time.Time          `json:"start_time,omitempty"`
-	EndTime     *time.Time          `json:"end_time,omitempty"`
-	CreatedAt   time.Time           `json:"created_at"`
-	CreatedBy   string              `json:"created_by"`
+	ID          string           `json:"id" gorm:"primaryKey;type:uuid"`
+	TenantID    string           `json:"tenant_id" gorm:"type:uuid;not null;index"`
+	AgentID     string           `json:"agent_id" gorm:"type:uuid;not null;index"` // 参与实验的 Agent 配置
+	Name        string           `json:"name" gorm:"size:255;not null"`
+	Description string           `json:"description" gorm:"type:text"`
+	Status      ExperimentStatus `json:"status" gorm:"size:20;not null;index"`
+
+	Variants      []Variant          `json:"variants" gorm:"type:jsonb;serializer:json"`
+	TrafficSplit  map[string]float64 `json:"traffic_split" gorm:"type:jsonb;serializer:json"` // variant_id -> 流量比例，未分配的流量归对照组
+	Metrics       []string           `json:"metrics" gorm:"type:jsonb;serializer:json"`       // 需要跟踪的指标
+	PrimaryMetric string             `json:"primary_metric" gorm:"size:50;not null"`          // 显著性检验使用的指标
+	StopRule      StopRule           `json:"stop_rule" gorm:"type:jsonb;serializer:json"`
+
+	WinnerVariantID string `json:"winner_variant_id,omitempty" gorm:"size:64"`
+	StopReason      string `json:"stop_reason,omitempty" gorm:"type:text"`
+
+	StartTime *time.Time `json:"start_time,omitempty"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"not null;autoUpdateTime"`
+	CreatedBy string     `json:"created_by" gorm:"type:uuid"`
+}
+
+// TableName 指定表名
+func (Experiment) TableName() string {
+	return "agent_experiments"
 }
 
 // ExperimentStatus 实验状态
@@ -47,388 +99,858 @@ type Variant struct {
 	ID          string         `json:"id"`
 	Name        string         `json:"name"`
 	Description string         `json:"description"`
-	AgentConfig map[string]any `json:"agent_config"` // Agent 配置覆盖
+	AgentConfig map[string]any `json:"agent_config"` // Agent 配置覆盖（model_id、temperature、max_tokens、system_prompt、prompt_template_id，其余并入 extra_config）
 	IsControl   bool           `json:"is_control"`   // 是否为对照组
 }
 
+// ApplyTo 返回应用了变体覆盖项的 Agent 配置副本
+func (v *Variant) ApplyTo(config *AgentConfig) *AgentConfig {
+	cfg := *config
+	if len(v.AgentConfig) == 0 {
+		return &cfg
+	}
+	extra := make(map[string]any, len(config.ExtraConfig))
+	for k, val := range config.ExtraConfig {
+		extra[k] = val
+	}
+	for key, value := range v.AgentConfig {
+		switch key {
+		case "model_id":
+			if s, ok := value.(string); ok && s != "" {
+				cfg.PrimaryModelID = s
+				cfg.ModelID = s
+				cfg.ActiveModelID = s
+			}
+		case "temperature":
+			if f, ok := value.(float64); ok {
+				cfg.Temperature = f
+			}
+		case "max_tokens":
+			if f, ok := value.(float64); ok {
+				cfg.MaxTokens = int(f)
+			}
+		case "system_prompt":
+			if s, ok := value.(string); ok {
+				cfg.SystemPrompt = s
+			}
+		case "prompt_template_id":
+			if s, ok := value.(string); ok {
+				cfg.PromptTemplateID = s
+			}
+		default:
+			extra[key] = value
+		}
+	}
+	cfg.ExtraConfig = extra
+	return &cfg
+}
+
+// StopRule 实验停止规则
+type StopRule struct {
+	MinSamplesPerVariant int     `json:"min_samples_per_variant"` // 每个变体的最小样本量，默认 100
+	Confidence           float64 `json:"confidence"`              // 置信水平，默认 0.95
+	MaxDurationHours     int     `json:"max_duration_hours"`      // 最长运行时长，0 表示不限
+	AutoStop             bool    `json:"auto_stop"`               // 满足停止条件时自动结束实验
+}
+
+func (r StopRule) minSamples() int64 {
+	if r.MinSamplesPerVariant > 0 {
+		return int64(r.MinSamplesPerVariant)
+	}
+	return defaultMinSamplesPerVariant
+}
+
+func (r StopRule) confidence() float64 {
+	if r.Confidence > 0 && r.Confidence < 1 {
+		return r.Confidence
+	}
+	return defaultConfidence
+}
+
+// ExperimentAssignment 主体（用户/会话）与变体的粘性分配
+type ExperimentAssignment struct {
+	ID           string    `json:"id" gorm:"primaryKey;type:uuid"`
+	ExperimentID string    `json:"experiment_id" gorm:"type:uuid;not null;uniqueIndex:idx_experiment_assignment_subject"`
+	SubjectID    string    `json:"subject_id" gorm:"size:255;not null;uniqueIndex:idx_experiment_assignment_subject"`
+	VariantID    string    `json:"variant_id" gorm:"size:64;not null"`
+	CreatedAt    time.Time `json:"created_at" gorm:"not null;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (ExperimentAssignment) TableName() string {
+	return "agent_experiment_assignments"
+}
+
+// TrialResult 单次试验结果
+type TrialResult struct {
+	ID           string             `json:"id" gorm:"primaryKey;type:uuid"`
+	ExperimentID string             `json:"experiment_id" gorm:"type:uuid;not null;index"`
+	VariantID    string             `json:"variant_id" gorm:"size:64;not null;index"`
+	TenantID     string             `json:"tenant_id" gorm:"type:uuid;index"`
+	SubjectID    string             `json:"subject_id" gorm:"size:255"`
+	Source       string             `json:"source" gorm:"size:20"`        // agent, workflow
+	ReferenceID  string             `json:"reference_id" gorm:"size:255"` // 工作流执行 ID / 会话 ID 等
+	Success      bool               `json:"success" gorm:"not null"`
+	LatencyMs    int64              `json:"latency_ms"`
+	Tokens       int64              `json:"tokens"`
+	Cost         float64            `json:"cost"`
+	QualityScore *float64           `json:"quality_score,omitempty"`
+	Accepted     *bool              `json:"accepted,omitempty"` // 产出的暂存文件被采纳/拒绝
+	Metrics      map[string]float64 `json:"metrics,omitempty" gorm:"type:jsonb;serializer:json"`
+	FeedbackAt   *time.Time         `json:"feedback_at,omitempty"`
+	CreatedAt    time.Time          `json:"created_at" gorm:"not null;autoCreateTime;index"`
+}
+
+// TableName 指定表名
+func (TrialResult) TableName() string {
+	return "agent_experiment_trials"
+}
+
+// TrialFeedback 试验的事后反馈
+type TrialFeedback struct {
+	QualityScore *float64 `json:"quality_score"`
+	Accepted     *bool    `json:"accepted"`
+}
+
 // ExperimentResults 实验结果
 type ExperimentResults struct {
-	ExperimentID string                    `json:"experiment_id"`
-	VariantStats map[string]*VariantStats  `json:"variant_stats"`
-	StartTime    time.Time                 `json:"start_time"`
-	LastUpdated  time.Time                 `json:"last_updated"`
+	ExperimentID string                   `json:"experiment_id"`
+	VariantStats map[string]*VariantStats `json:"variant_stats"`
+	StartTime    time.Time                `json:"start_time"`
+	LastUpdated  time.Time                `json:"last_updated"`
 }
 
 // VariantStats 变体统计
 type VariantStats struct {
-	VariantID    string             `json:"variant_id"`
-	SampleSize   int64              `json:"sample_size"`
-	Conversions  int64              `json:"conversions"`
-	ConvRate     float64            `json:"conversion_rate"`
-	Metrics      map[string]float64 `json:"metrics"`
-	AvgLatencyMs float64            `json:"avg_latency_ms"`
-	SuccessRate  float64            `json:"success_rate"`
-	AvgTokens    float64            `json:"avg_tokens"`
+	VariantID       string             `json:"variant_id"`
+	SampleSize      int64              `json:"sample_size"`
+	Conversions     int64              `json:"conversions"`
+	ConvRate        float64            `json:"conversion_rate"`
+	Metrics         map[string]float64 `json:"metrics"`
+	AvgLatencyMs    float64            `json:"avg_latency_ms"`
+	SuccessRate     float64            `json:"success_rate"`
+	AvgTokens       float64            `json:"avg_tokens"`
+	AvgCost         float64            `json:"avg_cost"`
+	QualitySamples  int64              `json:"quality_samples"`
+	AvgQualityScore float64            `json:"avg_quality_score"`
+	Accepted        int64              `json:"accepted"`
+	Rejected        int64              `json:"rejected"`
+	AcceptRate      float64            `json:"accept_rate"`
 }
 
 // NewABTestService 创建 A/B 测试服务
-func NewABTestService() *ABTestService {
-	return &ABTestService{
-		experiments: make(map[string]*Experiment),
-		results:     make(map[string]*ExperimentResults),
-	}
+func NewABTestService(db *gorm.DB) *ABTestService {
+	return &ABTestService{db: db}
+}
+
+// AutoMigrate 自动迁移实验相关表
+func (s *ABTestService) AutoMigrate() error {
+	return s.db.AutoMigrate(&Experiment{}, &ExperimentAssignment{}, &TrialResult{})
 }
 
 // CreateExperiment 创建实验
-func (s *ABTestService) CreateExperiment(exp *Experiment) error {
-	if exp.ID == "" {
-		exp.ID = fmt.Sprintf("exp_%d", time.Now().UnixNano())
+func (s *ABTestService) CreateExperiment(ctx context.Context, exp *Experiment) error {
+	if exp.TenantID == "" || exp.AgentID == "" {
+		return fmt.Errorf("实验必须指定租户和 Agent")
+	}
+	if len(exp.Variants) < 2 {
+		return fmt.Errorf("实验至少需要两个变体")
 	}
 
-	// 验证流量分配
-	var totalTraffic float64
-	for _, pct := range exp.TrafficSplit {
-		totalTraffic += pct
+	ids := make(map[string]bool, len(exp.Variants))
+	controls := 0
+	for i := range exp.Variants {
+		v := &exp.Variants[i]
+		if v.ID == "" {
+			v.ID = fmt.Sprintf("v%d", i+1)
+		}
+		if ids[v.ID] {
+			return fmt.Errorf("变体 ID 重复: %s", v.ID)
+		}
+		ids[v.ID] = true
+		if v.IsControl {
+			controls++
+		}
 	}
-	if totalTraffic > 1.0 {
-		return fmt.Errorf("total traffic split exceeds 100%%")
+	switch controls {
+	case 0:
+		exp.Variants[0].IsControl = true
+	case 1:
+	default:
+		return fmt.Errorf("只能有一个对照组")
 	}
 
-	exp.Status = StatusDraft
-	exp.CreatedAt = time.Now()
-
-	s.mu.Lock()
-	s.experiments[exp.ID] = exp
-	s.results[exp.ID] = &ExperimentResults{
-		ExperimentID: exp.ID,
-		VariantStats: make(map[string]*VariantStats),
-		StartTime:    time.Now(),
-		LastUpdated:  time.Now(),
+	// 验证流量分配，未指定时均分
+	if len(exp.TrafficSplit) == 0 {
+		exp.TrafficSplit = make(map[string]float64, len(exp.Variants))
+		for _, v := range exp.Variants {
+			exp.TrafficSplit[v.ID] = 1 / float64(len(exp.Variants))
+		}
 	}
-	for _, v := range exp.Variants {
-		s.results[exp.ID].VariantStats[v.ID] = &VariantStats{
-			VariantID: v.ID,
-			Metrics:   make(map[string]float64),
+	var totalTraffic float64
+	for variantID, pct := range exp.TrafficSplit {
+		if !ids[variantID] {
+			return fmt.Errorf("流量分配引用了不存在的变体: %s", variantID)
 		}
+		if pct < 0 {
+			return fmt.Errorf("流量比例不能为负数")
+		}
+		totalTraffic += pct
+	}
+	if totalTraffic > 1.0+1e-9 {
+		return fmt.Errorf("流量分配总和超过 100%%")
 	}
-	s.mu.Unlock()
 
+	switch exp.PrimaryMetric {
+	case "":
+		exp.PrimaryMetric = MetricSuccess
+	case MetricSuccess, MetricAcceptRate, MetricQualityScore, MetricLatency, MetricCost:
+	default:
+		return fmt.Errorf("不支持的主指标: %s", exp.PrimaryMetric)
+	}
+
+	exp.ID = uuid.New().String()
+	exp.Status = StatusDraft
+	exp.StartTime = nil
+	exp.EndTime = nil
+	exp.WinnerVariantID = ""
+	exp.StopReason = ""
+	if err := s.db.WithContext(ctx).Create(exp).Error; err != nil {
+		return fmt.Errorf("创建实验失败: %w", err)
+	}
 	return nil
 }
 
-// StartExperiment 启动实验
-func (s *ABTestService) StartExperiment(expID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	exp, ok := s.experiments[expID]
-	if !ok {
-		return fmt.Errorf("experiment not found: %s", expID)
+// StartExperiment 启动实验（同一 Agent 同时只能运行一个实验）
+func (s *ABTestService) StartExperiment(ctx context.Context, tenantID, expID string) error {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return err
 	}
-
-	if exp.Status == StatusRunning {
-		return fmt.Errorf("experiment already running")
+	switch exp.Status {
+	case StatusRunning:
+		return fmt.Errorf("实验已在运行")
+	case StatusCompleted:
+		return fmt.Errorf("实验已结束，无法重新启动")
 	}
 
-	now := time.Now()
-	exp.Status = StatusRunning
-	exp.StartTime = &now
-	s.results[expID].StartTime = now
+	var running int64
+	if err := s.db.WithContext(ctx).Model(&Experiment{}).
+		Where("tenant_id = ? AND agent_id = ? AND status = ? AND id <> ?", tenantID, exp.AgentID, StatusRunning, expID).
+		Count(&running).Error; err != nil {
+		return err
+	}
+	if running > 0 {
+		return fmt.Errorf("该 Agent 已有运行中的实验")
+	}
 
-	return nil
+	updates := map[string]any{"status": StatusRunning}
+	if exp.StartTime == nil {
+		updates["start_time"] = time.Now().UTC()
+	}
+	return s.updateExperiment(ctx, exp, updates)
 }
 
-// PauseExperiment 暂停实验
-func (s *ABTestService) PauseExperiment(expID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// PauseExperiment 暂停实验（暂停期间 Agent 使用原配置，已有分配保留）
+func (s *ABTestService) PauseExperiment(ctx context.Context, tenantID, expID string) error {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return err
+	}
+	if exp.Status != StatusRunning {
+		return ErrExperimentNotRunning
+	}
+	return s.updateExperiment(ctx, exp, map[string]any{"status": StatusPaused})
+}
 
-	exp, ok := s.experiments[expID]
-	if !ok {
-		return fmt.Errorf("experiment not found: %s", expID)
+// CompleteExperiment 结束实验，winnerVariantID 可为空
+func (s *ABTestService) CompleteExperiment(ctx context.Context, tenantID, expID, winnerVariantID, reason string) error {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return err
 	}
+	if exp.Status == StatusCompleted {
+		return nil
+	}
+	if winnerVariantID != "" && exp.variant(winnerVariantID) == nil {
+		return ErrVariantNotFound
+	}
+	return s.updateExperiment(ctx, exp, map[string]any{
+		"status":            StatusCompleted,
+		"end_time":          time.Now().UTC(),
+		"winner_variant_id": winnerVariantID,
+		"stop_reason":       reason,
+	})
+}
 
-	exp.Status = StatusPaused
+func (s *ABTestService) updateExperiment(ctx context.Context, exp *Experiment, updates map[string]any) error {
+	if err := s.db.WithContext(ctx).Model(&Experiment{}).Where("id = ?", exp.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新实验失败: %w", err)
+	}
+	s.active.Delete(exp.TenantID + ":" + exp.AgentID)
 	return nil
 }
 
-// CompleteExperiment 结束实验
-func (s *ABTestService) CompleteExperiment(expID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	exp, ok := s.experiments[expID]
-	if !ok {
-		return fmt.Errorf("experiment not found: %s", expID)
+// GetExperiment 获取实验详情
+func (s *ABTestService) GetExperiment(ctx context.Context, tenantID, expID string) (*Experiment, error) {
+	var exp Experiment
+	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", expID, tenantID).First(&exp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExperimentNotFound
+		}
+		return nil, err
 	}
+	return &exp, nil
+}
 
-	now := time.Now()
-	exp.Status = StatusCompleted
-	exp.EndTime = &now
+// ListExperiments 列出租户的实验
+func (s *ABTestService) ListExperiments(ctx context.Context, tenantID, agentID string, status ExperimentStatus) ([]*Experiment, error) {
+	query := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID)
+	if agentID != "" {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	result := make([]*Experiment, 0)
+	if err := query.Order("created_at DESC").Find(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
 
+// DeleteExperiment 删除实验及其分配和试验记录
+func (s *ABTestService) DeleteExperiment(ctx context.Context, tenantID, expID string) error {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("experiment_id = ?", expID).Delete(&TrialResult{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("experiment_id = ?", expID).Delete(&ExperimentAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Experiment{}, "id = ?", expID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("删除实验失败: %w", err)
+	}
+	s.active.Delete(exp.TenantID + ":" + exp.AgentID)
 	return nil
 }
 
-// GetVariant 根据用户 ID 获取分配的变体
-func (s *ABTestService) GetVariant(expID, userID string) (*Variant, error) {
-	s.mu.RLock()
-	exp, ok := s.experiments[expID]
-	s.mu.RUnlock()
+// ActiveExperimentForAgent 返回 Agent 正在运行的实验，没有时返回 nil
+func (s *ABTestService) ActiveExperimentForAgent(ctx context.Context, tenantID, agentID string) (*Experiment, error) {
+	key := tenantID + ":" + agentID
+	if cached, ok := s.active.Load(key); ok {
+		entry := cached.(*activeExperimentEntry)
+		if time.Now().Before(entry.expiresAt) {
+			return entry.experiment, nil
+		}
+	}
 
-	if !ok {
-		return nil, fmt.Errorf("experiment not found: %s", expID)
+	var exps []Experiment
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND agent_id = ? AND status = ?", tenantID, agentID, StatusRunning).
+		Limit(1).Find(&exps).Error; err != nil {
+		return nil, err
+	}
+	var exp *Experiment
+	if len(exps) > 0 {
+		exp = &exps[0]
+	}
+	s.active.Store(key, &activeExperimentEntry{experiment: exp, expiresAt: time.Now().Add(activeExperimentCacheTTL)})
+	return exp, nil
+}
+
+// GetVariant 获取主体分配的变体
+// 首次分配按一致性哈希计算并写入数据库，之后始终返回同一变体（流量比例调整不影响已分配主体）
+func (s *ABTestService) GetVariant(ctx context.Context, tenantID, expID, subjectID string) (*Variant, error) {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return nil, err
 	}
+	return s.assign(ctx, exp, subjectID)
+}
 
+func (s *ABTestService) assign(ctx context.Context, exp *Experiment, subjectID string) (*Variant, error) {
 	if exp.Status != StatusRunning {
-		return nil, fmt.Errorf("experiment not running")
+		return nil, ErrExperimentNotRunning
+	}
+	if subjectID == "" {
+		return nil, fmt.Errorf("缺少分配主体")
+	}
+
+	var assignment ExperimentAssignment
+	err := s.db.WithContext(ctx).
+		Where("experiment_id = ? AND subject_id = ?", exp.ID, subjectID).
+		First(&assignment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 并发分配时以先写入者为准
+		candidate := ExperimentAssignment{
+			ID:           uuid.New().String(),
+			ExperimentID: exp.ID,
+			SubjectID:    subjectID,
+			VariantID:    exp.pickVariant(hashToBucket(exp.ID, subjectID)).ID,
+		}
+		if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&candidate).Error; err != nil {
+			return nil, fmt.Errorf("保存变体分配失败: %w", err)
+		}
+		err = s.db.WithContext(ctx).
+			Where("experiment_id = ? AND subject_id = ?", exp.ID, subjectID).
+			First(&assignment).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询变体分配失败: %w", err)
+	}
+
+	if v := exp.variant(assignment.VariantID); v != nil {
+		return v, nil
 	}
+	return exp.control(), nil
+}
 
-	// 使用一致性哈希分配变体
-	bucket := s.hashToBucket(expID, userID)
-	
+// pickVariant 按流量比例选择变体，未分配的流量归对照组
+func (e *Experiment) pickVariant(bucket float64) *Variant {
 	var cumulative float64
-	for _, variant := range exp.Variants {
-		cumulative += exp.TrafficSplit[variant.ID]
+	for i := range e.Variants {
+		cumulative += e.TrafficSplit[e.Variants[i].ID]
 		if bucket < cumulative {
-			return &variant, nil
+			return &e.Variants[i]
 		}
 	}
+	return e.control()
+}
 
-	// 返回对照组
-	for _, variant := range exp.Variants {
-		if variant.IsControl {
-			return &variant, nil
+func (e *Experiment) variant(id string) *Variant {
+	for i := range e.Variants {
+		if e.Variants[i].ID == id {
+			return &e.Variants[i]
 		}
 	}
-
-	return &exp.Variants[0], nil
+	return nil
 }
 
-// hashToBucket 一致性哈希到 [0, 1) 区间
-func (s *ABTestService) hashToBucket(expID, userID string) float64 {
-	h := sha256.New()
-	h.Write([]byte(expID + ":" + userID))
-	hash := hex.EncodeToString(h.Sum(nil))
-	
-	// 取前 8 字节转换为数值
-	var val uint64
-	for i := 0; i < 8 && i < len(hash); i++ {
-		val = val*16 + uint64(hash[i]%16)
+func (e *Experiment) control() *Variant {
+	for i := range e.Variants {
+		if e.Variants[i].IsControl {
+			return &e.Variants[i]
+		}
 	}
-	
-	return float64(val) / float64(1<<32)
+	return &e.Variants[0]
 }
 
-// RecordResult 记录实验结果
-func (s *ABTestService) RecordResult(expID, variantID string, result *TrialResult) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// hashToBucket 一致性哈希到 [0, 1) 区间
+func hashToBucket(expID, subjectID string) float64 {
+	sum := sha256.Sum256([]byte(expID + ":" + subjectID))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / float64(1<<53)
+}
 
-	results, ok := s.results[expID]
-	if !ok {
-		return fmt.Errorf("experiment not found: %s", expID)
+// RecordResult 记录实验结果，实验必须属于 tenantID
+func (s *ABTestService) RecordResult(ctx context.Context, tenantID, expID, variantID string, result *TrialResult) error {
+	var exp Experiment
+	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", expID, tenantID).First(&exp).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrExperimentNotFound
+		}
+		return err
 	}
-
-	stats, ok := results.VariantStats[variantID]
-	if !ok {
-		return fmt.Errorf("variant not found: %s", variantID)
+	if exp.variant(variantID) == nil {
+		return ErrVariantNotFound
 	}
 
-	// 更新统计
-	stats.SampleSize++
-	if result.Success {
-		stats.Conversions++
+	if result.ID == "" {
+		result.ID = uuid.New().String()
 	}
-	stats.ConvRate = float64(stats.Conversions) / float64(stats.SampleSize)
-
-	// 更新延迟（增量平均）
-	n := float64(stats.SampleSize)
-	stats.AvgLatencyMs = stats.AvgLatencyMs*(n-1)/n + float64(result.LatencyMs)/n
-	stats.AvgTokens = stats.AvgTokens*(n-1)/n + float64(result.Tokens)/n
-
-	successCount := stats.ConvRate * float64(stats.SampleSize)
-	stats.SuccessRate = successCount / float64(stats.SampleSize)
-
-	// 更新自定义指标
-	for k, v := range result.Metrics {
-		old := stats.Metrics[k]
-		stats.Metrics[k] = old*(n-1)/n + v/n
+	result.ExperimentID = expID
+	result.VariantID = variantID
+	result.TenantID = exp.TenantID
+	if err := s.db.WithContext(ctx).Create(result).Error; err != nil {
+		return fmt.Errorf("记录试验结果失败: %w", err)
 	}
 
-	results.LastUpdated = time.Now()
+	if exp.Status == StatusRunning && exp.StopRule.AutoStop {
+		var total int64
+		if err := s.db.WithContext(ctx).Model(&TrialResult{}).Where("experiment_id = ?", expID).Count(&total).Error; err == nil &&
+			total%stopRuleCheckInterval == 0 {
+			_, _ = s.ApplyStopRule(ctx, exp.TenantID, expID)
+		}
+	}
+	return nil
+}
 
+// RecordFeedback 记录试验的质量评分或采纳结果
+func (s *ABTestService) RecordFeedback(ctx context.Context, tenantID, trialID string, feedback *TrialFeedback) error {
+	updates := map[string]any{"feedback_at": time.Now().UTC()}
+	if feedback.QualityScore != nil {
+		updates["quality_score"] = *feedback.QualityScore
+	}
+	if feedback.Accepted != nil {
+		updates["accepted"] = *feedback.Accepted
+	}
+	if len(updates) == 1 {
+		return nil
+	}
+	res := s.db.WithContext(ctx).Model(&TrialResult{}).
+		Where("id = ? AND tenant_id = ?", trialID, tenantID).
+		Updates(updates)
+	if res.Error != nil {
+		return fmt.Errorf("记录试验反馈失败: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return ErrTrialNotFound
+	}
 	return nil
 }
 
-// TrialResult 单次试验结果
-type TrialResult struct {
-	Success   bool               `json:"success"`
-	LatencyMs int64              `json:"latency_ms"`
-	Tokens    int64              `json:"tokens"`
-	Metrics   map[string]float64 `json:"metrics"`
+// RecordStagingReview 根据暂存文件的审核结果记录采纳情况
+// 暂存文件元数据中携带 experiment_trial_id（来自 Agent 执行结果元数据）时生效
+func (s *ABTestService) RecordStagingReview(ctx context.Context, tenantID, metadata string, accepted bool) error {
+	if metadata == "" {
+		return nil
+	}
+	var meta struct {
+		TrialID string `json:"experiment_trial_id"`
+	}
+	if err := json.Unmarshal([]byte(metadata), &meta); err != nil || meta.TrialID == "" {
+		return nil
+	}
+	return s.RecordFeedback(ctx, tenantID, meta.TrialID, &TrialFeedback{Accepted: &accepted})
 }
 
 // GetResults 获取实验结果
-func (s *ABTestService) GetResults(expID string) (*ExperimentResults, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	results, ok := s.results[expID]
-	if !ok {
-		return nil, fmt.Errorf("experiment not found: %s", expID)
+func (s *ABTestService) GetResults(ctx context.Context, tenantID, expID string) (*ExperimentResults, error) {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return nil, err
 	}
 
-	return results, nil
-}
-
-// GetExperiment 获取实验详情
-func (s *ABTestService) GetExperiment(expID string) (*Experiment, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	results := &ExperimentResults{
+		ExperimentID: exp.ID,
+		VariantStats: make(map[string]*VariantStats, len(exp.Variants)),
+		StartTime:    exp.CreatedAt,
+		LastUpdated:  exp.UpdatedAt,
+	}
+	if exp.StartTime != nil {
+		results.StartTime = *exp.StartTime
+	}
+	for _, v := range exp.Variants {
+		results.VariantStats[v.ID] = &VariantStats{VariantID: v.ID, Metrics: make(map[string]float64)}
+	}
+
+	var rows []struct {
+		VariantID       string
+		SampleSize      int64
+		Conversions     int64
+		AvgLatencyMs    float64
+		AvgTokens       float64
+		AvgCost         float64
+		QualitySamples  int64
+		AvgQualityScore float64
+		Accepted        int64
+		Rejected        int64
+	}
+	if err := s.db.WithContext(ctx).Model(&TrialResult{}).
+		Select(`variant_id,
+			COUNT(*) AS sample_size,
+			COALESCE(SUM(CASE WHEN success THEN 1 ELSE 0 END), 0) AS conversions,
+			COALESCE(AVG(latency_ms), 0) AS avg_latency_ms,
+			COALESCE(AVG(tokens), 0) AS avg_tokens,
+			COALESCE(AVG(cost), 0) AS avg_cost,
+			COUNT(quality_score) AS quality_samples,
+			COALESCE(AVG(quality_score), 0) AS avg_quality_score,
+			COALESCE(SUM(CASE WHEN accepted THEN 1 ELSE 0 END), 0) AS accepted,
+			COALESCE(SUM(CASE WHEN NOT accepted THEN 1 ELSE 0 END), 0) AS rejected`).
+		Where("experiment_id = ?", expID).
+		Group("variant_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计实验结果失败: %w", err)
+	}
+	for _, row := range rows {
+		stats, ok := results.VariantStats[row.VariantID]
+		if !ok {
+			continue
+		}
+		stats.SampleSize = row.SampleSize
+		stats.Conversions = row.Conversions
+		stats.AvgLatencyMs = row.AvgLatencyMs
+		stats.AvgTokens = row.AvgTokens
+		stats.AvgCost = row.AvgCost
+		stats.QualitySamples = row.QualitySamples
+		stats.AvgQualityScore = row.AvgQualityScore
+		stats.Accepted = row.Accepted
+		stats.Rejected = row.Rejected
+		if row.SampleSize > 0 {
+			stats.ConvRate = float64(row.Conversions) / float64(row.SampleSize)
+			stats.SuccessRate = stats.ConvRate
+		}
+		if reviewed := row.Accepted + row.Rejected; reviewed > 0 {
+			stats.AcceptRate = float64(row.Accepted) / float64(reviewed)
+		}
+	}
 
-	exp, ok := s.experiments[expID]
-	if !ok {
-		return nil, fmt.Errorf("experiment not found: %s", expID)
+	// 自定义指标按变体求均值
+	sums := make(map[string]map[string]float64)
+	counts := make(map[string]map[string]int)
+	var batch []TrialResult
+	err = s.db.WithContext(ctx).Select("id", "variant_id", "metrics").
+		Where("experiment_id = ? AND metrics IS NOT NULL", expID).
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, trial := range batch {
+				if sums[trial.VariantID] == nil {
+					sums[trial.VariantID] = make(map[string]float64)
+					counts[trial.VariantID] = make(map[string]int)
+				}
+				for k, v := range trial.Metrics {
+					sums[trial.VariantID][k] += v
+					counts[trial.VariantID][k]++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计自定义指标失败: %w", err)
+	}
+	for variantID, metricSums := range sums {
+		stats, ok := results.VariantStats[variantID]
+		if !ok {
+			continue
+		}
+		for k, sum := range metricSums {
+			stats.Metrics[k] = sum / float64(counts[variantID][k])
+		}
 	}
 
-	return exp, nil
+	return results, nil
 }
 
-// ListExperiments 列出所有实验
-func (s *ABTestService) ListExperiments(status ExperimentStatus) []*Experiment {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// metricSample 某变体在某指标上的样本统计
+type metricSample struct {
+	VariantID string
+	N         int64
+	Mean      float64
+	MeanSq    float64
+}
 
-	result := make([]*Experiment, 0)
-	for _, exp := range s.experiments {
-		if status == "" || exp.Status == status {
-			result = append(result, exp)
-		}
+func (m metricSample) variance() float64 {
+	if m.N < 2 {
+		return 0
 	}
-	return result
+	v := (m.MeanSq - m.Mean*m.Mean) * float64(m.N) / float64(m.N-1)
+	return math.Max(v, 0)
 }
 
-// DeleteExperiment 删除实验
-func (s *ABTestService) DeleteExperiment(expID string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, ok := s.experiments[expID]; !ok {
-		return fmt.Errorf("experiment not found: %s", expID)
+// metricExpr 指标对应的取值表达式与过滤条件
+func metricExpr(metric string) (expr, filter string, proportion, lowerIsBetter bool) {
+	switch metric {
+	case MetricAcceptRate:
+		return "CASE WHEN accepted THEN 1.0 ELSE 0.0 END", "accepted IS NOT NULL", true, false
+	case MetricQualityScore:
+		return "quality_score", "quality_score IS NOT NULL", false, false
+	case MetricLatency:
+		return "latency_ms", "", false, true
+	case MetricCost:
+		return "cost", "", false, true
+	default:
+		return "CASE WHEN success THEN 1.0 ELSE 0.0 END", "", true, false
 	}
-
-	delete(s.experiments, expID)
-	delete(s.results, expID)
-	return nil
 }
 
-// CalculateSignificance 计算统计显著性（简化版 Z-test）
-func (s *ABTestService) CalculateSignificance(expID string) (*SignificanceResult, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	exp, ok := s.experiments[expID]
-	if !ok {
-		return nil, fmt.Errorf("experiment not found")
+func (s *ABTestService) metricSamples(ctx context.Context, expID, metric string) (map[string]metricSample, error) {
+	expr, filter, _, _ := metricExpr(metric)
+	query := s.db.WithContext(ctx).Model(&TrialResult{}).
+		Select(fmt.Sprintf("variant_id, COUNT(*) AS n, COALESCE(AVG(%[1]s), 0) AS mean, COALESCE(AVG((%[1]s) * (%[1]s)), 0) AS mean_sq", expr)).
+		Where("experiment_id = ?", expID)
+	if filter != "" {
+		query = query.Where(filter)
 	}
-
-	results, ok := s.results[expID]
-	if !ok {
-		return nil, fmt.Errorf("results not found")
+	var rows []metricSample
+	if err := query.Group("variant_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	samples := make(map[string]metricSample, len(rows))
+	for _, row := range rows {
+		samples[row.VariantID] = row
 	}
+	return samples, nil
+}
 
-	// 找到对照组
-	var controlID string
-	for _, v := range exp.Variants {
-		if v.IsControl {
-			controlID = v.ID
-			break
-		}
+// CalculateSignificance 计算主指标的统计显著性
+// 比例指标使用双比例 Z 检验，连续指标使用 Welch Z 检验；多个实验组与对照组比较时做 Bonferroni 校正
+func (s *ABTestService) CalculateSignificance(ctx context.Context, tenantID, expID string) (*SignificanceResult, error) {
+	exp, err := s.GetExperiment(ctx, tenantID, expID)
+	if err != nil {
+		return nil, err
 	}
-	if controlID == "" {
-		controlID = exp.Variants[0].ID
+	samples, err := s.metricSamples(ctx, expID, exp.PrimaryMetric)
+	if err != nil {
+		return nil, fmt.Errorf("统计实验指标失败: %w", err)
 	}
+	return evaluateSignificance(exp, samples, time.Now()), nil
+}
 
-	controlStats := results.VariantStats[controlID]
-	if controlStats == nil || controlStats.SampleSize < 30 {
-		return nil, fmt.Errorf("insufficient control sample size")
-	}
+func evaluateSignificance(exp *Experiment, samples map[string]metricSample, now time.Time) *SignificanceResult {
+	_, _, proportion, lowerIsBetter := metricExpr(exp.PrimaryMetric)
+	confidence := exp.StopRule.confidence()
+	minSamples := exp.StopRule.minSamples()
+	control := exp.control()
 
 	sig := &SignificanceResult{
-		ControlID:     controlID,
-		Comparisons:   make([]VariantComparison, 0),
-	}
-
-	for variantID, stats := range results.VariantStats {
-		if variantID == controlID || stats.SampleSize < 30 {
+		ExperimentID:  exp.ID,
+		PrimaryMetric: exp.PrimaryMetric,
+		LowerIsBetter: lowerIsBetter,
+		Confidence:    confidence,
+		ControlID:     control.ID,
+		ControlSample: samples[control.ID].N,
+		ControlMean:   samples[control.ID].Mean,
+		Comparisons:   make([]VariantComparison, 0, len(exp.Variants)-1),
+	}
+
+	// Bonferroni 校正后的显著性水平
+	alpha := (1 - confidence) / float64(len(exp.Variants)-1)
+	zCritical := normalQuantile(1 - alpha/2)
+	zInterval := normalQuantile(1 - (1-confidence)/2)
+
+	c := samples[control.ID]
+	enoughSamples := c.N >= minSamples
+	for _, v := range exp.Variants {
+		if v.ID == control.ID {
 			continue
 		}
-
-		// 计算 Z-score
-		p1 := controlStats.ConvRate
-		p2 := stats.ConvRate
-		n1 := float64(controlStats.SampleSize)
-		n2 := float64(stats.SampleSize)
-
-		pooledP := (p1*n1 + p2*n2) / (n1 + n2)
-		se := pooledSE(pooledP, n1, n2)
-		
-		var zScore float64
-		if se > 0 {
-			zScore = (p2 - p1) / se
+		t := samples[v.ID]
+		if t.N < minSamples {
+			enoughSamples = false
+		}
+		cmp := VariantComparison{
+			VariantID:  v.ID,
+			SampleSize: t.N,
+			Mean:       t.Mean,
+			Difference: t.Mean - c.Mean,
+		}
+		if c.Mean != 0 {
+			cmp.Improvement = cmp.Difference / math.Abs(c.Mean) * 100
+			if lowerIsBetter {
+				cmp.Improvement = -cmp.Improvement
+			}
 		}
 
-		// 简化的显著性判断
-		significant := abs(zScore) > 1.96 // 95% 置信度
-
-		sig.Comparisons = append(sig.Comparisons, VariantComparison{
-			VariantID:   variantID,
-			Improvement: (p2 - p1) / p1 * 100,
-			ZScore:      zScore,
-			Significant: significant,
-		})
+		if c.N > 0 && t.N > 0 {
+			var seTest, seInterval float64
+			if proportion {
+				n1, n2 := float64(c.N), float64(t.N)
+				pooled := (c.Mean*n1 + t.Mean*n2) / (n1 + n2)
+				seTest = math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+				seInterval = math.Sqrt(c.Mean*(1-c.Mean)/n1 + t.Mean*(1-t.Mean)/n2)
+			} else {
+				seTest = math.Sqrt(c.variance()/float64(c.N) + t.variance()/float64(t.N))
+				seInterval = seTest
+			}
+			if seTest > 0 {
+				cmp.ZScore = cmp.Difference / seTest
+				cmp.PValue = math.Erfc(math.Abs(cmp.ZScore) / math.Sqrt2)
+				cmp.Significant = math.Abs(cmp.ZScore) > zCritical
+			}
+			cmp.ConfidenceInterval = [2]float64{cmp.Difference - zInterval*seInterval, cmp.Difference + zInterval*seInterval}
+		}
+		cmp.Better = cmp.Significant && cmp.Improvement > 0
+		sig.Comparisons = append(sig.Comparisons, cmp)
 	}
+	sort.Slice(sig.Comparisons, func(i, j int) bool {
+		return sig.Comparisons[i].VariantID < sig.Comparisons[j].VariantID
+	})
 
-	return sig, nil
+	sig.Decision = stopDecision(exp, sig.Comparisons, enoughSamples, now)
+	return sig
 }
 
-func pooledSE(p, n1, n2 float64) float64 {
-	if n1 <= 0 || n2 <= 0 {
-		return 0
+// stopDecision 按停止规则判断实验是否应结束
+func stopDecision(exp *Experiment, comparisons []VariantComparison, enoughSamples bool, now time.Time) StopDecision {
+	expired := exp.StopRule.MaxDurationHours > 0 && exp.StartTime != nil &&
+		now.Sub(*exp.StartTime) >= time.Duration(exp.StopRule.MaxDurationHours)*time.Hour
+
+	if enoughSamples {
+		var winner *VariantComparison
+		allWorse := len(comparisons) > 0
+		for i := range comparisons {
+			cmp := &comparisons[i]
+			if cmp.Better && (winner == nil || cmp.Improvement > winner.Improvement) {
+				winner = cmp
+			}
+			if !cmp.Significant || cmp.Improvement >= 0 {
+				allWorse = false
+			}
+		}
+		if winner != nil {
+			return StopDecision{ShouldStop: true, WinnerVariantID: winner.VariantID, Reason: fmt.Sprintf("变体 %s 显著优于对照组", winner.VariantID)}
+		}
+		if allWorse {
+			return StopDecision{ShouldStop: true, WinnerVariantID: exp.control().ID, Reason: "所有实验组均显著劣于对照组"}
+		}
+	}
+	if expired {
+		return StopDecision{ShouldStop: true, Reason: "已达最长运行时长，未发现显著差异"}
 	}
-	return sqrt(p * (1 - p) * (1/n1 + 1/n2))
+	if !enoughSamples {
+		return StopDecision{Reason: fmt.Sprintf("样本量不足（每个变体至少 %d）", exp.StopRule.minSamples())}
+	}
+	return StopDecision{Reason: "尚未发现显著差异"}
 }
 
-func sqrt(x float64) float64 {
-	if x <= 0 {
-		return 0
+// ApplyStopRule 计算显著性，满足停止条件时结束实验
+func (s *ABTestService) ApplyStopRule(ctx context.Context, tenantID, expID string) (*SignificanceResult, error) {
+	sig, err := s.CalculateSignificance(ctx, tenantID, expID)
+	if err != nil {
+		return nil, err
 	}
-	z := x
-	for i := 0; i < 10; i++ {
-		z = (z + x/z) / 2
+	if sig.Decision.ShouldStop {
+		if err := s.CompleteExperiment(ctx, tenantID, expID, sig.Decision.WinnerVariantID, sig.Decision.Reason); err != nil {
+			return sig, err
+		}
+		sig.Decision.Stopped = true
 	}
-	return z
+	return sig, nil
 }
 
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
-	}
-	return x
+// normalQuantile 标准正态分布分位数
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
 }
 
 // SignificanceResult 显著性结果
 type SignificanceResult struct {
-	ControlID   string              `json:"control_id"`
-	Comparisons []VariantComparison `json:"comparisons"`
+	ExperimentID  string              `json:"experiment_id"`
+	PrimaryMetric string              `json:"primary_metric"`
+	LowerIsBetter bool                `json:"lower_is_better"`
+	Confidence    float64             `json:"confidence"`
+	ControlID     string              `json:"control_id"`
+	ControlSample int64               `json:"control_sample_size"`
+	ControlMean   float64             `json:"control_mean"`
+	Comparisons   []VariantComparison `json:"comparisons"`
+	Decision      StopDecision        `json:"decision"`
 }
 
 // VariantComparison 变体对比
 type VariantComparison struct {
-	VariantID   string  `json:"variant_id"`
-	Improvement float64 `json:"improvement_pct"`
-	ZScore      float64 `json:"z_score"`
-	Significant bool    `json:"significant"`
+	VariantID          string     `json:"variant_id"`
+	SampleSize         int64      `json:"sample_size"`
+	Mean               float64    `json:"mean"`
+	Difference         float64    `json:"difference"`      // 与对照组均值之差
+	Improvement        float64    `json:"improvement_pct"` // 相对对照组的提升（已按指标方向调整）
+	ZScore             float64    `json:"z_score"`
+	PValue             float64    `json:"p_value"`             // 双侧 p 值（未校正）
+	ConfidenceInterval [2]float64 `json:"confidence_interval"` // 差值的置信区间
+	Significant        bool       `json:"significant"`
+	Better             bool       `json:"better"` // 显著优于对照组
+}
+
+// StopDecision 停止规则判定
+type StopDecision struct {
+	ShouldStop      bool   `json:"should_stop"`
+	Stopped         bool   `json:"stopped"` // 本次判定已自动结束实验
+	WinnerVariantID string `json:"winner_variant_id,omitempty"`
+	Reason          string `json:"reason"`
 }
 
 // ABTestMiddleware A/B 测试中间件（用于 Agent 执行）
@@ -443,10 +965,10 @@ func NewABTestMiddleware(service *ABTestService) *ABTestMiddleware {
 // WrapExecution 包装 Agent 执行，自动分配变体并记录结果
 func (m *ABTestMiddleware) WrapExecution(
 	ctx context.Context,
-	expID, userID string,
+	tenantID, expID, subjectID string,
 	execute func(config map[string]any) (*TrialResult, error),
 ) (*TrialResult, error) {
-	variant, err := m.service.GetVariant(expID, userID)
+	variant, err := m.service.GetVariant(ctx, tenantID, expID, subjectID)
 	if err != nil {
 		// 实验不存在或未运行，使用默认配置
 		return execute(nil)
@@ -455,14 +977,13 @@ func (m *ABTestMiddleware) WrapExecution(
 	result, err := execute(variant.AgentConfig)
 	if err != nil {
 		// 记录失败
-		m.service.RecordResult(expID, variant.ID, &TrialResult{
-			Success: false,
-		})
+		_ = m.service.RecordResult(ctx, tenantID, expID, variant.ID, &TrialResult{SubjectID: subjectID, Success: false})
 		return nil, err
 	}
 
 	// 记录成功
-	m.service.RecordResult(expID, variant.ID, result)
+	result.SubjectID = subjectID
+	_ = m.service.RecordResult(ctx, tenantID, expID, variant.ID, result)
 
 	return result, nil
 }
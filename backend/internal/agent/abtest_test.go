@@ -0,0 +1,208 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+	testAgentID  = "22222222-2222-2222-2222-222222222222"
+)
+
+func newTestABTestService(t *testing.T) *ABTestService {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	svc := NewABTestService(db)
+	if err := svc.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return svc
+}
+
+func startTestExperiment(t *testing.T, svc *ABTestService, metric string, rule StopRule) *Experiment {
+	t.Helper()
+	ctx := context.Background()
+	exp := &Experiment{
+		TenantID:      testTenantID,
+		AgentID:       testAgentID,
+		Name:          "提示词对比",
+		Variants:      []Variant{{ID: "control"}, {ID: "concise", AgentConfig: map[string]any{"temperature": 0.2}}},
+		PrimaryMetric: metric,
+		StopRule:      rule,
+	}
+	if err := svc.CreateExperiment(ctx, exp); err != nil {
+		t.Fatalf("CreateExperiment: %v", err)
+	}
+	if err := svc.StartExperiment(ctx, testTenantID, exp.ID); err != nil {
+		t.Fatalf("StartExperiment: %v", err)
+	}
+	return exp
+}
+
+func TestABTestStickyAssignment(t *testing.T) {
+	svc := newTestABTestService(t)
+	ctx := context.Background()
+	exp := startTestExperiment(t, svc, MetricSuccess, StopRule{})
+
+	seen := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		subject := fmt.Sprintf("user:%d", i)
+		first, err := svc.GetVariant(ctx, testTenantID, exp.ID, subject)
+		if err != nil {
+			t.Fatalf("GetVariant: %v", err)
+		}
+		again, _ := svc.GetVariant(ctx, testTenantID, exp.ID, subject)
+		if again.ID != first.ID {
+			t.Fatalf("subject %s reassigned from %s to %s", subject, first.ID, again.ID)
+		}
+		seen[first.ID]++
+	}
+	if seen["control"] < 60 || seen["concise"] < 60 {
+		t.Fatalf("unbalanced split: %v", seen)
+	}
+
+	// 调整流量后已分配主体保持不变
+	before, _ := svc.GetVariant(ctx, testTenantID, exp.ID, "user:0")
+	svc.db.Model(&Experiment{}).Where("id = ?", exp.ID).
+		Update("traffic_split", `{"control":0,"concise":1}`)
+	after, _ := svc.GetVariant(ctx, testTenantID, exp.ID, "user:0")
+	if before.ID != after.ID {
+		t.Fatalf("assignment changed after traffic update: %s -> %s", before.ID, after.ID)
+	}
+
+	if err := svc.StartExperiment(ctx, testTenantID, startTestExperimentDraft(t, svc).ID); err == nil {
+		t.Fatal("expected second running experiment on the same agent to be rejected")
+	}
+}
+
+func startTestExperimentDraft(t *testing.T, svc *ABTestService) *Experiment {
+	t.Helper()
+	exp := &Experiment{TenantID: testTenantID, AgentID: testAgentID, Name: "另一实验",
+		Variants: []Variant{{ID: "a"}, {ID: "b"}}}
+	if err := svc.CreateExperiment(context.Background(), exp); err != nil {
+		t.Fatalf("CreateExperiment: %v", err)
+	}
+	return exp
+}
+
+func TestABTestResultsAndFeedback(t *testing.T) {
+	svc := newTestABTestService(t)
+	ctx := context.Background()
+	exp := startTestExperiment(t, svc, MetricAcceptRate, StopRule{})
+
+	record := func(variant string, success bool, latency int64) string {
+		trial := &TrialResult{TenantID: testTenantID, Success: success, LatencyMs: latency, Metrics: map[string]float64{"words": 100}}
+		if err := svc.RecordResult(ctx, testTenantID, exp.ID, variant, trial); err != nil {
+			t.Fatalf("RecordResult: %v", err)
+		}
+		return trial.ID
+	}
+	if err := svc.RecordResult(ctx, "other-tenant", exp.ID, "concise", &TrialResult{Success: true}); !errors.Is(err, ErrExperimentNotFound) {
+		t.Fatalf("expected ErrExperimentNotFound for another tenant, got %v", err)
+	}
+	accepted := record("concise", true, 100)
+	rejected := record("concise", true, 300)
+	record("control", false, 200)
+
+	if err := svc.RecordStagingReview(ctx, testTenantID, `{"experiment_trial_id":"`+accepted+`"}`, true); err != nil {
+		t.Fatalf("RecordStagingReview: %v", err)
+	}
+	if err := svc.RecordStagingReview(ctx, testTenantID, `{"experiment_trial_id":"`+rejected+`"}`, false); err != nil {
+		t.Fatalf("RecordStagingReview: %v", err)
+	}
+	if err := svc.RecordStagingReview(ctx, testTenantID, `{"review_note":"x"}`, true); err != nil {
+		t.Fatalf("metadata without trial should be ignored: %v", err)
+	}
+	score := 4.5
+	if err := svc.RecordFeedback(ctx, testTenantID, accepted, &TrialFeedback{QualityScore: &score}); err != nil {
+		t.Fatalf("RecordFeedback: %v", err)
+	}
+	if err := svc.RecordFeedback(ctx, testTenantID, "missing", &TrialFeedback{QualityScore: &score}); err != ErrTrialNotFound {
+		t.Fatalf("expected ErrTrialNotFound, got %v", err)
+	}
+
+	results, err := svc.GetResults(ctx, testTenantID, exp.ID)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	concise := results.VariantStats["concise"]
+	if concise.SampleSize != 2 || concise.SuccessRate != 1 || concise.AvgLatencyMs != 200 {
+		t.Fatalf("unexpected concise stats: %+v", concise)
+	}
+	if concise.Accepted != 1 || concise.Rejected != 1 || concise.AcceptRate != 0.5 {
+		t.Fatalf("unexpected accept stats: %+v", concise)
+	}
+	if concise.QualitySamples != 1 || concise.AvgQualityScore != 4.5 || concise.Metrics["words"] != 100 {
+		t.Fatalf("unexpected quality/custom metrics: %+v", concise)
+	}
+	if control := results.VariantStats["control"]; control.SampleSize != 1 || control.SuccessRate != 0 {
+		t.Fatalf("unexpected control stats: %+v", control)
+	}
+}
+
+func TestABTestSignificanceStopRule(t *testing.T) {
+	svc := newTestABTestService(t)
+	ctx := context.Background()
+	exp := startTestExperiment(t, svc, MetricSuccess, StopRule{MinSamplesPerVariant: 50, AutoStop: true})
+
+	for i := 0; i < 60; i++ {
+		// 对照组成功率 50%，实验组 90%
+		if err := svc.RecordResult(ctx, testTenantID, exp.ID, "control", &TrialResult{TenantID: testTenantID, Success: i%2 == 0}); err != nil {
+			t.Fatalf("RecordResult: %v", err)
+		}
+		if err := svc.RecordResult(ctx, testTenantID, exp.ID, "concise", &TrialResult{TenantID: testTenantID, Success: i%10 != 0}); err != nil {
+			t.Fatalf("RecordResult: %v", err)
+		}
+	}
+
+	stored, err := svc.GetExperiment(ctx, testTenantID, exp.ID)
+	if err != nil {
+		t.Fatalf("GetExperiment: %v", err)
+	}
+	if stored.Status != StatusCompleted || stored.WinnerVariantID != "concise" {
+		t.Fatalf("expected auto stop with concise winner, got %s/%s", stored.Status, stored.WinnerVariantID)
+	}
+
+	sig, err := svc.CalculateSignificance(ctx, testTenantID, exp.ID)
+	if err != nil {
+		t.Fatalf("CalculateSignificance: %v", err)
+	}
+	if len(sig.Comparisons) != 1 {
+		t.Fatalf("unexpected comparisons: %+v", sig.Comparisons)
+	}
+	cmp := sig.Comparisons[0]
+	if !cmp.Significant || !cmp.Better || cmp.PValue >= 0.05 || cmp.ConfidenceInterval[0] <= 0 {
+		t.Fatalf("expected significant improvement: %+v", cmp)
+	}
+}
+
+func TestStopDecisionMaxDuration(t *testing.T) {
+	start := time.Now().Add(-48 * time.Hour)
+	exp := &Experiment{
+		Variants:      []Variant{{ID: "a", IsControl: true}, {ID: "b"}},
+		PrimaryMetric: MetricLatency,
+		StopRule:      StopRule{MinSamplesPerVariant: 10, MaxDurationHours: 24},
+		StartTime:     &start,
+	}
+	samples := map[string]metricSample{
+		"a": {VariantID: "a", N: 5, Mean: 100, MeanSq: 10100},
+		"b": {VariantID: "b", N: 5, Mean: 90, MeanSq: 8200},
+	}
+	sig := evaluateSignificance(exp, samples, time.Now())
+	if !sig.LowerIsBetter || !sig.Decision.ShouldStop || sig.Decision.WinnerVariantID != "" {
+		t.Fatalf("expected expiry stop without winner: %+v", sig.Decision)
+	}
+	if sig.Comparisons[0].Improvement <= 0 {
+		t.Fatalf("lower latency should count as improvement: %+v", sig.Comparisons[0])
+	}
+}
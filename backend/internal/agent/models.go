@@ -28,6 +28,9 @@ type AgentConfig struct {
 	// 灵活模型路由配置（支持自定义任务类型）
 	ModelRouting map[string]string `json:"modelRouting" gorm:"column:model_routing;type:jsonb;serializer:json"`
 
+	// 成本感知路由：按 Prompt 规模、能力、预算与实时延迟在多个模型间选择
+	RoutingPolicy *ModelRoutingPolicy `json:"routingPolicy,omitempty" gorm:"column:routing_policy;type:jsonb;serializer:json"`
+
 	// 计算字段（不存储）
 	ModelID        string `json:"modelId" gorm:"-"`
 	ActiveModelID  string `json:"activeModelId" gorm:"-"`
@@ -50,6 +53,9 @@ type AgentConfig struct {
 	AllowedTools []string `json:"allowedTools" gorm:"type:jsonb;serializer:json"` // 允许使用的工具 ID 列表
 	AutoToolUse  bool     `json:"autoToolUse" gorm:"default:false"`               // 是否允许模型自主调用工具
 
+	// 结构化输出：输出必须符合的 JSON Schema（为空表示自由文本）
+	OutputSchema map[string]any `json:"outputSchema,omitempty" gorm:"type:jsonb;serializer:json"`
+
 	// 扩展配置
 	ExtraConfig map[string]any `json:"extraConfig" gorm:"type:jsonb;serializer:json"`
 
@@ -1,12 +1,14 @@
 package parser
 
 import (
+	"encoding/json"
 	"strings"
 )
 
 // RepairJSON 尝试修复常见的 JSON 格式错误
 // 1. 移除 Markdown 代码块标记 (```json ... ```)
 // 2. 移除首尾空白字符
+// 3. 截取正文前后夹杂说明文字时的 JSON 主体
 func RepairJSON(input string) string {
 	cleaned := strings.TrimSpace(input)
 
@@ -24,6 +26,18 @@ func RepairJSON(input string) string {
 		}
 	}
 
+	// 仍不是合法 JSON 时，截取第一个 { 或 [ 到最后一个对应括号之间的内容
+	cleaned = strings.TrimSpace(cleaned)
+	if start := strings.IndexAny(cleaned, "{["); start >= 0 && !json.Valid([]byte(cleaned)) {
+		closing := "}"
+		if cleaned[start] == '[' {
+			closing = "]"
+		}
+		if end := strings.LastIndex(cleaned, closing); end > start {
+			cleaned = cleaned[start : end+1]
+		}
+	}
+
 	// 也可以尝试更复杂的正则替换，例如修复尾部逗号等，
 	// 但对于大多数 LLM 输出，剥离 Markdown 是最关键的。
 	return strings.TrimSpace(cleaned)
@@ -0,0 +1,236 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaParser 按 JSON Schema 解析并校验输出
+// 支持常用关键字：type、properties、required、additionalProperties、items、enum、
+// minimum/maximum、minLength/maxLength、minItems/maxItems
+type SchemaParser struct {
+	Schema map[string]any
+}
+
+// NewSchemaParser 创建 Schema 解析器
+func NewSchemaParser(schema map[string]any) *SchemaParser {
+	return &SchemaParser{Schema: schema}
+}
+
+// Parse 修复并解析 JSON，校验不通过时返回 *SchemaError
+func (p *SchemaParser) Parse(text string) (any, error) {
+	var result any
+	if err := json.Unmarshal([]byte(RepairJSON(text)), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON output: %w", err)
+	}
+	if violations := ValidateSchema(result, p.Schema); len(violations) > 0 {
+		return result, &SchemaError{Violations: violations}
+	}
+	return result, nil
+}
+
+func (p *SchemaParser) FormatInstructions() string {
+	schemaBytes, _ := json.MarshalIndent(p.Schema, "", "  ")
+	return fmt.Sprintf("输出必须是符合以下 JSON Schema 的有效 JSON，不要包含其他文字：\n```json\n%s\n```", string(schemaBytes))
+}
+
+// SchemaError 输出不符合 Schema
+type SchemaError struct {
+	Violations []string
+}
+
+func (e *SchemaError) Error() string {
+	return "输出不符合 JSON Schema: " + strings.Join(e.Violations, "; ")
+}
+
+// ValidateSchema 校验值是否符合 Schema，返回所有违规项（为空表示通过）
+func ValidateSchema(value any, schema map[string]any) []string {
+	var violations []string
+	validateValue("$", value, schema, &violations)
+	return violations
+}
+
+func validateValue(path string, value any, schema map[string]any, violations *[]string) {
+	if len(schema) == 0 {
+		return
+	}
+	addf := func(format string, args ...any) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if matchesType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			addf("类型应为 %s，实际为 %s", strings.Join(types, "|"), jsonType(value))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, candidate := range enum {
+			if jsonEqual(candidate, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			addf("取值不在枚举范围 %v 内", enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, name := range stringList(schema["required"]) {
+			if _, ok := v[name]; !ok {
+				addf("缺少必填字段 %q", name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if propSchema, ok := props[key].(map[string]any); ok {
+				validateValue(path+"."+key, v[key], propSchema, violations)
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					addf("不允许的字段 %q", key)
+				}
+			case map[string]any:
+				validateValue(path+"."+key, v[key], extra, violations)
+			}
+		}
+
+	case []any:
+		if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(v)) < n {
+			addf("元素数量不能少于 %v", n)
+		}
+		if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(v)) > n {
+			addf("元素数量不能多于 %v", n)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), item, items, violations)
+			}
+		}
+
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := schemaNumber(schema["minLength"]); ok && length < n {
+			addf("长度不能小于 %v", n)
+		}
+		if n, ok := schemaNumber(schema["maxLength"]); ok && length > n {
+			addf("长度不能大于 %v", n)
+		}
+
+	case float64:
+		if n, ok := schemaNumber(schema["minimum"]); ok && v < n {
+			addf("不能小于 %v", n)
+		}
+		if n, ok := schemaNumber(schema["maximum"]); ok && v > n {
+			addf("不能大于 %v", n)
+		}
+	}
+}
+
+// schemaTypes 解析 type 关键字（字符串或字符串数组）
+func schemaTypes(v any) []string {
+	if s, ok := v.(string); ok {
+		return []string{s}
+	}
+	return stringList(v)
+}
+
+func stringList(v any) []string {
+	switch list := v.(type) {
+	case []string:
+		return list
+	case []any:
+		out := make([]string, 0, len(list))
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func schemaNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func matchesType(value any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func jsonEqual(a, b any) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aj) == string(bj)
+}
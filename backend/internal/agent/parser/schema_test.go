@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+var reviewSchema = map[string]any{
+	"type":                 "object",
+	"required":             []any{"verdict", "score"},
+	"additionalProperties": false,
+	"properties": map[string]any{
+		"verdict": map[string]any{"type": "string", "enum": []any{"pass", "revise"}},
+		"score":   map[string]any{"type": "integer", "minimum": 0, "maximum": 10},
+		"notes":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "maxItems": 2},
+	},
+}
+
+func TestSchemaParserAcceptsValidOutput(t *testing.T) {
+	text := "评审结果如下：\n```json\n{\"verdict\":\"pass\",\"score\":8,\"notes\":[\"节奏紧凑\"]}\n```"
+	value, err := NewSchemaParser(reviewSchema).Parse(text)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if m := value.(map[string]any); m["verdict"] != "pass" || m["score"] != float64(8) {
+		t.Fatalf("unexpected value %+v", value)
+	}
+}
+
+func TestSchemaParserReportsViolations(t *testing.T) {
+	_, err := NewSchemaParser(reviewSchema).Parse(`{"verdict":"maybe","score":8.5,"notes":["a","b",3],"extra":true}`)
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected SchemaError, got %v", err)
+	}
+	got := strings.Join(schemaErr.Violations, "\n")
+	for _, want := range []string{"$.verdict: 取值不在枚举范围", "$.score: 类型应为 integer", "$.notes: 元素数量不能多于 2", "$.notes[2]: 类型应为 string", `$: 不允许的字段 "extra"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing violation %q in:\n%s", want, got)
+		}
+	}
+
+	_, err = NewSchemaParser(reviewSchema).Parse(`{"verdict":"pass"}`)
+	if !errors.As(err, &schemaErr) || !strings.Contains(schemaErr.Error(), `缺少必填字段 "score"`) {
+		t.Fatalf("expected missing field violation, got %v", err)
+	}
+}
+
+func TestRepairJSONExtractsBody(t *testing.T) {
+	cases := map[string]string{
+		"```json\n{\"a\":1}\n```": `{"a":1}`,
+		"结果：{\"a\":{\"b\":2}} 以上": `{"a":{"b":2}}`,
+		"列表 [1,2] 完毕":             `[1,2]`,
+		`"含{括号}的字符串"`:             `"含{括号}的字符串"`,
+	}
+	for input, want := range cases {
+		if got := RepairJSON(input); got != want {
+			t.Errorf("RepairJSON(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package agent
+
+import (
+	"fmt"
+	"math"
+)
+
+// 路由策略
+const (
+	RoutingStrategyQuality = "quality" // 按候选顺序取第一个满足条件的模型（候选按质量从高到低排列）
+	RoutingStrategyCost    = "cost"    // 取估算成本最低的模型
+)
+
+// 路由原因
+const (
+	RoutingReasonQuality    = "quality"     // 质量优先
+	RoutingReasonCost       = "cost"        // 成本优先
+	RoutingReasonBudget     = "budget_low"  // 预算不足，切换为成本优先
+	RoutingReasonNoEligible = "no_eligible" // 没有满足条件的候选，使用默认模型
+	RoutingReasonFallback   = "fallback"    // 选中模型的客户端不可用，使用默认模型
+)
+
+// 候选被跳过的原因
+const (
+	RoutingSkipUnknownModel = "unknown_model"     // 模型不存在或已停用
+	RoutingSkipTools        = "tools_unsupported" // 需要工具调用但模型不支持
+	RoutingSkipContext      = "context_window"    // Prompt 与输出超出上下文窗口
+	RoutingSkipPromptLarge  = "prompt_too_large"  // 超过候选的 Prompt 上限
+	RoutingSkipPromptSmall  = "prompt_too_small"  // 低于候选的 Prompt 下限
+	RoutingSkipLatency      = "latency"           // 实时延迟超限
+)
+
+// defaultBudgetThreshold 预算剩余比例低于该值时切换为成本优先
+const defaultBudgetThreshold = 0.2
+
+// ModelRoutingPolicy 成本感知的模型路由策略：按 Prompt 规模、所需能力、租户剩余预算与模型实时延迟在候选模型中选择
+type ModelRoutingPolicy struct {
+	Enabled    bool               `json:"enabled"`
+	Strategy   string             `json:"strategy"` // quality（默认）/ cost
+	Candidates []RoutingCandidate `json:"candidates"`
+
+	// BudgetThreshold 租户预算剩余比例低于该值时改为成本优先，默认 0.2
+	BudgetThreshold float64 `json:"budgetThreshold,omitempty"`
+
+	// MaxLatencyMs 候选模型实时 P95 延迟上限（毫秒），0 表示使用模型自身的延迟 SLO
+	MaxLatencyMs int `json:"maxLatencyMs,omitempty"`
+}
+
+// RoutingCandidate 候选模型
+type RoutingCandidate struct {
+	ModelID string `json:"modelId"`
+
+	// MinPromptTokens / MaxPromptTokens 候选适用的估算 Prompt 规模，0 表示不限
+	MinPromptTokens int `json:"minPromptTokens,omitempty"`
+	MaxPromptTokens int `json:"maxPromptTokens,omitempty"`
+}
+
+// RoutingModel 候选模型的能力、价格与实时表现
+type RoutingModel struct {
+	ModelID         string
+	Name            string
+	ContextWindow   int // 0 表示未知
+	SupportsTools   bool
+	InputCostPer1K  float64
+	OutputCostPer1K float64
+	LatencySloMs    int
+	P95LatencyMs    float64 // 0 表示暂无监控数据
+}
+
+// EstimateCost 估算一次调用的成本
+func (m RoutingModel) EstimateCost(promptTokens, outputTokens int) float64 {
+	return float64(promptTokens)/1000*m.InputCostPer1K + float64(outputTokens)/1000*m.OutputCostPer1K
+}
+
+// RoutingBudget 租户预算状态
+type RoutingBudget struct {
+	RemainingRatio float64 // 剩余预算占阈值的比例，可能为负
+	Source         string  // 触发限制的成本告警
+}
+
+// RoutingRequest 一次调用的路由输入
+type RoutingRequest struct {
+	PromptTokens    int
+	MaxOutputTokens int
+	NeedsTools      bool
+	Budget          *RoutingBudget // nil 表示没有预算限制
+}
+
+// RoutingSkip 被跳过的候选
+type RoutingSkip struct {
+	ModelID string `json:"modelId"`
+	Reason  string `json:"reason"`
+}
+
+// RoutingDecision 路由决策，记录在 AgentResult.Metadata["model_routing"] 中
+type RoutingDecision struct {
+	ModelID              string        `json:"modelId"`
+	ModelName            string        `json:"modelName,omitempty"`
+	DefaultModelID       string        `json:"defaultModelId"`
+	Strategy             string        `json:"strategy"`
+	Reason               string        `json:"reason"`
+	PromptTokens         int           `json:"promptTokens"`
+	EstimatedCost        float64       `json:"estimatedCost"`
+	DefaultEstimatedCost float64       `json:"defaultEstimatedCost"`
+	BudgetRemainingRatio *float64      `json:"budgetRemainingRatio,omitempty"`
+	BudgetSource         string        `json:"budgetSource,omitempty"`
+	Skipped              []RoutingSkip `json:"skipped,omitempty"`
+}
+
+// Validate 校验路由策略
+func (p *ModelRoutingPolicy) Validate() error {
+	switch p.Strategy {
+	case "", RoutingStrategyQuality, RoutingStrategyCost:
+	default:
+		return fmt.Errorf("无效的路由策略: %s", p.Strategy)
+	}
+	if p.Enabled && len(p.Candidates) == 0 {
+		return fmt.Errorf("启用模型路由时至少需要一个候选模型")
+	}
+	for _, c := range p.Candidates {
+		if c.ModelID == "" {
+			return fmt.Errorf("候选模型 ID 不能为空")
+		}
+		if c.MaxPromptTokens > 0 && c.MinPromptTokens > c.MaxPromptTokens {
+			return fmt.Errorf("候选模型 %s 的 Prompt 规模区间无效", c.ModelID)
+		}
+	}
+	if p.BudgetThreshold < 0 || p.BudgetThreshold > 1 {
+		return fmt.Errorf("预算阈值应在 0 到 1 之间")
+	}
+	return nil
+}
+
+// Route 在候选中选择模型；models 为候选模型信息，defaultModelID 为 Agent 原本使用的模型
+func (p *ModelRoutingPolicy) Route(req RoutingRequest, models map[string]RoutingModel, defaultModelID string) *RoutingDecision {
+	decision := &RoutingDecision{
+		ModelID:        defaultModelID,
+		DefaultModelID: defaultModelID,
+		Strategy:       p.Strategy,
+		Reason:         RoutingReasonQuality,
+		PromptTokens:   req.PromptTokens,
+	}
+	if decision.Strategy != RoutingStrategyCost {
+		decision.Strategy = RoutingStrategyQuality
+	}
+	if decision.Strategy == RoutingStrategyCost {
+		decision.Reason = RoutingReasonCost
+	}
+	if req.Budget != nil {
+		ratio := req.Budget.RemainingRatio
+		decision.BudgetRemainingRatio = &ratio
+		decision.BudgetSource = req.Budget.Source
+		threshold := p.BudgetThreshold
+		if threshold <= 0 {
+			threshold = defaultBudgetThreshold
+		}
+		if ratio < threshold {
+			decision.Strategy, decision.Reason = RoutingStrategyCost, RoutingReasonBudget
+		}
+	}
+	if m, ok := models[defaultModelID]; ok {
+		decision.DefaultEstimatedCost = m.EstimateCost(req.PromptTokens, req.MaxOutputTokens)
+	}
+
+	// 能力与规模过滤
+	var eligible, fast []RoutingModel
+	var slow []RoutingSkip
+	for _, c := range p.Candidates {
+		m, ok := models[c.ModelID]
+		reason := ""
+		switch {
+		case !ok:
+			reason = RoutingSkipUnknownModel
+		case req.NeedsTools && !m.SupportsTools:
+			reason = RoutingSkipTools
+		case m.ContextWindow > 0 && req.PromptTokens+req.MaxOutputTokens > m.ContextWindow:
+			reason = RoutingSkipContext
+		case c.MaxPromptTokens > 0 && req.PromptTokens > c.MaxPromptTokens:
+			reason = RoutingSkipPromptLarge
+		case c.MinPromptTokens > 0 && req.PromptTokens < c.MinPromptTokens:
+			reason = RoutingSkipPromptSmall
+		}
+		if reason != "" {
+			decision.Skipped = append(decision.Skipped, RoutingSkip{ModelID: c.ModelID, Reason: reason})
+			continue
+		}
+		eligible = append(eligible, m)
+		if p.tooSlow(m) {
+			slow = append(slow, RoutingSkip{ModelID: c.ModelID, Reason: RoutingSkipLatency})
+			continue
+		}
+		fast = append(fast, m)
+	}
+	// 全部候选都超出延迟上限时不因延迟排除
+	if len(fast) > 0 {
+		eligible = fast
+		decision.Skipped = append(decision.Skipped, slow...)
+	}
+	if len(eligible) == 0 {
+		decision.Reason = RoutingReasonNoEligible
+		decision.EstimatedCost = decision.DefaultEstimatedCost
+		return decision
+	}
+
+	chosen := eligible[0]
+	if decision.Strategy == RoutingStrategyCost {
+		best := math.Inf(1)
+		for _, m := range eligible {
+			if cost := m.EstimateCost(req.PromptTokens, req.MaxOutputTokens); cost < best {
+				best, chosen = cost, m
+			}
+		}
+	}
+	decision.ModelID = chosen.ModelID
+	decision.ModelName = chosen.Name
+	decision.EstimatedCost = chosen.EstimateCost(req.PromptTokens, req.MaxOutputTokens)
+	return decision
+}
+
+// tooSlow 模型实时 P95 延迟是否超出上限
+func (p *ModelRoutingPolicy) tooSlow(m RoutingModel) bool {
+	limit := float64(p.MaxLatencyMs)
+	if limit <= 0 {
+		limit = float64(m.LatencySloMs)
+	}
+	return limit > 0 && m.P95LatencyMs > limit
+}
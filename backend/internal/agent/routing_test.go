@@ -0,0 +1,146 @@
+package agent
+
+import "testing"
+
+func routingTestModels() map[string]RoutingModel {
+	return map[string]RoutingModel{
+		"flagship": {ModelID: "flagship", Name: "旗舰", ContextWindow: 128000, SupportsTools: true, InputCostPer1K: 0.01, OutputCostPer1K: 0.03},
+		"mini":     {ModelID: "mini", Name: "轻量", ContextWindow: 8000, SupportsTools: false, InputCostPer1K: 0.0005, OutputCostPer1K: 0.0015},
+		"mid":      {ModelID: "mid", Name: "中档", ContextWindow: 32000, SupportsTools: true, InputCostPer1K: 0.002, OutputCostPer1K: 0.006},
+	}
+}
+
+func TestModelRoutingPolicyRoute(t *testing.T) {
+	models := routingTestModels()
+	candidates := []RoutingCandidate{{ModelID: "mini", MaxPromptTokens: 2000}, {ModelID: "mid"}, {ModelID: "flagship"}}
+
+	cases := []struct {
+		name      string
+		policy    ModelRoutingPolicy
+		req       RoutingRequest
+		models    map[string]RoutingModel
+		wantModel string
+		wantWhy   string
+		wantSkip  string
+	}{
+		{
+			name:      "quality picks first eligible",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: candidates},
+			req:       RoutingRequest{PromptTokens: 500, MaxOutputTokens: 1000},
+			wantModel: "mini",
+			wantWhy:   RoutingReasonQuality,
+		},
+		{
+			name:      "large prompt skips small candidate",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: candidates},
+			req:       RoutingRequest{PromptTokens: 5000, MaxOutputTokens: 1000},
+			wantModel: "mid",
+			wantWhy:   RoutingReasonQuality,
+			wantSkip:  RoutingSkipPromptLarge,
+		},
+		{
+			name:      "tools required",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: candidates},
+			req:       RoutingRequest{PromptTokens: 500, NeedsTools: true},
+			wantModel: "mid",
+			wantWhy:   RoutingReasonQuality,
+			wantSkip:  RoutingSkipTools,
+		},
+		{
+			name:      "long context",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: []RoutingCandidate{{ModelID: "mid"}, {ModelID: "flagship"}}},
+			req:       RoutingRequest{PromptTokens: 60000, MaxOutputTokens: 4000},
+			wantModel: "flagship",
+			wantWhy:   RoutingReasonQuality,
+			wantSkip:  RoutingSkipContext,
+		},
+		{
+			name:      "cost picks cheapest",
+			policy:    ModelRoutingPolicy{Enabled: true, Strategy: RoutingStrategyCost, Candidates: []RoutingCandidate{{ModelID: "flagship"}, {ModelID: "mid"}}},
+			req:       RoutingRequest{PromptTokens: 500, MaxOutputTokens: 500},
+			wantModel: "mid",
+			wantWhy:   RoutingReasonCost,
+		},
+		{
+			name:      "low budget switches to cost",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: []RoutingCandidate{{ModelID: "flagship"}, {ModelID: "mid"}}},
+			req:       RoutingRequest{PromptTokens: 500, Budget: &RoutingBudget{RemainingRatio: 0.1, Source: "月度预算"}},
+			wantModel: "mid",
+			wantWhy:   RoutingReasonBudget,
+		},
+		{
+			name:      "healthy budget keeps quality",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: []RoutingCandidate{{ModelID: "flagship"}, {ModelID: "mid"}}},
+			req:       RoutingRequest{PromptTokens: 500, Budget: &RoutingBudget{RemainingRatio: 0.6}},
+			wantModel: "flagship",
+			wantWhy:   RoutingReasonQuality,
+		},
+		{
+			name:   "slow candidate skipped",
+			policy: ModelRoutingPolicy{Enabled: true, MaxLatencyMs: 3000, Candidates: []RoutingCandidate{{ModelID: "flagship"}, {ModelID: "mid"}}},
+			req:    RoutingRequest{PromptTokens: 500},
+			models: func() map[string]RoutingModel {
+				m := routingTestModels()
+				slow := m["flagship"]
+				slow.P95LatencyMs = 8000
+				m["flagship"] = slow
+				return m
+			}(),
+			wantModel: "mid",
+			wantWhy:   RoutingReasonQuality,
+			wantSkip:  RoutingSkipLatency,
+		},
+		{
+			name:      "no eligible falls back to default",
+			policy:    ModelRoutingPolicy{Enabled: true, Candidates: []RoutingCandidate{{ModelID: "mini"}, {ModelID: "missing"}}},
+			req:       RoutingRequest{PromptTokens: 500, NeedsTools: true},
+			wantModel: "flagship",
+			wantWhy:   RoutingReasonNoEligible,
+			wantSkip:  RoutingSkipUnknownModel,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := tc.models
+			if m == nil {
+				m = models
+			}
+			decision := tc.policy.Route(tc.req, m, "flagship")
+			if decision.ModelID != tc.wantModel || decision.Reason != tc.wantWhy {
+				t.Fatalf("got model=%s reason=%s, want model=%s reason=%s", decision.ModelID, decision.Reason, tc.wantModel, tc.wantWhy)
+			}
+			if tc.wantSkip != "" {
+				found := false
+				for _, s := range decision.Skipped {
+					found = found || s.Reason == tc.wantSkip
+				}
+				if !found {
+					t.Fatalf("expected skip reason %s, got %+v", tc.wantSkip, decision.Skipped)
+				}
+			}
+			if decision.DefaultEstimatedCost < decision.EstimatedCost-1e-12 && decision.Reason != RoutingReasonQuality {
+				t.Fatalf("cost routing should not exceed default cost: %+v", decision)
+			}
+		})
+	}
+}
+
+func TestModelRoutingPolicyValidate(t *testing.T) {
+	valid := ModelRoutingPolicy{Enabled: true, Strategy: RoutingStrategyCost, Candidates: []RoutingCandidate{{ModelID: "mini"}}}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid policy: %v", err)
+	}
+	invalid := []ModelRoutingPolicy{
+		{Enabled: true},
+		{Enabled: true, Strategy: "fastest", Candidates: []RoutingCandidate{{ModelID: "mini"}}},
+		{Enabled: true, Candidates: []RoutingCandidate{{}}},
+		{Enabled: true, Candidates: []RoutingCandidate{{ModelID: "mini", MinPromptTokens: 100, MaxPromptTokens: 10}}},
+		{Enabled: true, BudgetThreshold: 1.5, Candidates: []RoutingCandidate{{ModelID: "mini"}}},
+	}
+	for i, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
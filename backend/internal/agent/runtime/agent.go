@@ -76,6 +76,27 @@ type AgentChunk struct {
 
 	// Metadata 元数据（仅在最后一个 chunk）
 	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Event 事件类型（为空表示普通内容块，tool_call / tool_result 表示工具调用过程）
+	Event string `json:"event,omitempty"`
+
+	// ToolCall 工具调用信息（仅 tool_call / tool_result 事件）
+	ToolCall *ToolCallEvent `json:"tool_call,omitempty"`
+}
+
+// 流式事件类型
+const (
+	ChunkEventToolCall   = "tool_call"
+	ChunkEventToolResult = "tool_result"
+)
+
+// ToolCallEvent 流式响应中的工具调用事件
+type ToolCallEvent struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 // Message 消息
@@ -0,0 +1,56 @@
+package runtime
+
+import (
+	"context"
+
+	"backend/internal/ai"
+)
+
+// scopedAgent 在执行前写入调用归属（租户、用户、工作流、Agent），供模型调用计费与 Token 审计使用
+type scopedAgent struct {
+	Agent
+	tenantID string
+	agentID  string
+}
+
+// withCallScope 包装 Agent 以写入调用归属
+func (r *Registry) withCallScope(agent Agent, tenantID, agentID string) Agent {
+	return &scopedAgent{Agent: agent, tenantID: tenantID, agentID: agentID}
+}
+
+// Execute 执行 Agent
+func (a *scopedAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	return a.Agent.Execute(callScopeContext(ctx, a.tenantID, a.agentID, input), input)
+}
+
+// ExecuteStream 流式执行 Agent
+func (a *scopedAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	return a.Agent.ExecuteStream(callScopeContext(ctx, a.tenantID, a.agentID, input), input)
+}
+
+// callScopeContext 写入调用归属
+// 外层（如工作流执行器）已写入时沿用其执行、步骤信息，只替换 Agent
+func callScopeContext(ctx context.Context, tenantID, agentID string, input *AgentInput) context.Context {
+	scope := ai.CallScopeFromContext(ctx)
+	switch {
+	case scope == nil:
+		scope = &ai.CallScope{TenantID: tenantID, AgentID: agentID}
+	case scope.AgentID != agentID:
+		scope = scope.WithAgent(agentID)
+	default:
+		return ctx
+	}
+
+	if input != nil && input.Context != nil {
+		if scope.UserID == "" {
+			scope.UserID = input.Context.UserID
+		}
+		if scope.WorkflowID == "" && input.Context.WorkflowID != nil {
+			scope.WorkflowID = *input.Context.WorkflowID
+		}
+		if scope.StepID == "" && input.Context.StepID != nil {
+			scope.StepID = *input.Context.StepID
+		}
+	}
+	return ai.WithCallScope(ctx, scope)
+}
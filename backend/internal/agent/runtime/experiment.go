@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	agentpkg "backend/internal/agent"
+	"backend/internal/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// SetExperimentService 设置 A/B 实验服务，设置后 Agent 执行会按运行中的实验分配变体并记录指标
+func (r *Registry) SetExperimentService(experiments *agentpkg.ABTestService) {
+	r.experiments = experiments
+}
+
+// RecordExperimentFeedback 为试验补充质量评分或采纳结果（未启用实验时忽略）
+func (r *Registry) RecordExperimentFeedback(ctx context.Context, tenantID, trialID string, feedback *agentpkg.TrialFeedback) error {
+	if r.experiments == nil || trialID == "" {
+		return nil
+	}
+	return r.experiments.RecordFeedback(ctx, tenantID, trialID, feedback)
+}
+
+// experimentAgent 在执行时查找 Agent 运行中的实验，按主体分配变体并记录试验结果
+type experimentAgent struct {
+	Agent
+	registry *Registry
+	tenantID string
+	agentID  string
+}
+
+// withExperiments 包装 Agent 以支持 A/B 实验
+func (r *Registry) withExperiments(agent Agent, tenantID, agentID string) Agent {
+	return &experimentAgent{Agent: agent, registry: r, tenantID: tenantID, agentID: agentID}
+}
+
+// experimentTrial 一次执行对应的实验试验
+type experimentTrial struct {
+	experiment *agentpkg.Experiment
+	variant    *agentpkg.Variant
+	trial      *agentpkg.TrialResult
+	start      time.Time
+}
+
+// begin 为本次执行分配变体，返回实际执行的 Agent；不参与实验时 trial 为 nil
+func (a *experimentAgent) begin(ctx context.Context, input *AgentInput) (Agent, *experimentTrial) {
+	experiments := a.registry.experiments
+	if experiments == nil {
+		return a.Agent, nil
+	}
+	exp, err := experiments.ActiveExperimentForAgent(ctx, a.tenantID, a.agentID)
+	if err != nil || exp == nil {
+		return a.Agent, nil
+	}
+	subject := experimentSubject(input)
+	if subject == "" {
+		return a.Agent, nil
+	}
+	variant, err := experiments.GetVariant(ctx, a.tenantID, exp.ID, subject)
+	if err != nil {
+		logger.Warn("分配实验变体失败", zap.String("experiment_id", exp.ID), zap.Error(err))
+		return a.Agent, nil
+	}
+
+	target := a.Agent
+	if len(variant.AgentConfig) > 0 {
+		target, err = a.registry.variantAgent(ctx, a.tenantID, a.agentID, exp.ID, variant)
+		if err != nil {
+			logger.Warn("创建实验变体 Agent 失败，使用原配置", zap.String("experiment_id", exp.ID),
+				zap.String("variant_id", variant.ID), zap.Error(err))
+			return a.Agent, nil
+		}
+	}
+
+	trial := &agentpkg.TrialResult{
+		ID:        uuid.New().String(),
+		TenantID:  a.tenantID,
+		SubjectID: subject,
+		Source:    "agent",
+	}
+	if input != nil && input.Context != nil {
+		if input.Context.WorkflowID != nil && *input.Context.WorkflowID != "" {
+			trial.Source = "workflow"
+			trial.ReferenceID = *input.Context.WorkflowID
+		} else if input.Context.SessionID != nil {
+			trial.ReferenceID = *input.Context.SessionID
+		}
+	}
+	return target, &experimentTrial{experiment: exp, variant: variant, trial: trial, start: time.Now()}
+}
+
+// finish 记录试验结果；执行上下文取消时仍然写入
+func (t *experimentTrial) finish(ctx context.Context, experiments *agentpkg.ABTestService, success bool, result *AgentResult) {
+	t.trial.Success = success
+	t.trial.LatencyMs = time.Since(t.start).Milliseconds()
+	if result != nil {
+		if result.Usage != nil {
+			t.trial.Tokens = int64(result.Usage.TotalTokens)
+		}
+		t.trial.Cost = result.Cost
+		if score, ok := result.Metadata["quality_score"].(float64); ok {
+			t.trial.QualityScore = &score
+		}
+	}
+	if err := experiments.RecordResult(context.WithoutCancel(ctx), t.trial.TenantID, t.experiment.ID, t.variant.ID, t.trial); err != nil {
+		logger.Warn("记录实验结果失败", zap.String("experiment_id", t.experiment.ID), zap.Error(err))
+	}
+}
+
+// annotate 在结果元数据中标记实验信息，调用方可据此回传评分或在暂存文件元数据中关联
+func (t *experimentTrial) annotate(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	metadata["experiment_id"] = t.experiment.ID
+	metadata["experiment_variant_id"] = t.variant.ID
+	metadata["experiment_trial_id"] = t.trial.ID
+	return metadata
+}
+
+// Execute 执行 Agent 并记录实验结果
+func (a *experimentAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	target, trial := a.begin(ctx, input)
+	result, err := target.Execute(ctx, input)
+	if trial == nil {
+		return result, err
+	}
+
+	trial.finish(ctx, a.registry.experiments, err == nil && result != nil && result.Status != "failed", result)
+	if result != nil {
+		result.Metadata = trial.annotate(result.Metadata)
+	}
+	return result, err
+}
+
+// ExecuteStream 流式执行 Agent，结束时记录实验结果
+func (a *experimentAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	target, trial := a.begin(ctx, input)
+	chunkChan, errChan := target.ExecuteStream(ctx, input)
+	if trial == nil {
+		return chunkChan, errChan
+	}
+
+	outChan := make(chan AgentChunk, 10)
+	outErrChan := make(chan error, 1)
+	go func() {
+		defer close(outChan)
+		defer close(outErrChan)
+
+		// 最后一个 chunk 的元数据携带累计用量与评分，用于记录试验结果
+		var result *AgentResult
+		for chunk := range chunkChan {
+			if chunk.Done {
+				result = streamTrialResult(chunk.Metadata)
+				chunk.Metadata = trial.annotate(chunk.Metadata)
+			}
+			if err := sendChunk(ctx, outChan, chunk); err != nil {
+				// 调用方已离开：按失败记录试验，并排空上游输出让其正常退出
+				drainInBackground(chunkChan)
+				trial.finish(ctx, a.registry.experiments, false, result)
+				outErrChan <- err
+				return
+			}
+		}
+		err := <-errChan
+		trial.finish(ctx, a.registry.experiments, err == nil, result)
+		if err != nil {
+			outErrChan <- err
+		}
+	}()
+	return outChan, outErrChan
+}
+
+// streamTrialResult 从流式输出最后一个 chunk 的元数据构造试验结果
+func streamTrialResult(metadata map[string]any) *AgentResult {
+	result := &AgentResult{Metadata: metadata}
+	if usage, ok := metadata["usage"].(*Usage); ok {
+		result.Usage = usage
+	}
+	return result
+}
+
+// variantAgent 获取应用了变体配置的 Agent 实例
+func (r *Registry) variantAgent(ctx context.Context, tenantID, agentID, experimentID string, variant *agentpkg.Variant) (Agent, error) {
+	cacheKey := fmt.Sprintf("%s:%s:%s:%s", tenantID, agentID, experimentID, variant.ID)
+	r.mu.RLock()
+	if agent, ok := r.agents[cacheKey]; ok {
+		r.mu.RUnlock()
+		return agent, nil
+	}
+	r.mu.RUnlock()
+
+	var config agentpkg.AgentConfig
+	if err := r.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", agentID, tenantID).
+		First(&config).Error; err != nil {
+		return nil, fmt.Errorf("查询 Agent 配置失败: %w", err)
+	}
+
+	agent, err := r.createAgent(ctx, variant.ApplyTo(&config))
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.agents[cacheKey] = agent
+	r.mu.Unlock()
+	return agent, nil
+}
+
+// experimentSubject 变体分配主体：优先用户，其次会话
+func experimentSubject(input *AgentInput) string {
+	if input == nil || input.Context == nil {
+		return ""
+	}
+	if input.Context.UserID != "" {
+		return "user:" + input.Context.UserID
+	}
+	if input.Context.SessionID != nil && *input.Context.SessionID != "" {
+		return "session:" + *input.Context.SessionID
+	}
+	return ""
+}
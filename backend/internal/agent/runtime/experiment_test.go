@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	agentpkg "backend/internal/agent"
+	"backend/internal/ai"
+	"backend/internal/logger"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	experimentTestTenantID = "11111111-1111-1111-1111-111111111111"
+	experimentTestAgentID  = "22222222-2222-2222-2222-222222222222"
+)
+
+// endlessStreamAgent 测试用 Agent，持续输出 chunk 直到 ctx 结束
+type endlessStreamAgent struct {
+	stopped chan struct{}
+}
+
+func (a *endlessStreamAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	return &AgentResult{}, nil
+}
+
+func (a *endlessStreamAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	out := make(chan AgentChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(a.stopped)
+		defer close(out)
+		defer close(errCh)
+		for {
+			if err := sendChunk(ctx, out, AgentChunk{Content: "片段"}); err != nil {
+				errCh <- err
+				return
+			}
+		}
+	}()
+	return out, errCh
+}
+
+func (a *endlessStreamAgent) Name() string { return "endless" }
+
+func (a *endlessStreamAgent) Type() string { return "test" }
+
+func TestStreamTrialResultUsesFinalChunkUsage(t *testing.T) {
+	client := &streamingModelClient{chunks: []ai.StreamChunk{
+		{Content: "你好"},
+		{FinishReason: "stop", Usage: &ai.Usage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+	}}
+	out := make(chan AgentChunk, 4)
+	if err := streamCompletion(context.Background(), nil, client, nil, &AgentConfig{}, nil, out); err != nil {
+		t.Fatalf("streamCompletion: %v", err)
+	}
+	close(out)
+	var last AgentChunk
+	for chunk := range out {
+		last = chunk
+	}
+	if !last.Done {
+		t.Fatalf("expected final chunk, got %+v", last)
+	}
+	result := streamTrialResult(last.Metadata)
+	if result.Usage == nil || result.Usage.TotalTokens != 5 {
+		t.Fatalf("expected usage from final chunk, got %+v", result.Usage)
+	}
+}
+
+func TestExperimentStreamFinishesTrialWhenConsumerLeaves(t *testing.T) {
+	logger.Init("error", "console", "stdout")
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	experiments := agentpkg.NewABTestService(db)
+	if err := experiments.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	exp := &agentpkg.Experiment{
+		TenantID: experimentTestTenantID,
+		AgentID:  experimentTestAgentID,
+		Name:     "流式试验",
+		Variants: []agentpkg.Variant{{ID: "control"}, {ID: "treatment"}},
+	}
+	if err := experiments.CreateExperiment(context.Background(), exp); err != nil {
+		t.Fatalf("CreateExperiment: %v", err)
+	}
+	if err := experiments.StartExperiment(context.Background(), experimentTestTenantID, exp.ID); err != nil {
+		t.Fatalf("StartExperiment: %v", err)
+	}
+
+	inner := &endlessStreamAgent{stopped: make(chan struct{})}
+	registry := &Registry{experiments: experiments, agents: make(map[string]Agent)}
+	agent := registry.withExperiments(inner, experimentTestTenantID, experimentTestAgentID)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, errs := agent.ExecuteStream(ctx, &AgentInput{Context: &AgentContext{UserID: "user-1"}})
+	<-chunks
+	cancel()
+
+	// 调用方不再读取 chunks，包装层仍应结束并记录试验
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("experiment stream did not finish after the consumer left")
+	}
+	select {
+	case <-inner.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("wrapped agent stream kept running after cancel")
+	}
+
+	results, err := experiments.GetResults(context.Background(), experimentTestTenantID, exp.ID)
+	if err != nil {
+		t.Fatalf("GetResults: %v", err)
+	}
+	var samples int64
+	for _, stats := range results.VariantStats {
+		samples += stats.SampleSize
+		if stats.SampleSize > 0 && stats.SuccessRate != 0 {
+			t.Fatalf("cancelled trial must be recorded as failed: %+v", stats)
+		}
+	}
+	if samples != 1 {
+		t.Fatalf("expected 1 recorded trial, got %d", samples)
+	}
+}
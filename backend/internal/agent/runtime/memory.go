@@ -84,7 +84,7 @@ func (m *RAGMemoryService) Search(ctx context.Context, kbID string, query string
 	}
 
 	// 2. 搜索
-	searchResults, err := m.vectorStore.Search(ctx, kbID, embeddings[0], limit)
+	searchResults, err := m.vectorStore.Search(ctx, kbID, embeddings[0], limit, nil)
 	if err != nil {
 		return nil, err
 	}
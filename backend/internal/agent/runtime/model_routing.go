@@ -0,0 +1,271 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	agentpkg "backend/internal/agent"
+	"backend/internal/ai"
+	"backend/internal/billing"
+	"backend/internal/logger"
+	modelspkg "backend/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// routingBudgetTTL 租户预算状态缓存时间，避免每次调用都汇总成本
+const routingBudgetTTL = time.Minute
+
+// RoutingBilling 模型路由依赖的计费能力：读取租户剩余预算并记录路由决策
+type RoutingBilling interface {
+	GetBudgetStatus(ctx context.Context, tenantID string) (*billing.BudgetStatus, error)
+	RecordRoutingDecision(ctx context.Context, decision *billing.ModelRoutingDecision) error
+}
+
+// SetRoutingBilling 设置模型路由的计费服务，未设置时路由不考虑预算且不落库决策
+func (r *Registry) SetRoutingBilling(routingBilling RoutingBilling) {
+	r.routingBilling = routingBilling
+}
+
+// routingBudgetEntry 预算状态缓存项
+type routingBudgetEntry struct {
+	budget    *agentpkg.RoutingBudget
+	expiresAt time.Time
+}
+
+// routedAgent 按 Agent 的路由策略为每次调用选择模型
+type routedAgent struct {
+	Agent
+	registry *Registry
+	config   *agentpkg.AgentConfig
+}
+
+// withModelRouting 启用了路由策略时包装 Agent
+func (r *Registry) withModelRouting(agent Agent, config *agentpkg.AgentConfig) Agent {
+	if config.RoutingPolicy == nil || !config.RoutingPolicy.Enabled {
+		return agent
+	}
+	return &routedAgent{Agent: agent, registry: r, config: config}
+}
+
+// defaultModelID Agent 原本使用的模型
+func (a *routedAgent) defaultModelID() string {
+	if a.config.ModelID != "" {
+		return a.config.ModelID
+	}
+	return a.config.PrimaryModelID
+}
+
+// route 为本次调用选择模型，返回实际执行的 Agent；路由失败时使用原 Agent 且 decision 为 nil
+func (a *routedAgent) route(ctx context.Context, input *AgentInput) (Agent, *agentpkg.RoutingDecision) {
+	policy := a.config.RoutingPolicy
+	defaultModelID := a.defaultModelID()
+
+	ids := make([]string, 0, len(policy.Candidates)+1)
+	for _, c := range policy.Candidates {
+		ids = append(ids, c.ModelID)
+	}
+	if defaultModelID != "" {
+		ids = append(ids, defaultModelID)
+	}
+	models, err := a.registry.routingModels(ctx, a.config.TenantID, ids)
+	if err != nil {
+		logger.Warn("加载路由候选模型失败，使用默认模型", zap.String("agent_id", a.config.ID), zap.Error(err))
+		return a.Agent, nil
+	}
+
+	decision := policy.Route(agentpkg.RoutingRequest{
+		PromptTokens:    estimatePromptTokens(a.config, input),
+		MaxOutputTokens: a.config.MaxTokens,
+		NeedsTools:      a.config.AutoToolUse && len(a.config.AllowedTools) > 0,
+		Budget:          a.registry.routingBudget(ctx, a.config.TenantID),
+	}, models, defaultModelID)
+	if decision.ModelID == defaultModelID {
+		return a.Agent, decision
+	}
+
+	target, err := a.registry.routedModelAgent(ctx, a.config, decision.ModelID)
+	if err != nil {
+		logger.Warn("创建路由模型 Agent 失败，使用默认模型", zap.String("agent_id", a.config.ID),
+			zap.String("model_id", decision.ModelID), zap.Error(err))
+		decision.ModelID, decision.ModelName = defaultModelID, ""
+		decision.Reason = agentpkg.RoutingReasonFallback
+		decision.EstimatedCost = decision.DefaultEstimatedCost
+		return a.Agent, decision
+	}
+	return target, decision
+}
+
+// record 记录路由决策；执行上下文取消时仍然写入
+func (a *routedAgent) record(ctx context.Context, decision *agentpkg.RoutingDecision) {
+	if a.registry.routingBilling == nil {
+		return
+	}
+	err := a.registry.routingBilling.RecordRoutingDecision(context.WithoutCancel(ctx), &billing.ModelRoutingDecision{
+		TenantID:             a.config.TenantID,
+		AgentID:              a.config.ID,
+		ModelID:              decision.ModelID,
+		ModelName:            decision.ModelName,
+		DefaultModelID:       decision.DefaultModelID,
+		Strategy:             decision.Strategy,
+		Reason:               decision.Reason,
+		PromptTokens:         decision.PromptTokens,
+		EstimatedCost:        decision.EstimatedCost,
+		DefaultEstimatedCost: decision.DefaultEstimatedCost,
+	})
+	if err != nil {
+		logger.Warn("记录模型路由决策失败", zap.String("agent_id", a.config.ID), zap.Error(err))
+	}
+}
+
+// annotateRouting 在结果元数据中记录路由决策
+func annotateRouting(metadata map[string]any, decision *agentpkg.RoutingDecision) map[string]any {
+	if metadata == nil {
+		metadata = make(map[string]any)
+	}
+	metadata["model_routing"] = decision
+	return metadata
+}
+
+// Execute 按路由结果执行 Agent
+func (a *routedAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	target, decision := a.route(ctx, input)
+	result, err := target.Execute(ctx, input)
+	if decision == nil {
+		return result, err
+	}
+
+	a.record(ctx, decision)
+	if result != nil {
+		result.Metadata = annotateRouting(result.Metadata, decision)
+	}
+	return result, err
+}
+
+// ExecuteStream 按路由结果流式执行 Agent，决策记录在最后一个 chunk 的元数据中
+func (a *routedAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	target, decision := a.route(ctx, input)
+	chunkChan, errChan := target.ExecuteStream(ctx, input)
+	if decision == nil {
+		return chunkChan, errChan
+	}
+
+	a.record(ctx, decision)
+	outChan := make(chan AgentChunk, 10)
+	outErrChan := make(chan error, 1)
+	go func() {
+		defer close(outChan)
+		defer close(outErrChan)
+
+		for chunk := range chunkChan {
+			if chunk.Done {
+				chunk.Metadata = annotateRouting(chunk.Metadata, decision)
+			}
+			if err := sendChunk(ctx, outChan, chunk); err != nil {
+				drainInBackground(chunkChan)
+				outErrChan <- err
+				return
+			}
+		}
+		if err := <-errChan; err != nil {
+			outErrChan <- err
+		}
+	}()
+	return outChan, outErrChan
+}
+
+// routedModelAgent 获取使用指定模型的 Agent 实例
+func (r *Registry) routedModelAgent(ctx context.Context, config *agentpkg.AgentConfig, modelID string) (Agent, error) {
+	cacheKey := fmt.Sprintf("%s:%s:route:%s", config.TenantID, config.ID, modelID)
+	r.mu.RLock()
+	if agent, ok := r.agents[cacheKey]; ok {
+		r.mu.RUnlock()
+		return agent, nil
+	}
+	r.mu.RUnlock()
+
+	cfgCopy := *config
+	cfgCopy.ModelID = modelID
+	agent, err := r.createAgent(ctx, &cfgCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.agents[cacheKey] = agent
+	r.mu.Unlock()
+	return agent, nil
+}
+
+// routingModels 加载候选模型的能力、价格与实时 P95 延迟；停用或不存在的模型不会出现在结果中
+func (r *Registry) routingModels(ctx context.Context, tenantID string, ids []string) (map[string]agentpkg.RoutingModel, error) {
+	var rows []modelspkg.Model
+	if err := r.db.WithContext(ctx).
+		Where("id IN ? AND tenant_id = ? AND deleted_at IS NULL", ids, tenantID).
+		Where("is_active = ? AND status = ?", true, "active").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	monitor := ai.GetGlobalMonitor()
+	models := make(map[string]agentpkg.RoutingModel, len(rows))
+	for _, m := range rows {
+		rm := agentpkg.RoutingModel{
+			ModelID:         m.ID,
+			Name:            m.Name,
+			ContextWindow:   m.ContextWindow,
+			SupportsTools:   m.SupportsFunctionCalling || m.Features.FunctionCalling,
+			InputCostPer1K:  m.InputCostPer1K,
+			OutputCostPer1K: m.OutputCostPer1K,
+			LatencySloMs:    m.LatencySloMs,
+		}
+		// 监控按提供商与模型标识统计，与回退链保持一致
+		name := m.ModelIdentifier
+		if name == "" {
+			name = m.Name
+		}
+		if summary := monitor.GetSummary(m.Provider, name); summary != nil {
+			rm.P95LatencyMs = summary.P95Latency
+		}
+		models[m.ID] = rm
+	}
+	return models, nil
+}
+
+// routingBudget 读取租户预算状态（带短时缓存），未配置计费服务或告警时返回 nil
+func (r *Registry) routingBudget(ctx context.Context, tenantID string) *agentpkg.RoutingBudget {
+	if r.routingBilling == nil {
+		return nil
+	}
+	if cached, ok := r.routingBudgets.Load(tenantID); ok {
+		if entry := cached.(routingBudgetEntry); time.Now().Before(entry.expiresAt) {
+			return entry.budget
+		}
+	}
+
+	status, err := r.routingBilling.GetBudgetStatus(ctx, tenantID)
+	if err != nil {
+		logger.Warn("读取租户预算失败，路由不考虑预算", zap.String("tenant_id", tenantID), zap.Error(err))
+		return nil
+	}
+	var budget *agentpkg.RoutingBudget
+	if status != nil {
+		budget = &agentpkg.RoutingBudget{RemainingRatio: status.RemainingRatio, Source: status.AlertName}
+	}
+	r.routingBudgets.Store(tenantID, routingBudgetEntry{budget: budget, expiresAt: time.Now().Add(routingBudgetTTL)})
+	return budget
+}
+
+// estimatePromptTokens 估算本次调用的 Prompt Token 数（系统提示词、历史与输入）
+func estimatePromptTokens(config *agentpkg.AgentConfig, input *AgentInput) int {
+	tokens := ai.EstimateTokens(config.SystemPrompt)
+	if input == nil {
+		return tokens
+	}
+	tokens += ai.EstimateTokens(input.Content)
+	for _, msg := range input.History {
+		tokens += ai.EstimateTokens(msg.Content)
+	}
+	return tokens
+}
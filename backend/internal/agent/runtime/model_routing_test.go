@@ -0,0 +1,48 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	agentpkg "backend/internal/agent"
+	modelspkg "backend/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestRoutedAgentStreamStopsWhenConsumerLeaves(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&modelspkg.Model{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	inner := &endlessStreamAgent{stopped: make(chan struct{})}
+	registry := &Registry{db: db, agents: make(map[string]Agent)}
+	agent := registry.withModelRouting(inner, &agentpkg.AgentConfig{
+		ID:            "agent-1",
+		TenantID:      "tenant-1",
+		ModelID:       "model-default",
+		RoutingPolicy: &agentpkg.ModelRoutingPolicy{Enabled: true},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, errs := agent.ExecuteStream(ctx, &AgentInput{Content: "你好"})
+	<-chunks
+	cancel()
+
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("routed stream did not finish after the consumer left")
+	}
+	select {
+	case <-inner.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("wrapped agent stream kept running after cancel")
+	}
+}
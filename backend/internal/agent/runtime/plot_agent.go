@@ -154,28 +154,9 @@ func (a *PlotAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-cha
 			return
 		}
 
-		// 调用 AI 模型流式接口
-		chunkChan, modelErrChan := a.modelClient.ChatCompletionStream(ctx, &ai.ChatCompletionRequest{
-			Messages:    messages,
-			Temperature: a.config.Temperature,
-			MaxTokens:   a.config.MaxTokens,
-		})
-
-		// 转发响应块
-		for chunk := range chunkChan {
-			outChan <- AgentChunk{
-				Content: chunk.Content,
-				Done:    chunk.Done,
-			}
-		}
-
-		// 检查错误
-		select {
-		case err := <-modelErrChan:
-			if err != nil {
-				errChan <- err
-			}
-		default:
+		// 调用 AI 模型流式接口（配置了允许的工具时支持流式工具调用）
+		if err := streamCompletion(ctx, a.toolHelper, a.modelClient, messages, a.config, input, outChan); err != nil {
+			errChan <- err
 		}
 	}()
 
@@ -180,7 +180,8 @@ func (h *RAGHelper) EnrichWithKnowledge(ctx context.Context, config *agent.Agent
 	switch opts.Mode {
 	case RAGModeMapReduce:
 		if modelClient != nil {
-			contextText, err = h.buildMapReduceContext(ctx, modelClient, validResults, opts.MapMaxChunks)
+			// 摘要是自由文本，不受本次执行的结构化输出约束
+			contextText, err = h.buildMapReduceContext(withoutResponseFormat(ctx), modelClient, validResults, opts.MapMaxChunks)
 			if err != nil {
 				fmt.Printf("RAG map-reduce 失败, 回退到 stuff 模式: %v\n", err)
 				contextText = h.buildContextText(validResults)
@@ -10,8 +10,10 @@ import (
 	agentpkg "backend/internal/agent"
 	"backend/internal/agent/prompt"
 	"backend/internal/ai"
+	"backend/internal/logger"
 	"backend/internal/metrics"
 
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -20,12 +22,15 @@ type Registry struct {
 	db                  *gorm.DB
 	clientProvider      ai.ModelProvider
 	contextManager      *ContextManager
-	ragHelper           *RAGHelper       // RAG 辅助工具
-	toolHelper          *ToolHelper      // 工具调用辅助
-	memoryService       MemoryService    // Memory 服务
-	promptEngine        *prompt.Engine   // Prompt 引擎
-	agents              map[string]Agent // 缓存：agentConfigID -> Agent
-	defaultHistoryLimit int              // 会话历史窗口大小（条数，<=0 表示全量）
+	ragHelper           *RAGHelper              // RAG 辅助工具
+	toolHelper          *ToolHelper             // 工具调用辅助
+	memoryService       MemoryService           // Memory 服务
+	promptEngine        *prompt.Engine          // Prompt 引擎
+	experiments         *agentpkg.ABTestService // A/B 实验（可选）
+	routingBilling      RoutingBilling          // 模型路由的预算与决策记录（可选）
+	routingBudgets      sync.Map                // 模型路由：tenantID -> routingBudgetEntry
+	agents              map[string]Agent        // 缓存：agentConfigID -> Agent
+	defaultHistoryLimit int                     // 会话历史窗口大小（条数，<=0 表示全量）
 	mu                  sync.RWMutex
 }
 
@@ -100,6 +105,7 @@ func (r *Registry) GetAgent(ctx context.Context, tenantID, agentID string) (Agen
 	if err != nil {
 		return nil, err
 	}
+	agent = r.withCallScope(r.withExperiments(r.withModelRouting(agent, &config), tenantID, config.ID), tenantID, config.ID)
 
 	// 缓存 Agent
 	r.mu.Lock()
@@ -137,6 +143,7 @@ func (r *Registry) GetAgentByType(ctx context.Context, tenantID, agentType strin
 	if err != nil {
 		return nil, err
 	}
+	agent = r.withCallScope(r.withExperiments(r.withModelRouting(agent, &config), tenantID, config.ID), tenantID, config.ID)
 
 	// 缓存 Agent
 	r.mu.Lock()
@@ -149,7 +156,7 @@ func (r *Registry) GetAgentByType(ctx context.Context, tenantID, agentType strin
 // createAgent 创建 Agent 实例
 func (r *Registry) createAgent(ctx context.Context, config *agentpkg.AgentConfig) (Agent, error) {
 	// 获取模型客户端
-	modelClient, err := r.clientProvider.GetClient(ctx, config.TenantID, config.ModelID)
+	modelClient, err := r.agentModelClient(ctx, config)
 	if err != nil {
 		return nil, fmt.Errorf("获取模型客户端失败: %w", err)
 	}
@@ -170,8 +177,35 @@ func (r *Registry) createAgent(ctx context.Context, config *agentpkg.AgentConfig
 		agentConfig.PromptTemplateID = &config.PromptTemplateID
 	}
 
-	// 根据类型创建对应的 Agent（所有 Agent 都支持工具调用）
-	switch config.AgentType {
+	// 结构化输出：请求由各 Agent 构建，经包装客户端注入 Schema，结果统一校验
+	modelClient = &structuredOutputClient{ModelClient: modelClient}
+	agent, err := r.buildAgent(config.AgentType, agentConfig, modelClient)
+	if err != nil {
+		return nil, err
+	}
+	return newStructuredAgent(agent, modelClient, config.OutputSchema), nil
+}
+
+// agentModelClient 获取 Agent 的模型客户端：配置了备用模型且降级策略不是 manual 时，
+// 组装 主模型 → 备用模型 的回退链，由熔断器在主模型不可用时自动切换
+func (r *Registry) agentModelClient(ctx context.Context, config *agentpkg.AgentConfig) (ai.ModelClient, error) {
+	fallbackProvider, ok := r.clientProvider.(ai.FallbackModelProvider)
+	if !ok || config.SecondaryModelID == "" || config.SecondaryModelID == config.ModelID || config.FallbackStrategy == "manual" {
+		return r.clientProvider.GetClient(ctx, config.TenantID, config.ModelID)
+	}
+	client, err := fallbackProvider.GetFallbackClient(ctx, config.TenantID, []string{config.ModelID, config.SecondaryModelID})
+	if err != nil {
+		// 备用模型不可用时只使用主模型
+		logger.Warn("组装 Agent 回退链失败，仅使用主模型", zap.String("agent_id", config.ID),
+			zap.String("secondary_model_id", config.SecondaryModelID), zap.Error(err))
+		return r.clientProvider.GetClient(ctx, config.TenantID, config.ModelID)
+	}
+	return client, nil
+}
+
+// buildAgent 根据类型创建对应的 Agent（所有 Agent 都支持工具调用）
+func (r *Registry) buildAgent(agentType string, agentConfig *AgentConfig, modelClient ai.ModelClient) (Agent, error) {
+	switch agentType {
 	case "writer":
 		return NewWriterAgent(agentConfig, modelClient, r.ragHelper, r.promptEngine, r.toolHelper), nil
 	case "reviewer":
@@ -191,7 +225,7 @@ func (r *Registry) createAgent(ctx context.Context, config *agentpkg.AgentConfig
 	case "world_builder":
 		return NewWorldBuilderAgent(agentConfig, modelClient, r.ragHelper, r.promptEngine, r.toolHelper), nil
 	default:
-		return nil, fmt.Errorf("不支持的 Agent 类型: %s", config.AgentType)
+		return nil, fmt.Errorf("不支持的 Agent 类型: %s", agentType)
 	}
 }
 
@@ -396,6 +430,12 @@ func (r *Registry) ClearCacheForAgent(tenantID, agentID string) {
 	defer r.mu.Unlock()
 
 	delete(r.agents, cacheKey)
+	// 同时清除实验变体与路由模型实例
+	for key := range r.agents {
+		if strings.HasPrefix(key, cacheKey+":") {
+			delete(r.agents, key)
+		}
+	}
 }
 // GetContextManager 获取上下文管理器
 func (r *Registry) GetContextManager() *ContextManager {
@@ -0,0 +1,68 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	agentpkg "backend/internal/agent"
+	"backend/internal/ai"
+	"backend/internal/logger"
+)
+
+// fallbackProvider 记录请求的模型客户端提供方
+type fallbackProvider struct {
+	clientModelID string
+	fallbackChain []string
+	fallbackErr   error
+}
+
+func (p *fallbackProvider) GetClient(ctx context.Context, tenantID, modelID string) (ai.ModelClient, error) {
+	p.clientModelID = modelID
+	return &fakeModelClient{}, nil
+}
+
+func (p *fallbackProvider) GetFallbackClient(ctx context.Context, tenantID string, modelIDs []string) (*ai.FallbackClient, error) {
+	p.fallbackChain = modelIDs
+	if p.fallbackErr != nil {
+		return nil, p.fallbackErr
+	}
+	targets := make([]ai.FallbackTarget, 0, len(modelIDs))
+	for _, id := range modelIDs {
+		targets = append(targets, ai.FallbackTarget{ModelID: id, Provider: id, Client: &fakeModelClient{}})
+	}
+	return ai.NewFallbackClient(tenantID, targets, nil, nil), nil
+}
+
+func TestAgentModelClientUsesSecondaryModelFallback(t *testing.T) {
+	logger.Init("error", "console", "stdout")
+	ctx := context.Background()
+	config := &agentpkg.AgentConfig{ID: "agent-1", TenantID: "tenant-1", ModelID: "m-primary", SecondaryModelID: "m-secondary", FallbackStrategy: "auto"}
+
+	provider := &fallbackProvider{}
+	registry := &Registry{clientProvider: provider}
+	client, err := registry.agentModelClient(ctx, config)
+	if err != nil {
+		t.Fatalf("agentModelClient: %v", err)
+	}
+	if _, ok := client.(*ai.FallbackClient); !ok || !reflect.DeepEqual(provider.fallbackChain, []string{"m-primary", "m-secondary"}) {
+		t.Fatalf("配置备用模型时应使用回退链: %T %v", client, provider.fallbackChain)
+	}
+
+	// manual 策略不自动切换
+	provider = &fallbackProvider{}
+	registry.clientProvider = provider
+	config.FallbackStrategy = "manual"
+	if _, err := registry.agentModelClient(ctx, config); err != nil || provider.fallbackChain != nil || provider.clientModelID != "m-primary" {
+		t.Fatalf("manual 策略应只使用主模型: err=%v chain=%v", err, provider.fallbackChain)
+	}
+
+	// 备用模型不可用时只使用主模型
+	provider = &fallbackProvider{fallbackErr: errors.New("模型不存在")}
+	registry.clientProvider = provider
+	config.FallbackStrategy = "auto"
+	if _, err := registry.agentModelClient(ctx, config); err != nil || provider.clientModelID != "m-primary" {
+		t.Fatalf("回退链组装失败时应使用主模型: err=%v model=%s", err, provider.clientModelID)
+	}
+}
@@ -0,0 +1,232 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"backend/internal/agent/parser"
+	"backend/internal/ai"
+)
+
+// 结构化输出相关参数（AgentInput.ExtraParams）
+const (
+	outputSchemaParam     = "output_schema"      // 步骤级 JSON Schema，优先于 Agent 配置
+	outputSchemaNameParam = "output_schema_name" // Schema 名称（OpenAI json_schema.name / Anthropic 工具名）
+	outputRepairsParam    = "output_max_repairs" // 校验失败后的最大修复次数
+
+	defaultOutputMaxRepairs = 2
+)
+
+type responseFormatKey struct{}
+
+// withResponseFormat 在上下文中携带本次执行要求的结构化输出格式
+func withResponseFormat(ctx context.Context, format *ai.ResponseFormat) context.Context {
+	return context.WithValue(ctx, responseFormatKey{}, format)
+}
+
+// withoutResponseFormat 清除上下文中的结构化输出要求（用于 RAG 摘要等辅助调用）
+func withoutResponseFormat(ctx context.Context) context.Context {
+	if responseFormatFromContext(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, responseFormatKey{}, (*ai.ResponseFormat)(nil))
+}
+
+func responseFormatFromContext(ctx context.Context) *ai.ResponseFormat {
+	format, _ := ctx.Value(responseFormatKey{}).(*ai.ResponseFormat)
+	return format
+}
+
+// structuredOutputClient 为请求补充上下文中的结构化输出格式
+// 各 Agent 自行构建请求，通过包装模型客户端统一注入，无需逐个修改
+type structuredOutputClient struct {
+	ai.ModelClient
+}
+
+func (c *structuredOutputClient) ChatCompletion(ctx context.Context, req *ai.ChatCompletionRequest) (*ai.ChatCompletionResponse, error) {
+	return c.ModelClient.ChatCompletion(ctx, c.apply(ctx, req))
+}
+
+func (c *structuredOutputClient) ChatCompletionStream(ctx context.Context, req *ai.ChatCompletionRequest) (<-chan ai.StreamChunk, <-chan error) {
+	return c.ModelClient.ChatCompletionStream(ctx, c.apply(ctx, req))
+}
+
+func (c *structuredOutputClient) apply(ctx context.Context, req *ai.ChatCompletionRequest) *ai.ChatCompletionRequest {
+	format := responseFormatFromContext(ctx)
+	if format == nil || req == nil || req.ResponseFormat != nil {
+		return req
+	}
+	withFormat := *req
+	withFormat.ResponseFormat = format
+	return &withFormat
+}
+
+// structuredAgent 为 Agent 增加 JSON Schema 约束：
+// 以原生结构化输出请求模型，返回后校验，不符合时携带错误信息让模型修复
+type structuredAgent struct {
+	Agent
+	client ai.ModelClient
+	schema map[string]any // Agent 级 Schema，可被步骤级覆盖
+}
+
+// newStructuredAgent 包装 Agent
+func newStructuredAgent(agent Agent, client ai.ModelClient, schema map[string]any) Agent {
+	return &structuredAgent{Agent: agent, client: client, schema: schema}
+}
+
+// responseFormat 解析本次执行的输出格式，未配置 Schema 时返回 nil
+func (a *structuredAgent) responseFormat(input *AgentInput) *ai.ResponseFormat {
+	schema := a.schema
+	name := ""
+	if input != nil && input.ExtraParams != nil {
+		if s, ok := input.ExtraParams[outputSchemaParam].(map[string]any); ok && len(s) > 0 {
+			schema = s
+		}
+		name, _ = input.ExtraParams[outputSchemaNameParam].(string)
+	}
+	if len(schema) == 0 {
+		return nil
+	}
+	return &ai.ResponseFormat{Type: ai.ResponseFormatJSONSchema, Name: name, Schema: schema}
+}
+
+func (a *structuredAgent) maxRepairs(input *AgentInput) int {
+	if input != nil && input.ExtraParams != nil {
+		if n, ok := convertToInt(input.ExtraParams[outputRepairsParam]); ok && n >= 0 {
+			return n
+		}
+	}
+	return defaultOutputMaxRepairs
+}
+
+// Execute 执行并保证输出符合 Schema
+func (a *structuredAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	format := a.responseFormat(input)
+	if format == nil {
+		return a.Agent.Execute(ctx, input)
+	}
+
+	result, err := a.Agent.Execute(withResponseFormat(ctx, format), input)
+	if err != nil || result == nil {
+		return result, err
+	}
+
+	schemaParser := parser.NewSchemaParser(format.Schema)
+	output := result.Output
+	value, parseErr := schemaParser.Parse(output)
+	repairs := 0
+	for parseErr != nil && repairs < a.maxRepairs(input) {
+		repairs++
+		output, err = a.repair(ctx, format, schemaParser, output, parseErr, result)
+		if err != nil {
+			break
+		}
+		value, parseErr = schemaParser.Parse(output)
+	}
+
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]any)
+	}
+	result.Metadata["structured_output_repairs"] = repairs
+
+	if parseErr != nil {
+		if err == nil {
+			err = parseErr
+		}
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result, fmt.Errorf("结构化输出校验失败（已修复 %d 次）: %w", repairs, err)
+	}
+
+	canonical, _ := json.Marshal(value)
+	result.Output = string(canonical)
+	result.StructuredOutput = value
+	return result, nil
+}
+
+// repair 将原输出与校验错误交给模型修正，返回修正后的输出
+func (a *structuredAgent) repair(ctx context.Context, format *ai.ResponseFormat, schemaParser *parser.SchemaParser, output string, cause error, result *AgentResult) (string, error) {
+	var problems string
+	var schemaErr *parser.SchemaError
+	if errors.As(cause, &schemaErr) {
+		problems = "- " + strings.Join(schemaErr.Violations, "\n- ")
+	} else {
+		problems = "- " + cause.Error()
+	}
+
+	req := &ai.ChatCompletionRequest{
+		Messages: []ai.Message{
+			{Role: "system", Content: "你负责修正不符合格式要求的 JSON 输出。保留原输出的内容和含义，只修正结构、字段和取值，直接输出修正后的 JSON。\n" + schemaParser.FormatInstructions()},
+			{Role: "user", Content: fmt.Sprintf("原输出：\n%s\n\n存在的问题：\n%s", output, problems)},
+		},
+		Temperature:    0,
+		ResponseFormat: format,
+	}
+	resp, err := a.client.ChatCompletion(ctx, req)
+	if err != nil {
+		return output, fmt.Errorf("结构化输出修复失败: %w", err)
+	}
+
+	if result.Usage == nil {
+		result.Usage = &Usage{}
+	}
+	result.Usage.PromptTokens += resp.Usage.PromptTokens
+	result.Usage.CompletionTokens += resp.Usage.CompletionTokens
+	result.Usage.TotalTokens += resp.Usage.TotalTokens
+	return resp.Content, nil
+}
+
+// ExecuteStream 流式执行：以原生结构化输出请求模型，结束时校验
+// 内容已实时下发，无法修复，校验失败时通过错误通道返回
+func (a *structuredAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	format := a.responseFormat(input)
+	if format == nil {
+		return a.Agent.ExecuteStream(ctx, input)
+	}
+
+	chunkChan, errChan := a.Agent.ExecuteStream(withResponseFormat(ctx, format), input)
+	outChan := make(chan AgentChunk, 10)
+	outErrChan := make(chan error, 1)
+
+	go func() {
+		defer close(outChan)
+		defer close(outErrChan)
+
+		var builder strings.Builder
+		var validateErr error
+		for chunk := range chunkChan {
+			if chunk.Event == "" && !chunk.Done {
+				builder.WriteString(chunk.Content)
+			}
+			if chunk.Done {
+				value, err := parser.NewSchemaParser(format.Schema).Parse(builder.String())
+				if err != nil {
+					validateErr = fmt.Errorf("结构化输出校验失败: %w", err)
+				} else {
+					if chunk.Metadata == nil {
+						chunk.Metadata = make(map[string]any)
+					}
+					chunk.Metadata["structured_output"] = value
+				}
+			}
+			if err := sendChunk(ctx, outChan, chunk); err != nil {
+				drainInBackground(chunkChan)
+				outErrChan <- err
+				return
+			}
+		}
+
+		if err := <-errChan; err != nil {
+			outErrChan <- err
+			return
+		}
+		if validateErr != nil {
+			outErrChan <- validateErr
+		}
+	}()
+
+	return outChan, outErrChan
+}
@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/ai"
+)
+
+// scriptedClient 按顺序返回预设内容，记录收到的请求
+type scriptedClient struct {
+	fakeModelClient
+	replies  []string
+	requests []*ai.ChatCompletionRequest
+}
+
+func (c *scriptedClient) ChatCompletion(ctx context.Context, req *ai.ChatCompletionRequest) (*ai.ChatCompletionResponse, error) {
+	c.requests = append(c.requests, req)
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return &ai.ChatCompletionResponse{Content: reply, Usage: ai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}, nil
+}
+
+// clientAgent 直接用模型客户端回答的最小 Agent
+type clientAgent struct {
+	client ai.ModelClient
+}
+
+func (a *clientAgent) Execute(ctx context.Context, input *AgentInput) (*AgentResult, error) {
+	resp, err := a.client.ChatCompletion(ctx, &ai.ChatCompletionRequest{
+		Messages: []ai.Message{{Role: "user", Content: input.Content}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AgentResult{Output: resp.Content, Status: "success", Usage: &Usage{TotalTokens: resp.Usage.TotalTokens}}, nil
+}
+
+func (a *clientAgent) ExecuteStream(ctx context.Context, input *AgentInput) (<-chan AgentChunk, <-chan error) {
+	return nil, nil
+}
+
+func (a *clientAgent) Name() string { return "test" }
+func (a *clientAgent) Type() string { return "reviewer" }
+
+var verdictSchema = map[string]any{
+	"type":     "object",
+	"required": []any{"verdict"},
+	"properties": map[string]any{
+		"verdict": map[string]any{"type": "string", "enum": []any{"pass", "revise"}},
+	},
+}
+
+func newTestStructuredAgent(replies ...string) (Agent, *scriptedClient) {
+	raw := &scriptedClient{replies: replies}
+	client := &structuredOutputClient{ModelClient: raw}
+	return newStructuredAgent(&clientAgent{client: client}, client, verdictSchema), raw
+}
+
+func TestStructuredAgentPassesSchemaAndValidates(t *testing.T) {
+	agent, raw := newTestStructuredAgent(`{"verdict":"pass"}`)
+
+	result, err := agent.Execute(context.Background(), &AgentInput{Content: "评审"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if format := raw.requests[0].ResponseFormat; format == nil || format.Type != ai.ResponseFormatJSONSchema {
+		t.Fatalf("expected native response format, got %+v", format)
+	}
+	if m, ok := result.StructuredOutput.(map[string]any); !ok || m["verdict"] != "pass" {
+		t.Fatalf("unexpected structured output %+v", result.StructuredOutput)
+	}
+	if result.Metadata["structured_output_repairs"] != 0 {
+		t.Fatalf("unexpected repairs %v", result.Metadata["structured_output_repairs"])
+	}
+}
+
+func TestStructuredAgentRepairsViolations(t *testing.T) {
+	agent, raw := newTestStructuredAgent(`我的结论是 {"verdict":"ok"}`, `{"verdict":"revise"}`)
+
+	result, err := agent.Execute(context.Background(), &AgentInput{Content: "评审"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.Output != `{"verdict":"revise"}` || result.Usage.TotalTokens != 30 {
+		t.Fatalf("unexpected result %+v usage %+v", result, result.Usage)
+	}
+	if repairPrompt := raw.requests[1].Messages[1].Content; !strings.Contains(repairPrompt, "$.verdict: 取值不在枚举范围") {
+		t.Fatalf("repair prompt missing violation: %s", repairPrompt)
+	}
+}
+
+func TestStructuredAgentFailsAfterRepairs(t *testing.T) {
+	agent, _ := newTestStructuredAgent(`{}`, `{}`)
+
+	result, err := agent.Execute(context.Background(), &AgentInput{
+		Content:     "评审",
+		ExtraParams: map[string]any{outputRepairsParam: 1},
+	})
+	if err == nil || result.Status != "failed" || !strings.Contains(err.Error(), `缺少必填字段 "verdict"`) {
+		t.Fatalf("expected validation failure, got %v (%+v)", err, result)
+	}
+}
+
+func TestStructuredAgentStepSchemaOverridesAndSkips(t *testing.T) {
+	raw := &scriptedClient{replies: []string{"自由文本"}}
+	client := &structuredOutputClient{ModelClient: raw}
+	plain := newStructuredAgent(&clientAgent{client: client}, client, nil)
+
+	result, err := plain.Execute(context.Background(), &AgentInput{Content: "写作"})
+	if err != nil || result.Output != "自由文本" || raw.requests[0].ResponseFormat != nil {
+		t.Fatalf("agent without schema should pass through: %v %+v", err, result)
+	}
+
+	raw.replies = []string{`{"title":"第一章"}`}
+	stepSchema := map[string]any{"type": "object", "required": []any{"title"}}
+	result, err = plain.Execute(context.Background(), &AgentInput{
+		Content:     "写作",
+		ExtraParams: map[string]any{outputSchemaParam: stepSchema, outputSchemaNameParam: "chapter"},
+	})
+	if err != nil || result.StructuredOutput == nil || raw.requests[1].ResponseFormat.SchemaName() != "chapter" {
+		t.Fatalf("step schema not applied: %v %+v", err, result)
+	}
+}
+
+func TestStructuredAgentStreamStopsWhenConsumerLeaves(t *testing.T) {
+	inner := &endlessStreamAgent{stopped: make(chan struct{})}
+	agent := newStructuredAgent(inner, nil, verdictSchema)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	chunks, errs := agent.ExecuteStream(ctx, &AgentInput{Content: "评审"})
+	<-chunks
+	cancel()
+
+	select {
+	case <-errs:
+	case <-time.After(2 * time.Second):
+		t.Fatal("structured stream did not finish after the consumer left")
+	}
+	select {
+	case <-inner.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("wrapped agent stream kept running after cancel")
+	}
+}
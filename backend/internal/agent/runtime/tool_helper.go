@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 
 	"go.opentelemetry.io/otel"
@@ -16,6 +17,9 @@ import (
 	"backend/internal/tools"
 )
 
+// maxToolRounds 单次执行最多的工具调用轮次
+const maxToolRounds = 5
+
 // ToolHelper 工具调用辅助类
 type ToolHelper struct {
 	registry *tools.ToolRegistry
@@ -62,20 +66,7 @@ func (h *ToolHelper) ExecuteWithTools(
 	}
 
 	// 获取工具定义
-	toolDefs := make([]ai.Tool, 0, len(availableTools))
-	for _, toolName := range availableTools {
-		if def, exists := h.registry.GetDefinition(toolName); exists && def.Status == "active" {
-			toolDefs = append(toolDefs, ai.Tool{
-				Type: "function",
-				Function: ai.FunctionDef{
-					Name:        def.Name,
-					Description: def.Description,
-					Parameters:  def.Parameters,
-				},
-			})
-		}
-	}
-
+	toolDefs := h.toolDefinitions(availableTools)
 	if len(toolDefs) == 0 {
 		return client.ChatCompletion(ctx, &ai.ChatCompletionRequest{
 			Messages:    messages,
@@ -84,13 +75,12 @@ func (h *ToolHelper) ExecuteWithTools(
 		})
 	}
 
-	maxRounds := 5
 	conversationMessages := make([]ai.Message, len(messages))
 	copy(conversationMessages, messages)
 
-	for round := 0; round < maxRounds; round++ {
+	for round := 0; round < maxToolRounds; round++ {
 		roundSpanCtx, roundSpan := h.tracer.Start(ctx, fmt.Sprintf("Round-%d", round))
-		
+
 		// 1. 调用 AI 模型
 		aiReq := &ai.ChatCompletionRequest{
 			Messages:    conversationMessages,
@@ -117,86 +107,312 @@ func (h *ToolHelper) ExecuteWithTools(
 		roundSpan.SetAttributes(attribute.Int("tool_calls_count", len(aiResp.ToolCalls)))
 
 		// 3. 并发执行工具调用
-		toolResults := make([]string, len(aiResp.ToolCalls))
-		var wg sync.WaitGroup
-		var mu sync.Mutex
-
-		for i, toolCall := range aiResp.ToolCalls {
-			wg.Add(1)
-			go func(idx int, tc ai.ToolCall) {
-				defer wg.Done()
-				
-				// Start a span for each tool execution
-				_, toolSpan := h.tracer.Start(roundSpanCtx, fmt.Sprintf("ToolExecute:%s", tc.Function.Name))
-				defer toolSpan.End()
-				
-				toolSpan.SetAttributes(attribute.String("tool_name", tc.Function.Name))
-
-				// 健壮的 JSON 解析
-				argsStr := parser.RepairJSON(tc.Function.Arguments)
-				
-				var params map[string]any
-				if err := json.Unmarshal([]byte(argsStr), &params); err != nil {
-					mu.Lock()
-					toolResults[idx] = fmt.Sprintf("参数解析失败: %s", err.Error())
-					mu.Unlock()
-					toolSpan.RecordError(err)
-					toolSpan.SetStatus(codes.Error, "JSON unmarshal failed")
-					return
-				}
-
-				execReq := &tools.ToolExecutionRequest{
-					TenantID: tenantID,
-					ToolID:   tc.Function.Name,
-					ToolName: tc.Function.Name,
-					Input:    params,
-					AgentID:  userID,
-					Timeout:  30,
-				}
-
-				execResult, err := h.executor.Execute(ctx, execReq) // propagate ctx? usually better to use spanCtx but executor might need cancellation
-				var resultStr string
-				if err != nil {
-					resultStr = fmt.Sprintf("工具执行失败: %s", err.Error())
-					toolSpan.RecordError(err)
-					toolSpan.SetStatus(codes.Error, "Tool execution failed")
-				} else {
-					resultJSON, _ := json.Marshal(execResult.Output)
-					resultStr = string(resultJSON)
-				}
-
-				mu.Lock()
-				toolResults[idx] = resultStr
-				mu.Unlock()
-			}(i, toolCall)
-		}
-
-		wg.Wait()
+		toolResults, _ := h.executeToolCalls(ctx, roundSpanCtx, aiResp.ToolCalls, tenantID, userID)
 		roundSpan.End()
 
 		// 4. 更新对话历史
-		// 4.1 添加 Assistant 的 ToolCall 消息
-		assistantMsg := ai.Message{
-			Role:      "assistant",
-			Content:   aiResp.Content, // 可能为空
-			ToolCalls: aiResp.ToolCalls,
-		}
-		conversationMessages = append(conversationMessages, assistantMsg)
-
-		// 4.2 添加 Tool 结果消息
-		for i, toolCall := range aiResp.ToolCalls {
-			conversationMessages = append(conversationMessages, ai.Message{
-				Role:       "tool",
-				Content:    toolResults[i],
-				ToolCallID: toolCall.ID,
-				Name:       toolCall.Function.Name,
+		conversationMessages = appendToolRound(conversationMessages, aiResp.Content, aiResp.ToolCalls, toolResults)
+	}
+
+	span.RecordError(fmt.Errorf("max rounds exceeded"))
+	span.SetStatus(codes.Error, "Max rounds exceeded")
+	return nil, fmt.Errorf("超过最大工具调用轮次 (%d)", maxToolRounds)
+}
+
+// ExecuteStreamWithTools 带工具调用的流式执行
+// 文本增量实时转发；模型请求工具时按 Index 合并参数增量，完整后发出 tool_call 事件，
+// 执行完成后发出 tool_result 事件并开始下一轮。最后一个 chunk 的 Metadata 包含累计用量与结束原因
+func (h *ToolHelper) ExecuteStreamWithTools(
+	ctx context.Context,
+	client ai.ModelClient,
+	messages []ai.Message,
+	temperature float64,
+	maxTokens int,
+	tenantID string,
+	userID string,
+	availableTools []string,
+	out chan<- AgentChunk,
+) error {
+	ctx, span := h.tracer.Start(ctx, "ToolHelper.ExecuteStreamWithTools")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("available_tools_count", len(availableTools)),
+		attribute.String("tenant_id", tenantID),
+		attribute.String("user_id", userID),
+	)
+
+	toolDefs := h.toolDefinitions(availableTools)
+
+	conversationMessages := make([]ai.Message, len(messages))
+	copy(conversationMessages, messages)
+
+	var total ai.Usage
+	for round := 0; round < maxToolRounds; round++ {
+		req := &ai.ChatCompletionRequest{
+			Messages:    conversationMessages,
+			Temperature: temperature,
+			MaxTokens:   maxTokens,
+		}
+		if len(toolDefs) > 0 {
+			req.Tools = toolDefs
+			req.ToolChoice = "auto"
+		}
+
+		result, err := streamRound(ctx, client, req, out)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "AI Model stream failed")
+			return err
+		}
+		total.PromptTokens += result.usage.PromptTokens
+		total.CompletionTokens += result.usage.CompletionTokens
+		total.TotalTokens += result.usage.TotalTokens
+
+		if len(result.toolCalls) == 0 {
+			return sendChunk(ctx, out, AgentChunk{
+				Done:     true,
+				Metadata: streamMetadata(result, total, round),
 			})
 		}
+
+		span.AddEvent("tool_calls", trace.WithAttributes(attribute.Int("count", len(result.toolCalls))))
+		for _, tc := range result.toolCalls {
+			if err := sendChunk(ctx, out, AgentChunk{
+				Event: ChunkEventToolCall,
+				ToolCall: &ToolCallEvent{
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			}); err != nil {
+				return err
+			}
+		}
+
+		toolResults, toolErrs := h.executeToolCalls(ctx, ctx, result.toolCalls, tenantID, userID)
+		for i, tc := range result.toolCalls {
+			event := &ToolCallEvent{ID: tc.ID, Name: tc.Function.Name, Result: toolResults[i]}
+			if toolErrs[i] != nil {
+				event.Result = ""
+				event.Error = toolErrs[i].Error()
+			}
+			if err := sendChunk(ctx, out, AgentChunk{Event: ChunkEventToolResult, ToolCall: event}); err != nil {
+				return err
+			}
+		}
+
+		conversationMessages = appendToolRound(conversationMessages, result.content, result.toolCalls, toolResults)
 	}
 
 	span.RecordError(fmt.Errorf("max rounds exceeded"))
 	span.SetStatus(codes.Error, "Max rounds exceeded")
-	return nil, fmt.Errorf("超过最大工具调用轮次 (%d)", maxRounds)
+	return fmt.Errorf("超过最大工具调用轮次 (%d)", maxToolRounds)
+}
+
+// streamResult 单轮流式调用的汇总结果
+type streamResult struct {
+	content      string
+	toolCalls    []ai.ToolCall
+	finishReason string
+	model        string
+	usage        ai.Usage
+}
+
+// streamRound 执行一轮流式调用：转发文本增量，汇总工具调用、用量与结束原因
+func streamRound(ctx context.Context, client ai.ModelClient, req *ai.ChatCompletionRequest, out chan<- AgentChunk) (*streamResult, error) {
+	chunkChan, errChan := client.ChatCompletionStream(ctx, req)
+
+	result := &streamResult{}
+	acc := ai.NewToolCallAccumulator()
+	var content strings.Builder
+
+	for chunk := range chunkChan {
+		if chunk.Model != "" {
+			result.model = chunk.Model
+		}
+		if chunk.FinishReason != "" {
+			result.finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			result.usage = *chunk.Usage
+		}
+		if len(chunk.ToolCalls) > 0 {
+			acc.Add(chunk.ToolCalls)
+		}
+		if chunk.Content != "" {
+			content.WriteString(chunk.Content)
+			if err := sendChunk(ctx, out, AgentChunk{Content: chunk.Content}); err != nil {
+				// 消费方已离开：继续排空模型输出，避免提供方协程阻塞在发送上
+				drainInBackground(chunkChan)
+				return nil, err
+			}
+		}
+	}
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+
+	result.content = content.String()
+	result.toolCalls = acc.ToolCalls()
+	return result, nil
+}
+
+// sendChunk 发送 chunk，ctx 结束时放弃发送并返回 ctx.Err()，避免消费方离开后阻塞
+func sendChunk(ctx context.Context, out chan<- AgentChunk, chunk AgentChunk) error {
+	select {
+	case out <- chunk:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainInBackground 在后台读完上游输出，消费方离开后让上游协程正常退出
+func drainInBackground[T any](ch <-chan T) {
+	go func() {
+		for range ch {
+		}
+	}()
+}
+
+// streamMetadata 构造最后一个 chunk 的元数据
+func streamMetadata(result *streamResult, usage ai.Usage, toolRounds int) map[string]any {
+	metadata := map[string]any{
+		"finish_reason": result.finishReason,
+		"model":         result.model,
+		"tool_rounds":   toolRounds,
+	}
+	if usage.TotalTokens > 0 {
+		metadata["usage"] = &Usage{
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+	}
+	return metadata
+}
+
+// streamCompletion Agent 流式执行的公共逻辑
+// 配置了允许的工具时走流式工具调用，否则直接转发模型输出
+func streamCompletion(ctx context.Context, h *ToolHelper, client ai.ModelClient, messages []ai.Message, config *AgentConfig, input *AgentInput, out chan<- AgentChunk) error {
+	var tenantID, userID string
+	if input != nil && input.Context != nil {
+		tenantID = input.Context.TenantID
+		userID = input.Context.UserID
+	}
+
+	if h != nil && len(config.AllowedTools) > 0 {
+		return h.ExecuteStreamWithTools(ctx, client, messages, config.Temperature, config.MaxTokens, tenantID, userID, config.AllowedTools, out)
+	}
+
+	result, err := streamRound(ctx, client, &ai.ChatCompletionRequest{
+		Messages:    messages,
+		Temperature: config.Temperature,
+		MaxTokens:   config.MaxTokens,
+	}, out)
+	if err != nil {
+		return err
+	}
+	return sendChunk(ctx, out, AgentChunk{
+		Done:     true,
+		Metadata: streamMetadata(result, result.usage, 0),
+	})
+}
+
+// toolDefinitions 获取可用工具的定义（仅 active 状态）
+func (h *ToolHelper) toolDefinitions(availableTools []string) []ai.Tool {
+	toolDefs := make([]ai.Tool, 0, len(availableTools))
+	for _, toolName := range availableTools {
+		if def, exists := h.registry.GetDefinition(toolName); exists && def.Status == "active" {
+			toolDefs = append(toolDefs, ai.Tool{
+				Type: "function",
+				Function: ai.FunctionDef{
+					Name:        def.Name,
+					Description: def.Description,
+					Parameters:  def.Parameters,
+				},
+			})
+		}
+	}
+	return toolDefs
+}
+
+// executeToolCalls 并发执行工具调用，返回每个调用的结果文本（失败时为错误描述）与错误
+func (h *ToolHelper) executeToolCalls(ctx, spanCtx context.Context, toolCalls []ai.ToolCall, tenantID, userID string) ([]string, []error) {
+	toolResults := make([]string, len(toolCalls))
+	toolErrs := make([]error, len(toolCalls))
+	var wg sync.WaitGroup
+
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func(idx int, tc ai.ToolCall) {
+			defer wg.Done()
+
+			// Start a span for each tool execution
+			_, toolSpan := h.tracer.Start(spanCtx, fmt.Sprintf("ToolExecute:%s", tc.Function.Name))
+			defer toolSpan.End()
+
+			toolSpan.SetAttributes(attribute.String("tool_name", tc.Function.Name))
+
+			// 健壮的 JSON 解析
+			argsStr := parser.RepairJSON(tc.Function.Arguments)
+
+			var params map[string]any
+			if err := json.Unmarshal([]byte(argsStr), &params); err != nil {
+				toolResults[idx] = fmt.Sprintf("参数解析失败: %s", err.Error())
+				toolErrs[idx] = err
+				toolSpan.RecordError(err)
+				toolSpan.SetStatus(codes.Error, "JSON unmarshal failed")
+				return
+			}
+
+			execReq := &tools.ToolExecutionRequest{
+				TenantID: tenantID,
+				ToolID:   tc.Function.Name,
+				ToolName: tc.Function.Name,
+				Input:    params,
+				AgentID:  userID,
+				Timeout:  30,
+			}
+
+			execResult, err := h.executor.Execute(ctx, execReq) // propagate ctx? usually better to use spanCtx but executor might need cancellation
+			if err != nil {
+				toolResults[idx] = fmt.Sprintf("工具执行失败: %s", err.Error())
+				toolErrs[idx] = err
+				toolSpan.RecordError(err)
+				toolSpan.SetStatus(codes.Error, "Tool execution failed")
+				return
+			}
+			resultJSON, _ := json.Marshal(execResult.Output)
+			toolResults[idx] = string(resultJSON)
+		}(i, toolCall)
+	}
+
+	wg.Wait()
+	return toolResults, toolErrs
+}
+
+// appendToolRound 将一轮工具调用追加到对话历史
+func appendToolRound(messages []ai.Message, content string, toolCalls []ai.ToolCall, toolResults []string) []ai.Message {
+	// Assistant 的 ToolCall 消息
+	messages = append(messages, ai.Message{
+		Role:      "assistant",
+		Content:   content, // 可能为空
+		ToolCalls: toolCalls,
+	})
+
+	// Tool 结果消息
+	for i, toolCall := range toolCalls {
+		messages = append(messages, ai.Message{
+			Role:       "tool",
+			Content:    toolResults[i],
+			ToolCallID: toolCall.ID,
+			Name:       toolCall.Function.Name,
+		})
+	}
+	return messages
 }
 
 // GetAvailableTools 获取指定类别的可用工具
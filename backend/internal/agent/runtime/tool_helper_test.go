@@ -0,0 +1,52 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/ai"
+)
+
+// streamingModelClient 测试用流式模型客户端，按顺序输出 chunks
+type streamingModelClient struct {
+	fakeModelClient
+	chunks []ai.StreamChunk
+}
+
+func (f *streamingModelClient) ChatCompletionStream(ctx context.Context, req *ai.ChatCompletionRequest) (<-chan ai.StreamChunk, <-chan error) {
+	ch := make(chan ai.StreamChunk)
+	errCh := make(chan error, 1)
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+		for _, chunk := range f.chunks {
+			ch <- chunk
+		}
+	}()
+	return ch, errCh
+}
+
+func TestStreamCompletionStopsWhenConsumerLeaves(t *testing.T) {
+	client := &streamingModelClient{chunks: []ai.StreamChunk{{Content: "第一段"}, {Content: "第二段"}, {Content: "第三段"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan AgentChunk)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- streamCompletion(ctx, nil, client, nil, &AgentConfig{}, nil, out)
+	}()
+	<-out
+	// 消费方读取一个 chunk 后离开
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("streamCompletion blocked after the consumer left")
+	}
+}
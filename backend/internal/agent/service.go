@@ -133,6 +133,7 @@ type CreateAgentConfigRequest struct {
 	AnalysisModelID string
 	SummaryModelID  string
 	ModelRouting    map[string]string
+	RoutingPolicy   *ModelRoutingPolicy
 	// Prompt
 	PromptTemplateID string
 	SystemPrompt     string
@@ -147,6 +148,8 @@ type CreateAgentConfigRequest struct {
 	RAGEnabled       bool
 	RAGTopK          int
 	RAGMinScore      float64
+	// 结构化输出
+	OutputSchema map[string]any
 	// 状态
 	Status string
 	// 扩展
@@ -204,6 +207,11 @@ func (s *AgentService) CreateAgentConfig(ctx context.Context, req *CreateAgentCo
 	if req.FallbackTimeoutMs < 0 {
 		req.FallbackTimeoutMs = 0
 	}
+	if req.RoutingPolicy != nil {
+		if err := req.RoutingPolicy.Validate(); err != nil {
+			return nil, err
+		}
+	}
 
 	// 设置默认状态
 	status := req.Status
@@ -239,6 +247,7 @@ func (s *AgentService) CreateAgentConfig(ctx context.Context, req *CreateAgentCo
 		AnalysisModelID: req.AnalysisModelID,
 		SummaryModelID:  req.SummaryModelID,
 		ModelRouting:    req.ModelRouting,
+		RoutingPolicy:   req.RoutingPolicy,
 		// Prompt
 		PromptTemplateID: req.PromptTemplateID,
 		SystemPrompt:     req.SystemPrompt,
@@ -253,6 +262,8 @@ func (s *AgentService) CreateAgentConfig(ctx context.Context, req *CreateAgentCo
 		RAGEnabled:      req.RAGEnabled,
 		RAGTopK:         ragTopK,
 		RAGMinScore:     ragMinScore,
+		// 结构化输出
+		OutputSchema: req.OutputSchema,
 		// 状态
 		Status: status,
 		// 扩展
@@ -292,6 +303,7 @@ type UpdateAgentConfigRequest struct {
 	AnalysisModelID *string
 	SummaryModelID  *string
 	ModelRouting    map[string]string
+	RoutingPolicy   *ModelRoutingPolicy
 	// Prompt
 	PromptTemplateID *string
 	SystemPrompt     *string
@@ -306,6 +318,8 @@ type UpdateAgentConfigRequest struct {
 	RAGEnabled       *bool
 	RAGTopK          *int
 	RAGMinScore      *float64
+	// 结构化输出
+	OutputSchema map[string]any
 	// 状态
 	Status *string
 	// 扩展
@@ -356,6 +370,12 @@ func (s *AgentService) UpdateAgentConfig(ctx context.Context, tenantID, agentID
 	if req.ModelRouting != nil {
 		updates["model_routing"] = req.ModelRouting
 	}
+	if req.RoutingPolicy != nil {
+		if err := req.RoutingPolicy.Validate(); err != nil {
+			return nil, err
+		}
+		updates["routing_policy"] = req.RoutingPolicy
+	}
 	if req.PromptTemplateID != nil {
 		updates["prompt_template_id"] = *req.PromptTemplateID
 	}
@@ -388,6 +408,10 @@ func (s *AgentService) UpdateAgentConfig(ctx context.Context, tenantID, agentID
 	if req.RAGMinScore != nil {
 		updates["rag_min_score"] = *req.RAGMinScore
 	}
+	// 结构化输出
+	if req.OutputSchema != nil {
+		updates["output_schema"] = req.OutputSchema
+	}
 	// 状态
 	if req.Status != nil {
 		updates["status"] = *req.Status
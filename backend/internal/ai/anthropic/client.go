@@ -1,12 +1,14 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"backend/pkg/aiinterface"
@@ -66,26 +68,61 @@ type anthropicRequest struct {
 	TopP        float64            `json:"top_p,omitempty"`
 	Stream      bool               `json:"stream,omitempty"`
 	System      string             `json:"system,omitempty"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+	ToolChoice  map[string]any     `json:"tool_choice,omitempty"`
 }
 
 // anthropicMessage Anthropic 消息
+// Content 为字符串或内容块数组（工具调用与工具结果需要使用内容块）
 type anthropicMessage struct {
 	Role    string `json:"role"`
-	Content string `json:"content"`
+	Content any    `json:"content"`
+}
+
+// anthropicTool Anthropic 工具定义
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"input_schema"`
 }
 
 // anthropicResponse Anthropic API 响应
 type anthropicResponse struct {
-	ID      string              `json:"id"`
-	Model   string              `json:"model"`
-	Content []anthropicContent  `json:"content"`
-	Usage   anthropicUsage      `json:"usage"`
+	ID         string             `json:"id"`
+	Model      string             `json:"model"`
+	Content    []anthropicContent `json:"content"`
+	StopReason string             `json:"stop_reason"`
+	Usage      anthropicUsage     `json:"usage"`
 }
 
-// anthropicContent Anthropic 内容
+// anthropicContent Anthropic 内容块
 type anthropicContent struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`          // tool_use
+	Name      string          `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage `json:"input,omitempty"`       // tool_use
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	Content   string          `json:"content,omitempty"`     // tool_result
+}
+
+// anthropicStreamEvent Anthropic SSE 事件
+type anthropicStreamEvent struct {
+	Type         string            `json:"type"`
+	Index        int               `json:"index"`
+	Message      anthropicResponse `json:"message"`       // message_start
+	ContentBlock anthropicContent  `json:"content_block"` // content_block_start
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"` // message_delta
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
 }
 
 // anthropicUsage Anthropic Token 使用
@@ -96,31 +133,8 @@ type anthropicUsage struct {
 
 // ChatCompletion 对话补全（非流式）
 func (c *Client) ChatCompletion(ctx context.Context, req *aiinterface.ChatCompletionRequest) (*aiinterface.ChatCompletionResponse, error) {
-	// 转换消息格式
-	messages := make([]anthropicMessage, 0, len(req.Messages))
-	var systemPrompt string
-
-	for _, msg := range req.Messages {
-		if msg.Role == "system" {
-			// Anthropic 将 system 消息单独处理
-			systemPrompt = msg.Content
-		} else {
-			messages = append(messages, anthropicMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			})
-		}
-	}
-
 	// 构建请求
-	anthropicReq := anthropicRequest{
-		Model:       c.modelID,
-		Messages:    messages,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		TopP:        req.TopP,
-		System:      systemPrompt,
-	}
+	anthropicReq := c.buildRequest(req)
 
 	// 调用 API（带重试）
 	var resp *anthropicResponse
@@ -147,16 +161,31 @@ func (c *Client) ChatCompletion(ctx context.Context, req *aiinterface.ChatComple
 		return nil, err
 	}
 
-	// 转换响应
+	// 转换响应：结构化输出工具的参数即为最终结果
+	structuredTool := structuredToolName(req)
 	var content string
-	if len(resp.Content) > 0 {
-		content = resp.Content[0].Text
+	var toolCalls []aiinterface.ToolCall
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			content += block.Text
+		case "tool_use":
+			if structuredTool != "" && block.Name == structuredTool {
+				content = string(block.Input)
+				continue
+			}
+			call := aiinterface.ToolCall{ID: block.ID, Type: "function"}
+			call.Function.Name = block.Name
+			call.Function.Arguments = string(block.Input)
+			toolCalls = append(toolCalls, call)
+		}
 	}
 
 	return &aiinterface.ChatCompletionResponse{
-		ID:      resp.ID,
-		Model:   resp.Model,
-		Content: content,
+		ID:        resp.ID,
+		Model:     resp.Model,
+		Content:   content,
+		ToolCalls: toolCalls,
 		Usage: aiinterface.Usage{
 			PromptTokens:     resp.Usage.InputTokens,
 			CompletionTokens: resp.Usage.OutputTokens,
@@ -174,34 +203,12 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *aiinterface.Chat
 		defer close(chunkChan)
 		defer close(errChan)
 
-		// 转换消息格式
-		messages := make([]anthropicMessage, 0, len(req.Messages))
-		var systemPrompt string
-
-		for _, msg := range req.Messages {
-			if msg.Role == "system" {
-				systemPrompt = msg.Content
-			} else {
-				messages = append(messages, anthropicMessage{
-					Role:    msg.Role,
-					Content: msg.Content,
-				})
-			}
-		}
-
 		// 构建请求
-		anthropicReq := anthropicRequest{
-			Model:       c.modelID,
-			Messages:    messages,
-			MaxTokens:   req.MaxTokens,
-			Temperature: req.Temperature,
-			TopP:        req.TopP,
-			Stream:      true,
-			System:      systemPrompt,
-		}
+		anthropicReq := c.buildRequest(req)
+		anthropicReq.Stream = true
 
 		// 调用流式 API
-		if err := c.doStreamRequest(ctx, anthropicReq, chunkChan); err != nil {
+		if err := c.doStreamRequest(ctx, anthropicReq, structuredToolName(req), chunkChan); err != nil {
 			errChan <- err
 			return
 		}
@@ -299,7 +306,7 @@ func (c *Client) doRequest(ctx context.Context, req anthropicRequest) (*anthropi
 }
 
 // doStreamRequest 执行流式请求
-func (c *Client) doStreamRequest(ctx context.Context, req anthropicRequest, chunkChan chan<- aiinterface.StreamChunk) error {
+func (c *Client) doStreamRequest(ctx context.Context, req anthropicRequest, structuredTool string, chunkChan chan<- aiinterface.StreamChunk) error {
 	// 序列化请求
 	body, err := json.Marshal(req)
 	if err != nil {
@@ -343,33 +350,277 @@ func (c *Client) doStreamRequest(ctx context.Context, req anthropicRequest, chun
 		return c.parseError(httpResp.StatusCode, respBody)
 	}
 
-	// 读取流式响应（简化实现，实际应解析 SSE 格式）
-	// 这里仅作示意，实际需要实现完整的 SSE 解析
-	decoder := json.NewDecoder(httpResp.Body)
-	for {
-		var chunk map[string]any
-		if err := decoder.Decode(&chunk); err != nil {
-			if err == io.EOF {
-				break
-			}
+	// 解析 SSE 事件流
+	return parseStream(httpResp.Body, structuredTool, chunkChan)
+}
+
+// parseStream 解析 Anthropic SSE 事件流
+// 文本增量直接转发；tool_use 内容块转换为工具调用增量，Index 为工具调用序号（不含文本块）
+// structuredTool 非空时，该工具的参数增量作为文本内容转发（结构化输出）
+func parseStream(body io.Reader, structuredTool string, chunkChan chan<- aiinterface.StreamChunk) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var messageID, model string
+	var usage aiinterface.Usage
+	toolIndexes := make(map[int]int) // 内容块序号 -> 工具调用序号
+	structuredBlock := -1            // 结构化输出工具所在的内容块序号
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
 			return &aiinterface.ClientError{
 				Type:    aiinterface.ErrorTypeServerError,
-				Message: "读取流失败",
+				Message: "解析流式事件失败",
 				Err:     err,
 			}
 		}
 
-		// 简化处理：提取文本内容
-		if delta, ok := chunk["delta"].(map[string]any); ok {
-			if text, ok := delta["text"].(string); ok {
+		switch event.Type {
+		case "message_start":
+			messageID = event.Message.ID
+			model = event.Message.Model
+			usage.PromptTokens = event.Message.Usage.InputTokens
+
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" && structuredTool != "" && event.ContentBlock.Name == structuredTool {
+				structuredBlock = event.Index
+			} else if event.ContentBlock.Type == "tool_use" {
+				toolIndex := len(toolIndexes)
+				toolIndexes[event.Index] = toolIndex
+				delta := aiinterface.ToolCallDelta{Index: toolIndex, ID: event.ContentBlock.ID, Type: "function"}
+				delta.Function.Name = event.ContentBlock.Name
 				chunkChan <- aiinterface.StreamChunk{
-					Content: text,
-					Done:    false,
+					ID:        messageID,
+					Model:     model,
+					ToolCalls: []aiinterface.ToolCallDelta{delta},
 				}
 			}
+
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				chunkChan <- aiinterface.StreamChunk{
+					ID:      messageID,
+					Model:   model,
+					Content: event.Delta.Text,
+				}
+			case "input_json_delta":
+				if event.Index == structuredBlock {
+					chunkChan <- aiinterface.StreamChunk{
+						ID:      messageID,
+						Model:   model,
+						Content: event.Delta.PartialJSON,
+					}
+					continue
+				}
+				toolIndex, ok := toolIndexes[event.Index]
+				if !ok || event.Delta.PartialJSON == "" {
+					continue
+				}
+				delta := aiinterface.ToolCallDelta{Index: toolIndex}
+				delta.Function.Arguments = event.Delta.PartialJSON
+				chunkChan <- aiinterface.StreamChunk{
+					ID:        messageID,
+					Model:     model,
+					ToolCalls: []aiinterface.ToolCallDelta{delta},
+				}
+			}
+
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+			finalUsage := usage
+			finishReason := convertStopReason(event.Delta.StopReason)
+			if structuredBlock >= 0 && finishReason == aiinterface.FinishReasonToolCalls {
+				// 强制调用的结构化输出工具不是真正的工具调用
+				finishReason = aiinterface.FinishReasonStop
+			}
+			chunkChan <- aiinterface.StreamChunk{
+				ID:           messageID,
+				Model:        model,
+				FinishReason: finishReason,
+				Usage:        &finalUsage,
+			}
+
+		case "message_stop":
+			return nil
+
+		case "error":
+			errType := aiinterface.ErrorTypeServerError
+			if event.Error.Type == "rate_limit_error" || event.Error.Type == "overloaded_error" {
+				errType = aiinterface.ErrorTypeRateLimit
+			}
+			return &aiinterface.ClientError{
+				Type:    errType,
+				Message: fmt.Sprintf("Anthropic 流式错误: %s", event.Error.Message),
+			}
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return &aiinterface.ClientError{
+			Type:    aiinterface.ErrorTypeNetwork,
+			Message: "读取流失败",
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// convertStopReason 将 Anthropic stop_reason 转换为统一的结束原因
+func convertStopReason(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return aiinterface.FinishReasonStop
+	case "max_tokens":
+		return aiinterface.FinishReasonLength
+	case "tool_use":
+		return aiinterface.FinishReasonToolCalls
+	case "refusal":
+		return aiinterface.FinishReasonContentFilter
+	default:
+		return reason
+	}
+}
+
+// buildRequest 将统一请求转换为 Anthropic 请求
+// system 消息单独处理；assistant 的工具调用转为 tool_use 块；连续的 tool 消息合并为一条 user 消息中的 tool_result 块
+func (c *Client) buildRequest(req *aiinterface.ChatCompletionRequest) anthropicRequest {
+	messages := make([]anthropicMessage, 0, len(req.Messages))
+	var systemPrompt string
+
+	for _, msg := range req.Messages {
+		switch {
+		case msg.Role == "system":
+			// Anthropic 将 system 消息单独处理
+			systemPrompt = msg.Content
+
+		case msg.Role == "tool":
+			block := anthropicContent{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content}
+			if n := len(messages); n > 0 && messages[n-1].Role == "user" {
+				if blocks, ok := messages[n-1].Content.([]anthropicContent); ok && len(blocks) > 0 && blocks[0].Type == "tool_result" {
+					messages[n-1].Content = append(blocks, block)
+					continue
+				}
+			}
+			messages = append(messages, anthropicMessage{Role: "user", Content: []anthropicContent{block}})
+
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			blocks := make([]anthropicContent, 0, len(msg.ToolCalls)+1)
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicContent{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicContent{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			messages = append(messages, anthropicMessage{Role: "assistant", Content: blocks})
+
+		default:
+			messages = append(messages, anthropicMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			})
+		}
+	}
+
+	anthropicReq := anthropicRequest{
+		Model:       c.modelID,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		System:      systemPrompt,
+	}
+
+	for _, tool := range req.Tools {
+		schema := tool.Function.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		anthropicReq.Tools = append(anthropicReq.Tools, anthropicTool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if len(anthropicReq.Tools) > 0 {
+		anthropicReq.ToolChoice = convertToolChoice(req.ToolChoice)
+	}
+
+	// 结构化输出：没有其他工具时强制调用以 Schema 为参数的工具；
+	// 存在其他工具时无法强制，改为在系统提示词中说明输出格式
+	if format := req.ResponseFormat; format != nil {
+		if name := structuredToolName(req); name != "" {
+			anthropicReq.Tools = []anthropicTool{{
+				Name:        name,
+				Description: "以结构化格式返回最终结果",
+				InputSchema: structuredSchema(format),
+			}}
+			anthropicReq.ToolChoice = map[string]any{"type": "tool", "name": name}
+		} else {
+			schema, _ := json.Marshal(structuredSchema(format))
+			instruction := fmt.Sprintf("最终回答必须是符合以下 JSON Schema 的 JSON，不要包含其他文字：\n%s", schema)
+			if anthropicReq.System != "" {
+				anthropicReq.System += "\n\n"
+			}
+			anthropicReq.System += instruction
+		}
+	}
+
+	return anthropicReq
+}
+
+// structuredToolName 返回用于结构化输出的强制工具名；请求带有其他工具时返回空
+func structuredToolName(req *aiinterface.ChatCompletionRequest) string {
+	if req.ResponseFormat == nil || len(req.Tools) > 0 {
+		return ""
+	}
+	return req.ResponseFormat.SchemaName()
+}
+
+// structuredSchema 结构化输出工具的参数 Schema（Anthropic 要求顶层为 object）
+func structuredSchema(format *aiinterface.ResponseFormat) map[string]any {
+	if format.Type == aiinterface.ResponseFormatJSONSchema && len(format.Schema) > 0 {
+		return format.Schema
+	}
+	return map[string]any{"type": "object"}
+}
+
+// convertToolChoice 将 OpenAI 风格的 tool_choice 转换为 Anthropic 格式
+func convertToolChoice(choice any) map[string]any {
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "none":
+			return map[string]any{"type": "none"}
+		case "required":
+			return map[string]any{"type": "any"}
+		case "auto", "":
+			return map[string]any{"type": "auto"}
+		default:
+			return map[string]any{"type": "tool", "name": v}
+		}
+	case map[string]any:
+		if fn, ok := v["function"].(map[string]any); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				return map[string]any{"type": "tool", "name": name}
+			}
+		}
+	}
 	return nil
 }
 
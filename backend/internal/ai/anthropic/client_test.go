@@ -0,0 +1,132 @@
+package anthropic
+
+import (
+	"strings"
+	"testing"
+
+	"backend/pkg/aiinterface"
+)
+
+func TestParseStreamToolUse(t *testing.T) {
+	events := []string{
+		`event: message_start`,
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude","usage":{"input_tokens":12}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"查一下"}}`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search"}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"天气\"}"}}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":8}}`,
+		`data: {"type":"message_stop"}`,
+	}
+
+	chunkChan := make(chan aiinterface.StreamChunk, 20)
+	if err := parseStream(strings.NewReader(strings.Join(events, "\n\n")), "", chunkChan); err != nil {
+		t.Fatalf("parseStream: %v", err)
+	}
+	close(chunkChan)
+
+	var content string
+	var finishReason string
+	var usage *aiinterface.Usage
+	acc := aiinterface.NewToolCallAccumulator()
+	for chunk := range chunkChan {
+		content += chunk.Content
+		acc.Add(chunk.ToolCalls)
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if content != "查一下" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if finishReason != aiinterface.FinishReasonToolCalls {
+		t.Fatalf("unexpected finish reason %q", finishReason)
+	}
+	if usage == nil || usage.PromptTokens != 12 || usage.CompletionTokens != 8 || usage.TotalTokens != 20 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	calls := acc.ToolCalls()
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 tool call, got %d", len(calls))
+	}
+	if calls[0].ID != "toolu_1" || calls[0].Function.Name != "search" || calls[0].Function.Arguments != `{"query":"天气"}` {
+		t.Fatalf("unexpected tool call %+v", calls[0])
+	}
+}
+
+func TestBuildRequestToolMessages(t *testing.T) {
+	c := &Client{modelID: "claude"}
+	call := aiinterface.ToolCall{ID: "toolu_1", Type: "function"}
+	call.Function.Name = "search"
+	call.Function.Arguments = `{"query":"天气"}`
+
+	req := c.buildRequest(&aiinterface.ChatCompletionRequest{
+		Messages: []aiinterface.Message{
+			{Role: "system", Content: "你是助手"},
+			{Role: "user", Content: "今天天气如何"},
+			{Role: "assistant", ToolCalls: []aiinterface.ToolCall{call}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "晴"},
+		},
+		Tools:      []aiinterface.Tool{{Type: "function", Function: aiinterface.FunctionDef{Name: "search"}}},
+		ToolChoice: "required",
+	})
+
+	if req.System != "你是助手" || len(req.Messages) != 3 {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	blocks, ok := req.Messages[2].Content.([]anthropicContent)
+	if !ok || req.Messages[2].Role != "user" || blocks[0].Type != "tool_result" || blocks[0].ToolUseID != "toolu_1" {
+		t.Fatalf("unexpected tool result message %+v", req.Messages[2])
+	}
+	if req.ToolChoice["type"] != "any" || req.Tools[0].InputSchema["type"] != "object" {
+		t.Fatalf("unexpected tools %+v / %+v", req.Tools, req.ToolChoice)
+	}
+}
+
+func TestStructuredOutputToolForcing(t *testing.T) {
+	c := &Client{modelID: "claude"}
+	schema := map[string]any{"type": "object", "properties": map[string]any{"score": map[string]any{"type": "number"}}}
+	req := c.buildRequest(&aiinterface.ChatCompletionRequest{
+		Messages:       []aiinterface.Message{{Role: "user", Content: "评分"}},
+		ResponseFormat: &aiinterface.ResponseFormat{Type: aiinterface.ResponseFormatJSONSchema, Name: "review", Schema: schema},
+	})
+	if len(req.Tools) != 1 || req.Tools[0].Name != "review" {
+		t.Fatalf("expected forced tool, got %+v", req.Tools)
+	}
+	if choice := req.ToolChoice; choice["type"] != "tool" || choice["name"] != "review" {
+		t.Fatalf("unexpected tool choice %+v", req.ToolChoice)
+	}
+
+	events := []string{
+		`data: {"type":"message_start","message":{"id":"msg_1","model":"claude","usage":{"input_tokens":5}}}`,
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"review"}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"score\":"}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"8}"}}`,
+		`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":3}}`,
+	}
+	chunkChan := make(chan aiinterface.StreamChunk, 10)
+	if err := parseStream(strings.NewReader(strings.Join(events, "\n\n")), "review", chunkChan); err != nil {
+		t.Fatalf("parseStream: %v", err)
+	}
+	close(chunkChan)
+
+	var content, finishReason string
+	for chunk := range chunkChan {
+		content += chunk.Content
+		if len(chunk.ToolCalls) > 0 {
+			t.Fatalf("structured output must not surface as tool call: %+v", chunk.ToolCalls)
+		}
+		if chunk.FinishReason != "" {
+			finishReason = chunk.FinishReason
+		}
+	}
+	if content != `{"score":8}` || finishReason != aiinterface.FinishReasonStop {
+		t.Fatalf("unexpected content %q finish %q", content, finishReason)
+	}
+}
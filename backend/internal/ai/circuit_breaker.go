@@ -0,0 +1,172 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState 熔断器状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // 正常放行
+	CircuitOpen     CircuitState = "open"      // 熔断中，直接跳过
+	CircuitHalfOpen CircuitState = "half_open" // 冷却结束，放行探测请求
+)
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 连续失败多少次后熔断
+	OpenTimeout      time.Duration // 熔断持续时间，结束后进入半开状态
+	HalfOpenMaxCalls int           // 半开状态允许的探测请求数
+}
+
+// DefaultCircuitBreakerConfig 默认熔断器配置
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenMaxCalls: 1,
+	}
+}
+
+// CircuitBreaker 单个提供商的熔断器
+// 只统计可重试错误（限流、服务端错误、网络错误），参数错误等不影响熔断状态
+type CircuitBreaker struct {
+	config CircuitBreakerConfig
+	now    func() time.Time
+
+	mu               sync.Mutex
+	state            CircuitState
+	consecutiveFails int
+	openedAt         time.Time
+	halfOpenCalls    int
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaults.OpenTimeout
+	}
+	if config.HalfOpenMaxCalls <= 0 {
+		config.HalfOpenMaxCalls = defaults.HalfOpenMaxCalls
+	}
+	return &CircuitBreaker{
+		config: config,
+		now:    time.Now,
+		state:  CircuitClosed,
+	}
+}
+
+// Allow 判断是否允许请求通过
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.config.OpenTimeout {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.halfOpenCalls = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if b.halfOpenCalls >= b.config.HalfOpenMaxCalls {
+			return false
+		}
+		b.halfOpenCalls++
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess 记录成功调用
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = CircuitClosed
+	b.consecutiveFails = 0
+	b.halfOpenCalls = 0
+}
+
+// RecordIgnored 记录不计入熔断的结果（如参数、认证类错误）：不改变状态与失败计数，
+// 仅归还半开状态占用的探测名额，让后续请求继续探测
+func (b *CircuitBreaker) RecordIgnored() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen && b.halfOpenCalls > 0 {
+		b.halfOpenCalls--
+	}
+}
+
+// RecordFailure 记录可重试错误
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFails++
+	if b.state == CircuitHalfOpen || b.consecutiveFails >= b.config.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+		b.halfOpenCalls = 0
+	}
+}
+
+// State 当前状态
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// CircuitBreakerRegistry 按提供商维护熔断器
+type CircuitBreakerRegistry struct {
+	config   CircuitBreakerConfig
+	mu       sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakerRegistry 创建熔断器注册表
+func NewCircuitBreakerRegistry(config CircuitBreakerConfig) *CircuitBreakerRegistry {
+	return &CircuitBreakerRegistry{
+		config:   config,
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get 获取（或创建）指定提供商的熔断器
+func (r *CircuitBreakerRegistry) Get(provider string) *CircuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if b, ok := r.breakers[provider]; ok {
+		return b
+	}
+	b := NewCircuitBreaker(r.config)
+	r.breakers[provider] = b
+	return b
+}
+
+// States 返回所有提供商的熔断状态
+func (r *CircuitBreakerRegistry) States() map[string]CircuitState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	states := make(map[string]CircuitState, len(r.breakers))
+	for provider, b := range r.breakers {
+		states[provider] = b.State()
+	}
+	return states
+}
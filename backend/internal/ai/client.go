@@ -19,11 +19,14 @@ type (
 	Tool                   = aiinterface.Tool
 	FunctionDef            = aiinterface.FunctionDef
 	ToolCall               = aiinterface.ToolCall
+	ToolCallDelta          = aiinterface.ToolCallDelta
+	ToolCallAccumulator    = aiinterface.ToolCallAccumulator
 	ModelClient            = aiinterface.ModelClient
 	StreamReader           = aiinterface.StreamReader
 	ClientConfig           = aiinterface.ClientConfig
 	ClientError            = aiinterface.ClientError
 	ErrorType              = aiinterface.ErrorType
+	ResponseFormat         = aiinterface.ResponseFormat
 )
 
 // ModelProvider 模型提供方接口,用于通过租户和模型ID获取模型客户端
@@ -32,6 +35,11 @@ type ModelProvider interface {
 	GetClient(ctx context.Context, tenantID, modelID string) (ModelClient, error)
 }
 
+// FallbackModelProvider 可按给定顺序组装回退链客户端的模型提供方（ClientFactory 实现了该接口）
+type FallbackModelProvider interface {
+	GetFallbackClient(ctx context.Context, tenantID string, modelIDs []string) (*FallbackClient, error)
+}
+
 // 重新导出常量
 const (
 	ErrorTypeAuth          = aiinterface.ErrorTypeAuth
@@ -40,8 +48,21 @@ const (
 	ErrorTypeServerError   = aiinterface.ErrorTypeServerError
 	ErrorTypeNetwork       = aiinterface.ErrorTypeNetwork
 	ErrorTypeUnknown       = aiinterface.ErrorTypeUnknown
+
+	FinishReasonStop          = aiinterface.FinishReasonStop
+	FinishReasonLength        = aiinterface.FinishReasonLength
+	FinishReasonToolCalls     = aiinterface.FinishReasonToolCalls
+	FinishReasonContentFilter = aiinterface.FinishReasonContentFilter
+
+	ResponseFormatJSONObject = aiinterface.ResponseFormatJSONObject
+	ResponseFormatJSONSchema = aiinterface.ResponseFormatJSONSchema
 )
 
+// NewToolCallAccumulator 创建流式工具调用累加器
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return aiinterface.NewToolCallAccumulator()
+}
+
 // ModelCallLogger 模型调用日志记录器接口
 type ModelCallLogger interface {
 	// Log 记录模型调用
@@ -62,6 +83,10 @@ type ModelCallLog struct {
 	LatencyMs        int64   `json:"latency_ms"`
 	WorkflowID       *string `json:"workflow_id,omitempty"`
 	TraceID          *string `json:"trace_id,omitempty"`
+
+	// 回退链信息：实际应答的模型为 ModelID，RequestedModelID 为首选模型
+	RequestedModelID *string `json:"requested_model_id,omitempty"`
+	FallbackAttempt  int     `json:"fallback_attempt,omitempty"`
 }
 
 // 注意: 所有客户端配置、错误类型等定义已迁移到pkg/aiinterface
@@ -2,9 +2,8 @@ package converters
 
 import (
 	"backend/pkg/aiinterface"
+	"encoding/json"
 	"fmt"
-
-	
 )
 
 // OpenAIToGeminiConverter OpenAI -> Gemini 转换器
@@ -24,7 +23,41 @@ func (c *OpenAIToGeminiConverter) TargetFormat() Format {
 
 // GeminiPart Gemini 消息部分
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiFunctionCall Gemini 函数调用
+type GeminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse Gemini 函数调用结果
+type GeminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// GeminiTool Gemini 工具定义
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration Gemini 函数声明
+type GeminiFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// GeminiToolConfig Gemini 工具调用配置
+type GeminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"` // AUTO / ANY / NONE
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
 }
 
 // GeminiContent Gemini 内容
@@ -35,15 +68,19 @@ type GeminiContent struct {
 
 // GeminiGenerationConfig Gemini 生成配置
 type GeminiGenerationConfig struct {
-	Temperature     float64 `json:"temperature,omitempty"`
-	TopP            float64 `json:"topP,omitempty"`
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+	Temperature      float64        `json:"temperature,omitempty"`
+	TopP             float64        `json:"topP,omitempty"`
+	MaxOutputTokens  int            `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string         `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]any `json:"responseSchema,omitempty"`
 }
 
 // GeminiRequest Gemini 请求格式
 type GeminiRequest struct {
 	Contents         []GeminiContent         `json:"contents"`
 	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	Tools            []GeminiTool            `json:"tools,omitempty"`
+	ToolConfig       *GeminiToolConfig       `json:"toolConfig,omitempty"`
 }
 
 func (c *OpenAIToGeminiConverter) ConvertRequest(req *aiinterface.ChatCompletionRequest) (any, error) {
@@ -56,20 +93,165 @@ func (c *OpenAIToGeminiConverter) ConvertRequest(req *aiinterface.ChatCompletion
 	}
 
 	// 转换消息
+	// Gemini 的函数结果按函数名关联，需要记录工具调用 ID 对应的函数名
+	callNames := make(map[string]string)
 	for _, msg := range req.Messages {
-		role := c.convertRole(msg.Role)
-		content := GeminiContent{
-			Role: role,
-			Parts: []GeminiPart{
-				{Text: msg.Content},
-			},
+		switch {
+		case msg.Role == "tool":
+			name := msg.Name
+			if name == "" {
+				name = callNames[msg.ToolCallID]
+			}
+			part := GeminiPart{FunctionResponse: &GeminiFunctionResponse{
+				Name:     name,
+				Response: toolResultPayload(msg.Content),
+			}}
+			// 同一轮的多个函数结果合并到一条消息中
+			if n := len(geminiReq.Contents); n > 0 && geminiReq.Contents[n-1].Role == "function" {
+				geminiReq.Contents[n-1].Parts = append(geminiReq.Contents[n-1].Parts, part)
+				continue
+			}
+			geminiReq.Contents = append(geminiReq.Contents, GeminiContent{Role: "function", Parts: []GeminiPart{part}})
+
+		case msg.Role == "assistant" && len(msg.ToolCalls) > 0:
+			content := GeminiContent{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, GeminiPart{Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				callNames[tc.ID] = tc.Function.Name
+				var args map[string]any
+				_ = json.Unmarshal([]byte(tc.Function.Arguments), &args)
+				content.Parts = append(content.Parts, GeminiPart{FunctionCall: &GeminiFunctionCall{
+					Name: tc.Function.Name,
+					Args: args,
+				}})
+			}
+			geminiReq.Contents = append(geminiReq.Contents, content)
+
+		default:
+			role := c.convertRole(msg.Role)
+			content := GeminiContent{
+				Role: role,
+				Parts: []GeminiPart{
+					{Text: msg.Content},
+				},
+			}
+			geminiReq.Contents = append(geminiReq.Contents, content)
+		}
+	}
+
+	// 转换工具定义
+	if len(req.Tools) > 0 {
+		tool := GeminiTool{}
+		for _, t := range req.Tools {
+			tool.FunctionDeclarations = append(tool.FunctionDeclarations, GeminiFunctionDeclaration{
+				Name:        t.Function.Name,
+				Description: t.Function.Description,
+				Parameters:  t.Function.Parameters,
+			})
+		}
+		geminiReq.Tools = []GeminiTool{tool}
+		geminiReq.ToolConfig = c.convertToolChoice(req.ToolChoice)
+	}
+
+	// 结构化输出：Gemini 不支持函数调用与 JSON 输出同时使用，存在工具时忽略
+	if format := req.ResponseFormat; format != nil && len(req.Tools) == 0 {
+		geminiReq.GenerationConfig.ResponseMimeType = "application/json"
+		if format.Type == aiinterface.ResponseFormatJSONSchema && len(format.Schema) > 0 {
+			geminiReq.GenerationConfig.ResponseSchema = geminiSchema(format.Schema)
 		}
-		geminiReq.Contents = append(geminiReq.Contents, content)
 	}
 
 	return geminiReq, nil
 }
 
+// geminiUnsupportedSchemaKeys responseSchema 只支持 OpenAPI Schema 子集，这些关键字会导致请求被拒绝
+var geminiUnsupportedSchemaKeys = map[string]bool{
+	"$schema":              true,
+	"$id":                  true,
+	"$ref":                 true,
+	"$defs":                true,
+	"definitions":          true,
+	"additionalProperties": true,
+	"title":                true,
+	"default":              true,
+	"examples":             true,
+	"const":                true,
+}
+
+// geminiSchema 递归移除 Gemini 不支持的 JSON Schema 关键字
+func geminiSchema(schema map[string]any) map[string]any {
+	out := make(map[string]any, len(schema))
+	for key, value := range schema {
+		if geminiUnsupportedSchemaKeys[key] {
+			continue
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			if key == "properties" {
+				props := make(map[string]any, len(v))
+				for name, prop := range v {
+					if m, ok := prop.(map[string]any); ok {
+						props[name] = geminiSchema(m)
+					} else {
+						props[name] = prop
+					}
+				}
+				out[key] = props
+			} else {
+				out[key] = geminiSchema(v)
+			}
+		case []any:
+			items := make([]any, len(v))
+			for i, item := range v {
+				if m, ok := item.(map[string]any); ok {
+					items[i] = geminiSchema(m)
+				} else {
+					items[i] = item
+				}
+			}
+			out[key] = items
+		default:
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// convertToolChoice 将 OpenAI 风格的 tool_choice 转换为 Gemini 的 functionCallingConfig
+func (c *OpenAIToGeminiConverter) convertToolChoice(choice any) *GeminiToolConfig {
+	config := &GeminiToolConfig{}
+	config.FunctionCallingConfig.Mode = "AUTO"
+
+	switch v := choice.(type) {
+	case string:
+		switch v {
+		case "none":
+			config.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			config.FunctionCallingConfig.Mode = "ANY"
+		}
+	case map[string]any:
+		if fn, ok := v["function"].(map[string]any); ok {
+			if name, ok := fn["name"].(string); ok && name != "" {
+				config.FunctionCallingConfig.Mode = "ANY"
+				config.FunctionCallingConfig.AllowedFunctionNames = []string{name}
+			}
+		}
+	}
+	return config
+}
+
+// toolResultPayload 将工具结果转换为 functionResponse.response（要求为 JSON 对象）
+func toolResultPayload(content string) map[string]any {
+	var payload map[string]any
+	if err := json.Unmarshal([]byte(content), &payload); err == nil {
+		return payload
+	}
+	return map[string]any{"result": content}
+}
+
 func (c *OpenAIToGeminiConverter) convertRole(openaiRole string) string {
 	switch openaiRole {
 	case "system":
@@ -45,8 +45,10 @@ func (l *DBLogger) Log(ctx context.Context, log *ModelCallLog) error {
 		Cost:           log.TotalCost,
 		Status:         "success",
 		Metadata: map[string]interface{}{
-			"workflow_id": log.WorkflowID,
-			"trace_id":    log.TraceID,
+			"workflow_id":        log.WorkflowID,
+			"trace_id":           log.TraceID,
+			"requested_model_id": log.RequestedModelID,
+			"fallback_attempt":   log.FallbackAttempt,
 		},
 		CreatedAt: time.Now().UTC(),
 	}
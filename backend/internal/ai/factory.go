@@ -30,6 +30,9 @@ type ClientFactory struct {
 	diskCache *cache.DiskCache       // L3硬盘缓存
 	mu        sync.RWMutex
 	logger    ModelCallLogger
+	breakers  *CircuitBreakerRegistry // 回退链使用的提供商熔断器
+	monitor   *PerformanceMonitor
+	meter     CallMeter // 积分计量（可选）
 }
 
 // NewClientFactory 创建客户端工厂
@@ -39,11 +42,20 @@ func NewClientFactory(db *gorm.DB, logger ModelCallLogger, diskCache *cache.Disk
 		clients:   make(map[string]ModelClient),
 		diskCache: diskCache,
 		logger:    logger,
+		breakers:  NewCircuitBreakerRegistry(DefaultCircuitBreakerConfig()),
+		monitor:   GetGlobalMonitor(),
 	}
 }
 
+// SetMeter 设置积分计量器，之后创建的客户端在每次调用前预授权、调用后结算
+func (f *ClientFactory) SetMeter(meter CallMeter) {
+	f.meter = meter
+	f.ClearCache()
+}
+
 // GetClient 获取模型客户端
 // 从数据库加载模型配置并创建对应的客户端
+// 模型配置了回退链（capabilities.fallback_models）时返回 FallbackClient
 func (f *ClientFactory) GetClient(ctx context.Context, tenantID, modelID string) (ModelClient, error) {
 	// 检查缓存
 	cacheKey := fmt.Sprintf("%s:%s", tenantID, modelID)
@@ -54,15 +66,70 @@ func (f *ClientFactory) GetClient(ctx context.Context, tenantID, modelID string)
 	}
 	f.mu.RUnlock()
 
+	client, model, err := f.buildClient(ctx, tenantID, modelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chain := fallbackModelIDs(model); len(chain) > 0 {
+		targets := []FallbackTarget{newFallbackTarget(modelID, model, client)}
+		for _, fallbackID := range chain {
+			if fallbackID == modelID {
+				continue
+			}
+			fallbackClient, fallbackModel, err := f.buildClient(ctx, tenantID, fallbackID)
+			if err != nil {
+				// 回退模型不可用时跳过，不影响首选模型
+				continue
+			}
+			targets = append(targets, newFallbackTarget(fallbackID, fallbackModel, fallbackClient))
+		}
+		if len(targets) > 1 {
+			client = NewFallbackClient(tenantID, targets, f.breakers, f.monitor)
+		}
+	}
+
+	// 缓存客户端
+	f.mu.Lock()
+	f.clients[cacheKey] = client
+	f.mu.Unlock()
+
+	return client, nil
+}
+
+// GetFallbackClient 按给定顺序组装回退链客户端（如 deepseek → qwen → ollama）
+// 与模型自身的回退配置无关，每个候选只使用其单一客户端
+func (f *ClientFactory) GetFallbackClient(ctx context.Context, tenantID string, modelIDs []string) (*FallbackClient, error) {
+	if len(modelIDs) == 0 {
+		return nil, fmt.Errorf("回退链不能为空")
+	}
+	targets := make([]FallbackTarget, 0, len(modelIDs))
+	for _, modelID := range modelIDs {
+		client, model, err := f.buildClient(ctx, tenantID, modelID)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, newFallbackTarget(modelID, model, client))
+	}
+	return NewFallbackClient(tenantID, targets, f.breakers, f.monitor), nil
+}
+
+// CircuitStates 返回各 租户:提供商 的熔断状态
+func (f *ClientFactory) CircuitStates() map[string]CircuitState {
+	return f.breakers.States()
+}
+
+// buildClient 创建单个模型的客户端（不使用缓存，不组装回退链）
+func (f *ClientFactory) buildClient(ctx context.Context, tenantID, modelID string) (ModelClient, *modelspkg.Model, error) {
 	// 从数据库加载模型配置
 	var model modelspkg.Model
 	if err := f.db.WithContext(ctx).
 		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", modelID, tenantID).
 		First(&model).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return nil, fmt.Errorf("模型不存在: %s", modelID)
+			return nil, nil, fmt.Errorf("模型不存在: %s", modelID)
 		}
-		return nil, fmt.Errorf("查询模型失败: %w", err)
+		return nil, nil, fmt.Errorf("查询模型失败: %w", err)
 	}
 
 	// 创建客户端配置
@@ -127,7 +194,7 @@ func (f *ClientFactory) GetClient(ctx context.Context, tenantID, modelID string)
 	// 创建客户端
 	client, err := f.createClient(config, model.APIFormat)
 	if err != nil {
-		return nil, fmt.Errorf("创建客户端失败: %w", err)
+		return nil, nil, fmt.Errorf("创建客户端失败: %w", err)
 	}
 
 	// 如果启用了日志记录，包装客户端
@@ -135,12 +202,59 @@ func (f *ClientFactory) GetClient(ctx context.Context, tenantID, modelID string)
 		client = NewLoggingClient(client, f.logger, tenantID, modelID, &model, f.diskCache)
 	}
 
-	// 缓存客户端
-	f.mu.Lock()
-	f.clients[cacheKey] = client
-	f.mu.Unlock()
+	// 积分计量放在最外层：命中硬盘缓存的调用同样计费，回退链中每个候选按各自定价结算
+	if f.meter != nil {
+		name := model.ModelIdentifier
+		if name == "" {
+			name = model.Name
+		}
+		client = NewMeteringClient(client, f.meter, tenantID, modelID, model.Provider, name)
+	}
 
-	return client, nil
+	return client, &model, nil
+}
+
+// fallbackModelIDs 读取模型配置的回退链
+// capabilities.fallback_models: ["qwen-model-id", "ollama-model-id"]
+func fallbackModelIDs(model *modelspkg.Model) []string {
+	raw, ok := model.Capabilities["fallback_models"]
+	if !ok {
+		return nil
+	}
+	var ids []string
+	switch v := raw.(type) {
+	case []string:
+		ids = v
+	case []any:
+		for _, item := range v {
+			if id, ok := item.(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	case string:
+		ids = strings.Split(v, ",")
+	}
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// newFallbackTarget 构造回退链候选
+func newFallbackTarget(modelID string, model *modelspkg.Model, client ModelClient) FallbackTarget {
+	name := model.ModelIdentifier
+	if name == "" {
+		name = model.Name
+	}
+	return FallbackTarget{
+		ModelID:   modelID,
+		Provider:  model.Provider,
+		ModelName: name,
+		Client:    client,
+	}
 }
 
 // createClient 创建客户端
@@ -219,6 +333,21 @@ func (f *ClientFactory) ClearCacheForModel(tenantID, modelID string) {
 		_ = client.Close()
 		delete(f.clients, cacheKey)
 	}
+
+	// 回退链中包含该模型时一并失效
+	for key, client := range f.clients {
+		fallback, ok := client.(*FallbackClient)
+		if !ok || !strings.HasPrefix(key, tenantID+":") {
+			continue
+		}
+		for _, target := range fallback.targets {
+			if target.ModelID == modelID {
+				_ = fallback.Close()
+				delete(f.clients, key)
+				break
+			}
+		}
+	}
 }
 
 // resolveCredentials 解析凭证
@@ -0,0 +1,260 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FallbackTarget 回退链中的一个模型
+type FallbackTarget struct {
+	ModelID   string
+	Provider  string
+	ModelName string
+	Client    ModelClient
+}
+
+// FallbackClient 带回退链与熔断的路由客户端
+// 按顺序尝试各模型（如 deepseek → qwen → ollama），遇到可重试错误时切换到下一个，
+// 并为对应提供商的熔断器记一次失败；熔断中的提供商会被直接跳过
+type FallbackClient struct {
+	tenantID string
+	targets  []FallbackTarget
+	breakers *CircuitBreakerRegistry
+	monitor  *PerformanceMonitor
+}
+
+// NewFallbackClient 创建回退路由客户端
+// 熔断器按 租户+提供商 区分，避免一个租户的限流影响其他租户
+func NewFallbackClient(tenantID string, targets []FallbackTarget, breakers *CircuitBreakerRegistry, monitor *PerformanceMonitor) *FallbackClient {
+	if breakers == nil {
+		breakers = NewCircuitBreakerRegistry(DefaultCircuitBreakerConfig())
+	}
+	return &FallbackClient{
+		tenantID: tenantID,
+		targets:  targets,
+		breakers: breakers,
+		monitor:  monitor,
+	}
+}
+
+// fallbackContextKey 回退信息的上下文键
+type fallbackContextKey struct{}
+
+// FallbackInfo 回退路由信息，由 LoggingClient 写入 ModelCallLog
+type FallbackInfo struct {
+	RequestedModelID string // 回退链的首选模型
+	Attempt          int    // 第几个候选（从 0 开始，0 表示首选模型直接应答）
+}
+
+// withFallbackInfo 将回退信息写入上下文
+func withFallbackInfo(ctx context.Context, info FallbackInfo) context.Context {
+	return context.WithValue(ctx, fallbackContextKey{}, info)
+}
+
+// FallbackInfoFromContext 从上下文读取回退信息
+func FallbackInfoFromContext(ctx context.Context) (FallbackInfo, bool) {
+	info, ok := ctx.Value(fallbackContextKey{}).(FallbackInfo)
+	return info, ok
+}
+
+// ChatCompletion 对话补全（按回退链依次尝试）
+func (c *FallbackClient) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	var errs []error
+	for i, target := range c.targets {
+		breaker := c.breakers.Get(c.tenantID + ":" + target.Provider)
+		if !breaker.Allow() {
+			errs = append(errs, fmt.Errorf("%s: 熔断中", target.ModelID))
+			continue
+		}
+
+		start := time.Now()
+		resp, err := target.Client.ChatCompletion(c.attemptContext(ctx, i), req)
+		c.record(ctx, target, start, resp, err)
+
+		if err == nil {
+			breaker.RecordSuccess()
+			return resp, nil
+		}
+		if !isRetryableError(err) {
+			// 参数、认证类错误不反映提供商的可用性，不改变熔断状态
+			breaker.RecordIgnored()
+			return nil, err
+		}
+		breaker.RecordFailure()
+		errs = append(errs, fmt.Errorf("%s: %w", target.ModelID, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, c.exhaustedError(errs)
+}
+
+// ChatCompletionStream 对话补全（流式）
+// 只在首个响应块到达之前回退，已开始输出的流出错时直接返回错误
+func (c *FallbackClient) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest) (<-chan StreamChunk, <-chan error) {
+	chunkChan := make(chan StreamChunk, 10)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(chunkChan)
+		defer close(errChan)
+
+		var errs []error
+		for i, target := range c.targets {
+			breaker := c.breakers.Get(c.tenantID + ":" + target.Provider)
+			if !breaker.Allow() {
+				errs = append(errs, fmt.Errorf("%s: 熔断中", target.ModelID))
+				continue
+			}
+
+			start := time.Now()
+			upstream, upstreamErr := target.Client.ChatCompletionStream(c.attemptContext(ctx, i), req)
+			started, err := forwardStream(ctx, upstream, upstreamErr, chunkChan)
+			c.record(ctx, target, start, nil, err)
+
+			switch {
+			case err == nil:
+				breaker.RecordSuccess()
+			case isRetryableError(err):
+				breaker.RecordFailure()
+			default:
+				breaker.RecordIgnored()
+			}
+			if err == nil {
+				return
+			}
+			if started || !isRetryableError(err) || ctx.Err() != nil {
+				errChan <- err
+				return
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", target.ModelID, err))
+		}
+		errChan <- c.exhaustedError(errs)
+	}()
+
+	return chunkChan, errChan
+}
+
+// forwardStream 转发上游流，返回是否已输出过响应块以及上游错误
+func forwardStream(ctx context.Context, upstream <-chan StreamChunk, upstreamErr <-chan error, out chan<- StreamChunk) (bool, error) {
+	started := false
+	for upstream != nil {
+		select {
+		case chunk, ok := <-upstream:
+			if !ok {
+				upstream = nil
+				continue
+			}
+			started = true
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return started, ctx.Err()
+			}
+		case <-ctx.Done():
+			return started, ctx.Err()
+		}
+	}
+
+	if upstreamErr == nil {
+		return started, nil
+	}
+	select {
+	case err := <-upstreamErr:
+		return started, err
+	case <-ctx.Done():
+		return started, ctx.Err()
+	}
+}
+
+// Embedding 文本向量化（不同模型的向量空间不兼容，只使用首选模型）
+func (c *FallbackClient) Embedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	if len(c.targets) == 0 {
+		return nil, fmt.Errorf("回退链为空")
+	}
+	return c.targets[0].Client.Embedding(ctx, req)
+}
+
+// Name 返回客户端名称
+func (c *FallbackClient) Name() string {
+	names := make([]string, 0, len(c.targets))
+	for _, target := range c.targets {
+		names = append(names, target.Client.Name())
+	}
+	return "fallback(" + strings.Join(names, ",") + ")"
+}
+
+// Close 关闭客户端
+func (c *FallbackClient) Close() error {
+	var errs []error
+	for _, target := range c.targets {
+		if err := target.Client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Targets 返回回退链
+func (c *FallbackClient) Targets() []FallbackTarget {
+	return append([]FallbackTarget(nil), c.targets...)
+}
+
+// attemptContext 为单次尝试附加回退信息
+func (c *FallbackClient) attemptContext(ctx context.Context, attempt int) context.Context {
+	if len(c.targets) == 0 {
+		return ctx
+	}
+	return withFallbackInfo(ctx, FallbackInfo{
+		RequestedModelID: c.targets[0].ModelID,
+		Attempt:          attempt,
+	})
+}
+
+// record 将调用结果写入性能监控
+func (c *FallbackClient) record(ctx context.Context, target FallbackTarget, start time.Time, resp *ChatCompletionResponse, err error) {
+	if c.monitor == nil {
+		return
+	}
+	var inputTokens, outputTokens int
+	if resp != nil {
+		inputTokens = resp.Usage.PromptTokens
+		outputTokens = resp.Usage.CompletionTokens
+	}
+	c.monitor.RecordRequest(ctx, target.Provider, target.ModelName, time.Since(start), inputTokens, outputTokens, err)
+}
+
+// exhaustedError 构造回退链全部失败时的错误
+func (c *FallbackClient) exhaustedError(errs []error) error {
+	clientErr := &ClientError{
+		Type:    ErrorTypeServerError,
+		Message: "回退链中的所有模型均不可用",
+		Err:     errors.Join(errs...),
+	}
+	// 全部因限流失败时保留限流语义，便于上层退避
+	allRateLimited := len(errs) > 0
+	for _, err := range errs {
+		var ce *ClientError
+		if !errors.As(err, &ce) || ce.Type != ErrorTypeRateLimit {
+			allRateLimited = false
+			break
+		}
+	}
+	if allRateLimited {
+		clientErr.Type = ErrorTypeRateLimit
+	}
+	return clientErr
+}
+
+// isRetryableError 判断错误是否应触发回退
+func isRetryableError(err error) bool {
+	var clientErr *ClientError
+	if errors.As(err, &clientErr) {
+		return clientErr.IsRetryable()
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubModelClient 按预设结果应答的客户端
+type stubModelClient struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (s *stubModelClient) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &ChatCompletionResponse{Model: s.name, Content: "来自 " + s.name}, nil
+}
+
+func (s *stubModelClient) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest) (<-chan StreamChunk, <-chan error) {
+	s.calls++
+	chunks := make(chan StreamChunk, 2)
+	errs := make(chan error, 1)
+	if s.err != nil {
+		errs <- s.err
+	} else {
+		chunks <- StreamChunk{Model: s.name, Content: s.name}
+		chunks <- StreamChunk{Done: true}
+	}
+	close(chunks)
+	close(errs)
+	return chunks, errs
+}
+
+func (s *stubModelClient) Embedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	return &EmbeddingResponse{}, nil
+}
+
+func (s *stubModelClient) Name() string { return s.name }
+
+func (s *stubModelClient) Close() error { return nil }
+
+func TestFallbackClientFallsBackOnRetryableError(t *testing.T) {
+	primary := &stubModelClient{name: "deepseek", err: &ClientError{Type: ErrorTypeRateLimit, Message: "限流"}}
+	secondary := &stubModelClient{name: "qwen"}
+	client := NewFallbackClient("tenant-1", []FallbackTarget{
+		{ModelID: "m-deepseek", Provider: "deepseek", Client: primary},
+		{ModelID: "m-qwen", Provider: "qwen", Client: secondary},
+	}, nil, nil)
+
+	resp, err := client.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+	if err != nil {
+		t.Fatalf("期望回退成功: %v", err)
+	}
+	if resp.Model != "qwen" {
+		t.Fatalf("期望由 qwen 应答，实际 %s", resp.Model)
+	}
+
+	var streamed string
+	chunks, errs := client.ChatCompletionStream(context.Background(), &ChatCompletionRequest{})
+	for chunk := range chunks {
+		streamed += chunk.Content
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("流式回退失败: %v", err)
+	}
+	if streamed != "qwen" {
+		t.Fatalf("期望流式由 qwen 应答，实际 %q", streamed)
+	}
+}
+
+func TestFallbackClientDoesNotFallBackOnInvalidParams(t *testing.T) {
+	primary := &stubModelClient{name: "deepseek", err: &ClientError{Type: ErrorTypeInvalidParams, Message: "参数错误"}}
+	secondary := &stubModelClient{name: "qwen"}
+	client := NewFallbackClient("tenant-1", []FallbackTarget{
+		{ModelID: "m-deepseek", Provider: "deepseek", Client: primary},
+		{ModelID: "m-qwen", Provider: "qwen", Client: secondary},
+	}, nil, nil)
+
+	if _, err := client.ChatCompletion(context.Background(), &ChatCompletionRequest{}); err == nil {
+		t.Fatal("期望返回参数错误")
+	}
+	if secondary.calls != 0 {
+		t.Fatalf("参数错误不应触发回退，qwen 被调用 %d 次", secondary.calls)
+	}
+}
+
+func TestFallbackClientCircuitBreakerSkipsOpenProvider(t *testing.T) {
+	breakers := NewCircuitBreakerRegistry(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	primary := &stubModelClient{name: "deepseek", err: &ClientError{Type: ErrorTypeServerError, Message: "503"}}
+	secondary := &stubModelClient{name: "ollama"}
+	client := NewFallbackClient("tenant-1", []FallbackTarget{
+		{ModelID: "m-deepseek", Provider: "deepseek", Client: primary},
+		{ModelID: "m-ollama", Provider: "ollama", Client: secondary},
+	}, breakers, nil)
+
+	for i := 0; i < 4; i++ {
+		if _, err := client.ChatCompletion(context.Background(), &ChatCompletionRequest{}); err != nil {
+			t.Fatalf("第 %d 次调用失败: %v", i, err)
+		}
+	}
+	if primary.calls != 2 {
+		t.Fatalf("熔断后应跳过 deepseek，实际调用 %d 次", primary.calls)
+	}
+	if state := breakers.Get("tenant-1:deepseek").State(); state != CircuitOpen {
+		t.Fatalf("期望熔断器打开，实际 %s", state)
+	}
+}
+
+func TestFallbackClientExhaustedKeepsRateLimitType(t *testing.T) {
+	limited := &ClientError{Type: ErrorTypeRateLimit, Message: "限流"}
+	client := NewFallbackClient("tenant-1", []FallbackTarget{
+		{ModelID: "a", Provider: "deepseek", Client: &stubModelClient{name: "a", err: limited}},
+		{ModelID: "b", Provider: "qwen", Client: &stubModelClient{name: "b", err: limited}},
+	}, nil, nil)
+
+	_, err := client.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+	var clientErr *ClientError
+	if !errors.As(err, &clientErr) || clientErr.Type != ErrorTypeRateLimit {
+		t.Fatalf("期望限流错误，实际 %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenRecovers(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second})
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	breaker.RecordFailure()
+	if breaker.Allow() {
+		t.Fatal("熔断期间不应放行")
+	}
+
+	now = now.Add(2 * time.Second)
+	if !breaker.Allow() {
+		t.Fatal("冷却结束后应放行探测请求")
+	}
+	if breaker.Allow() {
+		t.Fatal("半开状态只允许一个探测请求")
+	}
+	breaker.RecordSuccess()
+	if breaker.State() != CircuitClosed {
+		t.Fatalf("探测成功后应关闭熔断，实际 %s", breaker.State())
+	}
+}
+
+func TestFallbackClientNonRetryableErrorKeepsBreakerState(t *testing.T) {
+	breakers := NewCircuitBreakerRegistry(CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	primary := &stubModelClient{name: "deepseek"}
+	client := NewFallbackClient("tenant-1", []FallbackTarget{
+		{ModelID: "m-deepseek", Provider: "deepseek", Client: primary},
+	}, breakers, nil)
+
+	// 可重试错误之间夹杂的参数错误不清零失败计数
+	for _, err := range []error{
+		&ClientError{Type: ErrorTypeServerError, Message: "503"},
+		&ClientError{Type: ErrorTypeInvalidParams, Message: "参数错误"},
+		&ClientError{Type: ErrorTypeServerError, Message: "503"},
+	} {
+		primary.err = err
+		client.ChatCompletion(context.Background(), &ChatCompletionRequest{})
+	}
+	if state := breakers.Get("tenant-1:deepseek").State(); state != CircuitOpen {
+		t.Fatalf("参数错误不应重置熔断计数，实际 %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoredProbeKeepsHalfOpen(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Second})
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	breaker.RecordFailure()
+	now = now.Add(2 * time.Second)
+	if !breaker.Allow() {
+		t.Fatal("冷却结束后应放行探测请求")
+	}
+	// 探测请求返回参数错误：保持半开并归还探测名额
+	breaker.RecordIgnored()
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("不计入熔断的结果不应改变状态，实际 %s", breaker.State())
+	}
+	if !breaker.Allow() {
+		t.Fatal("归还名额后应允许新的探测请求")
+	}
+}
@@ -2,15 +2,16 @@ package google
 
 import (
 	"backend/pkg/aiinterface"
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	
 	"backend/internal/ai/converters"
 )
 
@@ -144,8 +145,8 @@ func (c *GeminiClient) ChatCompletionStream(ctx context.Context, req *aiinterfac
 			return
 		}
 
-		// 2. 构建流式 API 请求
-		url := fmt.Sprintf("%s/models/%s:streamGenerateContent?key=%s", c.baseURL, c.model, c.apiKey)
+		// 2. 构建流式 API 请求（alt=sse 使响应按 SSE 事件逐块返回）
+		url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s", c.baseURL, c.model, c.apiKey)
 
 		body, err := json.Marshal(geminiReq)
 		if err != nil {
@@ -175,24 +176,9 @@ func (c *GeminiClient) ChatCompletionStream(ctx context.Context, req *aiinterfac
 		}
 
 		// 3. 读取流式响应
-		decoder := json.NewDecoder(resp.Body)
-		for {
-			var chunk GeminiStreamChunk
-			if err := decoder.Decode(&chunk); err != nil {
-				if err == io.EOF {
-					break
-				}
-				errChan <- fmt.Errorf("解析流式响应失败: %w", err)
-				return
-			}
-
-			// 转换为统一格式
-			chunkChan <- aiinterface.StreamChunk{
-				ID:      chunk.ID,
-				Model:   c.model,
-				Content: c.extractContent(&chunk),
-				Done:    false,
-			}
+		if err := c.readStream(resp.Body, chunkChan); err != nil {
+			errChan <- err
+			return
 		}
 
 		// 发送结束标记
@@ -206,6 +192,71 @@ func (c *GeminiClient) ChatCompletionStream(ctx context.Context, req *aiinterfac
 	return chunkChan, errChan
 }
 
+// readStream 解析 SSE 响应
+// Gemini 的函数调用在单个块中完整返回，不会拆分参数，因此每个 functionCall 直接作为一个完整的增量输出
+func (c *GeminiClient) readStream(body io.Reader, chunkChan chan<- aiinterface.StreamChunk) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	toolIndex := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+
+		var chunk GeminiStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析流式响应失败: %w", err)
+		}
+
+		out := aiinterface.StreamChunk{
+			ID:    chunk.ID,
+			Model: c.model,
+		}
+		if len(chunk.Candidates) > 0 {
+			candidate := chunk.Candidates[0]
+			for _, part := range candidate.Content.Parts {
+				if part.FunctionCall == nil {
+					out.Content += part.Text
+					continue
+				}
+				delta := aiinterface.ToolCallDelta{
+					Index: toolIndex,
+					ID:    fmt.Sprintf("call_%d", toolIndex),
+					Type:  "function",
+				}
+				delta.Function.Name = part.FunctionCall.Name
+				delta.Function.Arguments = marshalArgs(part.FunctionCall.Args)
+				out.ToolCalls = append(out.ToolCalls, delta)
+				toolIndex++
+			}
+			out.FinishReason = convertFinishReason(candidate.FinishReason, toolIndex > 0)
+		}
+		if chunk.UsageMetadata != nil && out.FinishReason != "" {
+			out.Usage = &aiinterface.Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			}
+		}
+
+		if out.Content == "" && len(out.ToolCalls) == 0 && out.FinishReason == "" {
+			continue
+		}
+		chunkChan <- out
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取流式响应失败: %w", err)
+	}
+	return nil
+}
+
 // Embedding 文本向量化
 func (c *GeminiClient) Embedding(ctx context.Context, req *aiinterface.EmbeddingRequest) (*aiinterface.EmbeddingResponse, error) {
 	// Gemini 的 embedding API
@@ -287,14 +338,25 @@ func (c *GeminiClient) Close() error {
 
 func (c *GeminiClient) convertResponse(resp *GeminiResponse) *aiinterface.ChatCompletionResponse {
 	content := ""
-	if len(resp.Candidates) > 0 && len(resp.Candidates[0].Content.Parts) > 0 {
-		content = resp.Candidates[0].Content.Parts[0].Text
+	var toolCalls []aiinterface.ToolCall
+	if len(resp.Candidates) > 0 {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if part.FunctionCall == nil {
+				content += part.Text
+				continue
+			}
+			call := aiinterface.ToolCall{ID: fmt.Sprintf("call_%d", len(toolCalls)), Type: "function"}
+			call.Function.Name = part.FunctionCall.Name
+			call.Function.Arguments = marshalArgs(part.FunctionCall.Args)
+			toolCalls = append(toolCalls, call)
+		}
 	}
 
 	return &aiinterface.ChatCompletionResponse{
-		ID:      resp.ID,
-		Model:   c.model,
-		Content: content,
+		ID:        resp.ID,
+		Model:     c.model,
+		Content:   content,
+		ToolCalls: toolCalls,
 		Usage: aiinterface.Usage{
 			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
 			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
@@ -303,10 +365,34 @@ func (c *GeminiClient) convertResponse(resp *GeminiResponse) *aiinterface.ChatCo
 	}
 }
 
-func (c *GeminiClient) extractContent(chunk *GeminiStreamChunk) string {
-	if len(chunk.Candidates) > 0 && len(chunk.Candidates[0].Content.Parts) > 0 {
-		return chunk.Candidates[0].Content.Parts[0].Text
+// convertFinishReason 将 Gemini finishReason 转换为统一的结束原因
+// Gemini 在返回函数调用时同样给出 STOP，需要结合是否出现过函数调用判断
+func convertFinishReason(reason string, hasToolCalls bool) string {
+	switch reason {
+	case "":
+		return ""
+	case "STOP":
+		if hasToolCalls {
+			return aiinterface.FinishReasonToolCalls
+		}
+		return aiinterface.FinishReasonStop
+	case "MAX_TOKENS":
+		return aiinterface.FinishReasonLength
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		return aiinterface.FinishReasonContentFilter
+	default:
+		return strings.ToLower(reason)
 	}
-	return ""
 }
 
+// marshalArgs 将函数调用参数序列化为 JSON 字符串
+func marshalArgs(args map[string]any) string {
+	if len(args) == 0 {
+		return "{}"
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
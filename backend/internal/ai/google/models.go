@@ -1,8 +1,11 @@
 package google
 
+import "backend/internal/ai/converters"
+
 // GeminiPart Gemini 消息部分
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text         string                         `json:"text"`
+	FunctionCall *converters.GeminiFunctionCall `json:"functionCall,omitempty"`
 }
 
 // GeminiContent Gemini 内容
@@ -13,7 +16,8 @@ type GeminiContent struct {
 
 // GeminiCandidate Gemini 候选结果
 type GeminiCandidate struct {
-	Content GeminiContent `json:"content"`
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
 }
 
 // GeminiUsageMetadata Gemini 使用统计
@@ -32,8 +36,9 @@ type GeminiResponse struct {
 
 // GeminiStreamChunk Gemini 流式响应块
 type GeminiStreamChunk struct {
-	ID         string            `json:"id"`
-	Candidates []GeminiCandidate `json:"candidates"`
+	ID            string               `json:"id"`
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
 }
 
 // 预置 Gemini 模型列表
@@ -212,6 +212,7 @@ func (c *LoggingClient) logCall(ctx context.Context, resp *ChatCompletionRespons
 		TraceID:          traceID,
 	}
 	c.enrichModelMetadata(log)
+	applyFallbackInfo(ctx, log)
 
 	// 异步记录日志（不阻塞主流程）
 	go func() {
@@ -246,6 +247,7 @@ func (c *LoggingClient) logStreamCall(ctx context.Context, promptTokens, complet
 		TraceID:          traceID,
 	}
 	c.enrichModelMetadata(log)
+	applyFallbackInfo(ctx, log)
 
 	// 异步记录日志
 	go func() {
@@ -280,6 +282,7 @@ func (c *LoggingClient) logEmbeddingCall(ctx context.Context, usage *Usage, late
 		TraceID:          traceID,
 	}
 	c.enrichModelMetadata(log)
+	applyFallbackInfo(ctx, log)
 
 	// 异步记录日志
 	go func() {
@@ -312,6 +315,17 @@ func (c *LoggingClient) enrichModelMetadata(log *ModelCallLog) {
 	}
 }
 
+// applyFallbackInfo 记录回退链信息，标明实际应答的模型
+func applyFallbackInfo(ctx context.Context, log *ModelCallLog) {
+	info, ok := FallbackInfoFromContext(ctx)
+	if !ok || log == nil {
+		return
+	}
+	requested := info.RequestedModelID
+	log.RequestedModelID = &requested
+	log.FallbackAttempt = info.Attempt
+}
+
 // calculateCost 计算成本
 func (c *LoggingClient) calculateCost(promptTokens, completionTokens int) float64 {
 	if c.model == nil {
@@ -0,0 +1,246 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrCallRefused 计量器拒绝本次模型调用（如积分不足）
+var ErrCallRefused = errors.New("模型调用被拒绝")
+
+// CallScope 模型调用的归属信息，用于计费与 Token 审计
+// 由 Agent 运行时和工作流执行器写入上下文，计量器据此确定扣费账户与关联记录
+type CallScope struct {
+	TenantID    string
+	UserID      string
+	WorkflowID  string
+	ExecutionID string
+	StepID      string
+	AgentID     string
+
+	parent  *CallScope
+	metered atomic.Bool
+}
+
+// WithAgent 返回归属于指定 Agent 的子范围（嵌套 Agent 调用），计量标记同步到父范围
+func (s *CallScope) WithAgent(agentID string) *CallScope {
+	return &CallScope{
+		TenantID:    s.TenantID,
+		UserID:      s.UserID,
+		WorkflowID:  s.WorkflowID,
+		ExecutionID: s.ExecutionID,
+		StepID:      s.StepID,
+		AgentID:     agentID,
+		parent:      s,
+	}
+}
+
+// MarkMetered 标记本范围内的调用已由计量器记录 Token 用量
+func (s *CallScope) MarkMetered() {
+	for scope := s; scope != nil; scope = scope.parent {
+		scope.metered.Store(true)
+	}
+}
+
+// Metered 本范围内是否有调用经过计量器（调用方可据此避免重复记录 Token 用量）
+func (s *CallScope) Metered() bool {
+	return s.metered.Load()
+}
+
+type callScopeKey struct{}
+
+// WithCallScope 在上下文中写入调用归属
+func WithCallScope(ctx context.Context, scope *CallScope) context.Context {
+	return context.WithValue(ctx, callScopeKey{}, scope)
+}
+
+// CallScopeFromContext 读取调用归属，不存在时返回 nil
+func CallScopeFromContext(ctx context.Context) *CallScope {
+	scope, _ := ctx.Value(callScopeKey{}).(*CallScope)
+	return scope
+}
+
+// MeteredCall 一次待计量的模型调用
+type MeteredCall struct {
+	TenantID    string
+	ModelID     string
+	Provider    string
+	Model       string // 模型标识（用于匹配定价）
+	Scope       *CallScope
+	Messages    []Message
+	MaxTokens   int
+	EmbedInputs []string // 向量化调用的输入文本
+}
+
+// CallMeter 模型调用计量器
+// Authorize 在调用前预授权（返回空 holdID 表示本次调用不计费），拒绝时返回的错误应包装 ErrCallRefused；
+// 调用成功后 Settle 按实际用量结算，失败时 Release 释放预授权；
+// 结算与释放发生在调用完成之后，失败由计量器自行记录，不影响调用结果
+type CallMeter interface {
+	Authorize(ctx context.Context, call *MeteredCall) (holdID string, err error)
+	Settle(ctx context.Context, holdID string, call *MeteredCall, usage Usage)
+	Release(ctx context.Context, holdID string, call *MeteredCall, reason string)
+}
+
+// MeteringClient 带积分计量的客户端包装器
+type MeteringClient struct {
+	client   ModelClient
+	meter    CallMeter
+	tenantID string
+	modelID  string
+	provider string
+	model    string
+}
+
+// NewMeteringClient 创建带积分计量的客户端
+func NewMeteringClient(client ModelClient, meter CallMeter, tenantID, modelID, provider, model string) *MeteringClient {
+	return &MeteringClient{
+		client:   client,
+		meter:    meter,
+		tenantID: tenantID,
+		modelID:  modelID,
+		provider: provider,
+		model:    model,
+	}
+}
+
+func (c *MeteringClient) newCall(ctx context.Context) *MeteredCall {
+	return &MeteredCall{
+		TenantID: c.tenantID,
+		ModelID:  c.modelID,
+		Provider: c.provider,
+		Model:    c.model,
+		Scope:    CallScopeFromContext(ctx),
+	}
+}
+
+// ChatCompletion 对话补全（预授权 → 调用 → 结算）
+func (c *MeteringClient) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	call := c.newCall(ctx)
+	call.Messages = req.Messages
+	call.MaxTokens = req.MaxTokens
+
+	holdID, err := c.meter.Authorize(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.ChatCompletion(ctx, req)
+	if err != nil {
+		c.meter.Release(context.WithoutCancel(ctx), holdID, call, err.Error())
+		return nil, err
+	}
+	c.meter.Settle(context.WithoutCancel(ctx), holdID, call, resp.Usage)
+	return resp, nil
+}
+
+// ChatCompletionStream 流式对话补全，结束后按最终用量结算
+// 提供商未返回用量时按输出内容估算
+func (c *MeteringClient) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest) (<-chan StreamChunk, <-chan error) {
+	call := c.newCall(ctx)
+	call.Messages = req.Messages
+	call.MaxTokens = req.MaxTokens
+
+	holdID, err := c.meter.Authorize(ctx, call)
+	if err != nil {
+		chunkChan := make(chan StreamChunk)
+		errChan := make(chan error, 1)
+		close(chunkChan)
+		errChan <- err
+		close(errChan)
+		return chunkChan, errChan
+	}
+
+	chunkChan, errChan := c.client.ChatCompletionStream(ctx, req)
+	outChunks := make(chan StreamChunk, 10)
+	outErrs := make(chan error, 1)
+
+	go func() {
+		defer close(outChunks)
+		defer close(outErrs)
+
+		// 消费方离开（ctx 结束）后不再转发，但继续读完提供商输出，按已生成的内容结算
+		var usage *Usage
+		var content []byte
+		forwarding := true
+		for chunk := range chunkChan {
+			if chunk.Usage != nil {
+				usage = chunk.Usage
+			}
+			content = append(content, chunk.Content...)
+			if !forwarding {
+				continue
+			}
+			select {
+			case outChunks <- chunk:
+			case <-ctx.Done():
+				forwarding = false
+			}
+		}
+		streamErr := <-errChan
+
+		settleCtx := context.WithoutCancel(ctx)
+		if streamErr != nil && usage == nil && len(content) == 0 {
+			c.meter.Release(settleCtx, holdID, call, streamErr.Error())
+			outErrs <- streamErr
+			return
+		}
+		if usage == nil {
+			prompt := 0
+			for _, m := range req.Messages {
+				prompt += EstimateTokens(m.Content)
+			}
+			completion := EstimateTokens(string(content))
+			usage = &Usage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion}
+		}
+		c.meter.Settle(settleCtx, holdID, call, *usage)
+		if streamErr != nil {
+			outErrs <- streamErr
+		}
+	}()
+
+	return outChunks, outErrs
+}
+
+// Embedding 文本向量化（按输入用量结算）
+func (c *MeteringClient) Embedding(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	call := c.newCall(ctx)
+	call.EmbedInputs = req.Texts
+
+	holdID, err := c.meter.Authorize(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Embedding(ctx, req)
+	if err != nil {
+		c.meter.Release(context.WithoutCancel(ctx), holdID, call, err.Error())
+		return nil, err
+	}
+	c.meter.Settle(context.WithoutCancel(ctx), holdID, call, resp.Usage)
+	return resp, nil
+}
+
+// Name 返回客户端名称
+func (c *MeteringClient) Name() string {
+	return c.client.Name()
+}
+
+// Close 关闭客户端
+func (c *MeteringClient) Close() error {
+	return c.client.Close()
+}
+
+// EstimateTokens 粗略估算文本 Token 数：中文约每字 1.5 Token，其他字符约每 4 个 1 Token
+func EstimateTokens(text string) int {
+	chinese, other := 0, 0
+	for _, r := range text {
+		if r >= 0x4E00 && r <= 0x9FFF {
+			chinese++
+		} else {
+			other++
+		}
+	}
+	return int(float64(chinese)*1.5) + (other+3)/4
+}
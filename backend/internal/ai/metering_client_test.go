@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// recordingMeter 记录结算结果的计量器
+type recordingMeter struct {
+	settled  chan Usage
+	released chan string
+}
+
+func (m *recordingMeter) Authorize(context.Context, *MeteredCall) (string, error) { return "hold-1", nil }
+
+func (m *recordingMeter) Settle(_ context.Context, _ string, _ *MeteredCall, usage Usage) {
+	m.settled <- usage
+}
+
+func (m *recordingMeter) Release(_ context.Context, _ string, _ *MeteredCall, reason string) {
+	m.released <- reason
+}
+
+// endlessStreamClient 持续输出直到 ctx 结束的流式客户端
+type endlessStreamClient struct{ stubModelClient }
+
+func (c *endlessStreamClient) ChatCompletionStream(ctx context.Context, req *ChatCompletionRequest) (<-chan StreamChunk, <-chan error) {
+	chunks := make(chan StreamChunk)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		defer close(errs)
+		for {
+			select {
+			case chunks <- StreamChunk{Content: "字"}:
+			case <-ctx.Done():
+				errs <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return chunks, errs
+}
+
+func TestMeteringClientSettlesWhenConsumerLeaves(t *testing.T) {
+	meter := &recordingMeter{settled: make(chan Usage, 1), released: make(chan string, 1)}
+	client := NewMeteringClient(&endlessStreamClient{}, meter, "tenant-1", "model-1", "openai", "gpt-4o")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	chunks, _ := client.ChatCompletionStream(ctx, &ChatCompletionRequest{Messages: []Message{{Role: "user", Content: "写一首诗"}}})
+	<-chunks
+	// 消费方读取一个 chunk 后离开，不再读取
+	cancel()
+
+	select {
+	case usage := <-meter.settled:
+		if usage.CompletionTokens == 0 {
+			t.Fatalf("expected usage estimated from generated content, got %+v", usage)
+		}
+	case reason := <-meter.released:
+		t.Fatalf("hold with generated content must be settled, released: %s", reason)
+	case <-time.After(2 * time.Second):
+		t.Fatal("hold was neither settled nor released after the consumer left")
+	}
+}
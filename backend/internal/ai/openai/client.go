@@ -3,19 +3,21 @@ package openai
 import (
 	"backend/pkg/aiinterface"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"time"
 
-	
-
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // Client OpenAI 客户端适配器
 type Client struct {
-	client     *openai.Client
-	modelID    string
-	maxRetries int
+	client      *openai.Client
+	modelID     string
+	maxRetries  int
+	streamUsage bool // 流式请求是否附带 stream_options.include_usage
 }
 
 // NewClient 创建 OpenAI 客户端
@@ -43,32 +45,24 @@ func NewClient(config *aiinterface.ClientConfig) (*Client, error) {
 		maxRetries = 3
 	}
 
+	// 部分 OpenAI 兼容服务不识别 stream_options，可通过 Extra["stream_usage"]=false 关闭
+	streamUsage := true
+	if v, ok := config.Extra["stream_usage"].(bool); ok {
+		streamUsage = v
+	}
+
 	return &Client{
-		client:     openai.NewClientWithConfig(clientConfig),
-		modelID:    config.Model,
-		maxRetries: maxRetries,
+		client:      openai.NewClientWithConfig(clientConfig),
+		modelID:     config.Model,
+		maxRetries:  maxRetries,
+		streamUsage: streamUsage,
 	}, nil
 }
 
 // ChatCompletion 对话补全（非流式）
 func (c *Client) ChatCompletion(ctx context.Context, req *aiinterface.ChatCompletionRequest) (*aiinterface.ChatCompletionResponse, error) {
-	// 转换消息格式
-	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-	for i, msg := range req.Messages {
-		messages[i] = openai.ChatCompletionMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
-
 	// 构建请求
-	openaiReq := openai.ChatCompletionRequest{
-		Model:       c.modelID,
-		Messages:    messages,
-		Temperature: float32(req.Temperature),
-		MaxTokens:   req.MaxTokens,
-		TopP:        float32(req.TopP),
-	}
+	openaiReq := c.buildRequest(req)
 
 	// 调用 API（带重试）
 	var resp openai.ChatCompletionResponse
@@ -104,9 +98,10 @@ func (c *Client) ChatCompletion(ctx context.Context, req *aiinterface.ChatComple
 	}
 
 	return &aiinterface.ChatCompletionResponse{
-		ID:      resp.ID,
-		Model:   resp.Model,
-		Content: resp.Choices[0].Message.Content,
+		ID:        resp.ID,
+		Model:     resp.Model,
+		Content:   resp.Choices[0].Message.Content,
+		ToolCalls: convertToolCalls(resp.Choices[0].Message.ToolCalls),
 		Usage: aiinterface.Usage{
 			PromptTokens:     resp.Usage.PromptTokens,
 			CompletionTokens: resp.Usage.CompletionTokens,
@@ -124,23 +119,11 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *aiinterface.Chat
 		defer close(chunkChan)
 		defer close(errChan)
 
-		// 转换消息格式
-		messages := make([]openai.ChatCompletionMessage, len(req.Messages))
-		for i, msg := range req.Messages {
-			messages[i] = openai.ChatCompletionMessage{
-				Role:    msg.Role,
-				Content: msg.Content,
-			}
-		}
-
 		// 构建请求
-		openaiReq := openai.ChatCompletionRequest{
-			Model:       c.modelID,
-			Messages:    messages,
-			Temperature: float32(req.Temperature),
-			MaxTokens:   req.MaxTokens,
-			TopP:        float32(req.TopP),
-			Stream:      true,
+		openaiReq := c.buildRequest(req)
+		openaiReq.Stream = true
+		if c.streamUsage {
+			openaiReq.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
 		}
 
 		// 创建流
@@ -156,7 +139,7 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *aiinterface.Chat
 			response, err := stream.Recv()
 			if err != nil {
 				// EOF 表示正常结束
-				if err.Error() == "EOF" {
+				if errors.Is(err, io.EOF) {
 					chunkChan <- aiinterface.StreamChunk{Done: true}
 					return
 				}
@@ -164,14 +147,26 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *aiinterface.Chat
 				return
 			}
 
-			// 发送响应块
-			if len(response.Choices) > 0 {
-				chunk := aiinterface.StreamChunk{
-					ID:      response.ID,
-					Model:   response.Model,
-					Content: response.Choices[0].Delta.Content,
-					Done:    false,
+			chunk := aiinterface.StreamChunk{
+				ID:    response.ID,
+				Model: response.Model,
+			}
+			if response.Usage != nil {
+				chunk.Usage = &aiinterface.Usage{
+					PromptTokens:     response.Usage.PromptTokens,
+					CompletionTokens: response.Usage.CompletionTokens,
+					TotalTokens:      response.Usage.TotalTokens,
 				}
+			}
+			if len(response.Choices) > 0 {
+				choice := response.Choices[0]
+				chunk.Content = choice.Delta.Content
+				chunk.FinishReason = string(choice.FinishReason)
+				chunk.ToolCalls = convertToolCallDeltas(choice.Delta.ToolCalls)
+			}
+
+			// 发送响应块（include_usage 的最后一块没有 choices，只携带 usage）
+			if len(response.Choices) > 0 || chunk.Usage != nil {
 				chunkChan <- chunk
 			}
 		}
@@ -180,6 +175,114 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *aiinterface.Chat
 	return chunkChan, errChan
 }
 
+// buildRequest 将统一请求转换为 OpenAI 请求（含工具定义与工具调用消息）
+func (c *Client) buildRequest(req *aiinterface.ChatCompletionRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = openai.ChatCompletionMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		for _, tc := range msg.ToolCalls {
+			messages[i].ToolCalls = append(messages[i].ToolCalls, openai.ToolCall{
+				ID:   tc.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+	}
+
+	openaiReq := openai.ChatCompletionRequest{
+		Model:       c.modelID,
+		Messages:    messages,
+		Temperature: float32(req.Temperature),
+		MaxTokens:   req.MaxTokens,
+		TopP:        float32(req.TopP),
+	}
+
+	for _, tool := range req.Tools {
+		openaiReq.Tools = append(openaiReq.Tools, openai.Tool{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+	if len(openaiReq.Tools) > 0 && req.ToolChoice != nil {
+		openaiReq.ToolChoice = req.ToolChoice
+	}
+	openaiReq.ResponseFormat = convertResponseFormat(req.ResponseFormat)
+
+	return openaiReq
+}
+
+// convertResponseFormat 转换结构化输出约束
+func convertResponseFormat(format *aiinterface.ResponseFormat) *openai.ChatCompletionResponseFormat {
+	if format == nil {
+		return nil
+	}
+	if format.Type != aiinterface.ResponseFormatJSONSchema || len(format.Schema) == 0 {
+		return &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+	return &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   format.SchemaName(),
+			Schema: schemaMarshaler(format.Schema),
+			Strict: format.Strict,
+		},
+	}
+}
+
+// schemaMarshaler 让 map 形式的 Schema 满足 json.Marshaler
+type schemaMarshaler map[string]any
+
+func (s schemaMarshaler) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any(s))
+}
+
+// convertToolCalls 转换非流式响应中的工具调用
+func convertToolCalls(calls []openai.ToolCall) []aiinterface.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]aiinterface.ToolCall, len(calls))
+	for i, call := range calls {
+		result[i].ID = call.ID
+		result[i].Type = string(call.Type)
+		result[i].Function.Name = call.Function.Name
+		result[i].Function.Arguments = call.Function.Arguments
+	}
+	return result
+}
+
+// convertToolCallDeltas 转换流式响应中的工具调用增量
+func convertToolCallDeltas(calls []openai.ToolCall) []aiinterface.ToolCallDelta {
+	if len(calls) == 0 {
+		return nil
+	}
+	result := make([]aiinterface.ToolCallDelta, len(calls))
+	for i, call := range calls {
+		idx := i
+		if call.Index != nil {
+			idx = *call.Index
+		}
+		result[i].Index = idx
+		result[i].ID = call.ID
+		result[i].Type = string(call.Type)
+		result[i].Function.Name = call.Function.Name
+		result[i].Function.Arguments = call.Function.Arguments
+	}
+	return result
+}
+
 // Embedding 文本向量化
 func (c *Client) Embedding(ctx context.Context, req *aiinterface.EmbeddingRequest) (*aiinterface.EmbeddingResponse, error) {
 	// 构建请求
@@ -261,6 +364,15 @@ func isRetryableError(err error) bool {
 
 // wrapError 包装错误
 func wrapError(err error) *aiinterface.ClientError {
+	// 优先按 HTTP 状态码分类
+	if statusCode := httpStatusCode(err); statusCode > 0 {
+		return &aiinterface.ClientError{
+			Type:    errorTypeForStatus(statusCode),
+			Message: fmt.Sprintf("OpenAI API 错误 (HTTP %d)", statusCode),
+			Err:     err,
+		}
+	}
+
 	errMsg := err.Error()
 
 	// 判断错误类型
@@ -287,6 +399,35 @@ func wrapError(err error) *aiinterface.ClientError {
 	}
 }
 
+// httpStatusCode 提取 go-openai 错误中的 HTTP 状态码
+func httpStatusCode(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
+
+// errorTypeForStatus 根据 HTTP 状态码判断错误类型
+func errorTypeForStatus(statusCode int) aiinterface.ErrorType {
+	switch {
+	case statusCode == 401 || statusCode == 403:
+		return aiinterface.ErrorTypeAuth
+	case statusCode == 429:
+		return aiinterface.ErrorTypeRateLimit
+	case statusCode >= 500:
+		return aiinterface.ErrorTypeServerError
+	case statusCode >= 400:
+		return aiinterface.ErrorTypeInvalidParams
+	default:
+		return aiinterface.ErrorTypeUnknown
+	}
+}
+
 // contains 字符串包含判断（不区分大小写）
 func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
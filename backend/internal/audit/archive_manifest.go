@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// manifestFileName 归档清单文件名，位于归档根目录
+const manifestFileName = "manifest.json"
+
+// 归档校验失败原因
+const (
+	ArchiveBreakManifestMissing = "manifest_missing"   // 存在归档文件但没有清单
+	ArchiveBreakManifestChain   = "manifest_chain"     // 清单条目链被改写
+	ArchiveBreakSignature       = "manifest_signature" // 清单签名无效
+	ArchiveBreakFileMissing     = "file_missing"       // 清单中的文件不存在
+	ArchiveBreakDigestMismatch  = "digest_mismatch"    // 文件摘要或大小与清单不符
+	ArchiveBreakUnlistedFile    = "unlisted_file"      // 文件未登记在清单中
+)
+
+// ArchiveManifest 归档清单：每次写入或删除归档文件都追加一条记录，
+// 记录之间以摘要串联，链尾摘要由签名器签名
+type ArchiveManifest struct {
+	Version    int                    `json:"version"`
+	Entries    []ArchiveManifestEntry `json:"entries"`
+	HeadDigest string                 `json:"head_digest"`
+	KeyID      string                 `json:"key_id,omitempty"`
+	Signature  []byte                 `json:"signature,omitempty"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+}
+
+// ArchiveManifestEntry 清单中的一条记录
+type ArchiveManifestEntry struct {
+	File       string    `json:"file"` // 相对归档目录的路径
+	Month      string    `json:"month,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	Size       int64     `json:"size"`
+	LogCount   int       `json:"log_count"`
+	FirstLogID string    `json:"first_log_id,omitempty"`
+	LastLogID  string    `json:"last_log_id,omitempty"`
+	Removed    bool      `json:"removed,omitempty"` // 过期清理删除的文件
+	CreatedAt  time.Time `json:"created_at"`
+	PrevDigest string    `json:"prev_digest"`
+	Digest     string    `json:"digest"`
+}
+
+// computeDigest 计算记录摘要，覆盖全部字段与上一条记录的摘要
+func (e ArchiveManifestEntry) computeDigest() string {
+	h := sha256.New()
+	writeHashField(h, "audit-archive:v1")
+	writeHashField(h, e.PrevDigest)
+	writeHashField(h, e.File)
+	writeHashField(h, e.Month)
+	writeHashField(h, e.SHA256)
+	writeHashField(h, strconv.FormatInt(e.Size, 10))
+	writeHashField(h, strconv.Itoa(e.LogCount))
+	writeHashField(h, e.FirstLogID)
+	writeHashField(h, e.LastLogID)
+	writeHashField(h, strconv.FormatBool(e.Removed))
+	writeHashField(h, e.CreatedAt.UTC().Format(time.RFC3339Nano))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signedMessage 清单签名内容
+func (m *ArchiveManifest) signedMessage() []byte {
+	return []byte("audit-archive-manifest:v1\n" + m.HeadDigest + "\n" + strconv.Itoa(len(m.Entries)))
+}
+
+// ArchiveBrokenLink 归档校验发现的第一个问题
+type ArchiveBrokenLink struct {
+	File     string `json:"file,omitempty"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// ArchiveVerification 归档校验结果
+type ArchiveVerification struct {
+	Valid             bool               `json:"valid"`
+	Files             int                `json:"files"`
+	ManifestEntries   int                `json:"manifest_entries"`
+	HeadDigest        string             `json:"head_digest,omitempty"`
+	SignatureVerified bool               `json:"signature_verified"`
+	BrokenLink        *ArchiveBrokenLink `json:"broken_link,omitempty"`
+	VerifiedAt        time.Time          `json:"verified_at"`
+}
+
+// SetSigner 设置清单签名器
+func (a *Archiver) SetSigner(signer CheckpointSigner) {
+	a.signer = signer
+}
+
+// manifestPath 清单文件路径
+func (a *Archiver) manifestPath() string {
+	return filepath.Join(a.archivePath, manifestFileName)
+}
+
+// loadManifest 读取清单，不存在时返回 nil
+func (a *Archiver) loadManifest() (*ArchiveManifest, error) {
+	data, err := os.ReadFile(a.manifestPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest ArchiveManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// appendManifest 追加记录并重新签名，先写临时文件再原子替换
+func (a *Archiver) appendManifest(entries ...ArchiveManifestEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	manifest, err := a.loadManifest()
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		manifest = &ArchiveManifest{Version: 1}
+	}
+
+	for _, entry := range entries {
+		entry.PrevDigest = manifest.HeadDigest
+		entry.Digest = entry.computeDigest()
+		manifest.Entries = append(manifest.Entries, entry)
+		manifest.HeadDigest = entry.Digest
+	}
+	manifest.UpdatedAt = time.Now().UTC()
+	manifest.KeyID, manifest.Signature = "", nil
+	if a.signer != nil {
+		signature, err := a.signer.Sign(manifest.signedMessage())
+		if err != nil {
+			return fmt.Errorf("failed to sign manifest: %w", err)
+		}
+		manifest.KeyID, manifest.Signature = a.signer.KeyID(), signature
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(a.archivePath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp := a.manifestPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp, a.manifestPath())
+}
+
+// manifestEntryFor 为刚写入的归档文件生成清单记录
+func (a *Archiver) manifestEntryFor(path string, month time.Time, logs []AuditLog) (ArchiveManifestEntry, error) {
+	digest, size, err := fileSHA256(path)
+	if err != nil {
+		return ArchiveManifestEntry{}, err
+	}
+	entry := ArchiveManifestEntry{
+		File:      a.relativePath(path),
+		Month:     month.Format("2006-01"),
+		SHA256:    digest,
+		Size:      size,
+		LogCount:  len(logs),
+		CreatedAt: time.Now().UTC(),
+	}
+	if len(logs) > 0 {
+		entry.FirstLogID, entry.LastLogID = logs[0].ID, logs[len(logs)-1].ID
+	}
+	return entry, nil
+}
+
+// relativePath 清单中统一使用相对归档目录的斜杠路径
+func (a *Archiver) relativePath(path string) string {
+	if rel, err := filepath.Rel(a.archivePath, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+// VerifyArchives 校验清单链与签名，逐个核对归档文件摘要，并检查未登记的文件，返回第一个问题
+func (a *Archiver) VerifyArchives() (*ArchiveVerification, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := &ArchiveVerification{Valid: true, VerifiedAt: time.Now()}
+	fail := func(link ArchiveBrokenLink) (*ArchiveVerification, error) {
+		result.Valid = false
+		result.BrokenLink = &link
+		return result, nil
+	}
+
+	archives, err := a.ListArchives()
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := a.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		if len(archives) > 0 {
+			return fail(ArchiveBrokenLink{Reason: ArchiveBreakManifestMissing})
+		}
+		return result, nil
+	}
+	result.ManifestEntries = len(manifest.Entries)
+	result.HeadDigest = manifest.HeadDigest
+
+	// 清单记录链
+	prev := ""
+	latest := make(map[string]ArchiveManifestEntry)
+	var order []string
+	for _, entry := range manifest.Entries {
+		if entry.PrevDigest != prev {
+			return fail(ArchiveBrokenLink{File: entry.File, Reason: ArchiveBreakManifestChain, Expected: prev, Actual: entry.PrevDigest})
+		}
+		if computed := entry.computeDigest(); computed != entry.Digest {
+			return fail(ArchiveBrokenLink{File: entry.File, Reason: ArchiveBreakManifestChain, Expected: computed, Actual: entry.Digest})
+		}
+		prev = entry.Digest
+		if _, ok := latest[entry.File]; !ok {
+			order = append(order, entry.File)
+		}
+		latest[entry.File] = entry
+	}
+	if manifest.HeadDigest != prev {
+		return fail(ArchiveBrokenLink{Reason: ArchiveBreakManifestChain, Expected: prev, Actual: manifest.HeadDigest})
+	}
+	if a.signer != nil {
+		if !a.signer.Verify(manifest.KeyID, manifest.signedMessage(), manifest.Signature) {
+			return fail(ArchiveBrokenLink{Reason: ArchiveBreakSignature, Actual: manifest.KeyID})
+		}
+		result.SignatureVerified = true
+	}
+
+	// 文件摘要
+	for _, file := range order {
+		entry := latest[file]
+		if entry.Removed {
+			continue
+		}
+		digest, size, err := fileSHA256(filepath.Join(a.archivePath, filepath.FromSlash(file)))
+		if errors.Is(err, os.ErrNotExist) {
+			return fail(ArchiveBrokenLink{File: file, Reason: ArchiveBreakFileMissing, Expected: entry.SHA256})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if digest != entry.SHA256 || size != entry.Size {
+			return fail(ArchiveBrokenLink{File: file, Reason: ArchiveBreakDigestMismatch, Expected: entry.SHA256, Actual: digest})
+		}
+		result.Files++
+	}
+
+	// 未登记的文件
+	for _, archive := range archives {
+		rel := a.relativePath(archive.Path)
+		if entry, ok := latest[rel]; !ok || entry.Removed {
+			return fail(ArchiveBrokenLink{File: rel, Reason: ArchiveBreakUnlistedFile})
+		}
+	}
+	return result, nil
+}
+
+// fileSHA256 计算文件的 SHA-256 与大小
+func fileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
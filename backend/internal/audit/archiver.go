@@ -19,6 +19,7 @@ type Archiver struct {
 	archivePath   string
 	retentionDays int
 	compressLevel int
+	signer        CheckpointSigner
 	mu            sync.Mutex
 }
 
@@ -124,6 +125,7 @@ func (a *Archiver) Archive(ctx context.Context) (*ArchiveResult, error) {
 	currentMonth := time.Date(oldest.Timestamp.Year(), oldest.Timestamp.Month(), 1, 0, 0, 0, 0, time.UTC)
 	endMonth := time.Date(cutoffTime.Year(), cutoffTime.Month(), 1, 0, 0, 0, 0, time.UTC)
 
+	var manifestEntries []ArchiveManifestEntry
 	for currentMonth.Before(endMonth) {
 		nextMonth := currentMonth.AddDate(0, 1, 0)
 
@@ -146,7 +148,10 @@ func (a *Archiver) Archive(ctx context.Context) (*ArchiveResult, error) {
 			filename, err := a.archiveToFile(logs, currentMonth)
 			if err != nil {
 				result.Errors = append(result.Errors, fmt.Sprintf("archive %s: %v", currentMonth.Format("2006-01"), err))
+			} else if entry, err := a.manifestEntryFor(filename, currentMonth, logs); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("digest %s: %v", currentMonth.Format("2006-01"), err))
 			} else {
+				manifestEntries = append(manifestEntries, entry)
 				result.ArchivedFiles = append(result.ArchivedFiles, filename)
 				result.TotalLogs += int64(len(logs))
 			}
@@ -157,6 +162,13 @@ func (a *Archiver) Archive(ctx context.Context) (*ArchiveResult, error) {
 
 	result.EndDate = cutoffTime
 
+	// 清单未能记录归档文件摘要时保留数据库中的日志
+	if err := a.appendManifest(manifestEntries...); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("manifest: %v", err))
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+
 	// 删除已归档的日志
 	deleted, err := a.store.DeleteLogs(ctx, cutoffTime)
 	if err != nil {
@@ -330,17 +342,31 @@ func (a *Archiver) CleanOldArchives(maxAge time.Duration) ([]string, error) {
 		return nil, err
 	}
 
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
 	cutoff := time.Now().Add(-maxAge)
 	var deleted []string
+	var removals []ArchiveManifestEntry
 
 	for _, archive := range archives {
 		if archive.ModTime.Before(cutoff) {
 			if err := os.Remove(archive.Path); err == nil {
 				deleted = append(deleted, archive.Path)
+				removals = append(removals, ArchiveManifestEntry{
+					File:      a.relativePath(archive.Path),
+					Removed:   true,
+					CreatedAt: time.Now().UTC(),
+				})
 			}
 		}
 	}
 
+	// 在清单中登记删除，避免校验时误报文件缺失
+	if err := a.appendManifest(removals...); err != nil {
+		return deleted, err
+	}
+
 	return deleted, nil
 }
 
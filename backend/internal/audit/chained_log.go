@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"backend/internal/infra"
+	"backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// AppendLog 实现 models.AuditLogAppender：将 AuditLogService 写入的日志（请求审计中间件、租户操作审计）挂接到所属租户的哈希链上。
+// 链上哈希只覆盖 ChainEntry 的字段，因此请求信息与元数据同时写入 details，篡改其中任何一项都会导致校验失败
+func (c *HashChain) AppendLog(ctx context.Context, log *models.AuditLog) error {
+	if log.ID == "" {
+		log.ID = uuid.New().String()
+	}
+	if log.CreatedAt.IsZero() {
+		log.CreatedAt = time.Now()
+	}
+
+	details, err := json.Marshal(chainedLogDetails(log))
+	if err != nil {
+		return fmt.Errorf("序列化审计日志详情失败: %w", err)
+	}
+	var metadata []byte
+	if log.Metadata != nil {
+		if metadata, err = json.Marshal(log.Metadata); err != nil {
+			return fmt.Errorf("序列化审计日志元数据失败: %w", err)
+		}
+	}
+
+	resource := log.RequestPath
+	if resource == "" {
+		resource = log.EventCategory
+	}
+	entry := ChainEntry{
+		ID:        log.ID,
+		TenantID:  log.TenantID,
+		UserID:    log.UserID,
+		Action:    log.EventType,
+		Resource:  resource,
+		Details:   details,
+		CreatedAt: log.CreatedAt,
+	}
+
+	const q = `
+		INSERT INTO audit_logs (id, tenant_id, user_id, event_type, event_category, event_level, action, resource, description,
+			details, metadata, ip_address, user_agent, request_path, request_method, status_code, created_at, chain_seq, prev_hash, entry_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+	`
+	return c.Append(ctx, entry, func(db infra.DB, e ChainEntry) error {
+		_, err := db.ExecContext(ctx, q,
+			e.ID,
+			e.TenantID,
+			nullableString(e.UserID),
+			log.EventType,
+			log.EventCategory,
+			log.EventLevel,
+			e.Action,
+			e.Resource,
+			log.Description,
+			jsonOrNull(e.Details),
+			jsonOrNull(metadata),
+			log.IPAddress,
+			log.UserAgent,
+			log.RequestPath,
+			log.RequestMethod,
+			log.StatusCode,
+			e.CreatedAt,
+			e.Seq,
+			e.PrevHash,
+			e.EntryHash,
+		)
+		return err
+	})
+}
+
+// chainedLogDetails 参与哈希计算的请求信息与元数据，空字段省略
+func chainedLogDetails(log *models.AuditLog) map[string]any {
+	details := map[string]any{
+		"event_category": log.EventCategory,
+		"event_level":    log.EventLevel,
+	}
+	optional := map[string]string{
+		"description":    log.Description,
+		"ip_address":     log.IPAddress,
+		"user_agent":     log.UserAgent,
+		"request_path":   log.RequestPath,
+		"request_method": log.RequestMethod,
+	}
+	for key, value := range optional {
+		if value != "" {
+			details[key] = value
+		}
+	}
+	if log.StatusCode != 0 {
+		details["status_code"] = log.StatusCode
+	}
+	if len(log.Metadata) > 0 {
+		details["metadata"] = log.Metadata
+	}
+	return details
+}
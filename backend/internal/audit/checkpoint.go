@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"backend/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoCheckpointSigner 未配置检查点签名密钥
+var ErrNoCheckpointSigner = errors.New("未配置审计检查点签名密钥")
+
+// Checkpoint 对租户链头的定期签名，用于证明该时刻之前的链未被改写
+type Checkpoint struct {
+	TenantID  string    `json:"tenant_id"`
+	Seq       int64     `json:"seq"`
+	EntryHash string    `json:"entry_hash"`
+	KeyID     string    `json:"key_id"`
+	Signature []byte    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// message 签名内容
+func (cp Checkpoint) message() []byte {
+	return []byte("audit-checkpoint:v1\n" + cp.TenantID + "\n" + strconv.FormatInt(cp.Seq, 10) + "\n" +
+		cp.EntryHash + "\n" + cp.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano))
+}
+
+// CheckpointSigner 检查点与归档清单的签名器
+type CheckpointSigner interface {
+	KeyID() string
+	Sign(message []byte) ([]byte, error)
+	Verify(keyID string, message, signature []byte) bool
+}
+
+// Ed25519Signer 基于 Ed25519 的签名器
+type Ed25519Signer struct {
+	keyID      string
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// NewEd25519Signer 由 32 字节种子创建签名器，keyID 为空时取公钥指纹
+func NewEd25519Signer(keyID string, seed []byte) (*Ed25519Signer, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("签名密钥种子长度应为 %d 字节", ed25519.SeedSize)
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	if keyID == "" {
+		sum := sha256.Sum256(publicKey)
+		keyID = hex.EncodeToString(sum[:8])
+	}
+	return &Ed25519Signer{keyID: keyID, privateKey: privateKey, publicKey: publicKey}, nil
+}
+
+// NewEd25519SignerFromEnv 从 AUDIT_SIGNING_KEY（base64 编码的种子）与 AUDIT_SIGNING_KEY_ID 创建签名器，
+// 未配置时返回 nil
+func NewEd25519SignerFromEnv() (*Ed25519Signer, error) {
+	encoded := os.Getenv("AUDIT_SIGNING_KEY")
+	if encoded == "" {
+		return nil, nil
+	}
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("解析 AUDIT_SIGNING_KEY 失败: %w", err)
+	}
+	return NewEd25519Signer(os.Getenv("AUDIT_SIGNING_KEY_ID"), seed)
+}
+
+// KeyID 密钥标识
+func (s *Ed25519Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey 公钥，供离线校验
+func (s *Ed25519Signer) PublicKey() ed25519.PublicKey {
+	return s.publicKey
+}
+
+// Sign 签名
+func (s *Ed25519Signer) Sign(message []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, message), nil
+}
+
+// Verify 校验签名，密钥标识不符视为无效
+func (s *Ed25519Signer) Verify(keyID string, message, signature []byte) bool {
+	return keyID == s.keyID && ed25519.Verify(s.publicKey, message, signature)
+}
+
+// Checkpoint 为租户当前链头生成签名检查点；链为空或自上次检查点以来没有新条目时返回 nil
+func (c *HashChain) Checkpoint(ctx context.Context, tenantID string) (*Checkpoint, error) {
+	if c.signer == nil {
+		return nil, ErrNoCheckpointSigner
+	}
+
+	var seq, lastSeq int64
+	var entryHash string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT h.seq, h.entry_hash,
+			COALESCE((SELECT MAX(seq) FROM audit_chain_checkpoints WHERE tenant_id = h.tenant_id), 0)
+		FROM audit_chain_heads h
+		WHERE h.tenant_id = $1
+	`, tenantID).Scan(&seq, &entryHash, &lastSeq)
+	if err != nil {
+		return nil, fmt.Errorf("读取链头失败: %w", err)
+	}
+	if seq == 0 || seq <= lastSeq {
+		return nil, nil
+	}
+
+	cp := &Checkpoint{
+		TenantID:  tenantID,
+		Seq:       seq,
+		EntryHash: entryHash,
+		KeyID:     c.signer.KeyID(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if cp.Signature, err = c.signer.Sign(cp.message()); err != nil {
+		return nil, fmt.Errorf("签名检查点失败: %w", err)
+	}
+	if _, err := c.db.ExecContext(ctx, `
+		INSERT INTO audit_chain_checkpoints (tenant_id, seq, entry_hash, key_id, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, seq) DO NOTHING
+	`, cp.TenantID, cp.Seq, cp.EntryHash, cp.KeyID, base64.StdEncoding.EncodeToString(cp.Signature), cp.CreatedAt); err != nil {
+		return nil, fmt.Errorf("保存检查点失败: %w", err)
+	}
+	return cp, nil
+}
+
+// CheckpointAll 为所有自上次检查点以来有新条目的租户生成检查点，返回生成数量
+func (c *HashChain) CheckpointAll(ctx context.Context) (int, error) {
+	if c.signer == nil {
+		return 0, ErrNoCheckpointSigner
+	}
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT h.tenant_id FROM audit_chain_heads h
+		WHERE h.seq > COALESCE((SELECT MAX(seq) FROM audit_chain_checkpoints WHERE tenant_id = h.tenant_id), 0)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("查询待签名租户失败: %w", err)
+	}
+	var tenantIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tenantIDs = append(tenantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range tenantIDs {
+		cp, err := c.Checkpoint(ctx, id)
+		if err != nil {
+			return count, err
+		}
+		if cp != nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// StartCheckpointer 按间隔周期性生成检查点
+func (c *HashChain) StartCheckpointer(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := c.CheckpointAll(ctx); err != nil {
+					logger.Warn("生成审计检查点失败", zap.Error(err))
+				} else if n > 0 {
+					logger.Info("已生成审计检查点", zap.Int("count", n))
+				}
+			}
+		}
+	}()
+}
+
+// ListCheckpoints 按序号列出租户的检查点
+func (c *HashChain) ListCheckpoints(ctx context.Context, tenantID string) ([]Checkpoint, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT seq, entry_hash, key_id, signature, created_at
+		FROM audit_chain_checkpoints
+		WHERE tenant_id = $1
+		ORDER BY seq
+	`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("查询检查点失败: %w", err)
+	}
+	defer rows.Close()
+
+	var checkpoints []Checkpoint
+	for rows.Next() {
+		cp := Checkpoint{TenantID: tenantID}
+		var signature string
+		if err := rows.Scan(&cp.Seq, &cp.EntryHash, &cp.KeyID, &signature, &cp.CreatedAt); err != nil {
+			return nil, err
+		}
+		// 无法解码的签名保留为空，校验时判为无效
+		cp.Signature, _ = base64.StdEncoding.DecodeString(signature)
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, rows.Err()
+}
@@ -11,9 +11,21 @@ import (
 
 // ComplianceReportService 合规报告生成服务
 type ComplianceReportService struct {
-	logStore    LogStore
-	userStore   UserStore
-	configStore ConfigStore
+	logStore        LogStore
+	userStore       UserStore
+	configStore     ConfigStore
+	chainVerifier   ChainVerifier
+	archiveVerifier ArchiveVerifier
+}
+
+// ChainVerifier 审计日志哈希链校验（由 HashChain 实现）
+type ChainVerifier interface {
+	VerifyAll(ctx context.Context) ([]ChainVerification, error)
+}
+
+// ArchiveVerifier 归档文件校验（由 Archiver 实现）
+type ArchiveVerifier interface {
+	VerifyArchives() (*ArchiveVerification, error)
 }
 
 // UserStore 用户存储接口
@@ -76,6 +88,16 @@ func NewComplianceReportService(logStore LogStore, userStore UserStore, configSt
 	}
 }
 
+// SetChainVerifier 设置哈希链校验器，设置后报告包含审计日志完整性校验结果
+func (s *ComplianceReportService) SetChainVerifier(v ChainVerifier) {
+	s.chainVerifier = v
+}
+
+// SetArchiveVerifier 设置归档校验器
+func (s *ComplianceReportService) SetArchiveVerifier(v ArchiveVerifier) {
+	s.archiveVerifier = v
+}
+
 // ComplianceReport 合规报告
 type ComplianceReport struct {
 	ReportID      string                `json:"report_id"`
@@ -87,6 +109,7 @@ type ComplianceReport struct {
 	AccessAudit   AccessAuditResult     `json:"access_audit"`
 	DataPrivacy   DataPrivacyResult     `json:"data_privacy"`
 	Incidents     []SecurityIncident    `json:"incidents,omitempty"`
+	Integrity     *AuditIntegrityResult `json:"integrity,omitempty"`
 	Recommendations []string            `json:"recommendations,omitempty"`
 }
 
@@ -163,6 +186,14 @@ type DataPrivacyResult struct {
 	EncryptedInTransit    bool   `json:"encrypted_in_transit"`
 }
 
+// AuditIntegrityResult 审计日志完整性校验结果
+type AuditIntegrityResult struct {
+	Valid    bool                 `json:"valid"`
+	Chains   []ChainVerification  `json:"chains,omitempty"`
+	Archives *ArchiveVerification `json:"archives,omitempty"`
+	Errors   []string             `json:"errors,omitempty"`
+}
+
 // SecurityIncident 安全事件
 type SecurityIncident struct {
 	ID          string    `json:"id"`
@@ -215,12 +246,85 @@ func (s *ComplianceReportService) GenerateReport(ctx context.Context, period Rep
 	incidents, _ := s.getSecurityIncidents(ctx, period)
 	report.Incidents = incidents
 
+	// 审计日志完整性
+	if integrity := s.verifyIntegrity(ctx); integrity != nil {
+		report.Integrity = integrity
+		if !integrity.Valid {
+			s.applyIntegrityFailure(report)
+		}
+	}
+
 	// 生成建议
 	report.Recommendations = s.generateRecommendations(report)
 
 	return report, nil
 }
 
+// verifyIntegrity 校验各租户哈希链与归档清单，未配置校验器时返回 nil
+func (s *ComplianceReportService) verifyIntegrity(ctx context.Context) *AuditIntegrityResult {
+	if s.chainVerifier == nil && s.archiveVerifier == nil {
+		return nil
+	}
+	result := &AuditIntegrityResult{Valid: true}
+	if s.chainVerifier != nil {
+		chains, err := s.chainVerifier.VerifyAll(ctx)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("chain verification: %v", err))
+		}
+		for _, chain := range chains {
+			result.Valid = result.Valid && chain.Valid
+		}
+		result.Chains = chains
+	}
+	if s.archiveVerifier != nil {
+		archives, err := s.archiveVerifier.VerifyArchives()
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("archive verification: %v", err))
+		} else {
+			result.Valid = result.Valid && archives.Valid
+			result.Archives = archives
+		}
+	}
+	// 无法完成校验同样视为未通过
+	if len(result.Errors) > 0 {
+		result.Valid = false
+	}
+	return result
+}
+
+// applyIntegrityFailure 完整性校验未通过时记录发现与安全事件并下调合规分数
+func (s *ComplianceReportService) applyIntegrityFailure(report *ComplianceReport) {
+	for _, chain := range report.Integrity.Chains {
+		if chain.BrokenLink == nil {
+			continue
+		}
+		report.Incidents = append(report.Incidents, SecurityIncident{
+			ID:          fmt.Sprintf("INC_%d", time.Now().UnixNano()),
+			Type:        "audit_log_tampering",
+			Severity:    "high",
+			Description: fmt.Sprintf("Audit hash chain of tenant %s broken at seq %d (%s)", chain.TenantID, chain.BrokenLink.Seq, chain.BrokenLink.Reason),
+			Timestamp:   time.Now(),
+		})
+	}
+	if archives := report.Integrity.Archives; archives != nil && archives.BrokenLink != nil {
+		report.Incidents = append(report.Incidents, SecurityIncident{
+			ID:          fmt.Sprintf("INC_%d", time.Now().UnixNano()),
+			Type:        "audit_archive_tampering",
+			Severity:    "high",
+			Description: fmt.Sprintf("Audit archive %s failed verification (%s)", archives.BrokenLink.File, archives.BrokenLink.Reason),
+			Timestamp:   time.Now(),
+		})
+	}
+	report.SecurityCheck.Findings = append(report.SecurityCheck.Findings, SecurityFinding{
+		Severity:    "high",
+		Category:    "audit_integrity",
+		Description: "Audit log integrity verification failed",
+		Remediation: "Investigate the first broken link and restore affected entries from signed archives",
+	})
+	report.Summary.ComplianceScore = max(report.Summary.ComplianceScore-30, 0)
+	report.Summary.RiskLevel = s.calculateRiskLevel(report.Summary.ComplianceScore)
+}
+
 func (s *ComplianceReportService) generateSummary(ctx context.Context, period ReportPeriod) (*ReportSummary, error) {
 	summary := &ReportSummary{}
 
@@ -476,6 +580,10 @@ func (s *ComplianceReportService) generateRecommendations(report *ComplianceRepo
 		recommendations = append(recommendations, "Investigate and resolve all security incidents")
 	}
 
+	if report.Integrity != nil && !report.Integrity.Valid {
+		recommendations = append(recommendations, "Investigate audit log integrity failures and rotate database credentials")
+	}
+
 	if report.Summary.ComplianceScore < 90 {
 		recommendations = append(recommendations, "Review and strengthen security policies")
 	}
@@ -524,6 +632,29 @@ func (s *ComplianceReportService) exportMarkdown(report *ComplianceReport) ([]by
 		md.WriteString("\n")
 	}
 
+	if report.Integrity != nil {
+		md.WriteString("## Audit Integrity\n\n")
+		md.WriteString(fmt.Sprintf("- **Verified:** %t\n", report.Integrity.Valid))
+		for _, chain := range report.Integrity.Chains {
+			if chain.BrokenLink != nil {
+				md.WriteString(fmt.Sprintf("- Tenant %s: broken at seq %d (%s)\n", chain.TenantID, chain.BrokenLink.Seq, chain.BrokenLink.Reason))
+				continue
+			}
+			md.WriteString(fmt.Sprintf("- Tenant %s: %d entries verified, %d checkpoints\n", chain.TenantID, chain.CheckedEntries, chain.Checkpoints))
+		}
+		if archives := report.Integrity.Archives; archives != nil {
+			if archives.BrokenLink != nil {
+				md.WriteString(fmt.Sprintf("- Archives: %s (%s)\n", archives.BrokenLink.File, archives.BrokenLink.Reason))
+			} else {
+				md.WriteString(fmt.Sprintf("- Archives: %d files verified\n", archives.Files))
+			}
+		}
+		for _, e := range report.Integrity.Errors {
+			md.WriteString(fmt.Sprintf("- Error: %s\n", e))
+		}
+		md.WriteString("\n")
+	}
+
 	if len(report.Recommendations) > 0 {
 		md.WriteString("## Recommendations\n\n")
 		for _, r := range report.Recommendations {
@@ -0,0 +1,414 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"time"
+
+	"backend/internal/infra"
+)
+
+// GenesisHash 每个租户哈希链的起点
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// verifyBatchSize 校验时每批读取的日志条数
+const verifyBatchSize = 1000
+
+// 断链原因
+const (
+	BreakHashMismatch       = "hash_mismatch"        // 条目内容与 entry_hash 不符（被篡改）
+	BreakPrevHashMismatch   = "prev_hash_mismatch"   // prev_hash 与上一条的 entry_hash 不符
+	BreakSequenceGap        = "sequence_gap"         // 序号不连续（中间条目被删除）
+	BreakCheckpointMismatch = "checkpoint_mismatch"  // 检查点记录的哈希与该序号的条目不符
+	BreakCheckpointInvalid  = "checkpoint_signature" // 检查点签名无效
+	BreakHeadMismatch       = "head_mismatch"        // 链尾与链头记录不符（末尾条目被删除）
+)
+
+// ChainEntry 参与哈希计算的审计日志字段
+type ChainEntry struct {
+	ID        string
+	TenantID  string
+	UserID    string
+	Action    string
+	Resource  string
+	Details   []byte // 规范化后的 JSON，见 canonicalDetails
+	CreatedAt time.Time
+	Seq       int64
+	PrevHash  string
+	EntryHash string
+}
+
+// BrokenLink 校验发现的第一个断链位置
+type BrokenLink struct {
+	Seq      int64  `json:"seq"`
+	EntryID  string `json:"entry_id,omitempty"`
+	Reason   string `json:"reason"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// ChainVerification 单个租户哈希链的校验结果
+type ChainVerification struct {
+	TenantID            string      `json:"tenant_id"`
+	Valid               bool        `json:"valid"`
+	CheckedEntries      int64       `json:"checked_entries"`
+	FirstSeq            int64       `json:"first_seq"`
+	LastSeq             int64       `json:"last_seq"`
+	HeadSeq             int64       `json:"head_seq"`
+	Anchor              string      `json:"anchor,omitempty"` // genesis / checkpoint / unanchored（链首已归档且无检查点）
+	Checkpoints         int         `json:"checkpoints"`
+	SignaturesVerified  bool        `json:"signatures_verified"`
+	LatestCheckpointSeq int64       `json:"latest_checkpoint_seq,omitempty"`
+	BrokenLink          *BrokenLink `json:"broken_link,omitempty"`
+	VerifiedAt          time.Time   `json:"verified_at"`
+}
+
+// HashChain 按租户维护审计日志哈希链：每条日志携带序号、上一条的哈希与自身哈希，
+// 链头保存在 audit_chain_heads 并在写入时加行锁，保证同一租户的链严格串行
+type HashChain struct {
+	db     infra.DB
+	signer CheckpointSigner
+}
+
+// NewHashChain 创建哈希链
+func NewHashChain(db infra.DB) *HashChain {
+	return &HashChain{db: db}
+}
+
+// SetSigner 设置检查点签名器，未设置时无法生成检查点，校验时也不验证签名
+func (c *HashChain) SetSigner(signer CheckpointSigner) {
+	c.signer = signer
+}
+
+// txBeginner *sql.DB 支持开启事务；*sql.Tx 不支持，此时视为已处于调用方事务中
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// withTx 在事务中执行 fn
+func (c *HashChain) withTx(ctx context.Context, fn func(db infra.DB) error) error {
+	beginner, ok := c.db.(txBeginner)
+	if !ok {
+		return fn(c.db)
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Append 锁定租户链头，为 entry 分配序号并计算哈希，再由 insert 写入日志行，最后推进链头
+func (c *HashChain) Append(ctx context.Context, entry ChainEntry, insert func(db infra.DB, entry ChainEntry) error) error {
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+	entry.Details = canonicalDetails(entry.Details)
+
+	return c.withTx(ctx, func(db infra.DB) error {
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO audit_chain_heads (tenant_id, seq, entry_hash, updated_at)
+			VALUES ($1, 0, $2, $3)
+			ON CONFLICT (tenant_id) DO NOTHING
+		`, entry.TenantID, GenesisHash, entry.CreatedAt); err != nil {
+			return fmt.Errorf("初始化链头失败: %w", err)
+		}
+
+		var seq int64
+		var prev string
+		if err := db.QueryRowContext(ctx,
+			`SELECT seq, entry_hash FROM audit_chain_heads WHERE tenant_id = $1 FOR UPDATE`,
+			entry.TenantID,
+		).Scan(&seq, &prev); err != nil {
+			return fmt.Errorf("锁定链头失败: %w", err)
+		}
+
+		entry.Seq = seq + 1
+		entry.PrevHash = prev
+		entry.EntryHash = ComputeEntryHash(entry)
+		if err := insert(db, entry); err != nil {
+			return err
+		}
+
+		_, err := db.ExecContext(ctx,
+			`UPDATE audit_chain_heads SET seq = $2, entry_hash = $3, updated_at = $4 WHERE tenant_id = $1`,
+			entry.TenantID, entry.Seq, entry.EntryHash, entry.CreatedAt,
+		)
+		return err
+	})
+}
+
+// ComputeEntryHash 计算条目哈希：SHA-256 覆盖带长度前缀的各字段与上一条哈希
+func ComputeEntryHash(e ChainEntry) string {
+	h := sha256.New()
+	writeHashField(h, "audit-chain:v1")
+	writeHashField(h, e.TenantID)
+	writeHashField(h, strconv.FormatInt(e.Seq, 10))
+	writeHashField(h, e.ID)
+	writeHashField(h, e.UserID)
+	writeHashField(h, e.Action)
+	writeHashField(h, e.Resource)
+	writeHashField(h, string(e.Details))
+	writeHashField(h, e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano))
+	writeHashField(h, e.PrevHash)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashField 写入长度前缀与内容，避免字段拼接产生歧义
+func writeHashField(h hash.Hash, s string) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(s)))
+	h.Write(size[:])
+	h.Write([]byte(s))
+}
+
+// canonicalDetails 将 details 规范化为键有序的紧凑 JSON，使其经过 JSONB 存取后哈希不变
+func canonicalDetails(raw []byte) []byte {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil || v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Verify 按序号遍历租户的全部链上日志，重新计算哈希并核对检查点与链头，返回第一个断链位置
+func (c *HashChain) Verify(ctx context.Context, tenantID string) (*ChainVerification, error) {
+	var headSeq int64
+	var headHash string
+	err := c.db.QueryRowContext(ctx,
+		`SELECT seq, entry_hash FROM audit_chain_heads WHERE tenant_id = $1`, tenantID,
+	).Scan(&headSeq, &headHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("读取链头失败: %w", err)
+	}
+
+	checkpoints, err := c.ListCheckpoints(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	v := newChainVerifier(tenantID, checkpoints, c.signer)
+	v.begin()
+	after := int64(0)
+	for !v.broken() {
+		entries, err := c.loadEntries(ctx, tenantID, after, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !v.add(entry) {
+				break
+			}
+		}
+		if len(entries) < verifyBatchSize {
+			break
+		}
+		after = entries[len(entries)-1].Seq
+	}
+	return v.finish(headSeq, headHash), nil
+}
+
+// VerifyAll 校验所有已建链租户
+func (c *HashChain) VerifyAll(ctx context.Context) ([]ChainVerification, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT tenant_id FROM audit_chain_heads ORDER BY tenant_id`)
+	if err != nil {
+		return nil, fmt.Errorf("查询链头失败: %w", err)
+	}
+	var tenantIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tenantIDs = append(tenantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]ChainVerification, 0, len(tenantIDs))
+	for _, id := range tenantIDs {
+		result, err := c.Verify(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("校验租户 %s 失败: %w", id, err)
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// loadEntries 按序号读取 after 之后的一批链上日志
+func (c *HashChain) loadEntries(ctx context.Context, tenantID string, after int64, limit int) ([]ChainEntry, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, user_id, action, resource, details, created_at, chain_seq, prev_hash, entry_hash
+		FROM audit_logs
+		WHERE tenant_id = $1 AND chain_seq IS NOT NULL AND chain_seq > $2
+		ORDER BY chain_seq
+		LIMIT $3
+	`, tenantID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("读取审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []ChainEntry
+	for rows.Next() {
+		var (
+			e       = ChainEntry{TenantID: tenantID}
+			userID  sql.NullString
+			details []byte
+		)
+		if err := rows.Scan(&e.ID, &userID, &e.Action, &e.Resource, &details, &e.CreatedAt, &e.Seq, &e.PrevHash, &e.EntryHash); err != nil {
+			return nil, err
+		}
+		e.UserID = userID.String
+		e.Details = canonicalDetails(details)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// chainVerifier 逐条校验哈希链，独立于存储以便复用与测试
+type chainVerifier struct {
+	result      *ChainVerification
+	checkpoints map[int64]Checkpoint
+	ordered     []Checkpoint
+	signer      CheckpointSigner
+	prev        *ChainEntry
+}
+
+func newChainVerifier(tenantID string, checkpoints []Checkpoint, signer CheckpointSigner) *chainVerifier {
+	v := &chainVerifier{
+		result: &ChainVerification{
+			TenantID:           tenantID,
+			Valid:              true,
+			Checkpoints:        len(checkpoints),
+			SignaturesVerified: signer != nil,
+		},
+		checkpoints: make(map[int64]Checkpoint, len(checkpoints)),
+		ordered:     checkpoints,
+		signer:      signer,
+	}
+	for _, cp := range checkpoints {
+		v.checkpoints[cp.Seq] = cp
+		v.result.LatestCheckpointSeq = max(v.result.LatestCheckpointSeq, cp.Seq)
+	}
+	return v
+}
+
+func (v *chainVerifier) broken() bool {
+	return v.result.BrokenLink != nil
+}
+
+func (v *chainVerifier) fail(link BrokenLink) bool {
+	if v.result.BrokenLink == nil {
+		v.result.BrokenLink = &link
+		v.result.Valid = false
+	}
+	return false
+}
+
+// begin 遍历前先校验全部检查点签名；检查点哈希在遍历到对应序号时核对
+func (v *chainVerifier) begin() {
+	if v.signer == nil {
+		return
+	}
+	for _, cp := range v.ordered {
+		if !v.signer.Verify(cp.KeyID, cp.message(), cp.Signature) {
+			v.fail(BrokenLink{Seq: cp.Seq, Reason: BreakCheckpointInvalid, Actual: cp.EntryHash})
+			return
+		}
+	}
+}
+
+// add 校验一条日志，发现断链时返回 false
+func (v *chainVerifier) add(e ChainEntry) bool {
+	if v.broken() {
+		return false
+	}
+	if v.prev == nil {
+		v.result.FirstSeq = e.Seq
+		switch cp, ok := v.checkpoints[e.Seq-1]; {
+		case e.Seq == 1:
+			v.result.Anchor = "genesis"
+			if e.PrevHash != GenesisHash {
+				return v.fail(BrokenLink{Seq: e.Seq, EntryID: e.ID, Reason: BreakPrevHashMismatch, Expected: GenesisHash, Actual: e.PrevHash})
+			}
+		case ok:
+			// 之前的条目已归档删除，由检查点锚定链首
+			v.result.Anchor = "checkpoint"
+			if e.PrevHash != cp.EntryHash {
+				return v.fail(BrokenLink{Seq: e.Seq, EntryID: e.ID, Reason: BreakPrevHashMismatch, Expected: cp.EntryHash, Actual: e.PrevHash})
+			}
+		default:
+			v.result.Anchor = "unanchored"
+		}
+	} else {
+		if e.Seq != v.prev.Seq+1 {
+			return v.fail(BrokenLink{Seq: v.prev.Seq + 1, Reason: BreakSequenceGap, Expected: strconv.FormatInt(v.prev.Seq+1, 10), Actual: strconv.FormatInt(e.Seq, 10)})
+		}
+		if e.PrevHash != v.prev.EntryHash {
+			return v.fail(BrokenLink{Seq: e.Seq, EntryID: e.ID, Reason: BreakPrevHashMismatch, Expected: v.prev.EntryHash, Actual: e.PrevHash})
+		}
+	}
+
+	if computed := ComputeEntryHash(e); computed != e.EntryHash {
+		return v.fail(BrokenLink{Seq: e.Seq, EntryID: e.ID, Reason: BreakHashMismatch, Expected: computed, Actual: e.EntryHash})
+	}
+	if cp, ok := v.checkpoints[e.Seq]; ok && cp.EntryHash != e.EntryHash {
+		return v.fail(BrokenLink{Seq: e.Seq, EntryID: e.ID, Reason: BreakCheckpointMismatch, Expected: cp.EntryHash, Actual: e.EntryHash})
+	}
+
+	v.prev = &e
+	v.result.CheckedEntries++
+	v.result.LastSeq = e.Seq
+	return true
+}
+
+// finish 核对链尾与链头、最新检查点，检测末尾条目被删除的情况
+func (v *chainVerifier) finish(headSeq int64, headHash string) *ChainVerification {
+	v.result.HeadSeq = headSeq
+	v.result.VerifiedAt = time.Now()
+	if v.broken() {
+		return v.result
+	}
+	lastSeq, lastHash := int64(0), GenesisHash
+	if v.prev != nil {
+		lastSeq, lastHash = v.prev.Seq, v.prev.EntryHash
+	}
+	if v.result.LatestCheckpointSeq > lastSeq {
+		v.fail(BrokenLink{Seq: lastSeq + 1, Reason: BreakSequenceGap, Expected: strconv.FormatInt(v.result.LatestCheckpointSeq, 10), Actual: strconv.FormatInt(lastSeq, 10)})
+		return v.result
+	}
+	// 链头不存在（从未写入）时没有可比对的对象
+	if headSeq == 0 && headHash == "" {
+		return v.result
+	}
+	// 全部条目已归档时只剩链头
+	if v.prev == nil && headSeq > 0 {
+		return v.result
+	}
+	if headSeq != lastSeq || headHash != lastHash {
+		v.fail(BrokenLink{Seq: lastSeq + 1, Reason: BreakHeadMismatch, Expected: headHash, Actual: lastHash})
+	}
+	return v.result
+}
@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"backend/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildChain 构造 n 条首尾相接的链上日志
+func buildChain(t *testing.T, n int) []ChainEntry {
+	t.Helper()
+	entries := make([]ChainEntry, 0, n)
+	prev := GenesisHash
+	base := time.Date(2026, 1, 1, 8, 0, 0, 123456789, time.UTC)
+	for i := 1; i <= n; i++ {
+		e := ChainEntry{
+			ID:        "log-" + strconv.Itoa(i),
+			TenantID:  "tenant-a",
+			UserID:    "user-1",
+			Action:    "document.update",
+			Resource:  "document",
+			Details:   canonicalDetails([]byte(`{"b": 2, "a": "x"}`)),
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			Seq:       int64(i),
+			PrevHash:  prev,
+		}
+		e.EntryHash = ComputeEntryHash(e)
+		prev = e.EntryHash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func verifyEntries(entries []ChainEntry, checkpoints []Checkpoint, signer CheckpointSigner, headSeq int64, headHash string) *ChainVerification {
+	v := newChainVerifier("tenant-a", checkpoints, signer)
+	v.begin()
+	for _, e := range entries {
+		if !v.add(e) {
+			break
+		}
+	}
+	return v.finish(headSeq, headHash)
+}
+
+func signCheckpoint(t *testing.T, signer CheckpointSigner, e ChainEntry) Checkpoint {
+	t.Helper()
+	cp := Checkpoint{TenantID: e.TenantID, Seq: e.Seq, EntryHash: e.EntryHash, KeyID: signer.KeyID(), CreatedAt: time.Now()}
+	sig, err := signer.Sign(cp.message())
+	require.NoError(t, err)
+	cp.Signature = sig
+	return cp
+}
+
+func TestCanonicalDetailsStableAcrossJSONB(t *testing.T) {
+	// JSONB 会重排键并去掉空白
+	require.Equal(t, canonicalDetails([]byte(`{"b": 2, "a": [1, {"y":1,"x":2}]}`)), canonicalDetails([]byte(`{"a":[1,{"x":2,"y":1}],"b":2}`)))
+	require.Nil(t, canonicalDetails([]byte("null")))
+}
+
+func TestChainVerifier(t *testing.T) {
+	signer, err := NewEd25519Signer("", make([]byte, 32))
+	require.NoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		cps := []Checkpoint{signCheckpoint(t, signer, entries[2])}
+		result := verifyEntries(entries, cps, signer, 5, entries[4].EntryHash)
+		require.True(t, result.Valid)
+		require.Equal(t, "genesis", result.Anchor)
+		require.EqualValues(t, 5, result.CheckedEntries)
+		require.True(t, result.SignaturesVerified)
+	})
+
+	t.Run("tampered content", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		entries[2].Action = "document.read"
+		result := verifyEntries(entries, nil, nil, 5, entries[4].EntryHash)
+		require.False(t, result.Valid)
+		require.Equal(t, BrokenLink{Seq: 3, EntryID: "log-3", Reason: BreakHashMismatch, Expected: ComputeEntryHash(entries[2]), Actual: entries[2].EntryHash}, *result.BrokenLink)
+	})
+
+	t.Run("rehashed tail is caught by checkpoint", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		cps := []Checkpoint{signCheckpoint(t, signer, entries[3])}
+		// 篡改者重算了第 3 条之后的全部哈希
+		entries[2].Action = "document.read"
+		for i := 2; i < len(entries); i++ {
+			entries[i].PrevHash = entries[i-1].EntryHash
+			entries[i].EntryHash = ComputeEntryHash(entries[i])
+		}
+		result := verifyEntries(entries, cps, signer, 5, entries[4].EntryHash)
+		require.False(t, result.Valid)
+		require.Equal(t, int64(4), result.BrokenLink.Seq)
+		require.Equal(t, BreakCheckpointMismatch, result.BrokenLink.Reason)
+	})
+
+	t.Run("deleted entry", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		entries = append(entries[:2], entries[3:]...)
+		result := verifyEntries(entries, nil, nil, 5, entries[3].EntryHash)
+		require.False(t, result.Valid)
+		require.Equal(t, int64(3), result.BrokenLink.Seq)
+		require.Equal(t, BreakSequenceGap, result.BrokenLink.Reason)
+	})
+
+	t.Run("truncated tail", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		result := verifyEntries(entries[:4], nil, nil, 5, entries[4].EntryHash)
+		require.False(t, result.Valid)
+		require.Equal(t, BreakHeadMismatch, result.BrokenLink.Reason)
+		require.Equal(t, int64(5), result.BrokenLink.Seq)
+	})
+
+	t.Run("forged checkpoint signature", func(t *testing.T) {
+		entries := buildChain(t, 3)
+		cp := signCheckpoint(t, signer, entries[1])
+		cp.EntryHash = entries[0].EntryHash
+		result := verifyEntries(entries, []Checkpoint{cp}, signer, 3, entries[2].EntryHash)
+		require.False(t, result.Valid)
+		require.Equal(t, BreakCheckpointInvalid, result.BrokenLink.Reason)
+	})
+
+	t.Run("archived prefix anchored by checkpoint", func(t *testing.T) {
+		entries := buildChain(t, 5)
+		cps := []Checkpoint{signCheckpoint(t, signer, entries[1])}
+		result := verifyEntries(entries[2:], cps, signer, 5, entries[4].EntryHash)
+		require.True(t, result.Valid)
+		require.Equal(t, "checkpoint", result.Anchor)
+		require.EqualValues(t, 3, result.FirstSeq)
+	})
+}
+
+func TestArchiveManifestVerification(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	signer, err := NewEd25519Signer("k1", make([]byte, 32))
+	require.NoError(t, err)
+
+	month := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	store := &memoryLogStore{logs: []AuditLog{
+		{ID: "a", Timestamp: month.Add(time.Hour), Action: "login"},
+		{ID: "b", Timestamp: month.AddDate(0, 1, 0).Add(time.Hour), Action: "login"},
+	}}
+	archiver := NewArchiver(store, ArchiveConfig{ArchivePath: dir, RetentionDays: 1})
+	archiver.SetSigner(signer)
+
+	result, err := archiver.Archive(ctx)
+	require.NoError(t, err)
+	require.Empty(t, result.Errors)
+	require.Len(t, result.ArchivedFiles, 2)
+
+	verification, err := archiver.VerifyArchives()
+	require.NoError(t, err)
+	require.True(t, verification.Valid)
+	require.True(t, verification.SignatureVerified)
+	require.Equal(t, 2, verification.Files)
+
+	// 改写归档文件
+	target := filepath.Join(dir, "2025", "audit_2025-03.json.gz")
+	require.NoError(t, os.WriteFile(target, []byte("forged"), 0644))
+	verification, err = archiver.VerifyArchives()
+	require.NoError(t, err)
+	require.False(t, verification.Valid)
+	require.Equal(t, "2025/audit_2025-03.json.gz", verification.BrokenLink.File)
+	require.Equal(t, ArchiveBreakDigestMismatch, verification.BrokenLink.Reason)
+
+	// 使用其他密钥重签的清单
+	other, err := NewEd25519Signer("k1", []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+	forger := NewArchiver(store, ArchiveConfig{ArchivePath: dir})
+	forger.SetSigner(other)
+	require.NoError(t, forger.appendManifest(ArchiveManifestEntry{File: "2025/audit_2025-03.json.gz", Removed: true, CreatedAt: time.Now()}))
+	verification, err = archiver.VerifyArchives()
+	require.NoError(t, err)
+	require.Equal(t, ArchiveBreakSignature, verification.BrokenLink.Reason)
+}
+
+// memoryLogStore 内存日志存储
+type memoryLogStore struct {
+	logs []AuditLog
+}
+
+func (s *memoryLogStore) QueryLogs(_ context.Context, filter LogFilter) ([]AuditLog, error) {
+	var logs []AuditLog
+	for _, log := range s.logs {
+		if !log.Timestamp.Before(*filter.StartTime) && log.Timestamp.Before(*filter.EndTime) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (s *memoryLogStore) DeleteLogs(_ context.Context, before time.Time) (int64, error) {
+	var kept []AuditLog
+	for _, log := range s.logs {
+		if !log.Timestamp.Before(before) {
+			kept = append(kept, log)
+		}
+	}
+	deleted := int64(len(s.logs) - len(kept))
+	s.logs = kept
+	return deleted, nil
+}
+
+func (s *memoryLogStore) GetOldestLog(_ context.Context) (*AuditLog, error) {
+	if len(s.logs) == 0 {
+		return nil, nil
+	}
+	return &s.logs[0], nil
+}
+
+func TestChainedLogDetailsCoverRequestFields(t *testing.T) {
+	log := &models.AuditLog{
+		EventCategory: "general",
+		EventLevel:    "info",
+		IPAddress:     "203.0.113.7",
+		RequestPath:   "/api/workflows/1",
+		RequestMethod: "DELETE",
+		StatusCode:    200,
+		Metadata:      map[string]interface{}{"duration": 12},
+	}
+	hashOf := func(log *models.AuditLog) string {
+		details, err := json.Marshal(chainedLogDetails(log))
+		require.NoError(t, err)
+		return ComputeEntryHash(ChainEntry{TenantID: "tenant-a", Seq: 1, ID: "log-1", Action: "workflow.delete", Details: canonicalDetails(details), PrevHash: GenesisHash})
+	}
+
+	original := hashOf(log)
+	// 请求信息不在 ChainEntry 的独立字段中，改写后同样会导致哈希变化
+	log.IPAddress = "198.51.100.1"
+	require.NotEqual(t, original, hashOf(log))
+	log.IPAddress = "203.0.113.7"
+	log.Metadata["duration"] = 13
+	require.NotEqual(t, original, hashOf(log))
+}
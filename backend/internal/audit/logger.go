@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"backend/internal/infra"
@@ -14,17 +15,24 @@ import (
 //
 // 该实现依赖 TenantContext 中的 tenantId/userId 来保证多租户隔离，
 // 不会在接口层返回错误，写入失败时静默忽略（可以根据项目日志系统扩展）。
+// 每条日志都挂接到所属租户的哈希链上，见 HashChain。
 type DBAuditLogger struct {
-	db  infra.DB
-	ids tenant.IDGenerator
+	db    infra.DB
+	ids   tenant.IDGenerator
+	chain *HashChain
 }
 
 // NewDBAuditLogger 创建一个基于 DB 的审计日志记录器。
 func NewDBAuditLogger(db infra.DB, ids tenant.IDGenerator) *DBAuditLogger {
-	return &DBAuditLogger{db: db, ids: ids}
+	return &DBAuditLogger{db: db, ids: ids, chain: NewHashChain(db)}
 }
 
-// LogAction 实现 tenant.AuditLogger 接口，将操作写入 audit_logs 表。
+// Chain 返回审计日志所在的哈希链，用于生成检查点与校验。
+func (l *DBAuditLogger) Chain() *HashChain {
+	return l.chain
+}
+
+// LogAction 实现 tenant.AuditLogger 接口，将操作写入 audit_logs 表并推进租户哈希链。
 func (l *DBAuditLogger) LogAction(ctx context.Context, tc tenant.TenantContext, action, resource string, details any) {
 	if ctx == nil || tc.TenantID == "" {
 		return
@@ -43,20 +51,45 @@ func (l *DBAuditLogger) LogAction(ctx context.Context, tc tenant.TenantContext,
 	}
 
 	const q = `
-		INSERT INTO audit_logs (id, tenant_id, user_id, action, resource, details, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO audit_logs (id, tenant_id, user_id, event_type, event_category, action, resource, details, created_at, chain_seq, prev_hash, entry_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 	`
 
+	entry := ChainEntry{
+		ID:        id,
+		TenantID:  tc.TenantID,
+		UserID:    tc.UserID,
+		Action:    action,
+		Resource:  resource,
+		Details:   detailsJSON,
+		CreatedAt: time.Now(),
+	}
 	// 写入失败时不向上抛错，避免业务流程因审计失败而中断。
-	_, _ = l.db.ExecContext(ctx, q,
-		id,
-		tc.TenantID,
-		nullableString(tc.UserID),
-		action,
-		resource,
-		jsonOrNull(detailsJSON),
-		time.Now().UTC(),
-	)
+	_ = l.chain.Append(ctx, entry, func(db infra.DB, e ChainEntry) error {
+		_, err := db.ExecContext(ctx, q,
+			e.ID,
+			e.TenantID,
+			nullableString(e.UserID),
+			e.Action,
+			eventCategory(e.Action),
+			e.Action,
+			e.Resource,
+			jsonOrNull(e.Details),
+			e.CreatedAt,
+			e.Seq,
+			e.PrevHash,
+			e.EntryHash,
+		)
+		return err
+	})
+}
+
+// eventCategory 取 action 的前缀（如 security.xxx 归入 security）作为事件分类。
+func eventCategory(action string) string {
+	if i := strings.IndexByte(action, '.'); i > 0 {
+		return action[:i]
+	}
+	return "tenant"
 }
 
 // SecurityEventLogger 用于记录安全相关事件（如跨租户访问尝试等）。
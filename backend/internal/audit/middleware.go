@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"context"
 	"time"
 
 	"backend/internal/auth"
@@ -58,9 +59,10 @@ func AuditMiddleware(auditService *models.AuditLogService) gin.HandlerFunc {
 
 		log.Metadata = metadata
 
-		// 异步写入审计日志（不阻塞请求）
+		// 异步写入审计日志（不阻塞请求）；请求结束后上下文即被取消，写入（含哈希链事务）不随之中断
+		ctx := context.WithoutCancel(c.Request.Context())
 		go func() {
-			_ = auditService.CreateLog(c.Request.Context(), log)
+			_ = auditService.CreateLog(ctx, log)
 		}()
 	}
 }
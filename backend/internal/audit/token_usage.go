@@ -6,13 +6,13 @@ import "time"
 type TokenUsage struct {
 	ID            string    `json:"id" gorm:"primaryKey;type:uuid"`
 	TenantID      string    `json:"tenantId" gorm:"type:uuid;not null;index"`
-	UserID        string    `json:"userId" gorm:"type:uuid;index"`
+	UserID        string    `json:"userId" gorm:"size:36;index"`
 	
-	// 上下文关联
-	WorkflowID    string    `json:"workflowId" gorm:"type:uuid;index"`
-	ExecutionID   string    `json:"executionId" gorm:"type:uuid;index"`
+	// 上下文关联（可为空，不使用 uuid 类型）
+	WorkflowID    string    `json:"workflowId" gorm:"size:36;index"`
+	ExecutionID   string    `json:"executionId" gorm:"size:36;index"`
 	StepID        string    `json:"stepId" gorm:"size:100"`
-	AgentID       string    `json:"agentId" gorm:"type:uuid"`
+	AgentID       string    `json:"agentId" gorm:"size:100"`
 	
 	// 模型信息
 	Model         string    `json:"model" gorm:"size:100;not null"`
@@ -0,0 +1,220 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/ai"
+	"backend/internal/audit"
+	"backend/internal/credits"
+	"backend/internal/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// 计量默认值
+const (
+	defaultMeterOutputTokens = 2048             // 请求未设置 max_tokens 时预估的输出 Token
+	defaultMeterHoldTTL      = 30 * time.Minute // 预授权有效期
+)
+
+// MeterOptions 计量配置
+type MeterOptions struct {
+	DefaultOutputTokens int           // 请求未设置 max_tokens 时预估的输出 Token
+	HoldTTL             time.Duration // 预授权有效期，超时未结算由清理任务释放
+}
+
+// Meter 模型调用积分计量器（实现 ai.CallMeter）
+// 调用前按 EstimateCost 冻结预估积分，调用后按实际 Usage 与 credits.CalculateCost 结算并退回差额；
+// 调用归属中没有用户的调用（系统任务、未写入归属的调用）从租户公共账户扣费，见 billedUserID
+type Meter struct {
+	billing *Service
+	credits *credits.Service
+	tokens  audit.AuditService // 每次调用的 Token 用量记录，结算流水关联其 ID（可选）
+	opts    MeterOptions
+}
+
+// NewMeter 创建计量器
+func NewMeter(billing *Service, creditsService *credits.Service, tokens audit.AuditService, opts MeterOptions) *Meter {
+	if opts.DefaultOutputTokens <= 0 {
+		opts.DefaultOutputTokens = defaultMeterOutputTokens
+	}
+	if opts.HoldTTL <= 0 {
+		opts.HoldTTL = defaultMeterHoldTTL
+	}
+	return &Meter{billing: billing, credits: creditsService, tokens: tokens, opts: opts}
+}
+
+// Authorize 预估本次调用的积分并冻结，可用余额不足时拒绝调用
+func (m *Meter) Authorize(ctx context.Context, call *ai.MeteredCall) (string, error) {
+	scope := callScope(call)
+
+	inputTokens := 0
+	for _, msg := range call.Messages {
+		inputTokens += ai.EstimateTokens(msg.Content)
+	}
+	for _, text := range call.EmbedInputs {
+		inputTokens += ai.EstimateTokens(text)
+	}
+	outputTokens := 0
+	if len(call.EmbedInputs) == 0 {
+		outputTokens = call.MaxTokens
+		if outputTokens <= 0 {
+			outputTokens = m.opts.DefaultOutputTokens
+		}
+	}
+
+	estimate, err := m.billing.EstimateCost(ctx, &CostEstimateRequest{
+		TenantID:     call.TenantID,
+		Provider:     call.Provider,
+		Model:        call.Model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	amount := estimate.TotalCost
+	if amount < 1 {
+		amount = 1
+	}
+
+	hold, err := m.credits.Hold(ctx, &credits.HoldRequest{
+		TenantID:   call.TenantID,
+		UserID:     billedUserID(call),
+		Amount:     amount,
+		Model:      call.Model,
+		WorkflowID: scope.WorkflowID,
+		AgentID:    scope.AgentID,
+		TTL:        m.opts.HoldTTL,
+	})
+	if err != nil {
+		if errors.Is(err, credits.ErrInsufficientCredits) {
+			return "", fmt.Errorf("%w: %w（预估需要 %d 积分）", ai.ErrCallRefused, err, amount)
+		}
+		return "", fmt.Errorf("积分预授权失败: %w", err)
+	}
+	return hold.ID, nil
+}
+
+// Settle 按实际用量结算预授权，并记录关联的 Token 用量
+// 调用耗时超过预授权有效期时预授权已被清理任务释放，此时直接从账户扣除实际积分
+func (m *Meter) Settle(ctx context.Context, holdID string, call *ai.MeteredCall, usage ai.Usage) {
+	if holdID == "" {
+		return
+	}
+	scope := callScope(call)
+
+	amount, err := m.credits.CalculateCost(ctx, call.TenantID, call.Provider, call.Model, usage.PromptTokens, usage.CompletionTokens)
+	if err != nil {
+		logger.Error("计算实际积分失败", zap.String("hold_id", holdID), zap.Error(err))
+		m.Release(ctx, holdID, call, "计算实际积分失败")
+		return
+	}
+
+	tokenUsageID := ""
+	if m.tokens != nil {
+		record := &audit.TokenUsage{
+			ID:               uuid.New().String(),
+			TenantID:         call.TenantID,
+			UserID:           scope.UserID,
+			WorkflowID:       scope.WorkflowID,
+			ExecutionID:      scope.ExecutionID,
+			StepID:           scope.StepID,
+			AgentID:          scope.AgentID,
+			Model:            call.Model,
+			Provider:         call.Provider,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			TotalTokens:      usage.TotalTokens,
+		}
+		if err := m.tokens.RecordTokenUsage(record); err != nil {
+			logger.Warn("记录 Token 用量失败", zap.String("hold_id", holdID), zap.Error(err))
+		} else {
+			tokenUsageID = record.ID
+			scope.MarkMetered()
+		}
+	}
+
+	_, err = m.credits.SettleHold(ctx, holdID, &credits.SettleRequest{
+		Amount:       amount,
+		TokenUsageID: tokenUsageID,
+		WorkflowID:   scope.WorkflowID,
+		AgentID:      scope.AgentID,
+		Model:        call.Model,
+	})
+	if errors.Is(err, credits.ErrHoldClosed) {
+		_, err = m.credits.Consume(ctx, &credits.ConsumeRequest{
+			TenantID:     call.TenantID,
+			UserID:       billedUserID(call),
+			Amount:       amount,
+			TokenUsageID: tokenUsageID,
+			WorkflowID:   scope.WorkflowID,
+			AgentID:      scope.AgentID,
+			Model:        call.Model,
+			Description:  fmt.Sprintf("AI调用消耗 %d 积分 (%s)，预授权已过期", amount, call.Model),
+		})
+	}
+	if err != nil {
+		logger.Error("积分结算失败", zap.String("hold_id", holdID), zap.Int64("amount", amount), zap.Error(err))
+	}
+}
+
+// Release 释放预授权
+func (m *Meter) Release(ctx context.Context, holdID string, call *ai.MeteredCall, reason string) {
+	if holdID == "" {
+		return
+	}
+	if runes := []rune(reason); len(runes) > 500 {
+		reason = string(runes[:500])
+	}
+	if err := m.credits.ReleaseHold(ctx, holdID, reason); err != nil {
+		logger.Warn("释放积分预授权失败", zap.String("hold_id", holdID), zap.Error(err))
+	}
+}
+
+// callScope 调用归属，未写入时视为租户级调用
+func callScope(call *ai.MeteredCall) *ai.CallScope {
+	if call.Scope != nil {
+		return call.Scope
+	}
+	return &ai.CallScope{TenantID: call.TenantID}
+}
+
+// billedUserID 扣费账户的用户 ID：有用户的调用从用户账户扣费，
+// 否则从租户公共账户（以租户 ID 作为 user_id 的积分账户）扣费，该账户由管理员按租户充值
+func billedUserID(call *ai.MeteredCall) string {
+	if call.Scope != nil && call.Scope.UserID != "" {
+		return call.Scope.UserID
+	}
+	return call.TenantID
+}
+
+// StartHoldSweeper 定时释放超时未结算的预授权（进程在调用中途退出时遗留）
+func (m *Meter) StartHoldSweeper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				released, err := m.credits.ReleaseExpiredHolds(ctx)
+				if err != nil {
+					logger.Warn("释放超时预授权失败", zap.Error(err))
+					continue
+				}
+				if released > 0 {
+					logger.Info("已释放超时预授权", zap.Int("count", released))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
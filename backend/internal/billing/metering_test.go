@@ -0,0 +1,107 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/ai"
+	"backend/internal/credits"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+	testUserID   = "22222222-2222-2222-2222-222222222222"
+)
+
+func newTestMeter(t *testing.T) (*Meter, *credits.Service, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&credits.CreditAccount{}, &credits.CreditTransaction{}, &credits.CreditHold{}, &credits.CreditPricing{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	creditsService := credits.NewService(db)
+	return NewMeter(NewService(db, creditsService, nil), creditsService, nil, MeterOptions{}), creditsService, db
+}
+
+func testCall(scope *ai.CallScope) *ai.MeteredCall {
+	return &ai.MeteredCall{
+		TenantID:  testTenantID,
+		Provider:  "openai",
+		Model:     "gpt-4o",
+		Scope:     scope,
+		Messages:  []ai.Message{{Role: "user", Content: "hello"}},
+		MaxTokens: 1000,
+	}
+}
+
+func TestMeterChargesTenantAccountWithoutUser(t *testing.T) {
+	meter, creditsService, _ := newTestMeter(t)
+	ctx := context.Background()
+
+	// 租户公共账户没有余额时拒绝调用，而不是免费放行
+	call := testCall(&ai.CallScope{TenantID: testTenantID})
+	if _, err := meter.Authorize(ctx, call); !errors.Is(err, ai.ErrCallRefused) {
+		t.Fatalf("expected ErrCallRefused, got %v", err)
+	}
+	if _, err := meter.Authorize(ctx, testCall(nil)); !errors.Is(err, ai.ErrCallRefused) {
+		t.Fatalf("expected ErrCallRefused without scope, got %v", err)
+	}
+
+	if _, err := creditsService.Recharge(ctx, &credits.RechargeRequest{TenantID: testTenantID, UserID: testTenantID, Amount: 100}); err != nil {
+		t.Fatalf("Recharge: %v", err)
+	}
+	holdID, err := meter.Authorize(ctx, call)
+	if err != nil || holdID == "" {
+		t.Fatalf("Authorize: hold=%q err=%v", holdID, err)
+	}
+	meter.Settle(ctx, holdID, call, ai.Usage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000})
+
+	account, err := creditsService.GetAccount(ctx, testTenantID, testTenantID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if account.Balance != 97 || account.FreezeAmount != 0 {
+		t.Fatalf("expected balance 97 freeze 0, got %d/%d", account.Balance, account.FreezeAmount)
+	}
+}
+
+func TestMeterSettlesExpiredHold(t *testing.T) {
+	meter, creditsService, db := newTestMeter(t)
+	ctx := context.Background()
+	if _, err := creditsService.Recharge(ctx, &credits.RechargeRequest{TenantID: testTenantID, UserID: testUserID, Amount: 100}); err != nil {
+		t.Fatalf("Recharge: %v", err)
+	}
+
+	call := testCall(&ai.CallScope{TenantID: testTenantID, UserID: testUserID})
+	holdID, err := meter.Authorize(ctx, call)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	// 长时间调用期间预授权过期并被清理任务释放
+	if err := db.Model(&credits.CreditHold{}).Where("id = ?", holdID).
+		Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("expire hold: %v", err)
+	}
+	if released, err := creditsService.ReleaseExpiredHolds(ctx); err != nil || released != 1 {
+		t.Fatalf("ReleaseExpiredHolds: released=%d err=%v", released, err)
+	}
+
+	meter.Settle(ctx, holdID, call, ai.Usage{PromptTokens: 1000, CompletionTokens: 1000, TotalTokens: 2000})
+
+	account, err := creditsService.GetAccount(ctx, testTenantID, testUserID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if account.Balance != 97 || account.FreezeAmount != 0 {
+		t.Fatalf("expected balance 97 freeze 0, got %d/%d", account.Balance, account.FreezeAmount)
+	}
+}
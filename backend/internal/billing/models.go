@@ -100,6 +100,7 @@ type CostReport struct {
 	ByProvider       []ProviderCostItem   `json:"byProvider"`
 	ByUser           []UserCostItem       `json:"byUser"`
 	DailyTrend       []DailyCostItem      `json:"dailyTrend"`
+	Routing          *RoutingCostSummary  `json:"routing,omitempty"` // Agent 模型路由汇总
 	GeneratedAt      time.Time            `json:"generatedAt"`
 }
 
@@ -150,7 +151,7 @@ type CostAlert struct {
 	AlertType     string     `json:"alertType" gorm:"size:50;not null"`     // daily, weekly, monthly, threshold
 	Threshold     float64    `json:"threshold" gorm:"type:decimal(10,2)"`   // 成本阈值
 	CurrentValue  float64    `json:"currentValue" gorm:"type:decimal(10,2)"`
-	UserID        string     `json:"userId" gorm:"type:uuid"`               // 针对特定用户
+	UserID        *string    `json:"userId" gorm:"type:uuid"`               // 针对特定用户，为空（NULL）表示不限用户
 	ModelName     string     `json:"modelName" gorm:"size:100"`             // 针对特定模型
 	NotifyEmail   string     `json:"notifyEmail" gorm:"size:255"`
 	NotifyWebhook string     `json:"notifyWebhook" gorm:"size:500"`
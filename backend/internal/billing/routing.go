@@ -0,0 +1,152 @@
+package billing
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ============================================================================
+// 模型路由
+// ============================================================================
+
+// BudgetStatus 租户预算状态，取租户级成本告警中剩余比例最低的一项
+type BudgetStatus struct {
+	AlertID        string  `json:"alertId"`
+	AlertName      string  `json:"alertName"`
+	AlertType      string  `json:"alertType"`
+	Threshold      float64 `json:"threshold"`
+	Spent          float64 `json:"spent"`
+	RemainingRatio float64 `json:"remainingRatio"` // (阈值 - 已用) / 阈值，超支时为负
+}
+
+// GetBudgetStatus 根据租户级（不限用户与模型）的成本告警计算剩余预算；未配置告警时返回 nil
+func (s *Service) GetBudgetStatus(ctx context.Context, tenantID string) (*BudgetStatus, error) {
+	var alerts []CostAlert
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND is_enabled = ? AND threshold > 0", tenantID, true).
+		Where("user_id IS NULL AND (model_name IS NULL OR model_name = '')").
+		Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+
+	var status *BudgetStatus
+	now := time.Now()
+	for _, alert := range alerts {
+		startDate, ok := alertPeriodStart(alert.AlertType, now)
+		if !ok {
+			continue
+		}
+		spent, err := s.alertCurrentCost(ctx, tenantID, &alert, startDate)
+		if err != nil {
+			return nil, err
+		}
+		ratio := (alert.Threshold - spent) / alert.Threshold
+		if status == nil || ratio < status.RemainingRatio {
+			status = &BudgetStatus{
+				AlertID:        alert.ID,
+				AlertName:      alert.Name,
+				AlertType:      alert.AlertType,
+				Threshold:      alert.Threshold,
+				Spent:          spent,
+				RemainingRatio: ratio,
+			}
+		}
+	}
+	return status, nil
+}
+
+// ModelRoutingDecision Agent 模型路由决策记录
+type ModelRoutingDecision struct {
+	ID                   string    `json:"id" gorm:"primaryKey;type:uuid"`
+	TenantID             string    `json:"tenantId" gorm:"type:uuid;not null;index:idx_routing_tenant_time"`
+	AgentID              string    `json:"agentId" gorm:"type:uuid;index"`
+	ModelID              string    `json:"modelId" gorm:"size:100"`
+	ModelName            string    `json:"modelName" gorm:"size:100"`
+	DefaultModelID       string    `json:"defaultModelId" gorm:"size:100"`
+	Strategy             string    `json:"strategy" gorm:"size:20"`
+	Reason               string    `json:"reason" gorm:"size:50"`
+	PromptTokens         int       `json:"promptTokens"`
+	EstimatedCost        float64   `json:"estimatedCost" gorm:"type:decimal(12,6)"`
+	DefaultEstimatedCost float64   `json:"defaultEstimatedCost" gorm:"type:decimal(12,6)"`
+	CreatedAt            time.Time `json:"createdAt" gorm:"not null;autoCreateTime;index:idx_routing_tenant_time"`
+}
+
+// TableName 指定表名
+func (ModelRoutingDecision) TableName() string {
+	return "model_routing_decisions"
+}
+
+// RecordRoutingDecision 记录一次模型路由决策
+func (s *Service) RecordRoutingDecision(ctx context.Context, decision *ModelRoutingDecision) error {
+	if decision.ID == "" {
+		decision.ID = uuid.New().String()
+	}
+	return s.db.WithContext(ctx).Create(decision).Error
+}
+
+// RoutingCostSummary 成本报表中的模型路由汇总
+type RoutingCostSummary struct {
+	TotalDecisions   int64              `json:"totalDecisions"`
+	RoutedDecisions  int64              `json:"routedDecisions"` // 选中模型与默认模型不同的次数
+	EstimatedCost    float64            `json:"estimatedCost"`
+	DefaultCost      float64            `json:"defaultCost"`      // 全部使用默认模型时的估算成本
+	EstimatedSavings float64            `json:"estimatedSavings"` // DefaultCost - EstimatedCost
+	ByModel          []RoutingModelItem `json:"byModel"`
+	ByReason         map[string]int64   `json:"byReason"`
+}
+
+// RoutingModelItem 按选中模型分组的路由统计
+type RoutingModelItem struct {
+	ModelID       string  `json:"modelId"`
+	ModelName     string  `json:"modelName"`
+	Decisions     int64   `json:"decisions"`
+	EstimatedCost float64 `json:"estimatedCost"`
+}
+
+// getRoutingSummary 汇总时间范围内的模型路由决策，没有记录时返回 nil
+func (s *Service) getRoutingSummary(ctx context.Context, tenantID string, start, end time.Time) *RoutingCostSummary {
+	base := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&ModelRoutingDecision{}).
+			Where("tenant_id = ?", tenantID).
+			Where("created_at BETWEEN ? AND ?", start, end)
+	}
+
+	summary := &RoutingCostSummary{ByReason: make(map[string]int64)}
+	var totals struct {
+		TotalDecisions  int64
+		RoutedDecisions int64
+		EstimatedCost   float64
+		DefaultCost     float64
+	}
+	if err := base().
+		Select("COUNT(*) as total_decisions, " +
+			"COALESCE(SUM(CASE WHEN model_id <> default_model_id THEN 1 ELSE 0 END), 0) as routed_decisions, " +
+			"COALESCE(SUM(estimated_cost), 0) as estimated_cost, COALESCE(SUM(default_estimated_cost), 0) as default_cost").
+		Scan(&totals).Error; err != nil || totals.TotalDecisions == 0 {
+		return nil
+	}
+	summary.TotalDecisions = totals.TotalDecisions
+	summary.RoutedDecisions = totals.RoutedDecisions
+	summary.EstimatedCost = totals.EstimatedCost
+	summary.DefaultCost = totals.DefaultCost
+	summary.EstimatedSavings = totals.DefaultCost - totals.EstimatedCost
+
+	base().
+		Select("model_id, MAX(model_name) as model_name, COUNT(*) as decisions, SUM(estimated_cost) as estimated_cost").
+		Group("model_id").
+		Order("decisions DESC").
+		Scan(&summary.ByModel)
+
+	var reasons []struct {
+		Reason string
+		Count  int64
+	}
+	base().Select("reason, COUNT(*) as count").Group("reason").Scan(&reasons)
+	for _, r := range reasons {
+		summary.ByReason[r.Reason] = r.Count
+	}
+	return summary
+}
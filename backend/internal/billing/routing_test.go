@@ -0,0 +1,63 @@
+package billing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+func TestGetBudgetStatusUsesTenantWideAlerts(t *testing.T) {
+	_, creditsService, db := newTestMeter(t)
+	if err := db.AutoMigrate(&CostAlert{}, &metrics.ModelCallLog{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewService(db, creditsService, nil)
+	ctx := context.Background()
+
+	if status, err := svc.GetBudgetStatus(ctx, testTenantID); err != nil || status != nil {
+		t.Fatalf("expected no budget without alerts: status=%+v err=%v", status, err)
+	}
+
+	tenantAlert, err := svc.CreateAlert(ctx, &CreateAlertRequest{TenantID: testTenantID, Name: "月度预算", AlertType: "monthly", Threshold: 100})
+	if err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+	// 针对单个用户的告警不参与租户预算
+	if _, err := svc.CreateAlert(ctx, &CreateAlertRequest{TenantID: testTenantID, Name: "用户预算", AlertType: "monthly", Threshold: 10, UserID: testUserID}); err != nil {
+		t.Fatalf("CreateAlert: %v", err)
+	}
+
+	var nullUsers int64
+	if err := db.Model(&CostAlert{}).Where("user_id IS NULL").Count(&nullUsers).Error; err != nil || nullUsers != 1 {
+		t.Fatalf("tenant-wide alert should store NULL user_id: count=%d err=%v", nullUsers, err)
+	}
+
+	for _, userID := range []string{testUserID, uuid.New().String()} {
+		if err := db.Create(&metrics.ModelCallLog{
+			ID:          uuid.New().String(),
+			TenantID:    testTenantID,
+			UserID:      userID,
+			ModelID:     uuid.New().String(),
+			ModelName:   "gpt-4o",
+			Provider:    "openai",
+			RequestType: "chat",
+			TotalCost:   30,
+			Status:      "success",
+			CreatedAt:   time.Now(),
+		}).Error; err != nil {
+			t.Fatalf("create call log: %v", err)
+		}
+	}
+
+	status, err := svc.GetBudgetStatus(ctx, testTenantID)
+	if err != nil {
+		t.Fatalf("GetBudgetStatus: %v", err)
+	}
+	if status == nil || status.AlertID != tenantAlert.ID || status.Spent != 60 || status.RemainingRatio < 0.39 || status.RemainingRatio > 0.41 {
+		t.Fatalf("unexpected budget status: %+v", status)
+	}
+}
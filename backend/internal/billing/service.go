@@ -18,6 +18,12 @@ type Service struct {
 	db             *gorm.DB
 	creditsService *credits.Service
 	metricsService metrics.MetricsServiceInterface
+	billObserver   BillPaidObserver
+}
+
+// BillPaidObserver 账单支付成功观察者（如订阅累计实付金额）
+type BillPaidObserver interface {
+	OnBillPaid(ctx context.Context, bill *Bill)
 }
 
 // NewService 创建计费服务
@@ -264,6 +270,9 @@ func (s *Service) GenerateCostReport(ctx context.Context, req *CostReportRequest
 	// 每日趋势
 	report.DailyTrend = s.getDailyTrend(ctx, req.TenantID, startDate, endDate)
 
+	// 模型路由
+	report.Routing = s.getRoutingSummary(ctx, req.TenantID, startDate, endDate)
+
 	return report, nil
 }
 
@@ -407,12 +416,15 @@ func (s *Service) CreateAlert(ctx context.Context, req *CreateAlertRequest) (*Co
 		Name:          req.Name,
 		AlertType:     req.AlertType,
 		Threshold:     req.Threshold,
-		UserID:        req.UserID,
 		ModelName:     req.ModelName,
 		NotifyEmail:   req.NotifyEmail,
 		NotifyWebhook: req.NotifyWebhook,
 		IsEnabled:     true,
 	}
+	// user_id 为 uuid 列，不限用户时存 NULL 而不是空字符串
+	if req.UserID != "" {
+		alert.UserID = &req.UserID
+	}
 
 	if err := s.db.WithContext(ctx).Create(&alert).Error; err != nil {
 		return nil, fmt.Errorf("创建告警失败: %w", err)
@@ -487,43 +499,14 @@ func (s *Service) CheckAlerts(ctx context.Context, tenantID string) ([]AlertTrig
 	now := time.Now()
 
 	for _, alert := range alerts {
-		var currentValue float64
-		var startDate time.Time
-
-		switch alert.AlertType {
-		case AlertTypeDaily:
-			startDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		case AlertTypeWeekly:
-			weekday := int(now.Weekday())
-			if weekday == 0 {
-				weekday = 7
-			}
-			startDate = now.AddDate(0, 0, -weekday+1)
-			startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, now.Location())
-		case AlertTypeMonthly:
-			startDate = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-		case AlertTypeThreshold:
-			startDate = time.Time{}
-		default:
+		startDate, ok := alertPeriodStart(alert.AlertType, now)
+		if !ok {
 			continue
 		}
 
 		// 查询当前成本
-		query := s.db.WithContext(ctx).Model(&metrics.ModelCallLog{}).
-			Select("COALESCE(SUM(total_cost), 0)").
-			Where("tenant_id = ?", tenantID)
-
-		if !startDate.IsZero() {
-			query = query.Where("created_at >= ?", startDate)
-		}
-		if alert.UserID != "" {
-			query = query.Where("user_id = ?", alert.UserID)
-		}
-		if alert.ModelName != "" {
-			query = query.Where("model_name = ?", alert.ModelName)
-		}
-
-		if err := query.Scan(&currentValue).Error; err != nil {
+		currentValue, err := s.alertCurrentCost(ctx, tenantID, &alert, startDate)
+		if err != nil {
 			continue
 		}
 
@@ -560,6 +543,48 @@ func (s *Service) CheckAlerts(ctx context.Context, tenantID string) ([]AlertTrig
 	return events, nil
 }
 
+// alertPeriodStart 告警统计周期的起始时间，阈值告警不限周期（返回零值）
+func alertPeriodStart(alertType string, now time.Time) (time.Time, bool) {
+	switch alertType {
+	case AlertTypeDaily:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), true
+	case AlertTypeWeekly:
+		weekday := int(now.Weekday())
+		if weekday == 0 {
+			weekday = 7
+		}
+		startDate := now.AddDate(0, 0, -weekday+1)
+		return time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, now.Location()), true
+	case AlertTypeMonthly:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), true
+	case AlertTypeThreshold:
+		return time.Time{}, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// alertCurrentCost 统计告警范围内自 startDate 起的成本
+func (s *Service) alertCurrentCost(ctx context.Context, tenantID string, alert *CostAlert, startDate time.Time) (float64, error) {
+	var currentValue float64
+	query := s.db.WithContext(ctx).Model(&metrics.ModelCallLog{}).
+		Select("COALESCE(SUM(total_cost), 0)").
+		Where("tenant_id = ?", tenantID)
+
+	if !startDate.IsZero() {
+		query = query.Where("created_at >= ?", startDate)
+	}
+	if alert.UserID != nil {
+		query = query.Where("user_id = ?", *alert.UserID)
+	}
+	if alert.ModelName != "" {
+		query = query.Where("model_name = ?", alert.ModelName)
+	}
+
+	err := query.Scan(&currentValue).Error
+	return currentValue, err
+}
+
 // ============================================================================
 // 计费审计
 // ============================================================================
@@ -780,6 +805,7 @@ func (s *Service) AutoMigrate() error {
 		&Refund{},
 		&Reconciliation{},
 		&ReconciliationDetail{},
+		&ModelRoutingDecision{},
 	)
 }
 
@@ -789,6 +815,11 @@ func (s *Service) AutoMigrate() error {
 
 // CreateBill 创建账单
 func (s *Service) CreateBill(ctx context.Context, req *CreateBillRequest) (*Bill, error) {
+	return s.CreateBillTx(s.db.WithContext(ctx), req)
+}
+
+// CreateBillTx 在调用方的事务中创建账单
+func (s *Service) CreateBillTx(tx *gorm.DB, req *CreateBillRequest) (*Bill, error) {
 	bill := &Bill{
 		ID:                 uuid.New().String(),
 		TenantID:           req.TenantID,
@@ -818,7 +849,7 @@ func (s *Service) CreateBill(ctx context.Context, req *CreateBillRequest) (*Bill
 		bill.Items = string(itemsJSON)
 	}
 
-	if err := s.db.WithContext(ctx).Create(bill).Error; err != nil {
+	if err := tx.Create(bill).Error; err != nil {
 		return nil, err
 	}
 
@@ -944,9 +975,16 @@ func (s *Service) ListPayments(ctx context.Context, tenantID, userID string, sta
 	return payments, total, nil
 }
 
+// SetBillPaidObserver 设置账单支付成功观察者
+func (s *Service) SetBillPaidObserver(observer BillPaidObserver) {
+	s.billObserver = observer
+}
+
 // PaymentCallback 支付回调处理
+// 账单从待支付变为已支付时通知 BillPaidObserver，重复回调不会重复通知
 func (s *Service) PaymentCallback(ctx context.Context, paymentNo, tradeNo string, success bool) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	var paidBillID string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var payment Payment
 		if err := tx.Where("payment_no = ?", paymentNo).First(&payment).Error; err != nil {
 			return err
@@ -959,12 +997,16 @@ func (s *Service) PaymentCallback(ctx context.Context, paymentNo, tradeNo string
 			payment.PaidAt = &now
 
 			// 更新账单状态
-			if err := tx.Model(&Bill{}).Where("id = ?", payment.BillID).Updates(map[string]interface{}{
+			result := tx.Model(&Bill{}).Where("id = ? AND status <> ?", payment.BillID, BillStatusPaid).Updates(map[string]interface{}{
 				"status":     BillStatusPaid,
 				"paid_at":    now,
 				"payment_id": payment.ID,
-			}).Error; err != nil {
-				return err
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				paidBillID = payment.BillID
 			}
 		} else {
 			payment.Status = PaymentStatusFailed
@@ -972,6 +1014,16 @@ func (s *Service) PaymentCallback(ctx context.Context, paymentNo, tradeNo string
 
 		return tx.Save(&payment).Error
 	})
+	if err != nil || paidBillID == "" || s.billObserver == nil {
+		return err
+	}
+
+	bill, err := s.GetBill(ctx, paidBillID)
+	if err != nil {
+		return fmt.Errorf("支付已确认，但读取账单失败: %w", err)
+	}
+	s.billObserver.OnBillPaid(ctx, bill)
+	return nil
 }
 
 // ============================================================================
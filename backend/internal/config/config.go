@@ -17,6 +17,34 @@ type Config struct {
 	RAG       RagConfig       `mapstructure:"rag"`
 	Workspace WorkspaceConfig `mapstructure:"workspace"`
 	Cache     CacheConfig     `mapstructure:"cache"` // 新增:缓存配置
+	MCP       MCPConfig       `mapstructure:"mcp"`
+	Webhook   WebhookConfig   `mapstructure:"webhook"`
+	Billing   BillingConfig   `mapstructure:"billing"`
+}
+
+// BillingConfig 计费配置
+type BillingConfig struct {
+	Metering MeteringConfig `mapstructure:"metering"`
+}
+
+// MeteringConfig 模型调用积分计量配置
+type MeteringConfig struct {
+	Enabled             bool `mapstructure:"enabled"`               // 是否在模型调用前预授权、调用后按实际用量扣费
+	DefaultOutputTokens int  `mapstructure:"default_output_tokens"` // 请求未设置 max_tokens 时预估的输出 Token，默认 2048
+	HoldTTLMinutes      int  `mapstructure:"hold_ttl_minutes"`      // 预授权有效期（分钟），默认 30
+}
+
+// MCPConfig 外部 MCP 服务连接配置
+type MCPConfig struct {
+	AllowedCommands []string            `mapstructure:"allowed_commands"` // 允许以 stdio 方式启动的命令，为空时禁用 stdio 传输
+	AllowedArgs     map[string][]string `mapstructure:"allowed_args"`     // 按命令限定允许的参数模式（通配），未配置的命令拒绝执行内联代码的参数
+	WorkDir         string              `mapstructure:"work_dir"`         // stdio 子进程工作目录
+	AllowedHosts    []string            `mapstructure:"allowed_hosts"`    // 允许指向内网地址的 HTTP MCP 服务主机名，其余只能连接公网地址
+}
+
+// WebhookConfig 租户 Webhook 投递配置
+type WebhookConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"` // 允许指向内网地址的端点主机名，其余端点只能是公网地址
 }
 
 // WorkspaceConfig 工作区文件系统配置
@@ -97,6 +125,20 @@ type AIConfig struct {
 // RagConfig RAG 相关配置
 type RagConfig struct {
 	VectorStore VectorStoreConfig `mapstructure:"vector_store"`
+	Reranker    RerankerConfig    `mapstructure:"reranker"`
+}
+
+// RerankerConfig 检索结果重排序配置
+type RerankerConfig struct {
+	Type           string `mapstructure:"type"`            // simple / cross_encoder / llm，为空时不启用
+	Endpoint       string `mapstructure:"endpoint"`        // cross_encoder: /rerank 服务地址
+	APIKey         string `mapstructure:"api_key"`         // cross_encoder: 服务密钥
+	Protocol       string `mapstructure:"protocol"`        // cross_encoder: cohere（Jina/BGE/Cohere）或 tei
+	Model          string `mapstructure:"model"`           // cross_encoder: 模型名称；llm: 模型 ID
+	TenantID       string `mapstructure:"tenant_id"`       // llm: 模型所属租户，请求未携带租户或租户没有同款模型时使用
+	BatchSize      int    `mapstructure:"batch_size"`      // 每批文档数
+	MaxConcurrency int    `mapstructure:"max_concurrency"` // 并发批次数
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"` // 单批超时
 }
 
 // VectorStoreConfig 向量存储配置
@@ -0,0 +1,150 @@
+package credits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+	testUserID   = "22222222-2222-2222-2222-222222222222"
+)
+
+func newTestService(t *testing.T, balance int64) *Service {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&CreditAccount{}, &CreditTransaction{}, &CreditHold{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewService(db)
+	if balance > 0 {
+		if _, err := svc.Recharge(context.Background(), &RechargeRequest{TenantID: testTenantID, UserID: testUserID, Amount: balance}); err != nil {
+			t.Fatalf("Recharge: %v", err)
+		}
+	}
+	return svc
+}
+
+func assertAccount(t *testing.T, svc *Service, balance, freeze int64) {
+	t.Helper()
+	account, err := svc.GetAccount(context.Background(), testTenantID, testUserID)
+	if err != nil {
+		t.Fatalf("GetAccount: %v", err)
+	}
+	if account.Balance != balance || account.FreezeAmount != freeze {
+		t.Fatalf("expected balance %d freeze %d, got %d/%d", balance, freeze, account.Balance, account.FreezeAmount)
+	}
+}
+
+func TestHoldReducesAvailableBalance(t *testing.T) {
+	svc := newTestService(t, 100)
+	ctx := context.Background()
+
+	if _, err := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 70}); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	available, _ := svc.GetAvailableBalance(ctx, testTenantID, testUserID)
+	if available != 30 {
+		t.Fatalf("expected available 30, got %d", available)
+	}
+	if _, err := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 40}); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("expected ErrInsufficientCredits, got %v", err)
+	}
+	if _, err := svc.Consume(ctx, &ConsumeRequest{TenantID: testTenantID, UserID: testUserID, Amount: 40}); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("frozen credits must not be consumable, got %v", err)
+	}
+	if _, err := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: "no-account", Amount: 1}); !errors.Is(err, ErrInsufficientCredits) {
+		t.Fatalf("expected ErrInsufficientCredits without account, got %v", err)
+	}
+	assertAccount(t, svc, 100, 70)
+}
+
+func TestSettleHoldRefundsDifference(t *testing.T) {
+	svc := newTestService(t, 100)
+	ctx := context.Background()
+
+	hold, err := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 50, Model: "gpt-4o", WorkflowID: "wf-1"})
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	tx, err := svc.SettleHold(ctx, hold.ID, &SettleRequest{Amount: 12, TokenUsageID: "usage-1", AgentID: "agent-1"})
+	if err != nil {
+		t.Fatalf("SettleHold: %v", err)
+	}
+	if tx.Amount != -12 || tx.TokenUsageID != "usage-1" || tx.WorkflowID != "wf-1" || tx.AgentID != "agent-1" || tx.Model != "gpt-4o" {
+		t.Fatalf("unexpected transaction: %+v", tx)
+	}
+	assertAccount(t, svc, 88, 0)
+
+	var stored CreditHold
+	svc.db.First(&stored, "id = ?", hold.ID)
+	if stored.Status != HoldStatusSettled || stored.SettledAmount != 12 || stored.RefundedAmount != 38 || stored.TransactionID != tx.ID {
+		t.Fatalf("unexpected hold: %+v", stored)
+	}
+	if _, err := svc.SettleHold(ctx, hold.ID, &SettleRequest{Amount: 1}); !errors.Is(err, ErrHoldClosed) {
+		t.Fatalf("expected ErrHoldClosed, got %v", err)
+	}
+}
+
+func TestSettleHoldCapsShortfall(t *testing.T) {
+	svc := newTestService(t, 100)
+	ctx := context.Background()
+
+	hold, _ := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 60})
+	other, _ := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 30})
+
+	// 实际消耗超过可用余额：只扣除不影响其他预授权的部分
+	tx, err := svc.SettleHold(ctx, hold.ID, &SettleRequest{Amount: 90})
+	if err != nil {
+		t.Fatalf("SettleHold: %v", err)
+	}
+	if tx.Amount != -70 {
+		t.Fatalf("expected capped charge 70, got %d", tx.Amount)
+	}
+	assertAccount(t, svc, 30, 30)
+
+	var stored CreditHold
+	svc.db.First(&stored, "id = ?", hold.ID)
+	if stored.Shortfall != 20 || stored.RefundedAmount != 0 {
+		t.Fatalf("unexpected hold: %+v", stored)
+	}
+	if _, err := svc.SettleHold(ctx, other.ID, &SettleRequest{Amount: 30}); err != nil {
+		t.Fatalf("SettleHold other: %v", err)
+	}
+	assertAccount(t, svc, 0, 0)
+}
+
+func TestReleaseHolds(t *testing.T) {
+	svc := newTestService(t, 100)
+	ctx := context.Background()
+
+	hold, _ := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 40})
+	if err := svc.ReleaseHold(ctx, hold.ID, "调用失败"); err != nil {
+		t.Fatalf("ReleaseHold: %v", err)
+	}
+	assertAccount(t, svc, 100, 0)
+	if err := svc.ReleaseHold(ctx, "missing", ""); !errors.Is(err, ErrHoldNotFound) {
+		t.Fatalf("expected ErrHoldNotFound, got %v", err)
+	}
+
+	expired, _ := svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 25})
+	svc.Hold(ctx, &HoldRequest{TenantID: testTenantID, UserID: testUserID, Amount: 10, TTL: time.Hour})
+	svc.db.Model(&CreditHold{}).Where("id = ?", expired.ID).Update("expires_at", time.Now().UTC().Add(-time.Minute))
+
+	released, err := svc.ReleaseExpiredHolds(ctx)
+	if err != nil {
+		t.Fatalf("ReleaseExpiredHolds: %v", err)
+	}
+	if released != 1 {
+		t.Fatalf("expected 1 released hold, got %d", released)
+	}
+	assertAccount(t, svc, 100, 10)
+}
@@ -45,9 +45,9 @@ type CreditTransaction struct {
 	BalanceAfter  int64           `json:"balanceAfter" gorm:"not null"`     // 变动后余额
 	
 	// 关联信息
-	TokenUsageID  string `json:"tokenUsageId" gorm:"type:uuid;index"`     // 关联的Token消耗记录
-	WorkflowID    string `json:"workflowId" gorm:"type:uuid"`
-	AgentID       string `json:"agentId" gorm:"type:uuid"`
+	TokenUsageID  string `json:"tokenUsageId" gorm:"size:36;index"`       // 关联的Token消耗记录（可为空，不使用 uuid 类型）
+	WorkflowID    string `json:"workflowId" gorm:"size:36"`
+	AgentID       string `json:"agentId" gorm:"size:36"`
 	Model         string `json:"model" gorm:"size:100"`                    // AI模型名称
 	
 	// 描述信息
@@ -55,13 +55,45 @@ type CreditTransaction struct {
 	Remark        string `json:"remark" gorm:"size:500"`                   // 管理员备注
 	
 	// 操作信息
-	OperatorID    string `json:"operatorId" gorm:"type:uuid"`             // 操作人（充值/调整时）
+	OperatorID    string `json:"operatorId" gorm:"size:36"`               // 操作人（充值/调整时）
 	OperatorName  string `json:"operatorName" gorm:"size:100"`
 	
 	// 时间
 	CreatedAt     time.Time `json:"createdAt" gorm:"not null;autoCreateTime;index:idx_credit_tx_time"`
 }
 
+// HoldStatus 预授权状态
+type HoldStatus string
+
+const (
+	HoldStatusHeld     HoldStatus = "held"     // 已冻结，等待结算
+	HoldStatusSettled  HoldStatus = "settled"  // 已按实际用量结算
+	HoldStatusReleased HoldStatus = "released" // 已释放（调用失败或超时）
+)
+
+// CreditHold 积分预授权
+// 模型调用前按预估用量冻结积分（计入账户 FreezeAmount），调用后按实际用量结算并退回差额
+type CreditHold struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:uuid"`
+	TenantID       string     `json:"tenantId" gorm:"type:uuid;not null;index"`
+	UserID         string     `json:"userId" gorm:"type:uuid;not null;index"`
+	AccountID      string     `json:"accountId" gorm:"type:uuid;not null;index"`
+	Amount         int64      `json:"amount" gorm:"not null"`                   // 冻结积分
+	SettledAmount  int64      `json:"settledAmount" gorm:"not null;default:0"`  // 实际扣除积分
+	RefundedAmount int64      `json:"refundedAmount" gorm:"not null;default:0"` // 退回的差额
+	Shortfall      int64      `json:"shortfall" gorm:"not null;default:0"`      // 实际用量超出可用余额而未能扣除的积分
+	Status         HoldStatus `json:"status" gorm:"size:20;not null;index"`
+	Model          string     `json:"model" gorm:"size:100"`
+	WorkflowID     string     `json:"workflowId" gorm:"size:36"`
+	AgentID        string     `json:"agentId" gorm:"size:36"`
+	TransactionID  string     `json:"transactionId" gorm:"size:36"`    // 结算流水
+	Reason         string     `json:"reason" gorm:"size:500"`          // 释放原因
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null;index"` // 超时未结算时由清理任务释放
+	ClosedAt       *time.Time `json:"closedAt"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"not null;autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"not null;autoUpdateTime"`
+}
+
 // CreditPricing 积分定价配置
 type CreditPricing struct {
 	ID            string    `json:"id" gorm:"primaryKey;type:uuid"`
@@ -123,6 +155,27 @@ type ConsumeRequest struct {
 	Description   string `json:"description"`
 }
 
+// HoldRequest 预授权请求
+type HoldRequest struct {
+	TenantID   string        `json:"tenantId"`
+	UserID     string        `json:"userId"`
+	Amount     int64         `json:"amount"`
+	Model      string        `json:"model"`
+	WorkflowID string        `json:"workflowId"`
+	AgentID    string        `json:"agentId"`
+	TTL        time.Duration `json:"-"` // 预授权有效期，默认 30 分钟
+}
+
+// SettleRequest 预授权结算请求
+type SettleRequest struct {
+	Amount       int64  `json:"amount"` // 实际消耗积分
+	TokenUsageID string `json:"tokenUsageId"`
+	WorkflowID   string `json:"workflowId"`
+	AgentID      string `json:"agentId"`
+	Model        string `json:"model"`
+	Description  string `json:"description"`
+}
+
 // GiftRequest 赠送请求
 type GiftRequest struct {
 	TenantID     string          `json:"tenantId"`
@@ -17,8 +17,13 @@ var (
 	ErrInsufficientCredits = errors.New("积分不足")
 	ErrAccountNotFound     = errors.New("积分账户不存在")
 	ErrInvalidAmount       = errors.New("无效的积分金额")
+	ErrHoldNotFound        = errors.New("积分预授权不存在")
+	ErrHoldClosed          = errors.New("积分预授权已结算或释放")
 )
 
+// defaultHoldTTL 预授权默认有效期
+const defaultHoldTTL = 30 * time.Minute
+
 // Service 积分服务
 type Service struct {
 	db *gorm.DB
@@ -84,6 +89,18 @@ func (s *Service) GetBalance(ctx context.Context, tenantID, userID string) (int6
 	return account.Balance, nil
 }
 
+// GetAvailableBalance 获取可用余额（余额减去预授权冻结）
+func (s *Service) GetAvailableBalance(ctx context.Context, tenantID, userID string) (int64, error) {
+	account, err := s.GetAccount(ctx, tenantID, userID)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return account.Balance - account.FreezeAmount, nil
+}
+
 // ============ 充值 ============
 
 // Recharge 管理员充值
@@ -150,8 +167,8 @@ func (s *Service) Consume(ctx context.Context, req *ConsumeRequest) (*CreditTran
 			return err
 		}
 
-		// 检查余额
-		if account.Balance < req.Amount {
+		// 检查可用余额（冻结部分不可消费）
+		if account.Balance-account.FreezeAmount < req.Amount {
 			return ErrInsufficientCredits
 		}
 
@@ -188,19 +205,204 @@ func (s *Service) Consume(ctx context.Context, req *ConsumeRequest) (*CreditTran
 	return tx, err
 }
 
-// CheckBalance 检查余额是否足够
+// CheckBalance 检查可用余额是否足够
 func (s *Service) CheckBalance(ctx context.Context, tenantID, userID string, required int64) (bool, error) {
-	balance, err := s.GetBalance(ctx, tenantID, userID)
+	balance, err := s.GetAvailableBalance(ctx, tenantID, userID)
 	if err != nil {
 		return false, err
 	}
 	return balance >= required, nil
 }
 
+// ============ 预授权 ============
+
+// Hold 冻结预估积分，可用余额不足时返回 ErrInsufficientCredits
+func (s *Service) Hold(ctx context.Context, req *HoldRequest) (*CreditHold, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	ttl := req.TTL
+	if ttl <= 0 {
+		ttl = defaultHoldTTL
+	}
+
+	var hold *CreditHold
+	err := s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		var account CreditAccount
+		if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tenant_id = ? AND user_id = ?", req.TenantID, req.UserID).
+			First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInsufficientCredits
+			}
+			return err
+		}
+		if account.Balance-account.FreezeAmount < req.Amount {
+			return ErrInsufficientCredits
+		}
+
+		hold = &CreditHold{
+			ID:         uuid.New().String(),
+			TenantID:   req.TenantID,
+			UserID:     req.UserID,
+			AccountID:  account.ID,
+			Amount:     req.Amount,
+			Status:     HoldStatusHeld,
+			Model:      req.Model,
+			WorkflowID: req.WorkflowID,
+			AgentID:    req.AgentID,
+			ExpiresAt:  time.Now().UTC().Add(ttl),
+		}
+		if err := db.Create(hold).Error; err != nil {
+			return err
+		}
+		return db.Model(&account).Update("freeze_amount", gorm.Expr("freeze_amount + ?", req.Amount)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// SettleHold 按实际消耗结算预授权：解除冻结、扣除实际积分，差额退回可用余额
+// 实际消耗超过可用余额时只扣除可用部分，不足部分记录在 Shortfall 中，余额不会为负
+func (s *Service) SettleHold(ctx context.Context, holdID string, req *SettleRequest) (*CreditTransaction, error) {
+	if req.Amount < 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var tx *CreditTransaction
+	err := s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		hold, account, err := s.lockHoldTx(db, holdID)
+		if err != nil {
+			return err
+		}
+
+		// 解除本次冻结后，其他预授权仍需保留
+		available := account.Balance - (account.FreezeAmount - hold.Amount)
+		charge := req.Amount
+		if charge > available {
+			charge = max(available, 0)
+		}
+		now := time.Now().UTC()
+		updates := map[string]interface{}{
+			"status":          HoldStatusSettled,
+			"settled_amount":  charge,
+			"refunded_amount": max(hold.Amount-charge, 0),
+			"shortfall":       req.Amount - charge,
+			"closed_at":       now,
+		}
+
+		if charge > 0 {
+			tx = &CreditTransaction{
+				ID:            uuid.New().String(),
+				TenantID:      hold.TenantID,
+				UserID:        hold.UserID,
+				AccountID:     account.ID,
+				Type:          TransactionTypeConsume,
+				Amount:        -charge,
+				BalanceBefore: account.Balance,
+				BalanceAfter:  account.Balance - charge,
+				TokenUsageID:  req.TokenUsageID,
+				WorkflowID:    firstNonEmpty(req.WorkflowID, hold.WorkflowID),
+				AgentID:       firstNonEmpty(req.AgentID, hold.AgentID),
+				Model:         firstNonEmpty(req.Model, hold.Model),
+				Description:   req.Description,
+			}
+			if tx.Description == "" {
+				tx.Description = fmt.Sprintf("AI调用消耗 %d 积分 (%s)，预授权 %d，退回 %d", charge, tx.Model, hold.Amount, max(hold.Amount-charge, 0))
+			}
+			if err := db.Create(tx).Error; err != nil {
+				return err
+			}
+			updates["transaction_id"] = tx.ID
+		}
+
+		if err := db.Model(hold).Updates(updates).Error; err != nil {
+			return err
+		}
+		return db.Model(account).Updates(map[string]interface{}{
+			"freeze_amount": gorm.Expr("freeze_amount - ?", hold.Amount),
+			"balance":       gorm.Expr("balance - ?", charge),
+			"total_used":    gorm.Expr("total_used + ?", charge),
+		}).Error
+	})
+	return tx, err
+}
+
+// ReleaseHold 释放预授权（调用失败或取消时），冻结积分全部退回
+func (s *Service) ReleaseHold(ctx context.Context, holdID, reason string) error {
+	return s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+		hold, account, err := s.lockHoldTx(db, holdID)
+		if err != nil {
+			return err
+		}
+		if err := db.Model(hold).Updates(map[string]interface{}{
+			"status":          HoldStatusReleased,
+			"refunded_amount": hold.Amount,
+			"reason":          reason,
+			"closed_at":       time.Now().UTC(),
+		}).Error; err != nil {
+			return err
+		}
+		return db.Model(account).Update("freeze_amount", gorm.Expr("freeze_amount - ?", hold.Amount)).Error
+	})
+}
+
+// ReleaseExpiredHolds 释放超时未结算的预授权（进程崩溃等场景），返回释放数量
+func (s *Service) ReleaseExpiredHolds(ctx context.Context) (int, error) {
+	var ids []string
+	if err := s.db.WithContext(ctx).Model(&CreditHold{}).
+		Where("status = ? AND expires_at < ?", HoldStatusHeld, time.Now().UTC()).
+		Limit(500).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, id := range ids {
+		if err := s.ReleaseHold(ctx, id, "预授权超时"); err != nil {
+			if errors.Is(err, ErrHoldClosed) {
+				continue
+			}
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+// lockHoldTx 锁定未结算的预授权及其账户
+func (s *Service) lockHoldTx(db *gorm.DB, holdID string) (*CreditHold, *CreditAccount, error) {
+	var hold CreditHold
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", holdID).
+		First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrHoldNotFound
+		}
+		return nil, nil, err
+	}
+	if hold.Status != HoldStatusHeld {
+		return nil, nil, ErrHoldClosed
+	}
+	var account CreditAccount
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", hold.AccountID).
+		First(&account).Error; err != nil {
+		return nil, nil, err
+	}
+	return &hold, &account, nil
+}
+
 // ============ 赠送 ============
 
 // Gift 赠送积分（注册、活动等）
 func (s *Service) Gift(ctx context.Context, req *GiftRequest) (*CreditTransaction, error) {
+	return s.GiftTx(s.db.WithContext(ctx), req)
+}
+
+// GiftTx 在调用方的事务中赠送积分
+func (s *Service) GiftTx(tx *gorm.DB, req *GiftRequest) (*CreditTransaction, error) {
 	if req.Amount <= 0 {
 		return nil, ErrInvalidAmount
 	}
@@ -208,14 +410,14 @@ func (s *Service) Gift(ctx context.Context, req *GiftRequest) (*CreditTransactio
 		req.Type = TransactionTypeGift
 	}
 
-	var tx *CreditTransaction
-	err := s.db.WithContext(ctx).Transaction(func(db *gorm.DB) error {
+	var record *CreditTransaction
+	err := tx.Transaction(func(db *gorm.DB) error {
 		account, err := s.getOrCreateAccountTx(db, req.TenantID, req.UserID)
 		if err != nil {
 			return err
 		}
 
-		tx = &CreditTransaction{
+		record = &CreditTransaction{
 			ID:            uuid.New().String(),
 			TenantID:      req.TenantID,
 			UserID:        req.UserID,
@@ -228,10 +430,10 @@ func (s *Service) Gift(ctx context.Context, req *GiftRequest) (*CreditTransactio
 			OperatorID:    req.OperatorID,
 			OperatorName:  req.OperatorName,
 		}
-		if tx.Description == "" {
-			tx.Description = fmt.Sprintf("赠送 %d 积分", req.Amount)
+		if record.Description == "" {
+			record.Description = fmt.Sprintf("赠送 %d 积分", req.Amount)
 		}
-		if err := db.Create(tx).Error; err != nil {
+		if err := db.Create(record).Error; err != nil {
 			return err
 		}
 
@@ -241,7 +443,7 @@ func (s *Service) Gift(ctx context.Context, req *GiftRequest) (*CreditTransactio
 		}).Error
 	})
 
-	return tx, err
+	return record, err
 }
 
 // GiftOnRegister 注册赠送积分
@@ -551,3 +753,12 @@ func (s *Service) getOrCreateAccountTx(db *gorm.DB, tenantID, userID string) (*C
 	}
 	return &account, nil
 }
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
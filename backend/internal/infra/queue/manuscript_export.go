@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/worker/tasks"
+
+	"github.com/hibiken/asynq"
+)
+
+// ManuscriptExportQueue 基于 asynq 的作品手稿导出队列
+type ManuscriptExportQueue struct {
+	client *asynq.Client
+}
+
+// NewManuscriptExportQueue 创建手稿导出队列
+func NewManuscriptExportQueue(cfg config.RedisConfig) *ManuscriptExportQueue {
+	client := asynq.NewClient(asynq.RedisClientOpt{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &ManuscriptExportQueue{client: client}
+}
+
+// EnqueueManuscriptExport 导出任务入队，同一任务重复入队时视为成功
+func (q *ManuscriptExportQueue) EnqueueManuscriptExport(ctx context.Context, jobID string) error {
+	payload, err := json.Marshal(tasks.ExportManuscriptPayload{JobID: jobID})
+	if err != nil {
+		return fmt.Errorf("marshal payload failed: %w", err)
+	}
+
+	task := asynq.NewTask(tasks.TypeExportManuscript, payload)
+	_, err = q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(2),
+		asynq.Timeout(30*time.Minute),
+		asynq.TaskID("manuscript-export:"+jobID),
+		asynq.Queue("export"),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("enqueue task failed: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭队列客户端
+func (q *ManuscriptExportQueue) Close() error {
+	return q.client.Close()
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/worker/tasks"
+
+	"github.com/hibiken/asynq"
+)
+
+// WebhookQueue 基于 asynq 的 Webhook 投递队列
+type WebhookQueue struct {
+	client *asynq.Client
+}
+
+// NewWebhookQueue 创建 Webhook 投递队列
+func NewWebhookQueue(cfg config.RedisConfig) *WebhookQueue {
+	client := asynq.NewClient(asynq.RedisClientOpt{
+		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+	return &WebhookQueue{client: client}
+}
+
+// EnqueueWebhookDelivery 投递入队，同一投递重复入队时视为成功
+func (q *WebhookQueue) EnqueueWebhookDelivery(ctx context.Context, deliveryID string, maxRetry int) error {
+	payload, err := json.Marshal(tasks.DeliverWebhookPayload{DeliveryID: deliveryID})
+	if err != nil {
+		return fmt.Errorf("marshal payload failed: %w", err)
+	}
+
+	task := asynq.NewTask(tasks.TypeDeliverWebhook, payload)
+	_, err = q.client.EnqueueContext(ctx, task,
+		asynq.MaxRetry(maxRetry),
+		asynq.Timeout(time.Minute),
+		asynq.TaskID("webhook:"+deliveryID),
+		asynq.Queue("webhook"),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return fmt.Errorf("enqueue task failed: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭队列客户端
+func (q *WebhookQueue) Close() error {
+	return q.client.Close()
+}
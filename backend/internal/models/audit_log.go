@@ -47,7 +47,13 @@ func (AuditLog) TableName() string {
 
 // AuditLogService 审计日志服务
 type AuditLogService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	appender AuditLogAppender
+}
+
+// AuditLogAppender 审计日志写入器（如租户哈希链），设置后带租户的日志经由其写入
+type AuditLogAppender interface {
+	AppendLog(ctx context.Context, log *AuditLog) error
 }
 
 // NewAuditLogService 创建审计日志服务
@@ -55,6 +61,11 @@ func NewAuditLogService(db *gorm.DB) *AuditLogService {
 	return &AuditLogService{db: db}
 }
 
+// SetAppender 设置审计日志写入器
+func (s *AuditLogService) SetAppender(appender AuditLogAppender) {
+	s.appender = appender
+}
+
 // CreateLog 创建审计日志
 func (s *AuditLogService) CreateLog(ctx context.Context, log *AuditLog) error {
 	// 移除对audit包的依赖
@@ -67,6 +78,9 @@ func (s *AuditLogService) CreateLog(ctx context.Context, log *AuditLog) error {
 		log.EventLevel = "info"
 	}
 
+	if s.appender != nil && log.TenantID != "" {
+		return s.appender.AppendLog(ctx, log)
+	}
 	return s.db.WithContext(ctx).Create(log).Error
 }
 
@@ -86,6 +100,16 @@ func (s *AuditLogService) CreateLogBatch(ctx context.Context, logs []*AuditLog)
 		}
 	}
 
+	// 哈希链逐条追加，保证每条日志都挂接到所属租户的链上
+	if s.appender != nil {
+		for _, log := range logs {
+			if err := s.CreateLog(ctx, log); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	return s.db.WithContext(ctx).CreateInBatches(logs, 100).Error
 }
 
@@ -208,6 +208,32 @@ func (s *ModelService) GetModel(ctx context.Context, tenantID, modelID string) (
 	return &model, nil
 }
 
+// FindEquivalentModel 查找租户下与源模型同一提供商、同一模型标识的启用模型，不存在时返回 nil
+// 用于把平台配置的模型（属于配置租户）映射为调用方租户自己的模型
+func (s *ModelService) FindEquivalentModel(ctx context.Context, tenantID, sourceTenantID, sourceModelID string) (*Model, error) {
+	source, err := s.GetModel(ctx, sourceTenantID, sourceModelID)
+	if err != nil {
+		return nil, err
+	}
+	if source.TenantID == tenantID {
+		return source, nil
+	}
+	var model Model
+	err = s.db.WithContext(ctx).
+		Scopes(common.NotDeleted()).
+		Where("tenant_id = ? AND provider = ? AND model_identifier = ? AND is_active = ?",
+			tenantID, source.Provider, source.ModelIdentifier, true).
+		Order("created_at").
+		First(&model).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询模型失败: %w", err)
+	}
+	return &model, nil
+}
+
 // CreateModelRequest 创建模型请求
 type CreateModelRequest struct {
 	TenantID        string
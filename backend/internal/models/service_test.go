@@ -41,3 +41,24 @@ func TestModelDiscoverySyncSupportsGemini(t *testing.T) {
 	require.NoError(t, db.Where("tenant_id = ? AND provider = ?", "tenant-discovery", "gemini").First(&stored).Error)
 	require.Contains(t, stored.ModelIdentifier, "gemini")
 }
+
+func TestFindEquivalentModelMapsToTenantModel(t *testing.T) {
+	ctx := context.Background()
+	db := setupModelTestDB(t)
+	svc := NewModelService(db)
+	source := &Model{ID: "11111111-0000-0000-0000-000000000001", TenantID: "platform", Name: "平台 DeepSeek",
+		Provider: "deepseek", ModelIdentifier: "deepseek-chat", Type: "chat", IsActive: true}
+	own := &Model{ID: "11111111-0000-0000-0000-000000000002", TenantID: "tenant-a", Name: "租户 DeepSeek",
+		Provider: "deepseek", ModelIdentifier: "deepseek-chat", Type: "chat", IsActive: true}
+	require.NoError(t, db.Create(source).Error)
+	require.NoError(t, db.Create(own).Error)
+
+	found, err := svc.FindEquivalentModel(ctx, "tenant-a", "platform", source.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	require.Equal(t, own.ID, found.ID)
+
+	missing, err := svc.FindEquivalentModel(ctx, "tenant-b", "platform", source.ID)
+	require.NoError(t, err)
+	require.Nil(t, missing, "租户没有同款模型时应返回 nil")
+}
@@ -0,0 +1,240 @@
+package moderation
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// 敏感词匹配器 (Aho–Corasick)
+// ============================================================================
+
+// wordMatcher 编译后的多模式匹配器
+// 按租户缓存，敏感词增删时重建；构建后只读，可被多个请求并发使用
+type wordMatcher struct {
+	words   []SensitiveWord
+	lengths []int // 每个敏感词归一化后的长度
+	nodes   []acNode
+	builtAt time.Time
+}
+
+// acNode 自动机节点
+type acNode struct {
+	next map[rune]int32
+	fail int32
+	out  []int32 // 在该节点结束的敏感词下标（含 fail 链上的输出）
+}
+
+// wordMatch 单次命中，区间为原文 rune 下标 [start, end)
+type wordMatch struct {
+	word  int
+	start int
+	end   int
+}
+
+// newWordMatcher 编译敏感词
+func newWordMatcher(words []SensitiveWord) *wordMatcher {
+	m := &wordMatcher{
+		nodes:   []acNode{{next: map[rune]int32{}}},
+		builtAt: time.Now(),
+	}
+
+	for _, w := range words {
+		if !w.IsActive {
+			continue
+		}
+		pattern := normalizeWord(w.Word)
+		if len(pattern) == 0 {
+			continue
+		}
+		idx := int32(len(m.words))
+		m.words = append(m.words, w)
+		m.lengths = append(m.lengths, len(pattern))
+		m.insert(pattern, idx)
+	}
+
+	m.buildFailLinks()
+	return m
+}
+
+func (m *wordMatcher) insert(pattern []rune, word int32) {
+	cur := int32(0)
+	for _, r := range pattern {
+		next, ok := m.nodes[cur].next[r]
+		if !ok {
+			next = int32(len(m.nodes))
+			m.nodes = append(m.nodes, acNode{next: map[rune]int32{}})
+			m.nodes[cur].next[r] = next
+		}
+		cur = next
+	}
+	m.nodes[cur].out = append(m.nodes[cur].out, word)
+}
+
+// buildFailLinks 按 BFS 顺序构建失败指针，并合并 fail 链上的输出
+func (m *wordMatcher) buildFailLinks() {
+	queue := make([]int32, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		m.nodes[child].fail = 0
+		queue = append(queue, child)
+	}
+
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[cur].next {
+			fail := m.nodes[cur].fail
+			for fail > 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			} else {
+				m.nodes[child].fail = 0
+			}
+			if inherited := m.nodes[m.nodes[child].fail].out; len(inherited) > 0 {
+				m.nodes[child].out = append(m.nodes[child].out, inherited...)
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// empty 是否没有任何敏感词
+func (m *wordMatcher) empty() bool {
+	return m == nil || len(m.words) == 0
+}
+
+// findAll 在归一化文本上查找所有命中（含重叠命中），按起始位置排序
+func (m *wordMatcher) findAll(text normalizedText) []wordMatch {
+	if m.empty() {
+		return nil
+	}
+
+	var matches []wordMatch
+	cur := int32(0)
+	for i, r := range text.runes {
+		for {
+			if next, ok := m.nodes[cur].next[r]; ok {
+				cur = next
+				break
+			}
+			if cur == 0 {
+				break
+			}
+			cur = m.nodes[cur].fail
+		}
+		for _, word := range m.nodes[cur].out {
+			first := i - m.lengths[word] + 1
+			matches = append(matches, wordMatch{
+				word:  int(word),
+				start: text.origin[first],
+				end:   text.origin[i] + 1,
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].start != matches[b].start {
+			return matches[a].start < matches[b].start
+		}
+		return matches[a].end > matches[b].end
+	})
+	return matches
+}
+
+// filter 检测并处理内容
+// block 命中的原文区间全部以 * 屏蔽；replace 命中按“最左最长”选取不重叠区间替换为替换词，
+// 与 block 区间重叠的 replace 命中以屏蔽为准，未配置替换词的 replace 等同 block；flag 只记录不修改内容
+func (m *wordMatcher) filter(content string) *FilterResult {
+	result := &FilterResult{
+		Original:     content,
+		Filtered:     content,
+		HasSensitive: false,
+		Matches:      []MatchedWord{},
+	}
+	if m.empty() || content == "" {
+		return result
+	}
+
+	original := []rune(content)
+	matches := m.findAll(normalizeText(original))
+	if len(matches) == 0 {
+		return result
+	}
+	result.HasSensitive = true
+
+	// rune 下标 -> 字节偏移，Position 与原实现一致使用字节偏移
+	byteOffsets := make([]int, len(original))
+	offset := 0
+	for i, r := range original {
+		byteOffsets[i] = offset
+		offset += utf8.RuneLen(r)
+	}
+
+	masked := make([]bool, len(original))
+	for _, match := range matches {
+		w := m.words[match.word]
+		result.Matches = append(result.Matches, MatchedWord{
+			Word:     w.Word,
+			Text:     string(original[match.start:match.end]),
+			Category: w.Category,
+			Level:    w.Level,
+			Position: byteOffsets[match.start],
+			Action:   w.Action,
+		})
+		if w.Action == "block" || (w.Action == "replace" && w.Replace == "") {
+			for i := match.start; i < match.end; i++ {
+				masked[i] = true
+			}
+		}
+	}
+
+	// 选取不重叠的替换区间（matches 已按起始位置升序、长度降序排列）
+	replacements := make(map[int]wordMatch)
+	covered := -1
+	for _, match := range matches {
+		w := m.words[match.word]
+		if w.Action != "replace" || w.Replace == "" || match.start < covered {
+			continue
+		}
+		if overlapsMask(masked, match.start, match.end) {
+			continue
+		}
+		replacements[match.start] = match
+		covered = match.end
+	}
+
+	var b strings.Builder
+	b.Grow(len(content))
+	for i := 0; i < len(original); {
+		if match, ok := replacements[i]; ok {
+			b.WriteString(m.words[match.word].Replace)
+			i = match.end
+			continue
+		}
+		if masked[i] && !isSeparator(original[i]) {
+			b.WriteRune('*')
+		} else {
+			b.WriteRune(original[i])
+		}
+		i++
+	}
+	result.Filtered = b.String()
+
+	return result
+}
+
+func overlapsMask(masked []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if masked[i] {
+			return true
+		}
+	}
+	return false
+}
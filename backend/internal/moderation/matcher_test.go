@@ -0,0 +1,63 @@
+package moderation
+
+import "testing"
+
+func testWords() []SensitiveWord {
+	return []SensitiveWord{
+		{Word: "赌博", Category: CategoryGambling, Level: "high", Action: "block", IsActive: true},
+		{Word: "网络赌博", Category: CategoryGambling, Level: "high", Action: "flag", IsActive: true},
+		{Word: "代开发票", Category: CategoryAd, Level: "medium", Action: "replace", Replace: "[广告]", IsActive: true},
+		{Word: "VPN", Category: CategoryCustom, Level: "low", Action: "replace", Replace: "工具", IsActive: true},
+		{Word: "停用词", Action: "block", IsActive: false},
+	}
+}
+
+func TestWordMatcherNormalization(t *testing.T) {
+	m := newWordMatcher(testWords())
+
+	cases := []struct {
+		content string
+		want    string
+	}{
+		{"他沉迷賭博多年", "赌博"},        // 繁体
+		{"这里可以 代 开·发 票", "代开发票"}, // 插入分隔符
+		{"翻墙用ｖｐｎ就行", "VPN"},      // 全角 + 大小写
+		{"赌​博", "赌博"},            // 零宽字符
+	}
+	for _, tc := range cases {
+		result := m.filter(tc.content)
+		if !result.HasSensitive {
+			t.Fatalf("%q: expected match", tc.content)
+		}
+		found := false
+		for _, match := range result.Matches {
+			if match.Word == tc.want {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("%q: expected %q in %+v", tc.content, tc.want, result.Matches)
+		}
+	}
+
+	if result := m.filter("今天天气不错，停用词不应命中"); result.HasSensitive {
+		t.Fatalf("unexpected matches: %+v", result.Matches)
+	}
+}
+
+func TestWordMatcherActions(t *testing.T) {
+	m := newWordMatcher(testWords())
+
+	result := m.filter("他说网络賭 博和代开发票，还有VPN、vpn")
+	if got, want := result.Filtered, "他说网络* *和[广告]，还有工具、工具"; got != want {
+		t.Fatalf("filtered = %q, want %q", got, want)
+	}
+	// 网络赌博 与 赌博 重叠命中都应记录
+	if len(result.Matches) != 5 {
+		t.Fatalf("expected 5 matches, got %+v", result.Matches)
+	}
+	first := result.Matches[0]
+	if first.Word != "网络赌博" || first.Text != "网络賭 博" || first.Position != len("他说") {
+		t.Fatalf("unexpected first match: %+v", first)
+	}
+}
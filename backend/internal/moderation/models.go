@@ -261,9 +261,10 @@ type FilterResult struct {
 // MatchedWord 匹配的敏感词
 type MatchedWord struct {
 	Word     string `json:"word"`
+	Text     string `json:"text"` // 原文中命中的片段（可能含全角、繁体或插入的分隔符）
 	Category string `json:"category"`
 	Level    string `json:"level"`
-	Position int    `json:"position"`
+	Position int    `json:"position"` // 命中片段在原文中的字节偏移
 	Action   string `json:"action"`
 }
 
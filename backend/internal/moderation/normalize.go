@@ -0,0 +1,125 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ============================================================================
+// 文本归一化
+// ============================================================================
+//
+// 敏感词与待检测内容都先经过同一套归一化，再交给 Aho–Corasick 匹配：
+//   1. 全角转半角（ＡＢＣ１２３ -> abc123，全角空格 -> 空格）
+//   2. 大小写折叠
+//   3. 繁体转简体（常用字对照表）
+//   4. 去除分隔符（空白、标点、符号、零宽字符等），防止“敏 感 词”“敏*感*词”绕过
+//
+// 归一化后的每个字符都记录其在原文中的位置，命中后可以映射回原文区间做替换/屏蔽。
+
+// normalizedText 归一化后的文本
+type normalizedText struct {
+	runes  []rune // 归一化后的字符（已去除分隔符）
+	origin []int  // runes[i] 在原文 rune 序列中的下标
+}
+
+// normalizeText 归一化文本，同时保留到原文的位置映射
+func normalizeText(original []rune) normalizedText {
+	nt := normalizedText{
+		runes:  make([]rune, 0, len(original)),
+		origin: make([]int, 0, len(original)),
+	}
+	for i, r := range original {
+		if nr, ok := normalizeRune(r); ok {
+			nt.runes = append(nt.runes, nr)
+			nt.origin = append(nt.origin, i)
+		}
+	}
+	return nt
+}
+
+// normalizeWord 归一化敏感词
+func normalizeWord(word string) []rune {
+	return normalizeText([]rune(word)).runes
+}
+
+// normalizeRune 归一化单个字符，第二个返回值为 false 表示该字符是分隔符，应被忽略
+func normalizeRune(r rune) (rune, bool) {
+	r = foldWidth(r)
+	if isSeparator(r) {
+		return 0, false
+	}
+	r = unicode.ToLower(r)
+	if s, ok := traditionalToSimplified[r]; ok {
+		r = s
+	}
+	return r, true
+}
+
+// foldWidth 全角字符转半角
+func foldWidth(r rune) rune {
+	switch {
+	case r == '　':
+		return ' '
+	case r >= '！' && r <= '～':
+		return r - 0xFEE0
+	}
+	return r
+}
+
+// isSeparator 是否为分隔符：空白、标点、符号、控制字符与零宽字符
+func isSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) ||
+		unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Mn, r)
+}
+
+// traditionalToSimplified 繁体 -> 简体 对照表
+var traditionalToSimplified = buildConversionTable(traditionalSimplifiedPairs)
+
+// traditionalSimplifiedPairs 常用繁简对照，每项为“繁简”两个字符
+// 覆盖常用字及敏感词库中的高频字，不追求完整（完整转换需要词语级的 OpenCC 词典）
+const traditionalSimplifiedPairs = `
+萬万 與与 醜丑 專专 業业 叢丛 東东 絲丝 兩两 嚴严 喪丧 個个 豐丰 臨临 為为 麗丽 舉举 義义 烏乌 樂乐 喬乔 習习 鄉乡 書书 買买 亂乱
+爭争 於于 虧亏 雲云 亞亚 產产 畝亩 親亲 億亿 僅仅 從从 侖仑 倉仓 儀仪 們们 價价 眾众 優优 會会 傘伞 偉伟 傳传 傷伤 倫伦 偽伪 體体
+餘余 傭佣 僉佥 俠侠 侶侣 僥侥 偵侦 側侧 僑侨 儈侩 儕侪 儂侬 俁俣 儔俦 儼俨 倆俩 儷俪 儉俭 債债 傾倾 僂偻 僕仆 儲储 兌兑 黨党 蘭兰
+關关 興兴 茲兹 養养 獸兽 內内 岡冈 冊册 寫写 軍军 農农 馮冯 沖冲 決决 況况 凍冻 淨净 涼凉 減减 湊凑 凜凛 幾几 鳳凤 憑凭 凱凯 擊击
+鑿凿 芻刍 劃划 劉刘 則则 剛刚 創创 刪删 別别 剎刹 劑剂 剮剐 劍剑 剝剥 劇剧 勸劝 辦办 務务 勱劢 動动 勵励 勁劲 勞劳 勢势 勳勋 勻匀
+匯汇 區区 醫医 華华 協协 單单 賣卖 盧卢 鹵卤 臥卧 衛卫 卻却 廠厂 廳厅 歷历 厲厉 壓压 厭厌 厙厍 廁厕 廂厢 廈厦 廚厨 縣县 參参 雙双
+發发 變变 敘叙 疊叠 葉叶 號号 嘆叹 嘰叽 嚇吓 呂吕 嗎吗 啟启 吳吴 員员 聽听 嗚呜 響响 嘯啸 噴喷 嚨咙 鹹咸 啞哑 喚唤 團团 園园 圍围
+圖图 圓圆 聖圣 場场 壞坏 塊块 堅坚 壇坛 墳坟 墜坠 壟垄 壘垒 墾垦 執执 報报 堯尧 塗涂 塵尘 墊垫 塹堑 牆墙 壯壮 聲声 殼壳 壺壶 處处
+備备 復复 夠够 頭头 誇夸 夾夹 奪夺 奮奋 獎奖 奧奥 婦妇 媽妈 嫵妩 嬌娇 孫孙 學学 孿孪 寧宁 寶宝 實实 寵宠 審审 憲宪 宮宫 寬宽 賓宾
+寢寝 對对 尋寻 導导 將将 爾尔 嘗尝 屍尸 盡尽 層层 屆届 屬属 嶼屿 歲岁 豈岂 島岛 嶺岭 崗岗 巖岩 帥帅 師师 帳帐 帶带 幫帮 幣币 幹干
+廣广 莊庄 慶庆 庫库 應应 廟庙 龐庞 廢废 開开 異异 棄弃 張张 彌弥 彎弯 歸归 當当 錄录 彙汇 彥彦 徹彻 徑径 後后 徵征 憶忆 憂忧 懷怀
+態态 總总 恆恒 惡恶 惱恼 惲恽 惻恻 愛爱 慘惨 慚惭 懶懒 憐怜 戀恋 懇恳 懼惧 戰战 戲戏 戶户 撲扑 擴扩 掃扫 揚扬 擾扰 撫抚 搶抢 護护
+擔担 擬拟 攏拢 揀拣 擁拥 攔拦 擰拧 撥拨 擇择 掛挂 摯挚 攣挛 撈捞 損损 撿捡 換换 搗捣 據据 擄掳 擠挤 擲掷 攙搀 擺摆 搖摇 攜携 攝摄
+數数 敵敌 斂敛 齋斋 斷断 無无 舊旧 時时 曠旷 曇昙 晝昼 顯显 晉晋 曬晒 曉晓 暈晕 暫暂 曆历 術术 機机 殺杀 雜杂 權权 條条 來来 楊杨
+極极 構构 槍枪 樞枢 標标 棧栈 欄栏 樹树 樣样 橋桥 檢检 樓楼 歡欢 歐欧 殘残 殲歼 殯殡 毆殴 毀毁 氣气 漢汉 湯汤 溝沟 沒没 滬沪 淚泪
+潑泼 澤泽 潔洁 灑洒 濁浊 濃浓 測测 濟济 渾浑 滅灭 漁渔 溫温 灣湾 濕湿 潰溃 滿满 濾滤 濫滥 潛潜 澀涩 灘滩 滯滞 燈灯 靈灵 災灾 爐炉
+點点 煉炼 煩烦 燒烧 熱热 煥焕 燜焖 營营 燦灿 爺爷 爛烂 牽牵 犧牺 狀状 猶犹 獄狱 獨独 獲获 獵猎 貓猫 獻献 環环 現现 瑪玛 璽玺 瓊琼
+畫画 暢畅 療疗 瘋疯 癢痒 瘡疮 盤盘 監监 蓋盖 盜盗 睜睁 矯矫 礦矿 碼码 磚砖 礎础 確确 禮礼 禍祸 離离 禿秃 種种 積积 稱称 穩稳 窮穷
+竊窃 窩窝 競竞 筆笔 築筑 節节 範范 簡简 籃篮 籌筹 類类 糧粮 糾纠 紀纪 約约 級级 紅红 紙纸 紡纺 紛纷 純纯 線线 練练 組组 細细 終终
+紹绍 經经 結结 給给 絕绝 統统 絡络 絞绞 繼继 續续 維维 綠绿 網网 緊紧 綜综 緒绪 編编 緣缘 縫缝 縱纵 織织 繩绳 繪绘 纖纤 罰罚 罷罢
+羅罗 聯联 聰聪 職职 肅肃 腸肠 膚肤 腫肿 腦脑 腳脚 臟脏 艱艰 藝艺 蘇苏 蘋苹 莖茎 薦荐 藥药 蓮莲 蕭萧 薩萨 藍蓝 蟲虫 蝦虾 蠟蜡 衝冲
+補补 裝装 製制 複复 襲袭 見见 規规 覺觉 視视 覽览 觀观 覬觊 計计 訂订 認认 討讨 讓让 訓训 議议 記记 講讲 許许 論论 設设 訪访 證证
+評评 識识 詐诈 訴诉 診诊 詞词 譯译 試试 詩诗 誠诚 話话 誕诞 該该 詳详 語语 誤误 說说 請请 諸诸 讀读 課课 誰谁 調调 談谈 謀谋 謊谎
+謝谢 謠谣 謹谨 譜谱 譴谴 譽誉 讚赞 豬猪 貝贝 負负 財财 貢贡 貨货 貪贪 貧贫 責责 貴贵 貸贷 費费 賀贺 貿贸 資资 賊贼 賄贿 賭赌 賠赔
+賞赏 賤贱 質质 賴赖 購购 賽赛 贈赠 贏赢 趕赶 趙赵 躍跃 踐践 蹤踪 車车 軌轨 軟软 轉转 輪轮 輕轻 載载 較较 輔辅 輛辆 輸输 轟轰 辭辞
+邊边 遼辽 達达 遷迁 過过 運运 還还 這这 進进 遠远 違违 連连 遲迟 適适 選选 遺遗 邏逻 郵邮 鄰邻 醞酝 釀酿 釋释 針针 釣钓 鈔钞 鈴铃
+鉛铅 銀银 銅铜 銷销 鋒锋 鋼钢 錢钱 錯错 鍋锅 鍵键 鎖锁 鎮镇 鏡镜 鐘钟 鐵铁 鑰钥 長长 門门 閃闪 閉闭 問问 閒闲 間间 閱阅 闆板 闖闯
+隊队 陽阳 陰阴 陣阵 階阶 際际 陸陆 隨随 險险 隱隐 隸隶 雖虽 雞鸡 難难 雛雏 電电 霧雾 靜静 韓韩 頁页 頂顶 項项 順顺 須须 預预 領领
+頻频 題题 額额 顏颜 願愿 顧顾 風风 飛飞 飯饭 飲饮 飽饱 餅饼 館馆 餓饿 饑饥 馬马 駕驾 駛驶 驗验 騙骗 騎骑 驚惊 驅驱 髮发 鬆松 鬥斗
+鬧闹 魚鱼 鮮鲜 鳥鸟 鳴鸣 鴉鸦 鴨鸭 鵝鹅 鷹鹰 鹽盐 麥麦 麼么 黃黄 齊齐 齒齿 龍龙 龜龟 穢秽 彈弹 砲炮
+`
+
+// buildConversionTable 解析对照表
+func buildConversionTable(pairs string) map[rune]rune {
+	table := make(map[rune]rune)
+	for _, pair := range strings.Fields(pairs) {
+		runes := []rune(pair)
+		if len(runes) != 2 || runes[0] == runes[1] {
+			continue
+		}
+		table[runes[0]] = runes[1]
+	}
+	return table
+}
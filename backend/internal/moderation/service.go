@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"strings"
+	"sync"
 	"time"
 
 	"backend/internal/agent/runtime"
@@ -13,11 +13,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// matcherTTL 敏感词匹配器缓存有效期
+// 本实例的增删会立即重建；多实例部署时其他实例最迟在该时间后加载新词库
+const matcherTTL = 5 * time.Minute
+
 // Service 内容审核服务
 type Service struct {
 	db            *gorm.DB
 	agentRegistry *runtime.Registry
-	wordCache     map[string][]SensitiveWord // 敏感词缓存
+
+	matcherMu sync.RWMutex
+	matchers  map[string]*wordMatcher // 按租户缓存的敏感词匹配器
 }
 
 // NewService 创建审核服务
@@ -25,7 +31,7 @@ func NewService(db *gorm.DB, agentRegistry *runtime.Registry) *Service {
 	return &Service{
 		db:            db,
 		agentRegistry: agentRegistry,
-		wordCache:     make(map[string][]SensitiveWord),
+		matchers:      make(map[string]*wordMatcher),
 	}
 }
 
@@ -94,6 +100,31 @@ func (s *Service) SubmitContent(ctx context.Context, tenantID, userID, userName
 	return task, nil
 }
 
+// aiReviewSchema AI 预审输出的 JSON Schema
+var aiReviewSchema = map[string]any{
+	"type":     "object",
+	"required": []any{"risk_level", "risk_score", "findings"},
+	"properties": map[string]any{
+		"risk_level": map[string]any{"type": "string", "enum": []any{"safe", "low", "medium", "high"}},
+		"risk_score": map[string]any{"type": "number", "minimum": 0, "maximum": 100},
+		"findings": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type":     "object",
+				"required": []any{"category", "description", "severity"},
+				"properties": map[string]any{
+					"category":    map[string]any{"type": "string"},
+					"description": map[string]any{"type": "string"},
+					"severity":    map[string]any{"type": "string"},
+					"position":    map[string]any{"type": "string"},
+					"suggestion":  map[string]any{"type": "string"},
+					"confidence":  map[string]any{"type": "number"},
+				},
+			},
+		},
+	},
+}
+
 // performAIReview 执行 AI 预审
 func (s *Service) performAIReview(ctx context.Context, task *ModerationTask) {
 	if s.agentRegistry == nil {
@@ -113,7 +144,9 @@ func (s *Service) performAIReview(ctx context.Context, task *ModerationTask) {
 			TenantID: task.TenantID,
 		},
 		ExtraParams: map[string]any{
-			"criteria": "请评估以下内容的合规性，检查是否存在：1.政治敏感 2.色情低俗 3.暴力血腥 4.广告营销 5.违法违规。输出JSON格式：{\"risk_level\":\"safe/low/medium/high\",\"risk_score\":0-100,\"findings\":[{\"category\":\"...\",\"description\":\"...\",\"severity\":\"...\"}]}",
+			"criteria":           "请评估以下内容的合规性，检查是否存在：1.政治敏感 2.色情低俗 3.暴力血腥 4.广告营销 5.违法违规。",
+			"output_schema":      aiReviewSchema,
+			"output_schema_name": "moderation_review",
 		},
 	}
 
@@ -131,15 +164,8 @@ func (s *Service) performAIReview(ctx context.Context, task *ModerationTask) {
 		Findings  []AIFinding `json:"findings"`
 	}
 
-	// 尝试从响应中提取 JSON
-	output := result.Output
-	if idx := strings.Index(output, "{"); idx >= 0 {
-		if endIdx := strings.LastIndex(output, "}"); endIdx > idx {
-			output = output[idx : endIdx+1]
-		}
-	}
-
-	if err := json.Unmarshal([]byte(output), &aiResult); err == nil {
+	// 输出已按 aiReviewSchema 校验，为规范化 JSON
+	if err := json.Unmarshal([]byte(result.Output), &aiResult); err == nil {
 		task.AIRiskLevel = aiResult.RiskLevel
 		task.AIRiskScore = aiResult.RiskScore
 		task.AIFindings = aiResult.Findings
@@ -374,8 +400,7 @@ func (s *Service) AddSensitiveWord(ctx context.Context, tenantID, createdBy stri
 		return nil, err
 	}
 
-	// 清除缓存
-	delete(s.wordCache, tenantID)
+	s.rebuildMatcher(ctx, tenantID)
 
 	return sw, nil
 }
@@ -406,7 +431,7 @@ func (s *Service) BatchAddWords(ctx context.Context, tenantID, createdBy string,
 		return 0, err
 	}
 
-	delete(s.wordCache, tenantID)
+	s.rebuildMatcher(ctx, tenantID)
 	return len(words), nil
 }
 
@@ -445,71 +470,57 @@ func (s *Service) DeleteSensitiveWord(ctx context.Context, id, tenantID string)
 		Where("id = ? AND tenant_id = ?", id, tenantID).
 		Delete(&SensitiveWord{}).Error
 	if err == nil {
-		delete(s.wordCache, tenantID)
+		s.rebuildMatcher(ctx, tenantID)
 	}
 	return err
 }
 
 // FilterContent 过滤内容
+// 敏感词编译为 Aho–Corasick 自动机，内容只需扫描一遍；匹配前做全半角、繁简、大小写归一化并忽略分隔符
 func (s *Service) FilterContent(ctx context.Context, tenantID, content string) *FilterResult {
-	result := &FilterResult{
-		Original:     content,
-		Filtered:     content,
-		HasSensitive: false,
-		Matches:      []MatchedWord{},
-	}
-
-	// 获取敏感词列表
-	words, err := s.getSensitiveWords(ctx, tenantID)
-	if err != nil || len(words) == 0 {
-		return result
-	}
-
-	// 检测敏感词
-	for _, w := range words {
-		if !w.IsActive {
-			continue
-		}
-
-		pos := strings.Index(strings.ToLower(content), strings.ToLower(w.Word))
-		if pos >= 0 {
-			result.HasSensitive = true
-			result.Matches = append(result.Matches, MatchedWord{
-				Word:     w.Word,
-				Category: w.Category,
-				Level:    w.Level,
-				Position: pos,
-				Action:   w.Action,
-			})
-
-			// 根据动作处理
-			if w.Action == "replace" && w.Replace != "" {
-				result.Filtered = strings.ReplaceAll(result.Filtered, w.Word, w.Replace)
-			} else if w.Action == "block" {
-				result.Filtered = strings.ReplaceAll(result.Filtered, w.Word, strings.Repeat("*", len([]rune(w.Word))))
-			}
+	matcher, err := s.getMatcher(ctx, tenantID)
+	if err != nil {
+		return &FilterResult{
+			Original: content,
+			Filtered: content,
+			Matches:  []MatchedWord{},
 		}
 	}
-
-	return result
+	return matcher.filter(content)
 }
 
-func (s *Service) getSensitiveWords(ctx context.Context, tenantID string) ([]SensitiveWord, error) {
-	// 检查缓存
-	if cached, ok := s.wordCache[tenantID]; ok {
-		return cached, nil
+// getMatcher 获取租户的敏感词匹配器，缓存缺失或过期时重建
+func (s *Service) getMatcher(ctx context.Context, tenantID string) (*wordMatcher, error) {
+	s.matcherMu.RLock()
+	matcher, ok := s.matchers[tenantID]
+	s.matcherMu.RUnlock()
+	if ok && time.Since(matcher.builtAt) < matcherTTL {
+		return matcher, nil
 	}
+	return s.rebuildMatcher(ctx, tenantID)
+}
 
+// rebuildMatcher 重新加载租户的敏感词并编译匹配器
+// 加载失败时清除缓存，下次过滤时重试
+func (s *Service) rebuildMatcher(ctx context.Context, tenantID string) (*wordMatcher, error) {
 	var words []SensitiveWord
 	err := s.db.WithContext(ctx).
 		Where("tenant_id = ? AND is_active = ?", tenantID, true).
 		Find(&words).Error
-
-	if err == nil {
-		s.wordCache[tenantID] = words
+	if err != nil {
+		s.matcherMu.Lock()
+		delete(s.matchers, tenantID)
+		s.matcherMu.Unlock()
+		return nil, err
 	}
 
-	return words, err
+	matcher := newWordMatcher(words)
+
+	s.matcherMu.Lock()
+	s.matchers[tenantID] = matcher
+	s.matcherMu.Unlock()
+
+	return matcher, nil
 }
 
 // ============================================================================
@@ -478,8 +478,9 @@ func (n *WebhookAlertNotifier) Name() string {
 	return "webhook"
 }
 
+// Notify 投递到告警所属租户（标签 tenant_id）的 Webhook 端点，无租户标签的告警不投递
 func (n *WebhookAlertNotifier) Notify(ctx context.Context, event *AlertEvent) error {
-	return n.webhook.Emit(ctx, "alert.fired", map[string]any{
+	return n.webhook.Emit(ctx, event.Labels["tenant_id"], "alert.fired", map[string]any{
 		"alert_id":  event.ID,
 		"rule_id":   event.RuleID,
 		"rule_name": event.RuleName,
@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// HubMessage 经消息总线在实例间广播的 WebSocket 消息
+type HubMessage struct {
+	ID       string          `json:"id"`
+	TenantID string          `json:"tenantId"`
+	UserID   string          `json:"userId"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// Backplane 多实例消息总线：SendToUser 经总线广播，每个实例把消息投递给本地连接
+type Backplane interface {
+	Publish(ctx context.Context, msg *HubMessage) error
+	// Subscribe 订阅总线消息，ctx 取消后停止；订阅建立后返回
+	Subscribe(ctx context.Context, handler func(*HubMessage)) error
+}
+
+// RedisBackplane 基于 Redis Pub/Sub 的消息总线
+type RedisBackplane struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisBackplane 创建 Redis 消息总线，channel 为空时使用默认频道
+func NewRedisBackplane(client redis.UniversalClient, channel string) *RedisBackplane {
+	if channel == "" {
+		channel = "ws_hub:messages"
+	}
+	return &RedisBackplane{client: client, channel: channel}
+}
+
+// Publish 发布消息
+func (b *RedisBackplane) Publish(ctx context.Context, msg *HubMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, data).Err()
+}
+
+// Subscribe 订阅消息（连接断开时由 go-redis 自动重连并重新订阅）
+func (b *RedisBackplane) Subscribe(ctx context.Context, handler func(*HubMessage)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case m, ok := <-ch:
+				if !ok {
+					return
+				}
+				var msg HubMessage
+				if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+					continue
+				}
+				handler(&msg)
+			}
+		}
+	}()
+	return nil
+}
+
+// LocalBackplane 进程内消息总线，用于单进程多 Hub 场景（开发与测试）
+type LocalBackplane struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[int]func(*HubMessage)
+}
+
+// NewLocalBackplane 创建进程内消息总线
+func NewLocalBackplane() *LocalBackplane {
+	return &LocalBackplane{handlers: make(map[int]func(*HubMessage))}
+}
+
+// Publish 同步投递给所有订阅者
+func (b *LocalBackplane) Publish(_ context.Context, msg *HubMessage) error {
+	b.mu.RLock()
+	handlers := make([]func(*HubMessage), 0, len(b.handlers))
+	for _, h := range b.handlers {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(msg)
+	}
+	return nil
+}
+
+// Subscribe 注册订阅者，ctx 取消后移除
+func (b *LocalBackplane) Subscribe(ctx context.Context, handler func(*HubMessage)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.handlers, id)
+		b.mu.Unlock()
+	}()
+	return nil
+}
@@ -9,10 +9,14 @@ import (
 	"backend/internal/logger"
 	"backend/internal/metrics"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
+// messageIDField 推送给客户端的消息中携带的消息 ID 字段，客户端可据此去重
+const messageIDField = "messageId"
+
 type clientConn struct {
 	conn     *websocket.Conn
 	channels map[string]struct{}
@@ -20,12 +24,17 @@ type clientConn struct {
 }
 
 // WebSocketHub 负责管理租户/用户的 WebSocket 连接
+// 未配置消息总线时为单进程模式：本地无连接的消息写入离线存储；
+// 配置消息总线后，消息先写入离线存储再经总线广播到所有实例，由各实例投递给本地连接，
+// 任一实例实时投递成功后将其从离线存储移除，离线存储中只保留未投递的消息
 type WebSocketHub struct {
 	mu                sync.RWMutex
 	clients           map[string]map[string]map[*websocket.Conn]*clientConn
 	offline           OfflineStore
+	backplane         Backplane
 	keepAliveInterval time.Duration
 	logger            *zap.Logger
+	cancel            context.CancelFunc
 }
 
 // HubOption 配置 hub
@@ -36,6 +45,11 @@ func WithOfflineStore(store OfflineStore) HubOption {
 	return func(h *WebSocketHub) { h.offline = store }
 }
 
+// WithBackplane 指定多实例消息总线
+func WithBackplane(backplane Backplane) HubOption {
+	return func(h *WebSocketHub) { h.backplane = backplane }
+}
+
 // WithKeepAliveInterval 设置心跳间隔
 func WithKeepAliveInterval(interval time.Duration) HubOption {
 	return func(h *WebSocketHub) { h.keepAliveInterval = interval }
@@ -59,9 +73,28 @@ func NewWebSocketHub(opts ...HubOption) *WebSocketHub {
 			opt(hub)
 		}
 	}
+	if hub.backplane != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		hub.cancel = cancel
+		if err := hub.backplane.Subscribe(ctx, hub.handleBackplaneMessage); err != nil {
+			// 订阅失败时退回单进程模式，避免消息只发布不投递
+			cancel()
+			hub.backplane = nil
+			if hub.logger != nil {
+				hub.logger.Warn("订阅 WebSocket 消息总线失败，使用单进程模式", zap.Error(err))
+			}
+		}
+	}
 	return hub
 }
 
+// Close 停止消息总线订阅
+func (h *WebSocketHub) Close() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+}
+
 // Register 注册连接
 func (h *WebSocketHub) Register(tenantID, userID string, conn *websocket.Conn, channels []string) {
 	h.mu.Lock()
@@ -104,36 +137,93 @@ func (h *WebSocketHub) Unregister(tenantID, userID string, conn *websocket.Conn)
 }
 
 // SendToUser 将通知发送给指定租户/用户的所有连接
+// JSON 对象消息会附带 messageId（已有时沿用），多实例模式下用于离线重放去重
 func (h *WebSocketHub) SendToUser(tenantID, userID string, payload any) error {
-	data, err := json.Marshal(payload)
+	messageID, data, err := encodeHubPayload(payload)
 	if err != nil {
 		return err
 	}
+	ctx := context.Background()
+
+	if h.backplane != nil {
+		// 无法得知用户连接在哪个实例，先写入离线存储，实时投递成功的实例负责移除
+		if err := h.storeOffline(ctx, tenantID, userID, data); err != nil && h.logger != nil {
+			h.logger.Warn("写入离线消息失败", zap.String("tenantId", tenantID), zap.String("userId", userID), zap.Error(err))
+		}
+		msg := &HubMessage{ID: messageID, TenantID: tenantID, UserID: userID, Payload: data}
+		if err := h.backplane.Publish(ctx, msg); err != nil {
+			if h.logger != nil {
+				h.logger.Warn("发布 WebSocket 消息失败，仅投递本地连接", zap.String("messageId", messageID), zap.Error(err))
+			}
+			if delivered, _ := h.deliverLocal(tenantID, userID, data); delivered {
+				h.markDelivered(ctx, tenantID, userID, data)
+			}
+			return err
+		}
+		return nil
+	}
+
+	delivered, err := h.deliverLocal(tenantID, userID, data)
+	if !delivered {
+		// 本地无连接或全部写入失败
+		if storeErr := h.storeOffline(ctx, tenantID, userID, data); storeErr != nil && err == nil {
+			err = storeErr
+		}
+	}
+	return err
+}
 
+// handleBackplaneMessage 投递总线消息到本地连接
+func (h *WebSocketHub) handleBackplaneMessage(msg *HubMessage) {
+	if msg == nil || msg.TenantID == "" || msg.UserID == "" {
+		return
+	}
+	if delivered, _ := h.deliverLocal(msg.TenantID, msg.UserID, msg.Payload); delivered {
+		h.markDelivered(context.Background(), msg.TenantID, msg.UserID, msg.Payload)
+	}
+}
+
+// markDelivered 从离线存储中移除已实时投递的消息
+func (h *WebSocketHub) markDelivered(ctx context.Context, tenantID, userID string, data []byte) {
+	tracker, ok := h.offline.(DeliveryTracker)
+	if !ok {
+		return
+	}
+	if err := tracker.MarkDelivered(ctx, tenantID, userID, data); err != nil && h.logger != nil {
+		h.logger.Debug("移除已投递的离线消息失败", zap.String("messageId", payloadMessageID(data)), zap.Error(err))
+	}
+}
+
+// deliverLocal 写入本地所有连接，至少一个连接成功时返回 true
+func (h *WebSocketHub) deliverLocal(tenantID, userID string, data []byte) (bool, error) {
 	h.mu.RLock()
-	userConns := h.clients[tenantID][userID]
+	userConns := make(map[*websocket.Conn]*clientConn, len(h.clients[tenantID][userID]))
+	for conn, client := range h.clients[tenantID][userID] {
+		userConns[conn] = client
+	}
 	h.mu.RUnlock()
 	if len(userConns) == 0 {
-		return h.storeOffline(context.Background(), tenantID, userID, data)
+		return false, nil
 	}
 
+	delivered := false
 	var firstErr error
 	for conn, client := range userConns {
 		client.mu.Lock()
 		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			client.mu.Unlock()
+		err := conn.WriteMessage(websocket.TextMessage, data)
+		client.mu.Unlock()
+		if err != nil {
 			h.Unregister(tenantID, userID, conn)
 			_ = conn.Close()
-			_ = h.storeOffline(context.Background(), tenantID, userID, data)
 			if firstErr == nil {
 				firstErr = err
 			}
 			continue
 		}
-		client.mu.Unlock()
+		delivered = true
 	}
-	return firstErr
+	return delivered, firstErr
 }
 
 // CloseTenant 清理租户下所有连接
@@ -214,3 +304,36 @@ func sliceToSet(values []string) map[string]struct{} {
 	}
 	return set
 }
+
+// encodeHubPayload 序列化消息并为 JSON 对象附加消息 ID
+func encodeHubPayload(payload any) (string, []byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	var obj map[string]json.RawMessage
+	if len(data) == 0 || data[0] != '{' || json.Unmarshal(data, &obj) != nil {
+		return uuid.New().String(), data, nil
+	}
+	if id := payloadMessageID(data); id != "" {
+		return id, data, nil
+	}
+	messageID := uuid.New().String()
+	obj[messageIDField], _ = json.Marshal(messageID)
+	data, err = json.Marshal(obj)
+	return messageID, data, err
+}
+
+// payloadMessageID 读取消息中的 messageId，不存在时返回空
+func payloadMessageID(data []byte) string {
+	if len(data) == 0 || data[0] != '{' {
+		return ""
+	}
+	var envelope struct {
+		MessageID string `json:"messageId"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return ""
+	}
+	return envelope.MessageID
+}
@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/logger"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	testTenantID = "tenant-1"
+	testUserID   = "user-1"
+)
+
+// connectTestClient 通过 httptest 建立到 hub 的 WebSocket 连接
+func connectTestClient(t *testing.T, hub *WebSocketHub) *websocket.Conn {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	registered := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Register(testTenantID, testUserID, conn, nil)
+		close(registered)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	select {
+	case <-registered:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection not registered")
+	}
+	return conn
+}
+
+func readTestMessage(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var msg map[string]any
+	if err := json.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return msg
+}
+
+func expectNoMessage(t *testing.T, conn *websocket.Conn) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	if _, data, err := conn.ReadMessage(); err == nil {
+		t.Fatalf("unexpected message: %s", data)
+	}
+}
+
+func newTestHubs(t *testing.T) (*WebSocketHub, *WebSocketHub) {
+	t.Helper()
+	logger.Init("error", "console", "stdout")
+	backplane := NewLocalBackplane()
+	store := NewMemoryOfflineStore(50)
+	replicaA := NewWebSocketHub(WithBackplane(backplane), WithOfflineStore(store), WithKeepAliveInterval(0))
+	replicaB := NewWebSocketHub(WithBackplane(backplane), WithOfflineStore(store), WithKeepAliveInterval(0))
+	t.Cleanup(replicaA.Close)
+	t.Cleanup(replicaB.Close)
+	return replicaA, replicaB
+}
+
+func TestHubFansOutAcrossReplicas(t *testing.T) {
+	replicaA, replicaB := newTestHubs(t)
+	conn := connectTestClient(t, replicaB)
+
+	if err := replicaA.SendToUser(testTenantID, testUserID, map[string]any{"type": "approval"}); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	msg := readTestMessage(t, conn)
+	if msg["type"] != "approval" || msg[messageIDField] == "" {
+		t.Fatalf("unexpected message: %v", msg)
+	}
+	if queued, _ := replicaA.offline.Drain(context.Background(), testTenantID, testUserID); len(queued) != 0 {
+		t.Fatalf("delivered message must be removed from offline store, got %d", len(queued))
+	}
+	if err := replicaA.SendToUser(testTenantID, testUserID, map[string]any{"type": "approval"}); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	readTestMessage(t, conn)
+
+	// 重新连接到另一个实例时，已实时投递的消息不会被离线重放
+	conn.Close()
+	replicaB.Unregister(testTenantID, testUserID, conn)
+	reconnected := connectTestClient(t, replicaA)
+	expectNoMessage(t, reconnected)
+}
+
+func TestHubReplaysUndeliveredMessagesOnce(t *testing.T) {
+	replicaA, replicaB := newTestHubs(t)
+
+	if err := replicaA.SendToUser(testTenantID, testUserID, map[string]any{"type": "progress", messageIDField: "msg-1"}); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	conn := connectTestClient(t, replicaB)
+	msg := readTestMessage(t, conn)
+	if msg[messageIDField] != "msg-1" {
+		t.Fatalf("expected caller-provided message id, got %v", msg)
+	}
+	expectNoMessage(t, conn)
+}
+
+func TestHubLocalModeStoresOfflineOnlyWithoutConnections(t *testing.T) {
+	logger.Init("error", "console", "stdout")
+	store := NewMemoryOfflineStore(50)
+	hub := NewWebSocketHub(WithOfflineStore(store), WithKeepAliveInterval(0))
+
+	conn := connectTestClient(t, hub)
+	if err := hub.SendToUser(testTenantID, testUserID, map[string]any{"type": "live"}); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	readTestMessage(t, conn)
+	if queued, _ := store.Drain(context.Background(), testTenantID, testUserID); len(queued) != 0 {
+		t.Fatalf("delivered message must not be stored offline, got %d", len(queued))
+	}
+
+	if err := hub.SendToUser(testTenantID, "offline-user", "plain text"); err != nil {
+		t.Fatalf("SendToUser: %v", err)
+	}
+	if queued, _ := store.Drain(context.Background(), testTenantID, "offline-user"); len(queued) != 1 {
+		t.Fatalf("expected 1 offline message, got %d", len(queued))
+	}
+}
@@ -1,6 +1,7 @@
 package notification
 
 import (
+	"bytes"
 	"context"
 	"sync"
 	"time"
@@ -14,6 +15,11 @@ type OfflineStore interface {
 	Drain(ctx context.Context, tenantID, userID string) ([][]byte, error)
 }
 
+// DeliveryTracker 多实例模式下消息先写入离线存储再广播，实时投递成功后从离线存储移除，只保留未投递的消息
+type DeliveryTracker interface {
+	MarkDelivered(ctx context.Context, tenantID, userID string, payload []byte) error
+}
+
 // MemoryOfflineStore 简单内存实现
 type MemoryOfflineStore struct {
 	mu    sync.Mutex
@@ -57,6 +63,20 @@ func (s *MemoryOfflineStore) Drain(_ context.Context, tenantID, userID string) (
 	return queue, nil
 }
 
+// MarkDelivered 移除一条与 payload 相同的离线消息
+func (s *MemoryOfflineStore) MarkDelivered(_ context.Context, tenantID, userID string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queue := s.data[tenantID][userID]
+	for i, msg := range queue {
+		if bytes.Equal(msg, payload) {
+			s.data[tenantID][userID] = append(queue[:i:i], queue[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
 // RedisOfflineStore 基于 Redis 的实现
 type RedisOfflineStore struct {
 	client redis.UniversalClient
@@ -110,3 +130,11 @@ func (s *RedisOfflineStore) Drain(ctx context.Context, tenantID, userID string)
 func (s *RedisOfflineStore) key(tenantID, userID string) string {
 	return "ws_offline:" + tenantID + ":" + userID
 }
+
+// MarkDelivered 从离线列表中移除一条与 payload 相同的消息
+func (s *RedisOfflineStore) MarkDelivered(ctx context.Context, tenantID, userID string, payload []byte) error {
+	if s == nil || s.client == nil {
+		return nil
+	}
+	return s.client.LRem(ctx, s.key(tenantID, userID), 1, payload).Err()
+}
@@ -7,115 +7,251 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"sync"
 	"time"
+
+	"backend/internal/logger"
+	"backend/internal/security"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWebhookEndpointNotFound = errors.New("Webhook 端点不存在")
+	ErrWebhookDeliveryNotFound = errors.New("Webhook 投递记录不存在")
+)
+
+// WebhookDeliveryStatus 投递状态
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // 等待投递
+	WebhookDeliveryRetrying  WebhookDeliveryStatus = "retrying"  // 投递失败，等待重试
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // 投递成功
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"      // 重试耗尽或端点不可用（死信，可重放）
+)
+
+// Webhook 投递默认值
+const (
+	defaultWebhookMaxAttempts      = 8                // 含首次投递的最大尝试次数
+	defaultWebhookDisableThreshold = 20               // 连续失败多少次后自动禁用端点
+	webhookBaseRetryDelay          = 30 * time.Second // 首次重试间隔
+	webhookMaxRetryDelay           = 6 * time.Hour    // 最大重试间隔
+	webhookStuckAfter              = 10 * time.Minute // 超过该时间仍未处理的投递视为入队丢失
+	webhookRequestTimeout          = 30 * time.Second // 单次投递请求超时
 )
 
 // WebhookService Webhook 通知服务
+// 端点与投递记录按租户持久化，投递通过队列异步执行并按指数退避重试，
+// 重试耗尽后进入死信状态，可通过 ReplayDelivery 重放；端点连续失败达到阈值后自动禁用
 type WebhookService struct {
-	client    *http.Client
-	endpoints map[string]*WebhookEndpoint
-	mu        sync.RWMutex
-	queue     chan *WebhookEvent
-	maxRetry  int
+	db               *gorm.DB
+	client           *http.Client
+	queue            WebhookQueue
+	maxAttempts      int
+	disableThreshold int
+	allowedHosts     []string
+}
+
+// WebhookQueue 持久化投递队列（如 asynq），maxRetry 次重试耗尽后由队列归档
+// 未配置时在进程内投递并重试，重启后由 RecoverPending 恢复未完成的投递
+type WebhookQueue interface {
+	EnqueueWebhookDelivery(ctx context.Context, deliveryID string, maxRetry int) error
+}
+
+// WebhookOption 配置 Webhook 服务
+type WebhookOption func(*WebhookService)
+
+// WithWebhookQueue 指定投递队列
+func WithWebhookQueue(queue WebhookQueue) WebhookOption {
+	return func(s *WebhookService) { s.queue = queue }
+}
+
+// WithWebhookMaxAttempts 设置最大尝试次数（含首次投递）
+func WithWebhookMaxAttempts(n int) WebhookOption {
+	return func(s *WebhookService) {
+		if n > 0 {
+			s.maxAttempts = n
+		}
+	}
+}
+
+// WithWebhookDisableThreshold 设置自动禁用端点的连续失败次数，0 表示不自动禁用
+func WithWebhookDisableThreshold(n int) WebhookOption {
+	return func(s *WebhookService) {
+		if n >= 0 {
+			s.disableThreshold = n
+		}
+	}
+}
+
+// WithWebhookAllowedHosts 放行指向内网地址的端点主机名（部署在内网的受信任接收方），其余端点只能是公网地址
+func WithWebhookAllowedHosts(hosts []string) WebhookOption {
+	return func(s *WebhookService) { s.allowedHosts = hosts }
+}
+
+// WithWebhookHTTPClient 指定 HTTP 客户端（默认只能连接公网地址及放行的主机）
+func WithWebhookHTTPClient(client *http.Client) WebhookOption {
+	return func(s *WebhookService) {
+		if client != nil {
+			s.client = client
+		}
+	}
 }
 
 // WebhookEndpoint Webhook 端点配置
 type WebhookEndpoint struct {
-	ID        string            `json:"id"`
-	Name      string            `json:"name"`
-	URL       string            `json:"url"`
-	Secret    string            `json:"secret,omitempty"` // HMAC 签名密钥
-	Headers   map[string]string `json:"headers,omitempty"`
-	Events    []string          `json:"events"`    // 订阅的事件类型
-	Active    bool              `json:"active"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
+	ID                  string            `json:"id" gorm:"primaryKey;size:36"`
+	TenantID            string            `json:"tenant_id" gorm:"size:36;not null;index"`
+	Name                string            `json:"name" gorm:"size:100;not null"`
+	URL                 string            `json:"url" gorm:"size:1000;not null"`
+	Secret              string            `json:"secret,omitempty" gorm:"size:200"` // HMAC 签名密钥
+	Headers             map[string]string `json:"headers,omitempty" gorm:"type:jsonb;serializer:json"`
+	Events              []string          `json:"events" gorm:"type:jsonb;serializer:json"` // 订阅的事件类型
+	Active              bool              `json:"active" gorm:"not null"`
+	ConsecutiveFailures int               `json:"consecutive_failures" gorm:"not null;default:0"`
+	DisabledAt          *time.Time        `json:"disabled_at,omitempty"`
+	DisabledReason      string            `json:"disabled_reason,omitempty" gorm:"size:500"`
+	LastSuccessAt       *time.Time        `json:"last_success_at,omitempty"`
+	LastFailureAt       *time.Time        `json:"last_failure_at,omitempty"`
+	CreatedBy           string            `json:"created_by,omitempty" gorm:"size:36"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
 }
 
 // WebhookEvent Webhook 事件
 type WebhookEvent struct {
-	ID          string         `json:"id"`
-	Type        string         `json:"type"`
-	Timestamp   time.Time      `json:"timestamp"`
-	Payload     map[string]any `json:"payload"`
-	EndpointID  string         `json:"-"`
-	Signature   string         `json:"signature,omitempty"`
-	RetryCount  int            `json:"-"`
-	NextRetryAt time.Time      `json:"-"`
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	TenantID  string         `json:"tenant_id"`
+	Timestamp time.Time      `json:"timestamp"`
+	Payload   map[string]any `json:"payload"`
 }
 
-// WebhookDelivery 投递记录
+// WebhookDelivery 投递记录（一个事件对一个端点一条，记录最近一次尝试的结果）
 type WebhookDelivery struct {
-	ID           string        `json:"id"`
-	EndpointID   string        `json:"endpoint_id"`
-	EventID      string        `json:"event_id"`
-	EventType    string        `json:"event_type"`
-	URL          string        `json:"url"`
-	RequestBody  string        `json:"request_body"`
-	ResponseCode int           `json:"response_code"`
-	ResponseBody string        `json:"response_body"`
-	Duration     time.Duration `json:"duration"`
-	Success      bool          `json:"success"`
-	Error        string        `json:"error,omitempty"`
-	CreatedAt    time.Time     `json:"created_at"`
+	ID            string                `json:"id" gorm:"primaryKey;size:36"`
+	TenantID      string                `json:"tenant_id" gorm:"size:36;not null;index"`
+	EndpointID    string                `json:"endpoint_id" gorm:"size:36;not null;index"`
+	EventID       string                `json:"event_id" gorm:"size:64;not null;index"`
+	EventType     string                `json:"event_type" gorm:"size:100;not null;index"`
+	URL           string                `json:"url" gorm:"size:1000"`
+	RequestBody   string                `json:"request_body" gorm:"type:text"`
+	Status        WebhookDeliveryStatus `json:"status" gorm:"size:20;not null;index"`
+	Attempts      int                   `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts   int                   `json:"max_attempts" gorm:"not null;default:0"`
+	NextAttemptAt *time.Time            `json:"next_attempt_at,omitempty" gorm:"index"`
+	ResponseCode  int                   `json:"response_code"`
+	ResponseBody  string                `json:"response_body" gorm:"type:text"`
+	Duration      time.Duration         `json:"duration"`
+	Success       bool                  `json:"success"`
+	Error         string                `json:"error,omitempty" gorm:"type:text"`
+	DeliveredAt   *time.Time            `json:"delivered_at,omitempty"`
+	ReplayOf      string                `json:"replay_of,omitempty" gorm:"size:36;index"` // 重放来源投递
+	CreatedAt     time.Time             `json:"created_at"`
+	UpdatedAt     time.Time             `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookDeliveryQuery 投递记录查询条件
+type WebhookDeliveryQuery struct {
+	EndpointID string
+	EventType  string
+	Status     WebhookDeliveryStatus
+	Limit      int
+	Offset     int
 }
 
 // NewWebhookService 创建 Webhook 服务
-func NewWebhookService(maxRetry int) *WebhookService {
-	if maxRetry <= 0 {
-		maxRetry = 3
+func NewWebhookService(db *gorm.DB, opts ...WebhookOption) *WebhookService {
+	s := &WebhookService{
+		db:               db,
+		maxAttempts:      defaultWebhookMaxAttempts,
+		disableThreshold: defaultWebhookDisableThreshold,
 	}
-
-	ws := &WebhookService{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		endpoints: make(map[string]*WebhookEndpoint),
-		queue:     make(chan *WebhookEvent, 1000),
-		maxRetry:  maxRetry,
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if s.client == nil {
+		// 连接时按解析后的地址拦截内网目标，防止通过端点地址或重定向访问内部服务
+		s.client = security.NewGuardedHTTPClient(s.allowedHosts, webhookRequestTimeout)
+		s.client.Timeout = webhookRequestTimeout
 	}
+	return s
+}
 
-	// 启动后台处理器
-	go ws.processQueue()
+// AutoMigrate 迁移 Webhook 表
+func (s *WebhookService) AutoMigrate() error {
+	return s.db.AutoMigrate(&WebhookEndpoint{}, &WebhookDelivery{})
+}
 
-	return ws
+// WebhookRetryDelay 第 attempt 次重试前的等待时间：30s 起指数增长，最长 6 小时
+func WebhookRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := webhookBaseRetryDelay
+	for i := 1; i < attempt && delay < webhookMaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookMaxRetryDelay)
 }
 
+// ============ 端点管理 ============
+
 // RegisterEndpoint 注册 Webhook 端点
-func (s *WebhookService) RegisterEndpoint(endpoint *WebhookEndpoint) error {
+func (s *WebhookService) RegisterEndpoint(ctx context.Context, tenantID string, endpoint *WebhookEndpoint) error {
 	if endpoint.URL == "" {
 		return fmt.Errorf("url is required")
 	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if endpoint.ID == "" {
-		endpoint.ID = fmt.Sprintf("wh_%d", time.Now().UnixNano())
+	if err := security.CheckURL(endpoint.URL, s.allowedHosts); err != nil {
+		return err
 	}
-	endpoint.CreatedAt = time.Now()
-	endpoint.UpdatedAt = time.Now()
+	endpoint.ID = uuid.New().String()
+	endpoint.TenantID = tenantID
 	endpoint.Active = true
+	endpoint.ConsecutiveFailures = 0
+	return s.db.WithContext(ctx).Create(endpoint).Error
+}
 
-	s.endpoints[endpoint.ID] = endpoint
-	return nil
+// GetEndpoint 获取端点
+func (s *WebhookService) GetEndpoint(ctx context.Context, tenantID, id string) (*WebhookEndpoint, error) {
+	var endpoint WebhookEndpoint
+	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&endpoint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookEndpointNotFound
+		}
+		return nil, err
+	}
+	return &endpoint, nil
 }
 
 // UpdateEndpoint 更新端点
-func (s *WebhookService) UpdateEndpoint(id string, update *WebhookEndpoint) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	existing, ok := s.endpoints[id]
-	if !ok {
-		return fmt.Errorf("endpoint not found: %s", id)
+func (s *WebhookService) UpdateEndpoint(ctx context.Context, tenantID, id string, update *WebhookEndpoint) error {
+	existing, err := s.GetEndpoint(ctx, tenantID, id)
+	if err != nil {
+		return err
 	}
-
 	if update.URL != "" {
+		if err := security.CheckURL(update.URL, s.allowedHosts); err != nil {
+			return err
+		}
 		existing.URL = update.URL
 	}
 	if update.Name != "" {
@@ -130,192 +266,405 @@ func (s *WebhookService) UpdateEndpoint(id string, update *WebhookEndpoint) erro
 	if update.Events != nil {
 		existing.Events = update.Events
 	}
-	existing.UpdatedAt = time.Now()
-
-	return nil
+	return s.db.WithContext(ctx).Save(existing).Error
 }
 
-// DeleteEndpoint 删除端点
-func (s *WebhookService) DeleteEndpoint(id string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if _, ok := s.endpoints[id]; !ok {
-		return fmt.Errorf("endpoint not found: %s", id)
+// DeleteEndpoint 删除端点（投递记录保留）
+func (s *WebhookService) DeleteEndpoint(ctx context.Context, tenantID, id string) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).Delete(&WebhookEndpoint{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookEndpointNotFound
 	}
-
-	delete(s.endpoints, id)
 	return nil
 }
 
-// SetEndpointActive 设置端点活动状态
-func (s *WebhookService) SetEndpointActive(id string, active bool) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	ep, ok := s.endpoints[id]
-	if !ok {
-		return fmt.Errorf("endpoint not found: %s", id)
+// SetEndpointActive 设置端点活动状态，重新启用时清零连续失败次数
+func (s *WebhookService) SetEndpointActive(ctx context.Context, tenantID, id string, active bool) error {
+	updates := map[string]any{"active": active, "updated_at": time.Now()}
+	if active {
+		updates["consecutive_failures"] = 0
+		updates["disabled_at"] = nil
+		updates["disabled_reason"] = ""
+	}
+	result := s.db.WithContext(ctx).Model(&WebhookEndpoint{}).Where("id = ? AND tenant_id = ?", id, tenantID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookEndpointNotFound
 	}
-
-	ep.Active = active
-	ep.UpdatedAt = time.Now()
 	return nil
 }
 
-// ListEndpoints 列出所有端点
-func (s *WebhookService) ListEndpoints() []*WebhookEndpoint {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	result := make([]*WebhookEndpoint, 0, len(s.endpoints))
-	for _, ep := range s.endpoints {
-		result = append(result, ep)
-	}
-	return result
+// ListEndpoints 列出租户的端点
+func (s *WebhookService) ListEndpoints(ctx context.Context, tenantID string) ([]*WebhookEndpoint, error) {
+	var endpoints []*WebhookEndpoint
+	err := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID).Order("created_at").Find(&endpoints).Error
+	return endpoints, err
 }
 
-// Emit 发送事件
-func (s *WebhookService) Emit(ctx context.Context, eventType string, payload map[string]any) error {
-	s.mu.RLock()
-	endpoints := make([]*WebhookEndpoint, 0)
-	for _, ep := range s.endpoints {
-		if ep.Active && s.shouldDeliver(ep, eventType) {
-			endpoints = append(endpoints, ep)
-		}
-	}
-	s.mu.RUnlock()
+// ============ 事件投递 ============
 
-	if len(endpoints) == 0 {
+// Emit 向租户下订阅该事件的活动端点投递事件（异步，持久化后立即返回）
+func (s *WebhookService) Emit(ctx context.Context, tenantID, eventType string, payload map[string]any) error {
+	if tenantID == "" {
 		return nil
 	}
+	var endpoints []*WebhookEndpoint
+	if err := s.db.WithContext(ctx).Where("tenant_id = ? AND active = ?", tenantID, true).Find(&endpoints).Error; err != nil {
+		return err
+	}
 
 	event := &WebhookEvent{
-		ID:        fmt.Sprintf("evt_%d", time.Now().UnixNano()),
+		ID:        "evt_" + uuid.New().String(),
 		Type:      eventType,
-		Timestamp: time.Now(),
+		TenantID:  tenantID,
+		Timestamp: time.Now().UTC(),
 		Payload:   payload,
 	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
 
-	// 向每个端点发送
+	var firstErr error
 	for _, ep := range endpoints {
-		evt := *event
-		evt.EndpointID = ep.ID
-		s.queue <- &evt
+		if !shouldDeliver(ep, eventType) {
+			continue
+		}
+		delivery := s.newDelivery(ep, event.ID, eventType, string(body))
+		if err := s.db.WithContext(ctx).Create(delivery).Error; err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.dispatch(ctx, delivery)
 	}
+	return firstErr
+}
 
-	return nil
+// TestEndpoint 向指定端点同步发送测试事件（不重试，记录投递结果）
+func (s *WebhookService) TestEndpoint(ctx context.Context, tenantID, endpointID, eventType string, payload map[string]any) (*WebhookDelivery, error) {
+	ep, err := s.GetEndpoint(ctx, tenantID, endpointID)
+	if err != nil {
+		return nil, err
+	}
+	event := &WebhookEvent{
+		ID:        "evt_" + uuid.New().String(),
+		Type:      eventType,
+		TenantID:  tenantID,
+		Timestamp: time.Now().UTC(),
+		Payload:   payload,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	delivery := s.newDelivery(ep, event.ID, eventType, string(body))
+	delivery.MaxAttempts = 1
+	s.attempt(ctx, ep, delivery)
+	delivery.Attempts = 1
+	delivery.Status = WebhookDeliveryDead
+	if delivery.Success {
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.DeliveredAt = &delivery.UpdatedAt
+	}
+	if err := s.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
 }
 
-// EmitSync 同步发送事件（等待响应）
-func (s *WebhookService) EmitSync(ctx context.Context, eventType string, payload map[string]any) ([]*WebhookDelivery, error) {
-	s.mu.RLock()
-	endpoints := make([]*WebhookEndpoint, 0)
-	for _, ep := range s.endpoints {
-		if ep.Active && s.shouldDeliver(ep, eventType) {
-			endpoints = append(endpoints, ep)
+// ProcessDelivery 执行一次投递尝试（由队列 worker 调用）
+// retry 为已重试次数，maxRetry 为最大重试次数；失败且仍可重试时返回错误以触发队列按退避重试，
+// 已完成、重试耗尽或端点不可用时返回 nil
+func (s *WebhookService) ProcessDelivery(ctx context.Context, deliveryID string, retry, maxRetry int) error {
+	var delivery WebhookDelivery
+	if err := s.db.WithContext(ctx).Where("id = ?", deliveryID).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
 		}
+		return err
 	}
-	s.mu.RUnlock()
-
-	if len(endpoints) == 0 {
-		return nil, nil
+	if delivery.Status == WebhookDeliverySucceeded || delivery.Status == WebhookDeliveryDead {
+		return nil
 	}
 
-	event := &WebhookEvent{
-		ID:        fmt.Sprintf("evt_%d", time.Now().UnixNano()),
-		Type:      eventType,
-		Timestamp: time.Now(),
-		Payload:   payload,
+	var ep WebhookEndpoint
+	if err := s.db.WithContext(ctx).Where("id = ?", delivery.EndpointID).First(&ep).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return s.markDead(ctx, &delivery, "端点已删除")
+		}
+		return err
+	}
+	if !ep.Active {
+		return s.markDead(ctx, &delivery, "端点已禁用")
 	}
 
-	deliveries := make([]*WebhookDelivery, 0, len(endpoints))
-	for _, ep := range endpoints {
-		evt := *event
-		evt.EndpointID = ep.ID
-		delivery := s.deliver(ctx, ep, &evt)
-		deliveries = append(deliveries, delivery)
+	s.attempt(ctx, &ep, &delivery)
+	delivery.Attempts++
+	now := time.Now()
+
+	if delivery.Success {
+		delivery.Status = WebhookDeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+		if err := s.db.WithContext(ctx).Save(&delivery).Error; err != nil {
+			return err
+		}
+		s.db.WithContext(ctx).Model(&WebhookEndpoint{}).Where("id = ?", ep.ID).
+			Updates(map[string]any{"consecutive_failures": 0, "last_success_at": now})
+		return nil
 	}
 
-	return deliveries, nil
+	s.recordEndpointFailure(ctx, &ep, &delivery)
+	if retry >= maxRetry {
+		delivery.Status = WebhookDeliveryDead
+		delivery.NextAttemptAt = nil
+		return s.db.WithContext(ctx).Save(&delivery).Error
+	}
+	next := now.Add(WebhookRetryDelay(retry + 1))
+	delivery.Status = WebhookDeliveryRetrying
+	delivery.NextAttemptAt = &next
+	if err := s.db.WithContext(ctx).Save(&delivery).Error; err != nil {
+		return err
+	}
+	return fmt.Errorf("webhook 投递失败（第 %d 次）: %s", delivery.Attempts, delivery.Error)
 }
 
-func (s *WebhookService) shouldDeliver(ep *WebhookEndpoint, eventType string) bool {
-	if len(ep.Events) == 0 {
-		return true // 空列表表示订阅所有事件
+// ============ 投递记录与重放 ============
+
+// ListDeliveries 查询租户的投递记录
+func (s *WebhookService) ListDeliveries(ctx context.Context, tenantID string, query *WebhookDeliveryQuery) ([]*WebhookDelivery, int64, error) {
+	db := s.db.WithContext(ctx).Model(&WebhookDelivery{}).Where("tenant_id = ?", tenantID)
+	if query.EndpointID != "" {
+		db = db.Where("endpoint_id = ?", query.EndpointID)
+	}
+	if query.EventType != "" {
+		db = db.Where("event_type = ?", query.EventType)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > 200 {
+		limit = 50
 	}
+	var deliveries []*WebhookDelivery
+	err := db.Order("created_at DESC").Offset(query.Offset).Limit(limit).Find(&deliveries).Error
+	return deliveries, total, err
+}
 
-	for _, e := range ep.Events {
-		if e == "*" || e == eventType {
-			return true
+// GetDelivery 获取投递记录
+func (s *WebhookService) GetDelivery(ctx context.Context, tenantID, id string) (*WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
 		}
+		return nil, err
 	}
-	return false
+	return &delivery, nil
 }
 
-func (s *WebhookService) processQueue() {
-	for event := range s.queue {
-		s.mu.RLock()
-		ep, ok := s.endpoints[event.EndpointID]
-		s.mu.RUnlock()
+// ReplayDelivery 重放投递：以相同事件（事件 ID 不变，接收方可据此去重）创建新的投递并入队
+func (s *WebhookService) ReplayDelivery(ctx context.Context, tenantID, deliveryID string) (*WebhookDelivery, error) {
+	original, err := s.GetDelivery(ctx, tenantID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	ep, err := s.GetEndpoint(ctx, tenantID, original.EndpointID)
+	if err != nil {
+		return nil, err
+	}
+	if !ep.Active {
+		return nil, fmt.Errorf("端点已禁用，请先重新启用: %s", ep.ID)
+	}
+	replay := s.newDelivery(ep, original.EventID, original.EventType, original.RequestBody)
+	replay.ReplayOf = original.ID
+	if err := s.db.WithContext(ctx).Create(replay).Error; err != nil {
+		return nil, err
+	}
+	s.dispatch(ctx, replay)
+	return replay, nil
+}
+
+// ReplayFailed 重放端点的全部死信投递，返回重放数量
+func (s *WebhookService) ReplayFailed(ctx context.Context, tenantID, endpointID string, since time.Time) (int, error) {
+	var ids []string
+	db := s.db.WithContext(ctx).Model(&WebhookDelivery{}).
+		Where("tenant_id = ? AND endpoint_id = ? AND status = ?", tenantID, endpointID, WebhookDeliveryDead)
+	if !since.IsZero() {
+		db = db.Where("created_at >= ?", since)
+	}
+	// 已被重放过的死信不再重复重放
+	db = db.Where("id NOT IN (?)", s.db.Model(&WebhookDelivery{}).Select("replay_of").Where("replay_of <> ''"))
+	if err := db.Order("created_at").Limit(1000).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	replayed := 0
+	for _, id := range ids {
+		if _, err := s.ReplayDelivery(ctx, tenantID, id); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
 
-		if !ok || !ep.Active {
+// RecoverPending 重新派发长时间未处理的投递（入队失败或进程内投递因重启中断），返回数量
+// 每个实例都会定时执行，派发前以条件更新认领投递，同一投递只会被一个实例重新派发
+func (s *WebhookService) RecoverPending(ctx context.Context) (int, error) {
+	var deliveries []*WebhookDelivery
+	cutoff := time.Now().Add(-webhookStuckAfter)
+	if err := stuckWebhookDeliveries(s.db.WithContext(ctx), cutoff).
+		Limit(500).
+		Find(&deliveries).Error; err != nil {
+		return 0, err
+	}
+	recovered := 0
+	for _, delivery := range deliveries {
+		// 认领后投递不再满足滞留条件，其他实例的条件更新影响 0 行
+		result := stuckWebhookDeliveries(s.db.WithContext(ctx).Model(&WebhookDelivery{}), cutoff).
+			Where("id = ?", delivery.ID).
+			Updates(map[string]any{"next_attempt_at": nil, "updated_at": time.Now()})
+		if result.Error != nil {
+			return recovered, result.Error
+		}
+		if result.RowsAffected == 0 {
 			continue
 		}
+		s.dispatch(ctx, delivery)
+		recovered++
+	}
+	return recovered, nil
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		delivery := s.deliver(ctx, ep, event)
-		cancel()
+// stuckWebhookDeliveries 超过 cutoff 仍未完成的投递
+func stuckWebhookDeliveries(db *gorm.DB, cutoff time.Time) *gorm.DB {
+	return db.Where("status IN ? AND ((next_attempt_at IS NULL AND updated_at < ?) OR next_attempt_at < ?)",
+		[]WebhookDeliveryStatus{WebhookDeliveryPending, WebhookDeliveryRetrying}, cutoff, cutoff)
+}
 
-		// 失败重试
-		if !delivery.Success && event.RetryCount < s.maxRetry {
-			event.RetryCount++
-			event.NextRetryAt = time.Now().Add(s.retryDelay(event.RetryCount))
+// StartRecovery 启动时及之后定时恢复未完成的投递
+func (s *WebhookService) StartRecovery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if recovered, err := s.RecoverPending(ctx); err != nil {
+				logger.Warn("恢复 Webhook 投递失败", zap.Error(err))
+			} else if recovered > 0 {
+				logger.Info("已重新派发 Webhook 投递", zap.Int("count", recovered))
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
 
-			go func(evt *WebhookEvent) {
-				time.Sleep(time.Until(evt.NextRetryAt))
-				s.queue <- evt
-			}(event)
+// ============ 内部实现 ============
+
+func (s *WebhookService) newDelivery(ep *WebhookEndpoint, eventID, eventType, body string) *WebhookDelivery {
+	return &WebhookDelivery{
+		ID:          uuid.New().String(),
+		TenantID:    ep.TenantID,
+		EndpointID:  ep.ID,
+		EventID:     eventID,
+		EventType:   eventType,
+		URL:         ep.URL,
+		RequestBody: body,
+		Status:      WebhookDeliveryPending,
+		MaxAttempts: s.maxAttempts,
+	}
+}
+
+// dispatch 将投递交给队列；无队列或入队失败时在进程内投递
+func (s *WebhookService) dispatch(ctx context.Context, delivery *WebhookDelivery) {
+	maxRetry := max(delivery.MaxAttempts-1, 0)
+	if s.queue != nil {
+		if err := s.queue.EnqueueWebhookDelivery(ctx, delivery.ID, maxRetry); err == nil {
+			return
+		} else {
+			logger.Warn("Webhook 投递入队失败，改为进程内投递", zap.String("delivery_id", delivery.ID), zap.Error(err))
 		}
 	}
+
+	go func(id string, retry int) {
+		ctx := context.Background()
+		for ; ; retry++ {
+			if err := s.ProcessDelivery(ctx, id, retry, maxRetry); err == nil {
+				return
+			}
+			time.Sleep(WebhookRetryDelay(retry + 1))
+		}
+	}(delivery.ID, max(delivery.Attempts, 0))
 }
 
-func (s *WebhookService) retryDelay(attempt int) time.Duration {
-	// 指数退避：1s, 2s, 4s, 8s...
-	return time.Duration(1<<(attempt-1)) * time.Second
+// markDead 将投递标记为死信
+func (s *WebhookService) markDead(ctx context.Context, delivery *WebhookDelivery, reason string) error {
+	delivery.Status = WebhookDeliveryDead
+	delivery.Error = reason
+	delivery.NextAttemptAt = nil
+	return s.db.WithContext(ctx).Save(delivery).Error
 }
 
-func (s *WebhookService) deliver(ctx context.Context, ep *WebhookEndpoint, event *WebhookEvent) *WebhookDelivery {
-	delivery := &WebhookDelivery{
-		ID:         fmt.Sprintf("dlv_%d", time.Now().UnixNano()),
-		EndpointID: ep.ID,
-		EventID:    event.ID,
-		EventType:  event.Type,
-		URL:        ep.URL,
-		CreatedAt:  time.Now(),
+// recordEndpointFailure 累计端点连续失败次数，达到阈值后自动禁用
+func (s *WebhookService) recordEndpointFailure(ctx context.Context, ep *WebhookEndpoint, delivery *WebhookDelivery) {
+	now := time.Now()
+	failures := ep.ConsecutiveFailures + 1
+	updates := map[string]any{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"last_failure_at":      now,
 	}
-
-	// 构建请求体
-	body, err := json.Marshal(event)
-	if err != nil {
-		delivery.Error = err.Error()
-		return delivery
+	if s.disableThreshold > 0 && failures >= s.disableThreshold {
+		updates["active"] = false
+		updates["disabled_at"] = now
+		updates["disabled_reason"] = truncateString(fmt.Sprintf("连续 %d 次投递失败，最近错误: %s", failures, delivery.Error), 500)
+		logger.Warn("Webhook 端点连续失败，已自动禁用",
+			zap.String("tenant_id", ep.TenantID), zap.String("endpoint_id", ep.ID), zap.Int("failures", failures))
 	}
-	delivery.RequestBody = string(body)
+	s.db.WithContext(ctx).Model(&WebhookEndpoint{}).Where("id = ?", ep.ID).Updates(updates)
+}
 
-	// 创建请求
-	req, err := http.NewRequestWithContext(ctx, "POST", ep.URL, bytes.NewReader(body))
+// attempt 发送一次请求并把结果写入 delivery（不落库）
+func (s *WebhookService) attempt(ctx context.Context, ep *WebhookEndpoint, delivery *WebhookDelivery) {
+	delivery.URL = ep.URL
+	delivery.Success = false
+	delivery.Error = ""
+	delivery.ResponseCode = 0
+	delivery.ResponseBody = ""
+	body := []byte(delivery.RequestBody)
+
+	reqCtx, cancel := context.WithTimeout(ctx, webhookRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, ep.URL, bytes.NewReader(body))
 	if err != nil {
 		delivery.Error = err.Error()
-		return delivery
+		return
 	}
 
 	// 设置头
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "AgentFlow-Webhook/1.0")
-	req.Header.Set("X-Webhook-ID", event.ID)
-	req.Header.Set("X-Webhook-Event", event.Type)
-	req.Header.Set("X-Webhook-Timestamp", event.Timestamp.Format(time.RFC3339))
+	req.Header.Set("X-Webhook-ID", delivery.EventID)
+	req.Header.Set("X-Webhook-Delivery", delivery.ID)
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", time.Now().UTC().Format(time.RFC3339))
 
 	// 自定义头
 	for k, v := range ep.Headers {
@@ -324,32 +673,26 @@ func (s *WebhookService) deliver(ctx context.Context, ep *WebhookEndpoint, event
 
 	// HMAC 签名
 	if ep.Secret != "" {
-		signature := s.sign(body, ep.Secret)
-		req.Header.Set("X-Webhook-Signature", signature)
-		event.Signature = signature
+		req.Header.Set("X-Webhook-Signature", s.sign(body, ep.Secret))
 	}
 
 	// 发送请求
 	start := time.Now()
 	resp, err := s.client.Do(req)
 	delivery.Duration = time.Since(start)
-
 	if err != nil {
 		delivery.Error = err.Error()
-		return delivery
+		return
 	}
 	defer resp.Body.Close()
 
 	delivery.ResponseCode = resp.StatusCode
-
-	// 读取响应
 	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 10*1024)) // 最大 10KB
 	delivery.ResponseBody = string(respBody)
-
-	// 判断成功
 	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
-
-	return delivery
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
 }
 
 func (s *WebhookService) sign(payload []byte, secret string) string {
@@ -358,6 +701,26 @@ func (s *WebhookService) sign(payload []byte, secret string) string {
 	return "sha256=" + hex.EncodeToString(h.Sum(nil))
 }
 
+func shouldDeliver(ep *WebhookEndpoint, eventType string) bool {
+	if len(ep.Events) == 0 {
+		return true // 空列表表示订阅所有事件
+	}
+
+	for _, e := range ep.Events {
+		if e == "*" || e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateString(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
 // 预定义事件类型
 const (
 	EventAgentExecuted     = "agent.executed"
@@ -0,0 +1,257 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/security"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingQueue 记录入队的投递，由测试手动驱动重试
+type recordingQueue struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (q *recordingQueue) EnqueueWebhookDelivery(_ context.Context, deliveryID string, _ int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ids = append(q.ids, deliveryID)
+	return nil
+}
+
+// webhookReceiver 记录收到的事件并按 status 响应
+type webhookReceiver struct {
+	mu     sync.Mutex
+	status int
+	events []WebhookEvent
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var event WebhookEvent
+	_ = json.NewDecoder(req.Body).Decode(&event)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	w.WriteHeader(r.status)
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func newTestWebhookService(t *testing.T, opts ...WebhookOption) (*WebhookService, *recordingQueue, *webhookReceiver, string) {
+	t.Helper()
+	logger.Init("error", "console", "stdout")
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	queue := &recordingQueue{}
+	// 测试接收方运行在回环地址上，需显式放行
+	base := []WebhookOption{WithWebhookQueue(queue), WithWebhookAllowedHosts([]string{"127.0.0.1"})}
+	svc := NewWebhookService(db, append(base, opts...)...)
+	if err := svc.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	receiver := &webhookReceiver{status: http.StatusOK}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return svc, queue, receiver, server.URL
+}
+
+func registerTestEndpoint(t *testing.T, svc *WebhookService, url string, events ...string) *WebhookEndpoint {
+	t.Helper()
+	endpoint := &WebhookEndpoint{Name: "partner", URL: url, Secret: "s3cret", Events: events}
+	if err := svc.RegisterEndpoint(context.Background(), testTenantID, endpoint); err != nil {
+		t.Fatalf("RegisterEndpoint: %v", err)
+	}
+	return endpoint
+}
+
+func TestWebhookEmitIsTenantScoped(t *testing.T) {
+	svc, queue, receiver, url := newTestWebhookService(t)
+	ctx := context.Background()
+	registerTestEndpoint(t, svc, url, EventWorkflowCompleted)
+
+	if err := svc.Emit(ctx, "other-tenant", EventWorkflowCompleted, map[string]any{"n": 1}); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if err := svc.Emit(ctx, testTenantID, EventWorkflowFailed, nil); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if len(queue.ids) != 0 {
+		t.Fatalf("expected no deliveries, got %d", len(queue.ids))
+	}
+
+	if err := svc.Emit(ctx, testTenantID, EventWorkflowCompleted, map[string]any{"execution_id": "exec-1"}); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	if len(queue.ids) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(queue.ids))
+	}
+	if err := svc.ProcessDelivery(ctx, queue.ids[0], 0, 3); err != nil {
+		t.Fatalf("ProcessDelivery: %v", err)
+	}
+	delivery, _ := svc.GetDelivery(ctx, testTenantID, queue.ids[0])
+	if delivery.Status != WebhookDeliverySucceeded || delivery.Attempts != 1 || len(receiver.events) != 1 {
+		t.Fatalf("unexpected delivery: %+v", delivery)
+	}
+	if receiver.events[0].TenantID != testTenantID || receiver.events[0].ID != delivery.EventID {
+		t.Fatalf("unexpected event: %+v", receiver.events[0])
+	}
+	if _, err := svc.GetDelivery(ctx, "other-tenant", delivery.ID); err != ErrWebhookDeliveryNotFound {
+		t.Fatalf("expected ErrWebhookDeliveryNotFound, got %v", err)
+	}
+}
+
+func TestWebhookRetriesThenDeadLettersAndReplays(t *testing.T) {
+	svc, queue, receiver, url := newTestWebhookService(t)
+	ctx := context.Background()
+	endpoint := registerTestEndpoint(t, svc, url)
+	receiver.setStatus(http.StatusInternalServerError)
+
+	if err := svc.Emit(ctx, testTenantID, EventWorkflowCompleted, nil); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	id := queue.ids[0]
+	if err := svc.ProcessDelivery(ctx, id, 0, 1); err == nil {
+		t.Fatal("expected retryable error")
+	}
+	delivery, _ := svc.GetDelivery(ctx, testTenantID, id)
+	if delivery.Status != WebhookDeliveryRetrying || delivery.NextAttemptAt == nil || delivery.ResponseCode != http.StatusInternalServerError {
+		t.Fatalf("expected retrying delivery, got %+v", delivery)
+	}
+	if err := svc.ProcessDelivery(ctx, id, 1, 1); err != nil {
+		t.Fatalf("last retry must not return error, got %v", err)
+	}
+	dead, total, err := svc.ListDeliveries(ctx, testTenantID, &WebhookDeliveryQuery{Status: WebhookDeliveryDead})
+	if err != nil || total != 1 || dead[0].Attempts != 2 {
+		t.Fatalf("expected 1 dead delivery, got %d (%v)", total, err)
+	}
+
+	receiver.setStatus(http.StatusOK)
+	replayed, err := svc.ReplayFailed(ctx, testTenantID, endpoint.ID, time.Time{})
+	if err != nil || replayed != 1 {
+		t.Fatalf("expected 1 replay, got %d (%v)", replayed, err)
+	}
+	replay, _ := svc.GetDelivery(ctx, testTenantID, queue.ids[1])
+	if replay.ReplayOf != id || replay.EventID != dead[0].EventID {
+		t.Fatalf("replay must reuse the original event: %+v", replay)
+	}
+	if err := svc.ProcessDelivery(ctx, replay.ID, 0, 1); err != nil {
+		t.Fatalf("ProcessDelivery: %v", err)
+	}
+	if again, _ := svc.ReplayFailed(ctx, testTenantID, endpoint.ID, time.Time{}); again != 0 {
+		t.Fatalf("already replayed deliveries must be skipped, got %d", again)
+	}
+}
+
+func TestWebhookAutoDisablesFailingEndpoint(t *testing.T) {
+	svc, queue, receiver, url := newTestWebhookService(t, WithWebhookDisableThreshold(2))
+	ctx := context.Background()
+	endpoint := registerTestEndpoint(t, svc, url)
+	receiver.setStatus(http.StatusBadGateway)
+
+	for i := 0; i < 2; i++ {
+		if err := svc.Emit(ctx, testTenantID, EventAgentExecuted, nil); err != nil {
+			t.Fatalf("Emit: %v", err)
+		}
+		_ = svc.ProcessDelivery(ctx, queue.ids[i], 0, 0)
+	}
+	disabled, _ := svc.GetEndpoint(ctx, testTenantID, endpoint.ID)
+	if disabled.Active || disabled.DisabledAt == nil || disabled.ConsecutiveFailures != 2 {
+		t.Fatalf("expected endpoint to be disabled, got %+v", disabled)
+	}
+	if err := svc.Emit(ctx, testTenantID, EventAgentExecuted, nil); err != nil || len(queue.ids) != 2 {
+		t.Fatalf("disabled endpoint must not receive deliveries, got %d (%v)", len(queue.ids), err)
+	}
+
+	if err := svc.SetEndpointActive(ctx, testTenantID, endpoint.ID, true); err != nil {
+		t.Fatalf("SetEndpointActive: %v", err)
+	}
+	enabled, _ := svc.GetEndpoint(ctx, testTenantID, endpoint.ID)
+	if !enabled.Active || enabled.ConsecutiveFailures != 0 || enabled.DisabledAt != nil {
+		t.Fatalf("re-enabling must reset failures, got %+v", enabled)
+	}
+}
+
+func TestWebhookRejectsInternalEndpoints(t *testing.T) {
+	svc, _, receiver, url := newTestWebhookService(t, WithWebhookAllowedHosts(nil))
+	ctx := context.Background()
+
+	for _, target := range []string{url, "http://169.254.169.254/latest/meta-data", "http://localhost:8080/hook", "file:///etc/passwd"} {
+		if err := svc.RegisterEndpoint(ctx, testTenantID, &WebhookEndpoint{Name: "internal", URL: target}); err == nil {
+			t.Fatalf("expected %s to be rejected", target)
+		}
+	}
+	endpoint := registerTestEndpoint(t, svc, "https://hooks.example.com/agentflow")
+	if err := svc.UpdateEndpoint(ctx, testTenantID, endpoint.ID, &WebhookEndpoint{URL: url}); !errors.Is(err, security.ErrHostNotAllowed) {
+		t.Fatalf("expected update to internal address to be rejected, got %v", err)
+	}
+
+	// 校验之前保存的内网端点在连接时被拦截，不会把内部服务的响应回传给租户
+	if err := svc.db.Model(&WebhookEndpoint{}).Where("id = ?", endpoint.ID).Update("url", url).Error; err != nil {
+		t.Fatalf("update url: %v", err)
+	}
+	delivery, err := svc.TestEndpoint(ctx, testTenantID, endpoint.ID, "webhook.test", nil)
+	if err != nil {
+		t.Fatalf("TestEndpoint: %v", err)
+	}
+	if delivery.Success || !strings.Contains(delivery.Error, security.ErrHostNotAllowed.Error()) || len(receiver.events) != 0 {
+		t.Fatalf("expected guarded client to refuse loopback, got %+v", delivery)
+	}
+}
+
+func TestWebhookRecoverPendingClaimsEachDeliveryOnce(t *testing.T) {
+	svc, queue, _, url := newTestWebhookService(t)
+	ctx := context.Background()
+	registerTestEndpoint(t, svc, url)
+	if err := svc.Emit(ctx, testTenantID, EventWorkflowCompleted, nil); err != nil {
+		t.Fatalf("Emit: %v", err)
+	}
+	stale := time.Now().Add(-time.Hour)
+	if err := svc.db.Model(&WebhookDelivery{}).Where("id = ?", queue.ids[0]).UpdateColumn("updated_at", stale).Error; err != nil {
+		t.Fatalf("age delivery: %v", err)
+	}
+
+	// 两个实例共享同一数据库，滞留投递只被其中一个重新派发
+	otherQueue := &recordingQueue{}
+	replica := NewWebhookService(svc.db, WithWebhookQueue(otherQueue))
+	first, err := svc.RecoverPending(ctx)
+	if err != nil {
+		t.Fatalf("RecoverPending: %v", err)
+	}
+	second, err := replica.RecoverPending(ctx)
+	if err != nil {
+		t.Fatalf("RecoverPending: %v", err)
+	}
+	if first != 1 || second != 0 || len(queue.ids) != 2 || len(otherQueue.ids) != 0 {
+		t.Fatalf("expected a single re-dispatch, got %d/%d (queues %d/%d)", first, second, len(queue.ids), len(otherQueue.ids))
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	if got := WebhookRetryDelay(1); got != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", got)
+	}
+	if got := WebhookRetryDelay(3); got != 2*time.Minute {
+		t.Fatalf("expected 2m, got %s", got)
+	}
+	if got := WebhookRetryDelay(50); got != 6*time.Hour {
+		t.Fatalf("expected cap of 6h, got %s", got)
+	}
+}
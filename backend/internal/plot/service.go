@@ -28,6 +28,32 @@ func NewService(db *gorm.DB, agentRegistry *runtime.Registry, workspaceService *
 	}
 }
 
+// plotBranchesSchema PlotAgent 输出的 JSON Schema
+var plotBranchesSchema = map[string]any{
+	"type":     "object",
+	"required": []any{"branches"},
+	"properties": map[string]any{
+		"branches": map[string]any{
+			"type":     "array",
+			"minItems": 1,
+			"items": map[string]any{
+				"type":     "object",
+				"required": []any{"id", "title", "summary"},
+				"properties": map[string]any{
+					"id":             map[string]any{"type": "integer"},
+					"title":          map[string]any{"type": "string"},
+					"summary":        map[string]any{"type": "string"},
+					"key_events":     map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+					"emotional_tone": map[string]any{"type": "string"},
+					"hook":           map[string]any{"type": "string"},
+					"difficulty":     map[string]any{"type": "integer", "minimum": 1, "maximum": 5},
+				},
+			},
+		},
+		"recommendation": map[string]any{"type": "string"},
+	},
+}
+
 // CreatePlotRecommendation 创建剧情推演
 func (s *Service) CreatePlotRecommendation(ctx context.Context, tenantID, userID string, req *CreatePlotRequest) (*PlotRecommendationResponse, error) {
 	// 调用 PlotAgent 生成剧情分支
@@ -38,8 +64,10 @@ func (s *Service) CreatePlotRecommendation(ctx context.Context, tenantID, userID
 
 	// 构建输入参数
 	extraParams := map[string]any{
-		"current_plot":  req.CurrentPlot,
-		"num_branches":  req.NumBranches,
+		"current_plot":       req.CurrentPlot,
+		"num_branches":       req.NumBranches,
+		"output_schema":      plotBranchesSchema,
+		"output_schema_name": "plot_branches",
 	}
 	if req.CharacterInfo != nil {
 		extraParams["characters"] = *req.CharacterInfo
@@ -63,7 +91,7 @@ func (s *Service) CreatePlotRecommendation(ctx context.Context, tenantID, userID
 		return nil, fmt.Errorf("生成剧情分支失败: %w", err)
 	}
 
-	// 解析 Agent 返回的 JSON
+	// 解析 Agent 返回的 JSON（已按 plotBranchesSchema 校验）
 	var agentOutput struct {
 		Branches       []PlotBranch `json:"branches"`
 		Recommendation string       `json:"recommendation"`
@@ -0,0 +1,334 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RerankProtocol /rerank 接口的请求格式
+type RerankProtocol string
+
+const (
+	// RerankProtocolCohere Cohere / Jina / BGE(Xinference) / SiliconFlow 等通用格式：
+	// {"model","query","documents":[...]} -> {"results":[{"index","relevance_score"}]}
+	RerankProtocolCohere RerankProtocol = "cohere"
+	// RerankProtocolTEI HuggingFace Text Embeddings Inference 格式：
+	// {"query","texts":[...]} -> [{"index","score"}]
+	RerankProtocolTEI RerankProtocol = "tei"
+)
+
+// CrossEncoderOptions Cross-Encoder 重排序器配置
+type CrossEncoderOptions struct {
+	Endpoint       string         // 服务地址，未以 /rerank 结尾时自动补全
+	APIKey         string         // Bearer Token（可选）
+	Model          string         // 模型名称，如 bge-reranker-v2-m3、jina-reranker-v2-base-multilingual
+	Protocol       RerankProtocol // 请求格式，默认 cohere
+	BatchSize      int            // 每次请求的最大文档数，默认 32
+	MaxConcurrency int            // 并发批次数，默认 4
+	MaxDocRunes    int            // 单个文档截断长度（按字符），默认 2000
+	TimeoutSeconds int            // 单批请求超时，默认 10 秒
+	HTTPClient     *http.Client
+}
+
+// CrossEncoderReranker 基于 Cross-Encoder 的重排序器 (调用外部 /rerank 服务)
+// 文档按批并发打分；服务返回原始 logits 时用 sigmoid 归一化到 0-1，保证不同批次的分数可比较
+type CrossEncoderReranker struct {
+	client         *http.Client
+	endpoint       string
+	apiKey         string
+	model          string
+	protocol       RerankProtocol
+	batchSize      int
+	maxConcurrency int
+	maxDocRunes    int
+	timeout        time.Duration
+}
+
+// NewCrossEncoderReranker 创建 Cross-Encoder 重排序器
+func NewCrossEncoderReranker(opts CrossEncoderOptions) (*CrossEncoderReranker, error) {
+	endpoint := strings.TrimSuffix(strings.TrimSpace(opts.Endpoint), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("rerank endpoint 不能为空")
+	}
+	if !strings.HasSuffix(endpoint, "/rerank") {
+		endpoint += "/rerank"
+	}
+
+	protocol := opts.Protocol
+	if protocol == "" {
+		protocol = RerankProtocolCohere
+	}
+	if protocol != RerankProtocolCohere && protocol != RerankProtocolTEI {
+		return nil, fmt.Errorf("不支持的 rerank 协议: %s", protocol)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = 4
+	}
+	maxDocRunes := opts.MaxDocRunes
+	if maxDocRunes <= 0 {
+		maxDocRunes = 2000
+	}
+	timeout := opts.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &CrossEncoderReranker{
+		client:         client,
+		endpoint:       endpoint,
+		apiKey:         opts.APIKey,
+		model:          opts.Model,
+		protocol:       protocol,
+		batchSize:      batchSize,
+		maxConcurrency: maxConcurrency,
+		maxDocRunes:    maxDocRunes,
+		timeout:        time.Duration(timeout) * time.Second,
+	}, nil
+}
+
+// Rerank 使用 Cross-Encoder API 进行重排序
+func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, documents []*SearchResult, topK int) ([]*SearchResult, error) {
+	if len(documents) == 0 {
+		return documents, nil
+	}
+
+	scores, err := scoreInBatches(ctx, len(documents), r.batchSize, r.maxConcurrency, func(ctx context.Context, start, end int) ([]float64, error) {
+		texts := make([]string, 0, end-start)
+		for _, doc := range documents[start:end] {
+			texts = append(texts, truncateRunes(doc.Content, r.maxDocRunes))
+		}
+		return r.scoreBatch(ctx, query, texts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeScores(scores)
+	return applyScores(documents, scores, topK), nil
+}
+
+// scoreBatch 对一批文档打分，返回与输入顺序一致的原始分数
+func (r *CrossEncoderReranker) scoreBatch(ctx context.Context, query string, texts []string) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var payload any
+	if r.protocol == RerankProtocolTEI {
+		payload = map[string]any{"query": query, "texts": texts, "truncate": true}
+	} else {
+		payload = map[string]any{"model": r.model, "query": query, "documents": texts, "top_n": len(texts), "return_documents": false}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化 rerank 请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rerank 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 rerank 响应失败: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("rerank API 错误 (%d): %s", resp.StatusCode, truncateRunes(string(data), 200))
+	}
+
+	entries, err := parseRerankResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]float64, len(texts))
+	seen := make([]bool, len(texts))
+	for _, e := range entries {
+		if e.Index < 0 || e.Index >= len(texts) {
+			return nil, fmt.Errorf("rerank 响应的 index 越界: %d", e.Index)
+		}
+		scores[e.Index] = e.score()
+		seen[e.Index] = true
+	}
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("rerank 响应缺少文档 %d 的分数", i)
+		}
+	}
+	return scores, nil
+}
+
+// rerankEntry /rerank 响应中的单条结果（兼容 relevance_score 与 score 字段）
+type rerankEntry struct {
+	Index          int      `json:"index"`
+	RelevanceScore *float64 `json:"relevance_score"`
+	Score          *float64 `json:"score"`
+}
+
+func (e rerankEntry) score() float64 {
+	if e.RelevanceScore != nil {
+		return *e.RelevanceScore
+	}
+	if e.Score != nil {
+		return *e.Score
+	}
+	return 0
+}
+
+// parseRerankResponse 解析 {"results":[...]}、{"data":[...]} 或顶层数组
+func parseRerankResponse(data []byte) ([]rerankEntry, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []rerankEntry
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("解析 rerank 响应失败: %w", err)
+		}
+		return entries, nil
+	}
+
+	var wrapped struct {
+		Results []rerankEntry `json:"results"`
+		Data    []rerankEntry `json:"data"`
+	}
+	if err := json.Unmarshal(trimmed, &wrapped); err != nil {
+		return nil, fmt.Errorf("解析 rerank 响应失败: %w", err)
+	}
+	if len(wrapped.Results) > 0 {
+		return wrapped.Results, nil
+	}
+	return wrapped.Data, nil
+}
+
+// --- 重排序公共逻辑 ---
+
+// scoreInBatches 将 n 个文档按 batchSize 切分并发打分，返回与文档顺序一致的分数
+func scoreInBatches(ctx context.Context, n, batchSize, maxConcurrency int, score func(ctx context.Context, start, end int) ([]float64, error)) ([]float64, error) {
+	scores := make([]float64, n)
+	sem := make(chan struct{}, maxConcurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for start := 0; start < n; start += batchSize {
+		end := start + batchSize
+		if end > n {
+			end = n
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = ctx.Err()
+				}
+				mu.Unlock()
+				return
+			}
+
+			batch, err := score(ctx, start, end)
+			if err == nil && len(batch) != end-start {
+				err = fmt.Errorf("批次 %d-%d 返回 %d 个分数", start, end, len(batch))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			copy(scores[start:end], batch)
+		}(start, end)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return scores, nil
+}
+
+// applyScores 按新分数降序排列并截取 TopK，同时更新文档分数
+func applyScores(documents []*SearchResult, scores []float64, topK int) []*SearchResult {
+	order := make([]int, len(documents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	if topK <= 0 || topK > len(documents) {
+		topK = len(documents)
+	}
+	result := make([]*SearchResult, 0, topK)
+	for _, idx := range order[:topK] {
+		doc := documents[idx]
+		doc.Score = scores[idx]
+		result = append(result, doc)
+	}
+	return result
+}
+
+// normalizeScores 将相关度归一化到 0-1
+// 全部已在 0-1 范围内时保持不变；否则视为原始 logits，统一使用 sigmoid 映射。
+// sigmoid 是逐点单调变换，不同批次的分数仍可直接比较（批内 min-max 则不行）
+func normalizeScores(scores []float64) {
+	for _, score := range scores {
+		if score < 0 || score > 1 {
+			for i, s := range scores {
+				scores[i] = 1 / (1 + math.Exp(-s))
+			}
+			return
+		}
+	}
+}
+
+// truncateRunes 按字符截断，避免截断半个中文字符
+func truncateRunes(s string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= maxRunes {
+		return s
+	}
+	return string(runes[:maxRunes])
+}
@@ -0,0 +1,211 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/tenant"
+	"backend/pkg/aiinterface"
+
+	"go.uber.org/zap"
+)
+
+// LLMRerankerOptions LLM 重排序器配置
+type LLMRerankerOptions struct {
+	ModelID         string              // 打分使用的模型 ID（属于 DefaultTenantID）
+	DefaultTenantID string              // 模型所属租户；上下文中没有租户信息或租户没有同款模型时使用
+	ModelResolver   RerankModelResolver // 为调用方租户解析自己的打分模型，为空时所有租户使用 ModelID
+	BatchSize       int                 // 每次请求打分的段落数，默认 8
+	MaxConcurrency  int                 // 并发批次数，默认 4
+	MaxDocRunes     int                 // 单个段落截断长度（按字符），默认 800
+	TimeoutSeconds  int                 // 单批请求超时，默认 30 秒
+}
+
+// RerankModelProvider 按租户获取打分模型（ai.ModelProvider / ai.ClientFactory 满足该接口）
+// 这里只依赖 aiinterface，避免 rag 包引入 internal/ai 的全部实现
+type RerankModelProvider interface {
+	GetClient(ctx context.Context, tenantID, modelID string) (aiinterface.ModelClient, error)
+}
+
+// RerankModelResolver 为租户解析与配置模型对应的打分模型（如同一提供商、同一模型标识），没有时返回空字符串
+type RerankModelResolver interface {
+	ResolveRerankModel(ctx context.Context, tenantID, sourceTenantID, sourceModelID string) (string, error)
+}
+
+// LLMReranker 使用对话模型为 query/段落 打分的重排序器
+// 模型通过 RerankModelProvider 按租户获取，因此可以使用租户自己配置的任意模型；
+// 分数为 0-10 分制，归一化到 0-1 后与 Cross-Encoder 的分数同一量纲
+type LLMReranker struct {
+	provider        RerankModelProvider
+	modelID         string
+	defaultTenantID string
+	resolver        RerankModelResolver
+	batchSize       int
+	maxConcurrency  int
+	maxDocRunes     int
+	timeout         time.Duration
+}
+
+// NewLLMReranker 创建 LLM 重排序器
+func NewLLMReranker(provider RerankModelProvider, opts LLMRerankerOptions) (*LLMReranker, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("ModelProvider 不能为空")
+	}
+	if strings.TrimSpace(opts.ModelID) == "" {
+		return nil, fmt.Errorf("rerank 模型 ID 不能为空")
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 8
+	}
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = 4
+	}
+	maxDocRunes := opts.MaxDocRunes
+	if maxDocRunes <= 0 {
+		maxDocRunes = 800
+	}
+	timeout := opts.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = 30
+	}
+
+	return &LLMReranker{
+		provider:        provider,
+		modelID:         opts.ModelID,
+		defaultTenantID: opts.DefaultTenantID,
+		resolver:        opts.ModelResolver,
+		batchSize:       batchSize,
+		maxConcurrency:  maxConcurrency,
+		maxDocRunes:     maxDocRunes,
+		timeout:         time.Duration(timeout) * time.Second,
+	}, nil
+}
+
+// llmRerankSystemPrompt 打分提示词
+const llmRerankSystemPrompt = `你是检索结果相关性评估器。给定一个查询和若干编号段落，判断每个段落对回答查询的帮助程度，给出 0-10 的整数分：
+10 = 直接、完整地回答了查询；5 = 部分相关或只提供背景；0 = 完全无关。
+只输出 JSON 数组，每个段落一项，格式为 [{"id":1,"score":7}]，不要输出其他内容。`
+
+// Rerank 使用 LLM 对段落打分并重排序
+func (r *LLMReranker) Rerank(ctx context.Context, query string, documents []*SearchResult, topK int) ([]*SearchResult, error) {
+	if len(documents) == 0 {
+		return documents, nil
+	}
+
+	tenantID, modelID := r.resolveModel(ctx)
+	client, err := r.provider.GetClient(ctx, tenantID, modelID)
+	if err != nil {
+		return nil, fmt.Errorf("获取 rerank 模型失败: %w", err)
+	}
+
+	scores, err := scoreInBatches(ctx, len(documents), r.batchSize, r.maxConcurrency, func(ctx context.Context, start, end int) ([]float64, error) {
+		return r.scoreBatch(ctx, client, query, documents[start:end])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return applyScores(documents, scores, topK), nil
+}
+
+// resolveModel 确定本次打分使用的租户与模型：
+// 优先使用调用方租户自己的同款模型，没有时回退到配置租户的模型（回退会记录日志）
+func (r *LLMReranker) resolveModel(ctx context.Context) (string, string) {
+	tc, ok := tenant.FromContext(ctx)
+	if !ok || tc.TenantID == "" || tc.TenantID == r.defaultTenantID {
+		return r.defaultTenantID, r.modelID
+	}
+	if r.resolver != nil {
+		modelID, err := r.resolver.ResolveRerankModel(ctx, tc.TenantID, r.defaultTenantID, r.modelID)
+		if err != nil {
+			logger.Warn("解析租户 rerank 模型失败", zap.String("tenant_id", tc.TenantID), zap.Error(err))
+		} else if modelID != "" {
+			return tc.TenantID, modelID
+		}
+	}
+	if r.defaultTenantID == "" {
+		// 没有配置租户时只能按调用方租户查找配置的模型 ID
+		return tc.TenantID, r.modelID
+	}
+	logger.Info("租户没有可用的 rerank 模型，使用配置租户的模型",
+		zap.String("tenant_id", tc.TenantID), zap.String("model_tenant_id", r.defaultTenantID), zap.String("model_id", r.modelID))
+	return r.defaultTenantID, r.modelID
+}
+
+// scoreBatch 对一批段落打分，返回 0-1 的分数
+func (r *LLMReranker) scoreBatch(ctx context.Context, client aiinterface.ModelClient, query string, docs []*SearchResult) ([]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "查询：%s\n\n", query)
+	for i, doc := range docs {
+		fmt.Fprintf(&b, "[%d]\n%s\n\n", i+1, truncateRunes(doc.Content, r.maxDocRunes))
+	}
+
+	resp, err := client.ChatCompletion(ctx, &aiinterface.ChatCompletionRequest{
+		Messages: []aiinterface.Message{
+			{Role: "system", Content: llmRerankSystemPrompt},
+			{Role: "user", Content: b.String()},
+		},
+		Temperature: 0,
+		MaxTokens:   16*len(docs) + 32,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rerank 模型调用失败: %w", err)
+	}
+
+	return parseLLMScores(resp.Content, len(docs))
+}
+
+// parseLLMScores 解析模型输出的分数
+// 兼容 [{"id":1,"score":7}] 与 [7, 3, ...] 两种形式；缺失的段落记 0 分
+func parseLLMScores(content string, n int) ([]float64, error) {
+	start := strings.Index(content, "[")
+	end := strings.LastIndex(content, "]")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("rerank 模型输出不是 JSON 数组: %s", truncateRunes(content, 100))
+	}
+	raw := content[start : end+1]
+
+	scores := make([]float64, n)
+
+	var items []struct {
+		ID    int     `json:"id"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(raw), &items); err == nil && len(items) > 0 && items[0].ID > 0 {
+		for _, item := range items {
+			if item.ID >= 1 && item.ID <= n {
+				scores[item.ID-1] = clampScore(item.Score / 10)
+			}
+		}
+		return scores, nil
+	}
+
+	var plain []float64
+	if err := json.Unmarshal([]byte(raw), &plain); err != nil {
+		return nil, fmt.Errorf("解析 rerank 模型输出失败: %w", err)
+	}
+	for i := 0; i < n && i < len(plain); i++ {
+		scores[i] = clampScore(plain[i] / 10)
+	}
+	return scores, nil
+}
+
+func clampScore(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+
+	"backend/internal/logger"
+
+	"go.uber.org/zap"
 )
 
 // MultiKBSearchRequest 多知识库搜索请求
@@ -20,6 +24,7 @@ type MultiKBSearchRequest struct {
 	RerankTopK     int     // 重排序 TopK
 	ScoreThreshold float64 // 最小相关度阈值
 	WeightByKB     map[string]float64 // 每个知识库的权重 (可选)
+	Filter         *SearchFilter      // 元数据过滤条件，作用于每个知识库 (可选)
 }
 
 // MultiKBSearchResponse 多知识库搜索响应
@@ -29,6 +34,7 @@ type MultiKBSearchResponse struct {
 	TopK          int
 	SearchedKBs   []string // 实际搜索的知识库
 	FailedKBs     []string // 搜索失败的知识库
+	RerankError   string   // 重排序失败原因，失败时结果保持加权排序
 }
 
 // MultiKBSearchResult 带知识库信息的搜索结果
@@ -76,6 +82,7 @@ func (s *MultiKBSearcher) Search(ctx context.Context, req *MultiKBSearchRequest)
 				EnableHybrid:    req.EnableHybrid,
 				EnableRerank:    false, // 先不重排，最后统一重排
 				ScoreThreshold:  0,     // 先不过滤
+				Filter:          req.Filter,
 			}
 
 			resp, err := s.ragService.Search(ctx, searchReq)
@@ -130,6 +137,7 @@ func (s *MultiKBSearcher) Search(ctx context.Context, req *MultiKBSearchRequest)
 	})
 
 	// 重排序 (如果启用)
+	var rerankErr string
 	if req.EnableRerank && s.ragService.reranker != nil && len(allResults) > 0 {
 		// 转换为 SearchResult 列表
 		searchResults := make([]*SearchResult, len(allResults))
@@ -142,7 +150,7 @@ func (s *MultiKBSearcher) Search(ctx context.Context, req *MultiKBSearchRequest)
 			rerankLimit = req.TopK
 		}
 
-		reranked, err := s.ragService.reranker.Rerank(ctx, req.Query, searchResults, rerankLimit)
+		reranked, err := s.ragService.reranker.Rerank(withSearchTenant(ctx, req.TenantID), req.Query, searchResults, rerankLimit)
 		if err == nil {
 			// 更新排序后的结果
 			rerankedResults := make([]*MultiKBSearchResult, 0, len(reranked))
@@ -158,6 +166,9 @@ func (s *MultiKBSearcher) Search(ctx context.Context, req *MultiKBSearchRequest)
 				}
 			}
 			allResults = rerankedResults
+		} else {
+			rerankErr = err.Error()
+			logger.Warn("多知识库检索重排序失败，保留加权排序", zap.String("tenant_id", req.TenantID), zap.Error(err))
 		}
 	}
 
@@ -183,6 +194,7 @@ func (s *MultiKBSearcher) Search(ctx context.Context, req *MultiKBSearchRequest)
 		TopK:        req.TopK,
 		SearchedKBs: searchedKBs,
 		FailedKBs:   failedKBs,
+		RerankError: rerankErr,
 	}, nil
 }
 
@@ -1,8 +1,6 @@
 package parsers
 
 import (
-	"archive/zip"
-	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -28,28 +26,15 @@ func (p *DocxParser) Parse(reader io.Reader) (string, error) {
 	}
 
 	// 打开 ZIP 归档
-	zipReader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	archive, err := openZipArchive(data)
 	if err != nil {
 		return "", fmt.Errorf("打开 DOCX 失败: %w", err)
 	}
 
-	// 查找 word/document.xml
-	var documentXML []byte
-	for _, file := range zipReader.File {
-		if file.Name == "word/document.xml" {
-			rc, err := file.Open()
-			if err != nil {
-				return "", fmt.Errorf("打开 document.xml 失败: %w", err)
-			}
-			documentXML, err = io.ReadAll(rc)
-			rc.Close()
-			if err != nil {
-				return "", fmt.Errorf("读取 document.xml 失败: %w", err)
-			}
-			break
-		}
+	documentXML, err := archive.readPart("word/document.xml")
+	if err != nil {
+		return "", err
 	}
-
 	if documentXML == nil {
 		return "", fmt.Errorf("无效的 DOCX 文件：找不到 document.xml")
 	}
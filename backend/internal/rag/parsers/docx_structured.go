@@ -0,0 +1,254 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// StructuredDocument 保留标题层级与基础格式的文档
+type StructuredDocument struct {
+	Title      string
+	Author     string
+	Paragraphs []Paragraph
+}
+
+// docxStyle 段落样式的语义
+type docxStyle struct {
+	heading  int
+	quote    bool
+	title    bool
+	subtitle bool
+}
+
+// ParseStructured 解析 DOCX，按段落样式（Heading 1-6 / 大纲级别）识别标题层级，并保留粗体、斜体与引用
+func (p *DocxParser) ParseStructured(reader io.Reader) (*StructuredDocument, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取文档失败: %w", err)
+	}
+	archive, err := openZipArchive(data)
+	if err != nil {
+		return nil, fmt.Errorf("打开 DOCX 失败: %w", err)
+	}
+
+	documentXML, err := archive.readPart("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if documentXML == nil {
+		return nil, fmt.Errorf("无效的 DOCX 文件：找不到 document.xml")
+	}
+	stylesXML, err := archive.readPart("word/styles.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &StructuredDocument{}
+	if coreXML, err := archive.readPart("docProps/core.xml"); err == nil && coreXML != nil {
+		doc.Title, doc.Author = parseDocxCoreProps(coreXML)
+	}
+	paragraphs, title, err := parseDocxParagraphs(documentXML, parseDocxStyles(stylesXML))
+	if err != nil {
+		return nil, fmt.Errorf("解析文档内容失败: %w", err)
+	}
+	doc.Paragraphs = paragraphs
+	// 正文中的标题段落比文档属性更可靠
+	if title != "" {
+		doc.Title = title
+	}
+	return doc, nil
+}
+
+// parseDocxStyles 解析 styles.xml，按样式名称或大纲级别识别标题与引用样式
+func parseDocxStyles(data []byte) map[string]docxStyle {
+	styles := map[string]docxStyle{}
+	if data == nil {
+		return styles
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var id string
+	var current docxStyle
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "style":
+				id, current = xmlAttr(t, "styleId"), docxStyle{}
+			case "name":
+				if id == "" {
+					continue
+				}
+				name := strings.ToLower(strings.TrimSpace(xmlAttr(t, "val")))
+				switch {
+				case strings.HasPrefix(name, "heading "):
+					if level, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && level > 0 {
+						current.heading = level
+					}
+				case name == "title":
+					current.title = true
+				case name == "subtitle":
+					current.subtitle = true
+				case name == "quote" || name == "intense quote":
+					current.quote = true
+				}
+			case "outlineLvl":
+				if level, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && level < 9 && id != "" && current.heading == 0 {
+					current.heading = level + 1
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "style" && id != "" {
+				styles[id] = current
+				id = ""
+			}
+		}
+	}
+	// 兼容未导出样式表的文档
+	for level := 1; level <= 6; level++ {
+		key := "Heading" + strconv.Itoa(level)
+		if _, ok := styles[key]; !ok {
+			styles[key] = docxStyle{heading: level}
+		}
+	}
+	if _, ok := styles["Title"]; !ok {
+		styles["Title"] = docxStyle{title: true}
+	}
+	return styles
+}
+
+// parseDocxCoreProps 读取文档属性中的标题与作者
+func parseDocxCoreProps(data []byte) (title, author string) {
+	var core struct {
+		Title   string `xml:"title"`
+		Creator string `xml:"creator"`
+	}
+	if err := xml.Unmarshal(data, &core); err != nil {
+		return "", ""
+	}
+	return strings.TrimSpace(core.Title), strings.TrimSpace(core.Creator)
+}
+
+// parseDocxParagraphs 流式遍历 document.xml，文本框等嵌套段落并入外层段落；
+// 第一个标题之前的 Title / Subtitle 段落视为封面信息，不计入正文
+func parseDocxParagraphs(data []byte, styles map[string]docxStyle) ([]Paragraph, string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		paragraphs []Paragraph
+		para       Paragraph
+		style      docxStyle
+		run        Run
+		title      string
+		seenHead   bool
+		paraDepth  int
+		inRunProps bool
+		inText     bool
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				paraDepth++
+				if paraDepth == 1 {
+					para, style = Paragraph{}, docxStyle{}
+				}
+			case "pStyle":
+				if paraDepth == 1 {
+					style = styles[xmlAttr(t, "val")]
+					para.HeadingLevel, para.Quote = style.heading, style.quote
+				}
+			case "outlineLvl":
+				if level, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && level < 9 && paraDepth == 1 {
+					para.HeadingLevel = level + 1
+				}
+			case "r":
+				run = Run{}
+			case "rPr":
+				inRunProps = true
+			case "b":
+				if inRunProps {
+					run.Bold = docxToggle(t)
+				}
+			case "i":
+				if inRunProps {
+					run.Italic = docxToggle(t)
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if !inRunProps {
+					para.Runs = append(para.Runs, Run{Text: " ", Bold: run.Bold, Italic: run.Italic})
+				}
+			case "br", "cr":
+				// 段内换行按空格处理，分页符忽略
+				if xmlAttr(t, "type") != "page" {
+					para.Runs = append(para.Runs, Run{Text: " ", Bold: run.Bold, Italic: run.Italic})
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				paraDepth--
+				if paraDepth > 0 {
+					continue
+				}
+				p, ok := finishParagraph(para)
+				if !ok {
+					continue
+				}
+				if !seenHead && (style.title || style.subtitle) {
+					if style.title && title == "" {
+						title = p.Text()
+					}
+					continue
+				}
+				seenHead = seenHead || p.HeadingLevel > 0
+				paragraphs = append(paragraphs, p)
+			case "rPr":
+				inRunProps = false
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText && paraDepth > 0 {
+				para.Runs = append(para.Runs, Run{Text: string(t), Bold: run.Bold, Italic: run.Italic})
+			}
+		}
+	}
+	return paragraphs, title, nil
+}
+
+// docxToggle 读取 <w:b/>、<w:i w:val="0"/> 等开关属性
+func docxToggle(t xml.StartElement) bool {
+	switch strings.ToLower(xmlAttr(t, "val")) {
+	case "0", "false", "off":
+		return false
+	}
+	return true
+}
+
+// xmlAttr 按本地名读取属性
+func xmlAttr(t xml.StartElement, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
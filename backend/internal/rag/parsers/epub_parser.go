@@ -0,0 +1,510 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// EpubParser EPUB 电子书解析器（EPUB 2 / EPUB 3）
+type EpubParser struct{}
+
+// NewEpubParser 创建 EPUB 解析器
+func NewEpubParser() *EpubParser {
+	return &EpubParser{}
+}
+
+// EpubBook 按阅读顺序（spine）展开的电子书
+type EpubBook struct {
+	Title    string
+	Author   string
+	Language string
+	Sections []EpubSection
+}
+
+// EpubSection 目录中的一个条目及其正文；Depth 为 0 表示不在目录中、应并入上一节的内容
+type EpubSection struct {
+	Title      string
+	Depth      int
+	Paragraphs []Paragraph
+}
+
+// epubTocEntry 目录条目（nav.xhtml 或 toc.ncx）
+type epubTocEntry struct {
+	title    string
+	file     string
+	fragment string
+	depth    int
+}
+
+// Parse 提取纯文本，供知识库索引使用
+func (p *EpubParser) Parse(reader io.Reader) (string, error) {
+	book, err := p.ParseBook(reader)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, section := range book.Sections {
+		if section.Title != "" {
+			sb.WriteString(section.Title)
+			sb.WriteString("\n")
+		}
+		for _, para := range section.Paragraphs {
+			if text := para.Text(); text != "" && !para.Break {
+				sb.WriteString(text)
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// SupportedExtensions 支持的扩展名
+func (p *EpubParser) SupportedExtensions() []string {
+	return []string{".epub"}
+}
+
+// CanParse 检查是否支持该扩展名
+func (p *EpubParser) CanParse(ext string) bool {
+	for _, e := range p.SupportedExtensions() {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBook 按 spine 顺序解析正文，章节标题与层级取自导航目录
+func (p *EpubParser) ParseBook(reader io.Reader) (*EpubBook, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("读取电子书失败: %w", err)
+	}
+	archive, err := openZipArchive(data)
+	if err != nil {
+		return nil, fmt.Errorf("打开 EPUB 失败: %w", err)
+	}
+
+	containerXML, err := archive.readPart("META-INF/container.xml")
+	if err != nil {
+		return nil, err
+	}
+	if containerXML == nil {
+		return nil, errors.New("无效的 EPUB 文件：找不到 META-INF/container.xml")
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(containerXML, &container); err != nil || len(container.Rootfiles) == 0 {
+		return nil, errors.New("无效的 EPUB 文件：container.xml 格式错误")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	opfXML, err := archive.readPart(opfPath)
+	if err != nil {
+		return nil, err
+	}
+	if opfXML == nil {
+		return nil, fmt.Errorf("无效的 EPUB 文件：找不到 %s", opfPath)
+	}
+
+	var pkg struct {
+		Title    []string `xml:"metadata>title"`
+		Creator  []string `xml:"metadata>creator"`
+		Language []string `xml:"metadata>language"`
+		Items    []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+		Spine struct {
+			Toc      string `xml:"toc,attr"`
+			ItemRefs []struct {
+				IDRef  string `xml:"idref,attr"`
+				Linear string `xml:"linear,attr"`
+			} `xml:"itemref"`
+		} `xml:"spine"`
+	}
+	if err := xml.Unmarshal(opfXML, &pkg); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", opfPath, err)
+	}
+
+	book := &EpubBook{
+		Title:    firstTrimmed(pkg.Title),
+		Author:   firstTrimmed(pkg.Creator),
+		Language: firstTrimmed(pkg.Language),
+	}
+	opfDir := path.Dir(opfPath)
+	hrefs := make(map[string]string, len(pkg.Items))
+	var navPath, ncxPath string
+	for _, item := range pkg.Items {
+		full := resolveEpubHref(opfDir, item.Href)
+		hrefs[item.ID] = full
+		if strings.Contains(" "+item.Properties+" ", " nav ") {
+			navPath = full
+		}
+		if item.ID == pkg.Spine.Toc || item.MediaType == "application/x-dtbncx+xml" {
+			ncxPath = full
+		}
+	}
+
+	// 优先使用 EPUB 3 导航文档，缺失时回退到 EPUB 2 的 NCX
+	var toc []epubTocEntry
+	if navPath != "" {
+		if navXHTML, err := archive.readPart(navPath); err == nil && navXHTML != nil {
+			toc = parseEpubNav(navXHTML, path.Dir(navPath))
+		}
+	}
+	if len(toc) == 0 && ncxPath != "" {
+		if ncxXML, err := archive.readPart(ncxPath); err == nil && ncxXML != nil {
+			toc = parseEpubNCX(ncxXML, path.Dir(ncxPath))
+		}
+	}
+	tocByFile := make(map[string][]epubTocEntry)
+	for _, entry := range toc {
+		tocByFile[entry.file] = append(tocByFile[entry.file], entry)
+	}
+
+	for _, ref := range pkg.Spine.ItemRefs {
+		file, ok := hrefs[ref.IDRef]
+		if !ok || file == navPath || ref.Linear == "no" {
+			continue
+		}
+		content, err := archive.readPart(file)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		book.Sections = append(book.Sections, splitEpubDocument(content, tocByFile[file])...)
+	}
+	if len(book.Sections) == 0 {
+		return nil, errors.New("电子书中没有可读取的正文")
+	}
+	return book, nil
+}
+
+// splitEpubDocument 按目录锚点切分一个内容文件；同一位置的多个条目只有最后一个承载正文
+func splitEpubDocument(content []byte, entries []epubTocEntry) []EpubSection {
+	anchors := make(map[string]bool)
+	for _, e := range entries {
+		if e.fragment != "" {
+			anchors[e.fragment] = true
+		}
+	}
+	paragraphs, positions := parseXHTMLParagraphs(content, anchors)
+
+	type start struct {
+		entry epubTocEntry
+		pos   int
+	}
+	var starts []start
+	for _, e := range entries {
+		pos := 0
+		if e.fragment != "" {
+			p, ok := positions[e.fragment]
+			if !ok {
+				continue
+			}
+			pos = p
+		}
+		starts = append(starts, start{entry: e, pos: pos})
+	}
+
+	var sections []EpubSection
+	if len(starts) == 0 || starts[0].pos > 0 {
+		end := len(paragraphs)
+		if len(starts) > 0 {
+			end = starts[0].pos
+		}
+		if end > 0 {
+			sections = append(sections, EpubSection{Paragraphs: paragraphs[:end]})
+		}
+	}
+	for i, s := range starts {
+		end := len(paragraphs)
+		if i+1 < len(starts) {
+			end = max(starts[i+1].pos, s.pos)
+		}
+		sections = append(sections, EpubSection{
+			Title:      s.entry.title,
+			Depth:      s.entry.depth,
+			Paragraphs: paragraphs[s.pos:end],
+		})
+	}
+	return sections
+}
+
+// parseEpubNav 解析 EPUB 3 nav 文档中 epub:type="toc" 的嵌套列表
+func parseEpubNav(data []byte, baseDir string) []epubTocEntry {
+	dec := newHTMLDecoder(data)
+	var (
+		entries []epubTocEntry
+		inToc   bool
+		navSkip int
+		olDepth int
+		current *epubTocEntry
+		label   strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "nav":
+				if inToc {
+					navSkip++
+				} else if xmlAttr(t, "type") == "toc" {
+					inToc = true
+				}
+			case "ol":
+				if inToc {
+					olDepth++
+				}
+			case "a", "span":
+				if inToc && current == nil {
+					file, fragment := splitEpubHref(baseDir, xmlAttr(t, "href"))
+					current = &epubTocEntry{file: file, fragment: fragment, depth: olDepth}
+					label.Reset()
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "nav":
+				if navSkip > 0 {
+					navSkip--
+				} else if inToc {
+					return entries
+				}
+			case "ol":
+				if inToc {
+					olDepth--
+				}
+			case "a", "span":
+				if current != nil {
+					current.title = collapseSpace(label.String())
+					if current.file != "" && current.title != "" {
+						entries = append(entries, *current)
+					}
+					current = nil
+				}
+			}
+		case xml.CharData:
+			if current != nil {
+				label.Write(t)
+			}
+		}
+	}
+	return entries
+}
+
+// parseEpubNCX 解析 EPUB 2 的 toc.ncx
+func parseEpubNCX(data []byte, baseDir string) []epubTocEntry {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	var (
+		entries []epubTocEntry
+		stack   []int // navPoint 对应的条目下标
+		inLabel bool
+		label   strings.Builder
+	)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navPoint":
+				entries = append(entries, epubTocEntry{depth: len(stack) + 1})
+				stack = append(stack, len(entries)-1)
+			case "text":
+				if len(stack) > 0 {
+					inLabel = true
+					label.Reset()
+				}
+			case "content":
+				if len(stack) > 0 {
+					idx := stack[len(stack)-1]
+					entries[idx].file, entries[idx].fragment = splitEpubHref(baseDir, xmlAttr(t, "src"))
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "navPoint":
+				if len(stack) > 0 {
+					stack = stack[:len(stack)-1]
+				}
+			case "text":
+				if inLabel && len(stack) > 0 {
+					idx := stack[len(stack)-1]
+					if entries[idx].title == "" {
+						entries[idx].title = collapseSpace(label.String())
+					}
+				}
+				inLabel = false
+			}
+		case xml.CharData:
+			if inLabel {
+				label.Write(t)
+			}
+		}
+	}
+	valid := entries[:0]
+	for _, e := range entries {
+		if e.file != "" && e.title != "" {
+			valid = append(valid, e)
+		}
+	}
+	return valid
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"li": true, "dt": true, "dd": true, "tr": true, "pre": true, "figcaption": true, "aside": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "blockquote": true,
+}
+
+var skippedElements = map[string]bool{"head": true, "script": true, "style": true, "title": true}
+
+// parseXHTMLParagraphs 将 XHTML 正文转换为段落，并记录目录锚点所在的段落下标
+func parseXHTMLParagraphs(data []byte, anchors map[string]bool) ([]Paragraph, map[string]int) {
+	dec := newHTMLDecoder(data)
+	var (
+		paragraphs []Paragraph
+		para       Paragraph
+		bold       int
+		italic     int
+		quote      int
+		skip       int
+	)
+	positions := make(map[string]int)
+	flush := func() {
+		if p, ok := finishParagraph(para); ok {
+			paragraphs = append(paragraphs, p)
+		}
+		para = Paragraph{Quote: quote > 0}
+	}
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if skippedElements[name] {
+				skip++
+				continue
+			}
+			if id := xmlAttr(t, "id"); id != "" && anchors[id] {
+				flush()
+				if _, seen := positions[id]; !seen {
+					positions[id] = len(paragraphs)
+				}
+			}
+			switch {
+			case name == "br":
+				flush()
+			case name == "hr":
+				flush()
+				paragraphs = append(paragraphs, Paragraph{Break: true})
+			case name == "b" || name == "strong":
+				bold++
+			case name == "i" || name == "em":
+				italic++
+			case blockElements[name]:
+				if name == "blockquote" {
+					quote++
+				}
+				flush()
+				if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+					para.HeadingLevel = int(name[1] - '0')
+				}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if skippedElements[name] {
+				skip = max(skip-1, 0)
+				continue
+			}
+			switch {
+			case name == "b" || name == "strong":
+				bold = max(bold-1, 0)
+			case name == "i" || name == "em":
+				italic = max(italic-1, 0)
+			case blockElements[name]:
+				if name == "blockquote" {
+					quote = max(quote-1, 0)
+				}
+				flush()
+			}
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if text := whitespaceRe.ReplaceAllString(string(t), " "); text != "" {
+				para.Runs = append(para.Runs, Run{Text: text, Bold: bold > 0, Italic: italic > 0})
+			}
+		}
+	}
+	flush()
+	return paragraphs, positions
+}
+
+var whitespaceRe = regexp.MustCompile(`[\s]+`)
+
+// newHTMLDecoder 宽松模式解析 XHTML，兼容 HTML 实体与未闭合标签
+func newHTMLDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	return dec
+}
+
+// resolveEpubHref 将相对链接解析为压缩包内路径
+func resolveEpubHref(baseDir, href string) string {
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return strings.TrimPrefix(path.Join(baseDir, href), "./")
+}
+
+// splitEpubHref 拆分链接中的文件与锚点
+func splitEpubHref(baseDir, href string) (string, string) {
+	if href == "" {
+		return "", ""
+	}
+	file, fragment, _ := strings.Cut(href, "#")
+	if file == "" {
+		return "", ""
+	}
+	return resolveEpubHref(baseDir, file), fragment
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(whitespaceRe.ReplaceAllString(s, " "))
+}
+
+func firstTrimmed(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -24,6 +24,7 @@ func NewParserRegistry() *ParserRegistry {
 	r.Register(NewHTMLParser())
 	r.Register(NewDocxParser())
 	r.Register(NewDocParser())
+	r.Register(NewEpubParser())
 	
 	return r
 }
@@ -0,0 +1,113 @@
+package parsers
+
+import (
+	"strings"
+)
+
+// Run 带格式的文本片段
+type Run struct {
+	Text   string
+	Bold   bool
+	Italic bool
+}
+
+// Paragraph 保留基础格式的段落
+type Paragraph struct {
+	HeadingLevel int // 1-6 为标题级别，0 为正文
+	Quote        bool
+	Break        bool // 场景分隔（水平线或 * * *）
+	Runs         []Run
+}
+
+// Text 段落纯文本
+func (p Paragraph) Text() string {
+	var sb strings.Builder
+	for _, r := range p.Runs {
+		sb.WriteString(r.Text)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// Markdown 以 Markdown 输出段落（标题、引用、粗体、斜体与分隔线）
+func (p Paragraph) Markdown() string {
+	if p.Break {
+		return "---"
+	}
+	var sb strings.Builder
+	switch {
+	case p.HeadingLevel > 0:
+		sb.WriteString(strings.Repeat("#", min(p.HeadingLevel, 6)))
+		sb.WriteString(" ")
+	case p.Quote:
+		sb.WriteString("> ")
+	}
+	for _, r := range mergeRuns(p.Runs) {
+		text := r.Text
+		// 标记须紧贴文字，首尾空白移到标记之外
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || (!r.Bold && !r.Italic) || p.HeadingLevel > 0 {
+			sb.WriteString(text)
+			continue
+		}
+		lead := text[:strings.Index(text, trimmed)]
+		trail := text[len(lead)+len(trimmed):]
+		marker := ""
+		if r.Bold {
+			marker += "**"
+		}
+		if r.Italic {
+			marker += "*"
+		}
+		closing := marker
+		if r.Bold && r.Italic {
+			closing = "***"
+		}
+		sb.WriteString(lead + marker + trimmed + closing + trail)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// isBreakText 判断是否为场景分隔行
+func isBreakText(text string) bool {
+	switch strings.ReplaceAll(strings.TrimSpace(text), " ", "") {
+	case "***", "---", "###", "◇◇◇", "＊＊＊":
+		return true
+	}
+	return false
+}
+
+// mergeRuns 合并相邻且格式相同的片段
+func mergeRuns(runs []Run) []Run {
+	var merged []Run
+	for _, r := range runs {
+		if r.Text == "" {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Bold == r.Bold && merged[n-1].Italic == r.Italic {
+			merged[n-1].Text += r.Text
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// finishParagraph 规范化段落，空段落返回 false
+func finishParagraph(p Paragraph) (Paragraph, bool) {
+	p.Runs = mergeRuns(p.Runs)
+	if p.Break {
+		return p, true
+	}
+	text := p.Text()
+	if text == "" {
+		return p, false
+	}
+	if isBreakText(text) {
+		return Paragraph{Break: true}, true
+	}
+	if n := len(p.Runs); n > 0 {
+		p.Runs[0].Text = strings.TrimLeft(p.Runs[0].Text, " \t　")
+		p.Runs[n-1].Text = strings.TrimRight(p.Runs[n-1].Text, " \t　")
+	}
+	return p, true
+}
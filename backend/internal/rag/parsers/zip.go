@@ -0,0 +1,63 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 压缩包（DOCX、EPUB）解压上限，防止压缩炸弹耗尽内存
+const (
+	maxZipPartSize  = 64 << 20  // 单个文件解压后的上限
+	maxZipTotalSize = 256 << 20 // 单个压缩包累计解压的上限
+)
+
+// ErrZipTooLarge 压缩包中的文件解压后超过大小限制
+var ErrZipTooLarge = errors.New("压缩包解压后超过大小限制")
+
+// zipArchive 带解压预算的压缩包，readPart 累计扣减，同一文件重复读取同样计入
+type zipArchive struct {
+	reader    *zip.Reader
+	remaining int64
+}
+
+// openZipArchive 打开内存中的压缩包
+func openZipArchive(data []byte) (*zipArchive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipArchive{reader: reader, remaining: maxZipTotalSize}, nil
+}
+
+// readPart 读取压缩包中的文件，不存在时返回 nil；
+// 按实际解压字节数限制（不信任文件头中声明的大小），超过单文件或累计上限时返回 ErrZipTooLarge
+func (a *zipArchive) readPart(name string) ([]byte, error) {
+	for _, file := range a.reader.File {
+		if file.Name != name {
+			continue
+		}
+		limit := min(int64(maxZipPartSize), a.remaining)
+		if file.UncompressedSize64 > uint64(limit) {
+			return nil, fmt.Errorf("%w: %s", ErrZipTooLarge, name)
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("打开 %s 失败: %w", name, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %w", name, err)
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("%w: %s", ErrZipTooLarge, name)
+		}
+		a.remaining -= int64(len(data))
+		return data, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,62 @@
+package parsers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("create %s: %v", name, err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestZipArchiveRejectsOversizedPart(t *testing.T) {
+	// 高压缩比的单个文件：压缩后很小，解压后超过单文件上限
+	data := buildZip(t, map[string]string{"word/document.xml": strings.Repeat("a", maxZipPartSize+1)})
+	archive, err := openZipArchive(data)
+	if err != nil {
+		t.Fatalf("openZipArchive: %v", err)
+	}
+	if _, err := archive.readPart("word/document.xml"); !errors.Is(err, ErrZipTooLarge) {
+		t.Fatalf("expected ErrZipTooLarge, got %v", err)
+	}
+	if _, err := NewDocxParser().Parse(bytes.NewReader(data)); !errors.Is(err, ErrZipTooLarge) {
+		t.Fatalf("expected Parse to fail with ErrZipTooLarge, got %v", err)
+	}
+}
+
+func TestZipArchiveTotalBudget(t *testing.T) {
+	archive, err := openZipArchive(buildZip(t, map[string]string{"chapter.xhtml": strings.Repeat("b", 600)}))
+	if err != nil {
+		t.Fatalf("openZipArchive: %v", err)
+	}
+	archive.remaining = 1000
+
+	if part, err := archive.readPart("chapter.xhtml"); err != nil || len(part) != 600 {
+		t.Fatalf("first read: len=%d err=%v", len(part), err)
+	}
+	// 同一文件被 spine 重复引用时同样计入累计解压量
+	if _, err := archive.readPart("chapter.xhtml"); !errors.Is(err, ErrZipTooLarge) {
+		t.Fatalf("expected ErrZipTooLarge after budget is spent, got %v", err)
+	}
+	if part, err := archive.readPart("missing.xhtml"); err != nil || part != nil {
+		t.Fatalf("missing part should return nil: %v %v", part, err)
+	}
+}
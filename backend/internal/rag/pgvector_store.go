@@ -72,8 +72,9 @@ func (s *PGVectorStore) AddVectors(ctx context.Context, vectors []*Vector) error
 // kbID: 知识库ID
 // queryVector: 查询向量
 // topK: 返回结果数量
+// filter: 元数据过滤条件（可选），下推为 SQL 条件
 // 返回: 搜索结果和错误
-func (s *PGVectorStore) Search(ctx context.Context, kbID string, queryVector []float32, topK int) ([]*SearchResult, error) {
+func (s *PGVectorStore) Search(ctx context.Context, kbID string, queryVector []float32, topK int, filter *SearchFilter) ([]*SearchResult, error) {
 	if len(queryVector) == 0 {
 		return nil, fmt.Errorf("查询向量不能为空")
 	}
@@ -85,6 +86,11 @@ func (s *PGVectorStore) Search(ctx context.Context, kbID string, queryVector []f
 	// 构建向量字符串 '[0.1, 0.2, ...]'
 	vectorStr := vectorToString(queryVector)
 
+	filterSQL, filterArgs, err := filter.toSQL()
+	if err != nil {
+		return nil, err
+	}
+
 	// 使用余弦相似度搜索
 	// 1 - (embedding <=> query_vector) 计算余弦相似度
 	// <=> 是pgvector的余弦距离操作符
@@ -95,14 +101,16 @@ func (s *PGVectorStore) Search(ctx context.Context, kbID string, queryVector []f
 			document_id,
 			content,
 			chunk_index,
-			metadata,
-			1 - (embedding <=> $1::vector) AS similarity
+			metadata_raw AS metadata,
+			1 - (embedding <=> ?::vector) AS similarity
 		FROM knowledge_chunks
-		WHERE knowledge_base_id = $2
-			AND deleted_at IS NULL
-		ORDER BY embedding <=> $1::vector
-		LIMIT $3
+		WHERE knowledge_base_id = ?
+			AND deleted_at IS NULL` + filterSQL + `
+		ORDER BY embedding <=> ?::vector
+		LIMIT ?
 	`
+	args := append([]any{vectorStr, kbID}, filterArgs...)
+	args = append(args, vectorStr, topK)
 
 	var results []struct {
 		ID              string                 `gorm:"column:id"`
@@ -110,11 +118,11 @@ func (s *PGVectorStore) Search(ctx context.Context, kbID string, queryVector []f
 		DocumentID      string                 `gorm:"column:document_id"`
 		Content         string                 `gorm:"column:content"`
 		ChunkIndex      int                    `gorm:"column:chunk_index"`
-		Metadata        map[string]interface{} `gorm:"column:metadata;type:jsonb"`
+		Metadata        map[string]interface{} `gorm:"column:metadata;type:jsonb;serializer:json"`
 		Similarity      float64                `gorm:"column:similarity"`
 	}
 
-	if err := s.db.WithContext(ctx).Raw(query, vectorStr, kbID, topK).Scan(&results).Error; err != nil {
+	if err := s.db.WithContext(ctx).Raw(query, args...).Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("向量搜索失败: %w", err)
 	}
 
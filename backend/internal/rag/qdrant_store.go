@@ -139,7 +139,8 @@ func (s *QdrantStore) AddVectors(ctx context.Context, vectors []*Vector) error {
 }
 
 // Search 在指定知识库内执行相似度检索
-func (s *QdrantStore) Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int) ([]*SearchResult, error) {
+// filter 转换为 Qdrant payload 过滤条件，与知识库条件一起下推
+func (s *QdrantStore) Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int, filter *SearchFilter) ([]*SearchResult, error) {
 	if len(queryVector) == 0 {
 		return nil, fmt.Errorf("查询向量不能为空")
 	}
@@ -150,11 +151,24 @@ func (s *QdrantStore) Search(ctx context.Context, knowledgeBaseID string, queryV
 		topK = 5
 	}
 
+	qf := mustMatchFilter(map[string]string{"knowledge_base_id": knowledgeBaseID})
+	must, mustNot, err := filter.toQdrant()
+	if err != nil {
+		return nil, err
+	}
+	if len(must) > 0 || len(mustNot) > 0 {
+		if qf == nil {
+			qf = &qdrantFilter{}
+		}
+		qf.Must = append(qf.Must, must...)
+		qf.MustNot = append(qf.MustNot, mustNot...)
+	}
+
 	req := searchRequest{
 		Vector:      queryVector,
 		Limit:       topK,
 		WithPayload: true,
-		Filter:      mustMatchFilter(map[string]string{"knowledge_base_id": knowledgeBaseID}),
+		Filter:      qf,
 	}
 
 	var resp searchResponse
@@ -350,7 +364,7 @@ func mustMatchFilter(values map[string]string) *qdrantFilter {
 		}
 		must = append(must, fieldCondition{
 			Key:   k,
-			Match: fieldMatch{Value: v},
+			Match: &fieldMatch{Value: v},
 		})
 	}
 	if len(must) == 0 {
@@ -412,16 +426,31 @@ type upsertPointsRequest struct {
 }
 
 type fieldCondition struct {
-	Key   string     `json:"key"`
-	Match fieldMatch `json:"match"`
+	Key     string            `json:"key,omitempty"`
+	Match   *fieldMatch       `json:"match,omitempty"`
+	Range   *fieldRange       `json:"range,omitempty"`
+	IsEmpty *isEmptyCondition `json:"is_empty,omitempty"`
 }
 
 type fieldMatch struct {
-	Value any `json:"value"`
+	Value any   `json:"value,omitempty"`
+	Any   []any `json:"any,omitempty"`
+}
+
+type fieldRange struct {
+	Gt  *float64 `json:"gt,omitempty"`
+	Gte *float64 `json:"gte,omitempty"`
+	Lt  *float64 `json:"lt,omitempty"`
+	Lte *float64 `json:"lte,omitempty"`
+}
+
+type isEmptyCondition struct {
+	Key string `json:"key"`
 }
 
 type qdrantFilter struct {
-	Must []fieldCondition `json:"must,omitempty"`
+	Must    []fieldCondition `json:"must,omitempty"`
+	MustNot []fieldCondition `json:"must_not,omitempty"`
 }
 
 type deletePointsRequest struct {
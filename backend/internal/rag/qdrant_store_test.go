@@ -82,7 +82,7 @@ func TestQdrantStoreSearch(t *testing.T) {
 		t.Fatalf("init store: %v", err)
 	}
 
-	results, err := store.Search(context.Background(), "kb", []float32{0.1, 0.2}, 3)
+	results, err := store.Search(context.Background(), "kb", []float32{0.1, 0.2}, 3, nil)
 	if err != nil {
 		t.Fatalf("search error: %v", err)
 	}
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"backend/internal/infra/queue"
+	"backend/internal/logger"
 	"backend/internal/metrics"
 	"backend/internal/rag/parsers"
+	"backend/internal/tenant"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -230,13 +233,17 @@ type SearchRequest struct {
 	EnableRerank   bool    // 启用重排序
 	RerankTopK     int     // 重排序 TopK
 	ScoreThreshold float64 // 最小相关度阈值
+
+	// Filter 元数据过滤条件（可选），向量检索时下推到向量存储
+	Filter *SearchFilter
 }
 
 // SearchResponse 搜索响应
 type SearchResponse struct {
-	Results []*SearchResult
-	Query   string
-	TopK    int
+	Results     []*SearchResult
+	Query       string
+	TopK        int
+	RerankError string // 重排序失败原因，失败时结果保持融合排序
 }
 
 // Search 语义搜索
@@ -244,6 +251,10 @@ func (s *RAGService) Search(ctx context.Context, req *SearchRequest) (*SearchRes
 	// 记录开始时间
 	start := time.Now()
 
+	if err := req.Filter.Validate(); err != nil {
+		return nil, err
+	}
+
 	// 1. 验证知识库
 	var kb KnowledgeBase
 	if err := s.db.WithContext(ctx).
@@ -266,7 +277,7 @@ func (s *RAGService) Search(ctx context.Context, req *SearchRequest) (*SearchRes
 	}
 
 	// 3. 向量搜索
-	vectorResults, err := s.vectorStore.Search(ctx, req.KnowledgeBaseID, queryEmbedding, req.TopK)
+	vectorResults, err := s.vectorStore.Search(ctx, req.KnowledgeBaseID, queryEmbedding, req.TopK, req.Filter)
 	if err != nil {
 		// 记录失败指标
 		metrics.RAGSearchesTotal.WithLabelValues(req.KnowledgeBaseID, "failed").Inc()
@@ -279,6 +290,8 @@ func (s *RAGService) Search(ctx context.Context, req *SearchRequest) (*SearchRes
 	if req.EnableHybrid && s.keywordSearcher != nil {
 		keywordResults, err := s.keywordSearcher.SearchKeywords(ctx, req.KnowledgeBaseID, req.Query, req.TopK)
 		if err == nil {
+			// 关键词检索不支持下推过滤，在内存中按相同条件筛选
+			keywordResults = filterResults(keywordResults, req.Filter)
 			// RRF 融合 (Reciprocal Rank Fusion)
 			finalResults = s.fusionResults(vectorResults, keywordResults, 60)
 		} else {
@@ -288,17 +301,20 @@ func (s *RAGService) Search(ctx context.Context, req *SearchRequest) (*SearchRes
 	}
 
 	// 5. 重排序 (Rerank)
+	var rerankErr string
 	if req.EnableRerank && s.reranker != nil && len(finalResults) > 0 {
 		rerankLimit := req.RerankTopK
 		if rerankLimit <= 0 {
 			rerankLimit = req.TopK // 默认与 TopK 一致
 		}
 
-		reranked, err := s.reranker.Rerank(ctx, req.Query, finalResults, rerankLimit)
+		reranked, err := s.reranker.Rerank(withSearchTenant(ctx, req.TenantID), req.Query, finalResults, rerankLimit)
 		if err == nil {
 			finalResults = reranked
 		} else {
-			fmt.Printf("Rerank failed: %v\n", err)
+			rerankErr = err.Error()
+			logger.Warn("重排序失败，保留原排序", zap.String("tenant_id", req.TenantID),
+				zap.String("knowledge_base_id", req.KnowledgeBaseID), zap.Error(err))
 		}
 	}
 
@@ -324,12 +340,24 @@ func (s *RAGService) Search(ctx context.Context, req *SearchRequest) (*SearchRes
 	}
 
 	return &SearchResponse{
-		Results: finalResults,
-		Query:   req.Query,
-		TopK:    req.TopK,
+		Results:     finalResults,
+		Query:       req.Query,
+		TopK:        req.TopK,
+		RerankError: rerankErr,
 	}, nil
 }
 
+// withSearchTenant 确保上下文携带检索请求的租户，供按租户选择模型的重排序器使用
+func withSearchTenant(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		return ctx
+	}
+	if tc, ok := tenant.FromContext(ctx); ok && tc.TenantID != "" {
+		return ctx
+	}
+	return tenant.WithTenantContext(ctx, tenant.TenantContext{TenantID: tenantID})
+}
+
 // fusionResults 实现 RRF 融合算法
 func (s *RAGService) fusionResults(listA, listB []*SearchResult, k float64) []*SearchResult {
 	scores := make(map[string]float64)
@@ -41,7 +41,7 @@ func (f *fakeVectorStore) AddVectors(ctx context.Context, vectors []*Vector) err
 	return nil
 }
 
-func (f *fakeVectorStore) Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int) ([]*SearchResult, error) {
+func (f *fakeVectorStore) Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int, filter *SearchFilter) ([]*SearchResult, error) {
 	if len(f.searchReply) > 0 {
 		return f.searchReply, nil
 	}
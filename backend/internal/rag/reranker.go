@@ -187,30 +187,6 @@ func tokenize(text string) []string {
 	return tokens
 }
 
-// CrossEncoderReranker 基于 Cross-Encoder 的重排序器 (需要外部 API)
-type CrossEncoderReranker struct {
-	apiEndpoint string
-	apiKey      string
-	model       string
-}
-
-// NewCrossEncoderReranker 创建 Cross-Encoder 重排序器
-func NewCrossEncoderReranker(endpoint, apiKey, model string) *CrossEncoderReranker {
-	return &CrossEncoderReranker{
-		apiEndpoint: endpoint,
-		apiKey:      apiKey,
-		model:       model,
-	}
-}
-
-// Rerank 使用 Cross-Encoder API 进行重排序
-func (r *CrossEncoderReranker) Rerank(ctx context.Context, query string, documents []*SearchResult, topK int) ([]*SearchResult, error) {
-	// TODO: 实现外部 API 调用 (Cohere, Jina, 或自建服务)
-	// 目前降级到简单重排序
-	simple := NewSimpleReranker()
-	return simple.Rerank(ctx, query, documents, topK)
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
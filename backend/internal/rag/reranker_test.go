@@ -0,0 +1,218 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"backend/internal/logger"
+	"backend/internal/tenant"
+	"backend/pkg/aiinterface"
+)
+
+func rerankDocs(contents ...string) []*SearchResult {
+	docs := make([]*SearchResult, len(contents))
+	for i, c := range contents {
+		docs[i] = &SearchResult{ChunkID: fmt.Sprintf("c%d", i), Content: c, Score: 0.5}
+	}
+	return docs
+}
+
+func TestCrossEncoderRerankerCohereProtocol(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/v1/rerank" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req struct {
+			Query     string   `json:"query"`
+			Documents []string `json:"documents"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		// 包含“林远”的段落得分更高，结果按分数倒序返回以验证 index 映射
+		type result struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		}
+		results := make([]result, 0, len(req.Documents))
+		for i := len(req.Documents) - 1; i >= 0; i-- {
+			score := 0.1
+			if strings.Contains(req.Documents[i], "林远") {
+				score = 0.9
+			}
+			results = append(results, result{Index: i, RelevanceScore: score})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": results})
+	}))
+	defer server.Close()
+
+	reranker, err := NewCrossEncoderReranker(CrossEncoderOptions{
+		Endpoint:  server.URL + "/v1",
+		APIKey:    "secret",
+		Model:     "bge-reranker-v2-m3",
+		BatchSize: 2,
+	})
+	if err != nil {
+		t.Fatalf("NewCrossEncoderReranker: %v", err)
+	}
+
+	docs := rerankDocs("苏晴在咖啡馆", "天气很好", "林远走进雨夜", "城市的灯光", "林远的过去")
+	results, err := reranker.Rerank(context.Background(), "林远是谁", docs, 2)
+	if err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected 3 batches, got %d", calls)
+	}
+	if len(results) != 2 || results[0].ChunkID != "c2" || results[1].ChunkID != "c4" || results[0].Score != 0.9 {
+		t.Fatalf("unexpected results: %+v, %+v", results[0], results[1])
+	}
+}
+
+func TestCrossEncoderRerankerTEINormalizesLogits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		entries := make([]map[string]any, len(req.Texts))
+		for i, text := range req.Texts {
+			entries[i] = map[string]any{"index": i, "score": float64(len([]rune(text))) - 3}
+		}
+		_ = json.NewEncoder(w).Encode(entries)
+	}))
+	defer server.Close()
+
+	reranker, err := NewCrossEncoderReranker(CrossEncoderOptions{Endpoint: server.URL, Protocol: RerankProtocolTEI})
+	if err != nil {
+		t.Fatalf("NewCrossEncoderReranker: %v", err)
+	}
+
+	results, err := reranker.Rerank(context.Background(), "q", rerankDocs("一", "一二三四五", "一二三"), 3)
+	if err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if results[0].ChunkID != "c1" || results[2].ChunkID != "c0" {
+		t.Fatalf("unexpected order: %s %s %s", results[0].ChunkID, results[1].ChunkID, results[2].ChunkID)
+	}
+	for _, r := range results {
+		if r.Score <= 0 || r.Score >= 1 {
+			t.Fatalf("score not normalized: %v", r.Score)
+		}
+	}
+	if results[1].Score != 0.5 {
+		t.Fatalf("logit 0 should map to 0.5, got %v", results[1].Score)
+	}
+}
+
+func TestCrossEncoderRerankerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	reranker, _ := NewCrossEncoderReranker(CrossEncoderOptions{Endpoint: server.URL})
+	if _, err := reranker.Rerank(context.Background(), "q", rerankDocs("a"), 1); err == nil {
+		t.Fatal("expected error from failing rerank service")
+	}
+}
+
+type stubRerankProvider struct {
+	tenantID string
+	modelID  string
+	client   aiinterface.ModelClient
+}
+
+func (p *stubRerankProvider) GetClient(ctx context.Context, tenantID, modelID string) (aiinterface.ModelClient, error) {
+	p.tenantID = tenantID
+	p.modelID = modelID
+	return p.client, nil
+}
+
+// stubRerankResolver 按租户返回预设的同款模型
+type stubRerankResolver map[string]string
+
+func (r stubRerankResolver) ResolveRerankModel(ctx context.Context, tenantID, sourceTenantID, sourceModelID string) (string, error) {
+	return r[tenantID], nil
+}
+
+type stubRerankClient struct {
+	aiinterface.ModelClient
+	reply func(prompt string) string
+}
+
+func (c *stubRerankClient) ChatCompletion(ctx context.Context, req *aiinterface.ChatCompletionRequest) (*aiinterface.ChatCompletionResponse, error) {
+	return &aiinterface.ChatCompletionResponse{Content: c.reply(req.Messages[len(req.Messages)-1].Content)}, nil
+}
+
+func TestLLMReranker(t *testing.T) {
+	logger.Init("error", "console", "stdout")
+	client := &stubRerankClient{reply: func(prompt string) string {
+		// 段落内容包含“相关”时给高分
+		var items []string
+		for i, block := range strings.Split(prompt, "\n\n")[1:] {
+			if block == "" {
+				continue
+			}
+			score := 2
+			if strings.Contains(block, "相关") {
+				score = 9
+			}
+			items = append(items, fmt.Sprintf(`{"id":%d,"score":%d}`, i+1, score))
+		}
+		return "```json\n[" + strings.Join(items, ",") + "]\n```"
+	}}
+	provider := &stubRerankProvider{client: client}
+
+	reranker, err := NewLLMReranker(provider, LLMRerankerOptions{
+		ModelID:         "m1",
+		DefaultTenantID: "default",
+		BatchSize:       2,
+		ModelResolver:   stubRerankResolver{"t1": "t1-m1"},
+	})
+	if err != nil {
+		t.Fatalf("NewLLMReranker: %v", err)
+	}
+
+	ctx := tenant.WithTenantContext(context.Background(), tenant.TenantContext{TenantID: "t1"})
+	results, err := reranker.Rerank(ctx, "q", rerankDocs("无关", "无关", "相关段落"), 1)
+	if err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if provider.tenantID != "t1" || provider.modelID != "t1-m1" {
+		t.Fatalf("expected the tenant's own model, got %s/%s", provider.tenantID, provider.modelID)
+	}
+	if len(results) != 1 || results[0].ChunkID != "c2" || results[0].Score != 0.9 {
+		t.Fatalf("unexpected result: %+v", results[0])
+	}
+
+	// 租户没有同款模型时使用配置租户的模型，而不是拿配置的模型 ID 去调用方租户下查找
+	other := tenant.WithTenantContext(context.Background(), tenant.TenantContext{TenantID: "t2"})
+	if _, err := reranker.Rerank(other, "q", rerankDocs("相关"), 1); err != nil {
+		t.Fatalf("Rerank: %v", err)
+	}
+	if provider.tenantID != "default" || provider.modelID != "m1" {
+		t.Fatalf("expected fallback to configured model, got %s/%s", provider.tenantID, provider.modelID)
+	}
+}
+
+func TestParseLLMScores(t *testing.T) {
+	scores, err := parseLLMScores("分数如下：[8, 3]", 3)
+	if err != nil {
+		t.Fatalf("parseLLMScores: %v", err)
+	}
+	if scores[0] != 0.8 || scores[1] != 0.3 || scores[2] != 0 {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+	if _, err := parseLLMScores("无法判断", 1); err == nil {
+		t.Fatal("expected error for non-JSON output")
+	}
+}
@@ -0,0 +1,335 @@
+package rag
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FilterOp 元数据过滤操作符
+type FilterOp string
+
+const (
+	FilterOpEq     FilterOp = "eq"     // 等于
+	FilterOpIn     FilterOp = "in"     // 属于给定集合
+	FilterOpRange  FilterOp = "range"  // 数值区间
+	FilterOpExists FilterOp = "exists" // 键存在且不为 null
+)
+
+// filterKeyPattern 元数据键只允许字母、数字、下划线，使用点号访问嵌套字段
+var filterKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+// SearchFilter 向量检索过滤条件
+// 所有条件之间为 AND 关系；DocumentIDs 非空时只在这些文档内检索。
+// 例如只检索某部作品的第 1–30 章：
+//
+//	{"conditions":[{"key":"work_id","op":"eq","value":"w1"},{"key":"chapter","op":"range","gte":1,"lte":30}]}
+type SearchFilter struct {
+	DocumentIDs []string          `json:"document_ids,omitempty"`
+	Conditions  []FilterCondition `json:"conditions,omitempty"`
+}
+
+// FilterCondition 单个元数据条件
+type FilterCondition struct {
+	Key    string   `json:"key"`              // 元数据键，支持 a.b 访问嵌套字段
+	Op     FilterOp `json:"op"`               // eq / in / range / exists
+	Value  any      `json:"value,omitempty"`  // eq 的比较值
+	Values []any    `json:"values,omitempty"` // in 的候选值
+	Gt     *float64 `json:"gt,omitempty"`     // range 下界（不含）
+	Gte    *float64 `json:"gte,omitempty"`    // range 下界（含）
+	Lt     *float64 `json:"lt,omitempty"`     // range 上界（不含）
+	Lte    *float64 `json:"lte,omitempty"`    // range 上界（含）
+}
+
+// IsEmpty 是否没有任何过滤条件
+func (f *SearchFilter) IsEmpty() bool {
+	return f == nil || (len(f.DocumentIDs) == 0 && len(f.Conditions) == 0)
+}
+
+// Validate 校验过滤条件
+func (f *SearchFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for i, cond := range f.Conditions {
+		if !filterKeyPattern.MatchString(cond.Key) {
+			return fmt.Errorf("过滤条件 %d 的键无效: %q", i, cond.Key)
+		}
+		switch cond.Op {
+		case FilterOpEq:
+			if cond.Value == nil {
+				return fmt.Errorf("过滤条件 %s: eq 需要 value", cond.Key)
+			}
+			if !isScalar(cond.Value) {
+				return fmt.Errorf("过滤条件 %s: value 只能是字符串、数字或布尔值", cond.Key)
+			}
+		case FilterOpIn:
+			if len(cond.Values) == 0 {
+				return fmt.Errorf("过滤条件 %s: in 需要至少一个 values", cond.Key)
+			}
+			for _, v := range cond.Values {
+				if !isScalar(v) {
+					return fmt.Errorf("过滤条件 %s: values 只能是字符串、数字或布尔值", cond.Key)
+				}
+			}
+		case FilterOpRange:
+			if cond.Gt == nil && cond.Gte == nil && cond.Lt == nil && cond.Lte == nil {
+				return fmt.Errorf("过滤条件 %s: range 需要至少一个边界", cond.Key)
+			}
+		case FilterOpExists:
+		default:
+			return fmt.Errorf("过滤条件 %s: 不支持的操作符 %q", cond.Key, cond.Op)
+		}
+	}
+	return nil
+}
+
+// Matches 在内存中判断检索结果是否满足过滤条件
+// 用于无法下推过滤的检索路径（如关键词检索）
+func (f *SearchFilter) Matches(documentID string, metadata map[string]any) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if len(f.DocumentIDs) > 0 {
+		found := false
+		for _, id := range f.DocumentIDs {
+			if id == documentID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, cond := range f.Conditions {
+		val, ok := metadataValue(metadata, cond.Key)
+		switch cond.Op {
+		case FilterOpExists:
+			if !ok || val == nil {
+				return false
+			}
+		case FilterOpEq:
+			if !ok || !scalarEqual(val, cond.Value) {
+				return false
+			}
+		case FilterOpIn:
+			matched := false
+			for _, candidate := range cond.Values {
+				if ok && scalarEqual(val, candidate) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		case FilterOpRange:
+			n, isNum := toFloat(val)
+			if !ok || !isNum {
+				return false
+			}
+			if (cond.Gt != nil && n <= *cond.Gt) || (cond.Gte != nil && n < *cond.Gte) ||
+				(cond.Lt != nil && n >= *cond.Lt) || (cond.Lte != nil && n > *cond.Lte) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// filterResults 按过滤条件筛选检索结果
+func filterResults(results []*SearchResult, filter *SearchFilter) []*SearchResult {
+	if filter.IsEmpty() {
+		return results
+	}
+	filtered := make([]*SearchResult, 0, len(results))
+	for _, r := range results {
+		if filter.Matches(r.DocumentID, r.Metadata) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// --- pgvector ---
+
+// toSQL 将过滤条件转换为 SQL 片段（以 AND 开头）与参数
+// 元数据键只包含安全字符，并以 jsonb_extract_path 的参数形式传入
+func (f *SearchFilter) toSQL() (string, []any, error) {
+	if f.IsEmpty() {
+		return "", nil, nil
+	}
+	if err := f.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	var clauses []string
+	var args []any
+
+	if len(f.DocumentIDs) > 0 {
+		clauses = append(clauses, "document_id IN ?")
+		args = append(args, f.DocumentIDs)
+	}
+
+	for _, cond := range f.Conditions {
+		path := strings.Split(cond.Key, ".")
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(path)), ", ")
+		extract := "jsonb_extract_path(metadata_raw, " + placeholders + ")"
+		pathArgs := make([]any, len(path))
+		for i, p := range path {
+			pathArgs[i] = p
+		}
+
+		switch cond.Op {
+		case FilterOpExists:
+			clauses = append(clauses, "jsonb_typeof("+extract+") <> 'null'")
+			args = append(args, pathArgs...)
+
+		case FilterOpEq:
+			// 使用 @> 包含查询，可命中 metadata_raw 上的 GIN 索引，同时保留 JSON 类型（"1" 与 1 不相等）
+			doc, err := containmentDocument(path, cond.Value)
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, "metadata_raw @> ?::jsonb")
+			args = append(args, doc)
+
+		case FilterOpIn:
+			parts := make([]string, 0, len(cond.Values))
+			for _, v := range cond.Values {
+				doc, err := containmentDocument(path, v)
+				if err != nil {
+					return "", nil, err
+				}
+				parts = append(parts, "metadata_raw @> ?::jsonb")
+				args = append(args, doc)
+			}
+			clauses = append(clauses, "("+strings.Join(parts, " OR ")+")")
+
+		case FilterOpRange:
+			// 非数值类型视为不匹配，避免 ::numeric 转换报错
+			numeric := "(CASE WHEN jsonb_typeof(" + extract + ") = 'number' THEN (" + extract + " #>> '{}')::numeric END)"
+			bounds := []struct {
+				op  string
+				val *float64
+			}{{">", cond.Gt}, {">=", cond.Gte}, {"<", cond.Lt}, {"<=", cond.Lte}}
+			for _, b := range bounds {
+				if b.val == nil {
+					continue
+				}
+				clauses = append(clauses, numeric+" "+b.op+" ?")
+				args = append(args, pathArgs...)
+				args = append(args, pathArgs...)
+				args = append(args, *b.val)
+			}
+		}
+	}
+
+	return " AND " + strings.Join(clauses, " AND "), args, nil
+}
+
+// containmentDocument 构造 {"a":{"b":value}} 形式的 JSON，用于 @> 查询
+func containmentDocument(path []string, value any) (string, error) {
+	var doc any = value
+	for i := len(path) - 1; i >= 0; i-- {
+		doc = map[string]any{path[i]: doc}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", fmt.Errorf("序列化过滤条件失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// --- Qdrant ---
+
+// toQdrant 将过滤条件转换为 Qdrant payload 过滤条件
+// 元数据写入在 payload.metadata 下，因此键前缀为 metadata.
+func (f *SearchFilter) toQdrant() (must, mustNot []fieldCondition, err error) {
+	if f.IsEmpty() {
+		return nil, nil, nil
+	}
+	if err := f.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(f.DocumentIDs) > 0 {
+		ids := make([]any, len(f.DocumentIDs))
+		for i, id := range f.DocumentIDs {
+			ids[i] = id
+		}
+		must = append(must, fieldCondition{Key: "document_id", Match: &fieldMatch{Any: ids}})
+	}
+
+	for _, cond := range f.Conditions {
+		key := "metadata." + cond.Key
+		switch cond.Op {
+		case FilterOpEq:
+			must = append(must, fieldCondition{Key: key, Match: &fieldMatch{Value: cond.Value}})
+		case FilterOpIn:
+			must = append(must, fieldCondition{Key: key, Match: &fieldMatch{Any: cond.Values}})
+		case FilterOpRange:
+			must = append(must, fieldCondition{Key: key, Range: &fieldRange{Gt: cond.Gt, Gte: cond.Gte, Lt: cond.Lt, Lte: cond.Lte}})
+		case FilterOpExists:
+			mustNot = append(mustNot, fieldCondition{IsEmpty: &isEmptyCondition{Key: key}})
+		}
+	}
+	return must, mustNot, nil
+}
+
+// --- 辅助函数 ---
+
+// metadataValue 按点号路径读取元数据
+func metadataValue(metadata map[string]any, key string) (any, bool) {
+	var current any = metadata
+	for _, part := range strings.Split(key, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func isScalar(v any) bool {
+	switch v.(type) {
+	case string, bool, float64, float32, int, int32, int64, json.Number:
+		return true
+	}
+	return false
+}
+
+func scalarEqual(a, b any) bool {
+	if an, ok := toFloat(a); ok {
+		bn, ok := toFloat(b)
+		return ok && an == bn
+	}
+	return a == b
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
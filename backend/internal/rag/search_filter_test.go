@@ -0,0 +1,104 @@
+package rag
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func chapterFilter() *SearchFilter {
+	return &SearchFilter{
+		DocumentIDs: []string{"doc-1", "doc-2"},
+		Conditions: []FilterCondition{
+			{Key: "work_id", Op: FilterOpEq, Value: "w1"},
+			{Key: "chapter", Op: FilterOpRange, Gte: floatPtr(1), Lte: floatPtr(30)},
+			{Key: "tags", Op: FilterOpIn, Values: []any{"setting", "character"}},
+			{Key: "source.url", Op: FilterOpExists},
+		},
+	}
+}
+
+func TestSearchFilterMatches(t *testing.T) {
+	filter := chapterFilter()
+	metadata := map[string]any{
+		"work_id": "w1",
+		"chapter": float64(12),
+		"tags":    "setting",
+		"source":  map[string]any{"url": "https://example.com"},
+	}
+
+	if !filter.Matches("doc-1", metadata) {
+		t.Fatal("expected metadata to match")
+	}
+	if filter.Matches("doc-3", metadata) {
+		t.Fatal("expected document outside filter to be excluded")
+	}
+
+	metadata["chapter"] = float64(31)
+	if filter.Matches("doc-1", metadata) {
+		t.Fatal("expected chapter outside range to be excluded")
+	}
+
+	metadata["chapter"] = "12"
+	if filter.Matches("doc-1", metadata) {
+		t.Fatal("expected non-numeric chapter to be excluded from range")
+	}
+
+	var empty *SearchFilter
+	if !empty.Matches("any", nil) {
+		t.Fatal("nil filter should match everything")
+	}
+}
+
+func TestSearchFilterValidate(t *testing.T) {
+	cases := []SearchFilter{
+		{Conditions: []FilterCondition{{Key: "a'; DROP TABLE x", Op: FilterOpEq, Value: "1"}}},
+		{Conditions: []FilterCondition{{Key: "a", Op: "like", Value: "1"}}},
+		{Conditions: []FilterCondition{{Key: "a", Op: FilterOpIn}}},
+		{Conditions: []FilterCondition{{Key: "a", Op: FilterOpRange}}},
+		{Conditions: []FilterCondition{{Key: "a", Op: FilterOpEq, Value: map[string]any{"x": 1}}}},
+	}
+	for i, f := range cases {
+		if err := f.Validate(); err == nil {
+			t.Fatalf("case %d: expected validation error", i)
+		}
+	}
+}
+
+func TestSearchFilterToSQL(t *testing.T) {
+	sql, args, err := chapterFilter().toSQL()
+	if err != nil {
+		t.Fatalf("toSQL: %v", err)
+	}
+	if !strings.HasPrefix(sql, " AND document_id IN ?") {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+	if got, want := strings.Count(sql, "?"), len(args); got != want {
+		t.Fatalf("placeholder count %d != args %d: %s", got, want, sql)
+	}
+	if args[1] != `{"work_id":"w1"}` {
+		t.Fatalf("unexpected containment arg: %v", args[1])
+	}
+	if strings.Contains(sql, "source") || strings.Contains(sql, "work_id") {
+		t.Fatalf("metadata keys must be bound as parameters: %s", sql)
+	}
+}
+
+func TestSearchFilterToQdrant(t *testing.T) {
+	must, mustNot, err := chapterFilter().toQdrant()
+	if err != nil {
+		t.Fatalf("toQdrant: %v", err)
+	}
+	data, _ := json.Marshal(qdrantFilter{Must: must, MustNot: mustNot})
+	want := `{"must":[` +
+		`{"key":"document_id","match":{"any":["doc-1","doc-2"]}},` +
+		`{"key":"metadata.work_id","match":{"value":"w1"}},` +
+		`{"key":"metadata.chapter","range":{"gte":1,"lte":30}},` +
+		`{"key":"metadata.tags","match":{"any":["setting","character"]}}],` +
+		`"must_not":[{"is_empty":{"key":"metadata.source.url"}}]}`
+	if string(data) != want {
+		t.Fatalf("unexpected qdrant filter:\n%s\nwant:\n%s", data, want)
+	}
+}
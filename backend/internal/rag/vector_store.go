@@ -41,7 +41,8 @@ type VectorStoreStats struct {
 // VectorStore 抽象向量写入、检索与删除功能，可由不同后端实现（pgvector、Qdrant 等）。
 type VectorStore interface {
 	AddVectors(ctx context.Context, vectors []*Vector) error
-	Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int) ([]*SearchResult, error)
+	// Search 相似度检索，filter 为 nil 时不做元数据过滤
+	Search(ctx context.Context, knowledgeBaseID string, queryVector []float32, topK int, filter *SearchFilter) ([]*SearchResult, error)
 	DeleteVectors(ctx context.Context, chunkIDs []string) error
 	DeleteByDocument(ctx context.Context, knowledgeBaseID, documentID string) error
 	DeleteByKnowledgeBase(ctx context.Context, knowledgeBaseID string) error
@@ -0,0 +1,88 @@
+package security
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrHostNotAllowed 目标地址指向内网、回环或链路本地地址
+var ErrHostNotAllowed = errors.New("目标地址不允许指向内网、回环或链路本地地址")
+
+// hostAllowed 主机是否在显式放行列表中（用于部署在内网的受信任服务）
+func hostAllowed(host string, allowedHosts []string) bool {
+	for _, allowed := range allowedHosts {
+		if strings.EqualFold(strings.TrimSpace(allowed), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedIP 内网、回环、链路本地、组播与未指定地址
+func blockedIP(ip net.IP) bool {
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// CheckHost 保存配置时校验主机名；域名在连接时按解析结果再次校验
+func CheckHost(host string, allowedHosts []string) error {
+	if hostAllowed(host, allowedHosts) {
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	return nil
+}
+
+// CheckURL 校验外发请求地址：仅允许 http/https，且主机不能指向内网（放行列表除外）
+func CheckURL(rawURL string, allowedHosts []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("无效的地址: %s", rawURL)
+	}
+	return CheckHost(u.Hostname(), allowedHosts)
+}
+
+// NewGuardedHTTPClient 创建只能连接公网地址的 HTTP 客户端：
+// 在建立 TCP 连接时检查 DNS 解析后的实际地址，防止域名解析到内网（DNS rebinding）
+func NewGuardedHTTPClient(allowedHosts []string, dialTimeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if hostAllowed(host, allowedHosts) {
+				return nil
+			}
+			if ip := net.ParseIP(host); ip != nil && blockedIP(ip) {
+				return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err == nil && hostAllowed(host, allowedHosts) {
+			// 放行的主机名解析到内网地址时同样允许
+			return (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext(ctx, network, addr)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	transport.Proxy = nil
+	return &http.Client{Transport: transport}
+}
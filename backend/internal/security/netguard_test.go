@@ -0,0 +1,48 @@
+package security
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "localhost", "10.0.0.8", "192.168.1.1", "169.254.169.254", "::1", "fd00::1", "0.0.0.0"} {
+		if err := CheckHost(host, nil); err == nil {
+			t.Fatalf("expected %s to be rejected", host)
+		}
+	}
+	for _, host := range []string{"mcp.example.com", "8.8.8.8"} {
+		if err := CheckHost(host, nil); err != nil {
+			t.Fatalf("expected %s to be allowed: %v", host, err)
+		}
+	}
+	if err := CheckHost("10.0.0.8", []string{"10.0.0.8"}); err != nil {
+		t.Fatalf("expected allowlisted host to pass: %v", err)
+	}
+	if err := CheckURL("file:///etc/passwd", nil); err == nil {
+		t.Fatal("expected non-http scheme to be rejected")
+	}
+	if err := CheckURL("http://169.254.169.254/latest/meta-data", nil); !errors.Is(err, ErrHostNotAllowed) {
+		t.Fatalf("expected metadata address to be rejected, got %v", err)
+	}
+
+	// 连接时按解析后的地址拦截
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	resp, err := NewGuardedHTTPClient(nil, time.Second).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("expected guarded client to refuse loopback")
+	}
+	if !errors.Is(err, ErrHostNotAllowed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err = NewGuardedHTTPClient([]string{"127.0.0.1"}, time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected allowlisted loopback to connect: %v", err)
+	}
+	resp.Body.Close()
+}
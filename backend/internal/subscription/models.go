@@ -107,6 +107,10 @@ type UserSubscription struct {
 	// 自动续订
 	AutoRenew        bool       `json:"autoRenew" gorm:"default:false"`
 	NextBillingDate  *time.Time `json:"nextBillingDate"`
+
+	// 计划中的套餐变更（降级在当前周期结束时生效）
+	ScheduledPlanID   string     `json:"scheduledPlanId" gorm:"size:36"`
+	ScheduledChangeAt *time.Time `json:"scheduledChangeAt" gorm:"index"`
 	
 	// 取消信息
 	CanceledAt     *time.Time `json:"canceledAt"`
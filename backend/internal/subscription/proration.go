@@ -0,0 +1,340 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSamePlan             = errors.New("当前已是该套餐")
+	ErrSubscriptionInactive = errors.New("订阅未生效，无法变更套餐")
+	ErrCurrencyMismatch     = errors.New("套餐币种不一致，无法折算差价")
+	ErrNoScheduledChange    = errors.New("没有计划中的套餐变更")
+)
+
+// defaultCreditsPerUnit 差价退回时每单位货币折算的积分
+const defaultCreditsPerUnit = 100
+
+// PlanChangeTiming 套餐变更生效时机
+type PlanChangeTiming string
+
+const (
+	PlanChangeImmediate PlanChangeTiming = "immediate"  // 立即生效（升级、试用期内变更）
+	PlanChangePeriodEnd PlanChangeTiming = "period_end" // 当前周期结束时生效（降级）
+)
+
+// PlanChangePreview 套餐变更预览：按当前周期剩余时间折算新旧套餐差价
+type PlanChangePreview struct {
+	SubscriptionID string           `json:"subscriptionId"`
+	FromPlanID     string           `json:"fromPlanId"`
+	FromPlanCode   string           `json:"fromPlanCode"`
+	ToPlanID       string           `json:"toPlanId"`
+	ToPlanCode     string           `json:"toPlanCode"`
+	Action         string           `json:"action"` // upgrade, downgrade
+	Timing         PlanChangeTiming `json:"timing"`
+	EffectiveAt    time.Time        `json:"effectiveAt"`
+	BillingCycle   BillingCycle     `json:"billingCycle"`
+	PeriodStart    *time.Time       `json:"periodStart"`
+	PeriodEnd      *time.Time       `json:"periodEnd"`
+	RemainingRatio float64          `json:"remainingRatio"` // 当前周期剩余比例（0~1）
+	UnusedCredit   float64          `json:"unusedCredit"`   // 原套餐未使用部分折算金额
+	ProratedCharge float64          `json:"proratedCharge"` // 新套餐剩余周期费用
+	AmountDue      float64          `json:"amountDue"`      // 需补缴金额（生成账单）
+	CreditAmount   float64          `json:"creditAmount"`   // 应退回金额（以积分返还）
+	CreditPoints   int64            `json:"creditPoints"`   // 退回积分
+	Currency       string           `json:"currency"`
+}
+
+// PlanChangeResult 套餐变更结果
+type PlanChangeResult struct {
+	Subscription        *UserSubscription  `json:"subscription"`
+	Preview             *PlanChangePreview `json:"preview"`
+	BillID              string             `json:"billId,omitempty"`              // 补缴差价账单
+	CreditTransactionID string             `json:"creditTransactionId,omitempty"` // 退回积分流水
+}
+
+// ProrationBiller 差价结算：补缴部分出账，退回部分以积分返还（由计费与积分服务适配实现）
+// tx 为套餐切换所在的事务，出账与退回积分必须通过它写入，与套餐切换一起提交或回滚
+type ProrationBiller interface {
+	CreateProrationBill(tx *gorm.DB, sub *UserSubscription, preview *PlanChangePreview) (billID string, err error)
+	CreditProration(tx *gorm.DB, sub *UserSubscription, preview *PlanChangePreview) (transactionID string, err error)
+}
+
+// SetProrationBiller 设置差价结算方式；creditsPerUnit 为每单位货币折算的积分，<=0 时使用默认值
+// 未设置时套餐变更仍会折算差价并记录在变更历史中，但不会出账或返还积分
+func (s *Service) SetProrationBiller(biller ProrationBiller, creditsPerUnit float64) {
+	if creditsPerUnit <= 0 {
+		creditsPerUnit = defaultCreditsPerUnit
+	}
+	s.biller = biller
+	s.creditsPerUnit = creditsPerUnit
+}
+
+// PreviewPlanChange 预览套餐变更的生效时间与差价，不做任何修改
+func (s *Service) PreviewPlanChange(ctx context.Context, subscriptionID, newPlanID string) (*PlanChangePreview, error) {
+	_, _, preview, err := s.preparePlanChange(ctx, subscriptionID, newPlanID, time.Now())
+	return preview, err
+}
+
+// CancelScheduledChange 取消计划中的套餐变更
+func (s *Service) CancelScheduledChange(ctx context.Context, subscriptionID string) (*UserSubscription, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if sub.ScheduledPlanID == "" {
+		return nil, ErrNoScheduledChange
+	}
+	if err := s.db.WithContext(ctx).Model(&UserSubscription{}).Where("id = ?", subscriptionID).
+		Updates(map[string]interface{}{"scheduled_plan_id": "", "scheduled_change_at": nil}).Error; err != nil {
+		return nil, err
+	}
+
+	s.recordHistory(ctx, &SubscriptionHistory{
+		ID:             uuid.New().String(),
+		TenantID:       sub.TenantID,
+		UserID:         sub.UserID,
+		SubscriptionID: subscriptionID,
+		Action:         "cancel_scheduled_change",
+		FromPlanID:     sub.PlanID,
+		ToPlanID:       sub.ScheduledPlanID,
+	})
+	return s.GetSubscription(ctx, subscriptionID)
+}
+
+// ApplyScheduledChanges 应用已到生效时间的计划变更（周期结束未续订的订阅），返回处理数量
+func (s *Service) ApplyScheduledChanges(ctx context.Context) (int, error) {
+	var subs []UserSubscription
+	if err := s.db.WithContext(ctx).
+		Where("scheduled_plan_id <> '' AND scheduled_change_at <= ? AND status IN ?", time.Now(),
+			[]SubscriptionStatus{StatusActive, StatusPastDue}).
+		Limit(500).
+		Find(&subs).Error; err != nil {
+		return 0, err
+	}
+	applied := 0
+	for i := range subs {
+		if _, err := s.applyScheduledChange(ctx, &subs[i]); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// StartScheduledChangeWorker 定时应用到期的计划变更
+func (s *Service) StartScheduledChangeWorker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_, _ = s.ApplyScheduledChanges(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// applyScheduledChange 切换到计划中的套餐并清除计划，返回新套餐
+func (s *Service) applyScheduledChange(ctx context.Context, sub *UserSubscription) (*SubscriptionPlan, error) {
+	plan, err := s.GetPlan(ctx, sub.ScheduledPlanID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(&UserSubscription{}).Where("id = ?", sub.ID).
+		Updates(map[string]interface{}{
+			"plan_id":             plan.ID,
+			"plan_code":           plan.Code,
+			"plan_tier":           plan.Tier,
+			"scheduled_plan_id":   "",
+			"scheduled_change_at": nil,
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	s.recordHistory(ctx, &SubscriptionHistory{
+		ID:             uuid.New().String(),
+		TenantID:       sub.TenantID,
+		UserID:         sub.UserID,
+		SubscriptionID: sub.ID,
+		Action:         "downgrade",
+		FromPlanID:     sub.PlanID,
+		ToPlanID:       plan.ID,
+		Remark:         "计划变更在周期结束时生效",
+	})
+
+	sub.PlanID, sub.PlanCode, sub.PlanTier = plan.ID, plan.Code, plan.Tier
+	sub.ScheduledPlanID, sub.ScheduledChangeAt = "", nil
+	return plan, nil
+}
+
+// preparePlanChange 加载订阅与套餐并计算变更预览
+func (s *Service) preparePlanChange(ctx context.Context, subscriptionID, newPlanID string, now time.Time) (*UserSubscription, *SubscriptionPlan, *PlanChangePreview, error) {
+	sub, err := s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if sub.Status != StatusActive && sub.Status != StatusTrialing && sub.Status != StatusPastDue {
+		return nil, nil, nil, ErrSubscriptionInactive
+	}
+	if sub.PlanID == newPlanID {
+		return nil, nil, nil, ErrSamePlan
+	}
+	oldPlan, err := s.GetPlan(ctx, sub.PlanID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	newPlan, err := s.GetPlan(ctx, newPlanID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	preview, err := s.calculatePlanChange(sub, oldPlan, newPlan, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return sub, newPlan, preview, nil
+}
+
+// calculatePlanChange 计算变更方向、生效时间与按剩余周期折算的差价
+// 升级立即生效：补缴 新套餐剩余周期费用 - 原套餐未使用部分，为负时退回积分；
+// 降级在当前周期结束时生效，不产生差价；试用期与终身订阅没有可折算的已付周期，立即切换
+func (s *Service) calculatePlanChange(sub *UserSubscription, oldPlan, newPlan *SubscriptionPlan, now time.Time) (*PlanChangePreview, error) {
+	cycle := sub.BillingCycle
+	if cycle == "" {
+		cycle = BillingCycleMonthly
+	}
+	oldPrice, newPrice := planPrice(oldPlan, cycle), planPrice(newPlan, cycle)
+
+	preview := &PlanChangePreview{
+		SubscriptionID: sub.ID,
+		FromPlanID:     oldPlan.ID,
+		FromPlanCode:   oldPlan.Code,
+		ToPlanID:       newPlan.ID,
+		ToPlanCode:     newPlan.Code,
+		Action:         "upgrade",
+		Timing:         PlanChangeImmediate,
+		EffectiveAt:    now,
+		BillingCycle:   cycle,
+		Currency:       newPlan.Currency,
+	}
+	if rank, oldRank := tierRank(newPlan.Tier), tierRank(oldPlan.Tier); rank < oldRank || (rank == oldRank && newPrice < oldPrice) {
+		preview.Action = "downgrade"
+	}
+
+	start, end, ok := currentPeriod(sub, cycle, now)
+	if !ok || sub.Status == StatusTrialing {
+		return preview, nil
+	}
+	preview.PeriodStart, preview.PeriodEnd = &start, &end
+
+	if preview.Action == "downgrade" {
+		preview.Timing = PlanChangePeriodEnd
+		preview.EffectiveAt = end
+		return preview, nil
+	}
+
+	if oldPlan.Currency != newPlan.Currency && oldPrice > 0 && newPrice > 0 {
+		return nil, ErrCurrencyMismatch
+	}
+	ratio := float64(end.Sub(now)) / float64(end.Sub(start))
+	preview.RemainingRatio = math.Round(ratio*10000) / 10000
+	preview.UnusedCredit = roundAmount(oldPrice * ratio)
+	preview.ProratedCharge = roundAmount(newPrice * ratio)
+	net := roundAmount(preview.ProratedCharge - preview.UnusedCredit)
+	if net > 0 {
+		preview.AmountDue = net
+	} else if net < 0 {
+		preview.CreditAmount = -net
+		preview.CreditPoints = int64(math.Round(-net * s.creditsPerUnit))
+	}
+	return preview, nil
+}
+
+// settleProration 在套餐切换的事务中按预览结果出账或返还积分
+func (s *Service) settleProration(tx *gorm.DB, sub *UserSubscription, preview *PlanChangePreview, result *PlanChangeResult) error {
+	if s.biller == nil {
+		return nil
+	}
+	if preview.AmountDue > 0 {
+		billID, err := s.biller.CreateProrationBill(tx, sub, preview)
+		if err != nil {
+			return err
+		}
+		result.BillID = billID
+	}
+	if preview.CreditPoints > 0 {
+		txID, err := s.biller.CreditProration(tx, sub, preview)
+		if err != nil {
+			return err
+		}
+		result.CreditTransactionID = txID
+	}
+	return nil
+}
+
+// currentPeriod 当前计费周期，没有到期时间或终身订阅时返回 false
+func currentPeriod(sub *UserSubscription, cycle BillingCycle, now time.Time) (time.Time, time.Time, bool) {
+	if sub.EndDate == nil || cycle == BillingCycleLifetime || !sub.EndDate.After(now) {
+		return time.Time{}, time.Time{}, false
+	}
+	end := *sub.EndDate
+	start := end.AddDate(0, -1, 0)
+	if cycle == BillingCycleYearly {
+		start = end.AddDate(-1, 0, 0)
+	}
+	if sub.StartDate.After(start) && sub.StartDate.Before(end) {
+		start = sub.StartDate
+	}
+	if start.After(now) {
+		start = now
+	}
+	return start, end, true
+}
+
+// planPrice 套餐在计费周期内的价格
+func planPrice(plan *SubscriptionPlan, cycle BillingCycle) float64 {
+	if cycle == BillingCycleYearly {
+		return plan.PriceYearly
+	}
+	return plan.PriceMonthly
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// scheduleDowngrade 记录在周期结束时生效的降级
+func (s *Service) scheduleDowngrade(ctx context.Context, sub *UserSubscription, preview *PlanChangePreview) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&UserSubscription{}).Where("id = ?", sub.ID).
+			Updates(map[string]interface{}{
+				"scheduled_plan_id":   preview.ToPlanID,
+				"scheduled_change_at": preview.EffectiveAt,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&SubscriptionHistory{
+			ID:             uuid.New().String(),
+			TenantID:       sub.TenantID,
+			UserID:         sub.UserID,
+			SubscriptionID: sub.ID,
+			Action:         "schedule_downgrade",
+			FromPlanID:     preview.FromPlanID,
+			ToPlanID:       preview.ToPlanID,
+			Remark:         fmt.Sprintf("将于 %s 生效", preview.EffectiveAt.Format("2006-01-02 15:04")),
+			CreatedAt:      time.Now(),
+		}).Error
+	})
+}
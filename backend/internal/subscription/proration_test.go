@@ -0,0 +1,246 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+	testUserID   = "22222222-2222-2222-2222-222222222222"
+)
+
+type fakeBiller struct {
+	bills      []*PlanChangePreview
+	credits    []*PlanChangePreview
+	billErr    error
+	seenPlanID string // 出账时在事务中读到的套餐
+}
+
+// CreateProrationBill 在事务中写入出账记录；billErr 非空时写入后返回错误，模拟结算中途失败
+func (b *fakeBiller) CreateProrationBill(tx *gorm.DB, sub *UserSubscription, preview *PlanChangePreview) (string, error) {
+	var current UserSubscription
+	if err := tx.First(&current, "id = ?", sub.ID).Error; err != nil {
+		return "", err
+	}
+	b.seenPlanID = current.PlanID
+	if err := tx.Create(&SubscriptionHistory{ID: uuid.New().String(), TenantID: sub.TenantID, UserID: sub.UserID,
+		SubscriptionID: sub.ID, Action: "proration_bill", Amount: preview.AmountDue}).Error; err != nil {
+		return "", err
+	}
+	if b.billErr != nil {
+		return "", b.billErr
+	}
+	b.bills = append(b.bills, preview)
+	return "bill-1", nil
+}
+
+func (b *fakeBiller) CreditProration(_ *gorm.DB, _ *UserSubscription, preview *PlanChangePreview) (string, error) {
+	b.credits = append(b.credits, preview)
+	return "tx-1", nil
+}
+
+func newTestService(t *testing.T) (*Service, *fakeBiller) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&SubscriptionPlan{}, &UserSubscription{}, &SubscriptionHistory{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	svc := NewService(db)
+	biller := &fakeBiller{}
+	svc.SetProrationBiller(biller, 10)
+	return svc, biller
+}
+
+func createTestPlan(t *testing.T, svc *Service, code string, tier PlanTier, monthly float64) *SubscriptionPlan {
+	t.Helper()
+	plan, err := svc.CreatePlan(context.Background(), testTenantID, &CreatePlanRequest{
+		Name: code, Code: code, Tier: tier, PriceMonthly: monthly, PriceYearly: monthly * 10, TrialDays: 7,
+	})
+	if err != nil {
+		t.Fatalf("CreatePlan: %v", err)
+	}
+	return plan
+}
+
+// createMidPeriodSubscription 创建一个 30 天周期、已过去 10 天的订阅
+func createMidPeriodSubscription(t *testing.T, svc *Service, plan *SubscriptionPlan) *UserSubscription {
+	t.Helper()
+	now := time.Now()
+	end := now.Add(20 * 24 * time.Hour)
+	sub := &UserSubscription{
+		ID:           uuid.New().String(),
+		TenantID:     testTenantID,
+		UserID:       testUserID,
+		PlanID:       plan.ID,
+		PlanCode:     plan.Code,
+		PlanTier:     plan.Tier,
+		Status:       StatusActive,
+		BillingCycle: BillingCycleMonthly,
+		StartDate:    now.Add(-10 * 24 * time.Hour),
+		EndDate:      &end,
+	}
+	if err := svc.db.Create(sub).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	return sub
+}
+
+func TestChangePlanUpgradeProration(t *testing.T) {
+	svc, biller := newTestService(t)
+	ctx := context.Background()
+	basic := createTestPlan(t, svc, "basic", PlanTierBasic, 30)
+	pro := createTestPlan(t, svc, "pro", PlanTierPro, 90)
+	sub := createMidPeriodSubscription(t, svc, basic)
+
+	preview, err := svc.PreviewPlanChange(ctx, sub.ID, pro.ID)
+	if err != nil {
+		t.Fatalf("PreviewPlanChange: %v", err)
+	}
+	if preview.Action != "upgrade" || preview.Timing != PlanChangeImmediate {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	// 剩余 2/3 周期：新套餐 60，原套餐抵扣 20，补缴 40
+	if preview.ProratedCharge < 59.99 || preview.ProratedCharge > 60.01 || preview.UnusedCredit < 19.99 || preview.UnusedCredit > 20.01 {
+		t.Fatalf("unexpected proration: %+v", preview)
+	}
+	if preview.AmountDue < 39.98 || preview.AmountDue > 40.02 || preview.CreditPoints != 0 {
+		t.Fatalf("unexpected amount due: %+v", preview)
+	}
+	if unchanged, _ := svc.GetSubscription(ctx, sub.ID); unchanged.PlanID != basic.ID {
+		t.Fatal("preview must not change the subscription")
+	}
+
+	result, err := svc.ChangePlan(ctx, sub.ID, pro.ID)
+	if err != nil {
+		t.Fatalf("ChangePlan: %v", err)
+	}
+	if result.Subscription.PlanID != pro.ID || result.BillID != "bill-1" || len(biller.bills) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if biller.seenPlanID != pro.ID {
+		t.Fatalf("biller must run inside the plan change transaction, saw plan %s", biller.seenPlanID)
+	}
+	// 差价账单支付前不计入实付金额
+	if result.Subscription.TotalPaid != 0 {
+		t.Fatalf("expected total paid 0 before payment, got %v", result.Subscription.TotalPaid)
+	}
+	if err := svc.RecordPayment(ctx, sub.ID, 40); err != nil {
+		t.Fatalf("RecordPayment: %v", err)
+	}
+	if paid, _ := svc.GetSubscription(ctx, sub.ID); paid.TotalPaid != 40 {
+		t.Fatalf("expected total paid 40, got %v", paid.TotalPaid)
+	}
+	if _, err := svc.ChangePlan(ctx, sub.ID, pro.ID); err != ErrSamePlan {
+		t.Fatalf("expected ErrSamePlan, got %v", err)
+	}
+}
+
+func TestChangePlanKeepsPlanWhenSettlementFails(t *testing.T) {
+	svc, biller := newTestService(t)
+	ctx := context.Background()
+	basic := createTestPlan(t, svc, "basic", PlanTierBasic, 30)
+	pro := createTestPlan(t, svc, "pro", PlanTierPro, 90)
+	sub := createMidPeriodSubscription(t, svc, basic)
+
+	biller.billErr = errors.New("billing unavailable")
+	if _, err := svc.ChangePlan(ctx, sub.ID, pro.ID); !errors.Is(err, biller.billErr) {
+		t.Fatalf("expected settlement error, got %v", err)
+	}
+	unchanged, err := svc.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if unchanged.PlanID != basic.ID || unchanged.TotalPaid != 0 {
+		t.Fatalf("plan change must roll back: plan=%s totalPaid=%v", unchanged.PlanID, unchanged.TotalPaid)
+	}
+	var billed int64
+	svc.db.Model(&SubscriptionHistory{}).Where("action = ?", "proration_bill").Count(&billed)
+	if billed != 0 {
+		t.Fatalf("biller writes must roll back with the plan change, found %d", billed)
+	}
+}
+
+func TestChangePlanCreditsOverpayment(t *testing.T) {
+	svc, biller := newTestService(t)
+	ctx := context.Background()
+	pro := createTestPlan(t, svc, "pro", PlanTierPro, 90)
+	// 更高等级但价格更低（如促销套餐）：多付部分以积分退回
+	enterprise := createTestPlan(t, svc, "enterprise-promo", PlanTierEnterprise, 60)
+	sub := createMidPeriodSubscription(t, svc, pro)
+
+	result, err := svc.ChangePlan(ctx, sub.ID, enterprise.ID)
+	if err != nil {
+		t.Fatalf("ChangePlan: %v", err)
+	}
+	if result.Preview.AmountDue != 0 || result.Preview.CreditPoints != 200 || result.CreditTransactionID != "tx-1" {
+		t.Fatalf("unexpected credit: %+v", result.Preview)
+	}
+	if len(biller.bills) != 0 || len(biller.credits) != 1 {
+		t.Fatalf("unexpected biller calls: bills=%d credits=%d", len(biller.bills), len(biller.credits))
+	}
+}
+
+func TestChangePlanSchedulesDowngrade(t *testing.T) {
+	svc, biller := newTestService(t)
+	ctx := context.Background()
+	basic := createTestPlan(t, svc, "basic", PlanTierBasic, 30)
+	pro := createTestPlan(t, svc, "pro", PlanTierPro, 90)
+	sub := createMidPeriodSubscription(t, svc, pro)
+
+	result, err := svc.ChangePlan(ctx, sub.ID, basic.ID)
+	if err != nil {
+		t.Fatalf("ChangePlan: %v", err)
+	}
+	scheduled := result.Subscription
+	if result.Preview.Timing != PlanChangePeriodEnd || scheduled.PlanID != pro.ID || scheduled.ScheduledPlanID != basic.ID {
+		t.Fatalf("expected scheduled downgrade, got %+v", scheduled)
+	}
+	if len(biller.bills)+len(biller.credits) != 0 {
+		t.Fatal("scheduled downgrade must not bill or credit")
+	}
+
+	// 续订时切换到新套餐并按新套餐计价
+	renewed, err := svc.RenewSubscription(ctx, sub.ID, BillingCycleMonthly)
+	if err != nil {
+		t.Fatalf("RenewSubscription: %v", err)
+	}
+	if renewed.PlanID != basic.ID || renewed.ScheduledPlanID != "" || renewed.TotalPaid != 30 {
+		t.Fatalf("expected downgrade applied on renewal, got %+v", renewed)
+	}
+}
+
+func TestApplyScheduledChanges(t *testing.T) {
+	svc, _ := newTestService(t)
+	ctx := context.Background()
+	basic := createTestPlan(t, svc, "basic", PlanTierBasic, 30)
+	pro := createTestPlan(t, svc, "pro", PlanTierPro, 90)
+	sub := createMidPeriodSubscription(t, svc, pro)
+
+	if _, err := svc.ChangePlan(ctx, sub.ID, basic.ID); err != nil {
+		t.Fatalf("ChangePlan: %v", err)
+	}
+	if applied, _ := svc.ApplyScheduledChanges(ctx); applied != 0 {
+		t.Fatalf("change must wait for period end, applied %d", applied)
+	}
+	svc.db.Model(&UserSubscription{}).Where("id = ?", sub.ID).Update("scheduled_change_at", time.Now().Add(-time.Minute))
+	if applied, err := svc.ApplyScheduledChanges(ctx); err != nil || applied != 1 {
+		t.Fatalf("expected 1 applied change, got %d (%v)", applied, err)
+	}
+	if current, _ := svc.GetSubscription(ctx, sub.ID); current.PlanID != basic.ID {
+		t.Fatalf("expected basic plan, got %s", current.PlanCode)
+	}
+
+	if _, err := svc.CancelScheduledChange(ctx, sub.ID); err != ErrNoScheduledChange {
+		t.Fatalf("expected ErrNoScheduledChange, got %v", err)
+	}
+}
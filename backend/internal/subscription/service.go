@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,12 +22,14 @@ var (
 
 // Service 订阅服务
 type Service struct {
-	db *gorm.DB
+	db             *gorm.DB
+	biller         ProrationBiller // 套餐变更差价结算（可选）
+	creditsPerUnit float64         // 差价退回时每单位货币折算的积分
 }
 
 // NewService 创建订阅服务
 func NewService(db *gorm.DB) *Service {
-	return &Service{db: db}
+	return &Service{db: db, creditsPerUnit: defaultCreditsPerUnit}
 }
 
 // ========== 套餐管理 ==========
@@ -330,7 +333,13 @@ func (s *Service) RenewSubscription(ctx context.Context, subscriptionID string,
 		return nil, err
 	}
 
-	plan, err := s.GetPlan(ctx, sub.PlanID)
+	// 计划中的降级在新周期开始时生效，续订按新套餐计价
+	var plan *SubscriptionPlan
+	if sub.ScheduledPlanID != "" {
+		plan, err = s.applyScheduledChange(ctx, sub)
+	} else {
+		plan, err = s.GetPlan(ctx, sub.PlanID)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -389,45 +398,81 @@ func (s *Service) RenewSubscription(ctx context.Context, subscriptionID string,
 }
 
 // ChangePlan 更换套餐（升降级）
-func (s *Service) ChangePlan(ctx context.Context, subscriptionID, newPlanID string) (*UserSubscription, error) {
-	sub, err := s.GetSubscription(ctx, subscriptionID)
+// 升级立即生效，并按当前周期剩余时间折算差价：补缴部分生成账单，多付部分以积分退回；
+// 降级在当前周期结束时生效（续订时或由 ApplyScheduledChanges 切换）
+func (s *Service) ChangePlan(ctx context.Context, subscriptionID, newPlanID string) (*PlanChangeResult, error) {
+	sub, newPlan, preview, err := s.preparePlanChange(ctx, subscriptionID, newPlanID, time.Now())
 	if err != nil {
 		return nil, err
 	}
+	result := &PlanChangeResult{Preview: preview}
 
-	newPlan, err := s.GetPlan(ctx, newPlanID)
+	if preview.Timing == PlanChangePeriodEnd {
+		if err := s.scheduleDowngrade(ctx, sub, preview); err != nil {
+			return nil, err
+		}
+		result.Subscription, err = s.GetSubscription(ctx, subscriptionID)
+		return result, err
+	}
+
+	// 套餐切换与差价结算在同一事务中：出账或退回积分失败时套餐保持不变
+	// 补缴差价计入 total_paid 在账单支付成功后由 RecordPayment 完成
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&UserSubscription{}).Where("id = ?", subscriptionID).Updates(map[string]interface{}{
+			"plan_id":             newPlanID,
+			"plan_code":           newPlan.Code,
+			"plan_tier":           newPlan.Tier,
+			"scheduled_plan_id":   "",
+			"scheduled_change_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.settleProration(tx, sub, preview, result); err != nil {
+			return fmt.Errorf("差价结算失败: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	oldPlanID := sub.PlanID
-	action := "upgrade"
-	if tierRank(newPlan.Tier) < tierRank(sub.PlanTier) {
-		action = "downgrade"
-	}
-
-	updates := map[string]interface{}{
-		"plan_id":   newPlanID,
-		"plan_code": newPlan.Code,
-		"plan_tier": newPlan.Tier,
-	}
-
-	if err := s.db.WithContext(ctx).Model(&UserSubscription{}).Where("id = ?", subscriptionID).Updates(updates).Error; err != nil {
-		return nil, err
+	// 记录历史（金额为正表示补缴，为负表示退回）
+	remark := ""
+	switch {
+	case result.BillID != "":
+		remark = "差价账单 " + result.BillID
+	case result.CreditTransactionID != "":
+		remark = fmt.Sprintf("退回 %d 积分", preview.CreditPoints)
 	}
-
-	// 记录历史
 	s.recordHistory(ctx, &SubscriptionHistory{
 		ID:             uuid.New().String(),
 		TenantID:       sub.TenantID,
 		UserID:         sub.UserID,
 		SubscriptionID: subscriptionID,
-		Action:         action,
-		FromPlanID:     oldPlanID,
+		Action:         preview.Action,
+		FromPlanID:     sub.PlanID,
 		ToPlanID:       newPlanID,
+		Amount:         preview.AmountDue - preview.CreditAmount,
+		Remark:         remark,
 	})
 
-	return s.GetSubscription(ctx, subscriptionID)
+	result.Subscription, err = s.GetSubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RecordPayment 订阅相关账单（如升级差价）支付成功后累计实付金额
+func (s *Service) RecordPayment(ctx context.Context, subscriptionID string, amount float64) error {
+	if amount <= 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Model(&UserSubscription{}).Where("id = ?", subscriptionID).
+		Updates(map[string]interface{}{
+			"total_paid":      gorm.Expr("total_paid + ?", amount),
+			"last_payment_at": time.Now(),
+		}).Error
 }
 
 // ========== 试用管理 ==========
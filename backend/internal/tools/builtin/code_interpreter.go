@@ -10,11 +10,16 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"backend/internal/tenant"
+	"backend/internal/tools"
 )
 
 // CodeInterpreterTool 代码解释器工具
 type CodeInterpreterTool struct {
-	config *CodeInterpreterConfig
+	config   *CodeInterpreterConfig
+	backends map[string]CodeSandbox
+	selector SandboxSelector
 }
 
 // CodeInterpreterConfig 代码解释器配置
@@ -27,6 +32,13 @@ type CodeInterpreterConfig struct {
 	EnableFileIO   bool          // 是否允许文件读写
 	MemoryLimit    int64         // 内存限制 (字节)
 	CPULimit       float64       // CPU 限制 (核心数)
+
+	Sandbox       string   // 默认沙箱后端: process / host
+	AllowHost     bool     // 是否允许租户选择宿主机直接执行
+	FileSizeLimit int64    // 单个文件写入上限 (字节)
+	MaxProcesses  int      // 进程/线程数上限
+	TmpfsSize     int64    // 私有 tmpfs 容量 (字节)
+	ReadOnlyPaths []string // 以只读方式挂载进沙箱的宿主目录
 }
 
 // DefaultCodeInterpreterConfig 默认配置
@@ -40,6 +52,11 @@ func DefaultCodeInterpreterConfig() *CodeInterpreterConfig {
 		EnableFileIO:  true,
 		MemoryLimit:   256 * 1024 * 1024, // 256MB
 		CPULimit:      1.0,
+		Sandbox:       SandboxProcess,
+		FileSizeLimit: 16 * 1024 * 1024, // 16MB
+		MaxProcesses:  32,
+		TmpfsSize:     64 * 1024 * 1024, // 64MB
+		ReadOnlyPaths: []string{"/usr", "/bin", "/sbin", "/lib", "/lib64", "/etc/alternatives"},
 	}
 }
 
@@ -48,7 +65,49 @@ func NewCodeInterpreterTool(config *CodeInterpreterConfig) *CodeInterpreterTool
 	if config == nil {
 		config = DefaultCodeInterpreterConfig()
 	}
-	return &CodeInterpreterTool{config: config}
+	t := &CodeInterpreterTool{config: config}
+	t.backends = map[string]CodeSandbox{
+		SandboxProcess: newProcessSandbox(config),
+	}
+	// 宿主机执行只能由部署方显式开启，租户配置无法自行降级
+	if config.AllowHost || config.Sandbox == SandboxHost {
+		t.backends[SandboxHost] = &hostSandbox{tool: t}
+	}
+	return t
+}
+
+// SetSandboxSelector 设置按租户选择沙箱后端的策略
+func (t *CodeInterpreterTool) SetSandboxSelector(selector SandboxSelector) {
+	t.selector = selector
+}
+
+// GetDefinition 获取工具定义
+func (t *CodeInterpreterTool) GetDefinition() *tools.ToolDefinition {
+	return &tools.ToolDefinition{
+		Name:        "code_interpreter",
+		DisplayName: "代码解释器",
+		Description: "在隔离沙箱中运行 Python / JavaScript / Bash 代码，可用于数据统计、图表生成等计算任务。沙箱无网络，仅 /work 与 /tmp 可写。",
+		Category:    "data_analysis",
+		Type:        "code_interpreter",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"language": map[string]any{
+					"type":        "string",
+					"enum":        t.config.AllowedLangs,
+					"description": "代码语言",
+				},
+				"code": map[string]any{
+					"type":        "string",
+					"description": "要执行的代码",
+				},
+			},
+			"required": []string{"language", "code"},
+		},
+		Timeout:     int(t.config.Timeout.Seconds()) + 5,
+		Status:      "active",
+		RequireAuth: true,
+	}
 }
 
 // Execute 执行代码
@@ -68,9 +127,21 @@ func (t *CodeInterpreterTool) Execute(ctx context.Context, input map[string]any)
 		return nil, fmt.Errorf("language %s is not allowed", language)
 	}
 
-	// 安全检查
-	if err := t.securityCheck(language, code); err != nil {
-		return nil, fmt.Errorf("security check failed: %w", err)
+	// 租户取自执行上下文，忽略输入中的 tenant_id，避免模型指定其他租户的沙箱后端
+	var tenantID string
+	if tc, ok := tenant.FromContext(ctx); ok {
+		tenantID = tc.TenantID
+	}
+	backend, err := t.selectSandbox(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 宿主机直接执行时只能依赖正则安全检查，隔离沙箱以内核边界为准
+	if backend.Name() == SandboxHost {
+		if err := t.securityCheck(language, code); err != nil {
+			return nil, fmt.Errorf("security check failed: %w", err)
+		}
 	}
 
 	// 创建带超时的上下文
@@ -78,12 +149,13 @@ func (t *CodeInterpreterTool) Execute(ctx context.Context, input map[string]any)
 	defer cancel()
 
 	// 执行代码
-	result, err := t.executeCode(execCtx, language, code)
+	result, err := backend.Run(execCtx, language, code)
 	if err != nil {
 		return map[string]any{
 			"success": false,
 			"error":   err.Error(),
 			"output":  "",
+			"sandbox": backend.Name(),
 		}, nil
 	}
 
@@ -96,8 +168,10 @@ func (t *CodeInterpreterTool) Execute(ctx context.Context, input map[string]any)
 		"success":  result.ExitCode == 0,
 		"output":   result.Output,
 		"error":    result.Error,
-		"exitCode": result.ExitCode,
-		"duration": result.Duration.String(),
+		"exitCode":      result.ExitCode,
+		"duration":      result.Duration.String(),
+		"sandbox":       backend.Name(),
+		"resourceUsage": result.Usage.toMap(),
 	}, nil
 }
 
@@ -118,6 +192,7 @@ type ExecutionResult struct {
 	Error    string
 	ExitCode int
 	Duration time.Duration
+	Usage    *ResourceUsage
 }
 
 // isLanguageAllowed 检查语言是否允许
@@ -261,6 +336,7 @@ func (t *CodeInterpreterTool) executeCode(ctx context.Context, language, code st
 		Output:   stdout.String(),
 		Error:    stderr.String(),
 		Duration: duration,
+		Usage:    collectUsage(cmd.ProcessState, duration),
 	}
 
 	if err != nil {
@@ -274,6 +350,7 @@ func (t *CodeInterpreterTool) executeCode(ctx context.Context, language, code st
 			result.Error = err.Error()
 		}
 	}
+	result.Usage.classify(ctx, t.config, result.ExitCode)
 
 	return result, nil
 }
@@ -0,0 +1,171 @@
+package builtin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 代码沙箱后端名称
+const (
+	SandboxHost    = "host"    // 宿主机直接执行，仅依赖正则安全检查
+	SandboxProcess = "process" // Linux 命名空间 + seccomp + rlimit 进程沙箱
+)
+
+// 资源超限类型
+const (
+	LimitTimeout  = "timeout"
+	LimitCPU      = "cpu"
+	LimitFileSize = "file_size"
+)
+
+// ErrSandboxUnavailable 当前平台或内核不支持进程沙箱
+var ErrSandboxUnavailable = errors.New("process sandbox is not available on this host")
+
+// CodeSandbox 代码执行后端
+type CodeSandbox interface {
+	Name() string
+	Run(ctx context.Context, language, code string) (*ExecutionResult, error)
+}
+
+// SandboxSelector 按租户选择沙箱后端，返回空字符串表示使用默认后端
+type SandboxSelector interface {
+	SandboxFor(ctx context.Context, tenantID string) string
+}
+
+// ResourceUsage 单次执行的资源使用情况
+type ResourceUsage struct {
+	WallTime      time.Duration
+	UserTime      time.Duration
+	SystemTime    time.Duration
+	MaxRSSBytes   int64
+	Signal        string // 终止进程的信号
+	LimitExceeded string // 触发的资源限制
+}
+
+// toMap 转换为工具输出中的结构化字段
+func (u *ResourceUsage) toMap() map[string]any {
+	if u == nil {
+		return nil
+	}
+	usage := map[string]any{
+		"wallTimeMs":   u.WallTime.Milliseconds(),
+		"userTimeMs":   u.UserTime.Milliseconds(),
+		"systemTimeMs": u.SystemTime.Milliseconds(),
+		"maxRssBytes":  u.MaxRSSBytes,
+	}
+	if u.Signal != "" {
+		usage["signal"] = u.Signal
+	}
+	if u.LimitExceeded != "" {
+		usage["limitExceeded"] = u.LimitExceeded
+	}
+	return usage
+}
+
+// classify 根据终止信号、退出码与上下文推断触发的资源限制
+func (u *ResourceUsage) classify(ctx context.Context, config *CodeInterpreterConfig, exitCode int) {
+	if u == nil {
+		return
+	}
+	signal := u.Signal
+	if signal == "" {
+		// 子进程被信号终止时 shell 以 128+信号值 退出
+		signal = shellExitSignal(exitCode)
+	}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		u.LimitExceeded = LimitTimeout
+	case signal == "SIGXCPU":
+		u.LimitExceeded = LimitCPU
+	case signal == "SIGXFSZ":
+		u.LimitExceeded = LimitFileSize
+	case signal == "SIGKILL" && u.UserTime+u.SystemTime >= cpuLimit(config):
+		// 超过 RLIMIT_CPU 硬限制时内核直接发送 SIGKILL
+		u.LimitExceeded = LimitCPU
+	}
+}
+
+// collectUsage 从进程状态中提取资源使用
+func collectUsage(state *os.ProcessState, wall time.Duration) *ResourceUsage {
+	usage := &ResourceUsage{WallTime: wall}
+	if state == nil {
+		return usage
+	}
+	usage.UserTime = state.UserTime()
+	usage.SystemTime = state.SystemTime()
+	usage.MaxRSSBytes = maxRSS(state)
+	usage.Signal = exitSignal(state)
+	return usage
+}
+
+// cpuLimit CPU 时间上限，与执行超时一致
+func cpuLimit(config *CodeInterpreterConfig) time.Duration {
+	if config.Timeout < time.Second {
+		return time.Second
+	}
+	return config.Timeout.Truncate(time.Second)
+}
+
+// selectSandbox 选择本次执行使用的沙箱后端
+func (t *CodeInterpreterTool) selectSandbox(ctx context.Context, tenantID string) (CodeSandbox, error) {
+	name := t.config.Sandbox
+	if name == "" {
+		name = SandboxProcess
+	}
+	if t.selector != nil && tenantID != "" {
+		if selected := strings.TrimSpace(t.selector.SandboxFor(ctx, tenantID)); selected != "" {
+			name = selected
+		}
+	}
+	backend, ok := t.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown code sandbox: %s", name)
+	}
+	return backend, nil
+}
+
+// hostSandbox 宿主机执行后端（原有行为）
+type hostSandbox struct {
+	tool *CodeInterpreterTool
+}
+
+func (s *hostSandbox) Name() string { return SandboxHost }
+
+func (s *hostSandbox) Run(ctx context.Context, language, code string) (*ExecutionResult, error) {
+	return s.tool.executeCode(ctx, language, code)
+}
+
+// sandboxScript 各语言在沙箱内的脚本文件名与启动命令
+func sandboxScript(language string) (string, []string, error) {
+	switch strings.ToLower(language) {
+	case "python", "python3":
+		return "main.py", []string{"python3", "main.py"}, nil
+	case "javascript", "js", "node":
+		return "main.js", []string{"node", "main.js"}, nil
+	case "bash", "sh":
+		return "main.sh", []string{"bash", "main.sh"}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported language: %s", language)
+	}
+}
+
+// limitedBuffer 超过上限后丢弃输出，防止沙箱进程撑爆宿主内存
+type limitedBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - len(b.buf); remain > 0 {
+		b.buf = append(b.buf, p[:min(len(p), remain)]...)
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return string(b.buf)
+}
@@ -0,0 +1,456 @@
+//go:build linux && (amd64 || arm64)
+
+package builtin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// 进程沙箱通过重新执行当前二进制进入初始化流程：
+// 父进程以新的 user/mount/pid/net/ipc/uts/cgroup 命名空间启动 /proc/self/exe，
+// 子进程在 init() 中搭建私有 tmpfs 根目录、pivot_root、设置 rlimit 与 seccomp 后 exec 解释器。
+const (
+	sandboxInitArg0   = "code-sandbox-init"
+	sandboxInitEnv    = "CODE_SANDBOX_INIT"
+	sandboxInitMarker = "code sandbox init failed: "
+	sandboxInitFailed = 125
+	sandboxSpecFD     = 3
+	sandboxPath       = "/usr/local/bin:/usr/bin:/bin"
+
+	// 宿主机以 root 运行时，沙箱内 root 映射为 nobody
+	sandboxNobody = 65534
+
+	secbitNoRoot       = 1 << 0
+	secbitNoRootLocked = 1 << 1
+)
+
+// sandboxSpec 父进程通过 fd 3 传给沙箱初始化进程的执行规格
+type sandboxSpec struct {
+	Root          string   `json:"root"`
+	Script        string   `json:"script"`
+	Code          string   `json:"code"`
+	Argv          []string `json:"argv"`
+	TmpfsSize     int64    `json:"tmpfsSize"`
+	ReadOnlyPaths []string `json:"readOnlyPaths"`
+	CPUSeconds    uint64   `json:"cpuSeconds"`
+	MemoryBytes   uint64   `json:"memoryBytes"`
+	FileSizeBytes uint64   `json:"fileSizeBytes"`
+	MaxProcesses  uint64   `json:"maxProcesses"`
+}
+
+func init() {
+	if len(os.Args) == 0 || os.Args[0] != sandboxInitArg0 || os.Getenv(sandboxInitEnv) != "1" {
+		return
+	}
+	// securebits、no_new_privs 与 seccomp 均作用于当前线程，必须与 exec 在同一线程
+	runtime.LockOSThread()
+	err := sandboxInit()
+	fmt.Fprintf(os.Stderr, "%s%v\n", sandboxInitMarker, err)
+	os.Exit(sandboxInitFailed)
+}
+
+// processSandbox Linux 进程级沙箱，无需 Docker
+type processSandbox struct {
+	config *CodeInterpreterConfig
+}
+
+func newProcessSandbox(config *CodeInterpreterConfig) *processSandbox {
+	return &processSandbox{config: config}
+}
+
+func (s *processSandbox) Name() string { return SandboxProcess }
+
+// Run 在隔离的命名空间中执行代码
+func (s *processSandbox) Run(ctx context.Context, language, code string) (*ExecutionResult, error) {
+	script, argv, err := sandboxScript(language)
+	if err != nil {
+		return nil, err
+	}
+
+	// 沙箱内 tmpfs 的挂载点，宿主机上始终是空目录
+	root, err := os.MkdirTemp("", "sandbox_root_*")
+	if err != nil {
+		return nil, fmt.Errorf("create sandbox root: %w", err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Chmod(root, 0o755); err != nil {
+		return nil, fmt.Errorf("chmod sandbox root: %w", err)
+	}
+
+	spec := &sandboxSpec{
+		Root:          root,
+		Script:        script,
+		Code:          code,
+		Argv:          argv,
+		TmpfsSize:     s.config.TmpfsSize,
+		ReadOnlyPaths: s.config.ReadOnlyPaths,
+		CPUSeconds:    uint64(cpuLimit(s.config) / time.Second),
+		MemoryBytes:   uint64(max(s.config.MemoryLimit, 0)),
+		FileSizeBytes: uint64(max(s.config.FileSizeLimit, 0)),
+		MaxProcesses:  uint64(max(s.config.MaxProcesses, 0)),
+	}
+
+	specR, specW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create spec pipe: %w", err)
+	}
+	defer specR.Close()
+
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{sandboxInitArg0}
+	cmd.Env = []string{sandboxInitEnv + "=1"}
+	cmd.ExtraFiles = []*os.File{specR}
+	cmd.WaitDelay = time.Second
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS | unix.CLONE_NEWCGROUP,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+	if os.Getuid() == 0 {
+		// 宿主机以 root 运行时，沙箱内 root 映射为 nobody，并切换身份、清空附加组
+		cmd.SysProcAttr.UidMappings[0].HostID = sandboxNobody
+		cmd.SysProcAttr.GidMappings[0].HostID = sandboxNobody
+		cmd.SysProcAttr.GidMappingsEnableSetgroups = true
+		cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0}
+	}
+
+	limit := s.config.MaxOutputSize + 1
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: limit}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	startTime := time.Now()
+	if err := cmd.Start(); err != nil {
+		specW.Close()
+		return nil, fmt.Errorf("%w: %v", ErrSandboxUnavailable, err)
+	}
+	go func() {
+		defer specW.Close()
+		_ = json.NewEncoder(specW).Encode(spec)
+	}()
+
+	waitErr := cmd.Wait()
+	duration := time.Since(startTime)
+
+	result := &ExecutionResult{
+		Output:   stdout.String(),
+		Error:    stderr.String(),
+		Duration: duration,
+		Usage:    collectUsage(cmd.ProcessState, duration),
+	}
+	if waitErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(waitErr, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		} else {
+			result.ExitCode = -1
+			result.Error = waitErr.Error()
+		}
+	}
+
+	if result.ExitCode == sandboxInitFailed {
+		if idx := strings.LastIndex(result.Error, sandboxInitMarker); idx >= 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSandboxUnavailable, strings.TrimSpace(result.Error[idx+len(sandboxInitMarker):]))
+		}
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.ExitCode = -1
+		result.Error = "execution timeout"
+	}
+	result.Usage.classify(ctx, s.config, result.ExitCode)
+
+	return result, nil
+}
+
+// sandboxInit 在新命名空间中完成隔离并 exec 解释器，仅在失败时返回
+func sandboxInit() error {
+	specFile := os.NewFile(sandboxSpecFD, "sandbox-spec")
+	var spec sandboxSpec
+	if err := json.NewDecoder(specFile).Decode(&spec); err != nil {
+		return fmt.Errorf("read spec: %w", err)
+	}
+	specFile.Close()
+	if len(spec.Argv) == 0 {
+		return errors.New("empty command")
+	}
+
+	if err := buildSandboxRoot(&spec); err != nil {
+		return err
+	}
+	_ = unix.Sethostname([]byte("sandbox"))
+
+	os.Setenv("PATH", sandboxPath)
+	bin, err := exec.LookPath(spec.Argv[0])
+	if err != nil {
+		return fmt.Errorf("%s is not available in sandbox: %w", spec.Argv[0], err)
+	}
+	if err := unix.Chdir("/work"); err != nil {
+		return fmt.Errorf("chdir: %w", err)
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+	// 沙箱内 uid 0 在 exec 后不再获得任何 capability
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, secbitNoRoot|secbitNoRootLocked, 0, 0, 0); err != nil {
+		return fmt.Errorf("set securebits: %w", err)
+	}
+	if err := installSeccompFilter(); err != nil {
+		return err
+	}
+	if err := applySandboxLimits(&spec); err != nil {
+		return err
+	}
+
+	env := []string{"PATH=" + sandboxPath, "HOME=/work", "TMPDIR=/tmp", "LANG=C.UTF-8"}
+	return unix.Exec(bin, spec.Argv, env)
+}
+
+// buildSandboxRoot 以私有 tmpfs 为根，只读挂载运行时目录后 pivot_root
+func buildSandboxRoot(spec *sandboxSpec) error {
+	root := spec.Root
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	opts := "mode=0755"
+	if spec.TmpfsSize > 0 {
+		opts = fmt.Sprintf("size=%d,%s", spec.TmpfsSize, opts)
+	}
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, opts); err != nil {
+		return fmt.Errorf("mount tmpfs: %w", err)
+	}
+
+	for _, path := range spec.ReadOnlyPaths {
+		if err := bindReadOnly(path, filepath.Join(root, path)); err != nil {
+			return fmt.Errorf("bind %s: %w", path, err)
+		}
+	}
+	for _, dir := range []struct {
+		name string
+		mode os.FileMode
+	}{{"work", 0o755}, {"tmp", 0o777 | os.ModeSticky}, {"dev", 0o755}, {"proc", 0o555}} {
+		path := filepath.Join(root, dir.name)
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			return fmt.Errorf("mkdir %s: %w", dir.name, err)
+		}
+		if err := os.Chmod(path, dir.mode); err != nil {
+			return fmt.Errorf("chmod %s: %w", dir.name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "work", spec.Script), []byte(spec.Code), 0o644); err != nil {
+		return fmt.Errorf("write script: %w", err)
+	}
+
+	// 设备节点与 /proc 缺失时解释器仍可运行，挂载失败不视为致命错误
+	for _, dev := range []string{"null", "zero", "random", "urandom"} {
+		target := filepath.Join(root, "dev", dev)
+		if f, err := os.Create(target); err == nil {
+			f.Close()
+			_ = unix.Mount("/dev/"+dev, target, "", unix.MS_BIND, "")
+		}
+	}
+	_ = unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, "")
+
+	oldRoot := filepath.Join(root, ".oldroot")
+	if err := os.Mkdir(oldRoot, 0o700); err != nil {
+		return fmt.Errorf("mkdir oldroot: %w", err)
+	}
+	if err := unix.PivotRoot(root, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := unix.Chdir("/"); err != nil {
+		return fmt.Errorf("chdir: %w", err)
+	}
+	if err := unix.Unmount("/.oldroot", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount oldroot: %w", err)
+	}
+	return os.Remove("/.oldroot")
+}
+
+// bindReadOnly 将宿主路径只读绑定到沙箱根下，符号链接按原样复制
+func bindReadOnly(src, dst string) error {
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	if info.IsDir() {
+		err = os.Mkdir(dst, 0o755)
+	} else {
+		var f *os.File
+		if f, err = os.Create(dst); err == nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := unix.Mount(src, dst, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return err
+	}
+
+	attr := &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY | unix.MOUNT_ATTR_NOSUID}
+	if err := unix.MountSetattr(-1, dst, unix.AT_RECURSIVE, attr); err == nil {
+		return nil
+	}
+	// 旧内核没有 mount_setattr：仅重挂顶层，并保留命名空间中被锁定的挂载标志
+	var st unix.Statfs_t
+	if err := unix.Statfs(dst, &st); err != nil {
+		return err
+	}
+	locked := uintptr(st.Flags) & (unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC |
+		unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME)
+	return unix.Mount("", dst, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY|unix.MS_NOSUID|locked, "")
+}
+
+// applySandboxLimits 设置 CPU、内存、文件大小与进程数 rlimit，值为 0 表示不限制
+func applySandboxLimits(spec *sandboxSpec) error {
+	limits := []struct {
+		name     string
+		resource int
+		soft     uint64
+		hard     uint64
+	}{
+		// 软限制触发 SIGXCPU，硬限制多留一秒后由内核 SIGKILL
+		{"cpu", unix.RLIMIT_CPU, spec.CPUSeconds, spec.CPUSeconds + 1},
+		{"memory", unix.RLIMIT_AS, spec.MemoryBytes, spec.MemoryBytes},
+		{"file size", unix.RLIMIT_FSIZE, spec.FileSizeBytes, spec.FileSizeBytes},
+		{"processes", unix.RLIMIT_NPROC, spec.MaxProcesses, spec.MaxProcesses},
+		{"open files", unix.RLIMIT_NOFILE, 256, 256},
+	}
+	for _, l := range limits {
+		if l.soft == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(l.resource, &syscall.Rlimit{Cur: l.soft, Max: l.hard}); err != nil {
+			return fmt.Errorf("set %s limit: %w", l.name, err)
+		}
+	}
+	if err := syscall.Setrlimit(unix.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		return fmt.Errorf("set core limit: %w", err)
+	}
+	return nil
+}
+
+// sandboxDeniedSyscalls 沙箱内禁止的系统调用，返回 EPERM
+var sandboxDeniedSyscalls = []uint32{
+	unix.SYS_PTRACE, unix.SYS_PROCESS_VM_READV, unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_MOUNT, unix.SYS_UMOUNT2, unix.SYS_PIVOT_ROOT, unix.SYS_CHROOT,
+	unix.SYS_FSOPEN, unix.SYS_FSCONFIG, unix.SYS_FSMOUNT, unix.SYS_FSPICK,
+	unix.SYS_MOVE_MOUNT, unix.SYS_OPEN_TREE, unix.SYS_MOUNT_SETATTR,
+	unix.SYS_UNSHARE, unix.SYS_SETNS,
+	unix.SYS_OPEN_BY_HANDLE_AT, unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_BPF, unix.SYS_PERF_EVENT_OPEN, unix.SYS_USERFAULTFD,
+	unix.SYS_KEYCTL, unix.SYS_ADD_KEY, unix.SYS_REQUEST_KEY,
+	unix.SYS_INIT_MODULE, unix.SYS_FINIT_MODULE, unix.SYS_DELETE_MODULE,
+	unix.SYS_KEXEC_LOAD, unix.SYS_KEXEC_FILE_LOAD, unix.SYS_REBOOT,
+	unix.SYS_SWAPON, unix.SYS_SWAPOFF, unix.SYS_ACCT, unix.SYS_QUOTACTL, unix.SYS_SYSLOG,
+	unix.SYS_SETTIMEOFDAY, unix.SYS_CLOCK_SETTIME, unix.SYS_CLOCK_ADJTIME, unix.SYS_ADJTIMEX,
+	unix.SYS_SETHOSTNAME, unix.SYS_SETDOMAINNAME,
+}
+
+// installSeccompFilter 安装 seccomp 过滤器：
+// 校验架构，拒绝 x32 调用与黑名单系统调用，禁止 clone 创建新的命名空间，clone3 返回 ENOSYS 让 libc 回退到 clone。
+func installSeccompFilter() error {
+	var arch uint32
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = unix.AUDIT_ARCH_X86_64
+	case "arm64":
+		arch = unix.AUDIT_ARCH_AARCH64
+	}
+	const (
+		offsetNr   = 0
+		offsetArch = 4
+		offsetArg0 = 16 // args[0] 低 32 位（小端）
+		x32Bit     = 0x40000000
+		nsFlags    = unix.CLONE_NEWNS | unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWNET |
+			unix.CLONE_NEWIPC | unix.CLONE_NEWUTS | unix.CLONE_NEWCGROUP
+	)
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+
+	n := uint8(len(sandboxDeniedSyscalls))
+	prog := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, arch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetNr),
+		jump(unix.BPF_JMP|unix.BPF_JGE|unix.BPF_K, x32Bit, n+6, 0),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE3, n+6, 0),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.SYS_CLONE, 0, 3),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, offsetArg0),
+		jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, nsFlags, n+2, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+	}
+	for i, nr := range sandboxDeniedSyscalls {
+		prog = append(prog, jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, nr, n-uint8(i), 0))
+	}
+	prog = append(prog,
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.EPERM)),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+	)
+
+	fprog := unix.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	if err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&fprog)), 0, 0); err != nil {
+		return fmt.Errorf("install seccomp filter: %w", err)
+	}
+	return nil
+}
+
+// maxRSS 峰值常驻内存（Linux rusage 以 KB 为单位）
+func maxRSS(state *os.ProcessState) int64 {
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return rusage.Maxrss * 1024
+	}
+	return 0
+}
+
+// exitSignal 终止进程的信号名称
+func exitSignal(state *os.ProcessState) string {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return unix.SignalName(status.Signal())
+	}
+	return ""
+}
+
+// shellExitSignal 将 shell 的 128+n 退出码还原为信号名称
+func shellExitSignal(exitCode int) string {
+	if exitCode <= 128 || exitCode > 128+64 {
+		return ""
+	}
+	return unix.SignalName(syscall.Signal(exitCode - 128))
+}
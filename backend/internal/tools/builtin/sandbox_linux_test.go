@@ -0,0 +1,159 @@
+//go:build linux && (amd64 || arm64)
+
+package builtin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/tools"
+)
+
+func newTestProcessSandbox(t *testing.T, mutate func(*CodeInterpreterConfig)) *CodeInterpreterTool {
+	t.Helper()
+	config := DefaultCodeInterpreterConfig()
+	config.Timeout = 5 * time.Second
+	if mutate != nil {
+		mutate(config)
+	}
+	tool := NewCodeInterpreterTool(config)
+
+	probe, err := tool.backends[SandboxProcess].Run(context.Background(), "bash", "true")
+	if errors.Is(err, ErrSandboxUnavailable) {
+		t.Skipf("process sandbox unavailable: %v", err)
+	}
+	if err != nil || probe.ExitCode != 0 {
+		t.Fatalf("probe failed: %v %+v", err, probe)
+	}
+	return tool
+}
+
+func runSandboxed(t *testing.T, tool *CodeInterpreterTool, code string) map[string]any {
+	t.Helper()
+	out, err := tool.Execute(context.Background(), map[string]any{"language": "bash", "code": code})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if out["sandbox"] != SandboxProcess {
+		t.Fatalf("expected process sandbox, got %v", out["sandbox"])
+	}
+	return out
+}
+
+func TestProcessSandboxIsolation(t *testing.T) {
+	tool := newTestProcessSandbox(t, nil)
+
+	out := runSandboxed(t, tool, `echo pid=$$; echo hi > /work/out.txt && cat /work/out.txt; touch /usr/x 2>/dev/null || echo ro; ls /root 2>/dev/null || echo nohome`)
+	output := out["output"].(string)
+	for _, want := range []string{"pid=1", "hi", "ro", "nohome"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in output, got %q (stderr %q)", want, output, out["error"])
+		}
+	}
+	usage, ok := out["resourceUsage"].(map[string]any)
+	if !ok || usage["wallTimeMs"] == nil || usage["maxRssBytes"].(int64) <= 0 {
+		t.Fatalf("expected resource usage, got %+v", out["resourceUsage"])
+	}
+
+	// 网络命名空间中只有未启用的 loopback
+	out = runSandboxed(t, tool, `exec 3<>/dev/tcp/1.1.1.1/80 && echo connected || echo offline`)
+	if !strings.Contains(out["output"].(string), "offline") {
+		t.Fatalf("network must be unavailable, got %q", out["output"])
+	}
+
+	// seccomp 拒绝创建新的命名空间
+	out = runSandboxed(t, tool, `unshare -r true 2>/dev/null && echo escaped || echo denied`)
+	if !strings.Contains(out["output"].(string), "denied") {
+		t.Fatalf("unshare must be denied, got %q", out["output"])
+	}
+}
+
+func TestProcessSandboxLimits(t *testing.T) {
+	tool := newTestProcessSandbox(t, func(c *CodeInterpreterConfig) {
+		c.Timeout = time.Second
+		c.FileSizeLimit = 4096
+	})
+
+	out := runSandboxed(t, tool, `head -c 8192 /dev/zero > /work/big`)
+	usage := out["resourceUsage"].(map[string]any)
+	if out["success"] != false || usage["limitExceeded"] != LimitFileSize {
+		t.Fatalf("expected file size limit, got %+v", out)
+	}
+
+	out = runSandboxed(t, tool, `while :; do :; done`)
+	usage = out["resourceUsage"].(map[string]any)
+	if out["success"] != false || (usage["limitExceeded"] != LimitCPU && usage["limitExceeded"] != LimitTimeout) {
+		t.Fatalf("expected cpu or timeout limit, got %+v", out)
+	}
+}
+
+type staticSandboxSelector map[string]string
+
+func (s staticSandboxSelector) SandboxFor(_ context.Context, tenantID string) string {
+	return s[tenantID]
+}
+
+func TestCodeInterpreterSelectsSandboxPerTenant(t *testing.T) {
+	config := DefaultCodeInterpreterConfig()
+	config.AllowHost = true
+	tool := NewCodeInterpreterTool(config)
+	tool.SetSandboxSelector(staticSandboxSelector{"legacy": SandboxHost, "broken": "docker"})
+	ctx := context.Background()
+
+	for tenantID, want := range map[string]string{"": SandboxProcess, "other": SandboxProcess, "legacy": SandboxHost} {
+		backend, err := tool.selectSandbox(ctx, tenantID)
+		if err != nil || backend.Name() != want {
+			t.Fatalf("tenant %q: expected %s, got %v (%v)", tenantID, want, backend, err)
+		}
+	}
+	if _, err := tool.selectSandbox(ctx, "broken"); err == nil {
+		t.Fatal("unknown sandbox must be rejected")
+	}
+
+	locked := NewCodeInterpreterTool(nil)
+	locked.SetSandboxSelector(staticSandboxSelector{"legacy": SandboxHost})
+	if _, err := locked.selectSandbox(ctx, "legacy"); err == nil {
+		t.Fatal("host execution must require AllowHost")
+	}
+}
+
+type namedSandbox string
+
+func (s namedSandbox) Name() string { return string(s) }
+
+func (s namedSandbox) Run(context.Context, string, string) (*ExecutionResult, error) {
+	return &ExecutionResult{Output: string(s)}, nil
+}
+
+func TestCodeInterpreterTakesTenantFromRequest(t *testing.T) {
+	config := DefaultCodeInterpreterConfig()
+	config.AllowHost = true
+	tool := NewCodeInterpreterTool(config)
+	tool.backends = map[string]CodeSandbox{SandboxProcess: namedSandbox(SandboxProcess), SandboxHost: namedSandbox(SandboxHost)}
+	tool.SetSandboxSelector(staticSandboxSelector{"legacy": SandboxHost})
+
+	registry := tools.NewToolRegistry()
+	if err := registry.Register("code_interpreter", tool, tool.GetDefinition()); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	executor := tools.NewToolExecutor(registry, nil)
+	run := func(tenantID string, input map[string]any) string {
+		input["language"], input["code"] = "python", "print(1)"
+		result, err := executor.Execute(context.Background(), &tools.ToolExecutionRequest{TenantID: tenantID, ToolName: "code_interpreter", Input: input})
+		if err != nil {
+			t.Fatalf("execute: %v", err)
+		}
+		return result.Output["sandbox"].(string)
+	}
+
+	if got := run("legacy", map[string]any{}); got != SandboxHost {
+		t.Fatalf("request tenant must select its sandbox, got %s", got)
+	}
+	// 模型在参数中伪造 tenant_id 不影响沙箱选择
+	if got := run("other", map[string]any{"tenant_id": "legacy"}); got != SandboxProcess {
+		t.Fatalf("tenant_id in tool input must be ignored, got %s", got)
+	}
+}
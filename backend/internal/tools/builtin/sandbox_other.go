@@ -0,0 +1,27 @@
+//go:build !linux || !(amd64 || arm64)
+
+package builtin
+
+import (
+	"context"
+	"os"
+)
+
+// processSandbox 非 Linux amd64/arm64 平台上不可用
+type processSandbox struct{}
+
+func newProcessSandbox(_ *CodeInterpreterConfig) *processSandbox {
+	return &processSandbox{}
+}
+
+func (s *processSandbox) Name() string { return SandboxProcess }
+
+func (s *processSandbox) Run(_ context.Context, _, _ string) (*ExecutionResult, error) {
+	return nil, ErrSandboxUnavailable
+}
+
+func maxRSS(_ *os.ProcessState) int64 { return 0 }
+
+func exitSignal(_ *os.ProcessState) string { return "" }
+
+func shellExitSignal(_ int) string { return "" }
@@ -6,6 +6,8 @@ import (
 	"sync"
 	"time"
 
+	"backend/internal/tenant"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -14,6 +16,7 @@ import (
 type ToolExecutor struct {
 	registry *ToolRegistry
 	db       *gorm.DB
+	metrics  *ToolMetrics
 }
 
 // NewToolExecutor 创建工具执行引擎
@@ -24,6 +27,11 @@ func NewToolExecutor(registry *ToolRegistry, db *gorm.DB) *ToolExecutor {
 	}
 }
 
+// SetMetrics 设置工具调用指标收集器
+func (e *ToolExecutor) SetMetrics(metrics *ToolMetrics) {
+	e.metrics = metrics
+}
+
 // Execute 执行工具
 func (e *ToolExecutor) Execute(ctx context.Context, req *ToolExecutionRequest) (*ToolExecutionResult, error) {
 	// 1. 查找工具
@@ -31,6 +39,10 @@ func (e *ToolExecutor) Execute(ctx context.Context, req *ToolExecutionRequest) (
 	if !exists {
 		return nil, fmt.Errorf("工具 %s 未注册", req.ToolName)
 	}
+	// 租户自定义工具（HTTP、MCP 等）只能由所属租户调用，调用方租户未知时同样拒绝
+	if def, ok := e.registry.GetDefinition(req.ToolName); ok && def.TenantID != "" && def.TenantID != req.TenantID {
+		return nil, fmt.Errorf("工具 %s 未注册", req.ToolName)
+	}
 	
 	// 2. 验证参数
 	if err := handler.Validate(req.Input); err != nil {
@@ -65,10 +77,22 @@ func (e *ToolExecutor) Execute(ctx context.Context, req *ToolExecutionRequest) (
 	
 	execCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
+	// 工具需要调用方租户时从上下文读取（以请求中的租户为准），不信任模型生成的参数
+	if req.TenantID != "" {
+		tc, _ := tenant.FromContext(execCtx)
+		if tc.TenantID != req.TenantID {
+			tc = tenant.TenantContext{TenantID: req.TenantID}
+		}
+		execCtx = tenant.WithTenantContext(execCtx, tc)
+	}
 	
 	startTime := time.Now()
 	output, err := handler.Execute(execCtx, req.Input)
-	duration := time.Since(startTime).Milliseconds()
+	elapsed := time.Since(startTime)
+	duration := elapsed.Milliseconds()
+	if e.metrics != nil {
+		e.metrics.RecordCall(req.ToolName, err == nil, elapsed, err)
+	}
 	
 	// 5. 更新执行记录
 	now := time.Now()
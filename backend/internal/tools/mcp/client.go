@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// clientInfo 连接外部 MCP 服务时上报的客户端信息
+var clientInfo = Implementation{Name: "AgentFlowCreativeHub", Version: "1.0.0"}
+
+// maxToolPages tools/list 分页上限，防止服务端返回循环游标
+const maxToolPages = 50
+
+// Client MCP 客户端
+type Client struct {
+	transport Transport
+	init      *InitializeResult
+}
+
+// NewClient 创建 MCP 客户端
+func NewClient(transport Transport) *Client {
+	return &Client{transport: transport}
+}
+
+// Initialize 启动传输并完成 initialize 握手
+func (c *Client) Initialize(ctx context.Context) error {
+	if err := c.transport.Start(ctx); err != nil {
+		return err
+	}
+
+	raw, err := c.transport.Call(ctx, "initialize", InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      clientInfo,
+	})
+	if err != nil {
+		return fmt.Errorf("MCP 初始化失败: %w", err)
+	}
+
+	var result InitializeResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return fmt.Errorf("解析 initialize 响应失败: %w", err)
+	}
+	if !supportedProtocolVersions[result.ProtocolVersion] {
+		return fmt.Errorf("不支持的 MCP 协议版本: %s", result.ProtocolVersion)
+	}
+	if t, ok := c.transport.(interface{ setProtocolVersion(string) }); ok {
+		t.setProtocolVersion(result.ProtocolVersion)
+	}
+	c.init = &result
+
+	return c.transport.Notify(ctx, "notifications/initialized", nil)
+}
+
+// ServerInfo 服务端信息（Initialize 之后可用）
+func (c *Client) ServerInfo() Implementation {
+	if c.init == nil {
+		return Implementation{}
+	}
+	return c.init.ServerInfo
+}
+
+// ListTools 获取服务端全部工具（自动翻页）
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if c.init != nil && c.init.Capabilities.Tools == nil {
+		return nil, nil
+	}
+
+	var tools []Tool
+	cursor := ""
+	for page := 0; page < maxToolPages; page++ {
+		raw, err := c.transport.Call(ctx, "tools/list", ListToolsParams{Cursor: cursor})
+		if err != nil {
+			return nil, fmt.Errorf("获取 MCP 工具列表失败: %w", err)
+		}
+		var result ListToolsResult
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("解析 tools/list 响应失败: %w", err)
+		}
+		tools = append(tools, result.Tools...)
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// CallTool 调用工具
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	raw, err := c.transport.Call(ctx, "tools/call", CallToolParams{Name: name, Arguments: arguments})
+	if err != nil {
+		return nil, err
+	}
+	var result CallToolResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, fmt.Errorf("解析 tools/call 响应失败: %w", err)
+	}
+	return &result, nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
@@ -0,0 +1,275 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend/internal/logger"
+	"backend/internal/tools"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestServer 模拟 Streamable HTTP MCP 服务：initialize 返回 JSON 并下发会话 ID，
+// 其余请求以 SSE 返回（先推送一条进度通知再返回结果）
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if msg.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "session-1")
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(NewResultResponse(msg.ID, InitializeResult{
+				ProtocolVersion: ProtocolVersion,
+				Capabilities:    ServerCapabilities{Tools: &ListChangedCapability{}},
+				ServerInfo:      Implementation{Name: "test-server", Version: "0.1.0"},
+			}))
+			return
+		}
+		if r.Header.Get("Mcp-Session-Id") != "session-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		if msg.IsNotification() {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var result any
+		switch msg.Method {
+		case "tools/list":
+			var params ListToolsParams
+			_ = json.Unmarshal(msg.Params, &params)
+			if params.Cursor == "" {
+				result = ListToolsResult{
+					Tools:      []Tool{{Name: "echo", InputSchema: map[string]any{"type": "object", "required": []any{"text"}}}},
+					NextCursor: "page-2",
+				}
+			} else {
+				result = ListToolsResult{Tools: []Tool{{Name: "fail", InputSchema: map[string]any{"type": "object"}}}}
+			}
+		case "tools/call":
+			var params CallToolParams
+			_ = json.Unmarshal(msg.Params, &params)
+			if params.Name == "fail" {
+				result = CallToolResult{Content: []Content{TextContent("boom")}, IsError: true}
+			} else {
+				if _, leaked := params.Arguments["tenant_id"]; leaked {
+					http.Error(w, "unexpected tenant_id", http.StatusBadRequest)
+					return
+				}
+				result = CallToolResult{Content: []Content{TextContent(fmt.Sprint(params.Arguments["text"]))}}
+			}
+		default:
+			result = nil
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+		data, _ := json.Marshal(NewResultResponse(msg.ID, result))
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}))
+}
+
+func TestClientOverHTTP(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(NewHTTPTransport(server.URL, nil, nil))
+	if err := client.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	if client.ServerInfo().Name != "test-server" {
+		t.Fatalf("unexpected server info: %+v", client.ServerInfo())
+	}
+
+	list, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "echo" || list[1].Name != "fail" {
+		t.Fatalf("unexpected tools: %+v", list)
+	}
+
+	result, err := client.CallTool(ctx, "echo", map[string]any{"text": "你好"})
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result.Text() != "你好" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestRemoteToolThroughExecutor(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	conn := &connection{
+		server: MCPServer{Name: "test"},
+		dial: func(ctx context.Context) (*Client, error) {
+			client := NewClient(NewHTTPTransport(server.URL, nil, nil))
+			return client, client.Initialize(ctx)
+		},
+	}
+
+	registry := tools.NewToolRegistry()
+	for _, name := range []string{"echo", "fail"} {
+		schema := map[string]any{"type": "object"}
+		if name == "echo" {
+			schema["required"] = []any{"text"}
+		}
+		def := &tools.ToolDefinition{Name: registeredToolName("tenant-a", "test", name), TenantID: "tenant-a", Type: "mcp", Status: "active"}
+		if err := registry.Register(def.Name, &RemoteTool{conn: conn, name: name, schema: schema}, def); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+
+	executor := tools.NewToolExecutor(registry, nil)
+	ctx := context.Background()
+	echo, fail := registeredToolName("tenant-a", "test", "echo"), registeredToolName("tenant-a", "test", "fail")
+
+	result, err := executor.Execute(ctx, &tools.ToolExecutionRequest{
+		TenantID: "tenant-a",
+		ToolName: echo,
+		Input:    map[string]any{"text": "hello", "tenant_id": "tenant-a"},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if result.Output["text"] != "hello" {
+		t.Fatalf("unexpected output: %+v", result.Output)
+	}
+
+	if _, err := executor.Execute(ctx, &tools.ToolExecutionRequest{TenantID: "tenant-a", ToolName: echo, Input: map[string]any{}}); err == nil {
+		t.Fatal("expected validation error for missing required argument")
+	}
+	if _, err := executor.Execute(ctx, &tools.ToolExecutionRequest{TenantID: "tenant-a", ToolName: fail, Input: map[string]any{}}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected tool error, got %v", err)
+	}
+	if _, err := executor.Execute(ctx, &tools.ToolExecutionRequest{TenantID: "tenant-b", ToolName: echo, Input: map[string]any{"text": "x"}}); err == nil {
+		t.Fatal("expected other tenants to be rejected")
+	}
+	if _, err := executor.Execute(ctx, &tools.ToolExecutionRequest{ToolName: echo, Input: map[string]any{"text": "x"}}); err == nil {
+		t.Fatal("expected calls without tenant to be rejected")
+	}
+}
+
+func TestNaming(t *testing.T) {
+	if got := normalizeNamespace("My GitHub-Server!"); got != "my_github_server" {
+		t.Fatalf("normalizeNamespace = %q", got)
+	}
+	if got := registeredToolName("", "gh", "issues.create"); got != "gh__issues_create" {
+		t.Fatalf("registeredToolName = %q", got)
+	}
+	a, b := registeredToolName("tenant-a", "gh", "issues.create"), registeredToolName("tenant-b", "gh", "issues.create")
+	if a == b || !strings.HasSuffix(a, "_gh__issues_create") {
+		t.Fatalf("expected tenant-scoped names, got %q and %q", a, b)
+	}
+	if got := registeredToolName("tenant-a", "ns", strings.Repeat("a", 100)); len(got) != maxToolNameLength {
+		t.Fatalf("expected name truncated to %d, got %d", maxToolNameLength, len(got))
+	}
+}
+
+func TestValidateStdioEnvAndArgs(t *testing.T) {
+	svc := NewService(nil, nil, Options{
+		AllowedCommands: []string{"node", "npx"},
+		AllowedArgs:     map[string][]string{"npx": {"-y", "@modelcontextprotocol/server-*"}},
+	})
+	stdio := func(command string, args []string, env map[string]string) *MCPServer {
+		return &MCPServer{Name: "fs", Transport: TransportStdio, Command: command, Args: args, Env: env}
+	}
+
+	for _, key := range []string{"LD_PRELOAD", "ld_library_path", "PATH", "NODE_OPTIONS", "DYLD_INSERT_LIBRARIES", "npm_config_registry"} {
+		if err := svc.validate(stdio("node", []string{"server.js"}, map[string]string{key: "x"})); !errors.Is(err, ErrStdioEnvNotAllowed) {
+			t.Fatalf("expected env %s to be rejected, got %v", key, err)
+		}
+	}
+	for _, args := range [][]string{{"-e", "require('child_process')"}, {"--eval=1"}, {"--require", "./x.js", "server.js"}} {
+		if err := svc.validate(stdio("node", args, nil)); !errors.Is(err, ErrStdioArgNotAllowed) {
+			t.Fatalf("expected args %v to be rejected, got %v", args, err)
+		}
+	}
+	if err := svc.validate(stdio("npx", []string{"-y", "evil-package"}, nil)); !errors.Is(err, ErrStdioArgNotAllowed) {
+		t.Fatalf("expected package outside the allowed pattern to be rejected, got %v", err)
+	}
+
+	if err := svc.validate(stdio("node", []string{"server.js", "--port", "0"}, map[string]string{"API_TOKEN": "x"})); err != nil {
+		t.Fatalf("expected plain node server to be allowed: %v", err)
+	}
+	if err := svc.validate(stdio("npx", []string{"-y", "@modelcontextprotocol/server-filesystem"}, nil)); err != nil {
+		t.Fatalf("expected allowed npx package: %v", err)
+	}
+}
+
+func TestServiceSyncRegistersToolsOnOtherReplicas(t *testing.T) {
+	logger.Init("error", "console", "stdout")
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	remote := newTestServer(t)
+	defer remote.Close()
+
+	opts := Options{AllowedHosts: []string{"127.0.0.1"}}
+	replicaA := NewService(db, tools.NewToolRegistry(), opts)
+	replicaB := NewService(db, tools.NewToolRegistry(), opts)
+	if err := replicaA.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	defer replicaA.Close()
+	defer replicaB.Close()
+
+	ctx := context.Background()
+	tenantID := "33333333-3333-3333-3333-333333333333"
+	server, err := replicaA.CreateServer(ctx, tenantID, "", &CreateServerRequest{Name: "remote", Transport: TransportHTTP, URL: remote.URL})
+	if err != nil {
+		t.Fatalf("CreateServer: %v", err)
+	}
+	echo := registeredToolName(tenantID, server.Namespace, "echo")
+	if _, ok := replicaA.registry.GetDefinition(echo); !ok {
+		t.Fatal("handling replica should register the remote tools")
+	}
+
+	// 另一实例同步后注册相同工具，配置未变时不重复连接
+	if err := replicaB.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, ok := replicaB.registry.GetDefinition(echo); !ok {
+		t.Fatal("other replica should register the remote tools after sync")
+	}
+	replicaB.mu.Lock()
+	first := replicaB.conns[server.ID]
+	replicaB.mu.Unlock()
+	if err := replicaB.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	replicaB.mu.Lock()
+	second := replicaB.conns[server.ID]
+	replicaB.mu.Unlock()
+	if first != second {
+		t.Fatal("unchanged server should keep its connection")
+	}
+
+	if err := replicaA.DeleteServer(ctx, tenantID, server.ID); err != nil {
+		t.Fatalf("DeleteServer: %v", err)
+	}
+	if err := replicaB.Sync(ctx); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, ok := replicaB.registry.GetDefinition(echo); ok {
+		t.Fatal("deleted server tools must be unregistered on other replicas")
+	}
+}
@@ -0,0 +1,51 @@
+package mcp
+
+import "time"
+
+// API Key 权限范围
+const (
+	ScopeMCP      = "mcp"       // 调用发布的 Agent、检索知识库、读取工作区
+	ScopeMCPWrite = "mcp:write" // 写入工作区文件
+)
+
+// 工作区访问级别
+const (
+	WorkspaceAccessNone  = "none"
+	WorkspaceAccessRead  = "read"
+	WorkspaceAccessWrite = "write"
+)
+
+// MCPExposure 租户通过 MCP 服务端对外发布的能力
+type MCPExposure struct {
+	ID       string `json:"id" gorm:"primaryKey;type:uuid"`
+	TenantID string `json:"tenantId" gorm:"type:uuid;not null;uniqueIndex"`
+
+	Enabled          bool     `json:"enabled" gorm:"not null"`
+	AgentIDs         []string `json:"agentIds" gorm:"type:jsonb;serializer:json"`         // 发布为工具的 Agent
+	KnowledgeBaseIDs []string `json:"knowledgeBaseIds" gorm:"type:jsonb;serializer:json"` // 允许检索的知识库
+	WorkspaceAccess  string   `json:"workspaceAccess" gorm:"size:20;not null"`            // none, read, write
+	Instructions     string   `json:"instructions" gorm:"type:text"`                      // initialize 时返回给客户端的说明
+
+	UpdatedBy string    `json:"updatedBy" gorm:"type:uuid"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (MCPExposure) TableName() string {
+	return "mcp_exposures"
+}
+
+// canReadWorkspace 是否允许读取工作区
+func (e *MCPExposure) canReadWorkspace() bool {
+	return e.WorkspaceAccess == WorkspaceAccessRead || e.WorkspaceAccess == WorkspaceAccessWrite
+}
+
+// UpdateExposureRequest 更新发布配置请求
+type UpdateExposureRequest struct {
+	Enabled          *bool    `json:"enabled"`
+	AgentIDs         []string `json:"agentIds"`
+	KnowledgeBaseIDs []string `json:"knowledgeBaseIds"`
+	WorkspaceAccess  *string  `json:"workspaceAccess"`
+	Instructions     *string  `json:"instructions"`
+}
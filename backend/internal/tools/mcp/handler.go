@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"errors"
+	"net/http"
+
+	response "backend/api/handlers/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler MCP 服务管理 HTTP 处理器
+type Handler struct {
+	svc *Service
+}
+
+// NewHandler 创建处理器
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// ListServers 列出 MCP 服务
+// @Summary 列出已配置的 MCP 服务
+// @Tags MCP
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.APIResponse{data=[]MCPServer}
+// @Router /api/mcp/servers [get]
+func (h *Handler) ListServers(c *gin.Context) {
+	servers, err := h.svc.ListServers(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: servers})
+}
+
+// CreateServer 添加 MCP 服务
+// @Summary 添加 MCP 服务并导入其工具
+// @Tags MCP
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateServerRequest true "服务配置"
+// @Success 201 {object} response.APIResponse{data=MCPServer}
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /api/mcp/servers [post]
+func (h *Handler) CreateServer(c *gin.Context) {
+	var req CreateServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "参数错误: " + err.Error()})
+		return
+	}
+
+	server, err := h.svc.CreateServer(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), &req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, response.APIResponse{Success: true, Data: server})
+}
+
+// GetServer 获取 MCP 服务详情
+// @Summary 获取 MCP 服务详情
+// @Tags MCP
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "服务ID"
+// @Success 200 {object} response.APIResponse{data=MCPServer}
+// @Failure 404 {object} response.ErrorResponse
+// @Router /api/mcp/servers/{id} [get]
+func (h *Handler) GetServer(c *gin.Context) {
+	server, err := h.svc.GetServer(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: server})
+}
+
+// UpdateServer 更新 MCP 服务
+// @Summary 更新 MCP 服务配置并重新连接
+// @Tags MCP
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "服务ID"
+// @Param request body UpdateServerRequest true "更新内容"
+// @Success 200 {object} response.APIResponse{data=MCPServer}
+// @Router /api/mcp/servers/{id} [put]
+func (h *Handler) UpdateServer(c *gin.Context) {
+	var req UpdateServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "参数错误: " + err.Error()})
+		return
+	}
+
+	server, err := h.svc.UpdateServer(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"), &req)
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: server})
+}
+
+// DeleteServer 删除 MCP 服务
+// @Summary 删除 MCP 服务并注销其工具
+// @Tags MCP
+// @Security BearerAuth
+// @Param id path string true "服务ID"
+// @Success 200 {object} response.APIResponse
+// @Router /api/mcp/servers/{id} [delete]
+func (h *Handler) DeleteServer(c *gin.Context) {
+	if err := h.svc.DeleteServer(c.Request.Context(), c.GetString("tenant_id"), c.Param("id")); err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Message: "删除成功"})
+}
+
+// RefreshServer 重新连接 MCP 服务
+// @Summary 重新连接并同步工具列表
+// @Tags MCP
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "服务ID"
+// @Success 200 {object} response.APIResponse{data=MCPServer}
+// @Router /api/mcp/servers/{id}/refresh [post]
+func (h *Handler) RefreshServer(c *gin.Context) {
+	server, err := h.svc.Refresh(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: server})
+}
+
+// ListServerTools 列出 MCP 服务导入的工具
+// @Summary 列出 MCP 服务导入的工具
+// @Tags MCP
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "服务ID"
+// @Success 200 {object} response.APIResponse
+// @Router /api/mcp/servers/{id}/tools [get]
+func (h *Handler) ListServerTools(c *gin.Context) {
+	defs, err := h.svc.ListServerTools(c.Request.Context(), c.GetString("tenant_id"), c.Param("id"))
+	if err != nil {
+		h.writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: gin.H{"tools": defs, "count": len(defs)}})
+}
+
+func (h *Handler) writeError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrServerNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrServerExists):
+		status = http.StatusConflict
+	case errors.Is(err, ErrStdioNotAllowed), errors.Is(err, ErrStdioEnvNotAllowed), errors.Is(err, ErrStdioArgNotAllowed):
+		status = http.StatusForbidden
+	}
+	c.JSON(status, response.ErrorResponse{Success: false, Message: err.Error()})
+}
+
+// ServerHandler MCP 服务端的发布配置与协议端点
+type ServerHandler struct {
+	server *Server
+}
+
+// NewServerHandler 创建处理器
+func NewServerHandler(server *Server) *ServerHandler {
+	return &ServerHandler{server: server}
+}
+
+// GetExposure 获取发布配置
+// @Summary 获取通过 MCP 对外发布的 Agent、知识库与工作区权限
+// @Tags MCP
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} response.APIResponse{data=MCPExposure}
+// @Router /api/mcp/exposure [get]
+func (h *ServerHandler) GetExposure(c *gin.Context) {
+	exposure, err := h.server.GetExposure(c.Request.Context(), c.GetString("tenant_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: exposure})
+}
+
+// UpdateExposure 更新发布配置
+// @Summary 更新通过 MCP 对外发布的 Agent、知识库与工作区权限
+// @Tags MCP
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body UpdateExposureRequest true "发布配置"
+// @Success 200 {object} response.APIResponse{data=MCPExposure}
+// @Failure 400 {object} response.ErrorResponse
+// @Router /api/mcp/exposure [put]
+func (h *ServerHandler) UpdateExposure(c *gin.Context) {
+	var req UpdateExposureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ErrorResponse{Success: false, Message: "参数错误: " + err.Error()})
+		return
+	}
+
+	exposure, err := h.server.UpdateExposure(c.Request.Context(), c.GetString("tenant_id"), c.GetString("user_id"), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrInvalidWorkspaceAccess) || errors.Is(err, ErrAgentNotInTenant) || errors.Is(err, ErrKnowledgeBaseNotFound) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, response.ErrorResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response.APIResponse{Success: true, Data: exposure})
+}
+
+// Serve MCP Streamable HTTP 端点，使用 API Key 认证
+// @Summary MCP 协议端点（JSON-RPC）
+// @Tags MCP
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer {API Key}"
+// @Router /mcp [post]
+func (h *ServerHandler) Serve(c *gin.Context) {
+	h.server.ServeHTTP(c.Writer, c.Request)
+}
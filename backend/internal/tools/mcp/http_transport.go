@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// HTTPTransport Streamable HTTP 传输
+// 每个请求 POST 到同一个端点，服务端以 application/json 或 text/event-stream 返回响应；
+// initialize 响应中的 Mcp-Session-Id 会在之后的请求中携带
+type HTTPTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	nextID          atomic.Int64
+	mu              sync.RWMutex
+	sessionID       string
+	protocolVersion string
+}
+
+// NewHTTPTransport 创建 Streamable HTTP 传输
+func NewHTTPTransport(url string, headers map[string]string, client *http.Client) *HTTPTransport {
+	if client == nil {
+		client = &http.Client{}
+	}
+	return &HTTPTransport{
+		url:     url,
+		headers: headers,
+		client:  client,
+	}
+}
+
+// Start HTTP 传输无需预先建立连接
+func (t *HTTPTransport) Start(ctx context.Context) error {
+	return nil
+}
+
+// setProtocolVersion 记录协商后的协议版本，之后的请求通过 MCP-Protocol-Version 头携带
+func (t *HTTPTransport) setProtocolVersion(version string) {
+	t.mu.Lock()
+	t.protocolVersion = version
+	t.mu.Unlock()
+}
+
+// Call 发送请求并等待响应
+func (t *HTTPTransport) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	msg, err := newRequest(t.nextID.Add(1), method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+
+	var reply *Message
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		reply, err = readSSEResponse(resp.Body, idKey(msg.ID))
+	} else {
+		reply, err = readJSONResponse(resp.Body, idKey(msg.ID))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 响应失败: %w", method, err)
+	}
+	return responseResult(reply)
+}
+
+// Notify 发送通知，服务端返回 202 Accepted
+func (t *HTTPTransport) Notify(ctx context.Context, method string, params any) error {
+	msg, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := t.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return nil
+}
+
+// Close 结束会话（尽力而为）
+func (t *HTTPTransport) Close() error {
+	t.mu.RLock()
+	sessionID := t.sessionID
+	t.mu.RUnlock()
+	if sessionID == "" {
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return nil
+	}
+	t.applyHeaders(req)
+	if resp, err := t.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+	return nil
+}
+
+func (t *HTTPTransport) post(ctx context.Context, msg *Message) (*http.Response, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.applyHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("MCP 请求失败: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound && req.Header.Get("Mcp-Session-Id") != "" {
+			return nil, fmt.Errorf("%w: 会话已失效", ErrTransportClosed)
+		}
+		return nil, fmt.Errorf("MCP 服务返回错误 (%d): %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp, nil
+}
+
+func (t *HTTPTransport) applyHeaders(req *http.Request) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+}
+
+// readJSONResponse 读取 application/json 响应（单条或批量）
+func readJSONResponse(body io.Reader, id string) (*Message, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []Message
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, err
+		}
+		for i := range batch {
+			if batch[i].IsResponse() && idKey(batch[i].ID) == id {
+				return &batch[i], nil
+			}
+		}
+		return nil, fmt.Errorf("批量响应中没有请求 %s 的结果", id)
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// readSSEResponse 读取 SSE 流，直到收到与请求 ID 匹配的响应
+// 流中的通知（如进度）与服务端请求被忽略
+func readSSEResponse(body io.Reader, id string) (*Message, error) {
+	reader := bufio.NewReaderSize(body, 64*1024)
+	var data strings.Builder
+
+	flush := func() *Message {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return nil
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(data.String()), &msg); err != nil {
+			return nil
+		}
+		if msg.IsResponse() && idKey(msg.ID) == id {
+			return &msg
+		}
+		return nil
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if msg := flush(); msg != nil {
+				return msg, nil
+			}
+		} else if strings.HasPrefix(line, "data:") {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		if err != nil {
+			if msg := flush(); msg != nil {
+				return msg, nil
+			}
+			if err == io.EOF {
+				return nil, fmt.Errorf("事件流在收到响应前结束")
+			}
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mcp
+
+import "time"
+
+// 传输类型
+const (
+	TransportStdio = "stdio" // 本地子进程
+	TransportHTTP  = "http"  // Streamable HTTP
+)
+
+// 连接状态
+const (
+	ServerStatusConnected    = "connected"
+	ServerStatusError        = "error"
+	ServerStatusDisconnected = "disconnected"
+)
+
+// MCPServer 租户配置的外部 MCP 服务
+type MCPServer struct {
+	ID       string `json:"id" gorm:"primaryKey;type:uuid"`
+	TenantID string `json:"tenantId" gorm:"type:uuid;not null;uniqueIndex:idx_mcp_server_tenant_name"`
+
+	// 基本信息
+	Name        string `json:"name" gorm:"size:100;not null;uniqueIndex:idx_mcp_server_tenant_name"`
+	Namespace   string `json:"namespace" gorm:"size:32;not null"` // 工具名前缀，注册名为 t{租户摘要}_{namespace}__{tool}
+	Description string `json:"description" gorm:"type:text"`
+
+	// 连接配置
+	Transport string            `json:"transport" gorm:"size:20;not null"` // stdio, http
+	Command   string            `json:"command,omitempty" gorm:"size:255"`
+	Args      []string          `json:"args,omitempty" gorm:"type:jsonb;serializer:json"`
+	Env       map[string]string `json:"-" gorm:"type:jsonb;serializer:json"` // 可能包含密钥，不对外返回
+	URL       string            `json:"url,omitempty" gorm:"size:500"`
+	Headers   map[string]string `json:"-" gorm:"type:jsonb;serializer:json"` // 可能包含 Token，不对外返回
+	Timeout   int               `json:"timeout" gorm:"default:30"`           // 工具调用超时（秒）
+
+	// 状态
+	Enabled         bool       `json:"enabled" gorm:"not null"`
+	Status          string     `json:"status" gorm:"size:20;default:disconnected"`
+	LastError       string     `json:"lastError,omitempty" gorm:"type:text"`
+	ToolCount       int        `json:"toolCount" gorm:"default:0"`
+	ServerName      string     `json:"serverName,omitempty" gorm:"size:255"` // initialize 返回的服务端名称
+	ServerVersion   string     `json:"serverVersion,omitempty" gorm:"size:50"`
+	LastConnectedAt *time.Time `json:"lastConnectedAt,omitempty"`
+
+	CreatedBy string    `json:"createdBy" gorm:"type:uuid"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"not null;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (MCPServer) TableName() string {
+	return "mcp_servers"
+}
+
+// CreateServerRequest 创建 MCP 服务请求
+type CreateServerRequest struct {
+	Name        string            `json:"name" binding:"required"`
+	Namespace   string            `json:"namespace"` // 为空时由名称生成
+	Description string            `json:"description"`
+	Transport   string            `json:"transport" binding:"required"`
+	Command     string            `json:"command"`
+	Args        []string          `json:"args"`
+	Env         map[string]string `json:"env"`
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Timeout     int               `json:"timeout"`
+	Enabled     *bool             `json:"enabled"`
+}
+
+// UpdateServerRequest 更新 MCP 服务请求
+type UpdateServerRequest struct {
+	Description *string           `json:"description"`
+	Command     *string           `json:"command"`
+	Args        []string          `json:"args"`
+	Env         map[string]string `json:"env"`
+	URL         *string           `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Timeout     *int              `json:"timeout"`
+	Enabled     *bool             `json:"enabled"`
+}
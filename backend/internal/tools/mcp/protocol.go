@@ -0,0 +1,272 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Model Context Protocol 协议类型 (JSON-RPC 2.0)
+// ============================================================================
+
+// ProtocolVersion 发起连接时使用的协议版本
+const ProtocolVersion = "2025-03-26"
+
+// supportedProtocolVersions 可接受的服务端协议版本
+var supportedProtocolVersions = map[string]bool{
+	"2025-06-18": true,
+	"2025-03-26": true,
+	"2024-11-05": true,
+}
+
+const jsonrpcVersion = "2.0"
+
+// JSON-RPC 错误码
+const (
+	ErrCodeParse          = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternal       = -32603
+)
+
+// Message JSON-RPC 消息（请求、通知与响应共用）
+// Method 非空为请求或通知（ID 为空时是通知）；Method 为空且 ID 非空为响应
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// IsResponse 是否为响应消息
+func (m *Message) IsResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// IsNotification 是否为通知
+func (m *Message) IsNotification() bool {
+	return m.Method != "" && len(m.ID) == 0
+}
+
+// idKey 将 ID 规范化为字符串，便于匹配请求与响应
+func idKey(id json.RawMessage) string {
+	return string(bytes.TrimSpace(id))
+}
+
+// RPCError JSON-RPC 错误
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP 错误 %d: %s", e.Code, e.Message)
+}
+
+// newRequest 构造请求消息
+func newRequest(id int64, method string, params any) (*Message, error) {
+	msg := &Message{
+		JSONRPC: jsonrpcVersion,
+		ID:      json.RawMessage(fmt.Sprintf("%d", id)),
+		Method:  method,
+	}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("序列化 %s 参数失败: %w", method, err)
+		}
+		msg.Params = raw
+	}
+	return msg, nil
+}
+
+// newNotification 构造通知消息
+func newNotification(method string, params any) (*Message, error) {
+	msg, err := newRequest(0, method, params)
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = nil
+	return msg, nil
+}
+
+// NewResultResponse 构造成功响应
+func NewResultResponse(id json.RawMessage, result any) *Message {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return NewErrorResponse(id, ErrCodeInternal, err.Error())
+	}
+	return &Message{JSONRPC: jsonrpcVersion, ID: id, Result: raw}
+}
+
+// NewErrorResponse 构造错误响应
+func NewErrorResponse(id json.RawMessage, code int, message string) *Message {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Message{JSONRPC: jsonrpcVersion, ID: id, Error: &RPCError{Code: code, Message: message}}
+}
+
+// responseResult 提取响应结果
+func responseResult(msg *Message) (json.RawMessage, error) {
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+// --- 生命周期 ---
+
+// Implementation 客户端 / 服务端信息
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeParams initialize 请求参数
+type InitializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+// InitializeResult initialize 响应
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// ServerCapabilities 服务端能力
+type ServerCapabilities struct {
+	Tools     *ListChangedCapability `json:"tools,omitempty"`
+	Resources *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts   *ListChangedCapability `json:"prompts,omitempty"`
+}
+
+// ListChangedCapability 支持列表变更通知的能力
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// ResourcesCapability 资源能力
+type ResourcesCapability struct {
+	Subscribe   bool `json:"subscribe,omitempty"`
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+// --- 工具 ---
+
+// Tool 工具描述
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations map[string]any `json:"annotations,omitempty"`
+}
+
+// ListToolsParams tools/list 请求参数
+type ListToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// ListToolsResult tools/list 响应
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CallToolParams tools/call 请求参数
+type CallToolParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// CallToolResult tools/call 响应
+type CallToolResult struct {
+	Content           []Content      `json:"content"`
+	StructuredContent map[string]any `json:"structuredContent,omitempty"`
+	IsError           bool           `json:"isError,omitempty"`
+}
+
+// Text 拼接结果中的文本内容
+func (r *CallToolResult) Text() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch {
+		case c.Type == "text" && c.Text != "":
+			parts = append(parts, c.Text)
+		case c.Type == "resource" && c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, c.Resource.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Content 工具结果内容块
+type Content struct {
+	Type     string            `json:"type"` // text, image, audio, resource
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"` // base64
+	MimeType string            `json:"mimeType,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// TextContent 构造文本内容块
+func TextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// ResourceContents 资源内容
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // base64
+}
+
+// --- 资源 ---
+
+// Resource 资源描述
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate 资源 URI 模板
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ListResourcesResult resources/list 响应
+type ListResourcesResult struct {
+	Resources  []Resource `json:"resources"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// ListResourceTemplatesResult resources/templates/list 响应
+type ListResourceTemplatesResult struct {
+	ResourceTemplates []ResourceTemplate `json:"resourceTemplates"`
+}
+
+// ReadResourceParams resources/read 请求参数
+type ReadResourceParams struct {
+	URI string `json:"uri"`
+}
+
+// ReadResourceResult resources/read 响应
+type ReadResourceResult struct {
+	Contents []ResourceContents `json:"contents"`
+}
@@ -0,0 +1,472 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/internal/agent"
+	"backend/internal/auth"
+	"backend/internal/logger"
+	"backend/internal/rag"
+	"backend/internal/tenant"
+	"backend/internal/workspace"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidWorkspaceAccess = errors.New("工作区访问级别仅支持 none、read、write")
+	ErrAgentNotInTenant       = errors.New("Agent 不存在或不属于当前租户")
+	ErrKnowledgeBaseNotFound  = errors.New("知识库不存在或不属于当前租户")
+)
+
+// serverInfo initialize 时返回的服务端信息
+var serverInfo = Implementation{Name: "AgentFlowCreativeHub", Version: "1.0.0"}
+
+// defaultSessionTTL 会话空闲过期时间
+const defaultSessionTTL = 30 * time.Minute
+
+// APIKeyValidator 校验 API Key（由 auth.APIKeyService 实现）
+type APIKeyValidator interface {
+	ValidateAPIKey(ctx context.Context, rawKey string) (*auth.APIKey, error)
+}
+
+// AgentRunner 执行已发布的 Agent（由 runtime.Registry 适配）
+type AgentRunner interface {
+	RunAgent(ctx context.Context, tenantID, userID, agentID, content string, variables map[string]any) (string, error)
+}
+
+// KnowledgeSearcher 知识库检索（由 rag.RAGService 实现）
+type KnowledgeSearcher interface {
+	Search(ctx context.Context, req *rag.SearchRequest) (*rag.SearchResponse, error)
+}
+
+// ServerOptions MCP 服务端依赖，未提供的能力不会发布
+type ServerOptions struct {
+	APIKeys   APIKeyValidator
+	Agents    AgentRunner
+	Knowledge KnowledgeSearcher
+	Workspace *workspace.Service
+	// SessionTTL 会话空闲过期时间，默认 30 分钟
+	SessionTTL time.Duration
+	// Redis 非空时会话保存在 Redis 中，多实例部署无需会话粘滞；
+	// 为空时会话只保存在本进程，多实例部署需要负载均衡按 Mcp-Session-Id 做会话粘滞
+	Redis redis.UniversalClient
+}
+
+// Server 以 Streamable HTTP 对外提供 MCP 服务
+// 通过 API Key 认证，按租户的发布配置暴露 Agent、知识库检索与工作区文件
+type Server struct {
+	db       *gorm.DB
+	opts     ServerOptions
+	sessions sessionStore
+}
+
+// serverSession 已完成 initialize 的客户端会话
+type serverSession struct {
+	id              string
+	keyID           string
+	tenantID        string
+	userID          string
+	scopes          map[string]bool
+	protocolVersion string
+	lastSeen        time.Time
+}
+
+// NewServer 创建 MCP 服务端
+func NewServer(db *gorm.DB, opts ServerOptions) *Server {
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = defaultSessionTTL
+	}
+	var sessions sessionStore = newMemorySessionStore()
+	if opts.Redis != nil {
+		sessions = newRedisSessionStore(opts.Redis)
+	}
+	return &Server{
+		db:       db,
+		opts:     opts,
+		sessions: sessions,
+	}
+}
+
+// AutoMigrate 自动迁移表结构
+func (s *Server) AutoMigrate() error {
+	return s.db.AutoMigrate(&MCPExposure{})
+}
+
+// --- 发布配置 ---
+
+// GetExposure 获取租户的发布配置，未配置时返回默认（未启用）配置
+func (s *Server) GetExposure(ctx context.Context, tenantID string) (*MCPExposure, error) {
+	var exposure MCPExposure
+	err := s.db.WithContext(ctx).Where("tenant_id = ?", tenantID).First(&exposure).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &MCPExposure{TenantID: tenantID, WorkspaceAccess: WorkspaceAccessNone}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &exposure, nil
+}
+
+// UpdateExposure 更新租户的发布配置
+func (s *Server) UpdateExposure(ctx context.Context, tenantID, userID string, req *UpdateExposureRequest) (*MCPExposure, error) {
+	exposure, err := s.GetExposure(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		exposure.Enabled = *req.Enabled
+	}
+	if req.AgentIDs != nil {
+		ids := uniqueStrings(req.AgentIDs)
+		var count int64
+		if len(ids) > 0 {
+			if err := s.db.WithContext(ctx).Model(&agent.AgentConfig{}).
+				Where("tenant_id = ? AND id IN ? AND deleted_at IS NULL", tenantID, ids).
+				Count(&count).Error; err != nil {
+				return nil, err
+			}
+		}
+		if int(count) != len(ids) {
+			return nil, ErrAgentNotInTenant
+		}
+		exposure.AgentIDs = ids
+	}
+	if req.KnowledgeBaseIDs != nil {
+		ids := uniqueStrings(req.KnowledgeBaseIDs)
+		var count int64
+		if len(ids) > 0 {
+			if err := s.db.WithContext(ctx).Model(&rag.KnowledgeBase{}).
+				Where("tenant_id = ? AND id IN ? AND deleted_at IS NULL", tenantID, ids).
+				Count(&count).Error; err != nil {
+				return nil, err
+			}
+		}
+		if int(count) != len(ids) {
+			return nil, ErrKnowledgeBaseNotFound
+		}
+		exposure.KnowledgeBaseIDs = ids
+	}
+	if req.WorkspaceAccess != nil {
+		access := strings.ToLower(strings.TrimSpace(*req.WorkspaceAccess))
+		switch access {
+		case WorkspaceAccessNone, WorkspaceAccessRead, WorkspaceAccessWrite:
+			exposure.WorkspaceAccess = access
+		default:
+			return nil, ErrInvalidWorkspaceAccess
+		}
+	}
+	if req.Instructions != nil {
+		exposure.Instructions = *req.Instructions
+	}
+	exposure.UpdatedBy = userID
+
+	if exposure.ID == "" {
+		exposure.ID = uuid.New().String()
+		err = s.db.WithContext(ctx).Create(exposure).Error
+	} else {
+		err = s.db.WithContext(ctx).Save(exposure).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exposure, nil
+}
+
+// --- Streamable HTTP ---
+
+// ServeHTTP 处理 MCP 端点请求
+// POST 承载 JSON-RPC 消息（支持批量），DELETE 结束会话；不提供服务端主动推送的 GET 流
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost, http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, status, err := s.authenticate(r)
+	if err != nil {
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+		}
+		writeJSONRPC(w, status, NewErrorResponse(nil, ErrCodeInvalidRequest, err.Error()))
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		sess, err := s.getSession(r.Context(), r.Header.Get("Mcp-Session-Id"), key.ID)
+		if err == nil && sess != nil {
+			err = s.sessions.Delete(r.Context(), sess.id)
+		}
+		if err != nil {
+			logger.Error("MCP 服务端删除会话失败", zap.Error(err))
+			writeJSONRPC(w, http.StatusInternalServerError, NewErrorResponse(nil, ErrCodeInternal, "会话存储不可用"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize+1))
+	if err != nil || len(body) > maxMessageSize {
+		writeJSONRPC(w, http.StatusBadRequest, NewErrorResponse(nil, ErrCodeParse, "请求体读取失败或过大"))
+		return
+	}
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+
+	var msgs []*Message
+	if batch {
+		err = json.Unmarshal(body, &msgs)
+	} else {
+		var msg Message
+		err = json.Unmarshal(body, &msg)
+		msgs = []*Message{&msg}
+	}
+	if err != nil || len(msgs) == 0 {
+		writeJSONRPC(w, http.StatusBadRequest, NewErrorResponse(nil, ErrCodeParse, "无效的 JSON-RPC 消息"))
+		return
+	}
+
+	// initialize 必须单独发送，其余请求需携带会话 ID
+	var sess *serverSession
+	initialize := !batch && msgs[0].Method == "initialize"
+	if initialize {
+		sess = newServerSession(key)
+	} else {
+		sessionID := r.Header.Get("Mcp-Session-Id")
+		if sessionID == "" {
+			writeJSONRPC(w, http.StatusBadRequest, NewErrorResponse(nil, ErrCodeInvalidRequest, "缺少 Mcp-Session-Id"))
+			return
+		}
+		if sess, err = s.getSession(r.Context(), sessionID, key.ID); err != nil {
+			logger.Error("MCP 服务端读取会话失败", zap.Error(err))
+			writeJSONRPC(w, http.StatusInternalServerError, NewErrorResponse(nil, ErrCodeInternal, "会话存储不可用"))
+			return
+		}
+		if sess == nil {
+			writeJSONRPC(w, http.StatusNotFound, NewErrorResponse(nil, ErrCodeInvalidRequest, "会话不存在或已过期"))
+			return
+		}
+	}
+
+	ctx := tenant.WithTenantContext(r.Context(), tenant.TenantContext{TenantID: sess.tenantID, UserID: sess.userID})
+	var responses []*Message
+	for _, msg := range msgs {
+		if msg.Method == "" {
+			// 客户端对服务端请求的响应，本服务端不发起请求，直接忽略
+			continue
+		}
+		resp := s.dispatch(ctx, sess, msg)
+		if resp != nil && !msg.IsNotification() {
+			responses = append(responses, resp)
+		}
+	}
+
+	// initialize 成功后才保存会话（含协商的协议版本）
+	if initialize && len(responses) == 1 && responses[0].Error == nil {
+		if err := s.sessions.Save(r.Context(), sess, s.opts.SessionTTL); err != nil {
+			logger.Error("MCP 服务端保存会话失败", zap.Error(err))
+			writeJSONRPC(w, http.StatusInternalServerError, NewErrorResponse(msgs[0].ID, ErrCodeInternal, "会话存储不可用"))
+			return
+		}
+	}
+
+	w.Header().Set("Mcp-Session-Id", sess.id)
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if batch {
+		writeJSONRPC(w, http.StatusOK, responses)
+		return
+	}
+	writeJSONRPC(w, http.StatusOK, responses[0])
+}
+
+// authenticate 校验 API Key、IP 白名单与 mcp 权限范围，返回失败时应使用的 HTTP 状态码
+func (s *Server) authenticate(r *http.Request) (*auth.APIKey, int, error) {
+	rawKey := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if rawKey == "" {
+		if authz := r.Header.Get("Authorization"); len(authz) > 7 && strings.EqualFold(authz[:7], "Bearer ") {
+			rawKey = strings.TrimSpace(authz[7:])
+		}
+	}
+	if rawKey == "" || s.opts.APIKeys == nil {
+		return nil, http.StatusUnauthorized, auth.ErrAPIKeyInvalid
+	}
+
+	key, err := s.opts.APIKeys.ValidateAPIKey(r.Context(), rawKey)
+	if err != nil {
+		if errors.Is(err, auth.ErrAPIKeyNotFound) || errors.Is(err, auth.ErrAPIKeyExpired) || errors.Is(err, auth.ErrAPIKeyRevoked) {
+			return nil, http.StatusUnauthorized, err
+		}
+		logger.Error("MCP 服务端校验 API Key 失败", zap.Error(err))
+		return nil, http.StatusInternalServerError, errors.New("API Key 校验失败")
+	}
+	if !ipAllowed(key.AllowedIPs, r.RemoteAddr) {
+		return nil, http.StatusForbidden, errors.New("当前 IP 不在 API Key 白名单中")
+	}
+	if !parseScopes(key.Scopes)[ScopeMCP] {
+		return nil, http.StatusForbidden, fmt.Errorf("API Key 缺少 %s 权限", ScopeMCP)
+	}
+	return key, 0, nil
+}
+
+// newServerSession 为 initialize 请求创建会话
+func newServerSession(key *auth.APIKey) *serverSession {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return &serverSession{
+		id:       hex.EncodeToString(buf),
+		keyID:    key.ID,
+		tenantID: key.TenantID,
+		userID:   key.UserID,
+		scopes:   parseScopes(key.Scopes),
+		lastSeen: time.Now(),
+	}
+}
+
+// getSession 查找会话并刷新过期时间；会话必须属于同一个 API Key
+func (s *Server) getSession(ctx context.Context, id, keyID string) (*serverSession, error) {
+	if id == "" {
+		return nil, nil
+	}
+	sess, err := s.sessions.Load(ctx, id, s.opts.SessionTTL)
+	if err != nil || sess == nil || sess.keyID != keyID {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// dispatch 处理单条请求或通知
+func (s *Server) dispatch(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	switch msg.Method {
+	case "initialize":
+		return s.handleInitialize(ctx, sess, msg)
+	case "ping":
+		return NewResultResponse(msg.ID, struct{}{})
+	case "notifications/initialized", "notifications/cancelled":
+		return nil
+	case "tools/list":
+		return s.handleListTools(ctx, sess, msg)
+	case "tools/call":
+		return s.handleCallTool(ctx, sess, msg)
+	case "resources/list":
+		return s.handleListResources(ctx, sess, msg)
+	case "resources/templates/list":
+		return s.handleListResourceTemplates(ctx, sess, msg)
+	case "resources/read":
+		return s.handleReadResource(ctx, sess, msg)
+	}
+	return NewErrorResponse(msg.ID, ErrCodeMethodNotFound, "不支持的方法: "+msg.Method)
+}
+
+func (s *Server) handleInitialize(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	var params InitializeParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "initialize 参数无效")
+	}
+	// 客户端请求的版本受支持时沿用，否则返回服务端版本由客户端决定是否断开
+	sess.protocolVersion = ProtocolVersion
+	if supportedProtocolVersions[params.ProtocolVersion] {
+		sess.protocolVersion = params.ProtocolVersion
+	}
+
+	exposure, err := s.GetExposure(ctx, sess.tenantID)
+	if err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "读取发布配置失败")
+	}
+	logger.Info("MCP 客户端已连接",
+		zap.String("tenant_id", sess.tenantID),
+		zap.String("client", params.ClientInfo.Name),
+		zap.String("protocol_version", sess.protocolVersion))
+
+	return NewResultResponse(msg.ID, InitializeResult{
+		ProtocolVersion: sess.protocolVersion,
+		Capabilities: ServerCapabilities{
+			Tools:     &ListChangedCapability{},
+			Resources: &ResourcesCapability{},
+		},
+		ServerInfo:   serverInfo,
+		Instructions: exposure.Instructions,
+	})
+}
+
+// writeJSONRPC 以 JSON 写出响应
+func writeJSONRPC(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// parseScopes 解析逗号分隔的权限范围
+func parseScopes(scopes string) map[string]bool {
+	result := make(map[string]bool)
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			result[scope] = true
+		}
+	}
+	return result
+}
+
+// ipAllowed 检查请求来源是否在白名单中，白名单为空时不限制
+func ipAllowed(allowed, remoteAddr string) bool {
+	if strings.TrimSpace(allowed) == "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	for _, entry := range strings.Split(allowed, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, cidr, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if entry == host {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueStrings 去除空值与重复值，保持原有顺序
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
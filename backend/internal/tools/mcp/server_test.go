@@ -0,0 +1,255 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/internal/agent"
+	"backend/internal/auth"
+	"backend/internal/logger"
+	"backend/internal/rag"
+	"backend/internal/workspace"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	testTenant = "11111111-1111-1111-1111-111111111111"
+	testAgent  = "22222222-2222-2222-2222-222222222222"
+	testKB     = "33333333-3333-3333-3333-333333333333"
+)
+
+type stubKeys map[string]*auth.APIKey
+
+func (s stubKeys) ValidateAPIKey(_ context.Context, raw string) (*auth.APIKey, error) {
+	if key, ok := s[raw]; ok {
+		return key, nil
+	}
+	return nil, auth.ErrAPIKeyNotFound
+}
+
+type stubAgents struct{}
+
+func (stubAgents) RunAgent(_ context.Context, tenantID, _, agentID, content string, _ map[string]any) (string, error) {
+	return "审校意见：" + content, nil
+}
+
+type stubKnowledge struct{}
+
+func (stubKnowledge) Search(_ context.Context, req *rag.SearchRequest) (*rag.SearchResponse, error) {
+	return &rag.SearchResponse{Results: []*rag.SearchResult{
+		{KnowledgeBaseID: req.KnowledgeBaseID, ChunkID: "c1", Content: "青云山位于东洲北境", Similarity: 0.9},
+	}}, nil
+}
+
+func setupMCPServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	_ = logger.Init("error", "console", "stdout")
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&MCPExposure{}, &agent.AgentConfig{}, &rag.KnowledgeBase{},
+		&workspace.WorkspaceNode{}, &workspace.WorkspaceFile{}, &workspace.WorkspaceFileVersion{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	db.Create(&agent.AgentConfig{ID: testAgent, TenantID: testTenant, AgentType: "reviewer", Name: "House Style", Status: "active"})
+	db.Create(&rag.KnowledgeBase{ID: testKB, TenantID: testTenant, Name: "世界观设定", Status: "active"})
+
+	keys := stubKeys{
+		"read-key":  {ID: "k1", TenantID: testTenant, Scopes: ScopeMCP},
+		"write-key": {ID: "k2", TenantID: testTenant, Scopes: ScopeMCP + "," + ScopeMCPWrite},
+		"none-key":  {ID: "k3", TenantID: testTenant, Scopes: "read"},
+	}
+	server := NewServer(db, ServerOptions{
+		APIKeys:   keys,
+		Agents:    stubAgents{},
+		Knowledge: stubKnowledge{},
+		Workspace: workspace.NewService(db),
+	})
+	enabled, access := true, WorkspaceAccessWrite
+	if _, err := server.UpdateExposure(context.Background(), testTenant, "", &UpdateExposureRequest{
+		Enabled:          &enabled,
+		AgentIDs:         []string{testAgent},
+		KnowledgeBaseIDs: []string{testKB},
+		WorkspaceAccess:  &access,
+	}); err != nil {
+		t.Fatalf("UpdateExposure: %v", err)
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+func connectTestClient(t *testing.T, url, key string) *Client {
+	t.Helper()
+	client := NewClient(NewHTTPTransport(url, map[string]string{"Authorization": "Bearer " + key}, nil))
+	if err := client.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func toolNames(list []Tool) string {
+	names := make([]string, 0, len(list))
+	for _, tool := range list {
+		names = append(names, tool.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestServerPublishesExposedCapabilities(t *testing.T) {
+	_, httpServer := setupMCPServer(t)
+	ctx := context.Background()
+
+	reader := connectTestClient(t, httpServer.URL, "read-key")
+	list, err := reader.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+	if got := toolNames(list); got != "agent_house_style,search_knowledge,list_workspace_files,read_workspace_file" {
+		t.Fatalf("unexpected tools for read key: %s", got)
+	}
+
+	result, err := reader.CallTool(ctx, "agent_house_style", map[string]any{"content": "第一章"})
+	if err != nil || result.Text() != "审校意见：第一章" {
+		t.Fatalf("agent call: %v %+v", err, result)
+	}
+	result, err = reader.CallTool(ctx, "search_knowledge", map[string]any{"query": "青云山"})
+	if err != nil || !strings.Contains(result.Text(), "世界观设定") {
+		t.Fatalf("knowledge call: %v %+v", err, result)
+	}
+	if _, err := reader.CallTool(ctx, "write_workspace_file", map[string]any{"content": "x"}); err == nil {
+		t.Fatal("expected write tool to be hidden without mcp:write scope")
+	}
+
+	writer := connectTestClient(t, httpServer.URL, "write-key")
+	if list, _ = writer.ListTools(ctx); !strings.Contains(toolNames(list), toolWriteFile) {
+		t.Fatalf("expected write tool for write key: %s", toolNames(list))
+	}
+	result, err = writer.CallTool(ctx, toolWriteFile, map[string]any{"name": "chapter1", "content": "风起青萍之末"})
+	if err != nil || result.IsError {
+		t.Fatalf("write call: %v %+v", err, result)
+	}
+	nodeID, _ := result.StructuredContent["node_id"].(string)
+
+	raw, err := writer.transport.Call(ctx, "resources/read", ReadResourceParams{URI: workspaceURIPrefix + nodeID})
+	if err != nil {
+		t.Fatalf("resources/read: %v", err)
+	}
+	var read ReadResourceResult
+	if err := json.Unmarshal(raw, &read); err != nil || len(read.Contents) != 1 || read.Contents[0].Text != "风起青萍之末" {
+		t.Fatalf("unexpected resource: %s", raw)
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	_, httpServer := setupMCPServer(t)
+
+	post := func(key, session, body string) int {
+		req, _ := http.NewRequest(http.MethodPost, httpServer.URL, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		if session != "" {
+			req.Header.Set("Mcp-Session-Id", session)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("post: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	ping := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	if status := post("", "", ping); status != http.StatusUnauthorized {
+		t.Fatalf("missing key: status %d", status)
+	}
+	if status := post("bogus", "", ping); status != http.StatusUnauthorized {
+		t.Fatalf("unknown key: status %d", status)
+	}
+	if status := post("none-key", "", ping); status != http.StatusForbidden {
+		t.Fatalf("key without mcp scope: status %d", status)
+	}
+	if status := post("read-key", "", ping); status != http.StatusBadRequest {
+		t.Fatalf("missing session: status %d", status)
+	}
+	if status := post("read-key", "unknown", ping); status != http.StatusNotFound {
+		t.Fatalf("unknown session: status %d", status)
+	}
+}
+
+func TestServerSessionLifecycle(t *testing.T) {
+	server, httpServer := setupMCPServer(t)
+
+	send := func(method, key, session, body string) *http.Response {
+		req, _ := http.NewRequest(method, httpServer.URL, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		if session != "" {
+			req.Header.Set("Mcp-Session-Id", session)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := send(http.MethodPost, "read-key", "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"test"}}}`)
+	session := resp.Header.Get("Mcp-Session-Id")
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("initialize: status %d session %q", resp.StatusCode, session)
+	}
+
+	ping := `{"jsonrpc":"2.0","id":2,"method":"ping"}`
+	if status := send(http.MethodPost, "read-key", session, ping).StatusCode; status != http.StatusOK {
+		t.Fatalf("ping: status %d", status)
+	}
+	if status := send(http.MethodPost, "write-key", session, ping).StatusCode; status != http.StatusNotFound {
+		t.Fatalf("session of another key: status %d", status)
+	}
+	if status := send(http.MethodGet, "read-key", session, "").StatusCode; status != http.StatusMethodNotAllowed {
+		t.Fatalf("GET stream is not offered: status %d", status)
+	}
+	if status := send(http.MethodDelete, "read-key", session, "").StatusCode; status != http.StatusNoContent {
+		t.Fatalf("delete: status %d", status)
+	}
+	if status := send(http.MethodPost, "read-key", session, ping).StatusCode; status != http.StatusNotFound {
+		t.Fatalf("deleted session: status %d", status)
+	}
+
+	// 空闲超过 SessionTTL 的会话过期
+	server.opts.SessionTTL = 50 * time.Millisecond
+	session = send(http.MethodPost, "read-key", "", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`).Header.Get("Mcp-Session-Id")
+	time.Sleep(100 * time.Millisecond)
+	if status := send(http.MethodPost, "read-key", session, ping).StatusCode; status != http.StatusNotFound {
+		t.Fatalf("expired session: status %d", status)
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	cases := []struct {
+		allowed, addr string
+		want          bool
+	}{
+		{"", "10.0.0.1:1234", true},
+		{"10.0.0.1", "10.0.0.1:1234", true},
+		{"10.0.0.0/24, 192.168.1.1", "10.0.0.9:80", true},
+		{"10.0.0.0/24", "10.0.1.9:80", false},
+	}
+	for _, c := range cases {
+		if got := ipAllowed(c.allowed, c.addr); got != c.want {
+			t.Errorf("ipAllowed(%q, %q) = %v, want %v", c.allowed, c.addr, got, c.want)
+		}
+	}
+}
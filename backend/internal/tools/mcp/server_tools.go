@@ -0,0 +1,574 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"backend/internal/agent"
+	"backend/internal/logger"
+	"backend/internal/rag"
+	"backend/internal/workspace"
+
+	"go.uber.org/zap"
+)
+
+// 发布的内置工具名
+const (
+	toolSearchKnowledge = "search_knowledge"
+	toolListFiles       = "list_workspace_files"
+	toolReadFile        = "read_workspace_file"
+	toolWriteFile       = "write_workspace_file"
+)
+
+// workspaceURIPrefix 工作区文件资源 URI 前缀
+const workspaceURIPrefix = "workspace://files/"
+
+// maxResources resources/list 最多返回的文件数
+const maxResources = 500
+
+// serverTool 发布给客户端的工具及其处理函数
+type serverTool struct {
+	Tool
+	call func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error)
+}
+
+// buildTools 按租户发布配置与会话权限生成工具列表
+func (s *Server) buildTools(ctx context.Context, sess *serverSession) ([]serverTool, error) {
+	exposure, err := s.GetExposure(ctx, sess.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if !exposure.Enabled {
+		return nil, nil
+	}
+
+	var result []serverTool
+	if s.opts.Agents != nil && len(exposure.AgentIDs) > 0 {
+		agentTools, err := s.agentTools(ctx, sess.tenantID, exposure.AgentIDs)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, agentTools...)
+	}
+	if s.opts.Knowledge != nil && len(exposure.KnowledgeBaseIDs) > 0 {
+		kbTool, err := s.knowledgeTool(ctx, sess.tenantID, exposure.KnowledgeBaseIDs)
+		if err != nil {
+			return nil, err
+		}
+		if kbTool != nil {
+			result = append(result, *kbTool)
+		}
+	}
+	if s.opts.Workspace != nil && exposure.canReadWorkspace() {
+		result = append(result, s.workspaceReadTools()...)
+		if exposure.WorkspaceAccess == WorkspaceAccessWrite && sess.scopes[ScopeMCPWrite] {
+			result = append(result, s.workspaceWriteTool())
+		}
+	}
+	return result, nil
+}
+
+func (s *Server) handleListTools(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	serverTools, err := s.buildTools(ctx, sess)
+	if err != nil {
+		logger.Error("MCP 服务端生成工具列表失败", zap.String("tenant_id", sess.tenantID), zap.Error(err))
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "生成工具列表失败")
+	}
+	list := make([]Tool, 0, len(serverTools))
+	for _, t := range serverTools {
+		list = append(list, t.Tool)
+	}
+	return NewResultResponse(msg.ID, ListToolsResult{Tools: list})
+}
+
+func (s *Server) handleCallTool(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	var params CallToolParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || params.Name == "" {
+		return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "tools/call 参数无效")
+	}
+
+	serverTools, err := s.buildTools(ctx, sess)
+	if err != nil {
+		logger.Error("MCP 服务端生成工具列表失败", zap.String("tenant_id", sess.tenantID), zap.Error(err))
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "生成工具列表失败")
+	}
+	for _, t := range serverTools {
+		if t.Name != params.Name {
+			continue
+		}
+		if params.Arguments == nil {
+			params.Arguments = map[string]any{}
+		}
+		if missing := missingRequired(t.InputSchema, params.Arguments); missing != "" {
+			return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "缺少必填参数: "+missing)
+		}
+		result, err := t.call(ctx, sess, params.Arguments)
+		if err != nil {
+			// 执行失败作为工具结果返回，便于模型感知并调整
+			return NewResultResponse(msg.ID, CallToolResult{Content: []Content{TextContent(err.Error())}, IsError: true})
+		}
+		return NewResultResponse(msg.ID, result)
+	}
+	return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "工具不存在: "+params.Name)
+}
+
+// --- Agent ---
+
+// agentTools 把发布的 Agent 转换为工具，名称为 agent_{名称或类型}，重名时追加 ID 前缀
+func (s *Server) agentTools(ctx context.Context, tenantID string, agentIDs []string) ([]serverTool, error) {
+	var configs []agent.AgentConfig
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND id IN ? AND status = ? AND deleted_at IS NULL", tenantID, agentIDs, "active").
+		Order("created_at ASC").
+		Find(&configs).Error; err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool, len(configs))
+	result := make([]serverTool, 0, len(configs))
+	for _, cfg := range configs {
+		base := normalizeNamespace(cfg.Name)
+		if base == "" {
+			base = normalizeNamespace(cfg.AgentType)
+		}
+		name := "agent_" + base
+		if base == "" || used[name] {
+			suffix := strings.ReplaceAll(cfg.ID, "-", "")
+			if len(suffix) > 8 {
+				suffix = suffix[:8]
+			}
+			name = strings.TrimSuffix("agent_"+base, "_") + "_" + suffix
+		}
+		used[name] = true
+
+		description := fmt.Sprintf("调用 Agent「%s」", cfg.Name)
+		if cfg.Description != "" {
+			description += "：" + cfg.Description
+		}
+		agentID, agentName := cfg.ID, cfg.Name
+		result = append(result, serverTool{
+			Tool: Tool{
+				Name:        name,
+				Description: description,
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"content":   map[string]any{"type": "string", "description": "发送给 Agent 的输入内容"},
+						"variables": map[string]any{"type": "object", "description": "提示词模板变量（可选）"},
+					},
+					"required": []any{"content"},
+				},
+				Annotations: map[string]any{"title": agentName, "readOnlyHint": true, "openWorldHint": false},
+			},
+			call: func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error) {
+				content, _ := args["content"].(string)
+				variables, _ := args["variables"].(map[string]any)
+				output, err := s.opts.Agents.RunAgent(ctx, sess.tenantID, sess.userID, agentID, content, variables)
+				if err != nil {
+					return nil, fmt.Errorf("Agent 执行失败: %w", err)
+				}
+				return &CallToolResult{Content: []Content{TextContent(output)}}, nil
+			},
+		})
+	}
+	return result, nil
+}
+
+// --- 知识库 ---
+
+// knowledgeTool 生成知识库检索工具，可检索范围限定为发布配置中的知识库
+func (s *Server) knowledgeTool(ctx context.Context, tenantID string, kbIDs []string) (*serverTool, error) {
+	var kbs []rag.KnowledgeBase
+	if err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND id IN ? AND deleted_at IS NULL", tenantID, kbIDs).
+		Find(&kbs).Error; err != nil {
+		return nil, err
+	}
+	if len(kbs) == 0 {
+		return nil, nil
+	}
+
+	names := make(map[string]string, len(kbs))
+	ids := make([]any, 0, len(kbs))
+	lines := make([]string, 0, len(kbs))
+	for _, kb := range kbs {
+		names[kb.ID] = kb.Name
+		ids = append(ids, kb.ID)
+		line := fmt.Sprintf("- %s：%s", kb.ID, kb.Name)
+		if kb.Description != "" {
+			line += "（" + kb.Description + "）"
+		}
+		lines = append(lines, line)
+	}
+
+	return &serverTool{
+		Tool: Tool{
+			Name:        toolSearchKnowledge,
+			Description: "在知识库中进行语义检索，返回最相关的片段。可检索的知识库：\n" + strings.Join(lines, "\n"),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query":             map[string]any{"type": "string", "description": "检索问题或关键词"},
+					"knowledge_base_id": map[string]any{"type": "string", "enum": ids, "description": "限定检索的知识库，不填则检索全部"},
+					"top_k":             map[string]any{"type": "integer", "minimum": 1, "maximum": 20, "description": "返回条数，默认 5"},
+				},
+				"required": []any{"query"},
+			},
+			Annotations: map[string]any{"title": "知识库检索", "readOnlyHint": true},
+		},
+		call: func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error) {
+			query, _ := args["query"].(string)
+			topK := intArg(args, "top_k", 5)
+			if topK < 1 || topK > 20 {
+				topK = 5
+			}
+			targets := make([]string, 0, len(names))
+			if kbID, _ := args["knowledge_base_id"].(string); kbID != "" {
+				if _, ok := names[kbID]; !ok {
+					return nil, ErrKnowledgeBaseNotFound
+				}
+				targets = append(targets, kbID)
+			} else {
+				for _, kb := range kbs {
+					targets = append(targets, kb.ID)
+				}
+			}
+			return s.searchKnowledge(ctx, sess.tenantID, query, topK, targets, names)
+		},
+	}, nil
+}
+
+// searchKnowledge 逐个知识库检索后按得分合并
+func (s *Server) searchKnowledge(ctx context.Context, tenantID, query string, topK int, kbIDs []string, names map[string]string) (*CallToolResult, error) {
+	var merged []*rag.SearchResult
+	var lastErr error
+	for _, kbID := range kbIDs {
+		resp, err := s.opts.Knowledge.Search(ctx, &rag.SearchRequest{
+			KnowledgeBaseID: kbID,
+			TenantID:        tenantID,
+			Query:           query,
+			TopK:            topK,
+		})
+		if err != nil {
+			lastErr = err
+			logger.Warn("MCP 知识库检索失败", zap.String("kb_id", kbID), zap.Error(err))
+			continue
+		}
+		merged = append(merged, resp.Results...)
+	}
+	if len(merged) == 0 && lastErr != nil {
+		return nil, fmt.Errorf("知识库检索失败: %w", lastErr)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return resultScore(merged[i]) > resultScore(merged[j]) })
+	if len(merged) > topK {
+		merged = merged[:topK]
+	}
+
+	if len(merged) == 0 {
+		return &CallToolResult{
+			Content:           []Content{TextContent("未检索到相关内容")},
+			StructuredContent: map[string]any{"results": []any{}},
+		}, nil
+	}
+	var sb strings.Builder
+	items := make([]any, 0, len(merged))
+	for i, r := range merged {
+		fmt.Fprintf(&sb, "[%d] %s（相关度 %.3f）\n%s\n\n", i+1, names[r.KnowledgeBaseID], resultScore(r), r.Content)
+		items = append(items, map[string]any{
+			"knowledge_base_id": r.KnowledgeBaseID,
+			"document_id":       r.DocumentID,
+			"chunk_id":          r.ChunkID,
+			"score":             resultScore(r),
+			"content":           r.Content,
+		})
+	}
+	return &CallToolResult{
+		Content:           []Content{TextContent(strings.TrimSpace(sb.String()))},
+		StructuredContent: map[string]any{"results": items},
+	}, nil
+}
+
+// resultScore 优先使用重排序得分，未重排序时使用向量相似度
+func resultScore(r *rag.SearchResult) float64 {
+	if r.Score != 0 {
+		return r.Score
+	}
+	return r.Similarity
+}
+
+// --- 工作区 ---
+
+func (s *Server) workspaceReadTools() []serverTool {
+	return []serverTool{
+		{
+			Tool: Tool{
+				Name:        toolListFiles,
+				Description: "列出工作区的目录与文件",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"parent_id": map[string]any{"type": "string", "description": "只列出该目录下的内容，不填为根目录"},
+						"depth":     map[string]any{"type": "integer", "description": "展开层级，默认 2，-1 为全部"},
+					},
+				},
+				Annotations: map[string]any{"title": "列出工作区文件", "readOnlyHint": true},
+			},
+			call: func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error) {
+				opts := workspace.TreeListOptions{Depth: intArg(args, "depth", 2)}
+				if parentID, _ := args["parent_id"].(string); parentID != "" {
+					opts.ParentID = &parentID
+				}
+				tree, err := s.opts.Workspace.ListTreeWithOptions(ctx, sess.tenantID, opts)
+				if err != nil {
+					return nil, err
+				}
+				data, err := json.Marshal(tree)
+				if err != nil {
+					return nil, err
+				}
+				return &CallToolResult{Content: []Content{TextContent(string(data))}}, nil
+			},
+		},
+		{
+			Tool: Tool{
+				Name:        toolReadFile,
+				Description: "读取工作区文件的最新内容",
+				InputSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"node_id": map[string]any{"type": "string", "description": "文件节点 ID"},
+					},
+					"required": []any{"node_id"},
+				},
+				Annotations: map[string]any{"title": "读取工作区文件", "readOnlyHint": true},
+			},
+			call: func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error) {
+				nodeID, _ := args["node_id"].(string)
+				detail, err := s.readWorkspaceFile(ctx, sess.tenantID, nodeID)
+				if err != nil {
+					return nil, err
+				}
+				content, versionID := fileContent(detail)
+				return &CallToolResult{
+					Content: []Content{TextContent(content)},
+					StructuredContent: map[string]any{
+						"node_id":    detail.Node.ID,
+						"path":       detail.Node.NodePath,
+						"version_id": versionID,
+						"content":    content,
+					},
+				}, nil
+			},
+		},
+	}
+}
+
+func (s *Server) workspaceWriteTool() serverTool {
+	return serverTool{
+		Tool: Tool{
+			Name:        toolWriteFile,
+			Description: "写入工作区文件：传 node_id 时为已有文件写入新版本，否则在 parent_id 目录下以 name 新建文件",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"content":             map[string]any{"type": "string", "description": "文件内容"},
+					"node_id":             map[string]any{"type": "string", "description": "已有文件节点 ID"},
+					"parent_id":           map[string]any{"type": "string", "description": "新建文件所在目录 ID"},
+					"name":                map[string]any{"type": "string", "description": "新建文件名"},
+					"summary":             map[string]any{"type": "string", "description": "版本说明"},
+					"expected_version_id": map[string]any{"type": "string", "description": "乐观锁：文件当前版本不一致时拒绝写入"},
+				},
+				"required": []any{"content"},
+			},
+			Annotations: map[string]any{"title": "写入工作区文件", "readOnlyHint": false, "destructiveHint": false},
+		},
+		call: func(ctx context.Context, sess *serverSession, args map[string]any) (*CallToolResult, error) {
+			content, _ := args["content"].(string)
+			summary, _ := args["summary"].(string)
+			var (
+				detail *workspace.FileDetail
+				err    error
+			)
+			if nodeID, _ := args["node_id"].(string); nodeID != "" {
+				expected, _ := args["expected_version_id"].(string)
+				detail, err = s.opts.Workspace.UpdateFileContent(ctx, &workspace.UpdateFileRequest{
+					TenantID:          sess.tenantID,
+					NodeID:            nodeID,
+					Content:           content,
+					Summary:           summary,
+					ToolName:          toolWriteFile,
+					UserID:            sess.userID,
+					ExpectedVersionID: expected,
+				})
+			} else {
+				name, _ := args["name"].(string)
+				if strings.TrimSpace(name) == "" {
+					return nil, errors.New("新建文件时 name 不能为空")
+				}
+				req := &workspace.CreateFileRequest{
+					TenantID: sess.tenantID,
+					Name:     name,
+					Content:  content,
+					Summary:  summary,
+					ToolName: toolWriteFile,
+					UserID:   sess.userID,
+				}
+				if parentID, _ := args["parent_id"].(string); parentID != "" {
+					req.ParentID = &parentID
+				}
+				detail, err = s.opts.Workspace.CreateFile(ctx, req)
+			}
+			if err != nil {
+				return nil, err
+			}
+			_, versionID := fileContent(detail)
+			return &CallToolResult{
+				Content: []Content{TextContent(fmt.Sprintf("已写入 %s", detail.Node.NodePath))},
+				StructuredContent: map[string]any{
+					"node_id":    detail.Node.ID,
+					"path":       detail.Node.NodePath,
+					"version_id": versionID,
+				},
+			}, nil
+		},
+	}
+}
+
+// readWorkspaceFile 读取文件节点，目录节点返回错误
+func (s *Server) readWorkspaceFile(ctx context.Context, tenantID, nodeID string) (*workspace.FileDetail, error) {
+	detail, err := s.opts.Workspace.GetFileDetail(ctx, tenantID, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if detail.Node.Type != "file" {
+		return nil, errors.New("该节点不是文件")
+	}
+	return detail, nil
+}
+
+// fileContent 返回文件最新版本的内容与版本 ID
+func fileContent(detail *workspace.FileDetail) (string, string) {
+	if detail.Version == nil {
+		return "", ""
+	}
+	return detail.Version.Content, detail.Version.ID
+}
+
+// --- 资源 ---
+
+// workspaceExposed 当前会话是否可访问工作区资源
+func (s *Server) workspaceExposed(ctx context.Context, tenantID string) (bool, error) {
+	if s.opts.Workspace == nil {
+		return false, nil
+	}
+	exposure, err := s.GetExposure(ctx, tenantID)
+	if err != nil {
+		return false, err
+	}
+	return exposure.Enabled && exposure.canReadWorkspace(), nil
+}
+
+func (s *Server) handleListResources(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	exposed, err := s.workspaceExposed(ctx, sess.tenantID)
+	if err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "读取发布配置失败")
+	}
+	resources := []Resource{}
+	if exposed {
+		tree, err := s.opts.Workspace.ListTree(ctx, sess.tenantID)
+		if err != nil {
+			return NewErrorResponse(msg.ID, ErrCodeInternal, "读取工作区失败")
+		}
+		var walk func(nodes []*workspace.TreeNode)
+		walk = func(nodes []*workspace.TreeNode) {
+			for _, node := range nodes {
+				if len(resources) >= maxResources {
+					return
+				}
+				if node.Type == "file" {
+					resources = append(resources, Resource{
+						URI:         workspaceURIPrefix + node.ID,
+						Name:        node.Name,
+						Description: node.NodePath,
+						MimeType:    "text/markdown",
+					})
+				}
+				walk(node.Children)
+			}
+		}
+		walk(tree)
+	}
+	return NewResultResponse(msg.ID, ListResourcesResult{Resources: resources})
+}
+
+func (s *Server) handleListResourceTemplates(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	exposed, err := s.workspaceExposed(ctx, sess.tenantID)
+	if err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "读取发布配置失败")
+	}
+	templates := []ResourceTemplate{}
+	if exposed {
+		templates = append(templates, ResourceTemplate{
+			URITemplate: workspaceURIPrefix + "{nodeId}",
+			Name:        "工作区文件",
+			Description: "按节点 ID 读取工作区文件的最新版本",
+			MimeType:    "text/markdown",
+		})
+	}
+	return NewResultResponse(msg.ID, ListResourceTemplatesResult{ResourceTemplates: templates})
+}
+
+func (s *Server) handleReadResource(ctx context.Context, sess *serverSession, msg *Message) *Message {
+	var params ReadResourceParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil || !strings.HasPrefix(params.URI, workspaceURIPrefix) {
+		return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "不支持的资源 URI")
+	}
+	exposed, err := s.workspaceExposed(ctx, sess.tenantID)
+	if err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInternal, "读取发布配置失败")
+	}
+	if !exposed {
+		return NewErrorResponse(msg.ID, ErrCodeInvalidParams, "未开放工作区访问")
+	}
+
+	detail, err := s.readWorkspaceFile(ctx, sess.tenantID, strings.TrimPrefix(params.URI, workspaceURIPrefix))
+	if err != nil {
+		return NewErrorResponse(msg.ID, ErrCodeInvalidParams, err.Error())
+	}
+	content, _ := fileContent(detail)
+	return NewResultResponse(msg.ID, ReadResourceResult{Contents: []ResourceContents{{
+		URI:      params.URI,
+		MimeType: "text/markdown",
+		Text:     content,
+	}}})
+}
+
+// --- 参数辅助 ---
+
+// missingRequired 返回第一个缺失的必填参数名
+func missingRequired(schema map[string]any, args map[string]any) string {
+	required, _ := schema["required"].([]any)
+	for _, r := range required {
+		name, _ := r.(string)
+		if v, ok := args[name]; !ok || v == nil || v == "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// intArg 读取整数参数（JSON 数字解码为 float64）
+func intArg(args map[string]any, key string, def int) int {
+	switch v := args[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return def
+}
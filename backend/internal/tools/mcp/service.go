@@ -0,0 +1,621 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/security"
+	"backend/internal/tools"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrServerNotFound     = errors.New("MCP 服务不存在")
+	ErrServerExists       = errors.New("MCP 服务名称已存在")
+	ErrStdioNotAllowed    = errors.New("该命令未在 stdio 白名单中")
+	ErrStdioEnvNotAllowed = errors.New("不允许为 stdio 命令设置该环境变量")
+	ErrStdioArgNotAllowed = errors.New("该参数不允许用于 stdio 命令")
+	ErrInvalidTransport   = errors.New("不支持的传输类型，仅支持 stdio 与 http")
+)
+
+// connectTimeout 建立连接与获取工具列表的超时
+const connectTimeout = 30 * time.Second
+
+// serversChangedChannel 服务配置变更通知频道，各实例收到后立即同步连接
+const serversChangedChannel = "mcp:servers:changed"
+
+// Options MCP 客户端服务配置
+type Options struct {
+	// AllowedCommands 允许以 stdio 方式启动的命令；为空时禁用 stdio 传输
+	// stdio 会在服务器上启动子进程，只应开放给受信任的命令
+	AllowedCommands []string
+	// AllowedArgs 按命令配置允许的参数模式（path.Match 通配），配置后每个参数都必须匹配其中之一；
+	// 未配置的命令拒绝执行内联代码或预加载模块的参数（如 node -e、python -c）
+	AllowedArgs map[string][]string
+	// WorkDir stdio 子进程的工作目录
+	WorkDir string
+	// AllowedHosts 允许指向内网地址的 MCP 服务主机名（部署在内网的受信任服务），其余 HTTP 服务只能连接公网地址
+	AllowedHosts []string
+	// HTTPClient HTTP 传输使用的客户端，为空时使用只能连接公网地址（及 AllowedHosts）的客户端
+	HTTPClient *http.Client
+	// Redis 配置后通过发布订阅通知其他实例立即同步连接，否则只按 StartSync 的周期同步
+	Redis redis.UniversalClient
+}
+
+// Service 外部 MCP 服务连接管理
+// 连接成功后把服务端的工具注册到 ToolRegistry（名称带命名空间前缀），断开或删除时注销；
+// ToolRegistry 是进程内的，多实例部署时每个实例按数据库中的配置各自连接（见 Sync）
+type Service struct {
+	db       *gorm.DB
+	registry *tools.ToolRegistry
+	opts     Options
+
+	connectMu sync.Mutex // 串行化同一实例内的连接与断开
+	mu        sync.Mutex
+	conns     map[string]*connection // serverID -> connection
+}
+
+// NewService 创建 MCP 客户端服务
+func NewService(db *gorm.DB, registry *tools.ToolRegistry, opts Options) *Service {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = security.NewGuardedHTTPClient(opts.AllowedHosts, connectTimeout)
+	}
+	return &Service{
+		db:       db,
+		registry: registry,
+		opts:     opts,
+		conns:    make(map[string]*connection),
+	}
+}
+
+// AutoMigrate 自动迁移表结构
+func (s *Service) AutoMigrate() error {
+	return s.db.AutoMigrate(&MCPServer{})
+}
+
+// --- 服务配置 ---
+
+// CreateServer 创建 MCP 服务配置并尝试连接
+// 连接失败不影响创建，错误记录在 LastError 中
+func (s *Service) CreateServer(ctx context.Context, tenantID, userID string, req *CreateServerRequest) (*MCPServer, error) {
+	server := &MCPServer{
+		ID:          uuid.New().String(),
+		TenantID:    tenantID,
+		Name:        strings.TrimSpace(req.Name),
+		Namespace:   normalizeNamespace(req.Namespace),
+		Description: req.Description,
+		Transport:   strings.ToLower(req.Transport),
+		Command:     strings.TrimSpace(req.Command),
+		Args:        req.Args,
+		Env:         req.Env,
+		URL:         strings.TrimSpace(req.URL),
+		Headers:     req.Headers,
+		Timeout:     req.Timeout,
+		Enabled:     true,
+		Status:      ServerStatusDisconnected,
+		CreatedBy:   userID,
+	}
+	if req.Enabled != nil {
+		server.Enabled = *req.Enabled
+	}
+	if server.Namespace == "" {
+		server.Namespace = normalizeNamespace(server.Name)
+	}
+	if server.Namespace == "" {
+		server.Namespace = "mcp_" + server.ID[:8]
+	}
+	if server.Timeout <= 0 {
+		server.Timeout = 30
+	}
+	if err := s.validate(server); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	s.db.WithContext(ctx).Model(&MCPServer{}).
+		Where("tenant_id = ? AND (name = ? OR namespace = ?)", tenantID, server.Name, server.Namespace).
+		Count(&count)
+	if count > 0 {
+		return nil, ErrServerExists
+	}
+
+	if err := s.db.WithContext(ctx).Create(server).Error; err != nil {
+		return nil, fmt.Errorf("创建 MCP 服务失败: %w", err)
+	}
+
+	if server.Enabled {
+		s.connect(ctx, server)
+	}
+	s.notifyChanged(ctx)
+	return server, nil
+}
+
+// UpdateServer 更新配置并重新连接
+func (s *Service) UpdateServer(ctx context.Context, tenantID, id string, req *UpdateServerRequest) (*MCPServer, error) {
+	server, err := s.GetServer(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Description != nil {
+		server.Description = *req.Description
+	}
+	if req.Command != nil {
+		server.Command = strings.TrimSpace(*req.Command)
+	}
+	if req.Args != nil {
+		server.Args = req.Args
+	}
+	if req.Env != nil {
+		server.Env = req.Env
+	}
+	if req.URL != nil {
+		server.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Headers != nil {
+		server.Headers = req.Headers
+	}
+	if req.Timeout != nil && *req.Timeout > 0 {
+		server.Timeout = *req.Timeout
+	}
+	if req.Enabled != nil {
+		server.Enabled = *req.Enabled
+	}
+	if err := s.validate(server); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(server).Error; err != nil {
+		return nil, fmt.Errorf("更新 MCP 服务失败: %w", err)
+	}
+
+	if server.Enabled {
+		s.connect(ctx, server)
+	} else {
+		s.disconnect(server.ID)
+		s.updateStatus(ctx, server, ServerStatusDisconnected, "", 0, nil)
+	}
+	s.notifyChanged(ctx)
+	return server, nil
+}
+
+// DeleteServer 断开连接并删除配置
+func (s *Service) DeleteServer(ctx context.Context, tenantID, id string) error {
+	server, err := s.GetServer(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+	s.disconnect(server.ID)
+	if err := s.db.WithContext(ctx).Delete(&MCPServer{}, "id = ?", server.ID).Error; err != nil {
+		return err
+	}
+	s.notifyChanged(ctx)
+	return nil
+}
+
+// GetServer 获取服务配置
+func (s *Service) GetServer(ctx context.Context, tenantID, id string) (*MCPServer, error) {
+	var server MCPServer
+	err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", id, tenantID).First(&server).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrServerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// ListServers 列出租户的 MCP 服务
+func (s *Service) ListServers(ctx context.Context, tenantID string) ([]MCPServer, error) {
+	var servers []MCPServer
+	err := s.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("name").
+		Find(&servers).Error
+	return servers, err
+}
+
+// ListServerTools 列出服务已注册的工具
+func (s *Service) ListServerTools(ctx context.Context, tenantID, id string) ([]*tools.ToolDefinition, error) {
+	server, err := s.GetServer(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	conn := s.conns[server.ID]
+	s.mu.Unlock()
+	if conn == nil {
+		return []*tools.ToolDefinition{}, nil
+	}
+
+	defs := make([]*tools.ToolDefinition, 0, len(conn.toolNames))
+	for _, name := range conn.toolNames {
+		if def, ok := s.registry.GetDefinition(name); ok {
+			defs = append(defs, def)
+		}
+	}
+	return defs, nil
+}
+
+// Refresh 重新连接并同步工具列表
+func (s *Service) Refresh(ctx context.Context, tenantID, id string) (*MCPServer, error) {
+	server, err := s.GetServer(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !server.Enabled {
+		return nil, fmt.Errorf("MCP 服务已停用")
+	}
+	s.connect(ctx, server)
+	return server, nil
+}
+
+// Sync 按数据库中的配置同步本实例的连接：连接新增或配置变化的启用服务，断开已删除或停用的服务
+// 其他实例创建、修改或删除服务后，本实例通过 Sync 注册或注销对应工具
+func (s *Service) Sync(ctx context.Context) error {
+	var servers []MCPServer
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Find(&servers).Error; err != nil {
+		return err
+	}
+
+	enabled := make(map[string]bool, len(servers))
+	for i := range servers {
+		server := &servers[i]
+		enabled[server.ID] = true
+		s.mu.Lock()
+		conn := s.conns[server.ID]
+		s.mu.Unlock()
+		if conn != nil && conn.fingerprint == configFingerprint(server) {
+			continue
+		}
+		s.connect(ctx, server)
+	}
+
+	s.mu.Lock()
+	var stale []string
+	for id := range s.conns {
+		if !enabled[id] {
+			stale = append(stale, id)
+		}
+	}
+	s.mu.Unlock()
+	for _, id := range stale {
+		s.disconnect(id)
+	}
+	return nil
+}
+
+// StartSync 启动时及之后定时同步连接；配置了 Redis 时收到变更通知立即同步
+func (s *Service) StartSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	trigger := make(chan struct{}, 1)
+	if s.opts.Redis != nil {
+		pubsub := s.opts.Redis.Subscribe(ctx, serversChangedChannel)
+		go func() {
+			defer pubsub.Close()
+			ch := pubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case _, ok := <-ch:
+					if !ok {
+						return
+					}
+					select {
+					case trigger <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.Sync(ctx); err != nil {
+				logger.Warn("同步 MCP 服务连接失败", zap.Error(err))
+			}
+			select {
+			case <-ticker.C:
+			case <-trigger:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// notifyChanged 通知其他实例服务配置已变更
+func (s *Service) notifyChanged(ctx context.Context) {
+	if s.opts.Redis == nil {
+		return
+	}
+	if err := s.opts.Redis.Publish(context.WithoutCancel(ctx), serversChangedChannel, "1").Err(); err != nil {
+		logger.Warn("发布 MCP 服务变更通知失败，其他实例将在下次定时同步时更新", zap.Error(err))
+	}
+}
+
+// Close 断开所有连接
+func (s *Service) Close() {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.conns))
+	for id := range s.conns {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.disconnect(id)
+	}
+}
+
+func (s *Service) validate(server *MCPServer) error {
+	if server.Name == "" {
+		return fmt.Errorf("服务名称不能为空")
+	}
+	switch server.Transport {
+	case TransportStdio:
+		if server.Command == "" {
+			return fmt.Errorf("stdio 传输需要 command")
+		}
+		allowed := false
+		for _, cmd := range s.opts.AllowedCommands {
+			if cmd == server.Command {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: %s", ErrStdioNotAllowed, server.Command)
+		}
+		if err := checkStdioArgs(server.Command, server.Args, s.opts.AllowedArgs); err != nil {
+			return err
+		}
+		if err := checkStdioEnv(server.Env); err != nil {
+			return err
+		}
+	case TransportHTTP:
+		u, err := url.Parse(server.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的 MCP 服务地址: %s", server.URL)
+		}
+		if err := security.CheckHost(u.Hostname(), s.opts.AllowedHosts); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidTransport
+	}
+	return nil
+}
+
+// --- 连接管理 ---
+
+// connection 单个 MCP 服务的连接
+// 传输断开后，下一次工具调用会自动重连
+type connection struct {
+	server      MCPServer
+	fingerprint string // 建立连接时的配置摘要，用于判断配置是否变化
+	dial        func(ctx context.Context) (*Client, error)
+	toolNames   []string
+
+	mu     sync.Mutex
+	client *Client
+}
+
+// getClient 获取可用客户端，必要时重连
+func (c *connection) getClient(ctx context.Context) (*Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		return c.client, nil
+	}
+	client, err := c.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("重连 MCP 服务 %s 失败: %w", c.server.Name, err)
+	}
+	c.client = client
+	return client, nil
+}
+
+// reset 丢弃已断开的客户端
+func (c *connection) reset(client *Client, cause error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == client {
+		_ = client.Close()
+		c.client = nil
+		logger.Warn("MCP 连接已断开，将在下次调用时重连",
+			zap.String("server", c.server.Name), zap.Error(cause))
+	}
+}
+
+func (c *connection) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client != nil {
+		_ = c.client.Close()
+		c.client = nil
+	}
+}
+
+func (s *Service) newTransport(server *MCPServer) Transport {
+	if server.Transport == TransportStdio {
+		return NewStdioTransport(server.Command, server.Args, server.Env, s.opts.WorkDir)
+	}
+	return NewHTTPTransport(server.URL, server.Headers, s.opts.HTTPClient)
+}
+
+func (s *Service) dial(ctx context.Context, server MCPServer) (*Client, error) {
+	client := NewClient(s.newTransport(&server))
+	if err := client.Initialize(ctx); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// connect 建立连接、获取工具列表并注册到 ToolRegistry
+// 结果写回服务状态；单个工具注册失败（如名称冲突）会记录在 LastError 中，不影响其他工具
+func (s *Service) connect(ctx context.Context, server *MCPServer) {
+	s.connectMu.Lock()
+	defer s.connectMu.Unlock()
+	s.disconnectLocked(server.ID)
+
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	// 按当前策略重新校验（白名单收紧前保存的配置不再连接）
+	if err := s.validate(server); err != nil {
+		s.updateStatus(ctx, server, ServerStatusError, err.Error(), 0, nil)
+		return
+	}
+
+	snapshot := *server
+	conn := &connection{
+		server:      snapshot,
+		fingerprint: configFingerprint(&snapshot),
+		dial: func(ctx context.Context) (*Client, error) {
+			return s.dial(ctx, snapshot)
+		},
+	}
+
+	client, err := conn.getClient(ctx)
+	if err != nil {
+		s.updateStatus(ctx, server, ServerStatusError, err.Error(), 0, nil)
+		return
+	}
+
+	remoteTools, err := client.ListTools(ctx)
+	if err != nil {
+		conn.close()
+		s.updateStatus(ctx, server, ServerStatusError, err.Error(), 0, nil)
+		return
+	}
+
+	var problems []string
+	for _, rt := range remoteTools {
+		name := registeredToolName(server.TenantID, server.Namespace, rt.Name)
+		schema := rt.InputSchema
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		definition := &tools.ToolDefinition{
+			ID:          uuid.New().String(),
+			TenantID:    server.TenantID,
+			Name:        name,
+			DisplayName: rt.Name,
+			Description: strings.TrimSpace(fmt.Sprintf("[%s] %s", server.Name, rt.Description)),
+			Category:    "mcp",
+			Type:        "mcp",
+			Parameters:  schema,
+			RequireAuth: true,
+			Timeout:     server.Timeout,
+			MaxRetries:  0,
+			Status:      "active",
+		}
+		handler := &RemoteTool{
+			conn:    conn,
+			name:    rt.Name,
+			schema:  schema,
+			timeout: time.Duration(server.Timeout) * time.Second,
+		}
+		if err := s.registry.Register(name, handler, definition); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		conn.toolNames = append(conn.toolNames, name)
+	}
+
+	s.mu.Lock()
+	s.conns[server.ID] = conn
+	s.mu.Unlock()
+
+	info := client.ServerInfo()
+	server.ServerName = info.Name
+	server.ServerVersion = info.Version
+	now := time.Now()
+	s.updateStatus(ctx, server, ServerStatusConnected, strings.Join(problems, "; "), len(conn.toolNames), &now)
+
+	logger.Info("MCP 服务已连接",
+		zap.String("tenant_id", server.TenantID),
+		zap.String("server", server.Name),
+		zap.Int("tools", len(conn.toolNames)))
+}
+
+// configFingerprint 影响连接与工具注册的配置摘要（状态字段不参与）
+func configFingerprint(server *MCPServer) string {
+	data, _ := json.Marshal(struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace"`
+		Transport string            `json:"transport"`
+		Command   string            `json:"command"`
+		Args      []string          `json:"args"`
+		Env       map[string]string `json:"env"`
+		URL       string            `json:"url"`
+		Headers   map[string]string `json:"headers"`
+		Timeout   int               `json:"timeout"`
+	}{server.Name, server.Namespace, server.Transport, server.Command, server.Args, server.Env, server.URL, server.Headers, server.Timeout})
+	return string(data)
+}
+
+// disconnect 注销工具并关闭连接
+func (s *Service) disconnect(serverID string) {
+	s.connectMu.Lock()
+	defer s.connectMu.Unlock()
+	s.disconnectLocked(serverID)
+}
+
+// disconnectLocked 同 disconnect，调用方需持有 connectMu
+func (s *Service) disconnectLocked(serverID string) {
+	s.mu.Lock()
+	conn := s.conns[serverID]
+	delete(s.conns, serverID)
+	s.mu.Unlock()
+	if conn == nil {
+		return
+	}
+
+	for _, name := range conn.toolNames {
+		s.registry.Unregister(name)
+	}
+	conn.close()
+}
+
+func (s *Service) updateStatus(ctx context.Context, server *MCPServer, status, lastError string, toolCount int, connectedAt *time.Time) {
+	server.Status = status
+	server.LastError = lastError
+	server.ToolCount = toolCount
+	updates := map[string]any{
+		"status":         status,
+		"last_error":     lastError,
+		"tool_count":     toolCount,
+		"server_name":    server.ServerName,
+		"server_version": server.ServerVersion,
+	}
+	if connectedAt != nil {
+		server.LastConnectedAt = connectedAt
+		updates["last_connected_at"] = connectedAt
+	}
+	if err := s.db.WithContext(context.WithoutCancel(ctx)).Model(&MCPServer{}).Where("id = ?", server.ID).Updates(updates).Error; err != nil {
+		logger.Warn("更新 MCP 服务状态失败", zap.String("server", server.Name), zap.Error(err))
+	}
+}
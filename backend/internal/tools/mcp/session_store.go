@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sessionStore MCP 会话存储，过期时间按最近一次访问滑动
+type sessionStore interface {
+	Save(ctx context.Context, sess *serverSession, ttl time.Duration) error
+	// Load 读取会话并刷新过期时间，不存在或已过期时返回 nil
+	Load(ctx context.Context, id string, ttl time.Duration) (*serverSession, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// memorySessionStore 进程内会话存储，多实例部署时需要按 Mcp-Session-Id 做会话粘滞
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*serverSession
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*serverSession)}
+}
+
+// Save 保存会话，并顺带清理过期会话
+func (m *memorySessionStore) Save(_ context.Context, sess *serverSession, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, existing := range m.sessions {
+		if time.Since(existing.lastSeen) > ttl {
+			delete(m.sessions, id)
+		}
+	}
+	sess.lastSeen = time.Now()
+	m.sessions[sess.id] = sess
+	return nil
+}
+
+func (m *memorySessionStore) Load(_ context.Context, id string, ttl time.Duration) (*serverSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	if time.Since(sess.lastSeen) > ttl {
+		delete(m.sessions, id)
+		return nil, nil
+	}
+	sess.lastSeen = time.Now()
+	return sess, nil
+}
+
+func (m *memorySessionStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// redisSessionStore 基于 Redis 的会话存储，多实例共享会话，请求可由任一实例处理
+type redisSessionStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+func newRedisSessionStore(client redis.UniversalClient) *redisSessionStore {
+	return &redisSessionStore{client: client, prefix: "mcp:session:"}
+}
+
+// sessionRecord 会话在 Redis 中的序列化形式
+type sessionRecord struct {
+	KeyID           string          `json:"keyId"`
+	TenantID        string          `json:"tenantId"`
+	UserID          string          `json:"userId"`
+	Scopes          map[string]bool `json:"scopes"`
+	ProtocolVersion string          `json:"protocolVersion"`
+}
+
+func (r *redisSessionStore) Save(ctx context.Context, sess *serverSession, ttl time.Duration) error {
+	data, err := json.Marshal(sessionRecord{
+		KeyID:           sess.keyID,
+		TenantID:        sess.tenantID,
+		UserID:          sess.userID,
+		Scopes:          sess.scopes,
+		ProtocolVersion: sess.protocolVersion,
+	})
+	if err != nil {
+		return err
+	}
+	sess.lastSeen = time.Now()
+	return r.client.Set(ctx, r.prefix+sess.id, data, ttl).Err()
+}
+
+func (r *redisSessionStore) Load(ctx context.Context, id string, ttl time.Duration) (*serverSession, error) {
+	data, err := r.client.GetEx(ctx, r.prefix+id, ttl).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var record sessionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &serverSession{
+		id:              id,
+		keyID:           record.KeyID,
+		tenantID:        record.TenantID,
+		userID:          record.UserID,
+		scopes:          record.Scopes,
+		protocolVersion: record.ProtocolVersion,
+		lastSeen:        time.Now(),
+	}, nil
+}
+
+func (r *redisSessionStore) Delete(ctx context.Context, id string) error {
+	return r.client.Del(ctx, r.prefix+id).Err()
+}
@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// deniedStdioEnv 会改变子进程加载或执行行为的环境变量：可执行文件搜索路径、Shell 启动脚本、
+// 解释器的预加载与启动选项；这些变量由服务端设置，租户配置中不允许覆盖
+var deniedStdioEnv = map[string]bool{
+	"PATH":              true,
+	"HOME":              true,
+	"SHELL":             true,
+	"IFS":               true,
+	"ENV":               true,
+	"BASH_ENV":          true,
+	"NODE_OPTIONS":      true,
+	"NODE_PATH":         true,
+	"PYTHONPATH":        true,
+	"PYTHONHOME":        true,
+	"PYTHONSTARTUP":     true,
+	"PYTHONUSERBASE":    true,
+	"PERL5LIB":          true,
+	"PERL5OPT":          true,
+	"PERLLIB":           true,
+	"RUBYLIB":           true,
+	"RUBYOPT":           true,
+	"CLASSPATH":         true,
+	"JAVA_TOOL_OPTIONS": true,
+	"_JAVA_OPTIONS":     true,
+	"JDK_JAVA_OPTIONS":  true,
+	"GCONV_PATH":        true,
+}
+
+// deniedStdioEnvPrefixes 动态链接器变量，以及可把包下载源指向任意地址的包管理器配置
+var deniedStdioEnvPrefixes = []string{"LD_", "DYLD_", "NPM_CONFIG_", "PIP_", "UV_"}
+
+// deniedStdioArgs 常见解释器与启动器中执行内联代码或预加载模块的参数（按命令文件名）
+var deniedStdioArgs = map[string][]string{
+	"node":    {"-e", "--eval", "-p", "--print", "-r", "--require", "--import", "--loader", "--experimental-loader", "--inspect", "--inspect-brk"},
+	"bun":     {"-e", "--eval", "-p", "--print", "-r", "--preload"},
+	"npx":     {"-c", "--call", "--node-options"},
+	"python":  {"-c"},
+	"python3": {"-c"},
+	"sh":      {"-c"},
+	"bash":    {"-c"},
+}
+
+// stdioEnvAllowed 环境变量是否允许由租户配置
+func stdioEnvAllowed(key string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(key))
+	if upper == "" || deniedStdioEnv[upper] {
+		return false
+	}
+	for _, prefix := range deniedStdioEnvPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkStdioEnv 校验租户配置的环境变量
+func checkStdioEnv(env map[string]string) error {
+	for key := range env {
+		if !stdioEnvAllowed(key) {
+			return fmt.Errorf("%w: %s", ErrStdioEnvNotAllowed, key)
+		}
+	}
+	return nil
+}
+
+// checkStdioArgs 校验启动参数：命令配置了参数模式时每个参数都必须匹配其中之一（path.Match 通配），
+// 未配置时拒绝该命令执行内联代码或预加载模块的参数
+func checkStdioArgs(command string, args []string, allowedArgs map[string][]string) error {
+	if patterns, ok := allowedArgs[command]; ok {
+		for _, arg := range args {
+			if !matchAnyPattern(arg, patterns) {
+				return fmt.Errorf("%w: %s", ErrStdioArgNotAllowed, arg)
+			}
+		}
+		return nil
+	}
+	denied := deniedStdioArgs[filepath.Base(command)]
+	for _, arg := range args {
+		for _, flag := range denied {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") ||
+				// 短参数可与值连写，如 python -cCODE
+				(len(flag) == 2 && strings.HasPrefix(arg, flag)) {
+				return fmt.Errorf("%w: %s", ErrStdioArgNotAllowed, arg)
+			}
+		}
+	}
+	return nil
+}
+
+func matchAnyPattern(arg string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, arg); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
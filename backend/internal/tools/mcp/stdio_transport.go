@@ -0,0 +1,269 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Transport MCP 传输层
+type Transport interface {
+	// Start 建立连接（stdio 启动子进程；HTTP 无需预先连接）
+	Start(ctx context.Context) error
+	// Call 发送请求并等待响应结果
+	Call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	// Notify 发送通知
+	Notify(ctx context.Context, method string, params any) error
+	// Close 关闭连接
+	Close() error
+}
+
+// ErrTransportClosed 连接已关闭
+var ErrTransportClosed = errors.New("MCP 连接已关闭")
+
+// maxMessageSize 单条消息的最大长度
+const maxMessageSize = 16 << 20
+
+// StdioTransport 通过子进程标准输入输出通信（每行一条 JSON-RPC 消息）
+type StdioTransport struct {
+	command string
+	args    []string
+	env     map[string]string
+	dir     string
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *Message
+	closed  bool
+	done    chan struct{}
+	stderr  *tailBuffer
+}
+
+// NewStdioTransport 创建 stdio 传输
+// 子进程不继承服务端环境变量（避免泄露数据库密码等），只传入 PATH/HOME 与 env 中允许的配置
+func NewStdioTransport(command string, args []string, env map[string]string, dir string) *StdioTransport {
+	return &StdioTransport{
+		command: command,
+		args:    args,
+		env:     env,
+		dir:     dir,
+		pending: make(map[string]chan *Message),
+		done:    make(chan struct{}),
+		stderr:  newTailBuffer(4096),
+	}
+}
+
+// Start 启动子进程
+func (t *StdioTransport) Start(ctx context.Context) error {
+	// 子进程生命周期独立于发起连接的请求，不使用 CommandContext
+	cmd := exec.Command(t.command, t.args...)
+	cmd.Dir = t.dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + os.Getenv("HOME")}
+	for k, v := range t.env {
+		if !stdioEnvAllowed(k) {
+			continue
+		}
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = t.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("创建 stdin 管道失败: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("创建 stdout 管道失败: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("启动 MCP 服务进程失败: %w", err)
+	}
+
+	t.cmd = cmd
+	t.stdin = stdin
+	go t.readLoop(stdout)
+	go func() {
+		_ = cmd.Wait()
+		t.shutdown()
+	}()
+	return nil
+}
+
+// readLoop 读取子进程输出并分发响应
+func (t *StdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			// 部分服务会向 stdout 打印日志，忽略非 JSON 行
+			continue
+		}
+		switch {
+		case msg.IsResponse():
+			t.deliver(&msg)
+		case msg.Method != "" && !msg.IsNotification():
+			t.replyServerRequest(&msg)
+		}
+	}
+	t.shutdown()
+}
+
+func (t *StdioTransport) deliver(msg *Message) {
+	t.mu.Lock()
+	ch, ok := t.pending[idKey(msg.ID)]
+	delete(t.pending, idKey(msg.ID))
+	t.mu.Unlock()
+	if ok {
+		ch <- msg
+	}
+}
+
+// replyServerRequest 响应服务端发起的请求：只支持 ping，其余返回 method not found
+func (t *StdioTransport) replyServerRequest(msg *Message) {
+	if msg.Method == "ping" {
+		_ = t.write(NewResultResponse(msg.ID, map[string]any{}))
+		return
+	}
+	_ = t.write(NewErrorResponse(msg.ID, ErrCodeMethodNotFound, "客户端不支持该方法: "+msg.Method))
+}
+
+func (t *StdioTransport) write(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if t.stdin == nil {
+		return ErrTransportClosed
+	}
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入 MCP 服务进程失败: %w", err)
+	}
+	return nil
+}
+
+// Call 发送请求并等待响应
+func (t *StdioTransport) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	msg, err := newRequest(t.nextID.Add(1), method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *Message, 1)
+	key := idKey(msg.ID)
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil, t.closedError()
+	}
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	if err := t.write(msg); err != nil {
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return responseResult(resp)
+	case <-t.done:
+		return nil, t.closedError()
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		_ = t.Notify(context.Background(), "notifications/cancelled", map[string]any{"requestId": json.RawMessage(key), "reason": ctx.Err().Error()})
+		return nil, ctx.Err()
+	}
+}
+
+// Notify 发送通知
+func (t *StdioTransport) Notify(ctx context.Context, method string, params any) error {
+	msg, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return t.write(msg)
+}
+
+// Close 关闭 stdin 并结束子进程
+func (t *StdioTransport) Close() error {
+	t.writeMu.Lock()
+	if t.stdin != nil {
+		_ = t.stdin.Close()
+	}
+	t.writeMu.Unlock()
+
+	if t.cmd != nil && t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	t.shutdown()
+	return nil
+}
+
+// shutdown 标记连接关闭并唤醒所有等待中的请求
+func (t *StdioTransport) shutdown() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.pending = make(map[string]chan *Message)
+	close(t.done)
+}
+
+func (t *StdioTransport) closedError() error {
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		return fmt.Errorf("%w: %s", ErrTransportClosed, tail)
+	}
+	return ErrTransportClosed
+}
+
+// tailBuffer 只保留最后 N 字节的缓冲区，用于记录子进程 stderr
+type tailBuffer struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,136 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RemoteTool 外部 MCP 服务提供的工具
+// 注册到 tools.ToolRegistry 后与内置工具一样经由 ToolExecutor 调用，执行记录与指标照常生效
+type RemoteTool struct {
+	conn    *connection
+	name    string // 服务端的工具名
+	schema  map[string]any
+	timeout time.Duration
+}
+
+// Execute 调用远程工具
+func (t *RemoteTool) Execute(ctx context.Context, input map[string]any) (map[string]any, error) {
+	client, err := t.conn.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+		defer cancel()
+	}
+
+	result, err := client.CallTool(ctx, t.name, t.arguments(input))
+	if err != nil {
+		if errors.Is(err, ErrTransportClosed) {
+			t.conn.reset(client, err)
+		}
+		return nil, fmt.Errorf("调用 MCP 工具 %s 失败: %w", t.name, err)
+	}
+
+	text := result.Text()
+	if result.IsError {
+		return nil, fmt.Errorf("MCP 工具 %s 返回错误: %s", t.name, text)
+	}
+
+	output := map[string]any{
+		"content": result.Content,
+		"text":    text,
+	}
+	if result.StructuredContent != nil {
+		output["structured"] = result.StructuredContent
+	}
+	return output, nil
+}
+
+// Validate 校验 inputSchema 中的必填参数
+func (t *RemoteTool) Validate(input map[string]any) error {
+	for _, key := range schemaRequired(t.schema) {
+		if _, ok := input[key]; !ok {
+			return fmt.Errorf("缺少必需参数: %s", key)
+		}
+	}
+	return nil
+}
+
+// arguments 去除 schema 未声明的平台注入参数（如 tenant_id），避免严格校验的服务端拒绝请求
+func (t *RemoteTool) arguments(input map[string]any) map[string]any {
+	properties, _ := t.schema["properties"].(map[string]any)
+	if _, declared := properties["tenant_id"]; declared {
+		return input
+	}
+	if _, ok := input["tenant_id"]; !ok {
+		return input
+	}
+	args := make(map[string]any, len(input))
+	for k, v := range input {
+		if k != "tenant_id" {
+			args[k] = v
+		}
+	}
+	return args
+}
+
+func schemaRequired(schema map[string]any) []string {
+	var required []string
+	switch v := schema["required"].(type) {
+	case []string:
+		required = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				required = append(required, s)
+			}
+		}
+	}
+	return required
+}
+
+// --- 命名 ---
+
+var (
+	namespaceInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+	toolNameInvalidChars  = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+)
+
+// maxToolNameLength 模型接口对函数名的长度限制
+const maxToolNameLength = 64
+
+// tenantKeyLength 工具名中租户摘要的十六进制长度
+const tenantKeyLength = 10
+
+// normalizeNamespace 规范化命名空间：小写字母、数字与下划线，最长 32 个字符
+func normalizeNamespace(s string) string {
+	ns := strings.Trim(namespaceInvalidChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+	if len(ns) > 32 {
+		ns = strings.TrimRight(ns[:32], "_")
+	}
+	return ns
+}
+
+// registeredToolName 注册到 ToolRegistry 的工具名：t{租户摘要}_{namespace}__{tool}
+// ToolRegistry 为进程内全局注册表，租户摘要保证不同租户使用相同命名空间时不会冲突
+func registeredToolName(tenantID, namespace, tool string) string {
+	name := namespace + "__" + toolNameInvalidChars.ReplaceAllString(tool, "_")
+	if tenantID != "" {
+		sum := sha256.Sum256([]byte(tenantID))
+		name = "t" + hex.EncodeToString(sum[:])[:tenantKeyLength] + "_" + name
+	}
+	if len(name) > maxToolNameLength {
+		name = name[:maxToolNameLength]
+	}
+	return name
+}
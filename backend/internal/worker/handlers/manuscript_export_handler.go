@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"backend/internal/worker/tasks"
+	"backend/internal/workspace"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// ManuscriptExporter 手稿导出抽象，便于注入 mock
+type ManuscriptExporter interface {
+	RunExport(ctx context.Context, jobID string) error
+}
+
+type ManuscriptExportHandler struct {
+	exporter ManuscriptExporter
+	logger   *zap.Logger
+}
+
+func NewManuscriptExportHandler(exporter ManuscriptExporter, logger *zap.Logger) *ManuscriptExportHandler {
+	return &ManuscriptExportHandler{
+		exporter: exporter,
+		logger:   logger,
+	}
+}
+
+// HandleExportManuscript 生成 EPUB / DOCX 文件，任务记录不存在时不再重试
+func (h *ManuscriptExportHandler) HandleExportManuscript(ctx context.Context, t *asynq.Task) error {
+	var p tasks.ExportManuscriptPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json unmarshal failed: %w: %w", err, asynq.SkipRetry)
+	}
+
+	if err := h.exporter.RunExport(ctx, p.JobID); err != nil {
+		h.logger.Warn("手稿导出失败",
+			zap.String("job_id", p.JobID),
+			zap.Error(err),
+		)
+		if errors.Is(err, workspace.ErrManuscriptExportNotFound) {
+			return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"backend/internal/worker/tasks"
+
+	"github.com/hibiken/asynq"
+	"go.uber.org/zap"
+)
+
+// WebhookDeliverer Webhook 投递抽象，便于注入 mock
+type WebhookDeliverer interface {
+	ProcessDelivery(ctx context.Context, deliveryID string, retry, maxRetry int) error
+}
+
+type WebhookHandler struct {
+	deliverer WebhookDeliverer
+	logger    *zap.Logger
+}
+
+func NewWebhookHandler(deliverer WebhookDeliverer, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		deliverer: deliverer,
+		logger:    logger,
+	}
+}
+
+// HandleDeliverWebhook 执行一次投递尝试，返回错误时由 asynq 按退避重试，重试耗尽后归档
+func (h *WebhookHandler) HandleDeliverWebhook(ctx context.Context, t *asynq.Task) error {
+	var p tasks.DeliverWebhookPayload
+	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json unmarshal failed: %w: %w", err, asynq.SkipRetry)
+	}
+
+	retry, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if err := h.deliverer.ProcessDelivery(ctx, p.DeliveryID, retry, maxRetry); err != nil {
+		h.logger.Warn("Webhook 投递失败",
+			zap.String("delivery_id", p.DeliveryID),
+			zap.Int("retry", retry),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package worker
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"backend/internal/config"
+	"backend/internal/notification"
 	"backend/internal/rag"
 	"backend/internal/worker/handlers"
 	"backend/internal/worker/tasks"
@@ -24,6 +26,8 @@ func NewServer(
 	cfg config.RedisConfig,
 	ragService *rag.RAGService,
 	workflowEngine *executor.Engine,
+	webhookService *notification.WebhookService,
+	manuscriptExporter handlers.ManuscriptExporter,
 	logger *zap.Logger,
 ) *Server {
 	srv := asynq.NewServer(
@@ -37,8 +41,17 @@ func NewServer(
 			Queues: map[string]int{
 				"workflow": 6, // 工作流优先级高
 				"rag":      3, // RAG 优先级中
+				"webhook":  2,
+				"export":   1, // 手稿导出耗时长，低优先级
 				"default":  1,
 			},
+			// Webhook 投递按其退避策略重试，其余任务沿用 asynq 默认策略
+			RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
+				if task.Type() == tasks.TypeDeliverWebhook {
+					return notification.WebhookRetryDelay(n + 1)
+				}
+				return asynq.DefaultRetryDelayFunc(n, err, task)
+			},
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
 				logger.Error("任务执行失败",
 					zap.String("type", task.Type()),
@@ -58,6 +71,18 @@ func NewServer(
 	workflowHandler := handlers.NewWorkflowHandler(workflowEngine, logger)
 	mux.HandleFunc(tasks.TypeExecuteWorkflow, workflowHandler.HandleExecuteWorkflow)
 
+	// 注册 Webhook 投递处理器
+	if webhookService != nil {
+		webhookHandler := handlers.NewWebhookHandler(webhookService, logger)
+		mux.HandleFunc(tasks.TypeDeliverWebhook, webhookHandler.HandleDeliverWebhook)
+	}
+
+	// 注册手稿导出处理器
+	if manuscriptExporter != nil {
+		exportHandler := handlers.NewManuscriptExportHandler(manuscriptExporter, logger)
+		mux.HandleFunc(tasks.TypeExportManuscript, exportHandler.HandleExportManuscript)
+	}
+
 	return &Server{
 		server: srv,
 		mux:    mux,
@@ -4,6 +4,8 @@ package tasks
 const (
 	TypeProcessDocument  = "rag:process_document"
 	TypeExecuteWorkflow  = "workflow:execute"
+	TypeDeliverWebhook   = "webhook:deliver"
+	TypeExportManuscript = "workspace:export_manuscript"
 )
 
 // ProcessDocumentPayload RAG文档处理任务载荷
@@ -19,3 +21,13 @@ type ExecuteWorkflowPayload struct {
 	UserID      string         `json:"user_id"`
 	Input       map[string]any `json:"input"`
 }
+
+// DeliverWebhookPayload Webhook 投递任务载荷
+type DeliverWebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+// ExportManuscriptPayload 作品手稿导出任务载荷
+type ExportManuscriptPayload struct {
+	JobID string `json:"job_id"`
+}
@@ -10,7 +10,8 @@ type ApprovalEvent struct {
 	ApprovalID   string
 	TenantID     string
 	ExecutionID  string
-	Status       string
+	Status       string // pending、stage_approved、escalated、approved、rejected、timeout
+	Stage        int    // 多级审批的阶段序号
 	ApprovedBy   string
 	AutoApproved bool
 	Comment      string
@@ -26,6 +26,7 @@ type Manager struct {
 	resolver TargetResolver
 	logger   *zap.Logger
 	eventBus *ApprovalEventBus
+	rules    *ApprovalRuleEngine
 }
 
 // ManagerOption 自定义配置
@@ -46,6 +47,11 @@ func WithEventBus(bus *ApprovalEventBus) ManagerOption {
 	return func(m *Manager) { m.eventBus = bus }
 }
 
+// WithRuleEngine 注入审批规则引擎，步骤未配置审批阶段时按匹配规则生成
+func WithRuleEngine(engine *ApprovalRuleEngine) ManagerOption {
+	return func(m *Manager) { m.rules = engine }
+}
+
 // WithManagerLogger 注入自定义日志器
 func WithManagerLogger(l *zap.Logger) ManagerOption {
 	return func(m *Manager) { m.logger = l }
@@ -65,6 +71,11 @@ func NewManager(db *gorm.DB, opts ...ManagerOption) *Manager {
 	return mgr
 }
 
+// AutoMigrate 迁移审批相关表
+func (m *Manager) AutoMigrate() error {
+	return m.db.AutoMigrate(&workflowpkg.ApprovalRequest{}, &workflowpkg.ApprovalDecision{}, &workflowpkg.ApprovalDelegation{})
+}
+
 // SetNotifier 设置通知器
 func (m *Manager) SetNotifier(notifier *notification.MultiNotifier) {
 	m.notifier = notifier
@@ -78,7 +89,16 @@ func (m *Manager) SetTargetResolver(resolver TargetResolver) {
 // CreateApprovalRequest 创建审批请求
 func (m *Manager) CreateApprovalRequest(ctx context.Context, req *ApprovalRequestInput) (*workflowpkg.ApprovalRequest, error) {
 	now := time.Now().UTC()
-	expiresAt := now.Add(time.Duration(req.TimeoutSeconds) * time.Second)
+	stages := req.Stages
+	if len(stages) == 0 {
+		stages = m.stagesFromRules(ctx, req)
+	}
+	stages = normalizeStages(stages, req.TimeoutSeconds)
+	timeout := req.TimeoutSeconds
+	if len(stages) > 0 {
+		timeout = stages[0].TimeoutSeconds
+	}
+	expiresAt := now.Add(time.Duration(timeout) * time.Second)
 
 	approval := &workflowpkg.ApprovalRequest{
 		ID:             uuid.New().String(),
@@ -95,9 +115,13 @@ func (m *Manager) CreateApprovalRequest(ctx context.Context, req *ApprovalReques
 		NotifiedAt:     &now,
 		TimeoutSeconds: req.TimeoutSeconds,
 		ExpiresAt:      &expiresAt,
+		Stages:         stages,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
+	if len(stages) > 0 {
+		approval.NotifyTargets = m.stageNotifyTargets(ctx, approval)
+	}
 
 	if err := m.db.WithContext(ctx).Create(approval).Error; err != nil {
 		return nil, fmt.Errorf("创建审批请求失败: %w", err)
@@ -117,62 +141,71 @@ func (m *Manager) CreateApprovalRequest(ctx context.Context, req *ApprovalReques
 }
 
 // ApproveRequest 批准请求
+// 多级审批时记录审批意见，当前阶段达到通过人数后进入下一阶段，最后一个阶段通过后整体批准
 func (m *Manager) ApproveRequest(ctx context.Context, approvalID, approvedBy, comment string) error {
 	approval, err := m.loadPendingApproval(ctx, approvalID)
 	if err != nil {
 		return err
 	}
-	now := time.Now().UTC()
-	updates := map[string]any{
-		"status":      "approved",
-		"approved_by": approvedBy,
-		"comment":     comment,
-		"resolved_at": now,
-		"updated_at":  now,
+	if len(approval.Stages) > 0 {
+		return m.decideStage(ctx, approval, approvedBy, "approved", comment)
 	}
-	if err := m.db.WithContext(ctx).Model(&approval).Updates(updates).Error; err != nil {
-		return fmt.Errorf("批准请求失败: %w", err)
-	}
-	m.decrementPendingGaugeWithTenant(approval.TenantID)
-	m.recordDecisionMetric(approval.TenantID, "approved", "manual")
-	m.publishEvent(ApprovalEvent{
-		ApprovalID:   approval.ID,
-		TenantID:     approval.TenantID,
-		ExecutionID:  approval.ExecutionID,
-		Status:       "approved",
-		ApprovedBy:   approvedBy,
-		AutoApproved: false,
-		Comment:      comment,
-		OccurredAt:   now,
-	})
-	return nil
+	return m.resolveApproval(ctx, approval, "approved", approvedBy, comment, "manual")
 }
 
 // RejectRequest 拒绝请求
+// 多级审批时当前阶段剩余审批人已不足以达到通过人数才整体拒绝
 func (m *Manager) RejectRequest(ctx context.Context, approvalID, approvedBy, comment string) error {
 	approval, err := m.loadPendingApproval(ctx, approvalID)
 	if err != nil {
 		return err
 	}
-	now := time.Now().UTC()
-	updates := map[string]any{
-		"status":      "rejected",
-		"approved_by": approvedBy,
-		"comment":     comment,
-		"resolved_at": now,
-		"updated_at":  now,
+	if len(approval.Stages) > 0 {
+		return m.decideStage(ctx, approval, approvedBy, "rejected", comment)
 	}
-	if err := m.db.WithContext(ctx).Model(&approval).Updates(updates).Error; err != nil {
-		return fmt.Errorf("拒绝请求失败: %w", err)
+	return m.resolveApproval(ctx, approval, "rejected", approvedBy, comment, "manual")
+}
+
+// resolveApproval 将仍处于 pending 的审批请求置为终态
+func (m *Manager) resolveApproval(ctx context.Context, approval *workflowpkg.ApprovalRequest, status, decidedBy, comment, decisionType string) error {
+	now := time.Now().UTC()
+	// jsonb 字段需经结构体更新才会应用序列化器，因此用 Select 指定列
+	row := &workflowpkg.ApprovalRequest{Status: status, ResolvedAt: &now, UpdatedAt: now}
+	columns := []string{"status", "resolved_at", "updated_at"}
+	if decidedBy != "" {
+		row.ApprovedBy = &decidedBy
+		columns = append(columns, "approved_by")
+	}
+	if comment != "" {
+		row.Comment = comment
+		columns = append(columns, "comment")
+	}
+	if len(approval.Stages) > 0 && approval.CurrentStage < len(approval.Stages) {
+		stage := &approval.Stages[approval.CurrentStage]
+		stage.Status = status
+		stage.CompletedAt = &now
+		row.Stages = approval.Stages
+		columns = append(columns, "stages")
+	}
+	result := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalRequest{}).
+		Where("id = ? AND status = ?", approval.ID, "pending").
+		Select(columns).
+		Updates(row)
+	if result.Error != nil {
+		return fmt.Errorf("更新审批状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("审批请求不存在或已处理")
 	}
 	m.decrementPendingGaugeWithTenant(approval.TenantID)
-	m.recordDecisionMetric(approval.TenantID, "rejected", "manual")
+	m.recordDecisionMetric(approval.TenantID, status, decisionType)
 	m.publishEvent(ApprovalEvent{
 		ApprovalID:   approval.ID,
 		TenantID:     approval.TenantID,
 		ExecutionID:  approval.ExecutionID,
-		Status:       "rejected",
-		ApprovedBy:   approvedBy,
+		Status:       status,
+		Stage:        approval.CurrentStage,
+		ApprovedBy:   decidedBy,
 		AutoApproved: false,
 		Comment:      comment,
 		OccurredAt:   now,
@@ -223,47 +256,56 @@ func (m *Manager) TimeoutRequest(ctx context.Context, approvalID string) error {
 	if err != nil {
 		return err
 	}
-	now := time.Now().UTC()
-	updates := map[string]any{
-		"status":      "timeout",
-		"resolved_at": now,
-		"updated_at":  now,
-	}
-	if err := m.db.WithContext(ctx).Model(&approval).Updates(updates).Error; err != nil {
-		return fmt.Errorf("标记超时失败: %w", err)
-	}
-	m.decrementPendingGaugeWithTenant(approval.TenantID)
-	m.recordDecisionMetric(approval.TenantID, "timeout", "system")
-	m.publishEvent(ApprovalEvent{
-		ApprovalID:  approval.ID,
-		TenantID:    approval.TenantID,
-		ExecutionID: approval.ExecutionID,
-		Status:      "timeout",
-		OccurredAt:  now,
-	})
-	return nil
+	return m.resolveApproval(ctx, approval, "timeout", "", "", "system")
 }
 
 // CheckExpiredApprovals 检查并处理过期的审批请求
+// 多级审批的阶段超时后依次升级到 EscalateTo 中的审批人，升级耗尽后按阶段的 TimeoutAction 处理
 func (m *Manager) CheckExpiredApprovals(ctx context.Context) error {
-	now := time.Now().UTC()
-
-	updates := map[string]any{
-		"status":      "timeout",
-		"resolved_at": now,
-		"updated_at":  now,
-	}
-
+	var expired []*workflowpkg.ApprovalRequest
 	if err := m.db.WithContext(ctx).
-		Model(&workflowpkg.ApprovalRequest{}).
-		Where("status = ? AND expires_at < ?", "pending", now).
-		Updates(updates).Error; err != nil {
-		return fmt.Errorf("更新过期审批请求失败: %w", err)
+		Where("status = ? AND expires_at < ?", "pending", time.Now().UTC()).
+		Order("expires_at ASC").
+		Limit(500).
+		Find(&expired).Error; err != nil {
+		return fmt.Errorf("查询过期审批请求失败: %w", err)
 	}
 
+	for _, approval := range expired {
+		var err error
+		if len(approval.Stages) > 0 {
+			err = m.handleStageTimeout(ctx, approval)
+		} else {
+			err = m.resolveApproval(ctx, approval, "timeout", "", "", "system")
+		}
+		if err != nil && m.logger != nil {
+			m.logger.Warn("处理过期审批请求失败", zap.String("approvalId", approval.ID), zap.Error(err))
+		}
+	}
 	return nil
 }
 
+// StartExpiryChecker 定时处理过期审批（升级或超时），ctx 取消后停止
+func (m *Manager) StartExpiryChecker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.CheckExpiredApprovals(ctx); err != nil && m.logger != nil {
+					m.logger.Warn("处理过期审批失败", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
 // ResendNotification 允许管理员手动重发通知
 func (m *Manager) ResendNotification(ctx context.Context, tenantID, approvalID string) error {
 	var approval workflowpkg.ApprovalRequest
@@ -737,6 +779,7 @@ type ApprovalRequestInput struct {
 	NotifyChannels []string
 	NotifyTargets  map[string][]string
 	TimeoutSeconds int
+	Stages         []workflowpkg.ApprovalStage // 多级审批阶段，空表示单人审批
 }
 
 func formatExpiresAt(ts *time.Time) string {
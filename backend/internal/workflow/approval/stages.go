@@ -0,0 +1,502 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	workflowpkg "backend/internal/workflow"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrNotApprover 当前用户不是该阶段的审批人，也不是其受托人
+	ErrNotApprover = errors.New("当前用户不是该审批阶段的审批人")
+	// ErrAlreadyDecided 当前用户（或其代理的委托人）已在该阶段给出意见
+	ErrAlreadyDecided = errors.New("已在当前审批阶段提交过审批意见")
+	// ErrNoEligibleApprovers 阶段配置了审批人但无法解析出任何用户（如角色下没有成员）
+	ErrNoEligibleApprovers = errors.New("审批阶段配置的审批人无法解析为任何用户，请检查审批配置")
+)
+
+// ApproverResolver 将审批人描述（user:<id>、role:<code>）展开为用户 ID
+// TargetResolver 实现该接口时用于角色审批人的资格校验与通知
+type ApproverResolver interface {
+	ResolveApprovers(ctx context.Context, tenantID string, specs []string) []string
+}
+
+// DelegationInput 创建审批委托的参数
+type DelegationInput struct {
+	TenantID    string
+	DelegatorID string
+	DelegateID  string
+	StartsAt    time.Time // 为空表示立即生效
+	EndsAt      *time.Time
+	Reason      string
+}
+
+// CreateDelegation 创建审批委托：有效期内受托人可代委托人审批
+func (m *Manager) CreateDelegation(ctx context.Context, input *DelegationInput) (*workflowpkg.ApprovalDelegation, error) {
+	if input.DelegatorID == "" || input.DelegateID == "" {
+		return nil, fmt.Errorf("委托人与受托人不能为空")
+	}
+	if input.DelegatorID == input.DelegateID {
+		return nil, fmt.Errorf("不能委托给自己")
+	}
+	startsAt := input.StartsAt
+	if startsAt.IsZero() {
+		startsAt = time.Now().UTC()
+	}
+	if input.EndsAt != nil && !input.EndsAt.After(startsAt) {
+		return nil, fmt.Errorf("委托结束时间必须晚于开始时间")
+	}
+	delegation := &workflowpkg.ApprovalDelegation{
+		ID:          uuid.New().String(),
+		TenantID:    input.TenantID,
+		DelegatorID: input.DelegatorID,
+		DelegateID:  input.DelegateID,
+		StartsAt:    startsAt,
+		EndsAt:      input.EndsAt,
+		Reason:      input.Reason,
+	}
+	if err := m.db.WithContext(ctx).Create(delegation).Error; err != nil {
+		return nil, fmt.Errorf("创建审批委托失败: %w", err)
+	}
+	return delegation, nil
+}
+
+// RevokeDelegation 撤销委托（仅委托人本人）
+func (m *Manager) RevokeDelegation(ctx context.Context, tenantID, delegatorID, delegationID string) error {
+	result := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalDelegation{}).
+		Where("id = ? AND tenant_id = ? AND delegator_id = ? AND revoked_at IS NULL", delegationID, tenantID, delegatorID).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return fmt.Errorf("撤销审批委托失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("审批委托不存在或已撤销")
+	}
+	return nil
+}
+
+// ListDelegations 列出用户作为委托人或受托人的委托
+func (m *Manager) ListDelegations(ctx context.Context, tenantID, userID string) ([]*workflowpkg.ApprovalDelegation, error) {
+	var delegations []*workflowpkg.ApprovalDelegation
+	if err := m.db.WithContext(ctx).
+		Where("tenant_id = ? AND (delegator_id = ? OR delegate_id = ?)", tenantID, userID, userID).
+		Order("created_at DESC").
+		Find(&delegations).Error; err != nil {
+		return nil, fmt.Errorf("查询审批委托失败: %w", err)
+	}
+	return delegations, nil
+}
+
+// ListDecisions 列出审批请求的各阶段审批意见
+func (m *Manager) ListDecisions(ctx context.Context, tenantID, approvalID string) ([]*workflowpkg.ApprovalDecision, error) {
+	var decisions []*workflowpkg.ApprovalDecision
+	if err := m.db.WithContext(ctx).
+		Where("tenant_id = ? AND approval_id = ?", tenantID, approvalID).
+		Order("stage ASC, created_at ASC").
+		Find(&decisions).Error; err != nil {
+		return nil, fmt.Errorf("查询审批意见失败: %w", err)
+	}
+	return decisions, nil
+}
+
+// StagesFromRuleAction 将审批规则的 assign / escalate 动作转换为审批阶段
+// Sequential 时每个指定审批人单独成为一个阶段，否则合并为一个会签阶段
+func StagesFromRuleAction(actionType string, action ApprovalAction) []workflowpkg.ApprovalStage {
+	if actionType != "assign" && actionType != "escalate" {
+		return nil
+	}
+	var approvers []string
+	for _, id := range action.AssignTo {
+		approvers = append(approvers, approverSpec(id, "user"))
+	}
+	if action.AssignToRole != "" {
+		approvers = append(approvers, approverSpec(action.AssignToRole, "role"))
+	}
+	var escalateTo []string
+	if action.EscalateTo != "" {
+		escalateTo = []string{approverSpec(action.EscalateTo, "role")}
+	}
+	if len(approvers) == 0 && len(escalateTo) == 0 {
+		return nil
+	}
+
+	timeout := action.TimeoutMinutes * 60
+	if action.EscalateAfter > 0 {
+		timeout = action.EscalateAfter * 60
+	}
+	timeoutAction := ""
+	if action.TimeoutAction == "approve" || action.TimeoutAction == "reject" {
+		timeoutAction = action.TimeoutAction
+	}
+	newStage := func(name string, approvers []string, required int) workflowpkg.ApprovalStage {
+		return workflowpkg.ApprovalStage{
+			Name:              name,
+			Approvers:         approvers,
+			RequiredApprovals: required,
+			TimeoutSeconds:    timeout,
+			EscalateTo:        escalateTo,
+			TimeoutAction:     timeoutAction,
+		}
+	}
+
+	if action.Sequential && len(approvers) > 1 {
+		stages := make([]workflowpkg.ApprovalStage, 0, len(approvers))
+		for i, approver := range approvers {
+			stages = append(stages, newStage(fmt.Sprintf("stage-%d", i+1), []string{approver}, 1))
+		}
+		return stages
+	}
+	return []workflowpkg.ApprovalStage{newStage("stage-1", approvers, action.RequiredApprovers)}
+}
+
+// stagesFromRules 按租户审批规则生成审批阶段
+func (m *Manager) stagesFromRules(ctx context.Context, req *ApprovalRequestInput) []workflowpkg.ApprovalStage {
+	if m.rules == nil {
+		return nil
+	}
+	result, err := m.rules.Evaluate(ctx, req.TenantID, req.WorkflowID, req.StepOutput)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Warn("评估审批规则失败", zap.String("tenantId", req.TenantID), zap.Error(err))
+		}
+		return nil
+	}
+	if !result.Matched {
+		return nil
+	}
+	return StagesFromRuleAction(result.ActionType, result.Action)
+}
+
+// decideStage 记录当前阶段的审批意见并计票
+func (m *Manager) decideStage(ctx context.Context, approval *workflowpkg.ApprovalRequest, userID, decision, comment string) error {
+	if approval.CurrentStage >= len(approval.Stages) {
+		return fmt.Errorf("审批阶段状态异常")
+	}
+	stage := &approval.Stages[approval.CurrentStage]
+	eligible := m.resolveApprovers(ctx, approval.TenantID, stage.Approvers)
+	if len(stage.Approvers) > 0 && len(eligible) == 0 {
+		// 不能退化为任何人都可审批
+		return fmt.Errorf("%w: 阶段 %s", ErrNoEligibleApprovers, stage.Name)
+	}
+	approverID, err := m.actingApprover(ctx, approval, userID, eligible)
+	if err != nil {
+		return err
+	}
+
+	record := &workflowpkg.ApprovalDecision{
+		ID:         uuid.New().String(),
+		TenantID:   approval.TenantID,
+		ApprovalID: approval.ID,
+		Stage:      approval.CurrentStage,
+		ApproverID: approverID,
+		DecidedBy:  userID,
+		Decision:   decision,
+		Comment:    comment,
+	}
+	if err := m.db.WithContext(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("记录审批意见失败: %w", err)
+	}
+
+	approvals, rejections, err := m.tallyStage(ctx, approval.ID, approval.CurrentStage)
+	if err != nil {
+		return err
+	}
+	required := requiredApprovals(stage, len(eligible))
+	switch {
+	case approvals >= required:
+		return m.completeStage(ctx, approval, userID, comment, "manual")
+	case decision == "rejected" && (len(eligible) == 0 || rejections > len(eligible)-required):
+		// 剩余审批人已不足以达到通过人数
+		return m.resolveApproval(ctx, approval, "rejected", userID, comment, "manual")
+	}
+	return nil
+}
+
+// completeStage 当前阶段通过：进入下一阶段，或在最后一个阶段时整体批准
+func (m *Manager) completeStage(ctx context.Context, approval *workflowpkg.ApprovalRequest, decidedBy, comment, decisionType string) error {
+	next := approval.CurrentStage + 1
+	if next >= len(approval.Stages) {
+		return m.resolveApproval(ctx, approval, "approved", decidedBy, comment, decisionType)
+	}
+
+	now := time.Now().UTC()
+	stage := &approval.Stages[approval.CurrentStage]
+	stage.Status = "approved"
+	stage.CompletedAt = &now
+	completed := approval.CurrentStage
+	approval.CurrentStage = next
+	approval.EscalationLevel = 0
+	expiresAt := now.Add(time.Duration(approval.Stages[next].TimeoutSeconds) * time.Second)
+	approval.ExpiresAt = &expiresAt
+	approval.NotifyTargets = m.stageNotifyTargets(ctx, approval)
+
+	result := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalRequest{}).
+		Where("id = ? AND status = ? AND current_stage = ?", approval.ID, "pending", completed).
+		Select("stages", "current_stage", "escalation_level", "expires_at", "notify_targets", "updated_at").
+		Updates(&workflowpkg.ApprovalRequest{
+			Stages:          approval.Stages,
+			CurrentStage:    next,
+			EscalationLevel: 0,
+			ExpiresAt:       &expiresAt,
+			NotifyTargets:   approval.NotifyTargets,
+			UpdatedAt:       now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("推进审批阶段失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil // 并发审批已推进该阶段
+	}
+
+	m.recordDecisionMetric(approval.TenantID, "stage_approved", decisionType)
+	m.publishEvent(ApprovalEvent{
+		ApprovalID:  approval.ID,
+		TenantID:    approval.TenantID,
+		ExecutionID: approval.ExecutionID,
+		Status:      "stage_approved",
+		Stage:       completed,
+		ApprovedBy:  decidedBy,
+		Comment:     comment,
+		OccurredAt:  now,
+	})
+	go m.dispatchNotification(approval.ID, "stage")
+	return nil
+}
+
+// handleStageTimeout 阶段超时：升级到下一个升级审批人，升级耗尽后按 TimeoutAction 处理
+func (m *Manager) handleStageTimeout(ctx context.Context, approval *workflowpkg.ApprovalRequest) error {
+	if approval.CurrentStage >= len(approval.Stages) {
+		return m.resolveApproval(ctx, approval, "timeout", "", "", "system")
+	}
+	stage := &approval.Stages[approval.CurrentStage]
+	if approval.EscalationLevel >= len(stage.EscalateTo) {
+		switch stage.TimeoutAction {
+		case "approve":
+			return m.completeStage(ctx, approval, "", "审批超时，自动通过", "timeout")
+		case "reject":
+			return m.resolveApproval(ctx, approval, "rejected", "", "审批超时，自动拒绝", "timeout")
+		default:
+			return m.resolveApproval(ctx, approval, "timeout", "", "", "system")
+		}
+	}
+
+	now := time.Now().UTC()
+	level := approval.EscalationLevel
+	stage.Approvers = dedupStrings(append(stage.Approvers, stage.EscalateTo[level]))
+	approval.EscalationLevel = level + 1
+	expiresAt := now.Add(time.Duration(stage.TimeoutSeconds) * time.Second)
+	approval.ExpiresAt = &expiresAt
+	approval.NotifyTargets = m.stageNotifyTargets(ctx, approval)
+
+	result := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalRequest{}).
+		Where("id = ? AND status = ? AND current_stage = ? AND escalation_level = ?", approval.ID, "pending", approval.CurrentStage, level).
+		Select("stages", "escalation_level", "expires_at", "notify_targets", "updated_at").
+		Updates(&workflowpkg.ApprovalRequest{
+			Stages:          approval.Stages,
+			EscalationLevel: level + 1,
+			ExpiresAt:       &expiresAt,
+			NotifyTargets:   approval.NotifyTargets,
+			UpdatedAt:       now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("升级审批失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	m.recordDecisionMetric(approval.TenantID, "escalated", "system")
+	m.publishEvent(ApprovalEvent{
+		ApprovalID:  approval.ID,
+		TenantID:    approval.TenantID,
+		ExecutionID: approval.ExecutionID,
+		Status:      "escalated",
+		Stage:       approval.CurrentStage,
+		OccurredAt:  now,
+	})
+	go m.dispatchNotification(approval.ID, "escalation")
+	return nil
+}
+
+// actingApprover 确定本次审批的计票身份：本人是审批人时为本人，否则为其有效委托人中尚未表态的一位
+func (m *Manager) actingApprover(ctx context.Context, approval *workflowpkg.ApprovalRequest, userID string, eligible []string) (string, error) {
+	var candidates []string
+	if len(eligible) == 0 || containsString(eligible, userID) {
+		candidates = append(candidates, userID)
+	}
+	if len(eligible) > 0 {
+		for _, delegator := range m.activeDelegators(ctx, approval.TenantID, userID) {
+			if containsString(eligible, delegator) {
+				candidates = append(candidates, delegator)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", ErrNotApprover
+	}
+
+	var decided []string
+	if err := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalDecision{}).
+		Where("approval_id = ? AND stage = ?", approval.ID, approval.CurrentStage).
+		Pluck("approver_id", &decided).Error; err != nil {
+		return "", fmt.Errorf("查询审批意见失败: %w", err)
+	}
+	for _, candidate := range candidates {
+		if !containsString(decided, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", ErrAlreadyDecided
+}
+
+func (m *Manager) tallyStage(ctx context.Context, approvalID string, stage int) (approvals, rejections int, err error) {
+	var rows []struct {
+		Decision string
+		Count    int
+	}
+	if err := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalDecision{}).
+		Select("decision, COUNT(*) AS count").
+		Where("approval_id = ? AND stage = ?", approvalID, stage).
+		Group("decision").
+		Scan(&rows).Error; err != nil {
+		return 0, 0, fmt.Errorf("统计审批意见失败: %w", err)
+	}
+	for _, row := range rows {
+		switch row.Decision {
+		case "approved":
+			approvals = row.Count
+		case "rejected":
+			rejections = row.Count
+		}
+	}
+	return approvals, rejections, nil
+}
+
+// resolveApprovers 展开审批人描述为用户 ID；未配置 ApproverResolver 时仅识别 user:<id> 与裸 ID
+func (m *Manager) resolveApprovers(ctx context.Context, tenantID string, specs []string) []string {
+	if len(specs) == 0 {
+		return nil
+	}
+	if resolver, ok := m.resolver.(ApproverResolver); ok {
+		return resolver.ResolveApprovers(ctx, tenantID, specs)
+	}
+	var ids []string
+	for _, spec := range specs {
+		kind, value, found := strings.Cut(strings.TrimSpace(spec), ":")
+		switch {
+		case !found:
+			ids = append(ids, kind)
+		case strings.EqualFold(kind, "user"):
+			ids = append(ids, value)
+		}
+	}
+	return dedupStrings(ids)
+}
+
+// activeDelegators 返回当前委托给 delegateID 的委托人
+func (m *Manager) activeDelegators(ctx context.Context, tenantID, delegateID string) []string {
+	now := time.Now().UTC()
+	var ids []string
+	if err := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalDelegation{}).
+		Where("tenant_id = ? AND delegate_id = ? AND revoked_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", tenantID, delegateID, now, now).
+		Pluck("delegator_id", &ids).Error; err != nil && m.logger != nil {
+		m.logger.Warn("查询审批委托失败", zap.String("delegateId", delegateID), zap.Error(err))
+	}
+	return ids
+}
+
+// activeDelegates 返回审批人当前的受托人
+func (m *Manager) activeDelegates(ctx context.Context, tenantID string, delegatorIDs []string) []string {
+	if len(delegatorIDs) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	var ids []string
+	if err := m.db.WithContext(ctx).Model(&workflowpkg.ApprovalDelegation{}).
+		Where("tenant_id = ? AND delegator_id IN ? AND revoked_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", tenantID, delegatorIDs, now, now).
+		Pluck("delegate_id", &ids).Error; err != nil && m.logger != nil {
+		m.logger.Warn("查询审批受托人失败", zap.Error(err))
+	}
+	return ids
+}
+
+// stageNotifyTargets 将站内信与邮件的通知目标替换为当前阶段审批人及其受托人
+func (m *Manager) stageNotifyTargets(ctx context.Context, approval *workflowpkg.ApprovalRequest) map[string][]string {
+	stage := approval.Stages[approval.CurrentStage]
+	targets := append([]string{}, stage.Approvers...)
+	for _, delegate := range m.activeDelegates(ctx, approval.TenantID, m.resolveApprovers(ctx, approval.TenantID, stage.Approvers)) {
+		targets = append(targets, "user:"+delegate)
+	}
+	targets = dedupStrings(targets)
+
+	channels := approval.NotifyChannels
+	if len(channels) == 0 {
+		channels = []string{"websocket"}
+	}
+	result := make(map[string][]string, len(approval.NotifyTargets)+len(channels))
+	for channel, values := range approval.NotifyTargets {
+		result[channel] = values
+	}
+	for _, channel := range channels {
+		if channel == "websocket" || channel == "email" {
+			result[channel] = targets
+		}
+	}
+	return result
+}
+
+// normalizeStages 补全阶段默认值
+func normalizeStages(stages []workflowpkg.ApprovalStage, defaultTimeout int) []workflowpkg.ApprovalStage {
+	if len(stages) == 0 {
+		return nil
+	}
+	normalized := make([]workflowpkg.ApprovalStage, len(stages))
+	for i, stage := range stages {
+		if stage.Name == "" {
+			stage.Name = fmt.Sprintf("stage-%d", i+1)
+		}
+		if stage.RequiredApprovals <= 0 {
+			stage.RequiredApprovals = 1
+		}
+		if stage.TimeoutSeconds <= 0 {
+			stage.TimeoutSeconds = defaultTimeout
+		}
+		stage.Approvers = dedupStrings(stage.Approvers)
+		stage.Status = "pending"
+		stage.CompletedAt = nil
+		normalized[i] = stage
+	}
+	return normalized
+}
+
+// requiredApprovals 阶段通过所需人数，不超过可审批人数，避免无法达成
+func requiredApprovals(stage *workflowpkg.ApprovalStage, eligible int) int {
+	required := max(stage.RequiredApprovals, 1)
+	if eligible > 0 && required > eligible {
+		required = eligible
+	}
+	return required
+}
+
+func approverSpec(value, defaultKind string) string {
+	if strings.Contains(value, ":") {
+		return value
+	}
+	return defaultKind + ":" + value
+}
+
+func containsString(items []string, target string) bool {
+	for _, item := range items {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
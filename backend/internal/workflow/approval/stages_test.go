@@ -0,0 +1,163 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/logger"
+	workflowpkg "backend/internal/workflow"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newStagedTestManager(t *testing.T) (*Manager, *gorm.DB) {
+	t.Helper()
+	logger.Init("error", "console", "stdout")
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	mgr := NewManager(db)
+	require.NoError(t, mgr.AutoMigrate())
+	return mgr, db
+}
+
+func createStagedApproval(t *testing.T, mgr *Manager, stages ...workflowpkg.ApprovalStage) *workflowpkg.ApprovalRequest {
+	t.Helper()
+	approval, err := mgr.CreateApprovalRequest(context.Background(), &ApprovalRequestInput{
+		TenantID:       "tenant-1",
+		ExecutionID:    "exec-1",
+		WorkflowID:     "wf-1",
+		StepID:         "review",
+		Type:           "required",
+		RequestedBy:    "author",
+		TimeoutSeconds: 3600,
+		Stages:         stages,
+	})
+	require.NoError(t, err)
+	return approval
+}
+
+func loadApproval(t *testing.T, db *gorm.DB, id string) *workflowpkg.ApprovalRequest {
+	t.Helper()
+	var approval workflowpkg.ApprovalRequest
+	require.NoError(t, db.Where("id = ?", id).First(&approval).Error)
+	return &approval
+}
+
+func TestStagedApprovalQuorumThenNextStage(t *testing.T) {
+	mgr, db := newStagedTestManager(t)
+	ctx := context.Background()
+	approval := createStagedApproval(t, mgr,
+		workflowpkg.ApprovalStage{Name: "editor", Approvers: []string{"user:ed-1", "user:ed-2", "user:ed-3"}, RequiredApprovals: 2},
+		workflowpkg.ApprovalStage{Name: "chief", Approvers: []string{"user:chief"}},
+	)
+	require.Equal(t, []string{"user:ed-1", "user:ed-2", "user:ed-3"}, approval.NotifyTargets["websocket"])
+
+	require.NoError(t, mgr.ApproveRequest(ctx, approval.ID, "ed-1", "ok"))
+	require.ErrorIs(t, mgr.ApproveRequest(ctx, approval.ID, "ed-1", "again"), ErrAlreadyDecided)
+	require.ErrorIs(t, mgr.ApproveRequest(ctx, approval.ID, "chief", "too early"), ErrNotApprover)
+	require.Equal(t, 0, loadApproval(t, db, approval.ID).CurrentStage)
+
+	require.NoError(t, mgr.ApproveRequest(ctx, approval.ID, "ed-2", "ok"))
+	current := loadApproval(t, db, approval.ID)
+	require.Equal(t, "pending", current.Status)
+	require.Equal(t, 1, current.CurrentStage)
+	require.Equal(t, "approved", current.Stages[0].Status)
+	require.Equal(t, []string{"user:chief"}, current.NotifyTargets["websocket"])
+
+	require.NoError(t, mgr.ApproveRequest(ctx, approval.ID, "chief", "publish"))
+	require.Equal(t, "approved", loadApproval(t, db, approval.ID).Status)
+
+	decisions, err := mgr.ListDecisions(ctx, "tenant-1", approval.ID)
+	require.NoError(t, err)
+	require.Len(t, decisions, 3)
+}
+
+func TestStagedApprovalRejectsWhenQuorumUnreachable(t *testing.T) {
+	mgr, db := newStagedTestManager(t)
+	ctx := context.Background()
+	approval := createStagedApproval(t, mgr,
+		workflowpkg.ApprovalStage{Approvers: []string{"user:a", "user:b", "user:c"}, RequiredApprovals: 2},
+	)
+
+	require.NoError(t, mgr.RejectRequest(ctx, approval.ID, "a", "no"))
+	require.Equal(t, "pending", loadApproval(t, db, approval.ID).Status)
+	require.NoError(t, mgr.RejectRequest(ctx, approval.ID, "b", "no"))
+	require.Equal(t, "rejected", loadApproval(t, db, approval.ID).Status)
+}
+
+func TestStageWithUnresolvableApproversRefusesDecisions(t *testing.T) {
+	mgr, db := newStagedTestManager(t)
+	ctx := context.Background()
+	approval := createStagedApproval(t, mgr, workflowpkg.ApprovalStage{Name: "legal", Approvers: []string{"role:legal"}})
+
+	require.ErrorIs(t, mgr.ApproveRequest(ctx, approval.ID, "anyone", "ok"), ErrNoEligibleApprovers)
+	require.ErrorIs(t, mgr.RejectRequest(ctx, approval.ID, "anyone", "no"), ErrNoEligibleApprovers)
+	require.Equal(t, "pending", loadApproval(t, db, approval.ID).Status)
+
+	decisions, err := mgr.ListDecisions(ctx, "tenant-1", approval.ID)
+	require.NoError(t, err)
+	require.Empty(t, decisions)
+}
+
+func TestDelegateApprovesOnBehalfOfApprover(t *testing.T) {
+	mgr, db := newStagedTestManager(t)
+	ctx := context.Background()
+	_, err := mgr.CreateDelegation(ctx, &DelegationInput{TenantID: "tenant-1", DelegatorID: "legal", DelegateID: "deputy"})
+	require.NoError(t, err)
+	approval := createStagedApproval(t, mgr, workflowpkg.ApprovalStage{Name: "legal", Approvers: []string{"user:legal"}})
+	require.Contains(t, approval.NotifyTargets["websocket"], "user:deputy")
+
+	require.NoError(t, mgr.ApproveRequest(ctx, approval.ID, "deputy", "on behalf of legal"))
+	require.Equal(t, "approved", loadApproval(t, db, approval.ID).Status)
+	decisions, err := mgr.ListDecisions(ctx, "tenant-1", approval.ID)
+	require.NoError(t, err)
+	require.Equal(t, "legal", decisions[0].ApproverID)
+	require.Equal(t, "deputy", decisions[0].DecidedBy)
+}
+
+func TestStageTimeoutEscalatesBeforeTimingOut(t *testing.T) {
+	mgr, db := newStagedTestManager(t)
+	ctx := context.Background()
+	approval := createStagedApproval(t, mgr, workflowpkg.ApprovalStage{
+		Approvers:      []string{"user:editor"},
+		TimeoutSeconds: 60,
+		EscalateTo:     []string{"user:chief"},
+	})
+	expire := func() {
+		require.NoError(t, db.Model(&workflowpkg.ApprovalRequest{}).Where("id = ?", approval.ID).
+			Update("expires_at", time.Now().UTC().Add(-time.Minute)).Error)
+	}
+
+	expire()
+	require.NoError(t, mgr.CheckExpiredApprovals(ctx))
+	escalated := loadApproval(t, db, approval.ID)
+	require.Equal(t, "pending", escalated.Status)
+	require.Equal(t, 1, escalated.EscalationLevel)
+	require.Contains(t, escalated.Stages[0].Approvers, "user:chief")
+	require.True(t, escalated.ExpiresAt.After(time.Now()))
+
+	expire()
+	require.NoError(t, mgr.CheckExpiredApprovals(ctx))
+	require.Equal(t, "timeout", loadApproval(t, db, approval.ID).Status)
+}
+
+func TestStagesFromRuleAction(t *testing.T) {
+	stages := StagesFromRuleAction("assign", ApprovalAction{
+		AssignTo:      []string{"u1", "u2"},
+		Sequential:    true,
+		EscalateTo:    "chief_editor",
+		EscalateAfter: 30,
+	})
+	require.Len(t, stages, 2)
+	require.Equal(t, []string{"user:u2"}, stages[1].Approvers)
+	require.Equal(t, []string{"role:chief_editor"}, stages[0].EscalateTo)
+	require.Equal(t, 1800, stages[0].TimeoutSeconds)
+
+	quorum := StagesFromRuleAction("assign", ApprovalAction{AssignToRole: "editor", RequiredApprovers: 2})
+	require.Len(t, quorum, 1)
+	require.Equal(t, 2, quorum[0].RequiredApprovals)
+	require.Nil(t, StagesFromRuleAction("auto_approve", ApprovalAction{AssignTo: []string{"u1"}}))
+}
@@ -75,6 +75,16 @@ func (r *ConfigTargetResolver) Settings(ctx context.Context, tenantID string) *t
 	return r.loadApprovalSettings(ctx, tenantID)
 }
 
+// ResolveApprovers 将审批人描述（user:<id>、role:<code>）展开为用户 ID
+func (r *ConfigTargetResolver) ResolveApprovers(ctx context.Context, tenantID string, specs []string) []string {
+	scope := &workflowpkg.ApprovalRequest{TenantID: tenantID}
+	var ids []string
+	for _, spec := range specs {
+		ids = append(ids, r.expandTargetSpec(ctx, scope, "websocket", spec)...)
+	}
+	return dedupStrings(ids)
+}
+
 func (r *ConfigTargetResolver) collectChannelTargets(ctx context.Context, approval *workflowpkg.ApprovalRequest, channel string, settings *tenant.ApprovalSettings) []string {
 	sources := make([]string, 0, 4)
 	if approval.NotifyTargets != nil {
@@ -18,6 +18,7 @@ type ConditionTrigger struct {
 	ID          string          `json:"id" gorm:"primaryKey;size:36"`
 	TenantID    string          `json:"tenantId" gorm:"size:36;index"`
 	WorkflowID  string          `json:"workflowId" gorm:"size:36;index"`
+	WorkflowVersion int         `json:"workflowVersion" gorm:"default:0"` // 触发时固定使用的定义版本，0 表示跟随已发布版本
 	Name        string          `json:"name" gorm:"size:100"`
 	Description string          `json:"description" gorm:"size:500"`
 	Enabled     bool            `json:"enabled" gorm:"default:true"`
@@ -47,11 +48,17 @@ type TriggerAction struct {
 	Parameters map[string]interface{} `json:"parameters"` // 动作参数
 }
 
+// WorkflowLauncher 提交指定版本的工作流执行，返回执行 ID
+type WorkflowLauncher interface {
+	Launch(ctx context.Context, workflowID, tenantID, userID string, version int, input map[string]any) (string, error)
+}
+
 // ConditionTriggerService 条件触发器服务
 type ConditionTriggerService struct {
 	db          *gorm.DB
 	mu          sync.RWMutex
 	subscribers map[string][]chan DataChangeEvent // 订阅者
+	launcher    WorkflowLauncher
 }
 
 // DataChangeEvent 数据变更事件
@@ -74,11 +81,26 @@ func NewConditionTriggerService(db *gorm.DB) *ConditionTriggerService {
 	}
 }
 
+// SetLauncher 设置工作流执行入口，未设置时 execute_workflow 动作不生效
+func (s *ConditionTriggerService) SetLauncher(launcher WorkflowLauncher) {
+	s.launcher = launcher
+}
+
+// AutoMigrate 自动迁移
+func (s *ConditionTriggerService) AutoMigrate() error {
+	return s.db.AutoMigrate(&ConditionTrigger{}, &TriggerLog{})
+}
+
 // CreateTrigger 创建触发器
 func (s *ConditionTriggerService) CreateTrigger(ctx context.Context, trigger *ConditionTrigger) error {
 	if trigger.ID == "" {
 		trigger.ID = uuid.New().String()
 	}
+	if trigger.WorkflowVersion > 0 {
+		if _, err := getVersion(ctx, s.db, trigger.WorkflowID, trigger.WorkflowVersion); err != nil {
+			return err
+		}
+	}
 	trigger.CreatedAt = time.Now()
 	trigger.UpdatedAt = time.Now()
 
@@ -368,9 +390,23 @@ func (s *ConditionTriggerService) fireTrigger(ctx context.Context, trigger *Cond
 func (s *ConditionTriggerService) executeAction(ctx context.Context, action TriggerAction, trigger *ConditionTrigger, event DataChangeEvent) {
 	switch action.Type {
 	case "execute_workflow":
-		// 触发工作流执行
-		// 需要注入 WorkflowEngine 或通过消息队列
-		fmt.Printf("Trigger workflow %s for trigger %s\n", action.Target, trigger.ID)
+		// 触发工作流执行：目标为空时执行触发器所属工作流，并固定使用触发器配置的版本
+		if s.launcher == nil {
+			return
+		}
+		workflowID, version := action.Target, 0
+		if workflowID == "" || workflowID == trigger.WorkflowID {
+			workflowID, version = trigger.WorkflowID, trigger.WorkflowVersion
+		}
+		input := map[string]any{"event": event}
+		for k, v := range action.Parameters {
+			input[k] = v
+		}
+		if _, err := s.launcher.Launch(ctx, workflowID, trigger.TenantID, event.UserID, version, input); err != nil {
+			s.LogTriggerExecution(ctx, trigger, event, "failed", err)
+			return
+		}
+		s.LogTriggerExecution(ctx, trigger, event, "success", nil)
 	case "send_notification":
 		// 发送通知
 		fmt.Printf("Send notification for trigger %s\n", trigger.ID)
@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"backend/internal/workflow/expression"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -238,18 +240,32 @@ func (d *WorkflowDebugger) GetVariable(sessionID, name string) (interface{}, err
 }
 
 // EvaluateExpression 求值表达式
-func (d *WorkflowDebugger) EvaluateExpression(sessionID, expression string) (interface{}, error) {
+// 语法与条件分支一致（govaluate + {{ }} + 路径访问），例如：
+// - step_1.output.quality_score > 80
+// - {{draft.word_count}} >= 1000 && contains({{tags}}, "悬疑")
+// - step_2.output.characters[0].name
+func (d *WorkflowDebugger) EvaluateExpression(sessionID, expr string) (interface{}, error) {
 	session, err := d.GetSession(sessionID)
 	if err != nil {
 		return nil, err
 	}
 
-	// 简单的变量替换 (实际可以使用表达式引擎)
-	if val, ok := session.Variables[expression]; ok {
-		return val, nil
-	}
+	return expression.Evaluate(expr, session.evaluationScope())
+}
 
-	return nil, fmt.Errorf("expression evaluation not supported: %s", expression)
+// evaluationScope 构建表达式求值的变量作用域
+// 已完成步骤的输出以步骤ID为键，显式设置的变量优先
+func (s *DebugSession) evaluationScope() map[string]interface{} {
+	scope := make(map[string]interface{}, len(s.Variables)+len(s.StepHistory))
+	for _, record := range s.StepHistory {
+		if record.StepID != "" && record.Output != nil {
+			scope[record.StepID] = record.Output
+		}
+	}
+	for k, v := range s.Variables {
+		scope[k] = v
+	}
+	return scope
 }
 
 // ShouldBreak 检查是否应该在此步骤暂停
@@ -0,0 +1,41 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWorkflowDebuggerEvaluateExpression(t *testing.T) {
+	debugger := NewWorkflowDebugger(nil)
+	session, err := debugger.StartDebugSession(context.Background(), "wf-1", "tenant-1", "user-1", []string{"review"})
+	if err != nil {
+		t.Fatalf("创建调试会话失败: %v", err)
+	}
+
+	_ = debugger.RecordStep(session.ID, DebugStepRecord{
+		StepID: "step_1",
+		Output: map[string]interface{}{"quality_score": 72, "summary": `{"issues":["节奏拖沓"]}`},
+		Status: "completed",
+	})
+	_ = debugger.SetVariable(session.ID, "threshold", 80)
+
+	result, err := debugger.EvaluateExpression(session.ID, "step_1.output.quality_score >= {{threshold}}")
+	if err != nil {
+		t.Fatalf("求值失败: %v", err)
+	}
+	if result != false {
+		t.Fatalf("期望 false，实际 %v", result)
+	}
+
+	issue, err := debugger.EvaluateExpression(session.ID, "step_1.output.summary.issues[0]")
+	if err != nil {
+		t.Fatalf("路径求值失败: %v", err)
+	}
+	if issue != "节奏拖沓" {
+		t.Fatalf("期望 节奏拖沓，实际 %v", issue)
+	}
+
+	if _, err := debugger.EvaluateExpression(session.ID, "step_1.output.quality_score >"); err == nil {
+		t.Fatal("期望语法错误")
+	}
+}
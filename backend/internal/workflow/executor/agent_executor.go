@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"backend/internal/agent/runtime"
+	"backend/internal/ai"
 	"backend/internal/audit"
 )
 
@@ -29,6 +30,17 @@ func NewAgentTaskExecutor(registry *runtime.Registry, auditService audit.AuditSe
 // ExecuteTask 执行单个任务
 // 实现TaskExecutor接口
 func (e *AgentTaskExecutor) ExecuteTask(ctx context.Context, task *Task) (*TaskResult, error) {
+	// 模型调用归属（积分计量与 Token 审计）
+	if ai.CallScopeFromContext(ctx) == nil && task.Context != nil {
+		ctx = ai.WithCallScope(ctx, &ai.CallScope{
+			TenantID:    task.Context.TenantID,
+			UserID:      task.Context.UserID,
+			WorkflowID:  task.Context.WorkflowID,
+			ExecutionID: task.Context.ExecutionID,
+			StepID:      task.Step.ID,
+		})
+	}
+
 	// 检查 MapReduce
 	if task.Step.MapReduce != nil && task.Step.MapReduce.Enabled {
 		return e.executeMapReduce(ctx, task)
@@ -97,8 +109,8 @@ func (e *AgentTaskExecutor) executeStandardAgent(ctx context.Context, task *Task
 
 	// 5. 构建TaskResult (并记录 Token 使用)
 	
-	// 记录 Token 消耗
-	if result.Usage != nil && e.auditService != nil {
+	// 记录 Token 消耗（模型调用已逐次计量时不再重复记录）
+	if scope := ai.CallScopeFromContext(ctx); result.Usage != nil && e.auditService != nil && (scope == nil || !scope.Metered()) {
 		tokenUsage := &audit.TokenUsage{
 			TenantID:         task.Context.TenantID,
 			UserID:           task.Context.UserID,
@@ -119,17 +131,28 @@ func (e *AgentTaskExecutor) executeStandardAgent(ctx context.Context, task *Task
 		}()
 	}
 
+	metadata := map[string]any{
+		"latency_ms": latency.Milliseconds(),
+		"agent_type": agent.Type(),
+		"agent_name": agent.Name(),
+		"usage":      result.Usage,
+		"cost":       result.Cost,
+	}
+	if result.StructuredOutput != nil {
+		metadata["structured_output"] = result.StructuredOutput
+	}
+	// A/B 实验信息，质量检查后据此回传评分
+	for _, key := range []string{"experiment_id", "experiment_variant_id", "experiment_trial_id"} {
+		if v, ok := result.Metadata[key]; ok {
+			metadata[key] = v
+		}
+	}
+
 	return &TaskResult{
-		ID:     task.ID,
-		Output: result.Output,
-		Status: result.Status,
-		Metadata: map[string]any{
-			"latency_ms": latency.Milliseconds(),
-			"agent_type": agent.Type(),
-			"agent_name": agent.Name(),
-			"usage":      result.Usage,
-			"cost":       result.Cost,
-		},
+		ID:       task.ID,
+		Output:   result.Output,
+		Status:   result.Status,
+		Metadata: metadata,
 	}, nil
 }
 
@@ -190,11 +213,21 @@ func (e *AgentTaskExecutor) buildAgentInput(task *Task) *runtime.AgentInput {
 		agentCtx.SessionID = &sessionID
 	}
 
+	// 步骤级结构化输出 Schema 通过 ExtraParams 传递，复制一份避免修改步骤定义
+	extraParams := task.Step.ExtraConfig
+	if len(task.Step.OutputSchema) > 0 {
+		extraParams = make(map[string]any, len(task.Step.ExtraConfig)+1)
+		for k, v := range task.Step.ExtraConfig {
+			extraParams[k] = v
+		}
+		extraParams["output_schema"] = task.Step.OutputSchema
+	}
+
 	return &runtime.AgentInput{
 		Content:     content,
 		Variables:   task.Input,
 		Context:     agentCtx,
-		ExtraParams: task.Step.ExtraConfig,
+		ExtraParams: extraParams,
 	}
 }
 
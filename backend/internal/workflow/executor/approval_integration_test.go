@@ -81,6 +81,7 @@ func TestApprovalWorkflowIntegration(t *testing.T) {
 		userID,
 		map[string]any{"test_input": "value"},
 		parsedDef,
+		0,
 	)
 
 	// 7. 验证工作流暂停
@@ -195,6 +196,7 @@ func TestApprovalWorkflowRejectionIntegration(t *testing.T) {
 		userID,
 		map[string]any{},
 		parsedDef,
+		0,
 	)
 
 	require.NoError(t, err)
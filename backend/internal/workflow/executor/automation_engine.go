@@ -6,9 +6,11 @@ import (
 	"strings"
 	"time"
 
+	agentpkg "backend/internal/agent"
 	"backend/internal/agent/runtime"
 	"backend/internal/audit"
 	"backend/internal/infra/queue"
+	"backend/internal/logger"
 	"backend/internal/metrics"
 	"backend/internal/notification"
 	workflowpkg "backend/internal/workflow"
@@ -17,6 +19,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -70,8 +73,8 @@ func (e *AutomationEngine) ExecuteWithAutomation(
 	input map[string]any,
 ) (*ExecutionResult, error) {
 
-	// 解析工作流定义
-	workflowDef, err := e.loadWorkflowDefinition(ctx, workflowID, tenantID)
+	// 解析已发布的工作流定义版本
+	workflowDef, version, err := e.loadWorkflowDefinition(ctx, workflowID, tenantID, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +87,7 @@ func (e *AutomationEngine) ExecuteWithAutomation(
 	// 检查是否启用自动化
 	if workflowDef.AutomationConfig == nil || workflowDef.AutomationConfig.Mode == "manual" {
 		// 使用标准执行模式
-		return e.Engine.Execute(ctx, workflowID, tenantID, userID, input)
+		return e.Engine.ExecuteVersion(ctx, workflowID, tenantID, userID, version, input)
 	}
 
 	// 初始化执行状态
@@ -95,7 +98,7 @@ func (e *AutomationEngine) ExecuteWithAutomation(
 	}
 
 	// 执行工作流（带自动化控制）
-	return e.executeAutomated(ctx, workflowID, executionID, tenantID, userID, input, workflowDef)
+	return e.executeAutomated(ctx, workflowID, executionID, tenantID, userID, input, workflowDef, version)
 }
 
 // ResumeExecution 恢复执行
@@ -119,7 +122,15 @@ func (e *AutomationEngine) ResumeExecution(
 		return nil, fmt.Errorf("无法从状态中恢复工作流信息")
 	}
 
-	workflowDef, err := e.loadWorkflowDefinition(ctx, workflowID, tenantID)
+	// 使用开始执行时固定的定义版本
+	version := 0
+	switch v := state.Metadata["workflow_version"].(type) {
+	case int:
+		version = v
+	case float64:
+		version = int(v)
+	}
+	workflowDef, _, err := e.loadWorkflowDefinition(ctx, workflowID, tenantID, version)
 	if err != nil {
 		return nil, err
 	}
@@ -219,7 +230,7 @@ func (e *AutomationEngine) resumeAutomated(
 	}
 
 	// 创建调度器
-	scheduler := NewScheduler(dag, taskExecutor, e.maxConcurrency)
+	scheduler := NewScheduler(dag, e.withSubWorkflows(taskExecutor), e.maxConcurrency)
 
 	// 恢复执行
 	results, err := scheduler.Resume(ctx, execCtx, previousResults)
@@ -272,6 +283,7 @@ func (e *AutomationEngine) executeAutomated(
 	workflowID, executionID, tenantID, userID string,
 	input map[string]any,
 	workflowDef *WorkflowDefinition,
+	version int,
 ) (*ExecutionResult, error) {
 
 	start := time.Now()
@@ -297,10 +309,11 @@ func (e *AutomationEngine) executeAutomated(
 	}
 	_ = e.stateManager.UpdateState(ctx, executionID, map[string]any{
 		"metadata": map[string]any{
-			"workflow_id": workflowID,
-			"tenant_id":   tenantID,
-			"user_id":     userID,
-			"input":       input,
+			"workflow_id":      workflowID,
+			"workflow_version": version,
+			"tenant_id":        tenantID,
+			"user_id":          userID,
+			"input":            input,
 		},
 	})
 
@@ -317,7 +330,7 @@ func (e *AutomationEngine) executeAutomated(
 	}
 
 	// 创建调度器（复用 Engine 并发配置）
-	scheduler := NewScheduler(dag, taskExecutor, e.maxConcurrency)
+	scheduler := NewScheduler(dag, e.withSubWorkflows(taskExecutor), e.maxConcurrency)
 
 	// 执行工作流
 	results, err := scheduler.Schedule(ctx, execCtx)
@@ -449,6 +462,12 @@ func (e *AutomatedTaskExecutor) ExecuteTask(ctx context.Context, task *Task) (*T
 		score, evalErr := e.qualityEvaluator.EvaluateQuality(ctx, outputStr, task.Step.QualityCheck)
 		if evalErr == nil {
 			result.Metadata["quality_score"] = score
+			if trialID, ok := result.Metadata["experiment_trial_id"].(string); ok && e.base.agentRegistry != nil {
+				quality := score
+				if err := e.base.agentRegistry.RecordExperimentFeedback(ctx, task.Context.TenantID, trialID, &agentpkg.TrialFeedback{QualityScore: &quality}); err != nil {
+					logger.Warn("回传实验质量评分失败", zap.String("trial_id", trialID), zap.Error(err))
+				}
+			}
 
 			// 检查是否需要重写
 			if e.qualityEvaluator.NeedsRewrite(score, task.Step.QualityCheck) {
@@ -514,6 +533,18 @@ func (e *AutomatedTaskExecutor) handleApproval(ctx context.Context, task *Task)
 		NotifyTargets:  notifyTargets,
 		TimeoutSeconds: timeoutSeconds,
 	}
+	if cfg := task.Step.ApprovalConfig; cfg != nil {
+		for _, stage := range cfg.Stages {
+			approvalInput.Stages = append(approvalInput.Stages, workflowpkg.ApprovalStage{
+				Name:              stage.Name,
+				Approvers:         stage.Approvers,
+				RequiredApprovals: stage.RequiredApprovals,
+				TimeoutSeconds:    stage.TimeoutSeconds,
+				EscalateTo:        stage.EscalateTo,
+				TimeoutAction:     stage.TimeoutAction,
+			})
+		}
+	}
 
 	approvalReq, err := e.approvalManager.CreateApprovalRequest(ctx, approvalInput)
 	if err != nil {
@@ -655,22 +686,24 @@ func (e *AutomationEngine) getApprovalStatus(ctx context.Context, approvalID str
 	return &approvalReq, nil
 }
 
-func (e *AutomationEngine) loadWorkflowDefinition(ctx context.Context, workflowID, tenantID string) (*WorkflowDefinition, error) {
+// loadWorkflowDefinition 加载并解析工作流定义版本（version 为 0 时使用已发布版本），返回解析结果与版本号
+func (e *AutomationEngine) loadWorkflowDefinition(ctx context.Context, workflowID, tenantID string, version int) (*WorkflowDefinition, int, error) {
 	// 从数据库加载工作流
-	var workflow struct {
-		Definition workflowpkg.WorkflowDefinition `gorm:"column:definition;type:jsonb;serializer:json"`
-	}
-
+	var workflow workflowpkg.Workflow
 	if err := e.db.WithContext(ctx).
-		Table("workflows").
-		Select("definition").
 		Where("id = ? AND tenant_id = ?", workflowID, tenantID).
 		First(&workflow).Error; err != nil {
-		return nil, fmt.Errorf("加载工作流定义失败: %w", err)
+		return nil, 0, fmt.Errorf("加载工作流定义失败: %w", err)
+	}
+
+	pinned, err := workflowpkg.ResolveVersion(ctx, e.db, &workflow, version)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// 解析定义
-	return e.parser.Parse(workflow.Definition)
+	def, err := e.parser.Parse(pinned.Definition)
+	return def, pinned.Number, err
 }
 
 func convertToAgentResult(taskResult *TaskResult) *runtime.AgentResult {
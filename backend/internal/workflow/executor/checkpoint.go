@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	workflowpkg "backend/internal/workflow"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// checkpointOutputKey 步骤输出在 WorkflowTask.Output 中的键（步骤输出不一定是对象）
+const checkpointOutputKey = "value"
+
+// StepCheckpointer 步骤检查点：每个步骤结束后持久化其结果
+type StepCheckpointer interface {
+	SaveCheckpoint(ctx context.Context, execCtx *ExecutionContext, step *StepDefinition, result *TaskResult) error
+}
+
+// dbCheckpointer 将步骤结果写入 workflow_tasks，同一执行的同一步骤只保留最新结果
+type dbCheckpointer struct {
+	db *gorm.DB
+}
+
+// SaveCheckpoint 保存步骤结果
+func (c *dbCheckpointer) SaveCheckpoint(ctx context.Context, execCtx *ExecutionContext, step *StepDefinition, result *TaskResult) error {
+	now := time.Now().UTC()
+	task := &workflowpkg.WorkflowTask{
+		ID:          uuid.New().String(),
+		TenantID:    execCtx.TenantID,
+		ExecutionID: execCtx.ExecutionID,
+		StepID:      result.ID,
+		Status:      result.Status,
+		Output:      map[string]any{checkpointOutputKey: result.Output},
+		Metadata:    result.Metadata,
+		CompletedAt: &now,
+	}
+	if step != nil {
+		task.StepType = step.Type
+		task.StepName = step.Name
+		task.AgentType = step.AgentType
+	}
+	if result.Error != nil {
+		task.ErrorMessage = result.Error.Error()
+	}
+
+	return c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "execution_id"}, {Name: "step_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "output", "metadata", "error_message", "completed_at", "updated_at"}),
+	}).Create(task).Error
+}
+
+// loadCheckpoints 读取执行中已成功完成的步骤结果，供 Scheduler.Resume 跳过这些步骤
+func loadCheckpoints(ctx context.Context, db *gorm.DB, executionID string) (map[string]*TaskResult, error) {
+	var tasks []workflowpkg.WorkflowTask
+	if err := db.WithContext(ctx).
+		Where("execution_id = ? AND status = ?", executionID, "success").
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]*TaskResult, len(tasks))
+	for _, task := range tasks {
+		metadata := task.Metadata
+		if metadata == nil {
+			metadata = make(map[string]any)
+		}
+		metadata["restored_from_checkpoint"] = true
+		results[task.StepID] = &TaskResult{
+			ID:       task.StepID,
+			Status:   task.Status,
+			Output:   task.Output[checkpointOutputKey],
+			Metadata: metadata,
+		}
+	}
+	return results, nil
+}
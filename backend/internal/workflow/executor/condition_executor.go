@@ -3,12 +3,11 @@ package executor
 import (
 	"context"
 	"fmt"
-	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/Knetic/govaluate"
+	"backend/internal/workflow/expression"
 )
 
 // ConditionExecutor 条件分支执行器
@@ -30,159 +29,24 @@ func NewConditionExecutor(taskExecutor TaskExecutor) *ConditionExecutor {
 // - step_2.output.status == "success"
 // - step_1.output.word_count >= 1000
 // - step_3.output.error != nil
+// - step_1.output.characters[0].name == "林远"（结构化输出的 JSON 路径）
+// 语法由 expression 包实现，与调试器的表达式求值保持一致
 func (e *ConditionExecutor) EvaluateCondition(expr string, execCtx *ExecutionContext) (bool, error) {
-	if expr == "" {
+	if strings.TrimSpace(expr) == "" {
 		return false, fmt.Errorf("条件表达式不能为空")
 	}
-
-	expr = strings.TrimSpace(expr)
-
-	if fnResult, handled, err := e.evaluateFunction(expr, execCtx); handled {
-		return fnResult, err
-	}
-
-	// 提前替换 {{ var }} 形式的变量为占位符，避免 govaluate 解析出错
-	placeholderMap := make(map[string]string)
-	re := regexp.MustCompile(`\{\{([^}]+)\}\}`)
-	processedExpr := re.ReplaceAllStringFunc(expr, func(match string) string {
-		content := strings.TrimSpace(match[2 : len(match)-2])
-		placeholder := fmt.Sprintf("var%d", len(placeholderMap))
-		placeholderMap[placeholder] = content
-		return placeholder
-	})
-
-	// 创建表达式
-	expression, err := govaluate.NewEvaluableExpression(processedExpr)
-	if err != nil {
-		return false, fmt.Errorf("解析表达式失败: %w", err)
-	}
-
-	parameters := make(map[string]interface{})
-
-	// 先填充占位符映射
-	for placeholder, varName := range placeholderMap {
-		val, err := e.getVariableValue(varName, execCtx)
-		if err != nil {
-			parameters[placeholder] = nil
-			continue
-		}
-		parameters[placeholder] = val
-	}
-
-	// 处理剩余变量
-	for _, v := range expression.Vars() {
-		if _, exists := parameters[v]; exists {
-			continue
-		}
-		val, err := e.getVariableValue(v, execCtx)
-		if err != nil {
-			parameters[v] = nil
-			continue
-		}
-		parameters[v] = val
-	}
-
-	result, err := expression.Evaluate(parameters)
-	if err != nil {
-		return false, fmt.Errorf("评估表达式失败: %w", err)
-	}
-
-	if boolResult, ok := result.(bool); ok {
-		return boolResult, nil
-	}
-
-	return false, fmt.Errorf("表达式结果不是布尔值: %v", result)
+	return expression.EvaluateBool(expr, execCtx.GetAllData())
 }
 
 // resolveValue 解析值（变量或字面量）
 func (e *ConditionExecutor) resolveValue(value string, execCtx *ExecutionContext) (any, error) {
-	if strings.HasPrefix(value, "len(") && strings.HasSuffix(value, ")") {
-		inner := strings.TrimSpace(value[4 : len(value)-1])
-		val, err := e.resolveValue(inner, execCtx)
-		if err != nil {
-			return nil, err
-		}
-		return float64(lengthOf(val)), nil
-	}
-
-	// 检查是否是变量引用 {{...}}
-	if strings.HasPrefix(value, "{{") && strings.HasSuffix(value, "}}") {
-		varName := strings.TrimSpace(value[2 : len(value)-2])
-		return e.getVariableValue(varName, execCtx)
-	}
-
-	// 检查是否是字符串字面量 "..."
-	if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
-		return value[1 : len(value)-1], nil
-	}
-
-	// 检查是否是数字
-	if num, err := strconv.ParseFloat(value, 64); err == nil {
-		return num, nil
-	}
-
-	// 检查是否是布尔值
-	if value == "true" {
-		return true, nil
-	}
-	if value == "false" {
-		return false, nil
-	}
-
-	// 检查是否是 null
-	if value == "null" {
-		return nil, nil
-	}
-
-	// 其他情况，作为字符串处理
-	return value, nil
+	return expression.ResolveValue(value, execCtx.GetAllData())
 }
 
 // getVariableValue 从执行上下文中获取变量值
 // 支持点号访问嵌套字段：step_1.output.content
 func (e *ConditionExecutor) getVariableValue(varName string, execCtx *ExecutionContext) (any, error) {
-	parts := strings.Split(varName, ".")
-	if len(parts) == 0 {
-		return nil, fmt.Errorf("无效的变量名: %s", varName)
-	}
-
-	// 从上下文数据中获取值
-	var current any = execCtx.Data
-
-	for i, part := range parts {
-		if current == nil {
-			return nil, fmt.Errorf("变量 %s 在路径 %s 处为 null", varName, strings.Join(parts[:i], "."))
-		}
-
-		// 使用反射访问字段
-		switch v := current.(type) {
-		case map[string]any:
-			val, ok := v[part]
-			if !ok {
-				return nil, fmt.Errorf("变量 %s 不存在（路径: %s）", varName, strings.Join(parts[:i+1], "."))
-			}
-			current = val
-
-		default:
-			// 尝试使用反射访问结构体字段
-			val := reflect.ValueOf(current)
-			if val.Kind() == reflect.Ptr {
-				val = val.Elem()
-			}
-
-			if val.Kind() == reflect.Struct {
-				field := val.FieldByName(part)
-				if !field.IsValid() {
-					return nil, fmt.Errorf("变量 %s 不存在（路径: %s）", varName, strings.Join(parts[:i+1], "."))
-				}
-				current = field.Interface()
-			} else {
-				return nil, fmt.Errorf("无法访问变量 %s（路径: %s）", varName, strings.Join(parts[:i+1], "."))
-			}
-		}
-	}
-
-	return current, nil
+	return expression.Lookup(execCtx.GetAllData(), varName)
 }
 
 // compareValues 比较两个值
@@ -212,145 +76,6 @@ func (e *ConditionExecutor) compareValues(left, right any, operator string) (boo
 	}
 }
 
-func (e *ConditionExecutor) evaluateFunction(expr string, execCtx *ExecutionContext) (bool, bool, error) {
-	switch {
-	case strings.HasPrefix(expr, "any_of(") && strings.HasSuffix(expr, ")"):
-		args := splitArguments(expr[7 : len(expr)-1])
-		for _, arg := range args {
-			res, err := e.EvaluateCondition(strings.TrimSpace(arg), execCtx)
-			if err != nil {
-				return false, true, err
-			}
-			if res {
-				return true, true, nil
-			}
-		}
-		return false, true, nil
-	case strings.HasPrefix(expr, "all_of(") && strings.HasSuffix(expr, ")"):
-		args := splitArguments(expr[7 : len(expr)-1])
-		if len(args) == 0 {
-			return false, true, fmt.Errorf("all_of 需要至少一个条件")
-		}
-		for _, arg := range args {
-			res, err := e.EvaluateCondition(strings.TrimSpace(arg), execCtx)
-			if err != nil {
-				return false, true, err
-			}
-			if !res {
-				return false, true, nil
-			}
-		}
-		return true, true, nil
-	case strings.HasPrefix(expr, "none_of(") && strings.HasSuffix(expr, ")"):
-		args := splitArguments(expr[8 : len(expr)-1])
-		for _, arg := range args {
-			res, err := e.EvaluateCondition(strings.TrimSpace(arg), execCtx)
-			if err != nil {
-				return false, true, err
-			}
-			if res {
-				return false, true, nil
-			}
-		}
-		return true, true, nil
-	case strings.HasPrefix(expr, "contains(") && strings.HasSuffix(expr, ")"):
-		args := splitArguments(expr[9 : len(expr)-1])
-		if len(args) != 2 {
-			return false, true, fmt.Errorf("contains 需要两个参数")
-		}
-		left, err := e.resolveValue(strings.TrimSpace(args[0]), execCtx)
-		if err != nil {
-			return false, true, err
-		}
-		right, err := e.resolveValue(strings.TrimSpace(args[1]), execCtx)
-		if err != nil {
-			return false, true, err
-		}
-		return containsValue(left, right), true, nil
-	case strings.HasPrefix(expr, "empty(") && strings.HasSuffix(expr, ")"):
-		val, err := e.resolveValue(strings.TrimSpace(expr[6:len(expr)-1]), execCtx)
-		if err != nil {
-			return false, true, err
-		}
-		return lengthOf(val) == 0, true, nil
-	case strings.HasPrefix(expr, "not_empty(") && strings.HasSuffix(expr, ")"):
-		val, err := e.resolveValue(strings.TrimSpace(expr[10:len(expr)-1]), execCtx)
-		if err != nil {
-			return false, true, err
-		}
-		return lengthOf(val) > 0, true, nil
-	default:
-		return false, false, nil
-	}
-}
-
-func splitArguments(body string) []string {
-	var args []string
-	depth := 0
-	start := 0
-	for i, r := range body {
-		switch r {
-		case '(':
-			depth++
-		case ')':
-			depth--
-		case ',':
-			if depth == 0 {
-				args = append(args, body[start:i])
-				start = i + 1
-			}
-		}
-	}
-	if start < len(body) {
-		args = append(args, body[start:])
-	}
-	return args
-}
-
-func containsValue(left, right any) bool {
-	switch lv := left.(type) {
-	case string:
-		return strings.Contains(lv, fmt.Sprintf("%v", right))
-	case []any:
-		for _, item := range lv {
-			if fmt.Sprintf("%v", item) == fmt.Sprintf("%v", right) {
-				return true
-			}
-		}
-	case []string:
-		for _, item := range lv {
-			if item == fmt.Sprintf("%v", right) {
-				return true
-			}
-		}
-	case map[string]any:
-		if key, ok := right.(string); ok {
-			_, exists := lv[key]
-			return exists
-		}
-	}
-	return false
-}
-
-func lengthOf(value any) int {
-	switch v := value.(type) {
-	case nil:
-		return 0
-	case string:
-		return len(v)
-	case []any:
-		return len(v)
-	case []string:
-		return len(v)
-	case map[string]any:
-		return len(v)
-	case map[string]string:
-		return len(v)
-	default:
-		return 0
-	}
-}
-
 // equals 判断两个值是否相等
 func (e *ConditionExecutor) equals(left, right any) bool {
 	// 尝试数字比较
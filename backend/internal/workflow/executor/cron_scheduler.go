@@ -0,0 +1,195 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend/internal/logger"
+	workflowpkg "backend/internal/workflow"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 定时调度默认参数
+const (
+	cronLeaseName        = "workflow_cron"
+	defaultCronInterval  = 30 * time.Second
+	defaultMisfireGrace  = 5 * time.Minute
+	defaultCronBatchSize = 100
+)
+
+// activeExecutionStatuses 未结束的执行状态，存在时不再启动同一工作流的定时执行
+var activeExecutionStatuses = []string{"pending", "queued", "running", "paused"}
+
+// WorkflowStarter 提交工作流执行
+type WorkflowStarter interface {
+	Execute(ctx context.Context, workflowID, tenantID, userID string, input map[string]any) (*ExecutionResult, error)
+}
+
+// CronScheduler 按 Workflow.Schedule 定时触发工作流。
+// 多实例部署时通过数据库租约选出一个实例执行调度，NextRunAt 以条件更新认领，避免重复触发
+type CronScheduler struct {
+	db           *gorm.DB
+	starter      WorkflowStarter
+	leader       *LeaderElector
+	misfireGrace time.Duration
+	batchSize    int
+	now          func() time.Time
+}
+
+// NewCronScheduler 创建定时调度器
+func NewCronScheduler(db *gorm.DB, starter WorkflowStarter) *CronScheduler {
+	return &CronScheduler{
+		db:           db,
+		starter:      starter,
+		leader:       NewLeaderElector(db, cronLeaseName, 3*defaultCronInterval),
+		misfireGrace: defaultMisfireGrace,
+		batchSize:    defaultCronBatchSize,
+		now:          time.Now,
+	}
+}
+
+// AutoMigrate 自动迁移调度租约表
+func (s *CronScheduler) AutoMigrate() error {
+	return s.db.AutoMigrate(&SchedulerLease{})
+}
+
+// Start 按间隔检查到期的定时工作流，ctx 结束时释放租约
+func (s *CronScheduler) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultCronInterval
+	}
+	// 租约至少覆盖三个检查周期，单次检查变慢不会导致领导者频繁切换
+	if s.leader.ttl < 3*interval {
+		s.leader.ttl = 3 * interval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Tick(ctx); err != nil {
+					logger.Warn("定时工作流调度失败", zap.Error(err))
+				}
+			case <-ctx.Done():
+				if err := s.leader.Release(context.Background()); err != nil {
+					logger.Warn("释放调度租约失败", zap.Error(err))
+				}
+				return
+			}
+		}
+	}()
+}
+
+// Tick 执行一次调度检查：非领导者实例直接返回，返回本次触发的执行数
+func (s *CronScheduler) Tick(ctx context.Context) (int, error) {
+	leader, err := s.leader.TryAcquire(ctx)
+	if err != nil || !leader {
+		return 0, err
+	}
+	return s.RunDue(ctx)
+}
+
+// RunDue 触发所有到期的定时工作流，返回触发的执行数
+func (s *CronScheduler) RunDue(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+	var workflows []workflowpkg.Workflow
+	if err := s.db.WithContext(ctx).
+		Where("schedule <> '' AND next_run_at IS NOT NULL AND next_run_at <= ?", now).
+		Where("status = ? AND deleted_at IS NULL", "active").
+		Order("next_run_at").
+		Limit(s.batchSize).
+		Find(&workflows).Error; err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for i := range workflows {
+		ok, err := s.fire(ctx, &workflows[i], now)
+		if err != nil {
+			logger.Warn("触发定时工作流失败", zap.String("workflow_id", workflows[i].ID), zap.Error(err))
+			continue
+		}
+		if ok {
+			started++
+		}
+	}
+	return started, nil
+}
+
+// fire 按补偿策略处理一个到期工作流，返回是否提交了执行
+func (s *CronScheduler) fire(ctx context.Context, wf *workflowpkg.Workflow, now time.Time) (bool, error) {
+	plan, err := wf.PlanScheduledRun(now, s.misfireGrace)
+	if err != nil {
+		// 表达式已无法解析：停止调度，等待用户修正
+		_, claimErr := s.claim(ctx, wf, map[string]any{"next_run_at": nil})
+		return false, errors.Join(err, claimErr)
+	}
+
+	busy := false
+	if plan.RunAt != nil {
+		if busy, err = s.hasActiveExecution(ctx, wf); err != nil {
+			return false, err
+		}
+		if busy && plan.WaitIfBusy {
+			return false, nil
+		}
+	}
+
+	updates := map[string]any{"next_run_at": plan.NextRunAt}
+	if plan.RunAt != nil && !busy {
+		updates["last_scheduled_at"] = *plan.RunAt
+	}
+	claimed, err := s.claim(ctx, wf, updates)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	fields := []zap.Field{zap.String("workflow_id", wf.ID), zap.Int("missed", plan.Missed)}
+	switch {
+	case plan.RunAt == nil:
+		logger.Info("跳过停机期间错过的定时执行", fields...)
+		return false, nil
+	case busy:
+		logger.Info("上一次执行尚未结束，跳过本次定时执行", fields...)
+		return false, nil
+	}
+
+	userID := wf.OwnerUserID
+	if userID == "" {
+		userID = wf.CreatedBy
+	}
+	if _, err := s.starter.Execute(ctx, wf.ID, wf.TenantID, userID, wf.DefaultInput()); err != nil {
+		// 提交失败：恢复认领前的调度状态，下一次检查时重试
+		return false, errors.Join(err, s.restore(ctx, wf, plan.NextRunAt))
+	}
+	logger.Info("已触发定时工作流", append(fields, zap.Time("scheduled_at", *plan.RunAt))...)
+	return true, nil
+}
+
+// claim 以 NextRunAt 为条件更新调度状态，返回是否由本实例认领
+func (s *CronScheduler) claim(ctx context.Context, wf *workflowpkg.Workflow, updates map[string]any) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&workflowpkg.Workflow{}).
+		Where("id = ? AND next_run_at = ?", wf.ID, *wf.NextRunAt).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// restore 撤销本实例对 claimedNextRunAt 的认领，将 NextRunAt 与 LastScheduledAt 恢复为认领前的值
+func (s *CronScheduler) restore(ctx context.Context, wf *workflowpkg.Workflow, claimedNextRunAt time.Time) error {
+	return s.db.WithContext(context.WithoutCancel(ctx)).Model(&workflowpkg.Workflow{}).
+		Where("id = ? AND next_run_at = ?", wf.ID, claimedNextRunAt).
+		Updates(map[string]any{"next_run_at": *wf.NextRunAt, "last_scheduled_at": wf.LastScheduledAt}).Error
+}
+
+// hasActiveExecution 工作流是否存在未结束的执行
+func (s *CronScheduler) hasActiveExecution(ctx context.Context, wf *workflowpkg.Workflow) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&workflowpkg.WorkflowExecution{}).
+		Where("workflow_id = ? AND tenant_id = ? AND status IN ?", wf.ID, wf.TenantID, activeExecutionStatuses).
+		Count(&count).Error
+	return count > 0, err
+}
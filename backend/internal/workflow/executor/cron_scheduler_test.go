@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/workflow"
+)
+
+type fakeStarter struct {
+	err   error
+	calls int
+}
+
+func (f *fakeStarter) Execute(context.Context, string, string, string, map[string]any) (*ExecutionResult, error) {
+	f.calls++
+	return &ExecutionResult{}, f.err
+}
+
+func createScheduledWorkflow(t *testing.T, s *CronScheduler, id, status string, nextRunAt time.Time) {
+	t.Helper()
+	_ = logger.Init("error", "console", "stdout")
+	w := &workflow.Workflow{
+		ID:         id,
+		TenantID:   "00000000-0000-0000-0000-000000000001",
+		Name:       "Cron",
+		Version:    "v1",
+		Visibility: "personal",
+		Schedule:   "0 * * * *",
+		NextRunAt:  &nextRunAt,
+		Status:     status,
+	}
+	if err := s.db.Create(w).Error; err != nil {
+		t.Fatalf("写入 workflow 失败: %v", err)
+	}
+}
+
+func TestCronSchedulerOnlyRunsActiveWorkflows(t *testing.T) {
+	starter := &fakeStarter{}
+	s := NewCronScheduler(setupEngineTestDB(t), starter)
+	due := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return due.Add(time.Second) }
+
+	createScheduledWorkflow(t, s, "00000000-0000-0000-0000-0000000000a1", "active", due)
+	createScheduledWorkflow(t, s, "00000000-0000-0000-0000-0000000000a2", "draft", due)
+	createScheduledWorkflow(t, s, "00000000-0000-0000-0000-0000000000a3", "archived", due)
+
+	started, err := s.RunDue(context.Background())
+	if err != nil || started != 1 || starter.calls != 1 {
+		t.Fatalf("只应触发已发布的工作流: started=%d calls=%d err=%v", started, starter.calls, err)
+	}
+}
+
+func TestCronSchedulerRetriesAfterSubmitFailure(t *testing.T) {
+	starter := &fakeStarter{err: errors.New("队列不可用")}
+	s := NewCronScheduler(setupEngineTestDB(t), starter)
+	due := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return due.Add(time.Second) }
+	id := "00000000-0000-0000-0000-0000000000b1"
+	createScheduledWorkflow(t, s, id, "active", due)
+
+	if started, _ := s.RunDue(context.Background()); started != 0 {
+		t.Fatalf("提交失败时不应计为触发: started=%d", started)
+	}
+	var wf workflow.Workflow
+	if err := s.db.First(&wf, "id = ?", id).Error; err != nil {
+		t.Fatalf("读取 workflow 失败: %v", err)
+	}
+	if wf.NextRunAt == nil || !wf.NextRunAt.Equal(due) || wf.LastScheduledAt != nil {
+		t.Fatalf("提交失败后应恢复调度状态: next=%v last=%v", wf.NextRunAt, wf.LastScheduledAt)
+	}
+
+	// 下一次检查时重试
+	starter.err = nil
+	s.now = func() time.Time { return due.Add(30 * time.Second) }
+	if started, err := s.RunDue(context.Background()); err != nil || started != 1 || starter.calls != 2 {
+		t.Fatalf("应在下一次检查时重试: started=%d calls=%d err=%v", started, starter.calls, err)
+	}
+	if err := s.db.First(&wf, "id = ?", id).Error; err != nil {
+		t.Fatalf("读取 workflow 失败: %v", err)
+	}
+	if !wf.NextRunAt.Equal(due.Add(time.Hour)) || wf.LastScheduledAt == nil || !wf.LastScheduledAt.Equal(due) {
+		t.Fatalf("重试成功后应推进调度: next=%v last=%v", wf.NextRunAt, wf.LastScheduledAt)
+	}
+}
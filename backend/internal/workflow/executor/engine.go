@@ -2,16 +2,20 @@ package executor
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"backend/internal/agent/runtime"
 	"backend/internal/audit"
 	"backend/internal/infra/queue"
+	"backend/internal/logger"
 	"backend/internal/worker/tasks"
 	workflowpkg "backend/internal/workflow"
 
 	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -23,6 +27,14 @@ type Engine struct {
 	queueClient    queue.Client
 	auditService   audit.AuditService
 	maxConcurrency int
+	observer       ExecutionObserver
+	workerID       string        // 执行租约持有者标识
+	leaseTTL       time.Duration // 执行租约有效期
+}
+
+// ExecutionObserver 工作流执行结束回调（如向租户投递 Webhook 事件）
+type ExecutionObserver interface {
+	OnExecutionFinished(ctx context.Context, execution *workflowpkg.WorkflowExecution)
 }
 
 // NewEngine 创建执行引擎
@@ -34,6 +46,8 @@ func NewEngine(db *gorm.DB, agentRegistry *runtime.Registry, queueClient queue.C
 		queueClient:    queueClient,
 		auditService:   auditService,
 		maxConcurrency: 5,
+		workerID:       newInstanceID(),
+		leaseTTL:       executionLeaseTTL,
 	}
 	for _, opt := range opts {
 		opt(e)
@@ -53,13 +67,32 @@ func WithMaxConcurrency(n int) EngineOption {
 	}
 }
 
+// SetExecutionObserver 设置执行结束回调
+func (e *Engine) SetExecutionObserver(observer ExecutionObserver) {
+	e.observer = observer
+}
+
 // MaxConcurrency 返回当前配置
 func (e *Engine) MaxConcurrency() int {
 	return e.maxConcurrency
 }
 
-// Execute 提交工作流执行任务 (异步)
+// Execute 提交工作流执行任务 (异步)，使用工作流已发布的定义版本
 func (e *Engine) Execute(ctx context.Context, workflowID, tenantID, userID string, input map[string]any) (*ExecutionResult, error) {
+	return e.ExecuteVersion(ctx, workflowID, tenantID, userID, 0, input)
+}
+
+// Launch 提交指定版本的工作流执行，返回执行 ID（供触发器使用）
+func (e *Engine) Launch(ctx context.Context, workflowID, tenantID, userID string, version int, input map[string]any) (string, error) {
+	result, err := e.ExecuteVersion(ctx, workflowID, tenantID, userID, version, input)
+	if err != nil {
+		return "", err
+	}
+	return result.ExecutionID, nil
+}
+
+// ExecuteVersion 提交工作流执行任务 (异步)，执行固定使用 version 指定的定义版本，0 表示已发布版本
+func (e *Engine) ExecuteVersion(ctx context.Context, workflowID, tenantID, userID string, version int, input map[string]any) (*ExecutionResult, error) {
 	// 1. 验证工作流是否存在
 	var workflow workflowpkg.Workflow
 	if err := e.db.WithContext(ctx).
@@ -71,25 +104,32 @@ func (e *Engine) Execute(ctx context.Context, workflowID, tenantID, userID strin
 		return nil, fmt.Errorf("查询工作流失败: %w", err)
 	}
 
-	// 2. 创建执行记录 (Status: queued)
+	// 2. 确定执行使用的定义版本
+	pinned, err := workflowpkg.ResolveVersion(ctx, e.db, &workflow, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 创建执行记录 (Status: queued)
 	executionID := uuid.New().String()
 	now := time.Now().UTC()
 	execution := &workflowpkg.WorkflowExecution{
-		ID:         executionID,
-		WorkflowID: workflowID,
-		TenantID:   tenantID,
-		UserID:     userID,
-		Status:     "queued",
-		Input:      input,
-		StartedAt:  &now, // 提交时间
-		TraceID:    uuid.New().String(),
+		ID:              executionID,
+		WorkflowID:      workflowID,
+		TenantID:        tenantID,
+		UserID:          userID,
+		Status:          "queued",
+		Input:           input,
+		StartedAt:       &now, // 提交时间
+		TraceID:         uuid.New().String(),
+		WorkflowVersion: pinned.Number,
 	}
 
 	if err := e.db.WithContext(ctx).Create(execution).Error; err != nil {
 		return nil, fmt.Errorf("创建执行记录失败: %w", err)
 	}
 
-	// 3. 入队任务
+	// 4. 入队任务
 	payload := tasks.ExecuteWorkflowPayload{
 		ExecutionID: executionID,
 		WorkflowID:  workflowID,
@@ -108,13 +148,14 @@ func (e *Engine) Execute(ctx context.Context, workflowID, tenantID, userID strin
 		return nil, fmt.Errorf("任务入队失败: %w", err)
 	}
 
-	// 4. 返回初始结果
+	// 5. 返回初始结果
 	return &ExecutionResult{
-		ExecutionID: executionID,
-		WorkflowID:  workflowID,
-		Status:      "queued",
-		Input:       input,
-		StartedAt:   now,
+		ExecutionID:     executionID,
+		WorkflowID:      workflowID,
+		WorkflowVersion: pinned.Number,
+		Status:          "queued",
+		Input:           input,
+		StartedAt:       now,
 	}, nil
 }
 
@@ -136,17 +177,34 @@ func (e *Engine) RunExecution(ctx context.Context, executionID string) error {
 		return fmt.Errorf("查询工作流失败: %w", err)
 	}
 
-	// 3. 更新状态为 running
-	if err := e.db.WithContext(ctx).Model(&execution).
-		Updates(map[string]any{"status": "running"}).Error; err != nil {
-		return fmt.Errorf("更新状态失败: %w", err)
+	// 3. 获取执行租约并更新状态为 running
+	acquired, err := e.acquireLease(ctx, &execution)
+	if err != nil {
+		return fmt.Errorf("获取执行租约失败: %w", err)
+	}
+	if !acquired {
+		logger.Info("执行已结束或由其他 Worker 处理，跳过",
+			zap.String("execution_id", executionID),
+			zap.String("status", execution.Status),
+			zap.String("lease_owner", execution.LeaseOwner))
+		return nil
 	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	leaseLost := e.keepLease(runCtx, cancel, executionID)
 
-	// 4. 解析工作流定义
-	workflowDef, err := e.parser.Parse(workflow.Definition)
+	// 4. 解析执行固定的定义版本
+	definition, err := e.executionDefinition(ctx, &execution, &workflow)
+	if err != nil {
+		return e.failExecution(ctx, &execution, err)
+	}
+	workflowDef, err := e.parser.Parse(definition)
 	if err != nil {
 		return e.failExecution(ctx, &execution, fmt.Errorf("解析工作流定义失败: %w", err))
 	}
+	if err := applyStepOverrides(workflowDef, execution.StepOverrides); err != nil {
+		return e.failExecution(ctx, &execution, err)
+	}
 
 	// 5. 构建 DAG
 	dag, err := e.parser.BuildDAG(workflowDef)
@@ -160,21 +218,47 @@ func (e *Engine) RunExecution(ctx context.Context, executionID string) error {
 		ExecutionID: executionID,
 		TenantID:    execution.TenantID,
 		UserID:      execution.UserID,
+		TraceID:     execution.TraceID,
 		Data:        execution.Input, // 使用记录中的 Input
 	}
 
-	// 7. 创建任务执行器与调度器
-	taskExecutor := NewAgentTaskExecutor(e.agentRegistry, e.auditService)
+	// 7. 创建任务执行器与调度器，每个步骤结束后写入检查点
+	taskExecutor := e.withSubWorkflows(NewAgentTaskExecutor(e.agentRegistry, e.auditService))
 	scheduler := NewScheduler(dag, taskExecutor, e.maxConcurrency)
+	scheduler.SetCheckpointer(&dbCheckpointer{db: e.db})
 
-	// 8. 执行调度
-	_, err = scheduler.Schedule(ctx, execCtx)
+	// 8. 执行调度：存在检查点时（Worker 重启后恢复）跳过已完成的步骤
+	checkpoints, err := loadCheckpoints(ctx, e.db, executionID)
+	if err != nil {
+		return e.failExecution(ctx, &execution, fmt.Errorf("加载步骤检查点失败: %w", err))
+	}
+	if len(checkpoints) > 0 {
+		logger.Info("从检查点恢复工作流执行",
+			zap.String("execution_id", executionID),
+			zap.Int("completed_steps", len(checkpoints)))
+		_, err = scheduler.Resume(runCtx, execCtx, checkpoints)
+	} else {
+		_, err = scheduler.Schedule(runCtx, execCtx)
+	}
 
-	// 9. 更新最终状态
+	// 租约已被接管：结果由新的持有者负责写入
+	if leaseLost.Load() {
+		logger.Warn("执行租约已丢失，停止写入执行结果", zap.String("execution_id", executionID))
+		return nil
+	}
+
+	// 9. 更新最终状态并释放租约；map 更新不经过字段的 json 序列化器，输出显式编码为 JSON
+	output := execCtx.GetAllData()
+	outputJSON, marshalErr := json.Marshal(output)
+	if marshalErr != nil {
+		return e.failExecution(ctx, &execution, fmt.Errorf("序列化执行输出失败: %w", marshalErr))
+	}
 	updates := map[string]any{
-		"completed_at": time.Now().UTC(),
-		"output":       execCtx.GetAllData(),
-		"updated_at":   time.Now().UTC(),
+		"completed_at":     time.Now().UTC(),
+		"output":           datatypes.JSON(outputJSON),
+		"updated_at":       time.Now().UTC(),
+		"lease_owner":      "",
+		"lease_expires_at": nil,
 	}
 
 	status := "completed"
@@ -184,38 +268,82 @@ func (e *Engine) RunExecution(ctx context.Context, executionID string) error {
 	}
 	updates["status"] = status
 
-	if err := e.db.WithContext(ctx).Model(&execution).Updates(updates).Error; err != nil {
-		return fmt.Errorf("更新执行结果失败: %w", err)
+	result := e.db.WithContext(ctx).Model(&execution).
+		Where("lease_owner = ?", e.workerID).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("更新执行结果失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		logger.Warn("执行租约已丢失，未写入执行结果", zap.String("execution_id", executionID))
+		return nil
 	}
 
 	// 10. 更新统计
 	e.updateWorkflowStats(ctx, execution.WorkflowID, status)
 
+	// 11. 通知执行结束
+	execution.Status = status
+	execution.Output = output
+	if err != nil {
+		execution.ErrorMessage = err.Error()
+	}
+	e.notifyFinished(ctx, &execution)
+
 	return err
 }
 
+// executionDefinition 返回执行固定的定义版本；版本化之前的执行使用工作流当前定义
+func (e *Engine) executionDefinition(ctx context.Context, execution *workflowpkg.WorkflowExecution, workflow *workflowpkg.Workflow) (workflowpkg.WorkflowDefinition, error) {
+	if execution.WorkflowVersion == 0 {
+		return workflow.Definition, nil
+	}
+	version, err := workflowpkg.ResolveVersion(ctx, e.db, workflow, execution.WorkflowVersion)
+	if err != nil {
+		return workflowpkg.WorkflowDefinition{}, err
+	}
+	return version.Definition, nil
+}
+
 func (e *Engine) failExecution(ctx context.Context, execution *workflowpkg.WorkflowExecution, err error) error {
 	e.db.WithContext(ctx).Model(execution).Updates(map[string]any{
-		"status":        "failed",
-		"error_message": err.Error(),
-		"completed_at":  time.Now().UTC(),
+		"status":           "failed",
+		"error_message":    err.Error(),
+		"completed_at":     time.Now().UTC(),
+		"lease_owner":      "",
+		"lease_expires_at": nil,
 	})
 	e.updateWorkflowStats(ctx, execution.WorkflowID, "failed")
+	execution.Status = "failed"
+	execution.ErrorMessage = err.Error()
+	e.notifyFinished(ctx, execution)
 	return err
 }
 
+func (e *Engine) notifyFinished(ctx context.Context, execution *workflowpkg.WorkflowExecution) {
+	if e.observer == nil {
+		return
+	}
+	if execution.CompletedAt == nil {
+		now := time.Now().UTC()
+		execution.CompletedAt = &now
+	}
+	e.observer.OnExecutionFinished(context.WithoutCancel(ctx), execution)
+}
+
 // ExecutionResult 执行结果
 type ExecutionResult struct {
-	ExecutionID string
-	WorkflowID  string
-	Status      string
-	Input       map[string]any
-	Output      map[string]any
-	Error       error
-	StartedAt   time.Time
-	CompletedAt *time.Time
-	Duration    time.Duration
-	Tasks       map[string]*TaskResult
+	ExecutionID     string
+	WorkflowID      string
+	WorkflowVersion int
+	Status          string
+	Input           map[string]any
+	Output          map[string]any
+	Error           error
+	StartedAt       time.Time
+	CompletedAt     *time.Time
+	Duration        time.Duration
+	Tasks           map[string]*TaskResult
 }
 
 // updateWorkflowStats 更新工作流统计
@@ -40,7 +40,7 @@ func setupEngineTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("初始化 sqlite 失败: %v", err)
 	}
-	if err := db.AutoMigrate(&workflow.Workflow{}, &workflow.WorkflowExecution{}); err != nil {
+	if err := db.AutoMigrate(&workflow.Workflow{}, &workflow.WorkflowExecution{}, &workflow.WorkflowVersion{}); err != nil {
 		t.Fatalf("迁移 schema 失败: %v", err)
 	}
 	return db
@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/worker/tasks"
+	workflowpkg "backend/internal/workflow"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 分叉执行错误
+var (
+	ErrExecutionNotFound = errors.New("执行记录不存在")
+	ErrInvalidFork       = errors.New("无法分叉执行")
+)
+
+// Fork 从父执行的 stepID 步骤起重新运行工作流：
+// stepID 及其全部下游步骤重新执行，其余步骤复用父执行记录的输出；override 可覆盖 stepID 的输入与 Agent 配置
+func (e *Engine) Fork(ctx context.Context, tenantID, userID, parentExecutionID, stepID string, override *workflowpkg.StepOverride) (*ExecutionResult, error) {
+	// 1. 查询父执行与工作流
+	var parent workflowpkg.WorkflowExecution
+	if err := e.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ?", parentExecutionID, tenantID).
+		First(&parent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExecutionNotFound
+		}
+		return nil, fmt.Errorf("查询执行记录失败: %w", err)
+	}
+	switch parent.Status {
+	case "pending", "queued", "running":
+		return nil, fmt.Errorf("%w: 父执行尚未结束", ErrInvalidFork)
+	}
+
+	var workflow workflowpkg.Workflow
+	if err := e.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", parent.WorkflowID, tenantID).
+		First(&workflow).Error; err != nil {
+		return nil, fmt.Errorf("查询工作流失败: %w", err)
+	}
+
+	// 2. 按父执行固定的定义版本计算需要重新执行的步骤
+	definition, err := e.executionDefinition(ctx, &parent, &workflow)
+	if err != nil {
+		return nil, err
+	}
+	workflowDef, err := e.parser.Parse(definition)
+	if err != nil {
+		return nil, fmt.Errorf("解析工作流定义失败: %w", err)
+	}
+	dag, err := e.parser.BuildDAG(workflowDef)
+	if err != nil {
+		return nil, fmt.Errorf("构建 DAG 失败: %w", err)
+	}
+	if _, ok := dag.Nodes[stepID]; !ok {
+		return nil, fmt.Errorf("%w: 步骤 %s 不存在", ErrInvalidFork, stepID)
+	}
+	rerun := downstreamSteps(dag, stepID)
+
+	// 3. 收集可复用的上游输出
+	reused, err := e.reusableOutputs(ctx, &parent, dag, rerun)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 创建分叉执行并写入复用的步骤检查点，Worker 执行时从检查点继续
+	now := time.Now().UTC()
+	execution := &workflowpkg.WorkflowExecution{
+		ID:                uuid.New().String(),
+		WorkflowID:        parent.WorkflowID,
+		TenantID:          tenantID,
+		UserID:            userID,
+		Status:            "queued",
+		Input:             parent.Input,
+		StartedAt:         &now,
+		TraceID:           uuid.New().String(),
+		WorkflowVersion:   parent.WorkflowVersion,
+		ParentExecutionID: &parent.ID,
+		ForkedFromStep:    stepID,
+	}
+	if override != nil {
+		execution.StepOverrides = map[string]workflowpkg.StepOverride{stepID: *override}
+	}
+	for i := range reused {
+		reused[i].ID = uuid.New().String()
+		reused[i].ExecutionID = execution.ID
+	}
+
+	if err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(execution).Error; err != nil {
+			return err
+		}
+		if len(reused) > 0 {
+			return tx.Create(&reused).Error
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("创建执行记录失败: %w", err)
+	}
+
+	// 5. 入队任务
+	payload := tasks.ExecuteWorkflowPayload{
+		ExecutionID: execution.ID,
+		WorkflowID:  execution.WorkflowID,
+		TenantID:    tenantID,
+		UserID:      userID,
+		Input:       execution.Input,
+	}
+	if err := e.queueClient.EnqueueExecuteWorkflow(payload); err != nil {
+		e.db.WithContext(ctx).Model(execution).Updates(map[string]any{
+			"status":        "failed",
+			"error_message": fmt.Sprintf("任务入队失败: %v", err),
+			"completed_at":  time.Now().UTC(),
+		})
+		return nil, fmt.Errorf("任务入队失败: %w", err)
+	}
+
+	return &ExecutionResult{
+		ExecutionID:     execution.ID,
+		WorkflowID:      execution.WorkflowID,
+		WorkflowVersion: execution.WorkflowVersion,
+		Status:          "queued",
+		Input:           execution.Input,
+		StartedAt:       now,
+	}, nil
+}
+
+// reusableOutputs 以父执行的步骤检查点构造分叉执行的检查点；
+// 检查点上线前的执行没有步骤记录，从其最终输出中按步骤 ID 取值。缺少输出的步骤会在分叉执行中重新运行
+func (e *Engine) reusableOutputs(ctx context.Context, parent *workflowpkg.WorkflowExecution, dag *DAG, rerun map[string]bool) ([]workflowpkg.WorkflowTask, error) {
+	var parentTasks []workflowpkg.WorkflowTask
+	if err := e.db.WithContext(ctx).
+		Where("execution_id = ? AND status = ?", parent.ID, "success").
+		Find(&parentTasks).Error; err != nil {
+		return nil, fmt.Errorf("查询步骤记录失败: %w", err)
+	}
+	byStep := make(map[string]workflowpkg.WorkflowTask, len(parentTasks))
+	for _, task := range parentTasks {
+		byStep[task.StepID] = task
+	}
+
+	now := time.Now().UTC()
+	var reused []workflowpkg.WorkflowTask
+	for id, node := range dag.Nodes {
+		if rerun[id] {
+			continue
+		}
+		task, ok := byStep[id]
+		if !ok {
+			output, found := parent.Output[id]
+			if !found {
+				continue
+			}
+			task = workflowpkg.WorkflowTask{
+				StepID:    id,
+				StepType:  node.Step.Type,
+				StepName:  node.Step.Name,
+				AgentType: node.Step.AgentType,
+				Status:    "success",
+				Output:    map[string]any{checkpointOutputKey: output},
+			}
+		}
+
+		metadata := make(map[string]any, len(task.Metadata)+1)
+		for k, v := range task.Metadata {
+			metadata[k] = v
+		}
+		metadata["reused_from_execution"] = parent.ID
+		reused = append(reused, workflowpkg.WorkflowTask{
+			TenantID:    parent.TenantID,
+			StepID:      id,
+			StepType:    task.StepType,
+			StepName:    task.StepName,
+			AgentType:   task.AgentType,
+			Status:      "success",
+			Output:      task.Output,
+			Metadata:    metadata,
+			CompletedAt: &now,
+		})
+	}
+	return reused, nil
+}
+
+// downstreamSteps 返回 stepID 及其全部（传递）下游步骤
+func downstreamSteps(dag *DAG, stepID string) map[string]bool {
+	steps := map[string]bool{stepID: true}
+	queue := []string{stepID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, dep := range dag.Nodes[id].Dependents {
+			if !steps[dep] {
+				steps[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return steps
+}
+
+// applyStepOverrides 将分叉执行的步骤覆盖应用到工作流定义
+func applyStepOverrides(def *WorkflowDefinition, overrides map[string]workflowpkg.StepOverride) error {
+	for stepID, override := range overrides {
+		var step *StepDefinition
+		for i := range def.Steps {
+			if def.Steps[i].ID == stepID {
+				step = &def.Steps[i]
+				break
+			}
+		}
+		if step == nil {
+			return fmt.Errorf("覆盖的步骤 %s 不存在", stepID)
+		}
+
+		if len(override.Input) > 0 {
+			input := make(map[string]any, len(step.Input)+len(override.Input))
+			for k, v := range step.Input {
+				input[k] = v
+			}
+			for k, v := range override.Input {
+				input[k] = v
+			}
+			step.Input = input
+		}
+		if override.AgentID != nil {
+			step.AgentID = override.AgentID
+		}
+		if override.AgentType != "" {
+			step.AgentType = override.AgentType
+			// 只指定 Agent 类型时不再使用原定义中的具体 Agent
+			if override.AgentID == nil {
+				step.AgentID = nil
+			}
+		}
+		if override.Role != "" {
+			step.Role = override.Role
+		}
+		if override.SystemPromptOverride != "" {
+			step.SystemPromptOverride = override.SystemPromptOverride
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"backend/internal/workflow"
+)
+
+func TestDownstreamSteps(t *testing.T) {
+	// outline -> chapter1 -> review, outline -> chapter2 -> review
+	def := &WorkflowDefinition{Steps: []StepDefinition{
+		{ID: "outline"},
+		{ID: "chapter1", DependsOn: []string{"outline"}},
+		{ID: "chapter2", DependsOn: []string{"outline"}},
+		{ID: "review", DependsOn: []string{"chapter1", "chapter2"}},
+	}}
+	dag, err := NewParser().BuildDAG(def)
+	if err != nil {
+		t.Fatalf("构建 DAG 失败: %v", err)
+	}
+
+	steps := downstreamSteps(dag, "chapter1")
+	if len(steps) != 2 || !steps["chapter1"] || !steps["review"] {
+		t.Fatalf("下游步骤错误: %v", steps)
+	}
+}
+
+func TestApplyStepOverrides(t *testing.T) {
+	agentID := "agent-1"
+	def := &WorkflowDefinition{Steps: []StepDefinition{
+		{ID: "chapter1", AgentType: "writer", AgentID: &agentID, Input: map[string]any{"topic": "a", "length": 3000}},
+	}}
+
+	err := applyStepOverrides(def, map[string]workflow.StepOverride{
+		"chapter1": {Input: map[string]any{"topic": "b"}, AgentType: "editor"},
+	})
+	if err != nil {
+		t.Fatalf("应用覆盖失败: %v", err)
+	}
+	step := def.Steps[0]
+	if step.Input["topic"] != "b" || step.Input["length"] != 3000 {
+		t.Fatalf("输入应合并覆盖: %v", step.Input)
+	}
+	if step.AgentType != "editor" || step.AgentID != nil {
+		t.Fatalf("Agent 配置未覆盖: %s %v", step.AgentType, step.AgentID)
+	}
+
+	if err := applyStepOverrides(def, map[string]workflow.StepOverride{"missing": {}}); err == nil {
+		t.Fatalf("覆盖不存在的步骤应报错")
+	}
+}
+
+func TestEngineForkReusesUpstreamOutputs(t *testing.T) {
+	db := setupEngineTestDB(t)
+	if err := db.AutoMigrate(&workflow.WorkflowTask{}); err != nil {
+		t.Fatalf("迁移 schema 失败: %v", err)
+	}
+	queueClient := &fakeQueueClient{}
+	engine := NewEngine(db, nil, queueClient, noopAuditService{})
+	ctx := context.Background()
+
+	// outline -> chapter1 -> review, outline -> chapter2 -> review
+	node := func(id string) workflow.Node {
+		return workflow.Node{ID: id, Type: workflow.NodeTypeAgent, Data: workflow.NodeData{Label: id}}
+	}
+	wf := &workflow.Workflow{
+		ID:       "wf-novel",
+		TenantID: "tenant-1",
+		Name:     "长篇写作",
+		Definition: workflow.WorkflowDefinition{
+			Nodes: []workflow.Node{node("outline"), node("chapter1"), node("chapter2"), node("review")},
+			Edges: []workflow.Edge{
+				{ID: "e1", Source: "outline", Target: "chapter1"},
+				{ID: "e2", Source: "outline", Target: "chapter2"},
+				{ID: "e3", Source: "chapter1", Target: "review"},
+				{ID: "e4", Source: "chapter2", Target: "review"},
+			},
+		},
+		Version:    "v1",
+		Visibility: "personal",
+	}
+	if err := db.Create(wf).Error; err != nil {
+		t.Fatalf("写入 workflow 失败: %v", err)
+	}
+
+	// chapter2 没有步骤记录（检查点上线前的执行），从父执行的最终输出中取值
+	parent := &workflow.WorkflowExecution{
+		ID:         "exec-parent",
+		WorkflowID: wf.ID,
+		TenantID:   "tenant-1",
+		UserID:     "user-1",
+		Status:     "completed",
+		Input:      map[string]any{"topic": "青云志"},
+		Output:     map[string]any{"chapter2": "第二章正文"},
+	}
+	if err := db.Create(parent).Error; err != nil {
+		t.Fatalf("写入父执行失败: %v", err)
+	}
+	for step, output := range map[string]string{"outline": "大纲", "chapter1": "第一章正文", "review": "审校意见"} {
+		if err := db.Create(&workflow.WorkflowTask{
+			ID:          "task-" + step,
+			TenantID:    "tenant-1",
+			ExecutionID: parent.ID,
+			StepID:      step,
+			Status:      "success",
+			Output:      map[string]any{checkpointOutputKey: output},
+		}).Error; err != nil {
+			t.Fatalf("写入步骤记录失败: %v", err)
+		}
+	}
+
+	override := &workflow.StepOverride{Input: map[string]any{"style": "更紧凑"}}
+	result, err := engine.Fork(ctx, "tenant-1", "user-2", parent.ID, "chapter1", override)
+	if err != nil {
+		t.Fatalf("Fork 失败: %v", err)
+	}
+	if queueClient.lastPayload.ExecutionID != result.ExecutionID || queueClient.lastPayload.Input["topic"] != "青云志" {
+		t.Fatalf("分叉执行未入队: %+v", queueClient.lastPayload)
+	}
+
+	var forked workflow.WorkflowExecution
+	if err := db.First(&forked, "id = ?", result.ExecutionID).Error; err != nil {
+		t.Fatalf("读取分叉执行失败: %v", err)
+	}
+	if forked.Status != "queued" || forked.ParentExecutionID == nil || *forked.ParentExecutionID != parent.ID ||
+		forked.ForkedFromStep != "chapter1" || forked.UserID != "user-2" ||
+		forked.StepOverrides["chapter1"].Input["style"] != "更紧凑" {
+		t.Fatalf("分叉执行记录错误: %+v", forked)
+	}
+
+	// 只复用 chapter1 上游与旁路的步骤，chapter1 与 review 重新执行
+	checkpoints, err := loadCheckpoints(ctx, db, forked.ID)
+	if err != nil {
+		t.Fatalf("加载检查点失败: %v", err)
+	}
+	if len(checkpoints) != 2 || checkpoints["outline"] == nil || checkpoints["chapter2"] == nil {
+		t.Fatalf("复用的检查点错误: %v", checkpoints)
+	}
+	if checkpoints["outline"].Output != "大纲" || checkpoints["chapter2"].Output != "第二章正文" {
+		t.Fatalf("复用的输出错误: outline=%v chapter2=%v", checkpoints["outline"].Output, checkpoints["chapter2"].Output)
+	}
+	if checkpoints["outline"].Metadata["reused_from_execution"] != parent.ID {
+		t.Fatalf("检查点应标记来源执行: %v", checkpoints["outline"].Metadata)
+	}
+
+	// 父执行不存在、属于其他租户、尚未结束或步骤不存在时拒绝分叉
+	if _, err := engine.Fork(ctx, "tenant-2", "user-2", parent.ID, "chapter1", nil); !errors.Is(err, ErrExecutionNotFound) {
+		t.Fatalf("其他租户的执行应视为不存在: %v", err)
+	}
+	if _, err := engine.Fork(ctx, "tenant-1", "user-2", parent.ID, "missing", nil); !errors.Is(err, ErrInvalidFork) {
+		t.Fatalf("不存在的步骤应拒绝分叉: %v", err)
+	}
+	if _, err := engine.Fork(ctx, "tenant-1", "user-2", forked.ID, "chapter1", nil); !errors.Is(err, ErrInvalidFork) {
+		t.Fatalf("未结束的执行应拒绝分叉: %v", err)
+	}
+
+	// 入队失败时分叉执行标记为失败
+	queueClient.enqueueErr = errors.New("queue down")
+	if _, err := engine.Fork(ctx, "tenant-1", "user-2", parent.ID, "review", nil); err == nil {
+		t.Fatal("入队失败时应返回错误")
+	}
+	var failed workflow.WorkflowExecution
+	if err := db.Where("parent_execution_id = ? AND forked_from_step = ?", parent.ID, "review").First(&failed).Error; err != nil {
+		t.Fatalf("读取分叉执行失败: %v", err)
+	}
+	if failed.Status != "failed" {
+		t.Fatalf("入队失败的分叉执行应标记为失败: %+v", failed)
+	}
+}
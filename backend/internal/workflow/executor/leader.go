@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SchedulerLease 后台任务的领导者租约，同一名称同一时刻只有一个实例持有
+type SchedulerLease struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:100"`
+	HolderID  string    `json:"holderId" gorm:"size:200;not null"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (SchedulerLease) TableName() string {
+	return "scheduler_leases"
+}
+
+// LeaderElector 基于数据库租约的领导者选举：持有者按间隔续约，租约过期后其他实例可以接管
+type LeaderElector struct {
+	db       *gorm.DB
+	name     string
+	holderID string
+	ttl      time.Duration
+}
+
+// NewLeaderElector 创建领导者选举器
+func NewLeaderElector(db *gorm.DB, name string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{
+		db:       db,
+		name:     name,
+		holderID: newInstanceID(),
+		ttl:      ttl,
+	}
+}
+
+// newInstanceID 进程实例标识，由主机名与随机后缀组成
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%s", host, uuid.New().String()[:8])
+}
+
+// HolderID 当前实例标识
+func (l *LeaderElector) HolderID() string {
+	return l.holderID
+}
+
+// TryAcquire 获取或续约租约，返回当前实例是否为领导者
+func (l *LeaderElector) TryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(l.ttl)
+
+	// 续约自己的租约或接管已过期的租约
+	result := l.db.WithContext(ctx).Model(&SchedulerLease{}).
+		Where("name = ? AND (holder_id = ? OR expires_at < ?)", l.name, l.holderID, now).
+		Updates(map[string]any{"holder_id": l.holderID, "expires_at": expiresAt})
+	if result.Error != nil {
+		return false, fmt.Errorf("续约调度租约失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// 租约尚不存在
+	result = l.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&SchedulerLease{Name: l.name, HolderID: l.holderID, ExpiresAt: expiresAt})
+	if result.Error != nil {
+		return false, fmt.Errorf("创建调度租约失败: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Release 释放自己持有的租约，便于其他实例立即接管
+func (l *LeaderElector) Release(ctx context.Context) error {
+	return l.db.WithContext(ctx).
+		Where("name = ? AND holder_id = ?", l.name, l.holderID).
+		Delete(&SchedulerLease{}).Error
+}
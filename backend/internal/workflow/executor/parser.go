@@ -75,6 +75,12 @@ type StepDefinition struct {
 
 	MapReduce *MapReduceConfig `json:"map_reduce"` // Map-Reduce 配置（可选）
 
+	SubWorkflow *SubWorkflowConfig `json:"sub_workflow"` // 子工作流配置（type=workflow）
+
+	// 结构化输出：Agent 输出必须符合的 JSON Schema（可选，优先于 Agent 配置）
+	// 输出为 JSON，后续步骤和条件可通过 <step_id>.output.<field> 引用字段
+	OutputSchema map[string]any `json:"output_schema"`
+
 	ExtraConfig map[string]any `json:"extra_config"` // 额外配置
 }
 
@@ -87,13 +93,31 @@ type MapReduceConfig struct {
 	ReducerAgent   string `json:"reducer_agent"`   // (可选) 汇总 Agent ID
 }
 
+// SubWorkflowConfig 子工作流配置，步骤输入作为子工作流的输入
+type SubWorkflowConfig struct {
+	WorkflowID string `json:"workflow_id"`
+	Version    int    `json:"version"` // 定义版本，0 表示已发布版本
+	Async      bool   `json:"async"`   // 为 true 时提交后立即返回，不等待子工作流结束
+}
+
 // ApprovalConfig 审批配置
 type ApprovalConfig struct {
-	Type           string              `json:"type"`            // required（强制）、optional（可选）、conditional（条件）
-	AutoApproveIf  *Condition          `json:"auto_approve_if"` // 自动批准条件
-	TimeoutSeconds int                 `json:"timeout_seconds"` // 审批超时时间（秒）
-	NotifyChannels []string            `json:"notify_channels"` // 通知渠道（email、webhook、websocket）
-	NotifyTargets  map[string][]string `json:"notify_targets"`  // 通知目标配置（email/webhook/websocket）
+	Type           string                `json:"type"`            // required（强制）、optional（可选）、conditional（条件）
+	AutoApproveIf  *Condition            `json:"auto_approve_if"` // 自动批准条件
+	TimeoutSeconds int                   `json:"timeout_seconds"` // 审批超时时间（秒）
+	NotifyChannels []string              `json:"notify_channels"` // 通知渠道（email、webhook、websocket）
+	NotifyTargets  map[string][]string   `json:"notify_targets"`  // 通知目标配置（email/webhook/websocket）
+	Stages         []ApprovalStageConfig `json:"stages"`          // 多级审批阶段，按顺序逐级审批
+}
+
+// ApprovalStageConfig 审批阶段配置
+type ApprovalStageConfig struct {
+	Name              string   `json:"name"`
+	Approvers         []string `json:"approvers"`          // 审批人：user:<id>、role:<code>
+	RequiredApprovals int      `json:"required_approvals"` // 通过所需人数（N of M），默认 1
+	TimeoutSeconds    int      `json:"timeout_seconds"`    // 阶段超时，默认沿用 timeout_seconds
+	EscalateTo        []string `json:"escalate_to"`        // 超时后依次升级的审批人
+	TimeoutAction     string   `json:"timeout_action"`     // 升级耗尽后的动作：timeout（默认）、approve、reject
 }
 
 // QualityCheckConfig 质量检查配置
@@ -199,6 +223,17 @@ func (p *Parser) Parse(def workflowpkg.WorkflowDefinition) (*WorkflowDefinition,
 			step.Output = "output" // 默认输出变量名
 		}
 
+		// 转换子工作流配置
+		if node.Type == workflowpkg.NodeTypeWorkflow {
+			step.SubWorkflow = &SubWorkflowConfig{
+				WorkflowID: node.Data.WorkflowID,
+				Version:    node.Data.WorkflowVersion,
+				Async:      node.Data.Async,
+			}
+			step.Timeout = node.Data.Timeout // 等待子执行完成的超时时间
+			step.Output = "output"
+		}
+
 		// 设置依赖
 		if deps, ok := dependencies[node.ID]; ok {
 			step.DependsOn = deps
@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/worker/tasks"
+	workflowpkg "backend/internal/workflow"
+
+	"go.uber.org/zap"
+)
+
+// 执行租约与恢复参数
+const (
+	executionLeaseTTL       = 2 * time.Minute
+	defaultRecoveryInterval = time.Minute
+	recoveryBatchSize       = 100
+
+	// legacyRunningTimeout 没有租约的 running 执行（租约上线前启动）超过该时长视为孤立
+	legacyRunningTimeout = 30 * time.Minute
+
+	// maxExecutionRecoveries 单个执行最多恢复的次数，超过后标记为失败，避免反复崩溃的执行无限重试
+	maxExecutionRecoveries = 3
+)
+
+// leasableExecutionStatuses 可以被 Worker 获取租约的执行状态
+var leasableExecutionStatuses = []string{"pending", "queued", "running"}
+
+// acquireLease 以条件更新获取执行租约并将状态置为 running：
+// 执行未结束且租约为空、已过期或本就由当前实例持有时成功
+func (e *Engine) acquireLease(ctx context.Context, execution *workflowpkg.WorkflowExecution) (bool, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(e.leaseTTL)
+	result := e.db.WithContext(ctx).Model(&workflowpkg.WorkflowExecution{}).
+		Where("id = ? AND status IN ?", execution.ID, leasableExecutionStatuses).
+		Where("lease_expires_at IS NULL OR lease_expires_at < ? OR lease_owner = ?", now, e.workerID).
+		Updates(map[string]any{
+			"status":           "running",
+			"lease_owner":      e.workerID,
+			"lease_expires_at": expiresAt,
+			"heartbeat_at":     now,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	execution.Status = "running"
+	execution.LeaseOwner = e.workerID
+	execution.LeaseExpiresAt = &expiresAt
+	execution.HeartbeatAt = &now
+	return true, nil
+}
+
+// keepLease 按租约有效期的三分之一续约；租约被其他实例接管时取消执行并标记丢失
+func (e *Engine) keepLease(ctx context.Context, cancel context.CancelFunc, executionID string) *atomic.Bool {
+	lost := &atomic.Bool{}
+	go func() {
+		ticker := time.NewTicker(e.leaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				renewed, err := e.renewLease(ctx, executionID)
+				if err != nil {
+					// 数据库暂时不可用时继续尝试，租约在有效期内仍然有效
+					if ctx.Err() == nil {
+						logger.Warn("执行租约续约失败", zap.String("execution_id", executionID), zap.Error(err))
+					}
+					continue
+				}
+				if !renewed {
+					lost.Store(true)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return lost
+}
+
+// renewLease 续约执行租约，返回当前实例是否仍持有租约
+func (e *Engine) renewLease(ctx context.Context, executionID string) (bool, error) {
+	now := time.Now().UTC()
+	result := e.db.WithContext(ctx).Model(&workflowpkg.WorkflowExecution{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", executionID, "running", e.workerID).
+		Updates(map[string]any{
+			"lease_expires_at": now.Add(e.leaseTTL),
+			"heartbeat_at":     now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// StartRecovery 按间隔扫描孤立的执行并从检查点恢复
+func (e *Engine) StartRecovery(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRecoveryInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := e.RecoverExecutions(ctx); err != nil {
+					logger.Warn("恢复工作流执行失败", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// RecoverExecutions 将租约过期的 running 执行重新入队，由 Worker 从最近的步骤检查点继续，返回恢复的执行数。
+// 多实例同时扫描时以条件更新认领，同一执行只会被恢复一次
+func (e *Engine) RecoverExecutions(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	var executions []workflowpkg.WorkflowExecution
+	if err := e.db.WithContext(ctx).
+		Where("status = ?", "running").
+		Where("(lease_expires_at IS NOT NULL AND lease_expires_at < ?) OR (lease_expires_at IS NULL AND started_at < ?)",
+			now, now.Add(-legacyRunningTimeout)).
+		Order("started_at").
+		Limit(recoveryBatchSize).
+		Find(&executions).Error; err != nil {
+		return 0, err
+	}
+
+	recovered := 0
+	for i := range executions {
+		ok, err := e.recoverExecution(ctx, &executions[i], now)
+		if err != nil {
+			logger.Warn("恢复工作流执行失败", zap.String("execution_id", executions[i].ID), zap.Error(err))
+			continue
+		}
+		if ok {
+			recovered++
+		}
+	}
+	return recovered, nil
+}
+
+// recoverExecution 恢复单个孤立执行，返回是否重新提交
+func (e *Engine) recoverExecution(ctx context.Context, execution *workflowpkg.WorkflowExecution, now time.Time) (bool, error) {
+	orphaned := e.db.WithContext(ctx).Model(&workflowpkg.WorkflowExecution{}).
+		Where("id = ? AND status = ?", execution.ID, "running").
+		Where("lease_expires_at IS NULL OR lease_expires_at < ?", now)
+
+	if execution.RecoveryCount >= maxExecutionRecoveries {
+		message := fmt.Sprintf("执行已恢复 %d 次仍未完成，停止恢复", execution.RecoveryCount)
+		result := orphaned.Updates(map[string]any{
+			"status":           "failed",
+			"error_message":    message,
+			"completed_at":     now,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return false, result.Error
+		}
+		e.updateWorkflowStats(ctx, execution.WorkflowID, "failed")
+		execution.Status = "failed"
+		execution.ErrorMessage = message
+		execution.CompletedAt = &now
+		e.notifyFinished(ctx, execution)
+		return false, nil
+	}
+
+	result := orphaned.Updates(map[string]any{
+		"status":           "queued",
+		"lease_owner":      "",
+		"lease_expires_at": nil,
+		"recovery_count":   execution.RecoveryCount + 1,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	logger.Info("重新提交孤立的工作流执行",
+		zap.String("execution_id", execution.ID),
+		zap.String("previous_owner", execution.LeaseOwner),
+		zap.Int("recovery_count", execution.RecoveryCount+1))
+
+	if e.queueClient == nil {
+		go func() {
+			if err := e.RunExecution(context.Background(), execution.ID); err != nil {
+				logger.Warn("恢复执行失败", zap.String("execution_id", execution.ID), zap.Error(err))
+			}
+		}()
+		return true, nil
+	}
+
+	payload := tasks.ExecuteWorkflowPayload{
+		ExecutionID: execution.ID,
+		WorkflowID:  execution.WorkflowID,
+		TenantID:    execution.TenantID,
+		UserID:      execution.UserID,
+		Input:       execution.Input,
+	}
+	if err := e.queueClient.EnqueueExecuteWorkflow(payload); err != nil {
+		return false, e.failExecution(ctx, execution, fmt.Errorf("恢复任务入队失败: %w", err))
+	}
+	return true, nil
+}
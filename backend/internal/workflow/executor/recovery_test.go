@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/workflow"
+
+	"gorm.io/gorm"
+)
+
+const recoveryTestTenant = "tenant-recovery"
+
+func setupRecoveryEngine(t *testing.T) (*Engine, *fakeQueueClient, *gorm.DB) {
+	t.Helper()
+	_ = logger.Init("error", "console", "stdout")
+	db := setupEngineTestDB(t)
+	createTestWorkflow(t, db, recoveryTestTenant, "wf-recovery")
+	queueClient := &fakeQueueClient{}
+	return NewEngine(db, nil, queueClient, noopAuditService{}), queueClient, db
+}
+
+// createLeasedExecution 创建 running 执行，leaseExpiresAt 为 nil 时表示没有租约
+func createLeasedExecution(t *testing.T, db *gorm.DB, id, owner string, leaseExpiresAt *time.Time, recoveryCount int) {
+	t.Helper()
+	startedAt := time.Now().UTC().Add(-time.Hour)
+	execution := &workflow.WorkflowExecution{
+		ID:             id,
+		WorkflowID:     "wf-recovery",
+		TenantID:       recoveryTestTenant,
+		UserID:         "user-recovery",
+		Status:         "running",
+		StartedAt:      &startedAt,
+		LeaseOwner:     owner,
+		LeaseExpiresAt: leaseExpiresAt,
+		RecoveryCount:  recoveryCount,
+	}
+	if err := db.Create(execution).Error; err != nil {
+		t.Fatalf("写入执行记录失败: %v", err)
+	}
+}
+
+func loadExecution(t *testing.T, db *gorm.DB, id string) workflow.WorkflowExecution {
+	t.Helper()
+	var execution workflow.WorkflowExecution
+	if err := db.First(&execution, "id = ?", id).Error; err != nil {
+		t.Fatalf("读取执行记录失败: %v", err)
+	}
+	return execution
+}
+
+func TestRecoverExecutionsRequeuesExpiredLease(t *testing.T) {
+	engine, queueClient, db := setupRecoveryEngine(t)
+	expired := time.Now().UTC().Add(-time.Minute)
+	createLeasedExecution(t, db, "exec-expired", "worker-crashed", &expired, 0)
+
+	recovered, err := engine.RecoverExecutions(context.Background())
+	if err != nil || recovered != 1 {
+		t.Fatalf("租约过期的执行应被恢复: recovered=%d err=%v", recovered, err)
+	}
+	execution := loadExecution(t, db, "exec-expired")
+	if execution.Status != "queued" || execution.LeaseOwner != "" || execution.LeaseExpiresAt != nil || execution.RecoveryCount != 1 {
+		t.Fatalf("恢复后应重新入队并清空租约: %+v", execution)
+	}
+	if queueClient.lastPayload.ExecutionID != "exec-expired" || queueClient.lastPayload.TenantID != recoveryTestTenant {
+		t.Fatalf("恢复任务入队 payload 错误: %+v", queueClient.lastPayload)
+	}
+
+	// 其他实例使用扫描时的旧记录再次认领：执行已不是 running，不重复恢复
+	stale := execution
+	stale.Status = "running"
+	other := NewEngine(db, nil, &fakeQueueClient{}, noopAuditService{})
+	if ok, err := other.recoverExecution(context.Background(), &stale, time.Now().UTC()); ok || err != nil {
+		t.Fatalf("已被认领的执行不应再次恢复: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestRecoverExecutionsSkipsLiveLease(t *testing.T) {
+	engine, queueClient, db := setupRecoveryEngine(t)
+	live := time.Now().UTC().Add(time.Minute)
+	createLeasedExecution(t, db, "exec-live", "worker-alive", &live, 0)
+
+	recovered, err := engine.RecoverExecutions(context.Background())
+	if err != nil || recovered != 0 {
+		t.Fatalf("租约有效的执行不应恢复: recovered=%d err=%v", recovered, err)
+	}
+	execution := loadExecution(t, db, "exec-live")
+	if execution.Status != "running" || execution.LeaseOwner != "worker-alive" || execution.RecoveryCount != 0 {
+		t.Fatalf("租约有效的执行应保持不变: %+v", execution)
+	}
+	if queueClient.lastPayload.ExecutionID != "" {
+		t.Fatalf("租约有效的执行不应入队: %+v", queueClient.lastPayload)
+	}
+}
+
+func TestRecoverExecutionsFailsAfterMaxRecoveries(t *testing.T) {
+	engine, queueClient, db := setupRecoveryEngine(t)
+	expired := time.Now().UTC().Add(-time.Minute)
+	createLeasedExecution(t, db, "exec-crashing", "worker-crashed", &expired, maxExecutionRecoveries)
+
+	recovered, err := engine.RecoverExecutions(context.Background())
+	if err != nil || recovered != 0 {
+		t.Fatalf("超过恢复次数的执行不应重新提交: recovered=%d err=%v", recovered, err)
+	}
+	execution := loadExecution(t, db, "exec-crashing")
+	if execution.Status != "failed" || execution.ErrorMessage == "" || execution.CompletedAt == nil ||
+		execution.LeaseOwner != "" || execution.RecoveryCount != maxExecutionRecoveries {
+		t.Fatalf("超过恢复次数应标记为失败: %+v", execution)
+	}
+	if queueClient.lastPayload.ExecutionID != "" {
+		t.Fatalf("超过恢复次数的执行不应入队: %+v", queueClient.lastPayload)
+	}
+}
+
+func TestRunExecutionSkipsExecutionLeasedByAnotherWorker(t *testing.T) {
+	engine, _, db := setupRecoveryEngine(t)
+	createLeasedExecution(t, db, "exec-leased", "", nil, 0)
+	db.Model(&workflow.WorkflowExecution{}).Where("id = ?", "exec-leased").Update("status", "queued")
+
+	execution := loadExecution(t, db, "exec-leased")
+	acquired, err := engine.acquireLease(context.Background(), &execution)
+	if err != nil || !acquired {
+		t.Fatalf("空闲执行应获取租约: acquired=%v err=%v", acquired, err)
+	}
+	// 持有者可以重新获取自己的租约
+	if acquired, err := engine.acquireLease(context.Background(), &execution); err != nil || !acquired {
+		t.Fatalf("持有者应能重新获取租约: acquired=%v err=%v", acquired, err)
+	}
+
+	// 同一任务被投递到第二个 Worker：租约有效时跳过，不覆盖持有者
+	second := NewEngine(db, nil, &fakeQueueClient{}, noopAuditService{})
+	if err := second.RunExecution(context.Background(), "exec-leased"); err != nil {
+		t.Fatalf("租约被占用时应跳过而不是报错: %v", err)
+	}
+	execution = loadExecution(t, db, "exec-leased")
+	if execution.Status != "running" || execution.LeaseOwner != engine.workerID {
+		t.Fatalf("第二个 Worker 不应接管执行: %+v", execution)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"context"
 	"fmt"
 	"sync"
+
+	"backend/internal/logger"
+
+	"go.uber.org/zap"
 )
 
 // Scheduler 任务调度器
@@ -11,7 +15,8 @@ type Scheduler struct {
 	dag            *DAG
 	executor       TaskExecutor
 	maxConcurrency int
-	templateEngine *TemplateEngine // 模板引擎
+	templateEngine *TemplateEngine  // 模板引擎
+	checkpointer   StepCheckpointer // 步骤检查点（可选）
 }
 
 // TaskExecutor 任务执行器接口
@@ -58,6 +63,11 @@ func (s *Scheduler) SetTemplateEngine(engine *TemplateEngine) {
 	s.templateEngine = engine
 }
 
+// SetCheckpointer 设置步骤检查点，每个步骤结束后持久化其结果
+func (s *Scheduler) SetCheckpointer(checkpointer StepCheckpointer) {
+	s.checkpointer = checkpointer
+}
+
 // Schedule 调度执行 (Event-driven / Kahn's Algorithm)
 func (s *Scheduler) Schedule(ctx context.Context, execCtx *ExecutionContext) (map[string]*TaskResult, error) {
 	// 1. 初始化状态
@@ -151,6 +161,7 @@ func (s *Scheduler) Schedule(ctx context.Context, execCtx *ExecutionContext) (ma
 		case res := <-doneChan:
 			completedTasks++
 			results[res.ID] = res
+			s.checkpoint(ctx, execCtx, res)
 
 			if res.Status == "success" {
 				// 任务成功
@@ -323,6 +334,7 @@ func (s *Scheduler) Resume(ctx context.Context, execCtx *ExecutionContext, previ
 		case res := <-doneChan:
 			completedTasks++
 			results[res.ID] = res
+			s.checkpoint(ctx, execCtx, res)
 
 			if res.Status == "success" {
 				// 任务成功
@@ -349,6 +361,24 @@ func (s *Scheduler) Resume(ctx context.Context, execCtx *ExecutionContext, previ
 	return results, nil
 }
 
+// checkpoint 持久化步骤结果；失败只记录日志，不影响工作流继续执行
+func (s *Scheduler) checkpoint(ctx context.Context, execCtx *ExecutionContext, res *TaskResult) {
+	if s.checkpointer == nil {
+		return
+	}
+	var step *StepDefinition
+	if node, ok := s.dag.Nodes[res.ID]; ok {
+		step = node.Step
+	}
+	// 取消执行时已完成步骤的结果仍需保存
+	if err := s.checkpointer.SaveCheckpoint(context.WithoutCancel(ctx), execCtx, step, res); err != nil {
+		logger.Warn("保存步骤检查点失败",
+			zap.String("execution_id", execCtx.ExecutionID),
+			zap.String("step_id", res.ID),
+			zap.Error(err))
+	}
+}
+
 // executeTask 执行单个任务
 func (s *Scheduler) executeTask(
 	ctx context.Context,
@@ -2,6 +2,7 @@ package executor
 
 import (
 	"context"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -235,3 +236,42 @@ func (m *mockTaskExecutor) ExecuteTask(ctx context.Context, task *Task) (*TaskRe
 	}, nil
 }
 
+// recordingCheckpointer 记录保存的检查点
+type recordingCheckpointer struct {
+	mu    sync.Mutex
+	saved []string
+}
+
+func (c *recordingCheckpointer) SaveCheckpoint(_ context.Context, _ *ExecutionContext, step *StepDefinition, result *TaskResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.saved = append(c.saved, step.ID+":"+result.Status)
+	return nil
+}
+
+// TestSchedulerResumeCheckpointsOnlyNewSteps 测试恢复执行时只为新执行的步骤保存检查点
+func TestSchedulerResumeCheckpointsOnlyNewSteps(t *testing.T) {
+	dag := &DAG{
+		Nodes: map[string]*Node{
+			"step1": {ID: "step1", Step: &StepDefinition{ID: "step1", Type: "test"}, Dependents: []string{"step2"}},
+			"step2": {ID: "step2", Step: &StepDefinition{ID: "step2", Type: "test"}, Dependencies: []string{"step1"}},
+		},
+	}
+	executor := &mockTaskExecutor{
+		results: map[string]*TaskResult{
+			"step2": {ID: "step2", Status: "success", Output: "step2_output"},
+		},
+	}
+	checkpointer := &recordingCheckpointer{}
+	scheduler := NewScheduler(dag, executor, 5)
+	scheduler.SetCheckpointer(checkpointer)
+
+	execCtx := NewExecutionContext("test-workflow", "test-execution", "test-tenant", "test-user")
+	previousResults := map[string]*TaskResult{
+		"step1": {ID: "step1", Status: "success", Output: "step1_output"},
+	}
+	_, err := scheduler.Resume(context.Background(), execCtx, previousResults)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"step2:success"}, checkpointer.saved)
+}
@@ -0,0 +1,267 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/logger"
+	"backend/internal/worker/tasks"
+	workflowpkg "backend/internal/workflow"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SubWorkflowStepType 子工作流步骤类型
+const SubWorkflowStepType = "workflow"
+
+const (
+	// maxSubWorkflowDepth 子工作流最大嵌套层数
+	maxSubWorkflowDepth = 5
+
+	// subWorkflowPollInterval 子工作流由其他 Worker 执行时查询状态的间隔
+	subWorkflowPollInterval = 2 * time.Second
+)
+
+// subWorkflowExecutor 执行 type=workflow 的步骤，其余步骤交给 next
+type subWorkflowExecutor struct {
+	engine *Engine
+	next   TaskExecutor
+}
+
+// withSubWorkflows 为任务执行器增加子工作流步骤支持
+func (e *Engine) withSubWorkflows(next TaskExecutor) TaskExecutor {
+	return &subWorkflowExecutor{engine: e, next: next}
+}
+
+// ExecuteTask 实现 TaskExecutor 接口
+func (x *subWorkflowExecutor) ExecuteTask(ctx context.Context, task *Task) (*TaskResult, error) {
+	if task.Step.Type != SubWorkflowStepType {
+		return x.next.ExecuteTask(ctx, task)
+	}
+	return x.engine.runSubWorkflow(ctx, task), nil
+}
+
+// runSubWorkflow 以步骤输入启动子工作流：等待模式下在当前 Worker 内执行并以子工作流输出作为步骤输出，
+// 调用方取消或超时时子工作流一并终止；异步模式只提交执行，步骤输出为子执行 ID
+func (e *Engine) runSubWorkflow(ctx context.Context, task *Task) *TaskResult {
+	start := time.Now()
+	cfg := task.Step.SubWorkflow
+	if cfg == nil || cfg.WorkflowID == "" {
+		return &TaskResult{ID: task.ID, Status: "failed", Error: fmt.Errorf("子工作流步骤 %s 缺少 workflow_id", task.ID)}
+	}
+
+	child, err := e.startSubWorkflow(ctx, task.Context, task.Step, task.Input)
+	if err != nil {
+		return &TaskResult{ID: task.ID, Status: "failed", Error: err}
+	}
+	metadata := map[string]any{
+		"child_execution_id":     child.ID,
+		"child_workflow_id":      child.WorkflowID,
+		"child_workflow_version": child.WorkflowVersion,
+		"async":                  cfg.Async,
+	}
+
+	if cfg.Async {
+		return &TaskResult{
+			ID: task.ID,
+			Output: map[string]any{
+				"execution_id": child.ID,
+				"workflow_id":  child.WorkflowID,
+				"status":       child.Status,
+			},
+			Status:   "success",
+			Metadata: metadata,
+		}
+	}
+
+	waitCtx := ctx
+	if task.Step.Timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(task.Step.Timeout)*time.Second)
+		defer cancel()
+	}
+	finished, err := e.awaitSubWorkflow(waitCtx, child)
+	metadata["latency_ms"] = time.Since(start).Milliseconds()
+	if err != nil {
+		if waitCtx.Err() != nil {
+			e.cancelSubWorkflow(child.ID, waitCtx.Err())
+		}
+		return &TaskResult{ID: task.ID, Status: "failed", Error: fmt.Errorf("子工作流执行中断: %w", err), Metadata: metadata}
+	}
+	if finished.Status != "completed" {
+		return &TaskResult{
+			ID:       task.ID,
+			Status:   "failed",
+			Error:    fmt.Errorf("子工作流执行失败: %s", finished.ErrorMessage),
+			Metadata: metadata,
+		}
+	}
+	return &TaskResult{ID: task.ID, Output: finished.Output, Status: "success", Metadata: metadata}
+}
+
+// startSubWorkflow 创建子执行记录，异步模式同时入队；
+// 调用方从检查点恢复时复用该步骤已创建且未失败、未取消的子执行，避免重复执行
+func (e *Engine) startSubWorkflow(ctx context.Context, caller *ExecutionContext, step *StepDefinition, input map[string]any) (*workflowpkg.WorkflowExecution, error) {
+	cfg := step.SubWorkflow
+
+	var existing workflowpkg.WorkflowExecution
+	err := e.db.WithContext(ctx).
+		Where("caller_execution_id = ? AND caller_step_id = ? AND status NOT IN ?", caller.ExecutionID, step.ID, []string{"failed", "cancelled"}).
+		Order("created_at DESC").
+		First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询子执行失败: %w", err)
+	}
+
+	if err := e.checkCallChain(ctx, caller.ExecutionID, cfg.WorkflowID); err != nil {
+		return nil, err
+	}
+
+	// 子工作流与调用方属于同一租户，以调用方用户身份执行
+	var workflow workflowpkg.Workflow
+	if err := e.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ? AND deleted_at IS NULL", cfg.WorkflowID, caller.TenantID).
+		First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("子工作流不存在: %s", cfg.WorkflowID)
+		}
+		return nil, fmt.Errorf("查询子工作流失败: %w", err)
+	}
+	pinned, err := workflowpkg.ResolveVersion(ctx, e.db, &workflow, cfg.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	traceID := caller.TraceID
+	if traceID == "" {
+		traceID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	callerID := caller.ExecutionID
+	child := &workflowpkg.WorkflowExecution{
+		ID:                uuid.New().String(),
+		WorkflowID:        workflow.ID,
+		TenantID:          caller.TenantID,
+		UserID:            caller.UserID,
+		Status:            "queued",
+		Input:             input,
+		StartedAt:         &now,
+		TraceID:           traceID,
+		WorkflowVersion:   pinned.Number,
+		CallerExecutionID: &callerID,
+		CallerStepID:      step.ID,
+	}
+	if err := e.db.WithContext(ctx).Create(child).Error; err != nil {
+		return nil, fmt.Errorf("创建子执行记录失败: %w", err)
+	}
+
+	if !cfg.Async {
+		return child, nil
+	}
+	if e.queueClient == nil {
+		go func() {
+			if err := e.RunExecution(context.Background(), child.ID); err != nil {
+				logger.Warn("子工作流执行失败", zap.String("execution_id", child.ID), zap.Error(err))
+			}
+		}()
+		return child, nil
+	}
+	payload := tasks.ExecuteWorkflowPayload{
+		ExecutionID: child.ID,
+		WorkflowID:  child.WorkflowID,
+		TenantID:    child.TenantID,
+		UserID:      child.UserID,
+		Input:       child.Input,
+	}
+	if err := e.queueClient.EnqueueExecuteWorkflow(payload); err != nil {
+		return nil, e.failExecution(ctx, child, fmt.Errorf("子工作流任务入队失败: %w", err))
+	}
+	return child, nil
+}
+
+// checkCallChain 沿调用链向上检查，拒绝循环调用与超过最大嵌套层数的子工作流
+func (e *Engine) checkCallChain(ctx context.Context, callerExecutionID, workflowID string) error {
+	depth := 0
+	for id := callerExecutionID; id != ""; {
+		depth++
+		if depth > maxSubWorkflowDepth {
+			return fmt.Errorf("子工作流嵌套超过 %d 层", maxSubWorkflowDepth)
+		}
+
+		var execution workflowpkg.WorkflowExecution
+		if err := e.db.WithContext(ctx).
+			Select("id", "workflow_id", "caller_execution_id").
+			Where("id = ?", id).
+			First(&execution).Error; err != nil {
+			return fmt.Errorf("查询调用链失败: %w", err)
+		}
+		if execution.WorkflowID == workflowID {
+			return fmt.Errorf("子工作流 %s 存在循环调用", workflowID)
+		}
+
+		id = ""
+		if execution.CallerExecutionID != nil {
+			id = *execution.CallerExecutionID
+		}
+	}
+	return nil
+}
+
+// awaitSubWorkflow 在当前 Worker 内执行子工作流并等待其结束；
+// 子执行已由其他 Worker 持有租约（调用方恢复后重新进入该步骤）时按间隔查询状态
+func (e *Engine) awaitSubWorkflow(ctx context.Context, child *workflowpkg.WorkflowExecution) (*workflowpkg.WorkflowExecution, error) {
+	var runErr error
+	if !executionFinished(child.Status) {
+		runErr = e.RunExecution(ctx, child.ID)
+	}
+
+	ticker := time.NewTicker(subWorkflowPollInterval)
+	defer ticker.Stop()
+	for {
+		var current workflowpkg.WorkflowExecution
+		if err := e.db.WithContext(ctx).Where("id = ?", child.ID).First(&current).Error; err != nil {
+			return nil, fmt.Errorf("查询子执行失败: %w", err)
+		}
+		if executionFinished(current.Status) {
+			return &current, nil
+		}
+		if runErr != nil {
+			return nil, runErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancelSubWorkflow 调用方取消或超时后终止尚未结束的子执行，状态记为 cancelled 以区别于子工作流自身失败
+func (e *Engine) cancelSubWorkflow(executionID string, cause error) {
+	now := time.Now().UTC()
+	result := e.db.Model(&workflowpkg.WorkflowExecution{}).
+		Where("id = ? AND status IN ?", executionID, leasableExecutionStatuses).
+		Updates(map[string]any{
+			"status":           "cancelled",
+			"error_message":    fmt.Sprintf("调用方执行已终止: %v", cause),
+			"completed_at":     now,
+			"lease_owner":      "",
+			"lease_expires_at": nil,
+		})
+	if result.Error != nil {
+		logger.Warn("终止子工作流执行失败", zap.String("execution_id", executionID), zap.Error(result.Error))
+	}
+}
+
+// executionFinished 执行是否已结束
+func executionFinished(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
@@ -0,0 +1,220 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"backend/internal/logger"
+	"backend/internal/workflow"
+
+	"gorm.io/gorm"
+)
+
+type stubTaskExecutor struct {
+	calls int
+}
+
+func (s *stubTaskExecutor) ExecuteTask(ctx context.Context, task *Task) (*TaskResult, error) {
+	s.calls++
+	return &TaskResult{ID: task.ID, Status: "success"}, nil
+}
+
+func TestParseSubWorkflowNode(t *testing.T) {
+	def, err := NewParser().Parse(workflow.WorkflowDefinition{
+		Nodes: []workflow.Node{{
+			ID:   "review",
+			Type: workflow.NodeTypeWorkflow,
+			Data: workflow.NodeData{
+				Label:           "大纲评审",
+				WorkflowID:      "wf-review",
+				WorkflowVersion: 3,
+				Timeout:         120,
+				Inputs:          map[string]string{"outline": "{{outline.output}}"},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+
+	step := def.Steps[0]
+	if step.Type != SubWorkflowStepType || step.SubWorkflow == nil {
+		t.Fatalf("未转换为子工作流步骤: %+v", step)
+	}
+	if step.SubWorkflow.WorkflowID != "wf-review" || step.SubWorkflow.Version != 3 || step.SubWorkflow.Async {
+		t.Fatalf("子工作流配置错误: %+v", step.SubWorkflow)
+	}
+	if step.Timeout != 120 {
+		t.Fatalf("子工作流超时时间应来自节点配置: %d", step.Timeout)
+	}
+	if step.Input["outline"] != "{{outline.output}}" {
+		t.Fatalf("输入映射错误: %v", step.Input)
+	}
+}
+
+func TestSubWorkflowExecutorDelegatesOtherSteps(t *testing.T) {
+	next := &stubTaskExecutor{}
+	executor := (&Engine{}).withSubWorkflows(next)
+
+	result, err := executor.ExecuteTask(context.Background(), &Task{ID: "write", Step: &StepDefinition{ID: "write", Type: "agent"}})
+	if err != nil || result.Status != "success" || next.calls != 1 {
+		t.Fatalf("非子工作流步骤应交给下层执行器: result=%+v err=%v calls=%d", result, err, next.calls)
+	}
+
+	// 缺少 workflow_id 的子工作流步骤直接失败，不调用下层执行器
+	result, err = executor.ExecuteTask(context.Background(), &Task{ID: "review", Step: &StepDefinition{ID: "review", Type: SubWorkflowStepType}})
+	if err != nil || result.Status != "failed" || next.calls != 1 {
+		t.Fatalf("缺少配置的子工作流步骤应失败: result=%+v err=%v calls=%d", result, err, next.calls)
+	}
+}
+
+const (
+	subTestTenant = "tenant-sub"
+	subTestUser   = "user-sub"
+)
+
+func setupSubWorkflowEngine(t *testing.T) (*Engine, *gorm.DB) {
+	t.Helper()
+	_ = logger.Init("error", "console", "stdout")
+	db := setupEngineTestDB(t)
+	if err := db.AutoMigrate(&workflow.WorkflowTask{}); err != nil {
+		t.Fatalf("迁移 schema 失败: %v", err)
+	}
+	// updated_at 由迁移脚本创建，模型中未声明，RunExecution 写入最终状态时会更新
+	if err := db.Exec("ALTER TABLE workflow_executions ADD COLUMN updated_at DATETIME").Error; err != nil {
+		t.Fatalf("迁移 schema 失败: %v", err)
+	}
+	return NewEngine(db, nil, nil, noopAuditService{}), db
+}
+
+// createCallerExecution 创建调用方执行记录，callerID 非空时作为其上级
+func createCallerExecution(t *testing.T, db *gorm.DB, id, workflowID, callerID string) {
+	t.Helper()
+	execution := &workflow.WorkflowExecution{ID: id, WorkflowID: workflowID, TenantID: subTestTenant, UserID: subTestUser, Status: "running"}
+	if callerID != "" {
+		execution.CallerExecutionID = &callerID
+	}
+	if err := db.Create(execution).Error; err != nil {
+		t.Fatalf("写入执行记录失败: %v", err)
+	}
+}
+
+func subWorkflowTask(callerExecutionID, childWorkflowID string, input map[string]any) *Task {
+	return &Task{
+		ID: "review",
+		Step: &StepDefinition{
+			ID:          "review",
+			Type:        SubWorkflowStepType,
+			SubWorkflow: &SubWorkflowConfig{WorkflowID: childWorkflowID},
+		},
+		Input:   input,
+		Context: NewExecutionContext("wf-parent", callerExecutionID, subTestTenant, subTestUser),
+	}
+}
+
+func TestRunSubWorkflowReturnsChildOutput(t *testing.T) {
+	engine, db := setupSubWorkflowEngine(t)
+	createTestWorkflow(t, db, subTestTenant, "wf-parent")
+	createTestWorkflow(t, db, subTestTenant, "wf-child")
+	createCallerExecution(t, db, "exec-parent", "wf-parent", "")
+
+	result := engine.runSubWorkflow(context.Background(), subWorkflowTask("exec-parent", "wf-child", map[string]any{"outline": "第一卷大纲"}))
+	if result.Status != "success" {
+		t.Fatalf("子工作流步骤应成功: %+v", result)
+	}
+	output, ok := result.Output.(map[string]any)
+	if !ok || output["outline"] != "第一卷大纲" {
+		t.Fatalf("步骤输出应为子工作流输出: %#v", result.Output)
+	}
+
+	var child workflow.WorkflowExecution
+	if err := db.First(&child, "id = ?", result.Metadata["child_execution_id"]).Error; err != nil {
+		t.Fatalf("读取子执行失败: %v", err)
+	}
+	if child.Status != "completed" || child.CallerExecutionID == nil || *child.CallerExecutionID != "exec-parent" ||
+		child.CallerStepID != "review" || child.TenantID != subTestTenant || child.UserID != subTestUser {
+		t.Fatalf("子执行记录错误: %+v", child)
+	}
+}
+
+func TestRunSubWorkflowReusesChildOnResume(t *testing.T) {
+	engine, db := setupSubWorkflowEngine(t)
+	createTestWorkflow(t, db, subTestTenant, "wf-parent")
+	createTestWorkflow(t, db, subTestTenant, "wf-child")
+	createCallerExecution(t, db, "exec-parent", "wf-parent", "")
+	task := subWorkflowTask("exec-parent", "wf-child", map[string]any{"outline": "第一卷大纲"})
+
+	first := engine.runSubWorkflow(context.Background(), task)
+	// 调用方从检查点恢复后再次进入该步骤：复用已完成的子执行
+	resumed := engine.runSubWorkflow(context.Background(), task)
+	if first.Status != "success" || resumed.Status != "success" ||
+		resumed.Metadata["child_execution_id"] != first.Metadata["child_execution_id"] {
+		t.Fatalf("恢复时应复用子执行: first=%+v resumed=%+v", first.Metadata, resumed.Metadata)
+	}
+
+	// 已取消的子执行不复用
+	if err := db.Model(&workflow.WorkflowExecution{}).Where("id = ?", first.Metadata["child_execution_id"]).
+		Update("status", "cancelled").Error; err != nil {
+		t.Fatalf("更新子执行状态失败: %v", err)
+	}
+	retried := engine.runSubWorkflow(context.Background(), task)
+	if retried.Status != "success" || retried.Metadata["child_execution_id"] == first.Metadata["child_execution_id"] {
+		t.Fatalf("已取消的子执行应重新创建: %+v", retried.Metadata)
+	}
+
+	var count int64
+	db.Model(&workflow.WorkflowExecution{}).Where("caller_execution_id = ?", "exec-parent").Count(&count)
+	if count != 2 {
+		t.Fatalf("应只创建两个子执行, got %d", count)
+	}
+}
+
+func TestCancelSubWorkflowMarksCancelled(t *testing.T) {
+	engine, db := setupSubWorkflowEngine(t)
+	createCallerExecution(t, db, "exec-child", "wf-child", "")
+	db.Model(&workflow.WorkflowExecution{}).Where("id = ?", "exec-child").Update("status", "queued")
+
+	engine.cancelSubWorkflow("exec-child", context.DeadlineExceeded)
+	var child workflow.WorkflowExecution
+	if err := db.First(&child, "id = ?", "exec-child").Error; err != nil {
+		t.Fatalf("读取子执行失败: %v", err)
+	}
+	if child.Status != "cancelled" || !executionFinished(child.Status) || !strings.Contains(child.ErrorMessage, "调用方执行已终止") {
+		t.Fatalf("子执行应标记为已取消: %+v", child)
+	}
+}
+
+func TestCheckCallChainRejectsCycleAndDepth(t *testing.T) {
+	engine, db := setupSubWorkflowEngine(t)
+	ctx := context.Background()
+
+	// wf-0 -> wf-1 -> ... -> wf-4，每层一个执行
+	caller := ""
+	for i := 0; i < maxSubWorkflowDepth; i++ {
+		id := fmt.Sprintf("exec-%d", i)
+		createCallerExecution(t, db, id, fmt.Sprintf("wf-%d", i), caller)
+		caller = id
+	}
+
+	if err := engine.checkCallChain(ctx, "exec-3", "wf-new"); err != nil {
+		t.Fatalf("未超过嵌套层数时应允许: %v", err)
+	}
+	if err := engine.checkCallChain(ctx, "exec-3", "wf-1"); err == nil || !strings.Contains(err.Error(), "循环调用") {
+		t.Fatalf("应拒绝循环调用: %v", err)
+	}
+	if err := engine.checkCallChain(ctx, "exec-4", "wf-new"); err != nil {
+		t.Fatalf("第 %d 层子工作流应允许: %v", maxSubWorkflowDepth, err)
+	}
+	createCallerExecution(t, db, "exec-5", "wf-5", "exec-4")
+	if err := engine.checkCallChain(ctx, "exec-5", "wf-new"); err == nil || !strings.Contains(err.Error(), "嵌套超过") {
+		t.Fatalf("应拒绝超过最大嵌套层数: %v", err)
+	}
+
+	// 调用链记录缺失时返回错误而不是放行
+	if err := engine.checkCallChain(ctx, "exec-missing", "wf-new"); err == nil || errors.Is(err, context.Canceled) {
+		t.Fatalf("调用链缺失时应返回错误: %v", err)
+	}
+}
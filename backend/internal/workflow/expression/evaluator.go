@@ -0,0 +1,335 @@
+package expression
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+)
+
+// 表达式求值器
+// 条件分支、循环条件与调试器共用同一套语法：
+// - govaluate 表达式：step_1.output.quality_score > 80 && {{status}} == "ok"
+// - {{ var }} 变量引用
+// - 路径访问：step_1.output.items[0].name（字符串形式的 JSON 会被自动解析）
+// - 函数：any_of / all_of / none_of / contains / empty / not_empty / len
+
+var (
+	// placeholderPattern 匹配 {{ var }} 形式的变量引用
+	placeholderPattern = regexp.MustCompile(`\{\{([^}]+)\}\}`)
+	// pathPattern 匹配带访问符的裸路径，如 step_1.output.score、items[0]
+	pathPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_]+|\[\d+\])+`)
+	// lenPattern 匹配表达式内部的 len(...) 调用
+	lenPattern = regexp.MustCompile(`\blen\(\s*([^()]+?)\s*\)`)
+)
+
+// Evaluate 求值表达式，返回任意类型的结果
+func Evaluate(expr string, vars map[string]any) (any, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("表达式不能为空")
+	}
+
+	if result, handled, err := evaluateFunction(expr, vars); handled {
+		return result, err
+	}
+
+	// 整个表达式就是一个变量引用时直接返回原值，避免 govaluate 对复杂类型求值
+	if m := placeholderPattern.FindStringSubmatch(expr); m != nil && m[0] == expr {
+		return Lookup(vars, strings.TrimSpace(m[1]))
+	}
+	if pathPattern.FindString(expr) == expr {
+		return Lookup(vars, expr)
+	}
+
+	processedExpr, parameters := substitute(expr, vars)
+
+	expression, err := govaluate.NewEvaluableExpression(processedExpr)
+	if err != nil {
+		return nil, fmt.Errorf("解析表达式失败: %w", err)
+	}
+
+	// 处理剩余的简单变量
+	for _, v := range expression.Vars() {
+		if _, exists := parameters[v]; exists {
+			continue
+		}
+		val, err := Lookup(vars, v)
+		if err != nil {
+			parameters[v] = nil
+			continue
+		}
+		parameters[v] = val
+	}
+
+	result, err := expression.Evaluate(parameters)
+	if err != nil {
+		return nil, fmt.Errorf("评估表达式失败: %w", err)
+	}
+	return result, nil
+}
+
+// EvaluateBool 求值表达式并要求结果为布尔值
+func EvaluateBool(expr string, vars map[string]any) (bool, error) {
+	result, err := Evaluate(expr, vars)
+	if err != nil {
+		return false, err
+	}
+	if boolResult, ok := result.(bool); ok {
+		return boolResult, nil
+	}
+	return false, fmt.Errorf("表达式结果不是布尔值: %v", result)
+}
+
+// ResolveValue 解析单个值（变量引用、len()、字面量）
+func ResolveValue(value string, vars map[string]any) (any, error) {
+	value = strings.TrimSpace(value)
+
+	if strings.HasPrefix(value, "len(") && strings.HasSuffix(value, ")") {
+		val, err := ResolveValue(value[4:len(value)-1], vars)
+		if err != nil {
+			return nil, err
+		}
+		return float64(lengthOf(val)), nil
+	}
+
+	// 变量引用 {{...}}
+	if strings.HasPrefix(value, "{{") && strings.HasSuffix(value, "}}") {
+		return Lookup(vars, strings.TrimSpace(value[2:len(value)-2]))
+	}
+
+	// 字符串字面量 "..."
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1], nil
+	}
+
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		return num, nil
+	}
+
+	switch value {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "nil":
+		return nil, nil
+	}
+
+	// 带访问符的路径按变量处理
+	if pathPattern.FindString(value) == value {
+		return Lookup(vars, value)
+	}
+
+	// 其他情况，作为字符串处理
+	return value, nil
+}
+
+// substitute 将 {{ var }} 与裸路径替换为占位符，并预先求出对应参数
+// 字符串字面量内部的内容保持不变
+func substitute(expr string, vars map[string]any) (string, map[string]any) {
+	parameters := make(map[string]any)
+	bind := func(val any) string {
+		placeholder := fmt.Sprintf("var%d", len(parameters))
+		parameters[placeholder] = val
+		return placeholder
+	}
+	resolve := func(path string) string {
+		val, err := Lookup(vars, path)
+		if err != nil {
+			val = nil
+		}
+		return bind(val)
+	}
+
+	var b strings.Builder
+	for _, seg := range splitQuoted(expr) {
+		if seg.quoted {
+			b.WriteString(seg.text)
+			continue
+		}
+		text := lenPattern.ReplaceAllStringFunc(seg.text, func(match string) string {
+			inner := strings.TrimSpace(lenPattern.FindStringSubmatch(match)[1])
+			if strings.HasPrefix(inner, "{{") && strings.HasSuffix(inner, "}}") {
+				inner = strings.TrimSpace(inner[2 : len(inner)-2])
+			}
+			val, _ := Lookup(vars, inner)
+			return bind(float64(lengthOf(val)))
+		})
+		text = placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+			return resolve(strings.TrimSpace(match[2 : len(match)-2]))
+		})
+		text = pathPattern.ReplaceAllStringFunc(text, resolve)
+		b.WriteString(text)
+	}
+	return b.String(), parameters
+}
+
+type exprSegment struct {
+	text   string
+	quoted bool
+}
+
+// splitQuoted 按字符串字面量切分表达式
+func splitQuoted(expr string) []exprSegment {
+	var segments []exprSegment
+	start := 0
+	var quote rune
+	for i, r := range expr {
+		switch {
+		case quote == 0 && (r == '"' || r == '\''):
+			if i > start {
+				segments = append(segments, exprSegment{text: expr[start:i]})
+			}
+			quote = r
+			start = i
+		case quote != 0 && r == quote && (i == 0 || expr[i-1] != '\\'):
+			segments = append(segments, exprSegment{text: expr[start : i+1], quoted: true})
+			quote = 0
+			start = i + 1
+		}
+	}
+	if start < len(expr) {
+		segments = append(segments, exprSegment{text: expr[start:], quoted: quote != 0})
+	}
+	return segments
+}
+
+func evaluateFunction(expr string, vars map[string]any) (any, bool, error) {
+	switch {
+	case strings.HasPrefix(expr, "any_of(") && strings.HasSuffix(expr, ")"):
+		for _, arg := range splitArguments(expr[7 : len(expr)-1]) {
+			res, err := EvaluateBool(strings.TrimSpace(arg), vars)
+			if err != nil {
+				return false, true, err
+			}
+			if res {
+				return true, true, nil
+			}
+		}
+		return false, true, nil
+	case strings.HasPrefix(expr, "all_of(") && strings.HasSuffix(expr, ")"):
+		args := splitArguments(expr[7 : len(expr)-1])
+		if len(args) == 0 {
+			return false, true, fmt.Errorf("all_of 需要至少一个条件")
+		}
+		for _, arg := range args {
+			res, err := EvaluateBool(strings.TrimSpace(arg), vars)
+			if err != nil {
+				return false, true, err
+			}
+			if !res {
+				return false, true, nil
+			}
+		}
+		return true, true, nil
+	case strings.HasPrefix(expr, "none_of(") && strings.HasSuffix(expr, ")"):
+		for _, arg := range splitArguments(expr[8 : len(expr)-1]) {
+			res, err := EvaluateBool(strings.TrimSpace(arg), vars)
+			if err != nil {
+				return false, true, err
+			}
+			if res {
+				return false, true, nil
+			}
+		}
+		return true, true, nil
+	case strings.HasPrefix(expr, "contains(") && strings.HasSuffix(expr, ")"):
+		args := splitArguments(expr[9 : len(expr)-1])
+		if len(args) != 2 {
+			return false, true, fmt.Errorf("contains 需要两个参数")
+		}
+		left, err := ResolveValue(args[0], vars)
+		if err != nil {
+			return false, true, err
+		}
+		right, err := ResolveValue(args[1], vars)
+		if err != nil {
+			return false, true, err
+		}
+		return containsValue(left, right), true, nil
+	case strings.HasPrefix(expr, "empty(") && strings.HasSuffix(expr, ")"):
+		val, err := ResolveValue(expr[6:len(expr)-1], vars)
+		if err != nil {
+			return false, true, err
+		}
+		return lengthOf(val) == 0, true, nil
+	case strings.HasPrefix(expr, "not_empty(") && strings.HasSuffix(expr, ")"):
+		val, err := ResolveValue(expr[10:len(expr)-1], vars)
+		if err != nil {
+			return false, true, err
+		}
+		return lengthOf(val) > 0, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+func splitArguments(body string) []string {
+	var args []string
+	depth := 0
+	start := 0
+	for i, r := range body {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(body) {
+		args = append(args, body[start:])
+	}
+	return args
+}
+
+func containsValue(left, right any) bool {
+	switch lv := left.(type) {
+	case string:
+		return strings.Contains(lv, fmt.Sprintf("%v", right))
+	case []any:
+		for _, item := range lv {
+			if fmt.Sprintf("%v", item) == fmt.Sprintf("%v", right) {
+				return true
+			}
+		}
+	case []string:
+		for _, item := range lv {
+			if item == fmt.Sprintf("%v", right) {
+				return true
+			}
+		}
+	case map[string]any:
+		if key, ok := right.(string); ok {
+			_, exists := lv[key]
+			return exists
+		}
+	}
+	return false
+}
+
+func lengthOf(value any) int {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []any:
+		return len(v)
+	case []string:
+		return len(v)
+	case map[string]any:
+		return len(v)
+	case map[string]string:
+		return len(v)
+	default:
+		return 0
+	}
+}
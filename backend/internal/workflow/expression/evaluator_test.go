@@ -0,0 +1,51 @@
+package expression
+
+import "testing"
+
+func TestEvaluatePathAccess(t *testing.T) {
+	vars := map[string]any{
+		"step_1":        map[string]any{"quality_score": 85, "status": "success"},
+		"step_2":        "```json\n{\"characters\":[{\"name\":\"林远\"},{\"name\":\"苏晴\"}]}\n```",
+		"step_3.output": map[string]any{"word_count": 1200},
+		"tags":          []any{"悬疑", "都市"},
+	}
+
+	cases := []struct {
+		expr string
+		want any
+	}{
+		{"step_1.output.quality_score > 80", true},
+		{`step_1.output.status == "success" && {{step_3.output.word_count}} >= 1000`, true},
+		{"step_2.output.characters[1].name", "苏晴"},
+		{`step_2.output.characters[0].name == "林远"`, true},
+		{"len({{tags}}) == 2", true},
+		{`contains({{tags}}, "悬疑")`, true},
+		{"step_3.output.word_count", 1200},
+		{"step_1.output.missing != nil", false},
+		{`"step_1.output.quality_score" == "step_1.output.quality_score"`, true},
+	}
+
+	for _, tc := range cases {
+		got, err := Evaluate(tc.expr, vars)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.expr, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %v, got %v", tc.expr, tc.want, got)
+		}
+	}
+}
+
+func TestLookupErrors(t *testing.T) {
+	vars := map[string]any{"step_1": map[string]any{"items": []any{1}}}
+
+	if _, err := Lookup(vars, "step_9.output"); err == nil {
+		t.Fatal("expected error for unknown step")
+	}
+	if _, err := Lookup(vars, "step_1.items[3]"); err == nil {
+		t.Fatal("expected error for out-of-range index")
+	}
+	if _, err := EvaluateBool("step_1.items", vars); err == nil {
+		t.Fatal("expected error for non-boolean result")
+	}
+}
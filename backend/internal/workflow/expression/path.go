@@ -0,0 +1,187 @@
+package expression
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Lookup 按路径从变量集合中取值
+// 支持：
+// - 点号访问嵌套字段：step_1.output.content
+// - 数组下标：chapters[0].title 或 chapters.0.title
+// - 扁平键：SetStepOutput 写入的 "step_1.output" 形式的键
+// - step_x.output 别名：步骤输出直接以 step_x 为键保存时，output 段视为步骤输出本身
+// - JSON 字符串：路径经过字符串形式的 JSON（如 Agent 的结构化输出）时自动解析
+func Lookup(vars map[string]any, path string) (any, error) {
+	parts, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 优先匹配最长的扁平键
+	start := 0
+	var current any
+	found := false
+	for i := len(parts); i > 0; i-- {
+		if val, ok := vars[strings.Join(parts[:i], ".")]; ok {
+			current, start, found = val, i, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("变量 %s 不存在（路径: %s）", path, parts[0])
+	}
+
+	for i := start; i < len(parts); i++ {
+		part := parts[i]
+		if current == nil {
+			return nil, fmt.Errorf("变量 %s 在路径 %s 处为 null", path, strings.Join(parts[:i], "."))
+		}
+
+		current = decodeJSONString(current)
+
+		next, ok := child(current, part)
+		if !ok {
+			if part == "output" {
+				// step_x.output 别名
+				continue
+			}
+			return nil, fmt.Errorf("变量 %s 不存在（路径: %s）", path, strings.Join(parts[:i+1], "."))
+		}
+		current = next
+	}
+
+	return current, nil
+}
+
+// splitPath 将 a.b[0].c 拆分为 [a b 0 c]
+func splitPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("无效的变量名: %s", path)
+	}
+
+	var parts []string
+	for _, seg := range strings.Split(path, ".") {
+		for seg != "" {
+			idx := strings.IndexByte(seg, '[')
+			if idx < 0 {
+				parts = append(parts, seg)
+				break
+			}
+			if idx > 0 {
+				parts = append(parts, seg[:idx])
+			}
+			end := strings.IndexByte(seg[idx:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("无效的变量名: %s", path)
+			}
+			parts = append(parts, strings.Trim(seg[idx+1:idx+end], `"'`))
+			seg = seg[idx+end+1:]
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("无效的变量名: %s", path)
+	}
+	return parts, nil
+}
+
+// child 取下一级的值
+func child(current any, part string) (any, bool) {
+	switch v := current.(type) {
+	case map[string]any:
+		val, ok := v[part]
+		return val, ok
+	case map[string]string:
+		val, ok := v[part]
+		return val, ok
+	case []any:
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 || idx >= len(v) {
+			return nil, false
+		}
+		return v[idx], true
+	case []string:
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 || idx >= len(v) {
+			return nil, false
+		}
+		return v[idx], true
+	}
+
+	// 其余类型使用反射
+	val := reflect.ValueOf(current)
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil, false
+		}
+		val = val.Elem()
+	}
+
+	switch val.Kind() {
+	case reflect.Struct:
+		field := val.FieldByName(part)
+		if !field.IsValid() {
+			field = fieldByJSONTag(val, part)
+		}
+		if !field.IsValid() || !field.CanInterface() {
+			return nil, false
+		}
+		return field.Interface(), true
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return nil, false
+		}
+		item := val.MapIndex(reflect.ValueOf(part).Convert(val.Type().Key()))
+		if !item.IsValid() {
+			return nil, false
+		}
+		return item.Interface(), true
+	case reflect.Slice, reflect.Array:
+		idx, err := strconv.Atoi(part)
+		if err != nil || idx < 0 || idx >= val.Len() {
+			return nil, false
+		}
+		return val.Index(idx).Interface(), true
+	}
+	return nil, false
+}
+
+// fieldByJSONTag 按 json 标签查找结构体字段
+func fieldByJSONTag(val reflect.Value, name string) reflect.Value {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == name {
+			return val.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// decodeJSONString 尝试将 JSON 字符串解析为结构化数据，失败时原样返回
+func decodeJSONString(value any) any {
+	s, ok := value.(string)
+	if !ok {
+		return value
+	}
+	s = strings.TrimSpace(s)
+	// 兼容 Markdown 代码块包裹的 JSON
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(strings.TrimSpace(s), "```")
+		s = strings.TrimSpace(s)
+	}
+	if s == "" || (s[0] != '{' && s[0] != '[') {
+		return value
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
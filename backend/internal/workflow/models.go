@@ -58,6 +58,7 @@ const (
 	NodeTypeTool     NodeType = "tool"
 	NodeTypeRouter   NodeType = "router"   // 路由/条件分支
 	NodeTypeApproval NodeType = "approval" // 人工审批
+	NodeTypeWorkflow NodeType = "workflow" // 子工作流
 )
 
 // Position 节点坐标
@@ -89,6 +90,11 @@ type NodeData struct {
 	Approvers []string `json:"approvers,omitempty"` // 审批人 UserID 列表
 	Timeout   int      `json:"timeout,omitempty"`   // 超时时间(秒)
 
+	// Workflow 节点配置（子工作流），输入通过 Inputs 映射
+	WorkflowID      string `json:"workflowId,omitempty"`
+	WorkflowVersion int    `json:"workflowVersion,omitempty"` // 子工作流定义版本，0 表示已发布版本
+	Async           bool   `json:"async,omitempty"`           // 提交子工作流后不等待其结束
+
 	// 输入变量映射 (Input Mapping)
 	// key: 当前节点所需的变量名
 	// value: 来源变量表达式，如 "{{step_1.output.summary}}"
@@ -141,14 +147,20 @@ type Workflow struct {
 	Variables  map[string]any     `json:"variables" gorm:"type:jsonb;serializer:json"` // 变量
 
 	// 版本
-	Version string `json:"version" gorm:"size:50;not null"`
+	Version          string     `json:"version" gorm:"size:50;not null"`   // 版本标签（自由文本）
+	LatestVersion    int        `json:"latestVersion" gorm:"default:0"`    // 最新保存的定义版本号（草稿）
+	PublishedVersion int        `json:"publishedVersion" gorm:"default:0"` // 已发布的定义版本号，执行默认使用该版本，0 表示未发布
+	PublishedAt      *time.Time `json:"publishedAt,omitempty"`
 
 	// 可见性
 	Visibility string `json:"visibility" gorm:"size:50;not null;default:personal"` // personal, tenant, public
 
 	// 调度配置
-	Schedule  string     `json:"schedule" gorm:"size:100"`  // cron 表达式
-	NextRunAt *time.Time `json:"nextRunAt" gorm:"index"`    // 下次执行时间
+	Schedule         string     `json:"schedule" gorm:"size:100"`         // cron 表达式
+	ScheduleTimezone string     `json:"scheduleTimezone" gorm:"size:64"`  // cron 时区（IANA 名称），为空时使用 UTC
+	MissedRunPolicy  string     `json:"missedRunPolicy" gorm:"size:20"`   // 停机期间错过执行的补偿策略：skip, run_once（默认）, run_all
+	NextRunAt        *time.Time `json:"nextRunAt" gorm:"index"`           // 下次执行时间
+	LastScheduledAt  *time.Time `json:"lastScheduledAt"`                  // 最近一次定时触发的计划时间
 
 	// 状态
 	Status string `json:"status" gorm:"size:50;default:draft"` // draft, active, archived
@@ -179,7 +191,7 @@ type WorkflowExecution struct {
 	UserID     string `json:"userId" gorm:"type:uuid;index"`
 
 	// 状态
-	Status string `json:"status" gorm:"size:50;not null;default:pending"` // pending, running, completed, failed, paused
+	Status string `json:"status" gorm:"size:50;not null;default:pending"` // pending, running, completed, failed, paused, cancelled
 
 	// 输入输出
 	Input        map[string]any `json:"input" gorm:"type:jsonb;serializer:json"`
@@ -193,17 +205,47 @@ type WorkflowExecution struct {
 	// 追踪
 	TraceID string `json:"traceId" gorm:"size:100;index"`
 
+	// 执行租约：Worker 执行期间定期续约，过期后由恢复任务从最近的步骤检查点继续
+	LeaseOwner     string     `json:"leaseOwner,omitempty" gorm:"size:200"`
+	LeaseExpiresAt *time.Time `json:"leaseExpiresAt,omitempty" gorm:"index"`
+	HeartbeatAt    *time.Time `json:"heartbeatAt,omitempty"`
+	RecoveryCount  int        `json:"recoveryCount" gorm:"default:0"`
+
+	// 执行固定使用的工作流定义版本，0 表示版本化之前的执行（使用工作流当前定义）
+	WorkflowVersion int `json:"workflowVersion" gorm:"default:0"`
+
+	// 分叉执行：从父执行的某个步骤起重新运行，上游步骤复用父执行的输出
+	ParentExecutionID *string                 `json:"parentExecutionId,omitempty" gorm:"type:uuid;index"`
+	ForkedFromStep    string                  `json:"forkedFromStep,omitempty" gorm:"size:100"`
+	StepOverrides     map[string]StepOverride `json:"stepOverrides,omitempty" gorm:"type:jsonb;serializer:json"`
+
+	// 子工作流执行：由调用方执行的 workflow 步骤发起
+	CallerExecutionID *string `json:"callerExecutionId,omitempty" gorm:"type:uuid;index"`
+	CallerStepID      string  `json:"callerStepId,omitempty" gorm:"size:100"`
+
 	// 时间戳
 	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime;index"`
 }
 
+// StepOverride 分叉执行时对步骤定义的覆盖，空值表示沿用原定义
+type StepOverride struct {
+	Input                map[string]any `json:"input,omitempty"` // 与步骤原输入合并，同名键以此为准
+	AgentType            string         `json:"agentType,omitempty"`
+	AgentID              *string        `json:"agentId,omitempty"`
+	Role                 string         `json:"role,omitempty"`
+	SystemPromptOverride string         `json:"systemPromptOverride,omitempty"`
+}
+
 // WorkflowTask 工作流任务
 type WorkflowTask struct {
 	ID          string `json:"id" gorm:"primaryKey;type:uuid"`
-	ExecutionID string `json:"executionId" gorm:"type:uuid;not null;index"`
+	TenantID    string `json:"tenantId" gorm:"type:uuid;index"`
+	ExecutionID string `json:"executionId" gorm:"type:uuid;not null;index;uniqueIndex:idx_workflow_tasks_execution_step"`
 
 	// 任务信息
-	StepID    string `json:"stepId" gorm:"size:100;not null"`
+	StepID    string `json:"stepId" gorm:"size:100;not null;uniqueIndex:idx_workflow_tasks_execution_step"`
+	StepType  string `json:"stepType" gorm:"size:50"`
+	StepName  string `json:"stepName" gorm:"size:255"`
 	AgentType string `json:"agentType" gorm:"size:100;not null"`
 
 	// 状态
@@ -212,6 +254,7 @@ type WorkflowTask struct {
 	// 输入输出
 	Input        map[string]any `json:"input" gorm:"type:jsonb;serializer:json"`
 	Output       map[string]any `json:"output" gorm:"type:jsonb;serializer:json"`
+	Metadata     map[string]any `json:"metadata" gorm:"type:jsonb;serializer:json"` // 用量、成本等步骤元数据
 	ErrorMessage string         `json:"errorMessage" gorm:"type:text"`
 
 	// 时间
@@ -223,4 +266,5 @@ type WorkflowTask struct {
 
 	// 时间戳
 	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
 }
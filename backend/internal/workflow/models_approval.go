@@ -31,7 +31,12 @@ type ApprovalRequest struct {
 
 	// 超时
 	TimeoutSeconds int        `json:"timeoutSeconds" gorm:"default:3600"`
-	ExpiresAt      *time.Time `json:"expiresAt"`
+	ExpiresAt      *time.Time `json:"expiresAt"` // 多级审批时为当前阶段（或当前升级层级）的到期时间
+
+	// 多级审批：按顺序逐级通过，空表示单人审批
+	Stages          []ApprovalStage `json:"stages,omitempty" gorm:"type:jsonb;serializer:json"`
+	CurrentStage    int             `json:"currentStage" gorm:"default:0"`
+	EscalationLevel int             `json:"escalationLevel" gorm:"default:0"` // 当前阶段已升级次数
 
 	// 时间戳
 	CreatedAt  time.Time  `json:"createdAt" gorm:"not null;autoCreateTime"`
@@ -54,3 +59,53 @@ type AutomationLog struct {
 	// 时间戳
 	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime;index"`
 }
+
+// ApprovalStage 审批阶段
+type ApprovalStage struct {
+	Name              string     `json:"name"`
+	Approvers         []string   `json:"approvers"`               // 审批人：user:<id>、role:<code>
+	RequiredApprovals int        `json:"requiredApprovals"`       // 通过所需人数（N of M），0 表示 1 人
+	TimeoutSeconds    int        `json:"timeoutSeconds"`          // 阶段超时，0 沿用请求超时
+	EscalateTo        []string   `json:"escalateTo,omitempty"`    // 超时后依次加入的升级审批人
+	TimeoutAction     string     `json:"timeoutAction,omitempty"` // 升级耗尽后的动作：timeout（默认）、approve、reject
+	Status            string     `json:"status"`                  // pending、approved、rejected、timeout
+	CompletedAt       *time.Time `json:"completedAt,omitempty"`
+}
+
+// ApprovalDecision 审批意见（多级/会签审批中每位审批人一条）
+type ApprovalDecision struct {
+	ID         string `json:"id" gorm:"primaryKey;size:36"`
+	TenantID   string `json:"tenantId" gorm:"size:36;not null;index"`
+	ApprovalID string `json:"approvalId" gorm:"size:36;not null;uniqueIndex:idx_approval_decision_actor"`
+	Stage      int    `json:"stage" gorm:"not null;uniqueIndex:idx_approval_decision_actor"`
+	ApproverID string `json:"approverId" gorm:"size:36;not null;uniqueIndex:idx_approval_decision_actor"` // 计票身份（委托时为委托人）
+	DecidedBy  string `json:"decidedBy" gorm:"size:36;not null"`                                          // 实际操作人
+	Decision   string `json:"decision" gorm:"size:20;not null"`                                           // approved、rejected
+	Comment    string `json:"comment" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (ApprovalDecision) TableName() string {
+	return "approval_decisions"
+}
+
+// ApprovalDelegation 审批委托：有效期内受托人可代委托人审批
+type ApprovalDelegation struct {
+	ID          string     `json:"id" gorm:"primaryKey;size:36"`
+	TenantID    string     `json:"tenantId" gorm:"size:36;not null;index"`
+	DelegatorID string     `json:"delegatorId" gorm:"size:36;not null;index"`
+	DelegateID  string     `json:"delegateId" gorm:"size:36;not null;index"`
+	StartsAt    time.Time  `json:"startsAt" gorm:"not null"`
+	EndsAt      *time.Time `json:"endsAt"` // 为空表示长期有效
+	Reason      string     `json:"reason" gorm:"size:500"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (ApprovalDelegation) TableName() string {
+	return "approval_delegations"
+}
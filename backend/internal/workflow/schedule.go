@@ -0,0 +1,148 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 错过执行的补偿策略（服务停机期间到期的执行）
+const (
+	MissedRunSkip = "skip"     // 丢弃错过的执行，等待下一个周期
+	MissedRunOnce = "run_once" // 合并为一次补执行（默认）
+	MissedRunAll  = "run_all"  // 逐次补执行，最多补 MaxCatchUpRuns 次
+)
+
+// MaxCatchUpRuns run_all 策略最多补执行的次数，更早的执行被丢弃
+const MaxCatchUpRuns = 10
+
+// scheduleTZPrefix cron 表达式的时区前缀
+const scheduleTZPrefix = "CRON_TZ="
+
+// cronParser 标准 5 段 cron 表达式，支持 @daily 等描述符
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSchedule 解析 cron 表达式；timezone 为 IANA 时区名，为空时使用 UTC。
+// 表达式自带 CRON_TZ= / TZ= 前缀时以前缀为准
+func ParseSchedule(expr, timezone string) (cron.Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("cron 表达式不能为空")
+	}
+	if !strings.HasPrefix(expr, scheduleTZPrefix) && !strings.HasPrefix(expr, "TZ=") {
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("无效的时区 %s: %w", timezone, err)
+		}
+		expr = scheduleTZPrefix + timezone + " " + expr
+	}
+
+	schedule, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 cron 表达式: %w", err)
+	}
+	return schedule, nil
+}
+
+// NextScheduleTime 计算 after 之后的下一次执行时间（UTC）
+func NextScheduleTime(expr, timezone string, after time.Time) (time.Time, error) {
+	schedule, err := ParseSchedule(expr, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := schedule.Next(after)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron 表达式 %s 不会触发", expr)
+	}
+	return next.UTC(), nil
+}
+
+// ValidateMissedRunPolicy 校验补偿策略，空值表示默认
+func ValidateMissedRunPolicy(policy string) error {
+	switch policy {
+	case "", MissedRunSkip, MissedRunOnce, MissedRunAll:
+		return nil
+	default:
+		return fmt.Errorf("无效的补偿策略: %s", policy)
+	}
+}
+
+// DefaultInput 由工作流变量生成定时执行的默认输入：
+// 变量值为 {"default": ...} 形式的定义时取其默认值，否则直接使用变量值
+func (w *Workflow) DefaultInput() map[string]any {
+	input := make(map[string]any, len(w.Variables))
+	for key, value := range w.Variables {
+		if def, ok := value.(map[string]any); ok {
+			if defaultValue, ok := def["default"]; ok {
+				input[key] = defaultValue
+				continue
+			}
+		}
+		input[key] = value
+	}
+	return input
+}
+
+// maxScheduleScan 计算错过次数时最多扫描的执行时间点，防止高频表达式在长时间停机后空转
+const maxScheduleScan = 100000
+
+// ScheduledRun 到期工作流本次调度的计划
+type ScheduledRun struct {
+	RunAt      *time.Time // 本次触发对应的计划时间，nil 表示本次不触发
+	NextRunAt  time.Time  // 处理后写回的下次执行时间
+	Missed     int        // 被丢弃的错过执行次数
+	WaitIfBusy bool       // 存在未结束的执行时保留本次计划，待其结束后再补（run_all）
+}
+
+// PlanScheduledRun 按补偿策略计划到期工作流本次的触发：
+// 到期时间在 misfireGrace 内且只错过一次时正常触发，否则视为停机期间错过的执行
+func (w *Workflow) PlanScheduledRun(now time.Time, misfireGrace time.Duration) (*ScheduledRun, error) {
+	if w.NextRunAt == nil {
+		return nil, fmt.Errorf("工作流 %s 没有下次执行时间", w.ID)
+	}
+	schedule, err := ParseSchedule(w.Schedule, w.ScheduleTimezone)
+	if err != nil {
+		return nil, err
+	}
+
+	// 收集 NextRunAt 至 now 之间的执行时间点，只保留最近 MaxCatchUpRuns 个
+	var due []time.Time
+	total := 0
+	for t := *w.NextRunAt; !t.IsZero() && !t.After(now) && total < maxScheduleScan; t = schedule.Next(t) {
+		total++
+		due = append(due, t)
+		if len(due) > MaxCatchUpRuns {
+			due = due[1:]
+		}
+	}
+	next := schedule.Next(now).UTC()
+	if total == 0 {
+		return &ScheduledRun{NextRunAt: w.NextRunAt.UTC()}, nil
+	}
+
+	if total == 1 && now.Sub(due[0]) <= misfireGrace {
+		runAt := due[0].UTC()
+		return &ScheduledRun{RunAt: &runAt, NextRunAt: next}, nil
+	}
+
+	switch w.MissedRunPolicy {
+	case MissedRunSkip:
+		return &ScheduledRun{NextRunAt: next, Missed: total}, nil
+	case MissedRunAll:
+		// 每次只补最早的一次，NextRunAt 保持在过去，由后续检查逐次补齐
+		runAt := due[0].UTC()
+		return &ScheduledRun{
+			RunAt:      &runAt,
+			NextRunAt:  schedule.Next(due[0]).UTC(),
+			Missed:     total - len(due),
+			WaitIfBusy: true,
+		}, nil
+	default:
+		runAt := due[len(due)-1].UTC()
+		return &ScheduledRun{RunAt: &runAt, NextRunAt: next, Missed: total - 1}, nil
+	}
+}
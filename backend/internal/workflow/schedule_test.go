@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNextScheduleTimeWithTimezone(t *testing.T) {
+	after := time.Date(2026, 3, 1, 0, 30, 0, 0, time.UTC) // 上海时间 08:30
+	next, err := NextScheduleTime("0 9 * * *", "Asia/Shanghai", after)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if want := time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Fatalf("下次执行时间 = %v, want %v", next, want)
+	}
+
+	// 未指定时区使用 UTC
+	next, err = NextScheduleTime("@daily", "", after)
+	if err != nil || !next.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("@daily 下次执行时间 = %v, err = %v", next, err)
+	}
+
+	for _, tc := range []struct{ expr, tz string }{
+		{"0 9 * *", ""},
+		{"0 9 * * *", "Mars/Olympus"},
+		{"0 0 30 2 *", ""},
+	} {
+		if _, err := NextScheduleTime(tc.expr, tc.tz, after); err == nil {
+			t.Fatalf("%q (%s) 应校验失败", tc.expr, tc.tz)
+		}
+	}
+}
+
+func TestPlanScheduledRun(t *testing.T) {
+	base := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	hourly := func(policy string, nextRunAt time.Time) *Workflow {
+		return &Workflow{ID: "wf", Schedule: "0 * * * *", MissedRunPolicy: policy, NextRunAt: &nextRunAt}
+	}
+
+	// 按时触发
+	plan, err := hourly("", base).PlanScheduledRun(base.Add(10*time.Second), 5*time.Minute)
+	if err != nil || plan.RunAt == nil || !plan.RunAt.Equal(base) || !plan.NextRunAt.Equal(base.Add(time.Hour)) {
+		t.Fatalf("按时触发计划异常: %+v, err = %v", plan, err)
+	}
+
+	// 停机 3 小时后恢复（错过 08:00、09:00、10:00、11:00 四次）
+	now := base.Add(3*time.Hour + 20*time.Minute)
+
+	plan, _ = hourly(MissedRunSkip, base).PlanScheduledRun(now, 5*time.Minute)
+	if plan.RunAt != nil || plan.Missed != 4 || !plan.NextRunAt.Equal(base.Add(4*time.Hour)) {
+		t.Fatalf("skip 计划异常: %+v", plan)
+	}
+
+	plan, _ = hourly(MissedRunOnce, base).PlanScheduledRun(now, 5*time.Minute)
+	if plan.RunAt == nil || !plan.RunAt.Equal(base.Add(3*time.Hour)) || plan.Missed != 3 || !plan.NextRunAt.Equal(base.Add(4*time.Hour)) {
+		t.Fatalf("run_once 计划异常: %+v", plan)
+	}
+
+	plan, _ = hourly(MissedRunAll, base).PlanScheduledRun(now, 5*time.Minute)
+	if plan.RunAt == nil || !plan.RunAt.Equal(base) || !plan.NextRunAt.Equal(base.Add(time.Hour)) || !plan.WaitIfBusy {
+		t.Fatalf("run_all 计划异常: %+v", plan)
+	}
+
+	// run_all 超过补执行上限时丢弃更早的执行
+	plan, _ = hourly(MissedRunAll, base).PlanScheduledRun(base.Add(24*time.Hour), 5*time.Minute)
+	if plan.Missed != 25-MaxCatchUpRuns || !plan.RunAt.Equal(base.Add(time.Duration(25-MaxCatchUpRuns)*time.Hour)) {
+		t.Fatalf("run_all 上限计划异常: %+v", plan)
+	}
+}
+
+func TestWorkflowServiceSchedule(t *testing.T) {
+	ctx := context.Background()
+	svc := NewWorkflowService(setupWorkflowServiceTestDB(t))
+
+	created, err := svc.CreateWorkflow(ctx, &CreateWorkflowRequest{
+		TenantID:         "tenant-A",
+		Name:             "连载更新",
+		Definition:       sampleWorkflowDefinition(),
+		Schedule:         "0 20 * * *",
+		ScheduleTimezone: "Asia/Shanghai",
+	})
+	if err != nil {
+		t.Fatalf("创建工作流失败: %v", err)
+	}
+	if created.NextRunAt == nil || created.NextRunAt.UTC().Hour() != 12 {
+		t.Fatalf("未按时区计算下次执行时间: %v", created.NextRunAt)
+	}
+
+	if _, err := svc.CreateWorkflow(ctx, &CreateWorkflowRequest{
+		TenantID: "tenant-A", Name: "无效", Definition: sampleWorkflowDefinition(), Schedule: "every day",
+	}); err == nil {
+		t.Fatalf("无效 cron 表达式应被拒绝")
+	}
+
+	empty := ""
+	updated, err := svc.UpdateWorkflow(ctx, "tenant-A", created.ID, &UpdateWorkflowRequest{Schedule: &empty})
+	if err != nil {
+		t.Fatalf("更新工作流失败: %v", err)
+	}
+	if updated.NextRunAt != nil {
+		t.Fatalf("清空表达式后应停止调度: %v", updated.NextRunAt)
+	}
+}
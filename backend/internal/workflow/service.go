@@ -3,6 +3,7 @@ package workflow
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -133,8 +134,10 @@ type CreateWorkflowRequest struct {
 	Version     string
 	Visibility  string
 	// 调度配置
-	Schedule  string // cron 表达式
-	NextRunAt *time.Time
+	Schedule         string // cron 表达式
+	ScheduleTimezone string // cron 时区，为空时使用 UTC
+	MissedRunPolicy  string // 错过执行的补偿策略：skip, run_once, run_all
+	NextRunAt        *time.Time
 	// 状态
 	Status string // draft, active, archived
 	// 元数据
@@ -164,6 +167,19 @@ func (s *WorkflowService) CreateWorkflow(ctx context.Context, req *CreateWorkflo
 		return nil, fmt.Errorf("工作流定义无效: %w", err)
 	}
 
+	// 调度配置：设置了 cron 表达式时由表达式计算下次执行时间
+	nextRunAt := req.NextRunAt
+	if req.Schedule != "" {
+		next, err := NextScheduleTime(req.Schedule, req.ScheduleTimezone, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		nextRunAt = &next
+	}
+	if err := ValidateMissedRunPolicy(req.MissedRunPolicy); err != nil {
+		return nil, err
+	}
+
 	// 设置默认状态
 	status := req.Status
 	if status == "" {
@@ -182,8 +198,10 @@ func (s *WorkflowService) CreateWorkflow(ctx context.Context, req *CreateWorkflo
 		Version:     req.Version,
 		Visibility:  req.Visibility,
 		// 调度配置
-		Schedule:  req.Schedule,
-		NextRunAt: req.NextRunAt,
+		Schedule:         req.Schedule,
+		ScheduleTimezone: req.ScheduleTimezone,
+		MissedRunPolicy:  req.MissedRunPolicy,
+		NextRunAt:        nextRunAt,
 		// 状态
 		Status: status,
 		// 统计
@@ -197,7 +215,20 @@ func (s *WorkflowService) CreateWorkflow(ctx context.Context, req *CreateWorkflo
 		UpdatedAt: time.Now().UTC(),
 	}
 
-	if err := s.db.WithContext(ctx).Create(workflow).Error; err != nil {
+	// 首个定义版本；以 active 状态创建时直接发布
+	workflow.LatestVersion = 1
+	if status == "active" {
+		workflow.PublishedVersion = 1
+		workflow.PublishedAt = &workflow.CreatedAt
+	}
+	version := newWorkflowVersion(workflow, 1, req.CreatedBy, "创建工作流")
+
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(workflow).Error; err != nil {
+			return err
+		}
+		return tx.Create(version).Error
+	}); err != nil {
 		return nil, fmt.Errorf("创建工作流失败: %w", err)
 	}
 
@@ -214,15 +245,20 @@ type UpdateWorkflowRequest struct {
 	Version     *string
 	Visibility  *string
 	// 调度配置
-	Schedule  *string
-	NextRunAt *time.Time
+	Schedule         *string
+	ScheduleTimezone *string
+	MissedRunPolicy  *string
+	NextRunAt        *time.Time
 	// 状态
 	Status *string
 	// 元数据
 	Metadata map[string]any
+	// 定义变更时记录到新版本
+	ChangeLog string
+	UpdatedBy string
 }
 
-// UpdateWorkflow 更新工作流
+// UpdateWorkflow 更新工作流：定义或变量变化时生成新的不可变版本；状态改为 active 时发布最新版本
 func (s *WorkflowService) UpdateWorkflow(ctx context.Context, tenantID, workflowID string, req *UpdateWorkflowRequest) (*Workflow, error) {
 	// 查询工作流
 	workflow, err := s.GetWorkflow(ctx, tenantID, workflowID)
@@ -265,13 +301,35 @@ func (s *WorkflowService) UpdateWorkflow(ctx context.Context, tenantID, workflow
 	if req.Visibility != nil {
 		updates["visibility"] = *req.Visibility
 	}
-	// 调度配置
-	if req.Schedule != nil {
-		updates["schedule"] = *req.Schedule
-	}
-	if req.NextRunAt != nil {
+	// 调度配置：表达式或时区变化时重新计算下次执行时间，清空表达式即停止调度
+	if req.Schedule != nil || req.ScheduleTimezone != nil {
+		schedule, timezone := workflow.Schedule, workflow.ScheduleTimezone
+		if req.Schedule != nil {
+			schedule = *req.Schedule
+			updates["schedule"] = schedule
+		}
+		if req.ScheduleTimezone != nil {
+			timezone = *req.ScheduleTimezone
+			updates["schedule_timezone"] = timezone
+		}
+		if schedule == "" {
+			updates["next_run_at"] = nil
+		} else {
+			next, err := NextScheduleTime(schedule, timezone, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			updates["next_run_at"] = next
+		}
+	} else if req.NextRunAt != nil {
 		updates["next_run_at"] = *req.NextRunAt
 	}
+	if req.MissedRunPolicy != nil {
+		if err := ValidateMissedRunPolicy(*req.MissedRunPolicy); err != nil {
+			return nil, err
+		}
+		updates["missed_run_policy"] = *req.MissedRunPolicy
+	}
 	// 状态
 	if req.Status != nil {
 		updates["status"] = *req.Status
@@ -282,10 +340,44 @@ func (s *WorkflowService) UpdateWorkflow(ctx context.Context, tenantID, workflow
 	}
 	updates["updated_at"] = time.Now().UTC()
 
-	// 执行更新
-	if err := s.db.WithContext(ctx).
-		Model(workflow).
-		Updates(updates).Error; err != nil {
+	// 执行更新，定义变化时在同一事务中追加版本
+	definition, variables := workflow.Definition, workflow.Variables
+	if req.Definition != nil {
+		definition = *req.Definition
+	}
+	if req.Variables != nil {
+		variables = req.Variables
+	}
+	changed := definitionChanged(workflow, definition, variables)
+
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if changed {
+			// 版本化之前创建的工作流先保留修改前的定义作为首个版本
+			if workflow.LatestVersion == 0 {
+				if _, err := appendVersion(tx, workflow, workflow.CreatedBy, "初始版本"); err != nil {
+					return err
+				}
+			}
+			next := *workflow
+			next.Definition, next.Variables = definition, variables
+			if req.Version != nil {
+				next.Version = *req.Version
+			}
+			if _, err := appendVersion(tx, &next, req.UpdatedBy, req.ChangeLog); err != nil {
+				return err
+			}
+			workflow.LatestVersion = next.LatestVersion
+		}
+
+		if req.Status != nil && *req.Status == "active" && workflow.LatestVersion > 0 {
+			updates["published_version"] = workflow.LatestVersion
+			updates["published_at"] = time.Now().UTC()
+		}
+		return tx.Model(workflow).Updates(updates).Error
+	}); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("更新工作流失败: %w", err)
 	}
 
@@ -355,6 +447,10 @@ func (s *WorkflowService) validateWorkflowDefinition(definition WorkflowDefiniti
 			if node.Data.AgentID == "" && node.Data.Label == "" {
 				return fmt.Errorf("Agent 节点 %s 缺少配置", node.ID)
 			}
+		case NodeTypeWorkflow:
+			if node.Data.WorkflowID == "" {
+				return fmt.Errorf("子工作流节点 %s 缺少 workflowId", node.ID)
+			}
 		}
 	}
 
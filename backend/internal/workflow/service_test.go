@@ -17,7 +17,7 @@ func setupWorkflowServiceTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("打开 sqlite 失败: %v", err)
 	}
-	if err := db.AutoMigrate(&Workflow{}); err != nil {
+	if err := db.AutoMigrate(&Workflow{}, &WorkflowVersion{}); err != nil {
 		t.Fatalf("迁移 schema 失败: %v", err)
 	}
 	return db
@@ -0,0 +1,300 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict 并发保存导致版本号冲突
+var ErrVersionConflict = errors.New("工作流已被其他人修改，请刷新后重试")
+
+// WorkflowVersion 工作流定义的不可变版本：每次保存定义生成一个新版本，执行固定使用某个版本
+type WorkflowVersion struct {
+	ID         string             `json:"id" gorm:"primaryKey;type:uuid"`
+	WorkflowID string             `json:"workflowId" gorm:"type:uuid;not null;uniqueIndex:idx_workflow_versions_number"`
+	TenantID   string             `json:"tenantId" gorm:"type:uuid;not null;index"`
+	Number     int                `json:"number" gorm:"not null;uniqueIndex:idx_workflow_versions_number"`
+	Label      string             `json:"label" gorm:"size:50"` // 保存时的版本标签
+	Definition WorkflowDefinition `json:"definition" gorm:"type:jsonb;not null;serializer:json"`
+	Variables  map[string]any     `json:"variables" gorm:"type:jsonb;serializer:json"`
+	ChangeLog  string             `json:"changeLog" gorm:"type:text"`
+	CreatedBy  string             `json:"createdBy" gorm:"size:100"`
+	CreatedAt  time.Time          `json:"createdAt" gorm:"not null;autoCreateTime"`
+}
+
+func (WorkflowVersion) TableName() string {
+	return "workflow_versions"
+}
+
+// AutoMigrate 自动迁移版本表
+func (s *WorkflowService) AutoMigrate() error {
+	return s.db.AutoMigrate(&WorkflowVersion{})
+}
+
+// newWorkflowVersion 以工作流当前定义构造版本
+func newWorkflowVersion(wf *Workflow, number int, createdBy, changeLog string) *WorkflowVersion {
+	return &WorkflowVersion{
+		ID:         uuid.New().String(),
+		WorkflowID: wf.ID,
+		TenantID:   wf.TenantID,
+		Number:     number,
+		Label:      wf.Version,
+		Definition: wf.Definition,
+		Variables:  wf.Variables,
+		ChangeLog:  changeLog,
+		CreatedBy:  createdBy,
+	}
+}
+
+// appendVersion 为工作流追加一个版本；以 latest_version 为条件递增，并发保存时返回 ErrVersionConflict
+func appendVersion(tx *gorm.DB, wf *Workflow, createdBy, changeLog string) (*WorkflowVersion, error) {
+	number := wf.LatestVersion + 1
+	result := tx.Model(&Workflow{}).
+		Where("id = ? AND latest_version = ?", wf.ID, wf.LatestVersion).
+		Update("latest_version", number)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrVersionConflict
+	}
+
+	version := newWorkflowVersion(wf, number, createdBy, changeLog)
+	if err := tx.Create(version).Error; err != nil {
+		return nil, fmt.Errorf("创建版本失败: %w", err)
+	}
+	wf.LatestVersion = number
+	return version, nil
+}
+
+// ResolveVersion 确定执行使用的定义版本：number > 0 时使用指定版本，否则使用已发布版本；
+// 尚未发布的工作流使用最新草稿版本，版本化之前创建、没有任何版本的工作流以当前定义生成首个版本
+func ResolveVersion(ctx context.Context, db *gorm.DB, wf *Workflow, number int) (*WorkflowVersion, error) {
+	switch {
+	case number > 0:
+	case wf.PublishedVersion > 0:
+		number = wf.PublishedVersion
+	case wf.LatestVersion > 0:
+		number = wf.LatestVersion
+	default:
+		version, err := appendVersion(db.WithContext(ctx), wf, wf.CreatedBy, "初始版本")
+		if errors.Is(err, ErrVersionConflict) {
+			// 其他执行已生成首个版本
+			return getVersion(ctx, db, wf.ID, 1)
+		}
+		return version, err
+	}
+	return getVersion(ctx, db, wf.ID, number)
+}
+
+// getVersion 查询工作流的指定版本
+func getVersion(ctx context.Context, db *gorm.DB, workflowID string, number int) (*WorkflowVersion, error) {
+	var version WorkflowVersion
+	if err := db.WithContext(ctx).
+		Where("workflow_id = ? AND number = ?", workflowID, number).
+		First(&version).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("工作流版本 %d 不存在", number)
+		}
+		return nil, fmt.Errorf("查询工作流版本失败: %w", err)
+	}
+	return &version, nil
+}
+
+// ListVersions 列出工作流的版本历史（不含定义内容）
+func (s *WorkflowService) ListVersions(ctx context.Context, tenantID, workflowID string) ([]WorkflowVersion, error) {
+	if _, err := s.GetWorkflow(ctx, tenantID, workflowID); err != nil {
+		return nil, err
+	}
+	var versions []WorkflowVersion
+	err := s.db.WithContext(ctx).
+		Omit("definition", "variables").
+		Where("workflow_id = ?", workflowID).
+		Order("number DESC").
+		Find(&versions).Error
+	return versions, err
+}
+
+// GetVersion 获取工作流的指定版本
+func (s *WorkflowService) GetVersion(ctx context.Context, tenantID, workflowID string, number int) (*WorkflowVersion, error) {
+	if _, err := s.GetWorkflow(ctx, tenantID, workflowID); err != nil {
+		return nil, err
+	}
+	return getVersion(ctx, s.db, workflowID, number)
+}
+
+// PublishVersion 发布指定版本（number 为 0 时发布最新版本），之后的执行与定时触发使用该版本
+func (s *WorkflowService) PublishVersion(ctx context.Context, tenantID, workflowID string, number int) (*Workflow, error) {
+	wf, err := s.GetWorkflow(ctx, tenantID, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if wf.TenantID != tenantID {
+		return nil, fmt.Errorf("无权限发布此工作流")
+	}
+	if number <= 0 {
+		// 版本化之前创建的工作流先以当前定义生成首个版本
+		version, err := ResolveVersion(ctx, s.db, wf, wf.LatestVersion)
+		if err != nil {
+			return nil, err
+		}
+		number = version.Number
+	} else if _, err := getVersion(ctx, s.db, workflowID, number); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := s.db.WithContext(ctx).Model(wf).Updates(map[string]any{
+		"published_version": number,
+		"published_at":      now,
+		"status":            "active",
+		"updated_at":        now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("发布工作流失败: %w", err)
+	}
+	return s.GetWorkflow(ctx, tenantID, workflowID)
+}
+
+// WorkflowVersionDiff 两个版本之间的定义差异
+type WorkflowVersionDiff struct {
+	Version1     int                       `json:"version1"`
+	Version2     int                       `json:"version2"`
+	AddedNodes   []string                  `json:"addedNodes"`
+	RemovedNodes []string                  `json:"removedNodes"`
+	ChangedNodes map[string]map[string]any `json:"changedNodes"` // 节点 ID -> 字段变更
+	AddedEdges   []string                  `json:"addedEdges"`   // source->target
+	RemovedEdges []string                  `json:"removedEdges"`
+	Changes      map[string]any            `json:"changes"` // 节点与连线之外的字段变更（变量、自动化配置等）
+}
+
+// DiffVersions 比较两个版本的定义
+func (s *WorkflowService) DiffVersions(ctx context.Context, tenantID, workflowID string, v1, v2 int) (*WorkflowVersionDiff, error) {
+	version1, err := s.GetVersion(ctx, tenantID, workflowID, v1)
+	if err != nil {
+		return nil, err
+	}
+	version2, err := s.GetVersion(ctx, tenantID, workflowID, v2)
+	if err != nil {
+		return nil, err
+	}
+	return diffVersions(version1, version2), nil
+}
+
+// diffVersions 比较两个版本：节点按 ID、连线按端点对应，节点坐标等界面属性不计入差异
+func diffVersions(v1, v2 *WorkflowVersion) *WorkflowVersionDiff {
+	diff := &WorkflowVersionDiff{
+		Version1:     v1.Number,
+		Version2:     v2.Number,
+		AddedNodes:   []string{},
+		RemovedNodes: []string{},
+		ChangedNodes: make(map[string]map[string]any),
+		AddedEdges:   []string{},
+		RemovedEdges: []string{},
+	}
+
+	nodes1 := make(map[string]Node, len(v1.Definition.Nodes))
+	for _, n := range v1.Definition.Nodes {
+		nodes1[n.ID] = n
+	}
+	nodes2 := make(map[string]Node, len(v2.Definition.Nodes))
+	for _, n := range v2.Definition.Nodes {
+		nodes2[n.ID] = n
+		old, ok := nodes1[n.ID]
+		if !ok {
+			diff.AddedNodes = append(diff.AddedNodes, n.ID)
+			continue
+		}
+		changes := diffMaps(toJSONMap(old.Data), toJSONMap(n.Data))
+		if old.Type != n.Type {
+			changes["type"] = map[string]any{"old": old.Type, "new": n.Type}
+		}
+		if len(changes) > 0 {
+			diff.ChangedNodes[n.ID] = changes
+		}
+	}
+	for id := range nodes1 {
+		if _, ok := nodes2[id]; !ok {
+			diff.RemovedNodes = append(diff.RemovedNodes, id)
+		}
+	}
+
+	edges1, edges2 := edgeKeys(v1.Definition.Edges), edgeKeys(v2.Definition.Edges)
+	for key := range edges2 {
+		if !edges1[key] {
+			diff.AddedEdges = append(diff.AddedEdges, key)
+		}
+	}
+	for key := range edges1 {
+		if !edges2[key] {
+			diff.RemovedEdges = append(diff.RemovedEdges, key)
+		}
+	}
+
+	def1, def2 := v1.Definition, v2.Definition
+	def1.Nodes, def1.Edges, def1.Viewport = nil, nil, Viewport{}
+	def2.Nodes, def2.Edges, def2.Viewport = nil, nil, Viewport{}
+	diff.Changes = diffMaps(toJSONMap(def1), toJSONMap(def2))
+	if !reflect.DeepEqual(toJSONMap(v1.Variables), toJSONMap(v2.Variables)) {
+		diff.Changes["variables"] = map[string]any{"old": v1.Variables, "new": v2.Variables}
+	}
+
+	sort.Strings(diff.AddedNodes)
+	sort.Strings(diff.RemovedNodes)
+	sort.Strings(diff.AddedEdges)
+	sort.Strings(diff.RemovedEdges)
+	return diff
+}
+
+// edgeKeys 以 source->target 标识连线
+func edgeKeys(edges []Edge) map[string]bool {
+	keys := make(map[string]bool, len(edges))
+	for _, e := range edges {
+		keys[e.Source+"->"+e.Target] = true
+	}
+	return keys
+}
+
+// toJSONMap 经 JSON 序列化转换为 map，便于按字段比较；nil 与空对象视为相同
+func toJSONMap(v any) map[string]any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	m := make(map[string]any)
+	_ = json.Unmarshal(data, &m)
+	return m
+}
+
+// diffMaps 找出两个 map 的字段差异
+func diffMaps(m1, m2 map[string]any) map[string]any {
+	changes := make(map[string]any)
+	for k, v1 := range m1 {
+		v2, ok := m2[k]
+		switch {
+		case !ok:
+			changes[k] = map[string]any{"old": v1, "new": nil, "removed": true}
+		case !reflect.DeepEqual(v1, v2):
+			changes[k] = map[string]any{"old": v1, "new": v2}
+		}
+	}
+	for k, v2 := range m2 {
+		if _, ok := m1[k]; !ok {
+			changes[k] = map[string]any{"old": nil, "new": v2, "added": true}
+		}
+	}
+	return changes
+}
+
+// definitionChanged 定义或变量是否与工作流当前保存的内容不同
+func definitionChanged(wf *Workflow, definition WorkflowDefinition, variables map[string]any) bool {
+	return !reflect.DeepEqual(toJSONMap(wf.Definition), toJSONMap(definition)) ||
+		!reflect.DeepEqual(toJSONMap(wf.Variables), toJSONMap(variables))
+}
@@ -0,0 +1,184 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWorkflowVersionsLifecycle(t *testing.T) {
+	ctx := context.Background()
+	db := setupWorkflowServiceTestDB(t)
+	svc := NewWorkflowService(db)
+
+	created, err := svc.CreateWorkflow(ctx, &CreateWorkflowRequest{
+		TenantID:   "tenant-A",
+		Name:       "长篇写作",
+		Definition: sampleWorkflowDefinition(),
+		CreatedBy:  "user-1",
+	})
+	if err != nil {
+		t.Fatalf("创建工作流失败: %v", err)
+	}
+	if created.LatestVersion != 1 || created.PublishedVersion != 0 {
+		t.Fatalf("新建草稿应只有版本 1 且未发布: %+v", created)
+	}
+
+	// 只改名称不生成版本
+	name := "长篇写作 v2"
+	if _, err := svc.UpdateWorkflow(ctx, "tenant-A", created.ID, &UpdateWorkflowRequest{Name: &name}); err != nil {
+		t.Fatalf("更新名称失败: %v", err)
+	}
+
+	// 修改定义生成版本 2
+	def := sampleWorkflowDefinition()
+	def.Nodes[1].Data.AgentID = "agent_editor"
+	def.Nodes = append(def.Nodes, Node{ID: "review", Type: NodeTypeAgent, Data: NodeData{Label: "审校", AgentID: "agent_reviewer"}})
+	def.Edges = append(def.Edges, Edge{ID: "edge2", Source: "writer", Target: "review"})
+	updated, err := svc.UpdateWorkflow(ctx, "tenant-A", created.ID, &UpdateWorkflowRequest{Definition: &def, ChangeLog: "增加审校"})
+	if err != nil {
+		t.Fatalf("更新定义失败: %v", err)
+	}
+	if updated.LatestVersion != 2 {
+		t.Fatalf("最新版本应为 2, 实际 %d", updated.LatestVersion)
+	}
+
+	// 未发布时执行使用最新草稿，发布后固定使用已发布版本
+	version, err := ResolveVersion(ctx, db, updated, 0)
+	if err != nil || version.Number != 2 {
+		t.Fatalf("未发布时应使用最新版本: %v %+v", err, version)
+	}
+	published, err := svc.PublishVersion(ctx, "tenant-A", created.ID, 1)
+	if err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+	if published.PublishedVersion != 1 || published.Status != "active" {
+		t.Fatalf("发布状态错误: %+v", published)
+	}
+	version, err = ResolveVersion(ctx, db, published, 0)
+	if err != nil || version.Number != 1 || len(version.Definition.Nodes) != 2 {
+		t.Fatalf("发布后应使用版本 1: %v %+v", err, version)
+	}
+
+	versions, err := svc.ListVersions(ctx, "tenant-A", created.ID)
+	if err != nil || len(versions) != 2 {
+		t.Fatalf("版本列表错误: %v %d", err, len(versions))
+	}
+
+	diff, err := svc.DiffVersions(ctx, "tenant-A", created.ID, 1, 2)
+	if err != nil {
+		t.Fatalf("比较版本失败: %v", err)
+	}
+	if len(diff.AddedNodes) != 1 || diff.AddedNodes[0] != "review" {
+		t.Fatalf("新增节点错误: %+v", diff.AddedNodes)
+	}
+	if len(diff.AddedEdges) != 1 || diff.AddedEdges[0] != "writer->review" {
+		t.Fatalf("新增连线错误: %+v", diff.AddedEdges)
+	}
+	if _, ok := diff.ChangedNodes["writer"]["agentId"]; !ok {
+		t.Fatalf("writer 节点的 agentId 变更未识别: %+v", diff.ChangedNodes)
+	}
+}
+
+func TestResolveVersionSnapshotsLegacyWorkflow(t *testing.T) {
+	ctx := context.Background()
+	db := setupWorkflowServiceTestDB(t)
+
+	// 版本化之前创建的工作流没有任何版本
+	legacy := &Workflow{ID: "11111111-1111-1111-1111-111111111111", TenantID: "tenant-A", Name: "旧工作流", Definition: sampleWorkflowDefinition(), Version: "v1"}
+	if err := db.Create(legacy).Error; err != nil {
+		t.Fatalf("写入工作流失败: %v", err)
+	}
+
+	version, err := ResolveVersion(ctx, db, legacy, 0)
+	if err != nil {
+		t.Fatalf("解析版本失败: %v", err)
+	}
+	if version.Number != 1 || legacy.LatestVersion != 1 {
+		t.Fatalf("应以当前定义生成版本 1: %+v", version)
+	}
+}
+
+type recordingLauncher struct {
+	workflowID string
+	version    int
+	input      map[string]any
+}
+
+func (l *recordingLauncher) Launch(_ context.Context, workflowID, _, _ string, version int, input map[string]any) (string, error) {
+	l.workflowID, l.version, l.input = workflowID, version, input
+	return "exec-1", nil
+}
+
+func TestTriggersLaunchPinnedVersion(t *testing.T) {
+	ctx := context.Background()
+	db := setupWorkflowServiceTestDB(t)
+	svc := NewWorkflowService(db)
+	created, err := svc.CreateWorkflow(ctx, &CreateWorkflowRequest{
+		TenantID:   "tenant-A",
+		Name:       "长篇写作",
+		Definition: sampleWorkflowDefinition(),
+		CreatedBy:  "user-1",
+	})
+	if err != nil {
+		t.Fatalf("创建工作流失败: %v", err)
+	}
+	def := sampleWorkflowDefinition()
+	def.Nodes[1].Data.AgentID = "agent_editor"
+	if _, err := svc.UpdateWorkflow(ctx, "tenant-A", created.ID, &UpdateWorkflowRequest{Definition: &def}); err != nil {
+		t.Fatalf("更新定义失败: %v", err)
+	}
+	if _, err := svc.PublishVersion(ctx, "tenant-A", created.ID, 2); err != nil {
+		t.Fatalf("发布失败: %v", err)
+	}
+
+	// Webhook 触发器固定版本 1：已发布版本 2 不影响触发
+	webhooks := NewWebhookTriggerService(db)
+	if err := webhooks.AutoMigrate(); err != nil {
+		t.Fatalf("迁移 schema 失败: %v", err)
+	}
+	launcher := &recordingLauncher{}
+	webhooks.SetLauncher(launcher)
+	resp, err := webhooks.CreateTrigger(ctx, &CreateTriggerRequest{
+		TenantID:        "tenant-A",
+		WorkflowID:      created.ID,
+		WorkflowVersion: 1,
+		Name:            "新稿件",
+		InputMapping:    map[string]string{"title": "outline"},
+	})
+	if err != nil {
+		t.Fatalf("创建 Webhook 触发器失败: %v", err)
+	}
+	trigger, err := webhooks.GetTriggerByPath(ctx, resp.EndpointPath)
+	if err != nil {
+		t.Fatalf("读取 Webhook 触发器失败: %v", err)
+	}
+	executionID, err := webhooks.Fire(ctx, trigger, map[string]any{"title": "第一卷", "ignored": true}, "203.0.113.1")
+	if err != nil || executionID != "exec-1" {
+		t.Fatalf("触发失败: %v %s", err, executionID)
+	}
+	if launcher.workflowID != created.ID || launcher.version != 1 || launcher.input["outline"] != "第一卷" || len(launcher.input) != 1 {
+		t.Fatalf("Webhook 应按映射提交固定版本的执行: %+v", launcher)
+	}
+	logs, err := webhooks.ListLogs(ctx, trigger.ID, 10)
+	if err != nil || len(logs) != 1 || logs[0].ExecutionID != "exec-1" || logs[0].Status != "success" {
+		t.Fatalf("触发日志错误: %v %+v", err, logs)
+	}
+
+	// 条件触发器固定版本 1
+	conditions := NewConditionTriggerService(db)
+	if err := conditions.AutoMigrate(); err != nil {
+		t.Fatalf("迁移 schema 失败: %v", err)
+	}
+	launcher = &recordingLauncher{}
+	conditions.SetLauncher(launcher)
+	actions, _ := json.Marshal([]TriggerAction{{Type: "execute_workflow"}})
+	condTrigger := &ConditionTrigger{TenantID: "tenant-A", WorkflowID: created.ID, WorkflowVersion: 1, Name: "文件更新", Enabled: true, Actions: actions}
+	if err := conditions.CreateTrigger(ctx, condTrigger); err != nil {
+		t.Fatalf("创建条件触发器失败: %v", err)
+	}
+	conditions.fireTrigger(ctx, condTrigger, DataChangeEvent{TenantID: "tenant-A", EntityType: "workspace_file", UserID: "user-1"})
+	if launcher.workflowID != created.ID || launcher.version != 1 {
+		t.Fatalf("条件触发器应提交固定版本的执行: %+v", launcher)
+	}
+}
@@ -19,6 +19,9 @@ type WebhookTrigger struct {
 	TenantID   string `json:"tenantId" gorm:"type:uuid;not null;index"`
 	WorkflowID string `json:"workflowId" gorm:"type:uuid;not null;index"`
 
+	// WorkflowVersion 触发时固定使用的定义版本，0 表示跟随已发布版本
+	WorkflowVersion int `json:"workflowVersion" gorm:"default:0"`
+
 	// 触发器配置
 	Name        string `json:"name" gorm:"size:255;not null"`
 	Description string `json:"description" gorm:"type:text"`
@@ -75,7 +78,8 @@ func (WebhookTriggerLog) TableName() string {
 
 // WebhookTriggerService Webhook 触发器服务
 type WebhookTriggerService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	launcher WorkflowLauncher
 }
 
 // NewWebhookTriggerService 创建服务
@@ -83,6 +87,11 @@ func NewWebhookTriggerService(db *gorm.DB) *WebhookTriggerService {
 	return &WebhookTriggerService{db: db}
 }
 
+// SetLauncher 设置工作流执行入口，未设置时触发请求返回错误
+func (s *WebhookTriggerService) SetLauncher(launcher WorkflowLauncher) {
+	s.launcher = launcher
+}
+
 // AutoMigrate 自动迁移
 func (s *WebhookTriggerService) AutoMigrate() error {
 	return s.db.AutoMigrate(&WebhookTrigger{}, &WebhookTriggerLog{})
@@ -92,6 +101,7 @@ func (s *WebhookTriggerService) AutoMigrate() error {
 type CreateTriggerRequest struct {
 	TenantID           string
 	WorkflowID         string
+	WorkflowVersion    int // 0 表示跟随已发布版本
 	Name               string
 	Description        string
 	InputMapping       map[string]string
@@ -118,6 +128,11 @@ func (s *WebhookTriggerService) CreateTrigger(ctx context.Context, req *CreateTr
 		}
 		return nil, fmt.Errorf("查询工作流失败: %w", err)
 	}
+	if req.WorkflowVersion > 0 {
+		if _, err := getVersion(ctx, s.db, wf.ID, req.WorkflowVersion); err != nil {
+			return nil, err
+		}
+	}
 
 	// 生成唯一端点路径
 	pathBytes := make([]byte, 16)
@@ -138,6 +153,7 @@ func (s *WebhookTriggerService) CreateTrigger(ctx context.Context, req *CreateTr
 		ID:                 uuid.New().String(),
 		TenantID:           req.TenantID,
 		WorkflowID:         req.WorkflowID,
+		WorkflowVersion:    req.WorkflowVersion,
 		Name:               req.Name,
 		Description:        req.Description,
 		EndpointPath:       endpointPath,
@@ -191,6 +207,39 @@ func (s *WebhookTriggerService) RecordTrigger(ctx context.Context, triggerID str
 		}).Error
 }
 
+// Fire 按输入映射提交触发器所属工作流的执行（固定使用触发器配置的版本），记录触发次数与日志，返回执行 ID
+func (s *WebhookTriggerService) Fire(ctx context.Context, trigger *WebhookTrigger, payload map[string]any, requestIP string) (string, error) {
+	if s.launcher == nil {
+		return "", fmt.Errorf("未配置工作流执行入口")
+	}
+
+	input := payload
+	if len(trigger.InputMapping) > 0 {
+		input = make(map[string]any, len(trigger.InputMapping))
+		for param, field := range trigger.InputMapping {
+			if value, ok := payload[param]; ok {
+				input[field] = value
+			}
+		}
+	}
+
+	log := &WebhookTriggerLog{TriggerID: trigger.ID, TenantID: trigger.TenantID, RequestIP: requestIP, Status: "success"}
+	executionID, err := s.launcher.Launch(ctx, trigger.WorkflowID, trigger.TenantID, trigger.CreatedBy, trigger.WorkflowVersion, input)
+	if err != nil {
+		log.Status = "failed"
+		log.ErrorMessage = err.Error()
+	} else {
+		log.ExecutionID = executionID
+		if recordErr := s.RecordTrigger(ctx, trigger.ID); recordErr != nil {
+			return executionID, recordErr
+		}
+	}
+	if logErr := s.CreateLog(ctx, log); logErr != nil && err == nil {
+		err = logErr
+	}
+	return executionID, err
+}
+
 // CreateLog 创建触发日志
 func (s *WebhookTriggerService) CreateLog(ctx context.Context, log *WebhookTriggerLog) error {
 	log.ID = uuid.New().String()
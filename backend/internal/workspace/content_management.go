@@ -111,10 +111,20 @@ type ImportTextRequest struct {
 
 // ImportResult 导入结果
 type ImportResult struct {
-	WorkID       string `json:"workId"`
-	WorkName     string `json:"workName"`
-	ChapterCount int    `json:"chapterCount"`
-	TotalWords   int    `json:"totalWords"`
+	WorkID       string            `json:"workId"`
+	WorkName     string            `json:"workName"`
+	VolumeCount  int               `json:"volumeCount"`
+	ChapterCount int               `json:"chapterCount"`
+	TotalWords   int               `json:"totalWords"`
+	Chapters     []ImportedChapter `json:"chapters"`
+}
+
+// ImportedChapter 导入的章节及其字数
+type ImportedChapter struct {
+	NodeID    string `json:"nodeId"`
+	Title     string `json:"title"`
+	Volume    string `json:"volume,omitempty"`
+	WordCount int    `json:"wordCount"`
 }
 
 // GetOutlineView 获取大纲总览
@@ -583,56 +593,68 @@ func (s *Service) ImportFromText(ctx context.Context, req *ImportTextRequest) (*
 		workName = "导入作品"
 	}
 
+	entries := make([]importEntry, 0, len(chapters))
+	for _, ch := range chapters {
+		entries = append(entries, importEntry{Title: ch.Title, Content: ch.Content})
+	}
+	return s.createImportedWork(ctx, req.TenantID, req.ParentID, req.UserID, workName, entries)
+}
+
+// importEntry 待导入的大纲条目，Children 非空时为卷
+type importEntry struct {
+	Title    string
+	Content  string
+	Children []importEntry
+}
+
+// createImportedWork 在一个事务内创建作品、卷与章节节点
+func (s *Service) createImportedWork(ctx context.Context, tenantID string, parentID *string, userID, workName string, entries []importEntry) (*ImportResult, error) {
 	result := &ImportResult{
 		WorkName: workName,
+		Chapters: []ImportedChapter{},
 	}
 
 	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 创建作品文件夹
-		workNode := &WorkspaceNode{
-			ID:        uuid.New().String(),
-			TenantID:  req.TenantID,
-			ParentID:  req.ParentID,
-			Name:      workName,
-			Slug:      slugify(workName),
-			Type:      "folder",
-			Category:  ContentTypeWork,
-			CreatedBy: req.UserID,
-			UpdatedBy: req.UserID,
-			CreatedAt: time.Now().UTC(),
-			UpdatedAt: time.Now().UTC(),
+		now := time.Now().UTC()
+		newNode := func(parent *WorkspaceNode, name, slug, nodeType, category string, sortOrder int) *WorkspaceNode {
+			node := &WorkspaceNode{
+				ID:        uuid.New().String(),
+				TenantID:  tenantID,
+				Name:      name,
+				Slug:      slug,
+				Type:      nodeType,
+				Category:  category,
+				SortOrder: sortOrder,
+				CreatedBy: userID,
+				UpdatedBy: userID,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if parent != nil {
+				node.ParentID = &parent.ID
+				node.NodePath = fmt.Sprintf("%s/%s", parent.NodePath, slug)
+			}
+			return node
 		}
-		if req.ParentID != nil {
+
+		// 创建作品文件夹
+		workNode := newNode(nil, workName, slugify(workName), "folder", ContentTypeWork, 0)
+		workNode.ParentID = parentID
+		if parentID != nil {
 			var parent WorkspaceNode
-			if err := tx.Where("id = ?", *req.ParentID).First(&parent).Error; err == nil {
+			if err := tx.Where("id = ? AND tenant_id = ?", *parentID, tenantID).First(&parent).Error; err == nil {
 				workNode.NodePath = fmt.Sprintf("%s/%s", parent.NodePath, workNode.Slug)
 			}
 		} else {
 			workNode.NodePath = workNode.Slug
 		}
-
 		if err := tx.Create(workNode).Error; err != nil {
 			return err
 		}
 		result.WorkID = workNode.ID
 
-		// 创建章节
-		for i, ch := range chapters {
-			chapterNode := &WorkspaceNode{
-				ID:        uuid.New().String(),
-				TenantID:  req.TenantID,
-				ParentID:  &workNode.ID,
-				Name:      ch.Title,
-				Slug:      fmt.Sprintf("chapter-%d", i+1),
-				Type:      "file",
-				NodePath:  fmt.Sprintf("%s/chapter-%d", workNode.NodePath, i+1),
-				Category:  ContentTypeChapter,
-				SortOrder: i,
-				CreatedBy: req.UserID,
-				UpdatedBy: req.UserID,
-				CreatedAt: time.Now().UTC(),
-				UpdatedAt: time.Now().UTC(),
-			}
+		createChapter := func(parent *WorkspaceNode, volume string, entry importEntry, index int) error {
+			chapterNode := newNode(parent, entry.Title, fmt.Sprintf("chapter-%d", index+1), "file", ContentTypeChapter, index)
 			if err := tx.Create(chapterNode).Error; err != nil {
 				return err
 			}
@@ -640,23 +662,22 @@ func (s *Service) ImportFromText(ctx context.Context, req *ImportTextRequest) (*
 			// 创建文件和版本
 			file := &WorkspaceFile{
 				ID:        uuid.New().String(),
-				TenantID:  req.TenantID,
+				TenantID:  tenantID,
 				NodeID:    chapterNode.ID,
 				Category:  ContentTypeChapter,
-				CreatedBy: req.UserID,
-				UpdatedBy: req.UserID,
-				CreatedAt: time.Now().UTC(),
-				UpdatedAt: time.Now().UTC(),
+				CreatedBy: userID,
+				UpdatedBy: userID,
+				CreatedAt: now,
+				UpdatedAt: now,
 			}
-
 			version := &WorkspaceFileVersion{
 				ID:        uuid.New().String(),
 				FileID:    file.ID,
-				TenantID:  req.TenantID,
-				Content:   ch.Content,
-				Summary:   fmt.Sprintf("导入章节: %s", ch.Title),
-				CreatedBy: req.UserID,
-				CreatedAt: time.Now().UTC(),
+				TenantID:  tenantID,
+				Content:   entry.Content,
+				Summary:   fmt.Sprintf("导入章节: %s", entry.Title),
+				CreatedBy: userID,
+				CreatedAt: now,
 			}
 			file.LatestVersionID = version.ID
 
@@ -667,8 +688,38 @@ func (s *Service) ImportFromText(ctx context.Context, req *ImportTextRequest) (*
 				return err
 			}
 
+			words := countWords(entry.Content)
 			result.ChapterCount++
-			result.TotalWords += countWords(ch.Content)
+			result.TotalWords += words
+			result.Chapters = append(result.Chapters, ImportedChapter{
+				NodeID:    chapterNode.ID,
+				Title:     entry.Title,
+				Volume:    volume,
+				WordCount: words,
+			})
+			return nil
+		}
+
+		// 创建卷与章节
+		volumes := 0
+		for i, entry := range entries {
+			if len(entry.Children) == 0 {
+				if err := createChapter(workNode, "", entry, i); err != nil {
+					return err
+				}
+				continue
+			}
+			volumes++
+			volumeNode := newNode(workNode, entry.Title, fmt.Sprintf("volume-%d", volumes), "folder", ContentTypeVolume, i)
+			if err := tx.Create(volumeNode).Error; err != nil {
+				return err
+			}
+			result.VolumeCount++
+			for j, child := range entry.Children {
+				if err := createChapter(volumeNode, entry.Title, child, j); err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
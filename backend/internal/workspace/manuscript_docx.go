@@ -0,0 +1,197 @@
+package workspace
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeDOCX 以 Office Open XML 格式写出作品，卷/章/场景按大纲层级映射为 Heading1-4 样式
+func writeDOCX(w io.Writer, book *manuscriptBook) error {
+	zw := zip.NewWriter(w)
+	entries := []zipEntry{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"word/_rels/document.xml.rels", docxDocumentRels},
+		{"word/styles.xml", docxStyles},
+		{"word/document.xml", buildDOCXDocument(book)},
+		{"docProps/core.xml", buildDOCXCoreProps(book)},
+		{"docProps/app.xml", docxAppProps},
+	}
+	if err := writeZipEntries(zw, entries); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+  <Default Extension="xml" ContentType="application/xml"/>
+  <Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+  <Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+  <Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+  <Override PartName="/docProps/app.xml" ContentType="application/vnd.openxmlformats-officedocument.extended-properties+xml"/>
+</Types>
+`
+
+const docxPackageRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+  <Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+  <Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties" Target="docProps/app.xml"/>
+</Relationships>
+`
+
+const docxDocumentRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>
+`
+
+const docxAppProps = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties">
+  <Application>Workspace Manuscript Export</Application>
+</Properties>
+`
+
+const docxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:docDefaults>
+    <w:rPrDefault><w:rPr>
+      <w:rFonts w:ascii="Times New Roman" w:hAnsi="Times New Roman" w:eastAsia="宋体" w:cs="Times New Roman"/>
+      <w:sz w:val="24"/><w:szCs w:val="24"/><w:lang w:val="en-US" w:eastAsia="zh-CN"/>
+    </w:rPr></w:rPrDefault>
+    <w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="360" w:lineRule="auto"/></w:pPr></w:pPrDefault>
+  </w:docDefaults>
+  <w:style w:type="paragraph" w:default="1" w:styleId="Normal">
+    <w:name w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:ind w:firstLineChars="200" w:firstLine="480"/><w:jc w:val="both"/></w:pPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Title">
+    <w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:spacing w:before="2400" w:after="480"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr>
+    <w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="56"/><w:szCs w:val="56"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Subtitle">
+    <w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/></w:pPr>
+    <w:rPr><w:sz w:val="32"/><w:szCs w:val="32"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Heading1">
+    <w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:keepNext/><w:spacing w:before="480" w:after="360"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/><w:outlineLvl w:val="0"/></w:pPr>
+    <w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="44"/><w:szCs w:val="44"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Heading2">
+    <w:name w:val="heading 2"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:keepNext/><w:spacing w:before="360" w:after="240"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/><w:outlineLvl w:val="1"/></w:pPr>
+    <w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="36"/><w:szCs w:val="36"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Heading3">
+    <w:name w:val="heading 3"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:outlineLvl w:val="2"/></w:pPr>
+    <w:rPr><w:rFonts w:eastAsia="黑体"/><w:b/><w:sz w:val="30"/><w:szCs w:val="30"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Heading4">
+    <w:name w:val="heading 4"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:keepNext/><w:spacing w:before="240" w:after="120"/><w:ind w:firstLineChars="0" w:firstLine="0"/><w:outlineLvl w:val="3"/></w:pPr>
+    <w:rPr><w:b/><w:sz w:val="26"/><w:szCs w:val="26"/></w:rPr>
+  </w:style>
+  <w:style w:type="paragraph" w:styleId="Quote">
+    <w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+    <w:pPr><w:ind w:left="720" w:right="720" w:firstLineChars="0" w:firstLine="0"/></w:pPr>
+    <w:rPr><w:i/></w:rPr>
+  </w:style>
+</w:styles>
+`
+
+func buildDOCXDocument(book *manuscriptBook) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:body>
+`)
+	docxParagraph(&sb, "Title", "", []markupRun{{Text: book.Title}})
+	if book.Author != "" {
+		docxParagraph(&sb, "Subtitle", "", []markupRun{{Text: book.Author}})
+	}
+	for _, section := range book.Sections {
+		level := min(section.Level, 4)
+		extra := ""
+		if level <= 2 {
+			// 卷与章另起一页
+			extra = "<w:pageBreakBefore/>"
+		}
+		docxParagraph(&sb, fmt.Sprintf("Heading%d", level), extra, []markupRun{{Text: section.Title}})
+		for _, block := range section.Blocks {
+			switch {
+			case block.Break:
+				docxParagraph(&sb, "", `<w:ind w:firstLineChars="0" w:firstLine="0"/><w:jc w:val="center"/>`, []markupRun{{Text: "* * *"}})
+			case block.Heading > 0:
+				docxParagraph(&sb, fmt.Sprintf("Heading%d", min(level+block.Heading, 4)), "", block.Runs)
+			case block.Quote:
+				docxParagraph(&sb, "Quote", "", block.Runs)
+			default:
+				docxParagraph(&sb, "", "", block.Runs)
+			}
+		}
+	}
+	sb.WriteString(`    <w:sectPr>
+      <w:pgSz w:w="11906" w:h="16838"/>
+      <w:pgMar w:top="1440" w:right="1800" w:bottom="1440" w:left="1800" w:header="851" w:footer="992" w:gutter="0"/>
+    </w:sectPr>
+  </w:body>
+</w:document>
+`)
+	return sb.String()
+}
+
+// docxParagraph 写出一个段落，style 为空时使用 Normal 样式
+func docxParagraph(sb *strings.Builder, style, extraPPr string, runs []markupRun) {
+	sb.WriteString("    <w:p>")
+	if style != "" || extraPPr != "" {
+		sb.WriteString("<w:pPr>")
+		if style != "" {
+			fmt.Fprintf(sb, `<w:pStyle w:val="%s"/>`, style)
+		}
+		sb.WriteString(extraPPr)
+		sb.WriteString("</w:pPr>")
+	}
+	for _, r := range runs {
+		sb.WriteString("<w:r>")
+		if r.Bold || r.Italic {
+			sb.WriteString("<w:rPr>")
+			if r.Bold {
+				sb.WriteString("<w:b/>")
+			}
+			if r.Italic {
+				sb.WriteString("<w:i/>")
+			}
+			sb.WriteString("</w:rPr>")
+		}
+		fmt.Fprintf(sb, `<w:t xml:space="preserve">%s</w:t>`, xmlEscape(r.Text))
+		sb.WriteString("</w:r>")
+	}
+	sb.WriteString("</w:p>\n")
+}
+
+func buildDOCXCoreProps(book *manuscriptBook) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+`)
+	fmt.Fprintf(&sb, "  <dc:title>%s</dc:title>\n", xmlEscape(book.Title))
+	if book.Author != "" {
+		fmt.Fprintf(&sb, "  <dc:creator>%s</dc:creator>\n", xmlEscape(book.Author))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&sb, "  <dc:description>%s</dc:description>\n", xmlEscape(book.Description))
+	}
+	fmt.Fprintf(&sb, "  <dc:language>%s</dc:language>\n", xmlEscape(book.Language))
+	fmt.Fprintf(&sb, "  <dc:identifier>%s</dc:identifier>\n", xmlEscape(book.Identifier))
+	fmt.Fprintf(&sb, "  <dcterms:modified xsi:type=\"dcterms:W3CDTF\">%s</dcterms:modified>\n", book.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	sb.WriteString("</cp:coreProperties>\n")
+	return sb.String()
+}
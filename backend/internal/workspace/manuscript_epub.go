@@ -0,0 +1,302 @@
+package workspace
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const epubCSS = `body { font-family: serif; line-height: 1.8; margin: 0 5%; }
+h1, h2, h3, h4 { text-align: center; margin: 2em 0 1em; }
+p { text-indent: 2em; margin: 0 0 0.5em; }
+blockquote { margin: 1em 2em; font-style: italic; }
+p.break { text-align: center; text-indent: 0; margin: 1em 0; }
+div.cover { text-align: center; }
+div.cover img { max-width: 100%; max-height: 100%; }
+`
+
+// zipEntry 压缩包中的文本条目
+type zipEntry struct {
+	name string
+	body string
+}
+
+// writeZipEntries 按顺序写入文本条目
+func writeZipEntries(zw *zip.Writer, entries []zipEntry) error {
+	for _, e := range entries {
+		fw, err := zw.Create(e.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, e.body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// epubDocument 一个 XHTML 内容文件，level ≤ 2 的节点各自另起一个文件
+type epubDocument struct {
+	Name     string
+	Sections []int
+}
+
+// writeEPUB 以 EPUB 3 格式写出作品（同时生成 nav.xhtml 与兼容 EPUB 2 阅读器的 toc.ncx）
+func writeEPUB(w io.Writer, book *manuscriptBook) error {
+	zw := zip.NewWriter(w)
+
+	// mimetype 必须为第一个且不压缩的条目
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	docs := splitEPUBDocuments(book.Sections)
+	sectionDoc := make(map[int]string, len(book.Sections))
+	for _, doc := range docs {
+		for _, idx := range doc.Sections {
+			sectionDoc[idx] = doc.Name
+		}
+	}
+
+	files := []zipEntry{
+		{"META-INF/container.xml", epubContainerXML},
+		{"OEBPS/styles/book.css", epubCSS},
+		{"OEBPS/content.opf", buildEPUBPackage(book, docs)},
+		{"OEBPS/nav.xhtml", buildEPUBNav(book, sectionDoc)},
+		{"OEBPS/toc.ncx", buildEPUBNCX(book, sectionDoc)},
+	}
+	if book.Cover != nil {
+		files = append(files, zipEntry{"OEBPS/text/cover.xhtml", buildEPUBCoverPage(book)})
+	}
+	for _, doc := range docs {
+		files = append(files, zipEntry{"OEBPS/text/" + doc.Name, buildEPUBDocument(book, doc)})
+	}
+	if err := writeZipEntries(zw, files); err != nil {
+		return err
+	}
+
+	if book.Cover != nil {
+		// 图片已压缩，直接存储
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "OEBPS/images/cover" + book.Cover.Ext, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(book.Cover.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const epubContainerXML = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// splitEPUBDocuments 按卷/章切分内容文件，更深层级的场景并入所属章节
+func splitEPUBDocuments(sections []manuscriptSection) []epubDocument {
+	var docs []epubDocument
+	for i, section := range sections {
+		if len(docs) == 0 || section.Level <= 2 {
+			docs = append(docs, epubDocument{Name: fmt.Sprintf("section-%03d.xhtml", len(docs)+1)})
+		}
+		docs[len(docs)-1].Sections = append(docs[len(docs)-1].Sections, i)
+	}
+	return docs
+}
+
+func buildEPUBPackage(book *manuscriptBook, docs []epubDocument) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="` + xmlEscape(book.Language) + `">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&sb, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", xmlEscape(book.Identifier))
+	fmt.Fprintf(&sb, "    <dc:title>%s</dc:title>\n", xmlEscape(book.Title))
+	fmt.Fprintf(&sb, "    <dc:language>%s</dc:language>\n", xmlEscape(book.Language))
+	if book.Author != "" {
+		fmt.Fprintf(&sb, "    <dc:creator id=\"creator\">%s</dc:creator>\n", xmlEscape(book.Author))
+	}
+	if book.Publisher != "" {
+		fmt.Fprintf(&sb, "    <dc:publisher>%s</dc:publisher>\n", xmlEscape(book.Publisher))
+	}
+	if book.Description != "" {
+		fmt.Fprintf(&sb, "    <dc:description>%s</dc:description>\n", xmlEscape(book.Description))
+	}
+	fmt.Fprintf(&sb, "    <meta property=\"dcterms:modified\">%s</meta>\n", book.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	if book.Cover != nil {
+		// EPUB 2 阅读器通过 meta name="cover" 识别封面
+		sb.WriteString("    <meta name=\"cover\" content=\"cover-image\"/>\n")
+	}
+	sb.WriteString("  </metadata>\n  <manifest>\n")
+	sb.WriteString("    <item id=\"nav\" href=\"nav.xhtml\" media-type=\"application/xhtml+xml\" properties=\"nav\"/>\n")
+	sb.WriteString("    <item id=\"ncx\" href=\"toc.ncx\" media-type=\"application/x-dtbncx+xml\"/>\n")
+	sb.WriteString("    <item id=\"css\" href=\"styles/book.css\" media-type=\"text/css\"/>\n")
+	if book.Cover != nil {
+		fmt.Fprintf(&sb, "    <item id=\"cover-image\" href=\"images/cover%s\" media-type=\"%s\" properties=\"cover-image\"/>\n", book.Cover.Ext, book.Cover.MediaType)
+		sb.WriteString("    <item id=\"cover\" href=\"text/cover.xhtml\" media-type=\"application/xhtml+xml\"/>\n")
+	}
+	for i, doc := range docs {
+		fmt.Fprintf(&sb, "    <item id=\"doc%d\" href=\"text/%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, doc.Name)
+	}
+	sb.WriteString("  </manifest>\n  <spine toc=\"ncx\">\n")
+	if book.Cover != nil {
+		sb.WriteString("    <itemref idref=\"cover\" linear=\"no\"/>\n")
+	}
+	sb.WriteString("    <itemref idref=\"nav\"/>\n")
+	for i := range docs {
+		fmt.Fprintf(&sb, "    <itemref idref=\"doc%d\"/>\n", i+1)
+	}
+	sb.WriteString("  </spine>\n</package>\n")
+	return sb.String()
+}
+
+// epubTocNode 目录树节点
+type epubTocNode struct {
+	index    int
+	children []*epubTocNode
+}
+
+// buildEPUBTocTree 按层级将扁平的章节列表还原为目录树
+func buildEPUBTocTree(sections []manuscriptSection) []*epubTocNode {
+	root := &epubTocNode{index: -1}
+	stack := []*epubTocNode{root}
+	levels := []int{0}
+	for i, section := range sections {
+		for len(stack) > 1 && levels[len(levels)-1] >= section.Level {
+			stack = stack[:len(stack)-1]
+			levels = levels[:len(levels)-1]
+		}
+		node := &epubTocNode{index: i}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, node)
+		stack = append(stack, node)
+		levels = append(levels, section.Level)
+	}
+	return root.children
+}
+
+func xhtmlHeader(sb *strings.Builder, book *manuscriptBook, title string, epubNS bool) {
+	sb.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE html>\n")
+	lang := xmlEscape(book.Language)
+	if epubNS {
+		fmt.Fprintf(sb, "<html xmlns=\"http://www.w3.org/1999/xhtml\" xmlns:epub=\"http://www.idpf.org/2007/ops\" xml:lang=\"%s\" lang=\"%s\">\n", lang, lang)
+	} else {
+		fmt.Fprintf(sb, "<html xmlns=\"http://www.w3.org/1999/xhtml\" xml:lang=\"%s\" lang=\"%s\">\n", lang, lang)
+	}
+	fmt.Fprintf(sb, "<head>\n  <meta charset=\"UTF-8\"/>\n  <title>%s</title>\n", xmlEscape(title))
+}
+
+func buildEPUBNav(book *manuscriptBook, sectionDoc map[int]string) string {
+	var sb strings.Builder
+	xhtmlHeader(&sb, book, book.Title, true)
+	sb.WriteString("</head>\n<body>\n  <nav epub:type=\"toc\" id=\"toc\">\n")
+	fmt.Fprintf(&sb, "    <h1>%s</h1>\n", xmlEscape(book.Title))
+	var write func(nodes []*epubTocNode, indent string)
+	write = func(nodes []*epubTocNode, indent string) {
+		sb.WriteString(indent + "<ol>\n")
+		for _, node := range nodes {
+			section := book.Sections[node.index]
+			fmt.Fprintf(&sb, "%s  <li><a href=\"text/%s#s%d\">%s</a>", indent, sectionDoc[node.index], node.index+1, xmlEscape(section.Title))
+			if len(node.children) > 0 {
+				sb.WriteString("\n")
+				write(node.children, indent+"    ")
+				sb.WriteString(indent + "  ")
+			}
+			sb.WriteString("</li>\n")
+		}
+		sb.WriteString(indent + "</ol>\n")
+	}
+	write(buildEPUBTocTree(book.Sections), "    ")
+	sb.WriteString("  </nav>\n</body>\n</html>\n")
+	return sb.String()
+}
+
+func buildEPUBNCX(book *manuscriptBook, sectionDoc map[int]string) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+`)
+	fmt.Fprintf(&sb, "    <meta name=\"dtb:uid\" content=\"%s\"/>\n", xmlEscape(book.Identifier))
+	sb.WriteString("  </head>\n")
+	fmt.Fprintf(&sb, "  <docTitle><text>%s</text></docTitle>\n  <navMap>\n", xmlEscape(book.Title))
+	order := 0
+	var write func(nodes []*epubTocNode, indent string)
+	write = func(nodes []*epubTocNode, indent string) {
+		for _, node := range nodes {
+			order++
+			fmt.Fprintf(&sb, "%s<navPoint id=\"np%d\" playOrder=\"%d\">\n", indent, node.index+1, order)
+			fmt.Fprintf(&sb, "%s  <navLabel><text>%s</text></navLabel>\n", indent, xmlEscape(book.Sections[node.index].Title))
+			fmt.Fprintf(&sb, "%s  <content src=\"text/%s#s%d\"/>\n", indent, sectionDoc[node.index], node.index+1)
+			write(node.children, indent+"  ")
+			sb.WriteString(indent + "</navPoint>\n")
+		}
+	}
+	write(buildEPUBTocTree(book.Sections), "    ")
+	sb.WriteString("  </navMap>\n</ncx>\n")
+	return sb.String()
+}
+
+func buildEPUBCoverPage(book *manuscriptBook) string {
+	var sb strings.Builder
+	xhtmlHeader(&sb, book, book.Title, false)
+	sb.WriteString("  <link rel=\"stylesheet\" type=\"text/css\" href=\"../styles/book.css\"/>\n</head>\n<body>\n")
+	fmt.Fprintf(&sb, "  <div class=\"cover\"><img src=\"../images/cover%s\" alt=\"%s\"/></div>\n", book.Cover.Ext, xmlEscape(book.Title))
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+func buildEPUBDocument(book *manuscriptBook, doc epubDocument) string {
+	var sb strings.Builder
+	xhtmlHeader(&sb, book, book.Sections[doc.Sections[0]].Title, false)
+	sb.WriteString("  <link rel=\"stylesheet\" type=\"text/css\" href=\"../styles/book.css\"/>\n</head>\n<body>\n")
+	for _, idx := range doc.Sections {
+		section := book.Sections[idx]
+		level := min(section.Level, 4)
+		fmt.Fprintf(&sb, "  <h%d id=\"s%d\">%s</h%d>\n", level, idx+1, xmlEscape(section.Title), level)
+		for _, block := range section.Blocks {
+			writeEPUBBlock(&sb, block, level)
+		}
+	}
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+// writeEPUBBlock 正文中的 Markdown 标题排在所属节点标题之下
+func writeEPUBBlock(sb *strings.Builder, block markupBlock, level int) {
+	switch {
+	case block.Break:
+		sb.WriteString("  <p class=\"break\">* * *</p>\n")
+	case block.Heading > 0:
+		h := min(level+block.Heading, 6)
+		fmt.Fprintf(sb, "  <h%d>%s</h%d>\n", h, epubRuns(block.Runs), h)
+	case block.Quote:
+		fmt.Fprintf(sb, "  <blockquote><p>%s</p></blockquote>\n", epubRuns(block.Runs))
+	default:
+		fmt.Fprintf(sb, "  <p>%s</p>\n", epubRuns(block.Runs))
+	}
+}
+
+func epubRuns(runs []markupRun) string {
+	var sb strings.Builder
+	for _, r := range runs {
+		text := xmlEscape(r.Text)
+		if r.Italic {
+			text = "<em>" + text + "</em>"
+		}
+		if r.Bold {
+			text = "<strong>" + text + "</strong>"
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
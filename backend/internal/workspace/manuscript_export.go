@@ -0,0 +1,542 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"backend/internal/logger"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 手稿导出格式
+const (
+	FormatEPUB ExportFormat = "epub"
+	FormatDOCX ExportFormat = "docx"
+)
+
+// 手稿导出任务状态
+const (
+	ManuscriptExportPending   = "pending"
+	ManuscriptExportRunning   = "running"
+	ManuscriptExportSucceeded = "succeeded"
+	ManuscriptExportFailed    = "failed"
+	ManuscriptExportExpired   = "expired"
+)
+
+const (
+	defaultManuscriptExportTTL = 7 * 24 * time.Hour
+	manuscriptRecoverAfter     = 10 * time.Minute
+	maxCoverImageSize          = 10 << 20 // 10MB
+)
+
+var (
+	ErrManuscriptExportNotFound = errors.New("导出任务不存在")
+	ErrManuscriptExportNotReady = errors.New("导出文件尚未生成")
+)
+
+// coverMediaTypes EPUB 支持的封面图片类型及扩展名
+var coverMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ManuscriptExportQueue 手稿导出任务队列
+type ManuscriptExportQueue interface {
+	EnqueueManuscriptExport(ctx context.Context, jobID string) error
+}
+
+// ManuscriptExportOptions 手稿元数据
+type ManuscriptExportOptions struct {
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Description string `json:"description,omitempty"`
+	CoverNodeID string `json:"cover_node_id,omitempty"` // 已上传的封面图片节点
+}
+
+// ManuscriptExportJob 作品手稿导出任务
+type ManuscriptExportJob struct {
+	ID            string                  `gorm:"type:uuid;primaryKey" json:"id"`
+	TenantID      string                  `gorm:"type:uuid;not null;index:idx_manuscript_export_work" json:"tenant_id"`
+	WorkID        string                  `gorm:"type:uuid;not null;index:idx_manuscript_export_work" json:"work_id"`
+	Format        string                  `gorm:"size:10;not null" json:"format"`
+	Options       ManuscriptExportOptions `gorm:"type:jsonb;serializer:json" json:"options"`
+	Status        string                  `gorm:"size:20;not null;index" json:"status"`
+	Progress      int                     `gorm:"default:0" json:"progress"` // 0-100
+	TotalSections int                     `gorm:"default:0" json:"total_sections"`
+	DoneSections  int                     `gorm:"default:0" json:"done_sections"`
+	WordCount     int                     `gorm:"default:0" json:"word_count"`
+	FileName      string                  `gorm:"size:255" json:"file_name"`
+	FileSize      int64                   `gorm:"default:0" json:"file_size"`
+	StoragePath   string                  `gorm:"size:1024" json:"-"`
+	Error         string                  `gorm:"type:text" json:"error,omitempty"`
+	Attempts      int                     `gorm:"default:0" json:"attempts"`
+	CreatedBy     string                  `gorm:"type:uuid" json:"created_by"`
+	CreatedAt     time.Time               `gorm:"not null" json:"created_at"`
+	UpdatedAt     time.Time               `gorm:"not null" json:"updated_at"`
+	StartedAt     *time.Time              `json:"started_at,omitempty"`
+	CompletedAt   *time.Time              `json:"completed_at,omitempty"`
+	ExpiresAt     *time.Time              `json:"expires_at,omitempty"`
+}
+
+// TableName 表名
+func (ManuscriptExportJob) TableName() string {
+	return "workspace_manuscript_exports"
+}
+
+// ManuscriptExportRequest 手稿导出请求
+type ManuscriptExportRequest struct {
+	TenantID string
+	WorkID   string
+	UserID   string
+	Format   ExportFormat
+	Options  ManuscriptExportOptions
+}
+
+// manuscriptBook 渲染 EPUB / DOCX 所需的完整作品
+type manuscriptBook struct {
+	Identifier  string
+	Title       string
+	Author      string
+	Language    string
+	Publisher   string
+	Description string
+	Modified    time.Time
+	Cover       *manuscriptCover
+	Sections    []manuscriptSection
+}
+
+// manuscriptCover 封面图片
+type manuscriptCover struct {
+	Data      []byte
+	MediaType string
+	Ext       string
+}
+
+// manuscriptSection 按大纲顺序展开的卷/章/场景
+type manuscriptSection struct {
+	NodeID   string
+	Level    int // 1 为作品的直接子节点
+	Title    string
+	Category string
+	Blocks   []markupBlock
+}
+
+// ManuscriptExportService 作品手稿（EPUB / DOCX）异步导出服务
+type ManuscriptExportService struct {
+	db        *gorm.DB
+	svc       *Service
+	queue     ManuscriptExportQueue
+	outputDir string
+	ttl       time.Duration
+}
+
+// NewManuscriptExportService 创建手稿导出服务，outputDir 为空时写入上传目录下的 exports
+func NewManuscriptExportService(svc *Service, outputDir string) *ManuscriptExportService {
+	if outputDir == "" {
+		outputDir = filepath.Join(svc.GetStoragePath(), "exports")
+	}
+	return &ManuscriptExportService{
+		db:        svc.db,
+		svc:       svc,
+		outputDir: outputDir,
+		ttl:       defaultManuscriptExportTTL,
+	}
+}
+
+// SetQueue 设置导出任务队列，未设置时在进程内异步执行
+func (s *ManuscriptExportService) SetQueue(queue ManuscriptExportQueue) {
+	s.queue = queue
+}
+
+// AutoMigrate 自动迁移表
+func (s *ManuscriptExportService) AutoMigrate() error {
+	return s.db.AutoMigrate(&ManuscriptExportJob{})
+}
+
+// CreateExport 创建导出任务并入队
+func (s *ManuscriptExportService) CreateExport(ctx context.Context, req *ManuscriptExportRequest) (*ManuscriptExportJob, error) {
+	if req.Format != FormatEPUB && req.Format != FormatDOCX {
+		return nil, fmt.Errorf("不支持的导出格式: %s", req.Format)
+	}
+	work, err := s.svc.GetNode(ctx, req.TenantID, req.WorkID)
+	if err != nil {
+		return nil, err
+	}
+	if work == nil || work.Type != "folder" {
+		return nil, errors.New("作品不存在")
+	}
+
+	now := time.Now().UTC()
+	job := &ManuscriptExportJob{
+		ID:        uuid.New().String(),
+		TenantID:  req.TenantID,
+		WorkID:    work.ID,
+		Format:    string(req.Format),
+		Options:   req.Options,
+		Status:    ManuscriptExportPending,
+		CreatedBy: req.UserID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+	s.dispatch(ctx, job.ID)
+	return job, nil
+}
+
+// GetExport 获取导出任务（含进度）
+func (s *ManuscriptExportService) GetExport(ctx context.Context, tenantID, jobID string) (*ManuscriptExportJob, error) {
+	var job ManuscriptExportJob
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND tenant_id = ?", jobID, tenantID).
+		First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrManuscriptExportNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListExports 列出作品的导出任务
+func (s *ManuscriptExportService) ListExports(ctx context.Context, tenantID, workID string, limit int) ([]ManuscriptExportJob, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	var jobs []ManuscriptExportJob
+	err := s.db.WithContext(ctx).
+		Where("tenant_id = ? AND work_id = ?", tenantID, workID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+// OpenExport 打开已完成的导出文件
+func (s *ManuscriptExportService) OpenExport(ctx context.Context, tenantID, jobID string) (*ManuscriptExportJob, *os.File, error) {
+	job, err := s.GetExport(ctx, tenantID, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if job.Status != ManuscriptExportSucceeded || job.StoragePath == "" {
+		return nil, nil, ErrManuscriptExportNotReady
+	}
+	file, err := os.Open(job.StoragePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, file, nil
+}
+
+// RunExport 执行导出任务，由 worker 或进程内调度调用；已完成的任务直接返回
+func (s *ManuscriptExportService) RunExport(ctx context.Context, jobID string) error {
+	var job ManuscriptExportJob
+	if err := s.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrManuscriptExportNotFound
+		}
+		return err
+	}
+	if job.Status == ManuscriptExportSucceeded || job.Status == ManuscriptExportExpired {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	if err := s.updateJob(ctx, job.ID, map[string]any{
+		"status":        ManuscriptExportRunning,
+		"progress":      0,
+		"done_sections": 0,
+		"error":         "",
+		"attempts":      job.Attempts + 1,
+		"started_at":    now,
+	}); err != nil {
+		return err
+	}
+
+	book, err := s.buildBook(ctx, &job)
+	if err == nil {
+		err = s.writeBook(ctx, &job, book)
+	}
+	if err != nil {
+		if updateErr := s.updateJob(context.Background(), job.ID, map[string]any{
+			"status": ManuscriptExportFailed,
+			"error":  err.Error(),
+		}); updateErr != nil {
+			logger.Warn("更新导出任务状态失败", zap.String("job_id", job.ID), zap.Error(updateErr))
+		}
+		return err
+	}
+	return nil
+}
+
+// RecoverPending 重新调度长时间未开始或执行中断的任务
+func (s *ManuscriptExportService) RecoverPending(ctx context.Context) (int, error) {
+	var jobs []ManuscriptExportJob
+	if err := s.db.WithContext(ctx).
+		Where("status IN ? AND updated_at < ?", []string{ManuscriptExportPending, ManuscriptExportRunning}, time.Now().UTC().Add(-manuscriptRecoverAfter)).
+		Limit(100).
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		s.dispatch(ctx, job.ID)
+	}
+	return len(jobs), nil
+}
+
+// CleanupExpired 删除过期的导出文件
+func (s *ManuscriptExportService) CleanupExpired(ctx context.Context) (int, error) {
+	var jobs []ManuscriptExportJob
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", ManuscriptExportSucceeded, time.Now().UTC()).
+		Limit(500).
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, job := range jobs {
+		if err := os.Remove(job.StoragePath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("删除过期导出文件失败", zap.String("job_id", job.ID), zap.Error(err))
+			continue
+		}
+		if err := s.updateJob(ctx, job.ID, map[string]any{"status": ManuscriptExportExpired, "storage_path": ""}); err == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// StartMaintenance 周期性恢复中断任务并清理过期文件
+func (s *ManuscriptExportService) StartMaintenance(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if n, err := s.RecoverPending(ctx); err != nil {
+					logger.Warn("恢复导出任务失败", zap.Error(err))
+				} else if n > 0 {
+					logger.Info("重新调度导出任务", zap.Int("count", n))
+				}
+				if _, err := s.CleanupExpired(ctx); err != nil {
+					logger.Warn("清理过期导出文件失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// dispatch 入队导出任务，无队列或入队失败时在进程内执行
+func (s *ManuscriptExportService) dispatch(ctx context.Context, jobID string) {
+	if s.queue != nil {
+		err := s.queue.EnqueueManuscriptExport(ctx, jobID)
+		if err == nil {
+			return
+		}
+		logger.Warn("导出任务入队失败，改为进程内执行", zap.String("job_id", jobID), zap.Error(err))
+	}
+	go func() {
+		if err := s.RunExport(context.Background(), jobID); err != nil {
+			logger.Warn("导出任务执行失败", zap.String("job_id", jobID), zap.Error(err))
+		}
+	}()
+}
+
+// buildBook 按大纲顺序读取作品内容并更新进度（读取阶段占 0-90%）
+func (s *ManuscriptExportService) buildBook(ctx context.Context, job *ManuscriptExportJob) (*manuscriptBook, error) {
+	view, err := s.svc.GetOutlineView(ctx, job.TenantID, job.WorkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(view.Items) == 0 {
+		return nil, errors.New("作品不存在")
+	}
+
+	var items []*OutlineItem
+	var levels []int
+	var walk func(children []*OutlineItem, level int)
+	walk = func(children []*OutlineItem, level int) {
+		for _, child := range children {
+			items = append(items, child)
+			levels = append(levels, level)
+			walk(child.Children, level+1)
+		}
+	}
+	walk(view.Items[0].Children, 1)
+	if len(items) == 0 {
+		return nil, errors.New("作品没有可导出的章节")
+	}
+
+	book := &manuscriptBook{
+		Identifier:  "urn:uuid:" + job.WorkID,
+		Title:       firstNonEmpty(job.Options.Title, view.WorkName),
+		Author:      job.Options.Author,
+		Language:    firstNonEmpty(job.Options.Language, "zh-CN"),
+		Publisher:   job.Options.Publisher,
+		Description: job.Options.Description,
+		Modified:    time.Now().UTC().Truncate(time.Second),
+	}
+	if job.Options.CoverNodeID != "" {
+		if book.Cover, err = s.loadCover(ctx, job.TenantID, job.Options.CoverNodeID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.updateJob(ctx, job.ID, map[string]any{"total_sections": len(items)}); err != nil {
+		return nil, err
+	}
+	words, lastProgress := 0, 0
+	for i, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		section := manuscriptSection{
+			NodeID:   item.ID,
+			Level:    levels[i],
+			Title:    item.Name,
+			Category: item.Category,
+		}
+		if item.Type == "file" {
+			detail, err := s.svc.GetFileDetail(ctx, job.TenantID, item.ID)
+			if err != nil {
+				return nil, fmt.Errorf("读取章节 %s 失败: %w", item.Name, err)
+			}
+			if detail.Version != nil {
+				section.Blocks = parseMarkup(detail.Version.Content)
+				words += countWords(detail.Version.Content)
+			}
+		}
+		book.Sections = append(book.Sections, section)
+
+		if progress := (i + 1) * 90 / len(items); progress != lastProgress || i == len(items)-1 {
+			lastProgress = progress
+			if err := s.updateJob(ctx, job.ID, map[string]any{
+				"progress":      progress,
+				"done_sections": i + 1,
+				"word_count":    words,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return book, nil
+}
+
+// loadCover 读取封面图片
+func (s *ManuscriptExportService) loadCover(ctx context.Context, tenantID, nodeID string) (*manuscriptCover, error) {
+	upload, err := s.svc.GetFileForDownload(ctx, tenantID, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("封面图片不可用: %w", err)
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(upload.MimeType, ";")[0]))
+	ext, ok := coverMediaTypes[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("不支持的封面图片类型: %s", upload.MimeType)
+	}
+	f, err := os.Open(upload.StoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("读取封面图片失败: %w", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxCoverImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取封面图片失败: %w", err)
+	}
+	if len(data) > maxCoverImageSize {
+		return nil, errors.New("封面图片超过 10MB")
+	}
+	return &manuscriptCover{Data: data, MediaType: mediaType, Ext: ext}, nil
+}
+
+// writeBook 渲染到临时文件后原子替换，并将任务标记为完成
+func (s *ManuscriptExportService) writeBook(ctx context.Context, job *ManuscriptExportJob, book *manuscriptBook) error {
+	dir := filepath.Join(s.outputDir, job.TenantID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建导出目录失败: %w", err)
+	}
+	path := filepath.Join(dir, job.ID+"."+job.Format)
+	tmp, err := os.CreateTemp(dir, job.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	switch ExportFormat(job.Format) {
+	case FormatEPUB:
+		err = writeEPUB(tmp, book)
+	case FormatDOCX:
+		err = writeDOCX(tmp, book)
+	default:
+		err = fmt.Errorf("不支持的导出格式: %s", job.Format)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("生成导出文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("保存导出文件失败: %w", err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	return s.updateJob(ctx, job.ID, map[string]any{
+		"status":       ManuscriptExportSucceeded,
+		"progress":     100,
+		"file_name":    sanitizeExportFileName(book.Title) + "." + job.Format,
+		"file_size":    stat.Size(),
+		"storage_path": path,
+		"completed_at": now,
+		"expires_at":   now.Add(s.ttl),
+	})
+}
+
+func (s *ManuscriptExportService) updateJob(ctx context.Context, jobID string, updates map[string]any) error {
+	updates["updated_at"] = time.Now().UTC()
+	return s.db.WithContext(ctx).Model(&ManuscriptExportJob{}).Where("id = ?", jobID).Updates(updates).Error
+}
+
+// sanitizeExportFileName 生成下载文件名，去除路径与控制字符
+func sanitizeExportFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "manuscript"
+	}
+	return name
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package workspace
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingExportQueue struct {
+	jobs []string
+}
+
+func (q *recordingExportQueue) EnqueueManuscriptExport(_ context.Context, jobID string) error {
+	q.jobs = append(q.jobs, jobID)
+	return nil
+}
+
+func readZipEntries(t *testing.T, path string) ([]string, map[string]string) {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+	var names []string
+	bodies := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		names = append(names, f.Name)
+		bodies[f.Name] = string(data)
+		if f.Name != "mimetype" && !strings.HasSuffix(f.Name, ".css") {
+			dec := xml.NewDecoder(strings.NewReader(string(data)))
+			for {
+				_, err := dec.Token()
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err, f.Name)
+			}
+		}
+	}
+	return names, bodies
+}
+
+func TestManuscriptExportEPUBAndDOCX(t *testing.T) {
+	ctx := context.Background()
+	db := setupWorkspaceTestDB(t)
+	require.NoError(t, db.AutoMigrate(&ManuscriptExportJob{}))
+	svc := NewService(db)
+	imported, err := svc.ImportFromText(ctx, &ImportTextRequest{
+		TenantID:   "tenant-export",
+		Content:    "第一章 启程\n他说：**快走**。\n---\n夜色 <渐深> & 冷\n第二章 归来\n> 旧信\n*终章*",
+		FileName:   "远行.txt",
+		UserID:     "user",
+		AutoDetect: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, imported.ChapterCount)
+
+	exports := NewManuscriptExportService(svc, t.TempDir())
+	queue := &recordingExportQueue{}
+	exports.SetQueue(queue)
+
+	_, err = exports.CreateExport(ctx, &ManuscriptExportRequest{TenantID: "tenant-export", WorkID: imported.WorkID, Format: "pdf"})
+	require.Error(t, err)
+
+	epubJob, err := exports.CreateExport(ctx, &ManuscriptExportRequest{
+		TenantID: "tenant-export",
+		WorkID:   imported.WorkID,
+		UserID:   "user",
+		Format:   FormatEPUB,
+		Options:  ManuscriptExportOptions{Author: "佚名"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, ManuscriptExportPending, epubJob.Status)
+	require.Equal(t, []string{epubJob.ID}, queue.jobs)
+
+	require.NoError(t, exports.RunExport(ctx, epubJob.ID))
+	job, err := exports.GetExport(ctx, "tenant-export", epubJob.ID)
+	require.NoError(t, err)
+	require.Equal(t, ManuscriptExportSucceeded, job.Status)
+	require.Equal(t, 100, job.Progress)
+	require.Equal(t, 2, job.DoneSections)
+	require.Equal(t, "远行.epub", job.FileName)
+	require.NotNil(t, job.ExpiresAt)
+
+	_, err = exports.GetExport(ctx, "other-tenant", epubJob.ID)
+	require.ErrorIs(t, err, ErrManuscriptExportNotFound)
+
+	names, bodies := readZipEntries(t, job.StoragePath)
+	require.Equal(t, "mimetype", names[0])
+	require.Equal(t, "application/epub+zip", bodies["mimetype"])
+	require.Contains(t, bodies["OEBPS/content.opf"], "<dc:creator id=\"creator\">佚名</dc:creator>")
+	require.Contains(t, bodies["OEBPS/content.opf"], "urn:uuid:"+imported.WorkID)
+	nav := bodies["OEBPS/nav.xhtml"]
+	require.Less(t, strings.Index(nav, "第一章 启程"), strings.Index(nav, "第二章 归来"))
+	chapter := bodies["OEBPS/text/section-001.xhtml"]
+	require.Contains(t, chapter, "<strong>快走</strong>")
+	require.Contains(t, chapter, "夜色 &lt;渐深&gt; &amp; 冷")
+	require.Contains(t, chapter, `<p class="break">`)
+	require.Contains(t, bodies["OEBPS/text/section-002.xhtml"], "<blockquote><p>旧信</p></blockquote>")
+
+	// 已完成的任务重复执行时保持不变
+	require.NoError(t, exports.RunExport(ctx, epubJob.ID))
+
+	docxJob, err := exports.CreateExport(ctx, &ManuscriptExportRequest{
+		TenantID: "tenant-export",
+		WorkID:   imported.WorkID,
+		Format:   FormatDOCX,
+	})
+	require.NoError(t, err)
+	require.NoError(t, exports.RunExport(ctx, docxJob.ID))
+	job, file, err := exports.OpenExport(ctx, "tenant-export", docxJob.ID)
+	require.NoError(t, err)
+	file.Close()
+
+	_, bodies = readZipEntries(t, job.StoragePath)
+	document := bodies["word/document.xml"]
+	require.Contains(t, document, `<w:pStyle w:val="Title"/>`)
+	require.Contains(t, document, `<w:pStyle w:val="Heading1"/><w:pageBreakBefore/></w:pPr><w:r><w:t xml:space="preserve">第二章 归来</w:t>`)
+	require.Contains(t, document, `<w:rPr><w:i/></w:rPr><w:t xml:space="preserve">终章</w:t>`)
+	require.Contains(t, bodies["word/styles.xml"], `w:styleId="Heading2"`)
+	require.Contains(t, bodies["[Content_Types].xml"], "/word/document.xml")
+
+	jobs, err := exports.ListExports(ctx, "tenant-export", imported.WorkID, 0)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+}
+
+func TestBuildEPUBTocTreeNestsByLevel(t *testing.T) {
+	sections := []manuscriptSection{
+		{Level: 1, Title: "第一卷"},
+		{Level: 2, Title: "第一章"},
+		{Level: 3, Title: "场景一"},
+		{Level: 2, Title: "第二章"},
+		{Level: 1, Title: "第二卷"},
+	}
+	tree := buildEPUBTocTree(sections)
+	require.Len(t, tree, 2)
+	require.Len(t, tree[0].children, 2)
+	require.Equal(t, 2, tree[0].children[0].children[0].index)
+	require.Equal(t, 4, tree[1].index)
+
+	docs := splitEPUBDocuments(sections)
+	require.Len(t, docs, 4)
+	require.Equal(t, []int{1, 2}, docs[1].Sections)
+}
@@ -0,0 +1,225 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"backend/internal/rag/parsers"
+)
+
+// maxManuscriptImportSize 手稿导入的文件大小上限
+const maxManuscriptImportSize = 100 << 20 // 100MB
+
+// ImportManuscriptRequest 手稿文件导入请求（EPUB / DOCX / TXT）
+type ImportManuscriptRequest struct {
+	TenantID string
+	ParentID *string
+	FileName string
+	Reader   io.Reader
+	UserID   string
+}
+
+// importHeading 按阅读顺序排列的标题及其正文；Level 为 0 表示无标题的续接内容
+type importHeading struct {
+	Title      string
+	Level      int
+	Paragraphs []parsers.Paragraph
+}
+
+// ImportManuscript 导入 EPUB（spine 顺序、目录标题）或 DOCX（标题样式）为卷/章节点，正文格式保留为 Markdown
+func (s *Service) ImportManuscript(ctx context.Context, req *ImportManuscriptRequest) (*ImportResult, error) {
+	data, err := io.ReadAll(io.LimitReader(req.Reader, maxManuscriptImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if len(data) > maxManuscriptImportSize {
+		return nil, errors.New("文件超过 100MB")
+	}
+
+	ext := strings.ToLower(filepath.Ext(req.FileName))
+	workName := strings.TrimSuffix(filepath.Base(req.FileName), filepath.Ext(req.FileName))
+	var headings []importHeading
+	switch ext {
+	case ".txt", ".md":
+		return s.ImportFromText(ctx, &ImportTextRequest{
+			TenantID:   req.TenantID,
+			ParentID:   req.ParentID,
+			Content:    string(data),
+			FileName:   workName,
+			UserID:     req.UserID,
+			AutoDetect: true,
+		})
+	case ".epub":
+		book, err := parsers.NewEpubParser().ParseBook(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		workName = firstNonEmpty(book.Title, workName)
+		for _, section := range book.Sections {
+			headings = append(headings, importHeading{Title: section.Title, Level: section.Depth, Paragraphs: section.Paragraphs})
+		}
+		// 没有目录时按正文中的 h1-h6 划分
+		if !slices.ContainsFunc(headings, func(h importHeading) bool { return h.Level > 0 }) {
+			var all []parsers.Paragraph
+			for _, h := range headings {
+				all = append(all, h.Paragraphs...)
+			}
+			headings = headingsFromParagraphs(all)
+		}
+	case ".docx":
+		doc, err := parsers.NewDocxParser().ParseStructured(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		workName = firstNonEmpty(doc.Title, workName)
+		headings = headingsFromParagraphs(doc.Paragraphs)
+	default:
+		return nil, fmt.Errorf("不支持的手稿格式: %s", ext)
+	}
+	if workName == "" {
+		workName = "导入作品"
+	}
+
+	entries := buildImportOutline(headings)
+	if len(entries) == 0 {
+		return nil, errors.New("未能解析出章节结构")
+	}
+	return s.createImportedWork(ctx, req.TenantID, req.ParentID, req.UserID, workName, entries)
+}
+
+// headingsFromParagraphs 以标题段落切分正文；全文没有标题时回退到 TXT 的章节识别规则
+func headingsFromParagraphs(paragraphs []parsers.Paragraph) []importHeading {
+	var headings []importHeading
+	for _, para := range paragraphs {
+		if para.HeadingLevel > 0 {
+			headings = append(headings, importHeading{Title: para.Text(), Level: para.HeadingLevel})
+			continue
+		}
+		if len(headings) == 0 {
+			headings = append(headings, importHeading{})
+		}
+		last := &headings[len(headings)-1]
+		last.Paragraphs = append(last.Paragraphs, para)
+	}
+	if slices.ContainsFunc(headings, func(h importHeading) bool { return h.Level > 0 }) {
+		return headings
+	}
+
+	var sb strings.Builder
+	for _, para := range paragraphs {
+		sb.WriteString(para.Markdown())
+		sb.WriteString("\n")
+	}
+	headings = headings[:0]
+	for _, ch := range parseChapters(sb.String(), true) {
+		headings = append(headings, importHeading{
+			Title:      ch.Title,
+			Level:      1,
+			Paragraphs: []parsers.Paragraph{{Runs: []parsers.Run{{Text: ch.Content}}}},
+		})
+	}
+	return headings
+}
+
+// buildImportOutline 将标题层级映射为卷/章：出现两级及以上标题时，最高一级中带下级标题的作为卷，
+// 次一级作为章，更深的标题并入章节正文成为 Markdown 小标题
+func buildImportOutline(headings []importHeading) []importEntry {
+	// 无标题内容并入上一节，开头的无标题内容作为序章
+	var merged []importHeading
+	for _, h := range headings {
+		if h.Level > 0 {
+			merged = append(merged, h)
+			continue
+		}
+		if len(merged) == 0 {
+			merged = append(merged, importHeading{Title: "序章", Level: -1})
+		}
+		last := &merged[len(merged)-1]
+		last.Paragraphs = append(last.Paragraphs, h.Paragraphs...)
+	}
+	if len(merged) > 0 && merged[0].Level < 0 && strings.TrimSpace(headingContent(merged[0])) == "" {
+		merged = merged[1:]
+	}
+
+	var levels []int
+	for _, h := range merged {
+		if h.Level > 0 && !slices.Contains(levels, h.Level) {
+			levels = append(levels, h.Level)
+		}
+	}
+	slices.Sort(levels)
+	volumeLevel, chapterLevel := 0, 0
+	switch len(levels) {
+	case 0:
+	case 1:
+		chapterLevel = levels[0]
+	default:
+		volumeLevel, chapterLevel = levels[0], levels[1]
+	}
+
+	var (
+		entries []importEntry
+		volume  *importEntry
+		chapter *importEntry
+	)
+	addChapter := func(entry importEntry) {
+		if volume != nil {
+			volume.Children = append(volume.Children, entry)
+			chapter = &volume.Children[len(volume.Children)-1]
+			return
+		}
+		entries = append(entries, entry)
+		chapter = &entries[len(entries)-1]
+	}
+	for i, h := range merged {
+		content := headingContent(h)
+		switch {
+		case h.Level < 0:
+			addChapter(importEntry{Title: h.Title, Content: content})
+			chapter = nil
+		case volumeLevel > 0 && h.Level == volumeLevel:
+			// 后面紧跟更低级标题时为卷，否则（如前言、后记）为独立章节
+			if i+1 < len(merged) && merged[i+1].Level > volumeLevel {
+				entries = append(entries, importEntry{Title: h.Title})
+				volume, chapter = &entries[len(entries)-1], nil
+				if content != "" {
+					addChapter(importEntry{Title: h.Title, Content: content})
+				}
+				continue
+			}
+			volume = nil
+			addChapter(importEntry{Title: h.Title, Content: content})
+		case h.Level <= chapterLevel || chapter == nil:
+			addChapter(importEntry{Title: h.Title, Content: content})
+		default:
+			// 场景等更深层级作为章节内的小标题
+			sub := strings.Repeat("#", min(h.Level-chapterLevel, 6)) + " " + h.Title
+			chapter.Content = strings.TrimSpace(chapter.Content + "\n" + sub + "\n" + content)
+		}
+	}
+	return entries
+}
+
+// headingContent 标题下正文的 Markdown，去掉与标题重复的首个标题段落
+func headingContent(h importHeading) string {
+	paragraphs := h.Paragraphs
+	if len(paragraphs) > 0 && paragraphs[0].HeadingLevel > 0 {
+		first := paragraphs[0].Text()
+		if first == h.Title || strings.Contains(h.Title, first) || strings.Contains(first, h.Title) {
+			paragraphs = paragraphs[1:]
+		}
+	}
+	lines := make([]string, 0, len(paragraphs))
+	for _, para := range paragraphs {
+		if md := para.Markdown(); md != "" {
+			lines = append(lines, md)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
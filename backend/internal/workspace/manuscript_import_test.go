@@ -0,0 +1,99 @@
+package workspace
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"backend/internal/rag/parsers"
+
+	"github.com/stretchr/testify/require"
+)
+
+func sampleManuscriptBook() *manuscriptBook {
+	return &manuscriptBook{
+		Identifier: "urn:uuid:sample",
+		Title:      "长河",
+		Author:     "佚名",
+		Language:   "zh-CN",
+		Modified:   time.Now(),
+		Sections: []manuscriptSection{
+			{Level: 1, Title: "第一卷 春"},
+			{Level: 2, Title: "第一章 渡口", Blocks: parseMarkup("他说：**快走**。\n---\n> 旧信")},
+			{Level: 3, Title: "场景一", Blocks: parseMarkup("*夜雨*落下")},
+			{Level: 2, Title: "第二章 归舟", Blocks: parseMarkup("船到了。")},
+			{Level: 1, Title: "第二卷 秋"},
+			{Level: 2, Title: "第三章 霜降", Blocks: parseMarkup("天冷了。")},
+		},
+	}
+}
+
+func TestImportManuscriptRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	db := setupWorkspaceTestDB(t)
+	svc := NewService(db)
+
+	writers := map[string]func(*bytes.Buffer, *manuscriptBook) error{
+		"长河.epub": func(buf *bytes.Buffer, b *manuscriptBook) error { return writeEPUB(buf, b) },
+		"长河.docx": func(buf *bytes.Buffer, b *manuscriptBook) error { return writeDOCX(buf, b) },
+	}
+	for fileName, write := range writers {
+		t.Run(fileName, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, write(&buf, sampleManuscriptBook()))
+
+			result, err := svc.ImportManuscript(ctx, &ImportManuscriptRequest{
+				TenantID: "tenant-import",
+				FileName: fileName,
+				Reader:   &buf,
+				UserID:   "user",
+			})
+			require.NoError(t, err)
+			require.Equal(t, "长河", result.WorkName)
+			require.Equal(t, 2, result.VolumeCount)
+			require.Equal(t, 3, result.ChapterCount)
+			require.Len(t, result.Chapters, 3)
+			require.Equal(t, "第一章 渡口", result.Chapters[0].Title)
+			require.Equal(t, "第一卷 春", result.Chapters[0].Volume)
+			require.Equal(t, "第二卷 秋", result.Chapters[2].Volume)
+			total := 0
+			for _, ch := range result.Chapters {
+				require.Positive(t, ch.WordCount)
+				total += ch.WordCount
+			}
+			require.Equal(t, result.TotalWords, total)
+
+			view, err := svc.GetOutlineView(ctx, "tenant-import", result.WorkID)
+			require.NoError(t, err)
+			volumes := view.Items[0].Children
+			require.Len(t, volumes, 2)
+			require.Equal(t, ContentTypeVolume, volumes[0].Category)
+			require.Equal(t, []string{"第一章 渡口", "第二章 归舟"}, []string{volumes[0].Children[0].Name, volumes[0].Children[1].Name})
+
+			detail, err := svc.GetFileDetail(ctx, "tenant-import", result.Chapters[0].NodeID)
+			require.NoError(t, err)
+			require.Equal(t, "他说：**快走**。\n---\n> 旧信\n# 场景一\n*夜雨*落下", detail.Version.Content)
+		})
+	}
+}
+
+func TestBuildImportOutlineWithoutVolumes(t *testing.T) {
+	headings := headingsFromParagraphs(nil)
+	require.Empty(t, headings)
+
+	entries := buildImportOutline([]importHeading{
+		{Level: 0, Paragraphs: parseTestParagraphs("楔子正文")},
+		{Title: "第一章", Level: 2, Paragraphs: parseTestParagraphs("甲")},
+		{Title: "第二章", Level: 2, Paragraphs: parseTestParagraphs("乙")},
+		{Level: 0, Paragraphs: parseTestParagraphs("乙续")},
+	})
+	require.Len(t, entries, 3)
+	require.Equal(t, "序章", entries[0].Title)
+	require.Equal(t, "乙\n乙续", entries[2].Content)
+	require.Empty(t, entries[1].Children)
+}
+
+func parseTestParagraphs(text string) []parsers.Paragraph {
+	return []parsers.Paragraph{{Runs: []parsers.Run{{Text: text}}}}
+}
@@ -0,0 +1,101 @@
+package workspace
+
+import (
+	"bufio"
+	"encoding/xml"
+	"strings"
+)
+
+// markupBlock 章节正文中的一个块（段落、标题、引用或场景分隔）
+type markupBlock struct {
+	Heading int // Markdown 标题级别，0 表示普通段落
+	Quote   bool
+	Break   bool // 场景分隔线（--- / ***）
+	Runs    []markupRun
+}
+
+// markupRun 行内文本片段
+type markupRun struct {
+	Text   string
+	Bold   bool
+	Italic bool
+}
+
+// parseMarkup 将章节正文（纯文本或简单 Markdown）解析为块，每个非空行视为一个段落
+func parseMarkup(content string) []markupBlock {
+	var blocks []markupBlock
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		switch {
+		case line == "---" || line == "***" || line == "* * *":
+			blocks = append(blocks, markupBlock{Break: true})
+		case strings.HasPrefix(line, "#"):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			text := strings.TrimSpace(line[level:])
+			if level > 6 || text == "" {
+				blocks = append(blocks, markupBlock{Runs: parseInline(line)})
+				continue
+			}
+			blocks = append(blocks, markupBlock{Heading: level, Runs: parseInline(text)})
+		case strings.HasPrefix(line, ">"):
+			blocks = append(blocks, markupBlock{Quote: true, Runs: parseInline(strings.TrimSpace(line[1:]))})
+		default:
+			blocks = append(blocks, markupBlock{Runs: parseInline(line)})
+		}
+	}
+	return blocks
+}
+
+// parseInline 解析 **粗体** 与 *斜体*，标记不成对时按原文输出
+func parseInline(text string) []markupRun {
+	var runs []markupRun
+	var buf strings.Builder
+	bold, italic := false, false
+	flush := func() {
+		if buf.Len() > 0 {
+			runs = append(runs, markupRun{Text: buf.String(), Bold: bold, Italic: italic})
+			buf.Reset()
+		}
+	}
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], "**"):
+			flush()
+			bold = !bold
+			i += 2
+		case text[i] == '*':
+			flush()
+			italic = !italic
+			i++
+		default:
+			buf.WriteByte(text[i])
+			i++
+		}
+	}
+	flush()
+	if bold || italic {
+		return []markupRun{{Text: text}}
+	}
+	return runs
+}
+
+// plainText 块的纯文本内容
+func (b markupBlock) plainText() string {
+	var sb strings.Builder
+	for _, r := range b.Runs {
+		sb.WriteString(r.Text)
+	}
+	return sb.String()
+}
+
+// xmlEscape 转义 XML 文本，非法字符替换为 U+FFFD
+func xmlEscape(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -29,6 +29,12 @@ type Service struct {
 	db          *gorm.DB
 	naming      *AutoNamingPolicy
 	initialized sync.Map
+	observer    StagingReviewObserver
+}
+
+// StagingReviewObserver 暂存文件审核结果观察者（如 A/B 实验的采纳率统计）
+type StagingReviewObserver interface {
+	OnStagingReviewed(ctx context.Context, staging *WorkspaceStagingFile, accepted bool)
 }
 
 // 业务内通用错误
@@ -109,6 +115,24 @@ func NewService(db *gorm.DB) *Service {
 	}
 }
 
+// SetStagingReviewObserver 设置暂存审核观察者
+func (s *Service) SetStagingReviewObserver(observer StagingReviewObserver) {
+	s.observer = observer
+}
+
+// notifyStagingReviewed 通知暂存文件被采纳或拒绝
+func (s *Service) notifyStagingReviewed(ctx context.Context, staging *WorkspaceStagingFile) {
+	if s.observer == nil || staging == nil {
+		return
+	}
+	switch staging.Status {
+	case StagingStatusApprovedPendingArchive, StagingStatusArchived:
+		s.observer.OnStagingReviewed(ctx, staging, true)
+	case StagingStatusRejected:
+		s.observer.OnStagingReviewed(ctx, staging, false)
+	}
+}
+
 // TreeNode 用于前端展示
 type TreeNode struct {
 	ID          string      `json:"id"`
@@ -870,6 +894,7 @@ func (s *Service) ReviewStagingFile(ctx context.Context, req *ReviewStagingReque
 	if err != nil {
 		return nil, err
 	}
+	s.notifyStagingReviewed(ctx, &result)
 	return &result, nil
 }
 
@@ -1290,8 +1315,8 @@ func (s *Service) markStagingFailure(tx *gorm.DB, staging *WorkspaceStagingFile,
 func (s *Service) PublishStagingFile(ctx context.Context, tenantID, stagingID, reviewerID string) (*WorkspaceFile, *WorkspaceFileVersion, error) {
 	var file *WorkspaceFile
 	var version *WorkspaceFileVersion
+	var staging WorkspaceStagingFile
 	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var staging WorkspaceStagingFile
 		if err := tx.Where("id = ? AND tenant_id = ?", stagingID, tenantID).First(&staging).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return errors.New("暂存记录不存在")
@@ -1318,14 +1343,22 @@ func (s *Service) PublishStagingFile(ctx context.Context, tenantID, stagingID, r
 	}); err != nil {
 		return nil, nil, err
 	}
+	s.notifyStagingReviewed(ctx, &staging)
 	return file, version, nil
 }
 
 // RejectStagingFile 拒绝暂存
 func (s *Service) RejectStagingFile(ctx context.Context, tenantID, stagingID, reviewerID, reason string) error {
+	// 元数据会被覆盖，先读取原记录供观察者使用
+	var staging WorkspaceStagingFile
+	if s.observer != nil {
+		if err := s.db.WithContext(ctx).Where("id = ? AND tenant_id = ?", stagingID, tenantID).First(&staging).Error; err != nil {
+			return err
+		}
+	}
 	metaPatch := map[string]string{"review_note": reason}
 	payload, _ := json.Marshal(metaPatch)
-	return s.db.WithContext(ctx).
+	if err := s.db.WithContext(ctx).
 		Model(&WorkspaceStagingFile{}).
 		Where("id = ? AND tenant_id = ?", stagingID, tenantID).
 		Updates(map[string]any{
@@ -1334,7 +1367,14 @@ func (s *Service) RejectStagingFile(ctx context.Context, tenantID, stagingID, re
 			"reviewed_at": time.Now().UTC(),
 			"updated_by":  reviewerID,
 			"metadata":    string(payload),
-		}).Error
+		}).Error; err != nil {
+		return err
+	}
+	if s.observer != nil {
+		staging.Status = StagingStatusRejected
+		s.notifyStagingReviewed(ctx, &staging)
+	}
+	return nil
 }
 
 // CreateContextLink 记录命令上下文
@@ -0,0 +1,64 @@
+package aiinterface
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ToolCallAccumulator 将流式工具调用增量合并为完整的工具调用
+type ToolCallAccumulator struct {
+	calls map[int]*ToolCall
+}
+
+// NewToolCallAccumulator 创建工具调用合并器
+func NewToolCallAccumulator() *ToolCallAccumulator {
+	return &ToolCallAccumulator{calls: make(map[int]*ToolCall)}
+}
+
+// Add 合并一批增量
+func (a *ToolCallAccumulator) Add(deltas []ToolCallDelta) {
+	for _, delta := range deltas {
+		call, ok := a.calls[delta.Index]
+		if !ok {
+			call = &ToolCall{Type: "function"}
+			a.calls[delta.Index] = call
+		}
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+// Len 已合并的工具调用数量
+func (a *ToolCallAccumulator) Len() int {
+	return len(a.calls)
+}
+
+// ToolCalls 按序号返回完整的工具调用，缺少 ID 的调用会生成占位 ID
+func (a *ToolCallAccumulator) ToolCalls() []ToolCall {
+	indexes := make([]int, 0, len(a.calls))
+	for idx := range a.calls {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	result := make([]ToolCall, 0, len(indexes))
+	for _, idx := range indexes {
+		call := *a.calls[idx]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", idx)
+		}
+		if call.Function.Arguments == "" {
+			call.Function.Arguments = "{}"
+		}
+		result = append(result, call)
+	}
+	return result
+}
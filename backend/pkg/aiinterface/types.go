@@ -21,6 +21,33 @@ type ChatCompletionRequest struct {
 	Tools       []Tool         `json:"tools,omitempty"`        // 可用工具列表（Function Calling）
 	ToolChoice  any            `json:"tool_choice,omitempty"`  // "auto", "none", 或指定工具
 	ExtraParams map[string]any `json:"extra_params"`           // 额外参数
+
+	// ResponseFormat 结构化输出约束（可选），由各提供商转换为原生能力
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// 结构化输出类型
+const (
+	ResponseFormatJSONObject = "json_object" // 仅要求输出合法 JSON
+	ResponseFormatJSONSchema = "json_schema" // 按 JSON Schema 输出
+)
+
+// ResponseFormat 结构化输出约束
+// OpenAI 使用 response_format.json_schema，Gemini 使用 responseSchema，
+// Anthropic 通过强制调用以 Schema 为参数的工具实现；不支持的提供商忽略该字段
+type ResponseFormat struct {
+	Type   string         `json:"type"`             // json_object, json_schema
+	Name   string         `json:"name,omitempty"`   // Schema 名称（仅字母、数字、下划线与短横线）
+	Schema map[string]any `json:"schema,omitempty"` // JSON Schema
+	Strict bool           `json:"strict,omitempty"` // 严格模式（OpenAI）
+}
+
+// SchemaName 返回 Schema 名称，未设置时为 structured_output
+func (f *ResponseFormat) SchemaName() string {
+	if f == nil || f.Name == "" {
+		return "structured_output"
+	}
+	return f.Name
 }
 
 // ChatCompletionResponse 对话补全响应
@@ -41,10 +68,33 @@ type Usage struct {
 
 // StreamChunk 流式响应块
 type StreamChunk struct {
-	ID      string `json:"id"`      // 响应 ID
-	Model   string `json:"model"`   // 使用的模型
-	Content string `json:"content"` // 增量内容
-	Done    bool   `json:"done"`    // 是否结束
+	ID           string          `json:"id"`                      // 响应 ID
+	Model        string          `json:"model"`                   // 使用的模型
+	Content      string          `json:"content"`                 // 增量内容
+	Done         bool            `json:"done"`                    // 是否结束
+	ToolCalls    []ToolCallDelta `json:"tool_calls,omitempty"`    // 工具调用增量（按 Index 合并）
+	FinishReason string          `json:"finish_reason,omitempty"` // 结束原因（见 FinishReason* 常量）
+	Usage        *Usage          `json:"usage,omitempty"`         // Token 使用情况（通常只在最后的块中出现）
+}
+
+// 流式结束原因
+const (
+	FinishReasonStop          = "stop"           // 正常结束
+	FinishReasonLength        = "length"         // 达到最大 Token 数
+	FinishReasonToolCalls     = "tool_calls"     // 模型请求调用工具
+	FinishReasonContentFilter = "content_filter" // 被内容安全策略拦截
+)
+
+// ToolCallDelta 流式工具调用增量
+// 同一个工具调用可能分多个块返回：首块携带 ID 与函数名，后续块追加 Arguments 片段
+type ToolCallDelta struct {
+	Index    int    `json:"index"`          // 工具调用序号
+	ID       string `json:"id,omitempty"`   // 调用 ID（首块）
+	Type     string `json:"type,omitempty"` // 固定为 "function"
+	Function struct {
+		Name      string `json:"name,omitempty"`      // 函数名称（首块）
+		Arguments string `json:"arguments,omitempty"` // 参数 JSON 片段
+	} `json:"function"`
 }
 
 // EmbeddingRequest 向量化请求